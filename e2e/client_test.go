@@ -0,0 +1,371 @@
+package e2e
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// callTimeout bounds each call; the fake devpod answers immediately
+const callTimeout = 20 * time.Second
+
+// initializeParams are sent by every test client
+var initializeParams = map[string]interface{}{
+	"protocolVersion": "2024-11-05",
+	"capabilities":    map[string]interface{}{},
+	"clientInfo":      map[string]interface{}{"name": "e2e", "version": "1.0.0"},
+}
+
+// rpcError is a JSON-RPC error response
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+}
+
+// rpcMessage is any JSON-RPC message a server sends
+type rpcMessage struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *rpcError       `json:"error"`
+}
+
+// rpcClient sends requests to a running server
+type rpcClient interface {
+	// call returns the result of a request or the error the server answered with
+	call(t *testing.T, method string, params interface{}) (json.RawMessage, *rpcError)
+}
+
+// request encodes a JSON-RPC request
+func request(id int, method string, params interface{}) []byte {
+	message := map[string]interface{}{"jsonrpc": "2.0", "id": id, "method": method}
+	if params != nil {
+		message["params"] = params
+	}
+	data, _ := json.Marshal(message)
+	return data
+}
+
+// responses routes the responses read from a server to their callers
+type responses struct {
+	mu      sync.Mutex
+	nextID  int
+	pending map[string]chan rpcMessage
+}
+
+func newResponses() *responses {
+	return &responses{pending: make(map[string]chan rpcMessage)}
+}
+
+// register returns the ID of a new request and the channel its response is
+// delivered on
+func (r *responses) register() (int, chan rpcMessage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	ch := make(chan rpcMessage, 1)
+	r.pending[fmt.Sprint(r.nextID)] = ch
+	return r.nextID, ch
+}
+
+// deliver passes a response to its caller. Notifications and requests of
+// the server are ignored.
+func (r *responses) deliver(message rpcMessage) {
+	if message.Method != "" || len(message.ID) == 0 {
+		return
+	}
+	r.mu.Lock()
+	ch, ok := r.pending[string(message.ID)]
+	delete(r.pending, string(message.ID))
+	r.mu.Unlock()
+	if ok {
+		ch <- message
+	}
+}
+
+// wait returns the response delivered on ch
+func wait(t *testing.T, method string, ch chan rpcMessage) (json.RawMessage, *rpcError) {
+	t.Helper()
+	select {
+	case message := <-ch:
+		return message.Result, message.Error
+	case <-time.After(callTimeout):
+		t.Fatalf("No response to %s within %s", method, callTimeout)
+		return nil, nil
+	}
+}
+
+// serverArgs are the flags every test server is started with
+func serverArgs(t *testing.T, transport string) []string {
+	return []string{
+		"-transport=" + transport,
+		"-data-dir=" + t.TempDir(),
+		"-workspace-root=" + t.TempDir(),
+		"-ssh-pool=false",
+		"-ssh-config=" + filepath.Join(t.TempDir(), "ssh_config"),
+		// the full log is shown when a test fails
+		"-verbose=2",
+	}
+}
+
+// stdioClient talks to a server started with the stdio transport
+type stdioClient struct {
+	stdin     io.WriteCloser
+	responses *responses
+	// garbage collects stdout lines that are not JSON-RPC messages
+	mu      sync.Mutex
+	garbage []string
+}
+
+// startSTDIO starts the server binary with the stdio transport
+func startSTDIO(t *testing.T, devpod *fakeDevPod) *stdioClient {
+	t.Helper()
+	dir := buildBinaries(t)
+	cmd := exec.Command(filepath.Join(dir, "mcp-server-devpod"+exeSuffix()), serverArgs(t, "stdio")...)
+	cmd.Env = devpod.env(dir)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &stdioClient{stdin: stdin, responses: newResponses()}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		scanner := bufio.NewScanner(stdout)
+		scanner.Buffer(make([]byte, 4*1024*1024), 4*1024*1024)
+		for scanner.Scan() {
+			var message rpcMessage
+			if err := json.Unmarshal(scanner.Bytes(), &message); err != nil {
+				c.mu.Lock()
+				c.garbage = append(c.garbage, scanner.Text())
+				c.mu.Unlock()
+				continue
+			}
+			c.responses.deliver(message)
+		}
+	}()
+	t.Cleanup(func() {
+		stdin.Close()
+		cmd.Process.Signal(os.Interrupt)
+		exited := make(chan error, 1)
+		go func() { exited <- cmd.Wait() }()
+		select {
+		case <-exited:
+		case <-time.After(5 * time.Second):
+			cmd.Process.Kill()
+			<-exited
+		}
+		<-done
+		if t.Failed() {
+			t.Logf("Server stderr:\n%s", tail(stderr.String(), 4000))
+		}
+	})
+
+	if _, rpcErr := c.call(t, "initialize", initializeParams); rpcErr != nil {
+		t.Fatalf("initialize failed: %v", rpcErr)
+	}
+	return c
+}
+
+func (c *stdioClient) call(t *testing.T, method string, params interface{}) (json.RawMessage, *rpcError) {
+	t.Helper()
+	id, ch := c.responses.register()
+	if _, err := c.stdin.Write(append(request(id, method, params), '\n')); err != nil {
+		t.Fatalf("Failed to send %s: %v", method, err)
+	}
+	return wait(t, method, ch)
+}
+
+// nonProtocolOutput returns the stdout lines that were not JSON-RPC messages
+func (c *stdioClient) nonProtocolOutput() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.garbage...)
+}
+
+// streamsClient talks to a server started with the HTTP Streams transport
+type streamsClient struct {
+	url       string
+	session   string
+	responses *responses
+	// notifications collects the notifications of the event stream
+	mu            sync.Mutex
+	notifications []rpcMessage
+}
+
+// startStreams starts the server binary with the HTTP Streams transport,
+// initializes a session and opens its event stream
+func startStreams(t *testing.T, devpod *fakeDevPod) *streamsClient {
+	t.Helper()
+	dir := buildBinaries(t)
+	port := freePort(t)
+	cmd := exec.Command(filepath.Join(dir, "mcp-server-devpod"+exeSuffix()), append(serverArgs(t, "http-streams"), "-addr="+port)...)
+	cmd.Env = devpod.env(dir)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(func() {
+		cancel()
+		cmd.Process.Signal(os.Interrupt)
+		exited := make(chan error, 1)
+		go func() { exited <- cmd.Wait() }()
+		select {
+		case <-exited:
+		case <-time.After(5 * time.Second):
+			cmd.Process.Kill()
+			<-exited
+		}
+		if t.Failed() {
+			t.Logf("Server output:\n%s", tail(output.String(), 4000))
+		}
+	})
+
+	c := &streamsClient{url: "http://127.0.0.1:" + port + "/mcp", responses: newResponses()}
+	deadline := time.Now().Add(callTimeout)
+	for {
+		resp, err := http.Get("http://127.0.0.1:" + port + "/health")
+		if err == nil {
+			resp.Body.Close()
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Server did not become healthy: %v", err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return c.open(ctx, t)
+}
+
+// open sends initialize, which returns the session ID, and reads the
+// session's event stream in the background
+func (c *streamsClient) open(ctx context.Context, t *testing.T) *streamsClient {
+	t.Helper()
+	resp, err := http.Post(c.url, "application/json", bytes.NewReader(request(0, "initialize", initializeParams)))
+	if err != nil {
+		t.Fatalf("initialize failed: %v", err)
+	}
+	defer resp.Body.Close()
+	var message rpcMessage
+	if err := json.NewDecoder(resp.Body).Decode(&message); err != nil || message.Error != nil {
+		t.Fatalf("initialize failed: %v %v", err, message.Error)
+	}
+	c.session = resp.Header.Get("Mcp-Session-Id")
+	if c.session == "" {
+		t.Fatal("initialize returned no Mcp-Session-Id")
+	}
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	req.Header.Set("Mcp-Session-Id", c.session)
+	req.Header.Set("Accept", "text/event-stream")
+	stream, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to open the event stream: %v", err)
+	}
+	if stream.StatusCode != http.StatusOK {
+		stream.Body.Close()
+		t.Fatalf("Event stream returned %s", stream.Status)
+	}
+	go func() {
+		defer stream.Body.Close()
+		scanner := bufio.NewScanner(stream.Body)
+		scanner.Buffer(make([]byte, 4*1024*1024), 4*1024*1024)
+		for scanner.Scan() {
+			data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+			if !ok {
+				continue
+			}
+			var message rpcMessage
+			if json.Unmarshal([]byte(data), &message) != nil {
+				continue
+			}
+			if message.Method != "" && len(message.ID) == 0 {
+				c.mu.Lock()
+				c.notifications = append(c.notifications, message)
+				c.mu.Unlock()
+			}
+			c.responses.deliver(message)
+		}
+	}()
+	return c
+}
+
+func (c *streamsClient) call(t *testing.T, method string, params interface{}) (json.RawMessage, *rpcError) {
+	t.Helper()
+	id, ch := c.responses.register()
+	req, _ := http.NewRequest(http.MethodPost, c.url, bytes.NewReader(request(id, method, params)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Mcp-Session-Id", c.session)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to send %s: %v", method, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("%s returned %s", method, resp.Status)
+	}
+	return wait(t, method, ch)
+}
+
+// received returns the notifications of a method received so far
+func (c *streamsClient) received(method string) []rpcMessage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var found []rpcMessage
+	for _, message := range c.notifications {
+		if message.Method == method {
+			found = append(found, message)
+		}
+	}
+	return found
+}
+
+// freePort returns a port nothing listens on
+func freePort(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	return port
+}
+
+// tail returns the end of long output
+func tail(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return "..." + s[len(s)-n:]
+}
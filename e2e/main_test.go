@@ -0,0 +1,155 @@
+// Package e2e drives the built server binary over its transports. A fake
+// devpod on PATH, built from testdata/fakedevpod, records every invocation
+// and answers with canned output, so the tests check the devpod command each
+// tool runs and the response the client receives.
+package e2e
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// shimDirEnv names the directory the fake devpod reads its responses from
+// and records its calls into
+const shimDirEnv = "FAKE_DEVPOD_DIR"
+
+// shimResponse is the output of a fake devpod command, see
+// testdata/fakedevpod
+type shimResponse struct {
+	Stdout string `json:"stdout,omitempty"`
+	Stderr string `json:"stderr,omitempty"`
+	Exit   int    `json:"exit,omitempty"`
+}
+
+var (
+	buildOnce sync.Once
+	buildErr  error
+	// binDir holds the server binary and the fake devpod
+	binDir string
+)
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if binDir != "" {
+		os.RemoveAll(binDir)
+	}
+	os.Exit(code)
+}
+
+// buildBinaries builds the server and the fake devpod into a shared
+// directory once per run
+func buildBinaries(t *testing.T) string {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("skipping end-to-end test in short mode")
+	}
+	buildOnce.Do(func() {
+		if binDir, buildErr = os.MkdirTemp("", "devpod-mcp-e2e-"); buildErr != nil {
+			return
+		}
+		goBin := filepath.Join(runtime.GOROOT(), "bin", "go")
+		if _, err := os.Stat(goBin); err != nil {
+			goBin = "go"
+		}
+		for name, pkg := range map[string]string{"mcp-server-devpod": "..", "devpod": "./testdata/fakedevpod"} {
+			build := exec.Command(goBin, "build", "-o", filepath.Join(binDir, name+exeSuffix()), pkg)
+			if output, err := build.CombinedOutput(); err != nil {
+				buildErr = fmt.Errorf("go build %s: %v: %s", pkg, err, output)
+				return
+			}
+		}
+	})
+	if buildErr != nil {
+		t.Fatalf("Failed to build the binaries: %v", buildErr)
+	}
+	return binDir
+}
+
+func exeSuffix() string {
+	if runtime.GOOS == "windows" {
+		return ".exe"
+	}
+	return ""
+}
+
+// fakeDevPod is the response and call directory of one server's fake devpod
+type fakeDevPod struct {
+	dir string
+}
+
+func newFakeDevPod(t *testing.T, responses map[string]shimResponse) *fakeDevPod {
+	t.Helper()
+	f := &fakeDevPod{dir: t.TempDir()}
+	data, err := json.Marshal(responses)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(f.dir, "responses.json"), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+// calls returns the recorded invocations, each joined by spaces
+func (f *fakeDevPod) calls(t *testing.T) []string {
+	t.Helper()
+	file, err := os.Open(filepath.Join(f.dir, "calls.jsonl"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var calls []string
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+	for scanner.Scan() {
+		var args []string
+		if err := json.Unmarshal(scanner.Bytes(), &args); err != nil {
+			t.Fatalf("Invalid call record %q: %v", scanner.Text(), err)
+		}
+		calls = append(calls, strings.Join(args, " "))
+	}
+	return calls
+}
+
+// reset forgets the recorded invocations
+func (f *fakeDevPod) reset(t *testing.T) {
+	t.Helper()
+	if err := os.Remove(filepath.Join(f.dir, "calls.jsonl")); err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+}
+
+// env returns the environment of a server using the fake devpod
+func (f *fakeDevPod) env(binDir string) []string {
+	env := []string{shimDirEnv + "=" + f.dir}
+	for _, kv := range os.Environ() {
+		if strings.HasPrefix(kv, "PATH=") || strings.HasPrefix(kv, shimDirEnv+"=") {
+			continue
+		}
+		env = append(env, kv)
+	}
+	return append(env, "PATH="+binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+// sortedKeys returns the keys of a map in order
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
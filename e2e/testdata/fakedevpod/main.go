@@ -0,0 +1,64 @@
+// Command fakedevpod stands in for the devpod CLI in the end-to-end tests.
+// It appends its arguments as a JSON array to calls.jsonl in $FAKE_DEVPOD_DIR
+// and prints the response in responses.json whose key is the longest prefix
+// of the space-joined arguments. Commands without a response succeed
+// without output.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type response struct {
+	Stdout string `json:"stdout,omitempty"`
+	Stderr string `json:"stderr,omitempty"`
+	Exit   int    `json:"exit,omitempty"`
+}
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	dir := os.Getenv("FAKE_DEVPOD_DIR")
+	if dir == "" {
+		fmt.Fprintln(os.Stderr, "fake devpod: FAKE_DEVPOD_DIR is not set")
+		return 1
+	}
+
+	line, _ := json.Marshal(args)
+	f, err := os.OpenFile(filepath.Join(dir, "calls.jsonl"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fake devpod: %v\n", err)
+		return 1
+	}
+	_, err = f.Write(append(line, '\n'))
+	f.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fake devpod: %v\n", err)
+		return 1
+	}
+
+	var responses map[string]response
+	if data, err := os.ReadFile(filepath.Join(dir, "responses.json")); err == nil {
+		if err := json.Unmarshal(data, &responses); err != nil {
+			fmt.Fprintf(os.Stderr, "fake devpod: invalid responses: %v\n", err)
+			return 1
+		}
+	}
+	key := strings.Join(args, " ")
+	var match string
+	var r response
+	for prefix, candidate := range responses {
+		if (key == prefix || strings.HasPrefix(key, prefix+" ")) && len(prefix) >= len(match) {
+			match, r = prefix, candidate
+		}
+	}
+	fmt.Fprint(os.Stdout, r.Stdout)
+	fmt.Fprint(os.Stderr, r.Stderr)
+	return r.Exit
+}
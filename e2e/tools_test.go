@@ -0,0 +1,414 @@
+package e2e
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+type obj = map[string]interface{}
+
+// toolCase is a call of one tool and what it must produce
+type toolCase struct {
+	tool string
+	args obj
+	// commands must each match a devpod invocation the call caused. A
+	// command ending in " ..." matches invocations it is a prefix of.
+	commands []string
+	// text must each appear in the text content of the result, e.g. the
+	// "key:value" of a result field
+	text []string
+	// errorCode expects the call to fail with this JSON-RPC error code
+	errorCode int
+	// network marks calls that need internet access and may fail offline
+	network bool
+	// save captures the first group of a pattern in the result text into
+	// a variable that later arguments reference as "$name"
+	save map[string]string
+}
+
+// toolCases covers every tool. They run in order on one server, so later
+// cases see the workspaces, prebuilds, schedules and secrets of earlier ones.
+var toolCases = []toolCase{
+	{tool: "devpod_listWorkspaces", commands: []string{"list --output json"}, text: []string{"1 workspace: api", `"gitRepository":"https://github.com/example/api"`}},
+	{tool: "devpod_listWorkspaces", args: obj{"format": "markdown"}, commands: []string{"list --output json"}, text: []string{"### workspaces", "| api |"}},
+	{
+		tool:     "devpod_createWorkspace",
+		args:     obj{"name": "web", "source": "https://github.com/example/web", "provider": "docker", "ide": "none"},
+		commands: []string{"up https://github.com/example/web --id web --ide none --provider docker"},
+		text:     []string{"created:true", "name:web", "message:Workspace created successfully"},
+	},
+	{
+		tool: "devpod_composeDevcontainer",
+		args: obj{"path": "svc", "image": "mcr.microsoft.com/devcontainers/go:1", "forwardPorts": []int{8080}},
+		text: []string{"folderCreated:true", `"image": "mcr.microsoft.com/devcontainers/go:1"`, "devcontainer.json"},
+	},
+	{tool: "devpod_analyzeSource", args: obj{"source": "svc"}, commands: []string{"provider list --output json"}, text: []string{"analysis:", "sourceType:local"}},
+	{tool: "devpod_listLocalProjects", text: []string{"Found 1 project(s)", `"count":1`, "svc", "workspace root"}},
+	{
+		tool:     "devpod_cloneWorkspace",
+		args:     obj{"name": "api", "newName": "api-copy"},
+		commands: []string{"up https://github.com/example/api --id api-copy --provider docker --ide vscode"},
+		text:     []string{"clonedFrom:api", "name:api-copy"},
+	},
+	{tool: "devpod_exportWorkspace", args: obj{"name": "api"}, commands: []string{"list --output json"}, text: []string{"spec:", `"gitRepository": "https://github.com/example/api"`}},
+	{
+		tool:     "devpod_importWorkspace",
+		args:     obj{"name": "api-imported", "spec": obj{"version": 1, "source": obj{"gitRepository": "https://github.com/example/api"}, "provider": obj{"name": "docker"}}},
+		commands: []string{"up https://github.com/example/api --id api-imported --provider docker"},
+		text:     []string{"name:api-imported", "message:Workspace imported successfully"},
+	},
+	{
+		tool:     "devpod_createEnvironment",
+		args:     obj{"name": "stack", "workspaces": []interface{}{obj{"name": "db", "source": "https://github.com/example/db"}}},
+		commands: []string{"up https://github.com/example/db --id db"},
+		text:     []string{"name:stack", "success:true"},
+	},
+	{tool: "devpod_listEnvironments", commands: []string{"status db --output json"}, text: []string{"1 environment: stack", `"count":1`}},
+	{tool: "devpod_deleteEnvironment", args: obj{"name": "stack"}, commands: []string{"delete db"}, text: []string{"success:true"}},
+	{
+		tool:     "devpod_triggerPrebuild",
+		args:     obj{"source": "https://github.com/example/api", "repository": "ghcr.io/example/prebuilds"},
+		commands: []string{"build https://github.com/example/api --repository ghcr.io/example/prebuilds"},
+		text:     []string{"prebuild:"},
+		save:     map[string]string{"prebuild": `id:(pb-[0-9a-f]+)`},
+	},
+	{tool: "devpod_listPrebuilds", text: []string{"1 prebuild: 1 succeeded", `"count":1`, "$prebuild"}},
+	{tool: "devpod_prebuildStatus", args: obj{"id": "$prebuild"}, text: []string{"$prebuild"}},
+	{tool: "devpod_deletePrebuild", args: obj{"id": "$prebuild"}, text: []string{"$prebuild"}},
+	{tool: "devpod_startWorkspace", args: obj{"name": "api"}, commands: []string{"up api"}, text: []string{"message:Workspace started successfully"}},
+	{
+		tool:     "devpod_openIDE",
+		args:     obj{"name": "api", "ide": "vscode"},
+		commands: []string{"up api --ide vscode --open-ide=false"},
+		text:     []string{"url:vscode://vscode-remote/ssh-remote+api.devpod/workspaces/api"},
+	},
+	{tool: "devpod_stopWorkspace", args: obj{"name": "api"}, commands: []string{"stop api"}, text: []string{"message:Workspace stopped successfully"}},
+	{tool: "devpod_deleteWorkspace", args: obj{"name": "old"}, commands: []string{"delete old"}, text: []string{"message:Workspace deleted successfully"}},
+	{tool: "devpod_listRecentOperations", args: obj{"name": "old"}, text: []string{`"count":1`, "deleted"}},
+	{tool: "devpod_undoLastOperation", args: obj{"id": "op-missing"}, errorCode: -32602},
+	{tool: "devpod_stopAll", args: obj{"names": []string{"api"}}, commands: []string{"stop api"}, text: []string{"changed:1", "failed:0"}},
+	{tool: "devpod_startAll", args: obj{"names": []string{"api"}}, commands: []string{"status api --output json"}, text: []string{"skipped:1", "failed:0"}},
+	{tool: "devpod_tagWorkspace", args: obj{"name": "api", "add": []string{"team-a"}}, text: []string{"tags:[team-a]"}},
+	{
+		tool: "devpod_scheduleOperation",
+		args: obj{"name": "api", "action": "stop", "cron": "0 19 * * 1-5"},
+		text: []string{"schedule:"},
+		save: map[string]string{"schedule": `id:(sch-[0-9a-f]+)`},
+	},
+	{tool: "devpod_listSchedules", text: []string{"1 schedule:", `"count":1`, "$schedule"}},
+	{tool: "devpod_cancelSchedule", args: obj{"id": "$schedule"}, text: []string{"message:"}},
+	{tool: "devpod_startWorkspace", args: obj{"name": "api", "autoStopAfter": "30m"}, commands: []string{"up api"}, text: []string{"autoStopAfter:30m", "autoStopSchedule:sch-"}},
+	{tool: "devpod_listProviders", commands: []string{"provider list --output json"}, text: []string{"1 provider: docker", `"initialized":true`}},
+	{tool: "devpod_searchProviders", args: obj{"query": "docker"}, commands: []string{"provider list --output json"}, text: []string{"providers:", "docker"}},
+	{tool: "devpod_updateProvider", args: obj{"name": "docker"}, commands: []string{"provider update docker"}, text: []string{"previousVersion:v0.0.1", "changed:false"}},
+	{tool: "devpod_addProvider", args: obj{"name": "kubernetes"}, commands: []string{"provider add kubernetes"}, text: []string{"message:Provider added successfully"}},
+	// Credentials depend on the machine, so only the argument checks run here
+	{tool: "devpod_configureAWSProvider", args: obj{"diskSize": -1}, errorCode: -32602},
+	{tool: "devpod_configureGCPProvider", args: obj{"diskSize": -1}, errorCode: -32602},
+	{tool: "devpod_configureAzureProvider", args: obj{"diskSize": -1}, errorCode: -32602},
+	// Hosts are registered without connecting to them
+	{tool: "devpod_addSSHHost", args: obj{"name": "build-box", "address": "build.example.com", "user": "dev", "test": false}, text: []string{"message:Host build-box registered", "replaced:false"}},
+	{tool: "devpod_listSSHHosts", text: []string{"Found 1 host(s)", `"count":1`, "build.example.com"}},
+	{tool: "devpod_testSSHHost", args: obj{"name": "missing"}, errorCode: -32602},
+	{tool: "devpod_removeSSHHost", args: obj{"name": "build-box"}, text: []string{"message:Host build-box removed"}},
+	{
+		tool:     "devpod_setProviderOptions",
+		args:     obj{"name": "docker", "options": obj{"DOCKER_HOST": "unix:///var/run/docker.sock"}},
+		commands: []string{"provider options docker --output json", "provider set-options docker ..."},
+		text:     []string{"name:docker"},
+	},
+	{tool: "devpod_ssh", args: obj{"name": "api", "command": "echo hi"}, commands: []string{"ssh api --command echo hi"}, text: []string{"output:hi"}},
+	{
+		tool:     "devpod_execTask",
+		args:     obj{"name": "api", "task": "postCreateCommand"},
+		commands: []string{"status api --output json", "ssh api --command for f in ...", "ssh api --command sh -c 'npm install' ..."},
+		text:     []string{"exitCode:0", "output:added 12 packages"},
+	},
+	{
+		tool:     "devpod_runTests",
+		args:     obj{"name": "api", "command": "go test ./..."},
+		commands: []string{"status api --output json", "ssh api --command if command -v timeout >/dev/null 2>&1; then timeout 600 sh -c 'go test ./...'; ..."},
+		text:     []string{"passed:1", "failed:0", "success:true"},
+	},
+	{tool: "devpod_getSSHConfig", args: obj{"name": "api"}, commands: []string{"list --output json"}, text: []string{"host:api.devpod", "source:generated", "ProxyCommand devpod ssh --stdio"}},
+	{tool: "devpod_syncDirectory", args: obj{"name": "api", "localPath": "missing-dir", "remotePath": "data"}, errorCode: -32602},
+	{tool: "devpod_fetchArtifact", args: obj{"name": "api", "path": "bin/app", "localPath": "missing-dir/app"}, errorCode: -32602},
+	{tool: "devpod_copyBetweenWorkspaces", args: obj{"source": "api", "path": "bin/app", "destination": "api"}, errorCode: -32602},
+	{tool: "devpod_snapshotWorkspace", args: obj{"name": "missing"}, commands: []string{"list --output json"}, errorCode: -32602},
+	{tool: "devpod_listSnapshots", text: []string{"0 snapshots", `"count":0`}},
+	{tool: "devpod_closeConnections", text: []string{"closed:", "ideTunnels:"}},
+	{tool: "devpod_estimateUsage", commands: []string{"list --output json"}, text: []string{"workspaces:"}},
+	{tool: "devpod_version", text: []string{"devpodVersion:v0.6.15"}},
+	{tool: "devpod_checkAvailability", commands: []string{"version"}, text: []string{"available:true", "version:v0.6.15"}},
+	{tool: "devpod_installCLI", commands: []string{"version"}, text: []string{"installed:false"}},
+	// The runtimes depend on the machine, so only the argument checks run here
+	{tool: "devpod_doctor", args: obj{"runtime": "podman"}, errorCode: -32602},
+	{tool: "devpod_checkUpgrade", commands: []string{"version"}, network: true},
+	{tool: "devpod_gcWorkspaces", args: obj{"dryRun": true}, commands: []string{"list --output json"}, text: []string{"report:"}},
+	{tool: "devpod_setDefaults", args: obj{"provider": "docker"}, text: []string{"defaults:", "docker"}},
+	{tool: "devpod_troubleshoot", args: obj{"name": "api"}, commands: []string{"troubleshoot api"}, text: []string{"name:api", "sections:"}},
+	{
+		tool:     "devpod_exportLogsBundle",
+		args:     obj{"names": []string{"api"}},
+		commands: []string{"list --output json", "troubleshoot api"},
+		text:     []string{"format:tar.gz", "troubleshoot/api.txt", "encoding:base64"},
+	},
+	{tool: "devpod_getFullOutput", args: obj{"id": "missing"}, errorCode: -32602},
+	{tool: "devpod_serverEvents", text: []string{"events:"}},
+	{tool: "devpod_whoami", text: []string{"multiUser:false", "contextSource:devpod"}},
+	{tool: "devpod_status", args: obj{"name": "api"}, commands: []string{"status api --output json"}, text: []string{"api: Running", `"state":"Running"`}},
+	{tool: "devpod_statusAll", args: obj{}, commands: []string{"list --output json", "status api --output json", "provider list --output json"}, text: []string{"1 workspace(s): 1 running; no problems", "- api: Running on docker", `"states":{"Running":1}`}},
+	{tool: "devpod_workspaceStats", args: obj{"name": "api"}, commands: []string{"ssh api --command ..."}, text: []string{"name:api", "topProcesses:"}},
+	{
+		tool:     "devpod_listProcesses",
+		args:     obj{"name": "api"},
+		commands: []string{"ssh api --command ps -eo pid=,ppid=,user=,pcpu=,pmem=,rss=,etimes=,args= 2>/dev/null"},
+		text:     []string{"Found 2 process(es)", `"total":2`, "/usr/local/bin/node server.js"},
+	},
+	{tool: "devpod_listOpenPorts", args: obj{"name": "api"}, commands: []string{"ssh api --command ss -ltnpH 2>/dev/null || netstat -ltnp 2>/dev/null"}, text: []string{"8080"}},
+	{tool: "devpod_networkInfo", args: obj{"name": "api", "targets": []string{"db:5432"}}, commands: []string{"ssh api --command ..."}, text: []string{"name:api", "reachability:[{db:5432 db 5432"}},
+	{
+		tool:     "devpod_killProcess",
+		args:     obj{"name": "api", "pid": 4242},
+		commands: []string{"ssh api --command kill -s TERM 4242 ..."},
+		text:     []string{"exited:true", "pid:4242"},
+	},
+	{tool: "devpod_gitStatus", args: obj{"name": "api"}, commands: []string{"ssh api --command echo ---devpod-git-status--- && git status --porcelain=v2 --branch"}, text: []string{"branch=main", `"branch":"main"`}},
+	{tool: "devpod_gitPull", args: obj{"name": "api"}, commands: []string{"ssh api --command git pull --ff-only ..."}, text: []string{"branch:main"}},
+	{tool: "devpod_gitCheckout", args: obj{"name": "api", "branch": "feature"}, commands: []string{"ssh api --command git checkout 'feature' ..."}, text: []string{"branch:main"}},
+	{tool: "devpod_setSecret", args: obj{"name": "TOKEN", "value": "s3cret"}, text: []string{"TOKEN"}},
+	{tool: "devpod_listSecrets", text: []string{"Found 1 secret(s)"}},
+	{tool: "devpod_deleteSecret", args: obj{"name": "TOKEN"}, text: []string{"message:Secret TOKEN deleted"}},
+}
+
+// gitStatusOutput is what git status --porcelain=v2 --branch prints after
+// the marker the git tools put before it
+const gitStatusOutput = "---devpod-git-status---\n# branch.oid 3f2c1d0e\n# branch.head main\n"
+
+// cannedResponses are the fake devpod's outputs, keyed by argument prefix
+var cannedResponses = map[string]shimResponse{
+	"version":                               {Stdout: "v0.6.15"},
+	"list --output json":                    {Stdout: `[{"id":"api","provider":{"name":"docker"},"ide":{"name":"vscode"},"source":{"gitRepository":"https://github.com/example/api"},"creationTimestamp":"2024-01-01T00:00:00Z","lastUsed":"2024-01-01T00:00:00Z","context":"default"}]`},
+	"provider list --output json":           {Stdout: `{"docker":{"config":{"name":"docker","version":"v0.0.1"},"default":true,"state":{"initialized":true}}}`},
+	"provider options docker --output json": {Stdout: `{"DOCKER_HOST":{"description":"The docker host to use"}}`},
+	"status api --output json":              {Stdout: `{"id":"api","context":"default","provider":"docker","state":"Running"}`},
+	"ssh api --command echo hi":             {Stdout: "hi\n"},
+	"ssh api --command ps":                  {Stdout: "    1     0 root  0.0  0.1  1024  3600 /sbin/init\n   42     1 node 12.5  2.0 40960   120 /usr/local/bin/node server.js\n"},
+	"ssh api --command ss":                  {Stdout: `LISTEN 0 511 0.0.0.0:8080 0.0.0.0:* users:(("node",pid=42,fd=20))` + "\n"},
+	"ssh api --command kill":                {Stdout: "exited\n"},
+	"ssh api --command echo":                {Stdout: gitStatusOutput},
+	"ssh api --command git":                 {Stdout: "Already up to date.\n" + gitStatusOutput},
+	"ssh api --command for":                 {Stdout: "\n---devpod-file--- .devcontainer/devcontainer.json\n" + `{"postCreateCommand": "npm install"}`},
+	"ssh api --command sh":                  {Stdout: "added 12 packages\n\n---devpod-exit--- 0\n"},
+	"ssh api --command if":                  {Stdout: "--- PASS: TestHealth (0.00s)\nok  \texample.com/api\t0.01s\n\n---devpod-exit--- 0\n"},
+}
+
+func TestToolsOverSTDIO(t *testing.T) {
+	devpod := newFakeDevPod(t, cannedResponses)
+	c := startSTDIO(t, devpod)
+	runToolCases(t, c, devpod)
+
+	// Anything else on stdout corrupts the stream for clients
+	if output := c.nonProtocolOutput(); len(output) > 0 {
+		t.Errorf("Expected only JSON-RPC messages on stdout, got:\n%s", strings.Join(output, "\n"))
+	}
+}
+
+func TestToolsOverHTTPStreams(t *testing.T) {
+	devpod := newFakeDevPod(t, cannedResponses)
+	c := startStreams(t, devpod)
+	runToolCases(t, c, devpod)
+}
+
+func TestCommandOutputStreamsOverHTTPStreams(t *testing.T) {
+	devpod := newFakeDevPod(t, cannedResponses)
+	c := startStreams(t, devpod)
+
+	// The session receives the console output ahead of the result, and a
+	// progress token adds progress notifications
+	params := obj{"name": "devpod_ssh", "arguments": obj{"name": "api", "command": "echo hi"}, "_meta": obj{"progressToken": "e2e"}}
+	if _, rpcErr := c.call(t, "tools/call", params); rpcErr != nil {
+		t.Fatalf("Unexpected error: %v", rpcErr)
+	}
+	output := c.received("devpod/commandOutput")
+	if len(output) != 1 || !strings.Contains(string(output[0].Params), `"lines":["hi"]`) || !strings.Contains(string(output[0].Params), `"tool":"devpod_ssh"`) {
+		t.Errorf("Unexpected command output notifications %v", output)
+	}
+	progress := c.received("notifications/progress")
+	if len(progress) != 1 || !strings.Contains(string(progress[0].Params), `"progressToken":"e2e"`) {
+		t.Errorf("Unexpected progress notifications %v", progress)
+	}
+}
+
+// runToolCases checks that the cases cover the listed tools and runs them
+func runToolCases(t *testing.T, c rpcClient, devpod *fakeDevPod) {
+	t.Helper()
+	checkCoverage(t, c)
+
+	vars := map[string]string{}
+	for _, tc := range toolCases {
+		tc := tc
+		t.Run(tc.tool, func(t *testing.T) {
+			devpod.reset(t)
+			result, rpcErr := c.call(t, "tools/call", obj{"name": tc.tool, "arguments": expand(tc.args, vars)})
+
+			switch {
+			case tc.errorCode != 0:
+				if rpcErr == nil || rpcErr.Code != tc.errorCode {
+					t.Fatalf("Expected error code %d, got %v", tc.errorCode, rpcErr)
+				}
+			case rpcErr != nil && tc.network:
+				t.Logf("Failed without network access: %v", rpcErr)
+			case rpcErr != nil:
+				t.Fatalf("Unexpected error: %v", rpcErr)
+			default:
+				text := resultText(t, result)
+				for _, want := range tc.text {
+					if want = expandString(want, vars); !strings.Contains(text, want) {
+						t.Errorf("Expected %q in the result, got %s", want, text)
+					}
+				}
+				for name, pattern := range tc.save {
+					match := regexp.MustCompile(pattern).FindStringSubmatch(text)
+					if match == nil {
+						t.Fatalf("Expected %s in the result, got %s", pattern, text)
+					}
+					vars[name] = match[1]
+				}
+			}
+			waitForCommands(t, devpod, tc.commands)
+		})
+	}
+}
+
+// checkCoverage fails for listed tools without a case and cases of tools
+// that are not listed
+func checkCoverage(t *testing.T, c rpcClient) {
+	t.Helper()
+	result, rpcErr := c.call(t, "tools/list", nil)
+	if rpcErr != nil {
+		t.Fatalf("tools/list failed: %v", rpcErr)
+	}
+	var list struct {
+		Tools []struct {
+			Name        string                 `json:"name"`
+			InputSchema map[string]interface{} `json:"inputSchema"`
+		} `json:"tools"`
+	}
+	if err := json.Unmarshal(result, &list); err != nil {
+		t.Fatalf("Invalid tools/list result: %v", err)
+	}
+
+	listed := map[string]bool{}
+	for _, tool := range list.Tools {
+		if !strings.HasPrefix(tool.Name, "devpod_") {
+			continue
+		}
+		if tool.InputSchema["type"] != "object" {
+			t.Errorf("Tool %s has no object input schema", tool.Name)
+		}
+		listed[tool.Name] = true
+	}
+	covered := map[string]bool{}
+	for _, tc := range toolCases {
+		covered[tc.tool] = true
+		if !listed[tc.tool] {
+			t.Errorf("Case for %s, which is not listed", tc.tool)
+		}
+	}
+	for _, name := range sortedKeys(listed) {
+		if !covered[name] {
+			t.Errorf("No case for tool %s", name)
+		}
+	}
+}
+
+// resultText returns the text content of a tool result
+func resultText(t *testing.T, result json.RawMessage) string {
+	t.Helper()
+	var call struct {
+		Content []struct {
+			Type     string `json:"type"`
+			Text     string `json:"text"`
+			Resource struct {
+				URI      string `json:"uri"`
+				MimeType string `json:"mimeType"`
+				Text     string `json:"text"`
+			} `json:"resource"`
+		} `json:"content"`
+	}
+	if err := json.Unmarshal(result, &call); err != nil {
+		t.Fatalf("Invalid tool result %s: %v", result, err)
+	}
+	if len(call.Content) == 0 || call.Content[0].Type != "text" || call.Content[0].Text == "" {
+		t.Fatalf("Expected text content, got %s", result)
+	}
+	// The full data of list and status tools follows their summary
+	text := call.Content[0].Text
+	for _, content := range call.Content[1:] {
+		if content.Type != "resource" || content.Resource.MimeType != "application/json" || !json.Valid([]byte(content.Resource.Text)) {
+			t.Fatalf("Expected a JSON resource after the text, got %s", result)
+		}
+		text += "\n" + content.Resource.Text
+	}
+	return text
+}
+
+// waitForCommands waits for the devpod invocations a call caused. Commands
+// of background work such as prebuilds may still be running after the
+// response.
+func waitForCommands(t *testing.T, devpod *fakeDevPod, commands []string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		calls := devpod.calls(t)
+		var missing []string
+		for _, want := range commands {
+			if !ranCommand(calls, want) {
+				missing = append(missing, want)
+			}
+		}
+		if len(missing) == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Errorf("Expected devpod %s, got calls:\n%s", strings.Join(missing, ", devpod "), strings.Join(calls, "\n"))
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func ranCommand(calls []string, want string) bool {
+	prefix, isPrefix := strings.CutSuffix(want, " ...")
+	for _, call := range calls {
+		if call == want || isPrefix && strings.HasPrefix(call, prefix+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// expand replaces "$name" string arguments by saved variables
+func expand(args obj, vars map[string]string) obj {
+	expanded := obj{}
+	for key, value := range args {
+		if s, ok := value.(string); ok {
+			value = expandString(s, vars)
+		}
+		expanded[key] = value
+	}
+	return expanded
+}
+
+func expandString(s string, vars map[string]string) string {
+	if name, ok := strings.CutPrefix(s, "$"); ok {
+		if value, ok := vars[name]; ok {
+			return value
+		}
+	}
+	return s
+}
@@ -1,5 +1,5 @@
 module github.com/Protobomb/mcp-server-devpod
 
-go 1.19
+go 1.21
 
 require github.com/protobomb/mcp-server-framework v1.2.2
@@ -1,18 +1,24 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
-	"os/exec"
 	"os/signal"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
+	"github.com/Protobomb/mcp-server-devpod/pkg/clientconfig"
+	"github.com/Protobomb/mcp-server-devpod/pkg/httptransport"
+	"github.com/Protobomb/mcp-server-devpod/pkg/selftest"
+	"github.com/Protobomb/mcp-server-devpod/pkg/server"
 	"github.com/protobomb/mcp-server-framework/pkg/mcp"
 	"github.com/protobomb/mcp-server-framework/pkg/transport"
 )
@@ -20,136 +26,98 @@ import (
 // version is set during build time via ldflags
 var version = "dev"
 
-// DevPodWorkspace represents a DevPod workspace
-type DevPodWorkspace struct {
-	ID                string                  `json:"id"`
-	UID               string                  `json:"uid"`
-	Picture           string                  `json:"picture,omitempty"`
-	Provider          DevPodWorkspaceProvider `json:"provider"`
-	Machine           map[string]interface{}  `json:"machine"`
-	IDE               DevPodWorkspaceIDE      `json:"ide"`
-	Source            DevPodWorkspaceSource   `json:"source"`
-	CreationTimestamp string                  `json:"creationTimestamp"`
-	LastUsed          string                  `json:"lastUsed"`
-	Context           string                  `json:"context"`
-}
-
-// DevPodWorkspaceProvider represents the provider configuration for a workspace
-type DevPodWorkspaceProvider struct {
-	Name    string                 `json:"name"`
-	Options map[string]interface{} `json:"options"`
-}
-
-// DevPodWorkspaceIDE represents the IDE configuration for a workspace
-type DevPodWorkspaceIDE struct {
-	Name string `json:"name"`
-}
-
-// DevPodWorkspaceSource represents the source configuration for a workspace
-type DevPodWorkspaceSource struct {
-	Image         string `json:"image,omitempty"`
-	GitRepository string `json:"gitRepository,omitempty"`
-}
-
-// DevPodProvider represents a DevPod provider
-type DevPodProvider struct {
-	Config DevPodProviderConfig `json:"config"`
-	State  DevPodProviderState  `json:"state"`
-}
-
-// DevPodProviderConfig represents the configuration of a DevPod provider
-type DevPodProviderConfig struct {
-	Name         string                 `json:"name"`
-	Version      string                 `json:"version"`
-	Description  string                 `json:"description"`
-	Icon         string                 `json:"icon,omitempty"`
-	Home         string                 `json:"home,omitempty"`
-	Source       map[string]interface{} `json:"source"`
-	OptionGroups []interface{}          `json:"optionGroups"`
-	Options      map[string]interface{} `json:"options"`
-	Agent        map[string]interface{} `json:"agent"`
-	Exec         map[string]interface{} `json:"exec"`
-}
-
-// DevPodProviderState represents the state of a DevPod provider
-type DevPodProviderState struct {
-	Initialized       bool                   `json:"initialized"`
-	Options           map[string]interface{} `json:"options"`
-	CreationTimestamp string                 `json:"creationTimestamp"`
-}
-
-// executeDevPodCommandWithDebug executes a DevPod command with comprehensive debug logging
-func executeDevPodCommandWithDebug(ctx context.Context, args []string) ([]byte, error) {
-	log.Printf("DEBUG: Executing devpod command with args: %v", args)
-	fmt.Fprintf(os.Stderr, "DEBUG: Executing devpod command with args: %v\n", args)
-
-	cmd := exec.CommandContext(ctx, "devpod", args...)
-
-	// Set environment variables
-	cmd.Env = os.Environ()
-
-	// Capture both stdout and stderr separately for better debugging
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	err := cmd.Run()
-
-	stdoutBytes := stdout.Bytes()
-	stderrBytes := stderr.Bytes()
-	stdoutStr := string(stdoutBytes)
-	stderrStr := string(stderrBytes)
-
-	log.Printf("DEBUG: Command completed with error: %v", err)
-	log.Printf("DEBUG: Command stdout (%d bytes): %q", len(stdoutBytes), stdoutStr)
-	log.Printf("DEBUG: Command stderr (%d bytes): %q", len(stderrBytes), stderrStr)
-
-	fmt.Fprintf(os.Stderr, "DEBUG: Command completed with error: %v\n", err)
-	fmt.Fprintf(os.Stderr, "DEBUG: Command stdout (%d bytes): %q\n", len(stdoutBytes), stdoutStr)
-	fmt.Fprintf(os.Stderr, "DEBUG: Command stderr (%d bytes): %q\n", len(stderrBytes), stderrStr)
-
-	if err != nil {
-		log.Printf("ERROR: devpod command failed: %v", err)
-		fmt.Fprintf(os.Stderr, "ERROR: devpod command failed: %v\n", err)
-		return nil, fmt.Errorf("devpod command failed: %v, stdout: %s, stderr: %s", err, stdoutStr, stderrStr)
-	}
-
-	log.Printf("DEBUG: Command completed successfully, returning %d bytes", len(stdoutBytes))
-	fmt.Fprintf(os.Stderr, "DEBUG: Command completed successfully, returning %d bytes\n", len(stdoutBytes))
-	return stdoutBytes, nil
-}
-
-func checkDevPodAvailable() error {
-	log.Printf("Checking DevPod availability...")
-	fmt.Fprintf(os.Stderr, "Checking DevPod availability...\n")
-
-	cmd := exec.Command("devpod", "version")
-	if err := cmd.Run(); err != nil {
-		log.Printf("DevPod not available: %v", err)
-		fmt.Fprintf(os.Stderr, "DevPod not available: %v\n", err)
-		return fmt.Errorf("DevPod binary not found or not executable: %w", err)
-	}
-
-	log.Printf("DevPod is available")
-	fmt.Fprintf(os.Stderr, "DevPod is available\n")
-	return nil
-}
-
 func main() {
 	// Add panic recovery to catch any crashes
 	defer func() {
 		if r := recover(); r != nil {
 			log.Printf("PANIC: Server crashed with error: %v", r)
-			fmt.Fprintf(os.Stderr, "PANIC: Server crashed with error: %v\n", r)
 			os.Exit(1)
 		}
 	}()
 
+	// Subcommands are handled before the server flags are parsed
+	if len(os.Args) > 1 && os.Args[1] == "generate-config" {
+		if err := generateConfig(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(2)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "selftest" {
+		ok, err := runSelftest(os.Args[2:])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(2)
+		}
+		if !ok {
+			os.Exit(1)
+		}
+		return
+	}
+
 	var (
-		transportType = flag.String("transport", "stdio", "Transport type: stdio, sse, or http-streams")
-		addr          = flag.String("addr", "8080", "Port for SSE and HTTP Streams transports")
-		showVersion   = flag.Bool("version", false, "Show version information")
+		transportType    = flag.String("transport", "stdio", "Transport type: stdio, sse, or http-streams")
+		addr             = flag.String("addr", "8080", "Port for SSE and HTTP Streams transports")
+		showVersion      = flag.Bool("version", false, "Show version information")
+		sshPooling       = flag.Bool("ssh-pool", true, "Reuse SSH control connections to workspaces between calls")
+		sshIdle          = flag.Duration("ssh-idle-timeout", 5*time.Minute, "Close pooled SSH connections after this idle period")
+		sshConfig        = flag.String("ssh-config", "", "SSH config file devpod writes workspace hosts to (default: ~/.ssh/config)")
+		watchInterval    = flag.Duration("watch-interval", 0, "Poll workspace state at this interval and notify clients of changes (0 disables)")
+		strictJSON       = flag.Bool("strict-json", false, "Require JSON output from devpod and never fall back to text parsing")
+		bootstrap        = flag.String("bootstrap-provider", "", "Provider to add on startup when no providers are configured (e.g. docker)")
+		runtimeName      = flag.String("container-runtime", "", "Container runtime the docker provider uses: docker, podman, nerdctl, or auto for the first one that works; sets the provider's DOCKER_PATH, and DOCKER_HOST for podman, on startup (empty leaves them alone)")
+		templatesPath    = flag.String("templates", "", "JSON file of workspace templates for devpod_createWorkspace")
+		maxConcurrent    = flag.Int("max-concurrent", 0, "Maximum devpod commands running at once across all clients (0 disables)")
+		maxPerSession    = flag.Int("max-concurrent-per-session", 0, "Maximum devpod commands running at once per client session (0 disables)")
+		maxCreates       = flag.Int("max-creates-per-hour", 0, "Maximum workspace creations in any rolling hour (0 disables)")
+		quotaFile        = flag.String("quota-file", "", "JSON file of quotas checked before workspaces are created: maxWorkspaces, maxWorkspacesPerProvider, allowedProviders, allowedMachineTypes, maxDiskSize and maxGpu")
+		namePattern      = flag.String("workspace-name-pattern", "", "Regular expression the names of new workspaces must match as a whole, e.g. [a-z][a-z0-9-]*")
+		namePrefix       = flag.String("workspace-name-prefix", "", "Prefix the names of new workspaces must start with, e.g. ai-; generated names get it")
+		nameMaxLength    = flag.Int("workspace-name-max-length", 0, "Maximum length of the names of new workspaces (0 disables)")
+		queueTimeout     = flag.Duration("queue-timeout", 30*time.Second, "How long a command waits for a free slot before it is rejected")
+		retries          = flag.Int("retries", 3, "Maximum attempts of devpod commands that fail with transient errors (1 disables retries)")
+		retryBackoff     = flag.Duration("retry-backoff", time.Second, "Delay before the first retry, doubled for each further retry up to 30s")
+		retryMutating    = flag.Bool("retry-mutating", false, "Also retry devpod commands that change workspaces or providers, such as up and delete, not just read-only ones like list and status")
+		breakerThreshold = flag.Int("breaker-threshold", 5, "Consecutive backend failures (docker daemon down, network unreachable, timeouts) after which devpod commands fail fast (0 disables)")
+		breakerCooldown  = flag.Duration("breaker-cooldown", 30*time.Second, "How long devpod commands fail fast before one is let through to probe the backend")
+		otelEndpoint     = flag.String("otel-endpoint", os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"), "OTLP/HTTP collector to export OpenTelemetry traces of tool calls and devpod commands to, e.g. http://localhost:4318 (empty disables tracing)")
+		otelService      = flag.String("otel-service-name", "mcp-server-devpod", "service.name of exported traces")
+		otelHeaders      = flag.String("otel-headers", os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"), "Comma-separated key=value headers sent to the OTLP collector, e.g. for authentication")
+		captureDir       = flag.String("capture-dir", "", "Write every JSON-RPC message received or sent to its own file in this directory, with credentials redacted, for protocol debugging")
+		policyFile       = flag.String("policy-file", "", "JSON rules file that allows or denies tool calls by tool, client, session and arguments")
+		policyWebhook    = flag.String("policy-webhook", "", "URL, e.g. an OPA decision endpoint, every tool call the rules allow is posted to for authorization")
+		policyTimeout    = flag.Duration("policy-timeout", 5*time.Second, "How long a policy webhook may take before the call is denied")
+		confirmDestroy   = flag.Bool("confirm-destructive", false, "Ask the user to confirm calls that delete or replace workspaces, secrets, files and other state through the client's elicitation support; calls are denied without it")
+		usersFile        = flag.String("users", "", "JSON file of users with their bearer token, DEVPOD_HOME and devpod context; enables multi-user mode on the HTTP Streams transport")
+		authHeader       = flag.String("auth-header", "", "Header an authenticating proxy sets to the user name, e.g. X-Forwarded-User, accepted instead of a bearer token")
+		userHomeRoot     = flag.String("devpod-home-root", "", "Directory holding a DEVPOD_HOME per authenticated user that the users file does not configure one for")
+		lockWait         = flag.Duration("lock-wait", 0, "How long an operation on a workspace waits for another one on it to finish before failing with a Conflict error")
+		allowEnv         = flag.String("allow-env", strings.Join(server.DefaultAllowedEnv, ","), "Comma-separated environment variables tools accept in their env argument; NAME* allows a prefix, empty allows none")
+		gcInterval       = flag.Duration("gc-interval", 0, "Collect stale workspaces in the background at this interval (0 disables)")
+		gcMaxIdle        = flag.Duration("gc-max-idle", 24*time.Hour, "Collect workspaces whose lastUsed is older than this")
+		gcPolicy         = flag.String("gc-policy", "stop", "What garbage collection does with stale workspaces: stop or delete")
+		autoStopAfter    = flag.Duration("auto-stop-after", 0, "Stop workspaces created or started through the server after this long without a tool call naming them, unless the call sets autoStopAfter (0 disables)")
+		resultFormat     = flag.String("result-format", "", "Default rendering of tool results: json, markdown (tables for lists) or text; calls override it with their format argument (default: Go's print format)")
+		maxOutput        = flag.Int("max-output-bytes", 16<<10, "Maximum devpod output in a tool result; longer output keeps its head and tail (negative: unlimited)")
+		outputLimits     = flag.String("tool-output-limits", "", "Per-tool output limits overriding -max-output-bytes, e.g. devpod_ssh=65536,devpod_createWorkspace=8192")
+		workspaceRoot    = flag.String("workspace-root", "", "Only allow local workspace sources inside this directory and resolve relative paths against it")
+		autoInstall      = flag.Bool("auto-install-devpod", false, "Download the devpod CLI on startup when it is missing")
+		installDir       = flag.String("devpod-install-dir", "", "Directory the devpod CLI is installed into (default: under the user cache directory)")
+		openBrowser      = flag.Bool("open-browser", false, "Enable devpod_openInBrowser to open workspace URLs on this machine (desktop setups)")
+		corsOrigins      = flag.String("cors-origins", "*", "Comma-separated browser origins allowed to call the SSE and HTTP Streams endpoints (* allows any)")
+		basePath         = flag.String("base-path", "", "Path prefix of the SSE and HTTP Streams endpoints, e.g. /devpod-mcp behind a reverse proxy")
+		dashboard        = flag.Bool("dashboard", false, "Serve a read-only status page at /dashboard on the SSE and HTTP Streams transports")
+		ideProxy         = flag.Bool("ide-proxy", false, "Proxy browser IDEs opened with devpod_openIDE at /workspaces/<name>/ide/ on the SSE and HTTP Streams transports")
+		publicURL        = flag.String("public-url", "", "Scheme and host clients reach the SSE and HTTP Streams transports at, e.g. https://mcp.example.com, used in the IDE proxy URLs (default: URLs relative to the server)")
+		dataDir          = flag.String("data-dir", "", "Directory for the state file, secret key and audit log (default: mcp-server-devpod under the user config directory)")
+		printConfig      = flag.Bool("print-client-config", false, "Print Claude Desktop, Cursor and generic MCP client configurations for these flags and exit")
+		listTools        = flag.Bool("list-tools", false, "Print the tool catalog (names, descriptions, input schemas and annotations) as JSON and exit without starting a transport")
+		heartbeat        = flag.Duration("heartbeat-interval", httptransport.DefaultHeartbeat, "Interval of heartbeats on idle SSE and HTTP Streams event streams (0 disables)")
+		sessionTTL       = flag.Duration("session-ttl", httptransport.DefaultSessionTTL, "How long HTTP Streams sessions are kept without requests or an open event stream (0 keeps them until the client ends them)")
 	)
+	verbose := verbosity(server.VerbosityQuiet)
+	flag.Var(&verbose, "verbose", "Log level: 0 logs warnings and errors only, 1 adds lifecycle messages, 2 adds every devpod command and protocol message")
+	flag.Var(verbosityShorthand{&verbose}, "v", "Raise the log level by one; repeat or use -v=2 for debug output (see -verbose)")
 	flag.Parse()
 
 	if *showVersion {
@@ -157,16 +125,23 @@ func main() {
 		return
 	}
 
-	log.Printf("Starting DevPod MCP server with transport: %s", *transportType)
-	fmt.Fprintf(os.Stderr, "Starting DevPod MCP server with transport: %s\n", *transportType)
-
-	// Check DevPod availability early to provide clear error message
-	if err := checkDevPodAvailable(); err != nil {
-		log.Printf("WARNING: %v", err)
-		fmt.Fprintf(os.Stderr, "WARNING: %v\n", err)
-		fmt.Fprintf(os.Stderr, "DevPod tools will return errors when called\n")
+	if *printConfig {
+		// Clients start the server with the flags given here
+		var serverArgs []string
+		flag.Visit(func(f *flag.Flag) {
+			if f.Name != "print-client-config" && f.Name != "transport" && f.Name != "addr" {
+				serverArgs = append(serverArgs, fmt.Sprintf("-%s=%s", f.Name, f.Value))
+			}
+		})
+		if err := printClientConfig(clientconfig.Clients, *transportType, *addr, "localhost", *basePath, serverArgs); err != nil {
+			log.Fatalf("Failed to generate client configuration: %v", err)
+		}
+		return
 	}
 
+	server.SetVerbosity(server.Verbosity(verbose))
+	server.Logf(server.VerbosityInfo, "Starting DevPod MCP server with transport: %s", *transportType)
+
 	// Format address for SSE and HTTP Streams transports
 	var formattedAddr string
 	if *transportType == "sse" || *transportType == "http-streams" {
@@ -178,25 +153,205 @@ func main() {
 		}
 	}
 
+	// Load the users of a shared server
+	var users map[string]server.User
+	if *usersFile != "" {
+		var err error
+		users, err = server.LoadUsers(*usersFile)
+		if err != nil {
+			log.Fatalf("Failed to load users: %v", err)
+		}
+		server.Logf(server.VerbosityInfo, "Loaded %d user(s) from %s", len(users), *usersFile)
+	}
+	multiUser := *usersFile != "" || *authHeader != "" || *userHomeRoot != ""
+	if multiUser && *transportType != "http-streams" {
+		log.Fatalf("Multi-user mode (-users, -auth-header, -devpod-home-root) requires the http-streams transport")
+	}
+	if *authHeader != "" && *usersFile == "" && *userHomeRoot == "" {
+		log.Fatalf("-auth-header requires -users or -devpod-home-root")
+	}
+	for name, user := range users {
+		if user.DevPodHome == "" && *userHomeRoot == "" {
+			log.Printf("WARNING: user %s has no devpodHome and shares the server's DEVPOD_HOME", name)
+		}
+	}
+
 	// Create transport
-	log.Printf("Creating transport: %s", *transportType)
-	fmt.Fprintf(os.Stderr, "Creating transport: %s\n", *transportType)
+	server.Logf(server.VerbosityDebug, "Creating transport: %s", *transportType)
+	httpOptions := httptransport.Options{
+		CORSOrigins: httptransport.ParseOrigins(*corsOrigins),
+		BasePath:    *basePath,
+		Heartbeat:   *heartbeat,
+		SessionTTL:  *sessionTTL,
+	}
+	if multiUser {
+		httpOptions.Authenticate = server.Authenticator(users, *authHeader, *userHomeRoot)
+	}
+	var ideProxyURL string
+	if *ideProxy {
+		if *transportType == "stdio" {
+			log.Printf("WARNING: -ide-proxy requires the sse or http-streams transport")
+		} else {
+			ideProxyURL = strings.TrimRight(*publicURL, "/") + httpOptions.Endpoint("/workspaces")
+		}
+	}
 	var t mcp.Transport
 	switch *transportType {
 	case "stdio":
 		t = transport.NewSTDIOTransport()
 	case "sse":
-		t = transport.NewSSETransport(formattedAddr)
+		t = httptransport.NewSSE(formattedAddr, httpOptions)
 	case "http-streams":
-		t = transport.NewHTTPStreamsTransport(formattedAddr)
+		// Session-aware so per-session defaults and limits apply
+		t = httptransport.NewStreamsWithOptions(formattedAddr, httpOptions)
 	default:
 		log.Fatalf("Unknown transport type: %s (supported: stdio, sse, http-streams)", *transportType)
 	}
 
-	// Create server
-	log.Printf("Creating MCP server")
-	fmt.Fprintf(os.Stderr, "Creating MCP server\n")
-	server := mcp.NewServer(t)
+	if *gcPolicy != "stop" && *gcPolicy != "delete" {
+		log.Fatalf("Unknown gc policy: %s (supported: stop, delete)", *gcPolicy)
+	}
+	if *runtimeName != "" && !server.ValidContainerRuntime(*runtimeName) {
+		log.Fatalf("Unknown container runtime: %s (supported: docker, podman, nerdctl, auto)", *runtimeName)
+	}
+	if *resultFormat != "" && !server.ValidResultFormat(*resultFormat) {
+		log.Fatalf("Unknown result format: %s (supported: json, markdown, text)", *resultFormat)
+	}
+	if *autoStopAfter < 0 || (*autoStopAfter > 0 && *autoStopAfter < time.Minute) {
+		log.Fatalf("Invalid -auto-stop-after %s: must be 0 or at least a minute", *autoStopAfter)
+	}
+
+	toolOutputLimits, err := server.ParseOutputLimits(*outputLimits)
+	if err != nil {
+		log.Fatalf("Failed to parse tool output limits: %v", err)
+	}
+	allowedEnv, err := server.ParseAllowedEnv(*allowEnv)
+	if err != nil {
+		log.Fatalf("Failed to parse allowed environment variables: %v", err)
+	}
+	otlpHeaders, err := server.ParseOTLPHeaders(*otelHeaders)
+	if err != nil {
+		log.Fatalf("Failed to parse OTLP headers: %v", err)
+	}
+
+	// Load workspace templates
+	var templates map[string]server.Template
+	if *templatesPath != "" {
+		var err error
+		templates, err = server.LoadTemplates(*templatesPath)
+		if err != nil {
+			log.Fatalf("Failed to load templates: %v", err)
+		}
+		server.Logf(server.VerbosityInfo, "Loaded %d workspace template(s) from %s", len(templates), *templatesPath)
+	}
+
+	var policyRules *server.PolicyRules
+	if *policyFile != "" {
+		policyRules, err = server.LoadPolicyRules(*policyFile)
+		if err != nil {
+			log.Fatalf("Failed to load policy: %v", err)
+		}
+		server.Logf(server.VerbosityInfo, "Loaded %d policy rule(s) from %s", len(policyRules.Rules), *policyFile)
+	}
+
+	var quotas server.Quotas
+	if *quotaFile != "" {
+		quotas, err = server.LoadQuotas(*quotaFile)
+		if err != nil {
+			log.Fatalf("Failed to load quotas: %v", err)
+		}
+		server.Logf(server.VerbosityInfo, "Loaded quotas from %s", *quotaFile)
+	}
+
+	names, err := server.NewNamePolicy(*namePattern, *namePrefix, *nameMaxLength)
+	if err != nil {
+		log.Fatalf("Failed to parse the workspace name policy: %v", err)
+	}
+
+	// Create server and register all DevPod tools
+	server.Logf(server.VerbosityDebug, "Creating MCP server")
+	srv := server.New(t, server.Options{
+		Version:           version,
+		DataDir:           *dataDir,
+		CaptureDir:        *captureDir,
+		SSHPool:           *sshPooling,
+		SSHIdleTimeout:    *sshIdle,
+		SSHConfigPath:     *sshConfig,
+		WatchInterval:     *watchInterval,
+		StrictJSON:        *strictJSON,
+		BootstrapProvider: *bootstrap,
+		ContainerRuntime:  *runtimeName,
+		Templates:         templates,
+		GCInterval:        *gcInterval,
+		GCMaxIdle:         *gcMaxIdle,
+		GCPolicy:          *gcPolicy,
+		AutoStopAfter:     *autoStopAfter,
+		MaxOutputBytes:    *maxOutput,
+		ResultFormat:      *resultFormat,
+		ToolOutputLimits:  toolOutputLimits,
+		WorkspaceRoot:     *workspaceRoot,
+		AutoInstall:       *autoInstall,
+		InstallDir:        *installDir,
+		OpenBrowser:       *openBrowser,
+		IDEProxyURL:       ideProxyURL,
+		Limits: server.Limits{
+			MaxConcurrent:           *maxConcurrent,
+			MaxConcurrentPerSession: *maxPerSession,
+			MaxCreatesPerHour:       *maxCreates,
+			QueueTimeout:            *queueTimeout,
+		},
+		Quotas: quotas,
+		Names:  names,
+		Retry: server.RetryPolicy{
+			MaxAttempts: *retries,
+			Backoff:     *retryBackoff,
+			Mutating:    *retryMutating,
+		},
+		Breaker: server.BreakerPolicy{
+			Threshold: *breakerThreshold,
+			Cooldown:  *breakerCooldown,
+		},
+		AllowedEnv:   allowedEnv,
+		LockWait:     *lockWait,
+		Users:        users,
+		UserHomeRoot: *userHomeRoot,
+		Policy: server.PolicyOptions{
+			Rules:   policyRules,
+			Webhook: *policyWebhook,
+			Timeout: *policyTimeout,
+			Confirm: *confirmDestroy,
+		},
+		Tracing: server.TracingOptions{
+			Endpoint:    *otelEndpoint,
+			ServiceName: *otelService,
+			Headers:     otlpHeaders,
+		},
+	})
+
+	// Keep credentials out of the debug log
+	server.SetLogOutput(srv.RedactingWriter(os.Stderr))
+
+	if *listTools {
+		if err := srv.WriteToolCatalog(os.Stdout); err != nil {
+			log.Fatalf("Failed to write tool catalog: %v", err)
+		}
+		return
+	}
+
+	if *dashboard {
+		if routes, ok := t.(interface {
+			Handle(path string, handler http.Handler)
+		}); ok {
+			routes.Handle("/dashboard", srv.DashboardHandler())
+		} else {
+			log.Printf("WARNING: -dashboard requires the sse or http-streams transport")
+		}
+	}
+	if ideProxyURL != "" {
+		t.(interface {
+			HandleBrowser(path string, handler http.Handler)
+		}).HandleBrowser("/workspaces/", http.StripPrefix(httpOptions.Endpoint(""), srv.IDEProxyHandler()))
+	}
 
 	// Setup context with cancellation
 	ctx, cancel := context.WithCancel(context.Background())
@@ -208,772 +363,199 @@ func main() {
 
 	go func() {
 		<-sigChan
-		log.Println("Shutting down DevPod MCP server...")
+		server.Logf(server.VerbosityInfo, "Shutting down DevPod MCP server...")
 		cancel()
 	}()
 
-	// Register MCP protocol handlers BEFORE starting the server (to prevent override)
-	log.Printf("Registering MCP protocol handlers")
-	fmt.Fprintf(os.Stderr, "Registering MCP protocol handlers\n")
-	registerMCPHandlers(server)
-
-	// Register DevPod handlers BEFORE starting the server
-	log.Printf("Registering DevPod handlers")
-	fmt.Fprintf(os.Stderr, "Registering DevPod handlers\n")
-	registerDevPodHandlers(server)
-
-	// Set up message handler for HTTP-based transports
-	log.Printf("Setting up message handler")
-	fmt.Fprintf(os.Stderr, "Setting up message handler\n")
-	setupMessageHandler(server, t)
-
-	// Add debug output to stderr for Claude Desktop
-	fmt.Fprintf(os.Stderr, "DevPod MCP server initializing with %s transport\n", *transportType)
-
 	// Start server (default handlers won't override existing ones)
-	log.Printf("About to start server...")
-	fmt.Fprintf(os.Stderr, "About to start server...\n")
-	if err := server.Start(ctx); err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to start server: %v\n", err)
-		log.Printf("Failed to start server: %v", err)
+	if err := srv.Start(ctx); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 
-	fmt.Fprintf(os.Stderr, "DevPod MCP server started with %s transport\n", *transportType)
-	log.Printf("DevPod MCP server started with %s transport", *transportType)
+	server.Logf(server.VerbosityInfo, "DevPod MCP server started with %s transport", *transportType)
 	if *transportType == "sse" {
-		log.Printf("Starting SSE server on %s", formattedAddr)
-		log.Printf("Listening on %s", *addr)
+		server.Logf(server.VerbosityInfo, "Listening on %s", formattedAddr)
+		server.Logf(server.VerbosityInfo, "Endpoints: %s (GET), %s (POST), %s (GET)", httpOptions.Endpoint("/sse"), httpOptions.Endpoint("/message"), httpOptions.Endpoint("/health"))
 	} else if *transportType == "http-streams" {
-		log.Printf("Starting HTTP Streams server on %s", formattedAddr)
-		log.Printf("Listening on %s", *addr)
-		log.Printf("Endpoints: /mcp (POST/GET), /health (GET)")
+		server.Logf(server.VerbosityInfo, "Listening on %s", formattedAddr)
+		server.Logf(server.VerbosityInfo, "Endpoints: %s (POST/GET), %s (GET)", httpOptions.Endpoint("/mcp"), httpOptions.Endpoint("/health"))
+	}
+	if *dashboard && *transportType != "stdio" {
+		server.Logf(server.VerbosityInfo, "Dashboard: %s", httpOptions.Endpoint("/dashboard"))
+	}
+	if ideProxyURL != "" {
+		server.Logf(server.VerbosityInfo, "IDE proxy: %s", httpOptions.Endpoint("/workspaces/<name>/ide/"))
 	}
 
 	// Wait for context cancellation
-	fmt.Fprintf(os.Stderr, "DevPod MCP server waiting for shutdown signal...\n")
 	<-ctx.Done()
-	fmt.Fprintf(os.Stderr, "DevPod MCP server received shutdown signal, cleaning up...\n")
 
 	// Cleanup
-	if err := server.Stop(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error stopping server: %v\n", err)
+	if err := srv.Stop(); err != nil {
 		log.Printf("Error stopping server: %v", err)
 	}
 
-	if err := server.Close(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error closing server: %v\n", err)
+	if err := srv.Close(); err != nil {
 		log.Printf("Error closing server: %v", err)
 	}
 
-	fmt.Fprintf(os.Stderr, "DevPod MCP server stopped\n")
-	log.Println("Server stopped")
+	server.Logf(server.VerbosityInfo, "Server stopped")
 }
 
-func registerMCPHandlers(server *mcp.Server) {
-	log.Printf("Registering prompts/list handler")
-	fmt.Fprintf(os.Stderr, "Registering prompts/list handler\n")
-	// Register prompts/list handler (required by Claude Desktop)
-	server.RegisterHandler("prompts/list", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
-		log.Printf("prompts/list called")
-		fmt.Fprintf(os.Stderr, "prompts/list called\n")
-		// Return empty prompts list since we don't provide any prompts
-		return map[string]interface{}{
-			"prompts": []interface{}{},
-		}, nil
-	})
-
-	log.Printf("Registering resources/list handler")
-	fmt.Fprintf(os.Stderr, "Registering resources/list handler\n")
-	// Register resources/list handler (optional but good practice)
-	server.RegisterHandler("resources/list", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
-		log.Printf("resources/list called")
-		fmt.Fprintf(os.Stderr, "resources/list called\n")
-		// Return empty resources list since we don't provide any resources
-		return map[string]interface{}{
-			"resources": []interface{}{},
-		}, nil
-	})
-
-	log.Printf("Registering tools/list handler")
-	fmt.Fprintf(os.Stderr, "Registering tools/list handler\n")
-	// Override the default tools/list handler to include our DevPod tools
-	server.RegisterHandler("tools/list", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
-		log.Printf("tools/list called")
-		fmt.Fprintf(os.Stderr, "tools/list called\n")
-		tools := []map[string]interface{}{
-			// Echo tool (from framework)
-			{
-				"name":        "echo",
-				"description": "Echo back the provided message",
-				"inputSchema": map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"message": map[string]interface{}{
-							"type":        "string",
-							"description": "The message to echo back",
-						},
-					},
-					"required": []string{"message"},
-				},
-			},
-			// DevPod tools
-			{
-				"name":        "devpod_listWorkspaces",
-				"description": "List all DevPod workspaces",
-				"inputSchema": map[string]interface{}{
-					"type":       "object",
-					"properties": map[string]interface{}{},
-				},
-			},
-			{
-				"name":        "devpod_status",
-				"description": "Get the status of a specific DevPod workspace",
-				"inputSchema": map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"name": map[string]interface{}{
-							"type":        "string",
-							"description": "The name of the workspace",
-						},
-					},
-					"required": []string{"name"},
-				},
-			},
-			{
-				"name":        "devpod_createWorkspace",
-				"description": "Create a new DevPod workspace",
-				"inputSchema": map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"name": map[string]interface{}{
-							"type":        "string",
-							"description": "The name of the workspace",
-						},
-						"source": map[string]interface{}{
-							"type":        "string",
-							"description": "The source repository or path",
-						},
-						"provider": map[string]interface{}{
-							"type":        "string",
-							"description": "The provider to use (optional)",
-						},
-						"ide": map[string]interface{}{
-							"type":        "string",
-							"description": "The IDE to use (optional)",
-						},
-					},
-					"required": []string{"name", "source"},
-				},
-			},
-			{
-				"name":        "devpod_startWorkspace",
-				"description": "Start a DevPod workspace",
-				"inputSchema": map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"name": map[string]interface{}{
-							"type":        "string",
-							"description": "The name of the workspace",
-						},
-						"ide": map[string]interface{}{
-							"type":        "string",
-							"description": "The IDE to use (optional)",
-						},
-					},
-					"required": []string{"name"},
-				},
-			},
-			{
-				"name":        "devpod_stopWorkspace",
-				"description": "Stop a DevPod workspace",
-				"inputSchema": map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"name": map[string]interface{}{
-							"type":        "string",
-							"description": "The name of the workspace",
-						},
-					},
-					"required": []string{"name"},
-				},
-			},
-			{
-				"name":        "devpod_deleteWorkspace",
-				"description": "Delete a DevPod workspace",
-				"inputSchema": map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"name": map[string]interface{}{
-							"type":        "string",
-							"description": "The name of the workspace",
-						},
-						"force": map[string]interface{}{
-							"type":        "boolean",
-							"description": "Force deletion without confirmation",
-						},
-					},
-					"required": []string{"name"},
-				},
-			},
-			{
-				"name":        "devpod_ssh",
-				"description": "SSH into a DevPod workspace",
-				"inputSchema": map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"name": map[string]interface{}{
-							"type":        "string",
-							"description": "The name of the workspace",
-						},
-						"command": map[string]interface{}{
-							"type":        "string",
-							"description": "Command to execute (optional)",
-						},
-					},
-					"required": []string{"name"},
-				},
-			},
-			{
-				"name":        "devpod_listProviders",
-				"description": "List all DevPod providers",
-				"inputSchema": map[string]interface{}{
-					"type":       "object",
-					"properties": map[string]interface{}{},
-				},
-			},
-			{
-				"name":        "devpod_addProvider",
-				"description": "Add a new DevPod provider",
-				"inputSchema": map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"name": map[string]interface{}{
-							"type":        "string",
-							"description": "The name of the provider",
-						},
-						"options": map[string]interface{}{
-							"type":        "object",
-							"description": "Provider-specific options",
-						},
-					},
-					"required": []string{"name"},
-				},
-			},
-		}
+// generateConfig implements the generate-config subcommand, which prints
+// client configurations for a transport. Arguments after -- are passed to
+// the server.
+func generateConfig(args []string) error {
+	flags := flag.NewFlagSet("generate-config", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: mcp-server-devpod generate-config [flags] [-- server flags]\n\n")
+		flags.PrintDefaults()
+	}
+	var (
+		client        = flags.String("client", "all", "Client to configure: "+strings.Join(clientconfig.Clients, ", ")+" or all")
+		transportType = flags.String("transport", "stdio", "Transport type: stdio, sse, or http-streams")
+		addr          = flags.String("addr", "8080", "Port for SSE and HTTP Streams transports")
+		host          = flags.String("host", "localhost", "Host clients reach the SSE and HTTP Streams transports on")
+		basePath      = flags.String("base-path", "", "Path prefix of the SSE and HTTP Streams endpoints")
+	)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
 
-		return map[string]interface{}{
-			"tools": tools,
-		}, nil
-	})
+	clients := clientconfig.Clients
+	if *client != "all" {
+		clients = []string{*client}
+	}
+	serverArgs := flags.Args()
+	if *basePath != "" {
+		serverArgs = append([]string{"-base-path=" + *basePath}, serverArgs...)
+	}
+	return printClientConfig(clients, *transportType, *addr, *host, *basePath, serverArgs)
 }
 
-func registerDevPodHandlers(server *mcp.Server) {
-	log.Printf("Registering DevPod handlers")
-	fmt.Fprintf(os.Stderr, "Registering DevPod handlers\n")
-
-	// Check if DevPod is available (but don't fail registration)
-	devpodAvailable := checkDevPodAvailable() == nil
-
-	// List workspaces
-	log.Printf("Registering devpod_listWorkspaces handler")
-	fmt.Fprintf(os.Stderr, "Registering devpod_listWorkspaces handler\n")
-	server.RegisterHandler("devpod_listWorkspaces", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
-		log.Printf("DEBUG: devpod_listWorkspaces called with params: %s", string(params))
-		fmt.Fprintf(os.Stderr, "DEBUG: devpod_listWorkspaces called with params: %s\n", string(params))
-
-		if !devpodAvailable {
-			log.Printf("ERROR: DevPod is not available on this system")
-			fmt.Fprintf(os.Stderr, "ERROR: DevPod is not available on this system\n")
-			return nil, fmt.Errorf("DevPod is not available on this system")
-		}
-
-		output, err := executeDevPodCommandWithDebug(ctx, []string{"list", "--output", "json"})
-		if err != nil {
-			log.Printf("ERROR: devpod_listWorkspaces failed: %v", err)
-			fmt.Fprintf(os.Stderr, "ERROR: devpod_listWorkspaces failed: %v\n", err)
-			return nil, fmt.Errorf("failed to list workspaces: %w", err)
-		}
-
-		var workspaces []DevPodWorkspace
-		if err := json.Unmarshal(output, &workspaces); err != nil {
-			log.Printf("DEBUG: JSON parsing failed, trying text parsing. Error: %v", err)
-			fmt.Fprintf(os.Stderr, "DEBUG: JSON parsing failed, trying text parsing. Error: %v\n", err)
-			// If JSON parsing fails, try to parse the text output
-			textResult := parseTextWorkspaceList(string(output))
-			result := map[string]interface{}{
-				"workspaces": textResult,
-			}
-			log.Printf("DEBUG: devpod_listWorkspaces returning text-parsed result: %v", result)
-			fmt.Fprintf(os.Stderr, "DEBUG: devpod_listWorkspaces returning text-parsed result: %v\n", result)
-			fmt.Printf("RESPONSE: devpod_listWorkspaces text-parsed result: %v\n", result)
-			return result, nil
-		}
-
-		result := map[string]interface{}{
-			"workspaces": workspaces,
-		}
-		log.Printf("DEBUG: devpod_listWorkspaces returning JSON-parsed result: %v", result)
-		fmt.Fprintf(os.Stderr, "DEBUG: devpod_listWorkspaces returning JSON-parsed result: %v\n", result)
-		fmt.Printf("RESPONSE: devpod_listWorkspaces result: %v\n", result)
-		return result, nil
-	})
-
-	// Create workspace
-	server.RegisterHandler("devpod_createWorkspace", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
-		var createParams struct {
-			Name     string `json:"name"`
-			Source   string `json:"source"`
-			Provider string `json:"provider,omitempty"`
-			IDE      string `json:"ide,omitempty"`
-		}
-
-		if err := json.Unmarshal(params, &createParams); err != nil {
-			return nil, mcp.NewInvalidParamsError("Invalid create workspace parameters")
-		}
-
-		if createParams.Name == "" || createParams.Source == "" {
-			return nil, mcp.NewInvalidParamsError("Name and source are required")
-		}
-
-		args := []string{"up", createParams.Source, "--id", createParams.Name}
-		if createParams.Provider != "" {
-			args = append(args, "--provider", createParams.Provider)
-		}
-		if createParams.IDE != "" {
-			args = append(args, "--ide", createParams.IDE)
-		}
-
-		cmd := exec.CommandContext(ctx, "devpod", args...)
-		output, err := cmd.CombinedOutput()
-		if err != nil {
-			return nil, fmt.Errorf("failed to create workspace: %w\nOutput: %s", err, string(output))
-		}
-
-		return map[string]interface{}{
-			"name":    createParams.Name,
-			"message": "Workspace created successfully",
-			"output":  string(output),
-		}, nil
-	})
-
-	// Start workspace
-	server.RegisterHandler("devpod_startWorkspace", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
-		var startParams struct {
-			Name string `json:"name"`
-			IDE  string `json:"ide,omitempty"`
-		}
-
-		if err := json.Unmarshal(params, &startParams); err != nil {
-			return nil, mcp.NewInvalidParamsError("Invalid start workspace parameters")
-		}
-
-		if startParams.Name == "" {
-			return nil, mcp.NewInvalidParamsError("Workspace name is required")
-		}
-
-		args := []string{"up", startParams.Name}
-		if startParams.IDE != "" {
-			args = append(args, "--ide", startParams.IDE)
-		}
-
-		cmd := exec.CommandContext(ctx, "devpod", args...)
-		output, err := cmd.CombinedOutput()
-		if err != nil {
-			return nil, fmt.Errorf("failed to start workspace: %w\nOutput: %s", err, string(output))
-		}
-
-		return map[string]interface{}{
-			"name":    startParams.Name,
-			"message": "Workspace started successfully",
-			"output":  string(output),
-		}, nil
-	})
-
-	// Stop workspace
-	server.RegisterHandler("devpod_stopWorkspace", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
-		var stopParams struct {
-			Name string `json:"name"`
-		}
-
-		if err := json.Unmarshal(params, &stopParams); err != nil {
-			return nil, mcp.NewInvalidParamsError("Invalid stop workspace parameters")
-		}
-
-		if stopParams.Name == "" {
-			return nil, mcp.NewInvalidParamsError("Workspace name is required")
-		}
-
-		cmd := exec.CommandContext(ctx, "devpod", "stop", stopParams.Name)
-		output, err := cmd.CombinedOutput()
-		if err != nil {
-			return nil, fmt.Errorf("failed to stop workspace: %w\nOutput: %s", err, string(output))
-		}
-
-		return map[string]interface{}{
-			"name":    stopParams.Name,
-			"message": "Workspace stopped successfully",
-			"output":  string(output),
-		}, nil
-	})
-
-	// Delete workspace
-	server.RegisterHandler("devpod_deleteWorkspace", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
-		var deleteParams struct {
-			Name  string `json:"name"`
-			Force bool   `json:"force,omitempty"`
-		}
-
-		if err := json.Unmarshal(params, &deleteParams); err != nil {
-			return nil, mcp.NewInvalidParamsError("Invalid delete workspace parameters")
-		}
-
-		if deleteParams.Name == "" {
-			return nil, mcp.NewInvalidParamsError("Workspace name is required")
-		}
-
-		args := []string{"delete", deleteParams.Name}
-		if deleteParams.Force {
-			args = append(args, "--force")
-		}
-
-		cmd := exec.CommandContext(ctx, "devpod", args...)
-		output, err := cmd.CombinedOutput()
-		if err != nil {
-			return nil, fmt.Errorf("failed to delete workspace: %w\nOutput: %s", err, string(output))
-		}
-
-		return map[string]interface{}{
-			"name":    deleteParams.Name,
-			"message": "Workspace deleted successfully",
-			"output":  string(output),
-		}, nil
-	})
-
-	// List providers
-	server.RegisterHandler("devpod_listProviders", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
-		output, err := executeDevPodCommandWithDebug(ctx, []string{"provider", "list", "--output", "json"})
-		if err != nil {
-			log.Printf("ERROR: devpod_listProviders failed: %v", err)
-			fmt.Fprintf(os.Stderr, "ERROR: devpod_listProviders failed: %v\n", err)
-			return nil, fmt.Errorf("failed to list providers: %w", err)
-		}
-
-		// DevPod provider list returns an object with provider names as keys
-		var providersMap map[string]DevPodProvider
-		if err := json.Unmarshal(output, &providersMap); err != nil {
-			log.Printf("DEBUG: JSON parsing failed, trying text parsing. Error: %v", err)
-			fmt.Fprintf(os.Stderr, "DEBUG: JSON parsing failed, trying text parsing. Error: %v\n", err)
-			// If JSON parsing fails, try to parse the text output
-			textResult := parseTextProviderList(string(output))
-			result := map[string]interface{}{
-				"providers": textResult,
-			}
-			log.Printf("DEBUG: devpod_listProviders returning text-parsed result: %v", result)
-			fmt.Fprintf(os.Stderr, "DEBUG: devpod_listProviders returning text-parsed result: %v\n", result)
-			fmt.Printf("RESPONSE: devpod_listProviders text-parsed result: %v\n", result)
-			return result, nil
-		}
-
-		result := map[string]interface{}{
-			"providers": providersMap,
-		}
-		log.Printf("DEBUG: devpod_listProviders returning JSON-parsed result: %v", result)
-		fmt.Fprintf(os.Stderr, "DEBUG: devpod_listProviders returning JSON-parsed result: %v\n", result)
-		fmt.Printf("RESPONSE: devpod_listProviders result: %v\n", result)
-		return result, nil
-	})
-
-	// Add provider
-	server.RegisterHandler("devpod_addProvider", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
-		log.Printf("DEBUG: devpod_addProvider called with params: %s", string(params))
-		fmt.Fprintf(os.Stderr, "DEBUG: devpod_addProvider called with params: %s\n", string(params))
-
-		var addParams struct {
-			Name    string            `json:"name"`
-			Options map[string]string `json:"options,omitempty"`
-		}
-
-		if err := json.Unmarshal(params, &addParams); err != nil {
-			log.Printf("ERROR: Failed to unmarshal addProvider params: %v", err)
-			fmt.Fprintf(os.Stderr, "ERROR: Failed to unmarshal addProvider params: %v\n", err)
-			return nil, mcp.NewInvalidParamsError("Invalid add provider parameters")
-		}
-
-		if addParams.Name == "" {
-			log.Printf("ERROR: Provider name is required")
-			fmt.Fprintf(os.Stderr, "ERROR: Provider name is required\n")
-			return nil, mcp.NewInvalidParamsError("Provider name is required")
-		}
-
-		args := []string{"provider", "add", addParams.Name}
-		for key, value := range addParams.Options {
-			args = append(args, "-o", fmt.Sprintf("%s=%s", key, value))
-		}
-
-		log.Printf("DEBUG: Executing devpod provider add with args: %v", args)
-		fmt.Fprintf(os.Stderr, "DEBUG: Executing devpod provider add with args: %v\n", args)
-
-		output, err := executeDevPodCommandWithDebug(ctx, args)
-		if err != nil {
-			log.Printf("ERROR: devpod_addProvider failed: %v", err)
-			fmt.Fprintf(os.Stderr, "ERROR: devpod_addProvider failed: %v\n", err)
-			return nil, fmt.Errorf("failed to add provider: %w\nOutput: %s", err, string(output))
-		}
-
-		result := map[string]interface{}{
-			"name":    addParams.Name,
-			"message": "Provider added successfully",
-			"output":  string(output),
-		}
-
-		log.Printf("DEBUG: devpod_addProvider returning result: %v", result)
-		fmt.Fprintf(os.Stderr, "DEBUG: devpod_addProvider returning result: %v\n", result)
-		fmt.Printf("RESPONSE: devpod_addProvider result: %v\n", result)
-		return result, nil
-	})
-
-	// SSH into workspace
-	server.RegisterHandler("devpod_ssh", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
-		var sshParams struct {
-			Name    string `json:"name"`
-			Command string `json:"command,omitempty"`
-		}
-
-		if err := json.Unmarshal(params, &sshParams); err != nil {
-			return nil, mcp.NewInvalidParamsError("Invalid SSH parameters")
-		}
-
-		if sshParams.Name == "" {
-			return nil, mcp.NewInvalidParamsError("Workspace name is required")
-		}
-
-		args := []string{"ssh", sshParams.Name}
-		if sshParams.Command != "" {
-			args = append(args, "--command", sshParams.Command)
-		}
-
-		cmd := exec.CommandContext(ctx, "devpod", args...)
-		output, err := cmd.CombinedOutput()
-		if err != nil {
-			return nil, fmt.Errorf("failed to SSH into workspace: %w\nOutput: %s", err, string(output))
-		}
-
-		return map[string]interface{}{
-			"name":    sshParams.Name,
-			"output":  string(output),
-			"message": "SSH command executed successfully",
-		}, nil
-	})
-
-	// Get workspace status
-	server.RegisterHandler("devpod_status", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
-		var statusParams struct {
-			Name string `json:"name"`
-		}
-
-		if err := json.Unmarshal(params, &statusParams); err != nil {
-			return nil, mcp.NewInvalidParamsError("Invalid status parameters")
-		}
-
-		if statusParams.Name == "" {
-			return nil, mcp.NewInvalidParamsError("Workspace name is required")
-		}
-
-		cmd := exec.CommandContext(ctx, "devpod", "status", statusParams.Name, "--output", "json")
-		output, err := cmd.Output()
-		if err != nil {
-			return nil, fmt.Errorf("failed to get workspace status: %w", err)
-		}
-
-		var status map[string]interface{}
-		if err := json.Unmarshal(output, &status); err != nil {
-			// If JSON parsing fails, return the text output
-			return map[string]interface{}{
-				"name":   statusParams.Name,
-				"status": strings.TrimSpace(string(output)),
-			}, nil
-		}
-
-		return status, nil
-	})
-
-	// Custom tools/call handler to route tool calls to our DevPod handlers
-	server.RegisterHandler("tools/call", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
-		var callParams struct {
-			Name      string                 `json:"name"`
-			Arguments map[string]interface{} `json:"arguments"`
-		}
-
-		if err := json.Unmarshal(params, &callParams); err != nil {
-			return nil, mcp.NewInvalidParamsError("Invalid tool call parameters")
-		}
-
-		// Handle framework's built-in echo tool
-		if callParams.Name == "echo" {
-			message, ok := callParams.Arguments["message"].(string)
-			if !ok {
-				return nil, mcp.NewInvalidParamsError("Missing or invalid 'message' parameter for echo tool")
-			}
-			return map[string]interface{}{
-				"content": []map[string]interface{}{
-					{
-						"type": "text",
-						"text": fmt.Sprintf("Echo: %s", message),
-					},
-				},
-			}, nil
-		}
-
-		// Get the handler for DevPod tools
-		handler := server.GetHandler(callParams.Name)
-		if handler == nil {
-			return nil, mcp.NewInvalidParamsError(fmt.Sprintf("Unknown tool: %s", callParams.Name))
-		}
+// printClientConfig prints the configuration of clients for a server
+// started with the transport, address and further arguments. A single
+// client's configuration is printed as plain JSON.
+func printClientConfig(clients []string, transportType, addr, host, basePath string, serverArgs []string) error {
+	command, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate the server binary: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(command); err == nil {
+		command = resolved
+	}
 
-		// Convert arguments back to JSON for the handler
-		argsBytes, err := json.Marshal(callParams.Arguments)
-		if err != nil {
-			return nil, mcp.NewInvalidParamsError("Failed to marshal tool arguments")
-		}
+	args := []string{"-transport=" + transportType}
+	if transportType != "stdio" {
+		args = append(args, "-addr="+addr)
+	}
+	opts := clientconfig.Options{
+		Transport: transportType,
+		Command:   command,
+		Args:      append(args, serverArgs...),
+	}
+	if transportType != "stdio" {
+		endpoint := "/mcp"
+		if transportType == "sse" {
+			endpoint = "/sse"
+		}
+		// The port may be given as 8080, :8080 or host:8080
+		port := addr[strings.LastIndex(addr, ":")+1:]
+		opts.URL = fmt.Sprintf("http://%s:%s%s", host, port, httptransport.Options{BasePath: basePath}.Endpoint(endpoint))
+	}
 
-		// Call the handler
-		result, err := handler(ctx, argsBytes)
+	if len(clients) == 1 {
+		config, err := clientconfig.Generate(clients[0], opts)
 		if err != nil {
-			return nil, err
-		}
-
-		// Wrap the result in the expected ToolsCallResult format
-		return map[string]interface{}{
-			"content": []map[string]interface{}{
-				{
-					"type": "text",
-					"text": fmt.Sprintf("%v", result),
-				},
-			},
-		}, nil
-	})
-}
-
-// Helper function to parse text workspace list output
-func parseTextWorkspaceList(output string) map[string]interface{} {
-	lines := strings.Split(strings.TrimSpace(output), "\n")
-	workspaces := []map[string]string{}
-
-	for _, line := range lines {
-		if line == "" || strings.HasPrefix(line, "NAME") {
-			continue
-		}
-		fields := strings.Fields(line)
-		if len(fields) >= 3 {
-			workspace := map[string]string{
-				"name":     fields[0],
-				"status":   fields[1],
-				"provider": fields[2],
-			}
-			if len(fields) > 3 {
-				workspace["ide"] = fields[3]
-			}
-			workspaces = append(workspaces, workspace)
+			return err
 		}
+		fmt.Print(string(config))
+		return nil
 	}
-
-	return map[string]interface{}{
-		"workspaces": workspaces,
+	text, err := clientconfig.Describe(clients, opts)
+	if err != nil {
+		return err
 	}
+	fmt.Println(text)
+	return nil
 }
 
-// Helper function to parse text provider list output
-func parseTextProviderList(output string) map[string]interface{} {
-	lines := strings.Split(strings.TrimSpace(output), "\n")
-	providers := []map[string]string{}
+// runSelftest implements the selftest subcommand, which runs the server
+// in-process and reports whether its protocol handlers and read-only tools
+// work
+func runSelftest(args []string) (bool, error) {
+	flags := flag.NewFlagSet("selftest", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: mcp-server-devpod selftest [flags]\n\n")
+		flags.PrintDefaults()
+	}
+	var (
+		live      = flags.Bool("live", false, "Call the devpod binary on PATH instead of canned output (read-only tools only)")
+		workspace = flags.String("workspace", "", "Workspace to query with devpod_status in live mode")
+		jsonOut   = flags.Bool("json", false, "Print the results as JSON")
+		timeout   = flags.Duration("timeout", 30*time.Second, "Timeout of each call")
+	)
+	if err := flags.Parse(args); err != nil {
+		return false, err
+	}
 
-	for _, line := range lines {
-		if line == "" || strings.HasPrefix(line, "NAME") {
-			continue
-		}
-		fields := strings.Fields(line)
-		if len(fields) >= 2 {
-			provider := map[string]string{
-				"name":    fields[0],
-				"version": fields[1],
-			}
-			if len(fields) > 2 && fields[2] == "*" {
-				provider["default"] = "true"
-			}
-			providers = append(providers, provider)
-		}
+	report, err := selftest.Run(context.Background(), selftest.Options{
+		Live:      *live,
+		Workspace: *workspace,
+		Version:   version,
+		Timeout:   *timeout,
+	})
+	if err != nil {
+		return false, err
 	}
 
-	return map[string]interface{}{
-		"providers": providers,
+	if *jsonOut {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(report)
+	} else {
+		err = report.WriteText(os.Stdout)
 	}
+	return report.OK(), err
 }
 
-// setupMessageHandler sets up the message handler for HTTP-based transports
-func setupMessageHandler(server *mcp.Server, t mcp.Transport) {
-	// Create a message handler function that processes JSON-RPC messages
-	messageHandler := func(message []byte) ([]byte, error) {
-		ctx := context.Background()
+// verbosity is the log level set by -verbose and -v
+type verbosity server.Verbosity
 
-		var request mcp.JSONRPCRequest
-		if err := json.Unmarshal(message, &request); err != nil {
-			return nil, fmt.Errorf("invalid JSON-RPC message: %w", err)
-		}
-
-		// Check if this is a notification (no ID field)
-		if request.ID == nil {
-			// This is a notification - handle it and don't send a response
-			if handler := server.GetNotificationHandler(request.Method); handler != nil {
-				if err := handler(ctx, request.Params); err != nil {
-					log.Printf("Error handling notification %s: %v", request.Method, err)
-				}
-			} else {
-				log.Printf("No handler for notification: %s", request.Method)
-			}
-			// Return nil for notifications (no response expected)
-			return nil, nil
-		}
-
-		// This is a request - handle it and send a response
-		response := mcp.JSONRPCResponse{
-			JSONRPC: mcp.JSONRPCVersion,
-			ID:      request.ID,
-		}
-
-		// Get the handler for this method
-		if handler := server.GetHandler(request.Method); handler != nil {
-			result, err := handler(ctx, request.Params)
-			if err != nil {
-				if rpcErr, ok := err.(*mcp.RPCError); ok {
-					response.Error = rpcErr
-				} else {
-					response.Error = &mcp.RPCError{
-						Code:    mcp.InternalError,
-						Message: err.Error(),
-					}
-				}
-			} else {
-				response.Result = result
-			}
-		} else {
-			response.Error = &mcp.RPCError{
-				Code:    mcp.MethodNotFound,
-				Message: fmt.Sprintf("Method not found: %s", request.Method),
-			}
-		}
+func (v *verbosity) String() string { return strconv.Itoa(int(*v)) }
 
-		// Marshal the response
-		return json.Marshal(response)
+func (v *verbosity) Set(value string) error {
+	level, err := strconv.Atoi(value)
+	if err != nil || level < int(server.VerbosityQuiet) || level > int(server.VerbosityDebug) {
+		return fmt.Errorf("invalid log level %q: expected 0, 1 or 2", value)
 	}
+	*v = verbosity(level)
+	return nil
+}
 
-	// Set up message handler for SSE transport
-	if sseTransport, ok := t.(*transport.SSETransport); ok {
-		sseTransport.SetMessageHandler(messageHandler)
+// verbosityShorthand is -v, which raises the log level by one when given
+// without a value, so -v -v is the same as -verbose=2
+type verbosityShorthand struct {
+	v *verbosity
+}
+
+func (s verbosityShorthand) String() string {
+	if s.v == nil {
+		return "0"
 	}
+	return s.v.String()
+}
 
-	// Set up message handler for HTTP Streams transport
-	if httpStreamsTransport, ok := t.(*transport.HTTPStreamsTransport); ok {
-		httpStreamsTransport.SetMessageHandler(messageHandler)
+func (s verbosityShorthand) Set(value string) error {
+	if value == "true" {
+		*s.v++
+		if *s.v > verbosity(server.VerbosityDebug) {
+			*s.v = verbosity(server.VerbosityDebug)
+		}
+		return nil
 	}
+	return s.v.Set(value)
 }
+
+// IsBoolFlag lets -v be given without a value
+func (s verbosityShorthand) IsBoolFlag() bool { return true }
@@ -0,0 +1,146 @@
+// Package clientconfig generates the configuration snippets MCP clients need
+// to launch or connect to the DevPod MCP server.
+package clientconfig
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ServerName is the key the server is registered under in client configs
+const ServerName = "devpod"
+
+// Supported clients
+const (
+	ClaudeDesktop = "claude-desktop"
+	Cursor        = "cursor"
+	Generic       = "generic"
+)
+
+// Clients lists the supported clients in the order they are printed
+var Clients = []string{ClaudeDesktop, Cursor, Generic}
+
+// configPaths are where each client reads its MCP configuration
+var configPaths = map[string]string{
+	ClaudeDesktop: "macOS: ~/Library/Application Support/Claude/claude_desktop_config.json, Windows: %APPDATA%\\Claude\\claude_desktop_config.json, Linux: ~/.config/Claude/claude_desktop_config.json",
+	Cursor:        "~/.cursor/mcp.json, or .cursor/mcp.json in a project",
+	Generic:       "the client's MCP server settings",
+}
+
+// Options describes how a client reaches the server
+type Options struct {
+	// Transport is stdio, sse or http-streams
+	Transport string
+	// Command is the absolute path of the server binary
+	Command string
+	// Args are the server arguments, including the transport flag
+	Args []string
+	// Env are environment variables the client passes to the server
+	Env map[string]string
+	// URL is the endpoint of the sse and http-streams transports, e.g.
+	// http://localhost:8080/mcp
+	URL string
+}
+
+// ConfigPath describes where a client reads its configuration
+func ConfigPath(client string) string {
+	return configPaths[client]
+}
+
+// Generate returns the indented JSON configuration of a client. Clients
+// launch stdio servers themselves; for the HTTP transports they connect to
+// the URL of a server started separately. Claude Desktop only launches
+// local processes, so it reaches HTTP servers through the mcp-remote bridge.
+func Generate(client string, opts Options) ([]byte, error) {
+	if _, ok := configPaths[client]; !ok {
+		return nil, fmt.Errorf("unknown client %q (supported: %s)", client, strings.Join(Clients, ", "))
+	}
+	env := opts.Env
+	if env == nil {
+		env = map[string]string{}
+	}
+
+	var server map[string]interface{}
+	switch opts.Transport {
+	case "stdio":
+		if opts.Command == "" {
+			return nil, fmt.Errorf("the stdio transport needs the server command")
+		}
+		args := opts.Args
+		if args == nil {
+			args = []string{}
+		}
+		server = map[string]interface{}{"command": opts.Command, "args": args, "env": env}
+		if client == Generic {
+			server["transport"] = "stdio"
+		}
+	case "sse", "http-streams":
+		if opts.URL == "" {
+			return nil, fmt.Errorf("the %s transport needs the server URL", opts.Transport)
+		}
+		switch client {
+		case ClaudeDesktop:
+			args := []string{"-y", "mcp-remote", opts.URL}
+			if opts.Transport == "sse" {
+				args = append(args, "--transport", "sse-only")
+			}
+			server = map[string]interface{}{"command": "npx", "args": args, "env": env}
+		case Cursor:
+			server = map[string]interface{}{"url": opts.URL}
+		default:
+			transport := "streamable-http"
+			if opts.Transport == "sse" {
+				transport = "sse"
+			}
+			server = map[string]interface{}{"url": opts.URL, "transport": transport}
+		}
+	default:
+		return nil, fmt.Errorf("unknown transport %q (supported: stdio, sse, http-streams)", opts.Transport)
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(map[string]interface{}{"mcpServers": map[string]interface{}{ServerName: server}}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Describe returns the configuration of each client with a heading naming
+// the client and its config file, followed by the command starting the
+// server when clients connect to a URL
+func Describe(clients []string, opts Options) (string, error) {
+	var b strings.Builder
+	for _, client := range clients {
+		config, err := Generate(client, opts)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "# %s (%s)\n%s\n", client, ConfigPath(client), config)
+	}
+	if opts.Transport != "stdio" {
+		fmt.Fprintf(&b, "# Start the server before connecting:\n# %s\n", CommandLine(opts.Command, opts.Args))
+	}
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
+
+// CommandLine quotes a command and its arguments for a POSIX shell
+func CommandLine(command string, args []string) string {
+	words := []string{shellQuote(command)}
+	for _, arg := range args {
+		words = append(words, shellQuote(arg))
+	}
+	return strings.Join(words, " ")
+}
+
+// shellQuote quotes a word unless it only has characters a shell leaves alone
+func shellQuote(word string) string {
+	if word != "" && strings.Trim(word, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./=:,@%+") == "" {
+		return word
+	}
+	return "'" + strings.ReplaceAll(word, "'", `'\''`) + "'"
+}
@@ -0,0 +1,73 @@
+package clientconfig
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	stdio := Options{Transport: "stdio", Command: "/usr/local/bin/mcp-server-devpod", Args: []string{"-transport=stdio"}}
+	streams := Options{Transport: "http-streams", Command: "/usr/local/bin/mcp-server-devpod", URL: "http://localhost:8080/mcp"}
+	sse := Options{Transport: "sse", Command: "/usr/local/bin/mcp-server-devpod", URL: "http://localhost:8080/sse"}
+
+	tests := []struct {
+		client string
+		opts   Options
+		want   map[string]interface{}
+	}{
+		{ClaudeDesktop, stdio, map[string]interface{}{
+			"command": "/usr/local/bin/mcp-server-devpod", "args": []interface{}{"-transport=stdio"}, "env": map[string]interface{}{},
+		}},
+		{ClaudeDesktop, streams, map[string]interface{}{
+			"command": "npx", "args": []interface{}{"-y", "mcp-remote", "http://localhost:8080/mcp"}, "env": map[string]interface{}{},
+		}},
+		{ClaudeDesktop, sse, map[string]interface{}{
+			"command": "npx", "args": []interface{}{"-y", "mcp-remote", "http://localhost:8080/sse", "--transport", "sse-only"}, "env": map[string]interface{}{},
+		}},
+		{Cursor, streams, map[string]interface{}{"url": "http://localhost:8080/mcp"}},
+		{Generic, streams, map[string]interface{}{"url": "http://localhost:8080/mcp", "transport": "streamable-http"}},
+		{Generic, sse, map[string]interface{}{"url": "http://localhost:8080/sse", "transport": "sse"}},
+	}
+	for _, tt := range tests {
+		data, err := Generate(tt.client, tt.opts)
+		if err != nil {
+			t.Errorf("Generate(%s, %s) failed: %v", tt.client, tt.opts.Transport, err)
+			continue
+		}
+		var config struct {
+			MCPServers map[string]map[string]interface{} `json:"mcpServers"`
+		}
+		if err := json.Unmarshal(data, &config); err != nil {
+			t.Fatalf("Invalid JSON: %v\n%s", err, data)
+		}
+		if got := config.MCPServers[ServerName]; !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Generate(%s, %s) = %v, want %v", tt.client, tt.opts.Transport, got, tt.want)
+		}
+	}
+
+	if _, err := Generate("vim", stdio); err == nil {
+		t.Error("Expected an unknown client to be rejected")
+	}
+	if _, err := Generate(Cursor, Options{Transport: "http-streams"}); err == nil {
+		t.Error("Expected a missing URL to be rejected")
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	text, err := Describe(Clients, Options{
+		Transport: "http-streams",
+		Command:   "/opt/mcp server/mcp-server-devpod",
+		Args:      []string{"-transport=http-streams", "-addr=8080"},
+		URL:       "http://localhost:8080/mcp",
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, want := range []string{"# claude-desktop (", "# cursor (", "# generic (", "# '/opt/mcp server/mcp-server-devpod' -transport=http-streams -addr=8080"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected %q in:\n%s", want, text)
+		}
+	}
+}
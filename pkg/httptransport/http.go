@@ -0,0 +1,224 @@
+package httptransport
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultHeartbeat is the default interval of heartbeats on event streams
+const DefaultHeartbeat = 15 * time.Second
+
+// DefaultSessionTTL is the default time HTTP Streams sessions are kept
+// without requests or an open event stream
+const DefaultSessionTTL = 30 * time.Minute
+
+// Options configure how the HTTP transports are served to browsers and
+// behind reverse proxies
+type Options struct {
+	// CORSOrigins are the browser origins allowed to call the endpoints, e.g.
+	// https://inspector.example.com; "*" allows any origin. Requests from
+	// other origins are refused.
+	CORSOrigins []string
+	// BasePath is prepended to every endpoint, e.g. /devpod-mcp serves
+	// /devpod-mcp/mcp, for proxies that forward the path unchanged
+	BasePath string
+	// Heartbeat is the interval of comments sent on idle event streams so
+	// proxies don't close them during long operations; 0 disables them
+	Heartbeat time.Duration
+	// SessionTTL is how long an HTTP Streams session is kept without
+	// requests or an open event stream, so clients that disconnect without
+	// ending their session don't leak it; 0 keeps sessions until ended
+	SessionTTL time.Duration
+	// Authenticate identifies the user of a request, e.g. by a bearer token
+	// or a header set by an authenticating proxy. Requests it returns an
+	// error for are refused with 401 Unauthorized; nil serves anyone.
+	Authenticate func(r *http.Request) (string, error)
+}
+
+// DefaultOptions allow any origin, serve the endpoints at the root path,
+// send heartbeats every DefaultHeartbeat and expire sessions idle for
+// DefaultSessionTTL
+func DefaultOptions() Options {
+	return Options{CORSOrigins: []string{"*"}, Heartbeat: DefaultHeartbeat, SessionTTL: DefaultSessionTTL}
+}
+
+// route is an additional handler served by a transport
+type route struct {
+	path    string
+	handler http.Handler
+	// browser routes do their own access control, see HandleBrowser
+	browser bool
+}
+
+// ParseOrigins parses a comma-separated list of CORS origins
+func ParseOrigins(value string) []string {
+	var origins []string
+	for _, origin := range strings.Split(value, ",") {
+		if origin = strings.TrimSuffix(strings.TrimSpace(origin), "/"); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
+
+// normalize cleans the base path to a leading slash and no trailing one, or
+// empty for the root
+func (o Options) normalize() Options {
+	path := strings.Trim(strings.TrimSpace(o.BasePath), "/")
+	if path != "" {
+		path = "/" + path
+	}
+	o.BasePath = path
+	return o
+}
+
+// Endpoint returns the path an endpoint is served at below the base path
+func (o Options) Endpoint(path string) string {
+	return o.normalize().BasePath + path
+}
+
+// allowedOrigin returns the value of Access-Control-Allow-Origin for a
+// request origin, or empty when the origin is not allowed
+func (o Options) allowedOrigin(origin string) string {
+	for _, allowed := range o.CORSOrigins {
+		if allowed == "*" {
+			return "*"
+		}
+		if origin != "" && strings.EqualFold(allowed, origin) {
+			return origin
+		}
+	}
+	return ""
+}
+
+// withCORS answers preflight requests and adds CORS headers for allowed
+// origins. Browser requests from other origins are refused so that web pages
+// cannot drive a server on localhost. methods lists the allowed methods.
+func (o Options) withCORS(next http.Handler, methods string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		allowed := o.allowedOrigin(origin)
+		if origin != "" && allowed == "" {
+			http.Error(w, "Origin not allowed", http.StatusForbidden)
+			return
+		}
+		if allowed != "" {
+			if allowed != "*" {
+				w.Header().Add("Vary", "Origin")
+			}
+			w.Header().Set("Access-Control-Allow-Origin", allowed)
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Mcp-Session-Id, Last-Event-ID")
+			w.Header().Set("Access-Control-Allow-Methods", methods)
+			w.Header().Set("Access-Control-Expose-Headers", "Mcp-Session-Id")
+		}
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// userKey is the request context key of the authenticated user
+type userKey struct{}
+
+// withAuth refuses requests Authenticate rejects and attaches the user to
+// the others
+func (o Options) withAuth(next http.Handler) http.Handler {
+	if o.Authenticate == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, err := o.Authenticate(r)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="mcp-server-devpod"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userKey{}, user)))
+	})
+}
+
+// withUser attaches the user to requests Authenticate accepts and passes the
+// others on without one
+func (o Options) withUser(next http.Handler) http.Handler {
+	if o.Authenticate == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, err := o.Authenticate(r); err == nil {
+			r = r.WithContext(context.WithValue(r.Context(), userKey{}, user))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handler serves the transport's endpoints on mux with the additional
+// routes. The API endpoints and routes get the CORS policy and require
+// authentication; browser routes get neither.
+func (o Options) handler(mux *http.ServeMux, routes []route, methods string) http.Handler {
+	var browser []route
+	for _, route := range routes {
+		if route.browser {
+			browser = append(browser, route)
+			continue
+		}
+		mux.Handle(o.Endpoint(route.path), o.withAuth(route.handler))
+	}
+	api := o.withCORS(mux, methods)
+	if len(browser) == 0 {
+		return api
+	}
+	outer := http.NewServeMux()
+	for _, route := range browser {
+		outer.Handle(o.Endpoint(route.path), o.withUser(route.handler))
+	}
+	outer.Handle("/", api)
+	return outer
+}
+
+// RequestUser returns the user the transport authenticated a request as, or
+// "" without authentication
+func RequestUser(r *http.Request) string {
+	user, _ := r.Context().Value(userKey{}).(string)
+	return user
+}
+
+// forwardedValue returns the first value of an X-Forwarded-* header, which
+// proxies chain as a comma-separated list
+func forwardedValue(r *http.Request, header string) string {
+	value, _, _ := strings.Cut(r.Header.Get(header), ",")
+	return strings.TrimSpace(value)
+}
+
+// externalURL returns the URL clients reach an endpoint at. A proxy that
+// strips a path prefix reports it in X-Forwarded-Prefix; one that serves a
+// different host or scheme reports them in X-Forwarded-Host and
+// X-Forwarded-Proto, making the URL absolute.
+func (o Options) externalURL(r *http.Request, endpoint string) string {
+	path := o.BasePath + endpoint
+	if prefix := strings.TrimRight(forwardedValue(r, "X-Forwarded-Prefix"), "/"); strings.HasPrefix(prefix, "/") && !strings.ContainsAny(prefix, "?#\\ ") {
+		path = prefix + path
+	}
+
+	host := forwardedValue(r, "X-Forwarded-Host")
+	if host == "" || strings.ContainsAny(host, "/?#@\\ ") {
+		return path
+	}
+	scheme := forwardedValue(r, "X-Forwarded-Proto")
+	if scheme != "https" {
+		scheme = "http"
+	}
+	return scheme + "://" + host + path
+}
+
+// clientAddr returns the address of the client, taking it from
+// X-Forwarded-For behind a proxy
+func clientAddr(r *http.Request) string {
+	if addr := forwardedValue(r, "X-Forwarded-For"); addr != "" {
+		return addr
+	}
+	return r.RemoteAddr
+}
@@ -0,0 +1,529 @@
+package httptransport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// replayLimit bounds the messages kept per client for replay on reconnect
+const replayLimit = 256
+
+// resumeWindow is how long a client's session outlives its event stream, so
+// responses to calls still running reach the client when it reconnects
+const resumeWindow = 5 * time.Minute
+
+// SSE implements mcp.Transport over Server-Sent Events. It speaks the
+// protocol of the framework's SSE transport: clients open an event stream
+// with GET /sse, receive an endpoint event with the URL to POST messages to,
+// and receive responses as message events on the stream.
+//
+// Every message event carries an ID. A client that loses its stream
+// reconnects with the Last-Event-ID header, or the sessionId parameter, and
+// receives the messages it missed from a bounded replay buffer.
+type SSE struct {
+	addr     string
+	server   *http.Server
+	clients  map[string]*sseClient
+	messages chan []byte
+	done     chan struct{}
+	mu       sync.RWMutex
+	closed   bool
+	handler  SessionHandler
+	onClose  func(sessionID string)
+	opts     Options
+	routes   []route
+}
+
+// NewSSE creates an SSE transport listening on addr
+func NewSSE(addr string, opts Options) *SSE {
+	return &SSE{
+		addr:     addr,
+		clients:  make(map[string]*sseClient),
+		messages: make(chan []byte, 100),
+		done:     make(chan struct{}),
+		opts:     opts.normalize(),
+	}
+}
+
+// SetMessageHandler sets the function that processes incoming messages
+func (t *SSE) SetMessageHandler(handler func([]byte) ([]byte, error)) {
+	t.SetSessionMessageHandler(func(_ string, message []byte) ([]byte, error) {
+		return handler(message)
+	})
+}
+
+// SetSessionMessageHandler sets the function that processes incoming
+// messages, told the client session each message comes from
+func (t *SSE) SetSessionMessageHandler(handler SessionHandler) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.handler = handler
+}
+
+// SetSessionClosedHandler sets a function called when a client is dropped
+// after its resume window
+func (t *SSE) SetSessionClosedHandler(onClose func(sessionID string)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.onClose = onClose
+}
+
+// Start starts the HTTP server
+func (t *SSE) Start(ctx context.Context) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.server != nil {
+		return fmt.Errorf("transport already started")
+	}
+
+	t.server = &http.Server{
+		Addr:              t.addr,
+		Handler:           t.Handler(),
+		ReadHeaderTimeout: 30 * time.Second,
+	}
+
+	go func() {
+		if err := t.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("[SSE] Server error: %v", err)
+		}
+	}()
+
+	return nil
+}
+
+// Handle serves an additional handler at path below the base path, e.g. a
+// status page. Call it before Start.
+func (t *SSE) Handle(path string, handler http.Handler) {
+	t.routes = append(t.routes, route{path: path, handler: handler})
+}
+
+// HandleBrowser serves a handler opened directly in a browser, such as a
+// proxied web app, at path below the base path. Browsers cannot send bearer
+// tokens when following a link, so unlike Handle its requests are not
+// refused without authentication, and the CORS policy of the API endpoints
+// does not apply: the handler does its own access control, with
+// RequestUser telling the authenticated user if any. Call it before Start.
+func (t *SSE) HandleBrowser(path string, handler http.Handler) {
+	t.routes = append(t.routes, route{path: path, handler: handler, browser: true})
+}
+
+// Handler returns the HTTP handler serving the transport's endpoints below
+// the base path
+func (t *SSE) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(t.opts.Endpoint("/sse"), t.opts.withAuth(http.HandlerFunc(t.handleStream)))
+	mux.Handle(t.opts.Endpoint("/message"), t.opts.withAuth(http.HandlerFunc(t.handleMessage)))
+	mux.HandleFunc(t.opts.Endpoint("/health"), t.handleHealth)
+	return t.opts.handler(mux, t.routes, "GET, POST, OPTIONS")
+}
+
+// Stop closes all event streams and shuts the HTTP server down
+func (t *SSE) Stop() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return nil
+	}
+
+	// Streams end on done and remove their own clients
+	t.closed = true
+	close(t.done)
+
+	if t.server != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return t.server.Shutdown(ctx)
+	}
+	return nil
+}
+
+// Close closes the transport
+func (t *SSE) Close() error {
+	return t.Stop()
+}
+
+// Send sends a message to every client, including clients about to
+// reconnect
+func (t *SSE) Send(message []byte) error {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if t.closed {
+		return fmt.Errorf("transport is closed")
+	}
+
+	for _, client := range t.clients {
+		client.push(message)
+	}
+	return nil
+}
+
+// SendTo sends a message to one client, e.g. a request only that client
+// may answer
+func (t *SSE) SendTo(sessionID string, message []byte) error {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if t.closed {
+		return fmt.Errorf("transport is closed")
+	}
+	client, ok := t.clients[sessionID]
+	if !ok {
+		return fmt.Errorf("unknown session %s", sessionID)
+	}
+	client.push(message)
+	return nil
+}
+
+// Receive returns the channel of messages received before a handler is set
+func (t *SSE) Receive() <-chan []byte {
+	return t.messages
+}
+
+// sseEvent is a message queued on a client's stream
+type sseEvent struct {
+	seq  int64
+	data []byte
+}
+
+// sseClient is a client session and the messages queued for it. It outlives
+// its event stream by resumeWindow.
+type sseClient struct {
+	id     string
+	mu     sync.Mutex
+	events []sseEvent
+	// next is the sequence number of the next message
+	next int64
+	// delivered is the sequence number of the last message written to a stream
+	delivered int64
+	notify    chan struct{}
+	// done ends the current stream
+	done         chan struct{}
+	connected    bool
+	disconnected time.Time
+}
+
+func newSSEClient(id string) *sseClient {
+	return &sseClient{id: id, next: 1, notify: make(chan struct{}, 1)}
+}
+
+// push queues a message, dropping the oldest beyond replayLimit
+func (c *sseClient) push(message []byte) {
+	c.mu.Lock()
+	c.events = append(c.events, sseEvent{seq: c.next, data: message})
+	c.next++
+	if len(c.events) > replayLimit {
+		c.events = c.events[len(c.events)-replayLimit:]
+	}
+	c.mu.Unlock()
+
+	select {
+	case c.notify <- struct{}{}:
+	default:
+	}
+}
+
+// pending returns the messages after a sequence number and whether older
+// ones have already left the replay buffer
+func (c *sseClient) pending(after int64) ([]sseEvent, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var events []sseEvent
+	for _, event := range c.events {
+		if event.seq > after {
+			events = append(events, event)
+		}
+	}
+	missed := len(c.events) > 0 && c.events[0].seq > after+1
+	return events, missed
+}
+
+// attach starts a new stream for the client, ending any previous one, and
+// returns the sequence number to resume after: the Last-Event-ID of the
+// client when known, otherwise the last message delivered
+func (c *sseClient) attach(resumeAfter int64) (chan struct{}, int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.done != nil {
+		close(c.done)
+	}
+	c.done = make(chan struct{})
+	c.connected = true
+	if resumeAfter < 0 || resumeAfter >= c.next {
+		resumeAfter = c.delivered
+	}
+	return c.done, resumeAfter
+}
+
+// detach marks the client disconnected unless a newer stream took over
+func (c *sseClient) detach(done chan struct{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.done == done {
+		close(c.done)
+		c.done = nil
+		c.connected = false
+		c.disconnected = time.Now()
+	}
+}
+
+// markDelivered records the last message written to a stream
+func (c *sseClient) markDelivered(seq int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if seq > c.delivered {
+		c.delivered = seq
+	}
+}
+
+// expired reports whether a disconnected client is past its resume window
+func (c *sseClient) expired(now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return !c.connected && now.Sub(c.disconnected) > resumeWindow
+}
+
+func (c *sseClient) isConnected() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.connected
+}
+
+// eventID identifies a message by client and sequence number, so that a
+// reconnecting EventSource, which only sends Last-Event-ID, finds its session
+func eventID(clientID string, seq int64) string {
+	return clientID + "-" + strconv.FormatInt(seq, 10)
+}
+
+// parseEventID splits an ID created by eventID
+func parseEventID(id string) (string, int64, bool) {
+	i := strings.LastIndex(id, "-")
+	if i <= 0 {
+		return "", 0, false
+	}
+	seq, err := strconv.ParseInt(id[i+1:], 10, 64)
+	if err != nil || seq < 0 {
+		return "", 0, false
+	}
+	return id[:i], seq, true
+}
+
+// connect returns the client a stream request belongs to, creating it when
+// new, and drops clients past their resume window
+func (t *SSE) connect(id string) (*sseClient, error) {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return nil, fmt.Errorf("transport is closed")
+	}
+
+	var expired []string
+	now := time.Now()
+	for clientID, client := range t.clients {
+		if client.expired(now) {
+			delete(t.clients, clientID)
+			expired = append(expired, clientID)
+		}
+	}
+	// The closed handler runs after the lock is released
+	onClose := t.onClose
+	defer func() {
+		if onClose != nil {
+			for _, clientID := range expired {
+				onClose(clientID)
+			}
+		}
+	}()
+	defer t.mu.Unlock()
+
+	if id == "" {
+		id = generateSessionID()
+	}
+	client, ok := t.clients[id]
+	if !ok {
+		client = newSSEClient(id)
+		t.clients[id] = client
+	}
+	return client, nil
+}
+
+// handleStream serves the event stream of a client. The endpoint event
+// carries the URL the client reaches the message endpoint at, which behind a
+// reverse proxy includes the proxy's prefix. A reconnecting client first
+// receives the messages it missed.
+func (t *SSE) handleStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	id := r.URL.Query().Get("sessionId")
+	resumeAfter := int64(-1)
+	if lastID, seq, ok := parseEventID(r.Header.Get("Last-Event-ID")); ok && (id == "" || id == lastID) {
+		id, resumeAfter = lastID, seq
+	}
+	client, err := t.connect(id)
+	if err != nil {
+		http.Error(w, "Transport closed", http.StatusServiceUnavailable)
+		return
+	}
+	done, sent := client.attach(resumeAfter)
+	defer client.detach(done)
+	log.Printf("[SSE] Client %s connected from %s", client.id, clientAddr(r))
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache, no-transform")
+	w.Header().Set("Connection", "keep-alive")
+	// Keep nginx from buffering the stream
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	endpoint := t.opts.externalURL(r, "/message") + "?sessionId=" + url.QueryEscape(client.id)
+	if _, err := fmt.Fprintf(w, "event: endpoint\ndata: %s\n\n", endpoint); err != nil {
+		return
+	}
+	flusher.Flush()
+
+	var heartbeat <-chan time.Time
+	if t.opts.Heartbeat > 0 {
+		ticker := time.NewTicker(t.opts.Heartbeat)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+
+	for {
+		events, missed := client.pending(sent)
+		if missed {
+			log.Printf("[SSE] Client %s missed messages beyond the replay buffer", client.id)
+		}
+		for _, event := range events {
+			if _, err := fmt.Fprintf(w, "id: %s\nevent: message\ndata: %s\n\n", eventID(client.id, event.seq), event.data); err != nil {
+				return
+			}
+			sent = event.seq
+		}
+		if len(events) > 0 {
+			flusher.Flush()
+			client.markDelivered(sent)
+		}
+
+		select {
+		case <-client.notify:
+		case <-heartbeat:
+			// Comments keep proxies from closing idle streams and are
+			// ignored by clients
+			if _, err := fmt.Fprintf(w, ": heartbeat %d\n\n", time.Now().Unix()); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-done:
+			return
+		case <-t.done:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// handleMessage processes a POSTed JSON-RPC message and answers it on the
+// client's event stream
+func (t *SSE) handleMessage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id := r.URL.Query().Get("sessionId")
+	if id == "" {
+		http.Error(w, "Missing sessionId parameter", http.StatusBadRequest)
+		return
+	}
+
+	t.mu.RLock()
+	client, ok := t.clients[id]
+	handler := t.handler
+	t.mu.RUnlock()
+	if !ok {
+		http.Error(w, "Invalid session ID", http.StatusBadRequest)
+		return
+	}
+
+	var message json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if handler == nil {
+		select {
+		case t.messages <- message:
+		default:
+			log.Printf("[SSE] Message buffer full, dropping message")
+		}
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	response, err := handler(id, message)
+	if err != nil {
+		log.Printf("[SSE] Error processing message: %v", err)
+		var envelope struct {
+			ID interface{} `json:"id"`
+		}
+		_ = json.Unmarshal(message, &envelope)
+		response, _ = json.Marshal(map[string]interface{}{
+			"jsonrpc": "2.0",
+			"id":      envelope.ID,
+			"error":   map[string]interface{}{"code": -32603, "message": "Internal error"},
+		})
+	}
+	if response != nil {
+		client.push(response)
+	}
+	w.WriteHeader(http.StatusAccepted)
+	if _, err := w.Write([]byte("Accepted")); err != nil {
+		log.Printf("[SSE] Failed to write response: %v", err)
+	}
+}
+
+// connectedClients counts the clients with an open event stream
+func (t *SSE) connectedClients() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	clients := 0
+	for _, client := range t.clients {
+		if client.isConnected() {
+			clients++
+		}
+	}
+	return clients
+}
+
+func (t *SSE) handleHealth(w http.ResponseWriter, r *http.Request) {
+	clients := t.connectedClients()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":    "ok",
+		"clients":   clients,
+		"transport": "sse",
+		"timestamp": time.Now().Unix(),
+	}); err != nil {
+		log.Printf("[SSE] Failed to encode health response: %v", err)
+	}
+}
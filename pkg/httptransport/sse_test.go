@@ -0,0 +1,253 @@
+package httptransport
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readEvent reads the next event from an SSE stream as its event name and
+// data, skipping comments
+func readEvent(t *testing.T, reader *bufio.Reader) (string, string) {
+	t.Helper()
+	_, event, data := readEventWithID(t, reader)
+	return event, data
+}
+
+// readEventWithID reads the next event from an SSE stream with its ID
+func readEventWithID(t *testing.T, reader *bufio.Reader) (string, string, string) {
+	t.Helper()
+	var id, event, data string
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Failed to read event: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case line == "":
+			if event != "" || data != "" {
+				return id, event, data
+			}
+		case strings.HasPrefix(line, "id: "):
+			id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func TestSSEEndpointBehindProxy(t *testing.T) {
+	s := NewSSE(":0", Options{CORSOrigins: []string{"*"}, BasePath: "/devpod-mcp"})
+	s.SetMessageHandler(func(message []byte) ([]byte, error) {
+		return []byte(`{"jsonrpc":"2.0","id":1,"result":{}}`), nil
+	})
+	server := httptest.NewServer(s.Handler())
+	defer server.Close()
+	defer s.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/devpod-mcp/sse", nil)
+	req.Header.Set("X-Forwarded-Proto", "https")
+	req.Header.Set("X-Forwarded-Host", "tools.example.com")
+	req.Header.Set("X-Forwarded-Prefix", "/proxy/")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to open stream: %v", err)
+	}
+	defer resp.Body.Close()
+	reader := bufio.NewReader(resp.Body)
+
+	event, endpoint := readEvent(t, reader)
+	if event != "endpoint" || !strings.HasPrefix(endpoint, "https://tools.example.com/proxy/devpod-mcp/message?sessionId=") {
+		t.Fatalf("Expected the external message URL, got %s %q", event, endpoint)
+	}
+
+	// The proxy forwards the message to the path below its prefix
+	local := server.URL + strings.TrimPrefix(endpoint, "https://tools.example.com/proxy")
+	post, err := http.Post(local, "application/json", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`))
+	if err != nil {
+		t.Fatalf("Failed to post message: %v", err)
+	}
+	post.Body.Close()
+	if post.StatusCode != http.StatusAccepted {
+		t.Fatalf("Expected 202 for a message, got %d", post.StatusCode)
+	}
+	if event, data := readEvent(t, reader); event != "message" || !strings.Contains(data, `"result"`) {
+		t.Errorf("Expected the response on the stream, got %s %q", event, data)
+	}
+}
+
+func TestExternalURL(t *testing.T) {
+	opts := Options{BasePath: "/mcp-base"}
+	tests := []struct {
+		headers map[string]string
+		want    string
+	}{
+		{nil, "/mcp-base/message"},
+		{map[string]string{"X-Forwarded-Prefix": "/a, /b"}, "/a/mcp-base/message"},
+		{map[string]string{"X-Forwarded-Prefix": "relative"}, "/mcp-base/message"},
+		{map[string]string{"X-Forwarded-Host": "example.com"}, "http://example.com/mcp-base/message"},
+		{map[string]string{"X-Forwarded-Host": "evil.com/x", "X-Forwarded-Proto": "https"}, "/mcp-base/message"},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/mcp-base/sse", nil)
+		for name, value := range test.headers {
+			req.Header.Set(name, value)
+		}
+		if got := opts.externalURL(req, "/message"); got != test.want {
+			t.Errorf("externalURL with %v = %q, want %q", test.headers, got, test.want)
+		}
+	}
+}
+
+// openStream opens an SSE stream and returns its reader and a function
+// closing it
+func openStream(t *testing.T, url, lastEventID string) (*bufio.Reader, func()) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		cancel()
+		t.Fatalf("Failed to open stream: %v", err)
+	}
+	return bufio.NewReader(resp.Body), func() {
+		cancel()
+		resp.Body.Close()
+	}
+}
+
+func TestSSEResumesWithLastEventID(t *testing.T) {
+	s := NewSSE(":0", Options{})
+	s.SetMessageHandler(func(message []byte) ([]byte, error) {
+		return message, nil
+	})
+	server := httptest.NewServer(s.Handler())
+	defer server.Close()
+	defer s.Stop()
+
+	reader, closeStream := openStream(t, server.URL+"/sse", "")
+	_, endpoint := readEvent(t, reader)
+	postMessage := func(body string) {
+		t.Helper()
+		resp, err := http.Post(server.URL+endpoint, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("Failed to post message: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusAccepted {
+			t.Fatalf("Expected 202 for a message, got %d", resp.StatusCode)
+		}
+	}
+
+	postMessage(`{"id":1}`)
+	lastID, _, data := readEventWithID(t, reader)
+	if lastID == "" || data != `{"id":1}` {
+		t.Fatalf("Expected the first response with an ID, got %q %q", lastID, data)
+	}
+	closeStream()
+
+	// The connection dropped while the second call was running
+	deadline := time.Now().Add(2 * time.Second)
+	for s.connectedClients() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	postMessage(`{"id":2}`)
+	postMessage(`{"id":3}`)
+
+	reader, closeStream = openStream(t, server.URL+"/sse", lastID)
+	defer closeStream()
+	if event, resumed := readEvent(t, reader); event != "endpoint" || resumed != endpoint {
+		t.Errorf("Expected the same endpoint after reconnecting, got %q", resumed)
+	}
+	for _, want := range []string{`{"id":2}`, `{"id":3}`} {
+		if _, _, data := readEventWithID(t, reader); data != want {
+			t.Errorf("Expected replayed message %s, got %s", want, data)
+		}
+	}
+}
+
+func TestSSEHeartbeat(t *testing.T) {
+	s := NewSSE(":0", Options{Heartbeat: 10 * time.Millisecond})
+	server := httptest.NewServer(s.Handler())
+	defer server.Close()
+	defer s.Stop()
+
+	reader, closeStream := openStream(t, server.URL+"/sse", "")
+	defer closeStream()
+	readEvent(t, reader)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Expected a heartbeat, got %v", err)
+		}
+		if strings.HasPrefix(line, ": heartbeat ") {
+			return
+		}
+	}
+}
+
+func TestSSESendToOneClient(t *testing.T) {
+	s := NewSSE(":0", Options{})
+	s.SetSessionMessageHandler(func(sessionID string, message []byte) ([]byte, error) {
+		return []byte(`"` + sessionID + `"`), nil
+	})
+	server := httptest.NewServer(s.Handler())
+	defer server.Close()
+	defer s.Stop()
+
+	first, closeFirst := openStream(t, server.URL+"/sse", "")
+	defer closeFirst()
+	_, endpoint := readEvent(t, first)
+	second, closeSecond := openStream(t, server.URL+"/sse", "")
+	defer closeSecond()
+	readEvent(t, second)
+	id := endpoint[strings.Index(endpoint, "sessionId=")+len("sessionId="):]
+
+	resp, err := http.Post(server.URL+endpoint, "application/json", strings.NewReader(`{"id":1}`))
+	if err != nil {
+		t.Fatalf("Failed to post message: %v", err)
+	}
+	resp.Body.Close()
+	if _, data := readEvent(t, first); data != `"`+id+`"` {
+		t.Errorf("Expected the handler to be told session %s, got %s", id, data)
+	}
+
+	if err := s.SendTo(id, []byte(`{"method":"only"}`)); err != nil {
+		t.Fatalf("SendTo failed: %v", err)
+	}
+	if err := s.Send([]byte(`{"method":"all"}`)); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if _, data := readEvent(t, first); data != `{"method":"only"}` {
+		t.Errorf("Expected the message for the first client, got %s", data)
+	}
+	if _, data := readEvent(t, second); data != `{"method":"all"}` {
+		t.Errorf("Expected the second client to only get the broadcast, got %s", data)
+	}
+	if err := s.SendTo("unknown", []byte(`{}`)); err == nil {
+		t.Error("Expected an error for an unknown session")
+	}
+}
+
+func TestParseEventID(t *testing.T) {
+	if id, seq, ok := parseEventID(eventID("a-b", 12)); !ok || id != "a-b" || seq != 12 {
+		t.Errorf("Expected a-b 12, got %q %d %v", id, seq, ok)
+	}
+	for _, invalid := range []string{"", "12", "-3", "abc-", "abc-x"} {
+		if _, _, ok := parseEventID(invalid); ok {
+			t.Errorf("Expected %q to be rejected", invalid)
+		}
+	}
+}
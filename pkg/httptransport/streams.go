@@ -0,0 +1,495 @@
+// Package httptransport provides the MCP HTTP transports. The HTTP Streams
+// transport tells the message handler which client session each message
+// belongs to. It speaks the same protocol as the framework's HTTP Streams
+// transport: clients POST JSON-RPC messages to /mcp with an Mcp-Session-Id
+// header and receive responses and notifications on a GET /mcp event stream.
+// The SSE transport speaks the protocol of the framework's SSE transport.
+// Both can be served below a base path, behind reverse proxies and to
+// browsers of configured origins.
+package httptransport
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// SessionHandler processes a message from a session and returns the response
+// to stream back, or nil when there is none
+type SessionHandler func(sessionID string, message []byte) ([]byte, error)
+
+// Streams implements mcp.Transport over HTTP Streams with per-session dispatch
+type Streams struct {
+	addr     string
+	server   *http.Server
+	sessions map[string]*session
+	messages chan []byte
+	done     chan struct{}
+	mu       sync.RWMutex
+	closed   bool
+	handler  SessionHandler
+	onClose  func(sessionID string)
+	opts     Options
+	routes   []route
+}
+
+// session is one client connection and its event stream
+type session struct {
+	id string
+	// user is the authenticated user that opened the session
+	user     string
+	messages chan []byte
+	done     chan struct{}
+	active   bool
+	// lastSeen is the time of the last request or the end of the last
+	// event stream
+	lastSeen time.Time
+}
+
+// NewStreams creates an HTTP Streams transport listening on addr with the
+// default options
+func NewStreams(addr string) *Streams {
+	return NewStreamsWithOptions(addr, DefaultOptions())
+}
+
+// NewStreamsWithOptions creates an HTTP Streams transport listening on addr
+func NewStreamsWithOptions(addr string, opts Options) *Streams {
+	return &Streams{
+		addr:     addr,
+		sessions: make(map[string]*session),
+		messages: make(chan []byte, 100),
+		done:     make(chan struct{}),
+		opts:     opts.normalize(),
+	}
+}
+
+// SetSessionMessageHandler sets the function that processes incoming messages
+func (t *Streams) SetSessionMessageHandler(handler SessionHandler) {
+	t.handler = handler
+}
+
+// SetSessionClosedHandler sets a function called when a client ends its session
+func (t *Streams) SetSessionClosedHandler(onClose func(sessionID string)) {
+	t.onClose = onClose
+}
+
+// Start starts the HTTP server
+func (t *Streams) Start(ctx context.Context) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.server != nil {
+		return fmt.Errorf("transport already started")
+	}
+
+	t.server = &http.Server{
+		Addr:              t.addr,
+		Handler:           t.Handler(),
+		ReadHeaderTimeout: 30 * time.Second,
+	}
+
+	go func() {
+		if err := t.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("[HTTP-STREAMS] Server error: %v", err)
+		}
+	}()
+	if t.opts.SessionTTL > 0 {
+		go t.sweep()
+	}
+
+	return nil
+}
+
+// sweep expires idle sessions until the transport stops
+func (t *Streams) sweep() {
+	interval := t.opts.SessionTTL / 2
+	if interval > time.Minute {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			t.expireIdle(now)
+		case <-t.done:
+			return
+		}
+	}
+}
+
+// expireIdle ends the sessions without an open event stream that made no
+// request for longer than the session TTL, as a DELETE would
+func (t *Streams) expireIdle(now time.Time) {
+	if t.opts.SessionTTL <= 0 {
+		return
+	}
+	var expired []string
+	t.mu.Lock()
+	for id, session := range t.sessions {
+		if !session.active && now.Sub(session.lastSeen) > t.opts.SessionTTL {
+			delete(t.sessions, id)
+			close(session.done)
+			expired = append(expired, id)
+		}
+	}
+	t.mu.Unlock()
+
+	for _, id := range expired {
+		log.Printf("[HTTP-STREAMS] Session %s expired after %s idle", id, t.opts.SessionTTL)
+		if t.onClose != nil {
+			t.onClose(id)
+		}
+	}
+}
+
+// Stop closes all sessions and shuts the HTTP server down
+func (t *Streams) Stop() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return nil
+	}
+
+	t.closed = true
+	close(t.done)
+	for _, session := range t.sessions {
+		close(session.done)
+	}
+
+	if t.server != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return t.server.Shutdown(ctx)
+	}
+	return nil
+}
+
+// Close closes the transport
+func (t *Streams) Close() error {
+	return t.Stop()
+}
+
+// Send sends a message to every session with an open event stream
+func (t *Streams) Send(message []byte) error {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if t.closed {
+		return fmt.Errorf("transport is closed")
+	}
+
+	for _, session := range t.sessions {
+		if session.active {
+			t.enqueue(session, message)
+		}
+	}
+	return nil
+}
+
+// SendTo sends a message to the event stream of one session, e.g. the
+// progress of a call the session made
+func (t *Streams) SendTo(sessionID string, message []byte) error {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if t.closed {
+		return fmt.Errorf("transport is closed")
+	}
+	session, ok := t.sessions[sessionID]
+	if !ok {
+		return fmt.Errorf("unknown session %s", sessionID)
+	}
+	t.enqueue(session, message)
+	return nil
+}
+
+// SendToUser sends a message to the event streams of the sessions of an
+// authenticated user, or of the sessions without one for "", e.g. changes to
+// the user's workspaces
+func (t *Streams) SendToUser(user string, message []byte) error {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if t.closed {
+		return fmt.Errorf("transport is closed")
+	}
+	for _, session := range t.sessions {
+		if session.active && session.user == user {
+			t.enqueue(session, message)
+		}
+	}
+	return nil
+}
+
+// Receive returns the channel of messages not handled by the session handler.
+// All messages go to the handler once one is set, so it stays empty then.
+func (t *Streams) Receive() <-chan []byte {
+	return t.messages
+}
+
+// enqueue queues a message on a session's stream without blocking
+func (t *Streams) enqueue(session *session, message []byte) {
+	select {
+	case session.messages <- message:
+	case <-session.done:
+	default:
+		log.Printf("[HTTP-STREAMS] Session %s buffer full, dropping message", session.id)
+	}
+}
+
+// Handle serves an additional handler at path below the base path, e.g. a
+// status page. Call it before Start.
+func (t *Streams) Handle(path string, handler http.Handler) {
+	t.routes = append(t.routes, route{path: path, handler: handler})
+}
+
+// HandleBrowser serves a handler opened directly in a browser, such as a
+// proxied web app, at path below the base path. Browsers cannot send bearer
+// tokens when following a link, so unlike Handle its requests are not
+// refused without authentication, and the CORS policy of the API endpoints
+// does not apply: the handler does its own access control, with
+// RequestUser telling the authenticated user if any. Call it before Start.
+func (t *Streams) HandleBrowser(path string, handler http.Handler) {
+	t.routes = append(t.routes, route{path: path, handler: handler, browser: true})
+}
+
+// Handler returns the HTTP handler serving the transport's endpoints below
+// the base path
+func (t *Streams) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(t.opts.Endpoint("/mcp"), t.opts.withAuth(http.HandlerFunc(t.handleMCP)))
+	mux.HandleFunc(t.opts.Endpoint("/health"), t.handleHealth)
+	return t.opts.handler(mux, t.routes, "GET, POST, DELETE, OPTIONS")
+}
+
+func (t *Streams) handleMCP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		t.handleStream(w, r)
+	case http.MethodPost:
+		t.handleMessage(w, r)
+	case http.MethodDelete:
+		t.handleDelete(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// lookup returns the session named by the Mcp-Session-Id header, writing an
+// error response when it is missing, unknown or opened by another user
+func (t *Streams) lookup(w http.ResponseWriter, r *http.Request) *session {
+	id := r.Header.Get("Mcp-Session-Id")
+	if id == "" {
+		http.Error(w, "Missing Mcp-Session-Id header", http.StatusBadRequest)
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	session, ok := t.sessions[id]
+	if !ok {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return nil
+	}
+	if session.user != RequestUser(r) {
+		http.Error(w, "Session belongs to another user", http.StatusForbidden)
+		return nil
+	}
+	session.lastSeen = time.Now()
+	return session
+}
+
+// SessionUser returns the authenticated user that opened a session, or ""
+// when the transport does not authenticate
+func (t *Streams) SessionUser(id string) string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if session, ok := t.sessions[id]; ok {
+		return session.user
+	}
+	return ""
+}
+
+// handleStream serves the event stream of a session
+func (t *Streams) handleStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	session := t.lookup(w, r)
+	if session == nil {
+		return
+	}
+
+	t.mu.Lock()
+	session.active = true
+	t.mu.Unlock()
+	defer func() {
+		t.mu.Lock()
+		session.active = false
+		session.lastSeen = time.Now()
+		t.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// Keep nginx from buffering the stream
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if _, err := fmt.Fprintf(w, ": connected\n\n"); err != nil {
+		return
+	}
+	flusher.Flush()
+
+	var heartbeat <-chan time.Time
+	if t.opts.Heartbeat > 0 {
+		ticker := time.NewTicker(t.opts.Heartbeat)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+
+	for {
+		select {
+		case message := <-session.messages:
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", message); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-heartbeat:
+			if _, err := fmt.Fprintf(w, ": heartbeat %d\n\n", time.Now().Unix()); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-session.done:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// handleMessage processes a POSTed JSON-RPC message. An initialize request
+// opens a new session and is answered in the response body; everything else
+// is answered on the session's event stream.
+func (t *Streams) handleMessage(w http.ResponseWriter, r *http.Request) {
+	var message json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	var envelope struct {
+		ID     interface{} `json:"id"`
+		Method string      `json:"method"`
+	}
+	if err := json.Unmarshal(message, &envelope); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if t.handler == nil {
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+
+	if envelope.Method == "initialize" {
+		t.handleInitialize(w, r, message)
+		return
+	}
+
+	// Responses to server-initiated requests carry no method but still need a session
+	session := t.lookup(w, r)
+	if session == nil {
+		return
+	}
+
+	response, err := t.handler(session.id, message)
+	if err != nil {
+		log.Printf("[HTTP-STREAMS] Error processing message: %v", err)
+		response, _ = json.Marshal(map[string]interface{}{
+			"jsonrpc": "2.0",
+			"id":      envelope.ID,
+			"error":   map[string]interface{}{"code": -32603, "message": "Internal error"},
+		})
+	}
+	if response != nil {
+		t.enqueue(session, response)
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// handleInitialize creates a session and returns the initialize response with
+// its ID in the Mcp-Session-Id header. The session exists while initialize is
+// handled so the handler can look up its user.
+func (t *Streams) handleInitialize(w http.ResponseWriter, r *http.Request, message []byte) {
+	id := generateSessionID()
+	t.mu.Lock()
+	t.sessions[id] = &session{
+		id:       id,
+		user:     RequestUser(r),
+		messages: make(chan []byte, 100),
+		done:     make(chan struct{}),
+		lastSeen: time.Now(),
+	}
+	t.mu.Unlock()
+
+	response, err := t.handler(id, message)
+	if err != nil {
+		t.mu.Lock()
+		delete(t.sessions, id)
+		t.mu.Unlock()
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("[HTTP-STREAMS] Session %s opened by %s", id, clientAddr(r))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Mcp-Session-Id", id)
+	if _, err := w.Write(response); err != nil {
+		log.Printf("[HTTP-STREAMS] Failed to write response: %v", err)
+	}
+}
+
+// handleDelete ends a session at the client's request
+func (t *Streams) handleDelete(w http.ResponseWriter, r *http.Request) {
+	session := t.lookup(w, r)
+	if session == nil {
+		return
+	}
+
+	t.mu.Lock()
+	if _, ok := t.sessions[session.id]; ok {
+		delete(t.sessions, session.id)
+		close(session.done)
+	}
+	t.mu.Unlock()
+
+	if t.onClose != nil {
+		t.onClose(session.id)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (t *Streams) handleHealth(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]string{"status": "ok"}); err != nil {
+		log.Printf("[HTTP-STREAMS] Failed to encode health response: %v", err)
+	}
+}
+
+// generateSessionID returns a random session ID
+func generateSessionID() string {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(bytes)
+}
@@ -0,0 +1,250 @@
+package httptransport
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func post(t *testing.T, s *Streams, sessionID, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(body))
+	if sessionID != "" {
+		req.Header.Set("Mcp-Session-Id", sessionID)
+	}
+	rec := httptest.NewRecorder()
+	s.handleMCP(rec, req)
+	return rec
+}
+
+func TestStreamsPassesSessionID(t *testing.T) {
+	s := NewStreams(":0")
+	var seen []string
+	s.SetSessionMessageHandler(func(sessionID string, message []byte) ([]byte, error) {
+		seen = append(seen, sessionID)
+		return []byte(`{"jsonrpc":"2.0","id":1,"result":{}}`), nil
+	})
+	var closed string
+	s.SetSessionClosedHandler(func(sessionID string) { closed = sessionID })
+
+	rec := post(t, s, "", `{"jsonrpc":"2.0","id":1,"method":"initialize"}`)
+	id := rec.Header().Get("Mcp-Session-Id")
+	if rec.Code != http.StatusOK || id == "" {
+		t.Fatalf("Expected a session from initialize, got %d %q", rec.Code, id)
+	}
+
+	if rec := post(t, s, id, `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`); rec.Code != http.StatusAccepted {
+		t.Fatalf("Expected 202 for a session message, got %d", rec.Code)
+	}
+	// Client responses to server requests have no method but must still be accepted
+	if rec := post(t, s, id, `{"jsonrpc":"2.0","id":"devpod-1","result":{}}`); rec.Code != http.StatusAccepted {
+		t.Fatalf("Expected 202 for a client response, got %d", rec.Code)
+	}
+	if len(seen) != 3 || seen[1] != id || seen[2] != id {
+		t.Errorf("Expected every message to carry session %s, got %v", id, seen)
+	}
+	if got := len(s.sessions[id].messages); got != 2 {
+		t.Errorf("Expected 2 queued responses, got %d", got)
+	}
+	if err := s.SendTo(id, []byte(`{"jsonrpc":"2.0","method":"devpod/commandOutput"}`)); err != nil || len(s.sessions[id].messages) != 3 {
+		t.Errorf("Expected the notification to be queued for session %s (%v)", id, err)
+	}
+	if err := s.SendTo("unknown", []byte(`{}`)); err == nil {
+		t.Error("Expected sending to an unknown session to fail")
+	}
+
+	req := httptest.NewRequest(http.MethodDelete, "/mcp", nil)
+	req.Header.Set("Mcp-Session-Id", id)
+	rec = httptest.NewRecorder()
+	s.handleMCP(rec, req)
+	if rec.Code != http.StatusNoContent || closed != id {
+		t.Errorf("Expected session %s to be closed, got %d %q", id, rec.Code, closed)
+	}
+	if rec := post(t, s, id, `{"jsonrpc":"2.0","id":3,"method":"tools/list"}`); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 after the session ended, got %d", rec.Code)
+	}
+}
+
+func TestStreamsCORSAndBasePath(t *testing.T) {
+	s := NewStreamsWithOptions(":0", Options{
+		CORSOrigins: ParseOrigins("https://inspector.example.com/, http://localhost:6274"),
+		BasePath:    "devpod-mcp/",
+	})
+	s.SetSessionMessageHandler(func(sessionID string, message []byte) ([]byte, error) {
+		return []byte(`{"jsonrpc":"2.0","id":1,"result":{}}`), nil
+	})
+	handler := s.Handler()
+
+	serve := func(method, path, origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"initialize"}`))
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve(http.MethodOptions, "/devpod-mcp/mcp", "https://inspector.example.com")
+	if rec.Code != http.StatusOK || rec.Header().Get("Access-Control-Allow-Origin") != "https://inspector.example.com" {
+		t.Errorf("Expected the preflight to allow the origin, got %d %v", rec.Code, rec.Header())
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Headers"); !strings.Contains(got, "Mcp-Session-Id") {
+		t.Errorf("Expected Mcp-Session-Id to be an allowed header, got %q", got)
+	}
+
+	if rec := serve(http.MethodPost, "/devpod-mcp/mcp", "http://localhost:6274"); rec.Code != http.StatusOK || rec.Header().Get("Mcp-Session-Id") == "" {
+		t.Errorf("Expected initialize under the base path to open a session, got %d", rec.Code)
+	}
+	if rec := serve(http.MethodPost, "/devpod-mcp/mcp", "https://evil.example.com"); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected a request from another origin to be refused, got %d", rec.Code)
+	}
+	// Clients that are not browsers send no Origin
+	if rec := serve(http.MethodPost, "/devpod-mcp/mcp", ""); rec.Code != http.StatusOK || rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("Expected a request without origin to pass without CORS headers, got %d", rec.Code)
+	}
+	if rec := serve(http.MethodPost, "/mcp", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected nothing outside the base path, got %d", rec.Code)
+	}
+	if rec := serve(http.MethodGet, "/devpod-mcp/health", ""); rec.Code != http.StatusOK {
+		t.Errorf("Expected health under the base path, got %d", rec.Code)
+	}
+}
+
+func TestStreamsBindSessionsToUsers(t *testing.T) {
+	s := NewStreamsWithOptions(":0", Options{Authenticate: func(r *http.Request) (string, error) {
+		switch r.Header.Get("Authorization") {
+		case "Bearer alice-token":
+			return "alice", nil
+		case "Bearer bob-token":
+			return "bob", nil
+		}
+		return "", errors.New("invalid token")
+	}})
+	s.SetSessionMessageHandler(func(sessionID string, message []byte) ([]byte, error) {
+		return []byte(`{"jsonrpc":"2.0","id":1,"result":{}}`), nil
+	})
+	handler := s.Handler()
+	request := func(token, sessionID, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		if sessionID != "" {
+			req.Header.Set("Mcp-Session-Id", sessionID)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := request("", "", `{"jsonrpc":"2.0","id":1,"method":"initialize"}`); rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") == "" {
+		t.Fatalf("Expected 401 with a challenge without a token, got %d", rec.Code)
+	}
+	rec := request("alice-token", "", `{"jsonrpc":"2.0","id":1,"method":"initialize"}`)
+	id := rec.Header().Get("Mcp-Session-Id")
+	if rec.Code != http.StatusOK || s.SessionUser(id) != "alice" {
+		t.Fatalf("Expected a session of alice, got %d %q", rec.Code, s.SessionUser(id))
+	}
+	if rec := request("bob-token", id, `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for another user's session, got %d", rec.Code)
+	}
+	if rec := request("alice-token", id, `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`); rec.Code != http.StatusAccepted {
+		t.Errorf("Expected 202 for the session's user, got %d", rec.Code)
+	}
+}
+
+func TestStreamsSendToUser(t *testing.T) {
+	s := NewStreamsWithOptions(":0", Options{Authenticate: func(r *http.Request) (string, error) {
+		return strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "), nil
+	}})
+	s.SetSessionMessageHandler(func(sessionID string, message []byte) ([]byte, error) {
+		return []byte(`{"jsonrpc":"2.0","id":1,"result":{}}`), nil
+	})
+	open := func(user string) *session {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"initialize"}`))
+		req.Header.Set("Authorization", "Bearer "+user)
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, req)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		session := s.sessions[rec.Header().Get("Mcp-Session-Id")]
+		if session == nil {
+			t.Fatalf("Expected a session of %s", user)
+		}
+		session.active = true
+		return session
+	}
+	alice, alice2, bob := open("alice"), open("alice"), open("bob")
+
+	if err := s.SendToUser("alice", []byte(`{"jsonrpc":"2.0","method":"ping"}`)); err != nil {
+		t.Fatalf("SendToUser failed: %v", err)
+	}
+	for _, session := range []*session{alice, alice2} {
+		if len(session.messages) != 1 {
+			t.Errorf("Expected the message in alice's session %s, got %d", session.id, len(session.messages))
+		}
+	}
+	if len(bob.messages) != 0 {
+		t.Errorf("Expected nothing in bob's session, got %d", len(bob.messages))
+	}
+}
+
+func TestStreamsExpireIdleSessions(t *testing.T) {
+	s := NewStreamsWithOptions(":0", Options{SessionTTL: time.Minute})
+	s.SetSessionMessageHandler(func(sessionID string, message []byte) ([]byte, error) {
+		return []byte(`{"jsonrpc":"2.0","id":1,"result":{}}`), nil
+	})
+	var closed []string
+	s.SetSessionClosedHandler(func(sessionID string) { closed = append(closed, sessionID) })
+
+	open := func() string {
+		t.Helper()
+		id := post(t, s, "", `{"jsonrpc":"2.0","id":1,"method":"initialize"}`).Header().Get("Mcp-Session-Id")
+		if id == "" {
+			t.Fatal("Expected a session from initialize")
+		}
+		return id
+	}
+	idle, busy, streaming := open(), open(), open()
+	s.mu.Lock()
+	for _, id := range []string{idle, busy, streaming} {
+		s.sessions[id].lastSeen = time.Now().Add(-2 * time.Minute)
+	}
+	s.sessions[streaming].active = true
+	s.mu.Unlock()
+	// A request keeps a session alive
+	if rec := post(t, s, busy, `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`); rec.Code != http.StatusAccepted {
+		t.Fatalf("Expected 202, got %d", rec.Code)
+	}
+
+	s.expireIdle(time.Now())
+	if len(closed) != 1 || closed[0] != idle {
+		t.Errorf("Expected only the idle session to be closed, got %v", closed)
+	}
+	if _, ok := s.sessions[idle]; ok {
+		t.Error("Expected the idle session to be removed")
+	}
+	for _, id := range []string{busy, streaming} {
+		if _, ok := s.sessions[id]; !ok {
+			t.Errorf("Expected session %s to be kept", id)
+		}
+	}
+	if rec := post(t, s, idle, `{"jsonrpc":"2.0","id":3,"method":"tools/list"}`); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an expired session, got %d", rec.Code)
+	}
+
+	// Without a TTL sessions stay until they are ended
+	s.opts.SessionTTL = 0
+	s.mu.Lock()
+	s.sessions[busy].lastSeen = time.Now().Add(-24 * time.Hour)
+	s.mu.Unlock()
+	s.expireIdle(time.Now())
+	if _, ok := s.sessions[busy]; !ok {
+		t.Error("Expected sessions to be kept without a TTL")
+	}
+}
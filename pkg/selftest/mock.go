@@ -0,0 +1,58 @@
+package selftest
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// mockWorkspace is the workspace the mock runner reports
+const mockWorkspace = "selftest"
+
+// mockOutputs are the canned outputs of devpod commands, keyed by their
+// arguments joined by spaces
+var mockOutputs = map[string]string{
+	"version":                       "v0.6.15",
+	"list --output json":            `[{"id":"selftest","uid":"default-se-1a2b3","provider":{"name":"docker"},"ide":{"name":"vscode"},"source":{"gitRepository":"https://github.com/loft-sh/devpod-example-go"},"creationTimestamp":"2024-01-01T00:00:00Z","lastUsed":"2024-01-01T00:00:00Z","context":"default"}]`,
+	"provider list --output json":   `{"docker":{"config":{"name":"docker","version":"v0.0.1","description":"DevPod on Docker"},"default":true,"state":{"initialized":true}}}`,
+	"status selftest --output json": `{"id":"selftest","context":"default","provider":"docker","state":"Running"}`,
+}
+
+// mockRunner answers devpod commands with canned output and records them.
+// Commands without canned output succeed with empty output.
+type mockRunner struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func newMockRunner() *mockRunner {
+	return &mockRunner{}
+}
+
+// Run implements server.Runner
+func (r *mockRunner) Run(ctx context.Context, args []string) ([]byte, []byte, error) {
+	key := strings.Join(args, " ")
+	r.mu.Lock()
+	r.calls = append(r.calls, key)
+	r.mu.Unlock()
+	return []byte(mockOutputs[key]), nil, nil
+}
+
+// count returns the number of commands run so far
+func (r *mockRunner) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.calls)
+}
+
+// ran reports whether command ran after the first from commands
+func (r *mockRunner) ran(command string, from int) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, call := range r.calls[from:] {
+		if call == command {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,413 @@
+// Package selftest runs the DevPod MCP server in-process and exercises its
+// protocol handlers and a safe, read-only subset of its tools through a real
+// MCP client, reporting a pass/fail result per check.
+package selftest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Protobomb/mcp-server-devpod/pkg/server"
+	"github.com/protobomb/mcp-server-framework/pkg/client"
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+// Check results
+const (
+	Pass = "pass"
+	Fail = "fail"
+	Skip = "skip"
+)
+
+// coreTools must be advertised by every build of the server
+var coreTools = []string{
+	"devpod_listWorkspaces",
+	"devpod_createWorkspace",
+	"devpod_startWorkspace",
+	"devpod_stopWorkspace",
+	"devpod_deleteWorkspace",
+	"devpod_status",
+	"devpod_ssh",
+	"devpod_listProviders",
+	"devpod_addProvider",
+	"devpod_version",
+}
+
+// Options configures a self-test run
+type Options struct {
+	// Live runs the tools against the devpod binary on PATH instead of
+	// canned output. Only tools that do not change workspaces are called.
+	Live bool
+	// Workspace is the workspace devpod_status is called for. Live runs
+	// skip that check without one; mock runs use a canned workspace.
+	Workspace string
+	// Version is reported by the server in the initialize response
+	Version string
+	// Timeout bounds each call (default: 30s)
+	Timeout time.Duration
+}
+
+// Result is the outcome of one check
+type Result struct {
+	Check      string `json:"check"`
+	Status     string `json:"status"`
+	DurationMs int64  `json:"durationMs"`
+	Detail     string `json:"detail,omitempty"`
+}
+
+// Report collects the results of a run
+type Report struct {
+	// Mode is mock or live
+	Mode    string   `json:"mode"`
+	Results []Result `json:"results"`
+	Passed  int      `json:"passed"`
+	Failed  int      `json:"failed"`
+	Skipped int      `json:"skipped"`
+}
+
+// OK reports whether no check failed
+func (r *Report) OK() bool {
+	return r.Failed == 0
+}
+
+// WriteText writes the results as a table followed by a summary line
+func (r *Report) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CHECK\tRESULT\tTIME\tDETAIL")
+	for _, result := range r.Results {
+		fmt.Fprintf(tw, "%s\t%s\t%dms\t%s\n", result.Check, result.Status, result.DurationMs, result.Detail)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "\n%d checks: %d passed, %d failed, %d skipped (%s devpod)\n",
+		len(r.Results), r.Passed, r.Failed, r.Skipped, r.Mode)
+	return err
+}
+
+func (r *Report) add(result Result) {
+	switch result.Status {
+	case Pass:
+		r.Passed++
+	case Fail:
+		r.Failed++
+	case Skip:
+		r.Skipped++
+	}
+	r.Results = append(r.Results, result)
+}
+
+// errSkipped marks a check that could not run
+type errSkipped string
+
+func (e errSkipped) Error() string { return string(e) }
+
+// Run starts a server on an in-process transport with a temporary data
+// directory, runs the checks and stops it again. The error is only set when
+// the server could not be started.
+func Run(ctx context.Context, opts Options) (*Report, error) {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 30 * time.Second
+	}
+
+	// Never touch the state, secrets or audit log of an installed server
+	dataDir, err := os.MkdirTemp("", "devpod-mcp-selftest-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a data directory: %w", err)
+	}
+	defer os.RemoveAll(dataDir)
+
+	report := &Report{Mode: "mock"}
+	var runner server.Runner
+	var mock *mockRunner
+	if opts.Live {
+		report.Mode = "live"
+	} else {
+		mock = newMockRunner()
+		runner = mock
+		if opts.Workspace == "" {
+			opts.Workspace = mockWorkspace
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	p := newPipe()
+	srv := server.New(p, server.Options{
+		Version: opts.Version,
+		Runner:  runner,
+		DataDir: dataDir,
+	})
+	if err := srv.Start(ctx); err != nil {
+		return nil, fmt.Errorf("failed to start the server: %w", err)
+	}
+	defer srv.Stop()
+
+	c := client.NewClient(p.client())
+	if err := c.Start(ctx); err != nil {
+		return nil, fmt.Errorf("failed to start the client: %w", err)
+	}
+	defer c.Close()
+
+	t := &tester{ctx: ctx, client: c, timeout: opts.Timeout, mock: mock, report: report}
+	t.check("initialize", t.initialize)
+	t.check("tools/list", t.listTools)
+	t.check("resources/list", func() (string, error) { return t.method("resources/list") })
+	t.check("prompts/list", func() (string, error) { return t.method("prompts/list") })
+
+	for _, tc := range toolChecks(opts.Workspace) {
+		tc := tc
+		t.check(tc.tool, func() (string, error) { return t.callTool(tc) })
+	}
+
+	t.check("unknown tool rejected", func() (string, error) {
+		return t.expectInvalidParams("devpod_doesNotExist", map[string]interface{}{})
+	})
+	t.check("missing argument rejected", func() (string, error) {
+		return t.expectInvalidParams("devpod_status", map[string]interface{}{})
+	})
+	return report, nil
+}
+
+// toolCheck is a call of a read-only tool
+type toolCheck struct {
+	tool string
+	args map[string]interface{}
+	// command is the devpod command the mock runner must receive
+	command string
+	// skip explains why the check cannot run
+	skip string
+}
+
+// toolChecks lists the tools called by a run. None of them creates, changes
+// or deletes workspaces, providers or secrets.
+func toolChecks(workspace string) []toolCheck {
+	status := toolCheck{
+		tool:    "devpod_status",
+		args:    map[string]interface{}{"name": workspace},
+		command: "status " + workspace + " --output json",
+	}
+	if workspace == "" {
+		status.skip = "no workspace given"
+	}
+	return []toolCheck{
+		{tool: "devpod_version"},
+		{tool: "devpod_listWorkspaces", command: "list --output json"},
+		{tool: "devpod_listProviders", command: "provider list --output json"},
+		status,
+		{tool: "devpod_listEnvironments"},
+		{tool: "devpod_listPrebuilds"},
+		{tool: "devpod_listSchedules"},
+		{tool: "devpod_listSecrets"},
+		{tool: "devpod_setDefaults"},
+		{tool: "devpod_serverEvents"},
+	}
+}
+
+// tester runs checks against a connected client
+type tester struct {
+	ctx     context.Context
+	client  *client.Client
+	timeout time.Duration
+	mock    *mockRunner
+	report  *Report
+}
+
+// check runs fn and records its result
+func (t *tester) check(name string, fn func() (string, error)) {
+	start := time.Now()
+	detail, err := fn()
+	result := Result{Check: name, Status: Pass, Detail: detail}
+	if skipped, ok := err.(errSkipped); ok {
+		result.Status = Skip
+		result.Detail = string(skipped)
+	} else if err != nil {
+		result.Status = Fail
+		result.Detail = err.Error()
+	}
+	result.DurationMs = time.Since(start).Milliseconds()
+	t.report.add(result)
+}
+
+// call sends a request and returns its result, or the error the server
+// answered with
+func (t *tester) call(method string, params interface{}) (json.RawMessage, *mcp.RPCError, error) {
+	ctx, cancel := context.WithTimeout(t.ctx, t.timeout)
+	defer cancel()
+	response, err := t.client.Call(ctx, method, params)
+	if err != nil {
+		return nil, nil, err
+	}
+	if response == nil {
+		return nil, nil, fmt.Errorf("connection closed")
+	}
+	if response.Error != nil {
+		return nil, response.Error, nil
+	}
+	result, err := json.Marshal(response.Result)
+	return result, nil, err
+}
+
+// method calls a protocol method without parameters
+func (t *tester) method(method string) (string, error) {
+	_, rpcErr, err := t.call(method, nil)
+	if err != nil {
+		return "", err
+	}
+	if rpcErr != nil {
+		return "", fmt.Errorf("%s (code %d)", rpcErr.Message, rpcErr.Code)
+	}
+	return "", nil
+}
+
+func (t *tester) initialize() (string, error) {
+	raw, rpcErr, err := t.call("initialize", mcp.InitializeParams{
+		ProtocolVersion: "2024-11-05",
+		ClientInfo:      mcp.ServerInfo{Name: "mcp-server-devpod-selftest", Version: "1.0.0"},
+	})
+	if err != nil {
+		return "", err
+	}
+	if rpcErr != nil {
+		return "", fmt.Errorf("%s (code %d)", rpcErr.Message, rpcErr.Code)
+	}
+
+	var result struct {
+		ProtocolVersion string                     `json:"protocolVersion"`
+		Capabilities    map[string]json.RawMessage `json:"capabilities"`
+		ServerInfo      struct {
+			Name          string `json:"name"`
+			Version       string `json:"version"`
+			DevPodVersion string `json:"devpodVersion"`
+		} `json:"serverInfo"`
+	}
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return "", fmt.Errorf("invalid result: %w", err)
+	}
+	if result.ProtocolVersion == "" || result.ServerInfo.Name == "" {
+		return "", fmt.Errorf("missing protocol version or server name: %s", raw)
+	}
+	if _, ok := result.Capabilities["tools"]; !ok {
+		return "", fmt.Errorf("tools capability not advertised")
+	}
+	if err := t.client.Notify("notifications/initialized", nil); err != nil {
+		return "", fmt.Errorf("failed to send initialized: %w", err)
+	}
+
+	devpod := result.ServerInfo.DevPodVersion
+	if devpod == "" {
+		devpod = "not available"
+	}
+	return fmt.Sprintf("%s %s, protocol %s, devpod %s", result.ServerInfo.Name, result.ServerInfo.Version, result.ProtocolVersion, devpod), nil
+}
+
+func (t *tester) listTools() (string, error) {
+	raw, rpcErr, err := t.call("tools/list", nil)
+	if err != nil {
+		return "", err
+	}
+	if rpcErr != nil {
+		return "", fmt.Errorf("%s (code %d)", rpcErr.Message, rpcErr.Code)
+	}
+
+	var result struct {
+		Tools []mcp.Tool `json:"tools"`
+	}
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return "", fmt.Errorf("invalid result: %w", err)
+	}
+	seen := make(map[string]bool, len(result.Tools))
+	for _, tool := range result.Tools {
+		if tool.Name == "" || tool.Description == "" {
+			return "", fmt.Errorf("tool %q has no name or description", tool.Name)
+		}
+		if tool.InputSchema["type"] != "object" {
+			return "", fmt.Errorf("tool %s has no object input schema", tool.Name)
+		}
+		if seen[tool.Name] {
+			return "", fmt.Errorf("tool %s is listed twice", tool.Name)
+		}
+		seen[tool.Name] = true
+	}
+	var missing []string
+	for _, name := range coreTools {
+		if !seen[name] {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("missing tools: %s", strings.Join(missing, ", "))
+	}
+	return fmt.Sprintf("%d tools", len(result.Tools)), nil
+}
+
+// callTool calls a tool and checks the shape of its result and, against
+// the mock runner, the devpod command it ran
+func (t *tester) callTool(tc toolCheck) (string, error) {
+	if tc.skip != "" {
+		return "", errSkipped(tc.skip)
+	}
+	args := tc.args
+	if args == nil {
+		args = map[string]interface{}{}
+	}
+	var before int
+	if t.mock != nil {
+		before = t.mock.count()
+	}
+
+	raw, rpcErr, err := t.call("tools/call", mcp.CallToolParams{Name: tc.tool, Arguments: args})
+	if err != nil {
+		return "", err
+	}
+	if rpcErr != nil {
+		return "", fmt.Errorf("%s (code %d)", rpcErr.Message, rpcErr.Code)
+	}
+
+	var result mcp.CallToolResult
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return "", fmt.Errorf("invalid result: %w", err)
+	}
+	if result.IsError {
+		return "", fmt.Errorf("tool reported an error: %s", firstText(result))
+	}
+	if len(result.Content) == 0 || result.Content[0].Type != "text" || result.Content[0].Text == "" {
+		return "", fmt.Errorf("expected text content, got %s", raw)
+	}
+	if t.mock != nil && tc.command != "" && !t.mock.ran(tc.command, before) {
+		return "", fmt.Errorf("expected devpod %s to run", tc.command)
+	}
+	return fmt.Sprintf("%d bytes", len(result.Content[0].Text)), nil
+}
+
+// expectInvalidParams calls a tool that must be rejected before it runs
+func (t *tester) expectInvalidParams(tool string, args map[string]interface{}) (string, error) {
+	_, rpcErr, err := t.call("tools/call", mcp.CallToolParams{Name: tool, Arguments: args})
+	if err != nil {
+		return "", err
+	}
+	if rpcErr == nil {
+		return "", fmt.Errorf("expected %s to be rejected", tool)
+	}
+	if rpcErr.Code != mcp.InvalidParams {
+		return "", fmt.Errorf("expected code %d, got %d: %s", mcp.InvalidParams, rpcErr.Code, rpcErr.Message)
+	}
+	return rpcErr.Message, nil
+}
+
+// firstText returns the first text content of a result
+func firstText(result mcp.CallToolResult) string {
+	for _, content := range result.Content {
+		if content.Text != "" {
+			return content.Text
+		}
+	}
+	return ""
+}
@@ -0,0 +1,40 @@
+package selftest
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunAgainstMock(t *testing.T) {
+	report, err := Run(context.Background(), Options{Version: "test", Timeout: 10 * time.Second})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !report.OK() || report.Skipped != 0 {
+		var buf bytes.Buffer
+		report.WriteText(&buf)
+		t.Fatalf("Expected every check to pass:\n%s", buf.String())
+	}
+	if report.Mode != "mock" || report.Passed != len(report.Results) || report.Passed < 10 {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+
+	var buf bytes.Buffer
+	if err := report.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "devpod_listWorkspaces") || !strings.Contains(buf.String(), "0 failed") {
+		t.Errorf("Unexpected text report:\n%s", buf.String())
+	}
+}
+
+func TestLiveRunSkipsStatusWithoutWorkspace(t *testing.T) {
+	for _, tc := range toolChecks("") {
+		if tc.tool == "devpod_status" && tc.skip == "" {
+			t.Error("Expected devpod_status to be skipped without a workspace")
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package selftest
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+// pipe connects the server and the client in-process. It is the server's
+// mcp.Transport; its client side is returned by client().
+type pipe struct {
+	toServer chan []byte
+	toClient chan []byte
+	done     chan struct{}
+	once     sync.Once
+}
+
+func newPipe() *pipe {
+	return &pipe{
+		toServer: make(chan []byte, 16),
+		toClient: make(chan []byte, 16),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start implements mcp.Transport
+func (p *pipe) Start(ctx context.Context) error {
+	return nil
+}
+
+// Stop implements mcp.Transport
+func (p *pipe) Stop() error {
+	p.once.Do(func() { close(p.done) })
+	return nil
+}
+
+// Close implements mcp.Transport
+func (p *pipe) Close() error {
+	return p.Stop()
+}
+
+// Send delivers a server message to the client
+func (p *pipe) Send(message []byte) error {
+	select {
+	case p.toClient <- append([]byte(nil), message...):
+		return nil
+	case <-p.done:
+		return io.ErrClosedPipe
+	}
+}
+
+// Receive returns the messages the client sent to the server
+func (p *pipe) Receive() <-chan []byte {
+	return p.toServer
+}
+
+// client returns the client side of the pipe
+func (p *pipe) client() *pipeClient {
+	return &pipeClient{p: p}
+}
+
+// pipeClient implements client.Transport on a pipe
+type pipeClient struct {
+	p *pipe
+}
+
+// Send delivers a client request to the server
+func (c *pipeClient) Send(request *mcp.JSONRPCRequest) error {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	select {
+	case c.p.toServer <- data:
+		return nil
+	case <-c.p.done:
+		return io.ErrClosedPipe
+	}
+}
+
+// Receive returns the next response of the server. Notifications and
+// server-initiated requests are skipped. Numeric IDs decode as float64 but
+// the client matches them against the int IDs it sent, so they are
+// converted back.
+func (c *pipeClient) Receive() (*mcp.JSONRPCResponse, error) {
+	for {
+		select {
+		case message := <-c.p.toClient:
+			var response mcp.JSONRPCResponse
+			if err := json.Unmarshal(message, &response); err != nil || response.ID == nil {
+				continue
+			}
+			var method struct {
+				Method string `json:"method"`
+			}
+			if json.Unmarshal(message, &method) == nil && method.Method != "" {
+				continue
+			}
+			if id, ok := response.ID.(float64); ok {
+				response.ID = int(id)
+			}
+			return &response, nil
+		case <-c.p.done:
+			return nil, io.EOF
+		}
+	}
+}
+
+// Close closes both sides of the pipe
+func (c *pipeClient) Close() error {
+	return c.p.Stop()
+}
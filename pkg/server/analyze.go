@@ -0,0 +1,458 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+// analyzeCloneTimeout bounds the shallow clone of a repository to analyze
+const analyzeCloneTimeout = 2 * time.Minute
+
+// maxAnalyzedFiles bounds the files looked at when analyzing a source
+const maxAnalyzedFiles = 5000
+
+// maxAnalyzeDepth is how deep below the source root files are looked at
+const maxAnalyzeDepth = 4
+
+// skippedDirs are directories of dependencies and build output that say
+// nothing about the project itself
+var skippedDirs = map[string]bool{
+	".git": true, "node_modules": true, "vendor": true, "target": true, "dist": true, "build": true,
+	".venv": true, "venv": true, "__pycache__": true, ".gradle": true, ".idea": true, ".vscode": true,
+}
+
+// languageExtensions maps source file extensions to languages
+var languageExtensions = map[string]string{
+	".go": "Go", ".js": "JavaScript", ".jsx": "JavaScript", ".mjs": "JavaScript", ".cjs": "JavaScript",
+	".ts": "TypeScript", ".tsx": "TypeScript", ".py": "Python", ".rs": "Rust", ".java": "Java",
+	".kt": "Kotlin", ".kts": "Kotlin", ".scala": "Scala", ".rb": "Ruby", ".php": "PHP", ".cs": "C#",
+	".fs": "F#", ".c": "C", ".h": "C", ".cpp": "C++", ".cc": "C++", ".hpp": "C++", ".swift": "Swift",
+	".ex": "Elixir", ".exs": "Elixir", ".dart": "Dart", ".lua": "Lua", ".sh": "Shell", ".r": "R",
+	".R": "R", ".jl": "Julia", ".zig": "Zig", ".hs": "Haskell", ".clj": "Clojure", ".erl": "Erlang",
+}
+
+// packageManagerFiles maps the files package managers keep at a project
+// root to the package manager and its language
+var packageManagerFiles = map[string][2]string{
+	"go.mod":            {"go modules", "Go"},
+	"package-lock.json": {"npm", "JavaScript"},
+	"yarn.lock":         {"yarn", "JavaScript"},
+	"pnpm-lock.yaml":    {"pnpm", "JavaScript"},
+	"bun.lockb":         {"bun", "JavaScript"},
+	"requirements.txt":  {"pip", "Python"},
+	"Pipfile":           {"pipenv", "Python"},
+	"poetry.lock":       {"poetry", "Python"},
+	"uv.lock":           {"uv", "Python"},
+	"Cargo.toml":        {"cargo", "Rust"},
+	"pom.xml":           {"maven", "Java"},
+	"build.gradle":      {"gradle", "Java"},
+	"build.gradle.kts":  {"gradle", "Kotlin"},
+	"build.sbt":         {"sbt", "Scala"},
+	"Gemfile":           {"bundler", "Ruby"},
+	"composer.json":     {"composer", "PHP"},
+	"mix.exs":           {"mix", "Elixir"},
+	"pubspec.yaml":      {"pub", "Dart"},
+	"Package.swift":     {"swift package manager", "Swift"},
+	"CMakeLists.txt":    {"cmake", "C++"},
+}
+
+// languageImages are the devcontainer base images recommended per language
+var languageImages = map[string]string{
+	"Go":         "mcr.microsoft.com/devcontainers/go:1",
+	"JavaScript": "mcr.microsoft.com/devcontainers/javascript-node:20",
+	"TypeScript": "mcr.microsoft.com/devcontainers/typescript-node:20",
+	"Python":     "mcr.microsoft.com/devcontainers/python:3",
+	"Rust":       "mcr.microsoft.com/devcontainers/rust:1",
+	"Java":       "mcr.microsoft.com/devcontainers/java:21",
+	"Kotlin":     "mcr.microsoft.com/devcontainers/java:21",
+	"Scala":      "mcr.microsoft.com/devcontainers/java:21",
+	"Ruby":       "mcr.microsoft.com/devcontainers/ruby:3",
+	"PHP":        "mcr.microsoft.com/devcontainers/php:8",
+	"C#":         "mcr.microsoft.com/devcontainers/dotnet:8.0",
+	"F#":         "mcr.microsoft.com/devcontainers/dotnet:8.0",
+	"C":          "mcr.microsoft.com/devcontainers/cpp:1",
+	"C++":        "mcr.microsoft.com/devcontainers/cpp:1",
+}
+
+// languageFeatures are the devcontainer features that add a language to
+// another language's base image
+var languageFeatures = map[string]string{
+	"Go":         "ghcr.io/devcontainers/features/go:1",
+	"JavaScript": "ghcr.io/devcontainers/features/node:1",
+	"TypeScript": "ghcr.io/devcontainers/features/node:1",
+	"Python":     "ghcr.io/devcontainers/features/python:1",
+	"Rust":       "ghcr.io/devcontainers/features/rust:1",
+	"Java":       "ghcr.io/devcontainers/features/java:1",
+	"Kotlin":     "ghcr.io/devcontainers/features/java:1",
+	"Ruby":       "ghcr.io/devcontainers/features/ruby:1",
+	"PHP":        "ghcr.io/devcontainers/features/php:1",
+	"C#":         "ghcr.io/devcontainers/features/dotnet:2",
+}
+
+// languageIDEs are the JetBrains IDEs suited to a language, offered next to
+// VS Code
+var languageIDEs = map[string]string{
+	"Go": "goland", "Python": "pycharm", "Java": "intellij", "Kotlin": "intellij", "Scala": "intellij",
+	"Rust": "rustrover", "PHP": "phpstorm", "Ruby": "rubymine", "C#": "rider", "F#": "rider",
+	"JavaScript": "webstorm", "TypeScript": "webstorm", "C": "clion", "C++": "clion",
+}
+
+// sourceLanguage is a language found in a source with its file count
+type sourceLanguage struct {
+	Name  string `json:"name"`
+	Files int    `json:"files"`
+}
+
+// sourceAnalysis describes the toolchain of a source and the workspace
+// configuration recommended for it
+type sourceAnalysis struct {
+	Source          string           `json:"source"`
+	Languages       []sourceLanguage `json:"languages"`
+	PackageManagers []string         `json:"packageManagers"`
+	// Devcontainers lists the devcontainer.json files, relative to the root
+	Devcontainers []string `json:"devcontainers"`
+	Dockerfiles   []string `json:"dockerfiles"`
+	ComposeFiles  []string `json:"composeFiles"`
+	// ExposedPorts are the ports the Dockerfiles EXPOSE
+	ExposedPorts []int `json:"exposedPorts,omitempty"`
+	// Truncated is set when the source had more files than were looked at
+	Truncated      bool                   `json:"truncated,omitempty"`
+	Recommendation analysisRecommendation `json:"recommendation"`
+}
+
+// analysisRecommendation is the suggested workspace configuration
+type analysisRecommendation struct {
+	Provider string `json:"provider"`
+	IDE      string `json:"ide"`
+	// AlternativeIDEs are IDEs suited to the primary language
+	AlternativeIDEs []string `json:"alternativeIdes,omitempty"`
+	// DevcontainerPath is the existing devcontainer.json to use
+	DevcontainerPath string `json:"devcontainerPath,omitempty"`
+	// Image and Features are suggested for devpod_composeDevcontainer when
+	// the source has no devcontainer.json
+	Image        string   `json:"image,omitempty"`
+	Features     []string `json:"features,omitempty"`
+	ForwardPorts []int    `json:"forwardPorts,omitempty"`
+	Reasons      []string `json:"reasons"`
+}
+
+// analyzeDir walks a source directory and detects its languages, package
+// managers and container configuration
+func analyzeDir(root string) (*sourceAnalysis, error) {
+	analysis := &sourceAnalysis{
+		Languages:       []sourceLanguage{},
+		PackageManagers: []string{},
+		Devcontainers:   []string{},
+		Dockerfiles:     []string{},
+		ComposeFiles:    []string{},
+	}
+	languages := make(map[string]int)
+	managers := make(map[string]bool)
+	ports := make(map[int]bool)
+	files := 0
+
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			// Unreadable entries are skipped rather than failing the analysis
+			return nil
+		}
+		rel, _ := filepath.Rel(root, path)
+		depth := strings.Count(rel, string(filepath.Separator))
+		if entry.IsDir() {
+			if path != root && (skippedDirs[entry.Name()] || depth >= maxAnalyzeDepth) && entry.Name() != ".devcontainer" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if files >= maxAnalyzedFiles {
+			analysis.Truncated = true
+			return filepath.SkipAll
+		}
+		files++
+
+		name := entry.Name()
+		rel = filepath.ToSlash(rel)
+		if language, ok := languageExtensions[filepath.Ext(name)]; ok {
+			languages[language]++
+		}
+		if manager, ok := packageManagerFiles[name]; ok {
+			managers[manager[0]] = true
+			if languages[manager[1]] == 0 {
+				// Count the manifest so projects without sources at this depth still show up
+				languages[manager[1]] = 0
+			}
+		}
+		switch {
+		case name == "devcontainer.json" && strings.Contains(rel, ".devcontainer"), rel == ".devcontainer.json":
+			analysis.Devcontainers = append(analysis.Devcontainers, rel)
+		case name == "Dockerfile" || strings.HasPrefix(name, "Dockerfile.") || strings.HasSuffix(name, ".Dockerfile"):
+			analysis.Dockerfiles = append(analysis.Dockerfiles, rel)
+			for _, port := range exposedPorts(path) {
+				ports[port] = true
+			}
+		case name == "docker-compose.yml" || name == "docker-compose.yaml" || name == "compose.yml" || name == "compose.yaml":
+			analysis.ComposeFiles = append(analysis.ComposeFiles, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for name, count := range languages {
+		analysis.Languages = append(analysis.Languages, sourceLanguage{Name: name, Files: count})
+	}
+	sort.Slice(analysis.Languages, func(i, j int) bool {
+		if analysis.Languages[i].Files != analysis.Languages[j].Files {
+			return analysis.Languages[i].Files > analysis.Languages[j].Files
+		}
+		return analysis.Languages[i].Name < analysis.Languages[j].Name
+	})
+	for manager := range managers {
+		analysis.PackageManagers = append(analysis.PackageManagers, manager)
+	}
+	sort.Strings(analysis.PackageManagers)
+	for port := range ports {
+		analysis.ExposedPorts = append(analysis.ExposedPorts, port)
+	}
+	sort.Ints(analysis.ExposedPorts)
+	sort.Strings(analysis.Devcontainers)
+	sort.Strings(analysis.Dockerfiles)
+	sort.Strings(analysis.ComposeFiles)
+	return analysis, nil
+}
+
+// exposedPorts returns the ports a Dockerfile EXPOSEs
+func exposedPorts(path string) []int {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var ports []int
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || !strings.EqualFold(fields[0], "EXPOSE") {
+			continue
+		}
+		for _, field := range fields[1:] {
+			port, err := strconv.Atoi(strings.SplitN(field, "/", 2)[0])
+			if err == nil && port > 0 && port <= 65535 {
+				ports = append(ports, port)
+			}
+		}
+	}
+	return ports
+}
+
+// recommend fills in the recommended workspace configuration. provider is
+// the provider to suggest.
+func (a *sourceAnalysis) recommend(provider string) {
+	rec := analysisRecommendation{Provider: provider, IDE: "vscode", Reasons: []string{}}
+	if provider != "" {
+		rec.Reasons = append(rec.Reasons, fmt.Sprintf("provider %s is the session default or the configured provider", provider))
+	}
+
+	primary := ""
+	for _, language := range a.Languages {
+		if languageImages[language.Name] != "" {
+			primary = language.Name
+			break
+		}
+	}
+	if ide := languageIDEs[primary]; ide != "" {
+		rec.AlternativeIDEs = []string{ide}
+	}
+
+	switch {
+	case len(a.Devcontainers) > 0:
+		rec.DevcontainerPath = a.Devcontainers[0]
+		rec.Reasons = append(rec.Reasons, fmt.Sprintf("the source has a devcontainer configuration at %s", rec.DevcontainerPath))
+	case primary != "":
+		rec.Image = languageImages[primary]
+		rec.Reasons = append(rec.Reasons, fmt.Sprintf("%s is the main language", primary))
+		seen := map[string]bool{languageFeatures[primary]: true}
+		for _, language := range a.Languages {
+			feature := languageFeatures[language.Name]
+			if feature != "" && !seen[feature] {
+				seen[feature] = true
+				rec.Features = append(rec.Features, feature)
+				rec.Reasons = append(rec.Reasons, fmt.Sprintf("%s is also used", language.Name))
+			}
+		}
+	default:
+		rec.Image = defaultDevcontainerImage
+		rec.Reasons = append(rec.Reasons, "no language with a dedicated devcontainer image was detected")
+	}
+	if rec.DevcontainerPath == "" && (len(a.Dockerfiles) > 0 || len(a.ComposeFiles) > 0) {
+		rec.Reasons = append(rec.Reasons, "the Dockerfiles and compose files are meant for deployment and are not used for the workspace")
+		if len(a.ComposeFiles) > 0 {
+			rec.Features = append(rec.Features, "ghcr.io/devcontainers/features/docker-in-docker:2")
+			rec.Reasons = append(rec.Reasons, "docker-in-docker runs the compose files inside the workspace")
+		}
+	}
+	if rec.DevcontainerPath == "" {
+		rec.ForwardPorts = a.ExposedPorts
+	}
+	a.Recommendation = rec
+}
+
+// cloneForAnalysis shallowly clones a git source into a temporary directory
+// and returns it with a function removing it. A branch given as repo@branch
+// is checked out; commits are not, as shallow clones only hold branch heads.
+func cloneForAnalysis(ctx context.Context, source string) (string, func(), []string, error) {
+	repository, ref := source, ""
+	if i := strings.LastIndex(source, "@"); i > strings.LastIndex(source, "/") && i > strings.LastIndex(source, ":") {
+		repository, ref = source[:i], source[i+1:]
+	}
+	var warnings []string
+	if strings.HasPrefix(ref, "sha256:") || strings.HasPrefix(ref, "pull/") {
+		warnings = append(warnings, fmt.Sprintf("analyzed the default branch instead of %s", ref))
+		ref = ""
+	}
+	if !strings.Contains(repository, "://") && !strings.HasPrefix(repository, "git@") {
+		repository = "https://" + repository
+	}
+
+	dir, err := os.MkdirTemp("", "devpod-analyze-")
+	if err != nil {
+		return "", nil, nil, err
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+
+	ctx, cancel := context.WithTimeout(ctx, analyzeCloneTimeout)
+	defer cancel()
+	args := []string{"clone", "--depth", "1", "--single-branch", "--no-tags"}
+	if ref != "" {
+		args = append(args, "--branch", ref)
+	}
+	cmd := exec.CommandContext(ctx, "git", append(args, "--", repository, dir)...)
+	// Never wait for credentials on a terminal nobody watches
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		cleanup()
+		return "", nil, nil, fmt.Errorf("failed to clone %s: %v: %s", repository, err, strings.TrimSpace(string(output)))
+	}
+	return dir, cleanup, warnings, nil
+}
+
+// recommendedProvider returns the session's default provider, else docker
+// when it is configured, else the first configured provider, else docker
+func (s *Server) recommendedProvider(ctx context.Context) string {
+	if provider := s.session(ctx).Provider; provider != "" {
+		return provider
+	}
+	output, err := s.output(ctx, []string{"provider", "list", "--output", "json"})
+	var providers map[string]DevPodProvider
+	if err != nil || json.Unmarshal(output, &providers) != nil || len(providers) == 0 {
+		return "docker"
+	}
+	if _, ok := providers["docker"]; ok {
+		return "docker"
+	}
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names[0]
+}
+
+// registerAnalyzeTools registers the tool that analyzes a source before
+// creating a workspace from it
+func (s *Server) registerAnalyzeTools() {
+	// Analyze source
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_analyzeSource",
+		Description: "Detect the languages, package managers and existing devcontainer, Dockerfile and compose files of a git repository (shallow clone) or local folder, and recommend the provider, IDE, devcontainer path or image and features to create a workspace with",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"source": map[string]interface{}{
+					"type":        "string",
+					"description": "The git repository, optionally with @branch, or local path to analyze",
+				},
+			},
+			"required": []string{"source"},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var analyzeParams struct {
+			Source string `json:"source"`
+		}
+
+		if err := json.Unmarshal(params, &analyzeParams); err != nil {
+			return nil, mcp.NewInvalidParamsError("Invalid analyze source parameters")
+		}
+
+		if analyzeParams.Source == "" {
+			return nil, mcp.NewInvalidParamsError("Source is required")
+		}
+
+		start := time.Now()
+		var dir string
+		var warnings []string
+		sourceType, source := classifySource(analyzeParams.Source)
+		if s.inLocalRoot(ctx, analyzeParams.Source) {
+			sourceType = sourceLocal
+		}
+		switch sourceType {
+		case sourceLocal:
+			resolved, err := s.resolveLocalSource(ctx, source)
+			if err != nil {
+				return nil, mcp.NewInvalidParamsError(err.Error())
+			}
+			dir = resolved
+		case sourceGit:
+			cloned, cleanup, cloneWarnings, err := cloneForAnalysis(ctx, source)
+			if err != nil {
+				return nil, err
+			}
+			defer cleanup()
+			dir, warnings = cloned, cloneWarnings
+		default:
+			return nil, mcp.NewInvalidParamsError(fmt.Sprintf("%s is an image; only git repositories and local folders can be analyzed", analyzeParams.Source))
+		}
+
+		analysis, err := analyzeDir(dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to analyze %s: %w", analyzeParams.Source, err)
+		}
+		analysis.Source = analyzeParams.Source
+		analysis.recommend(s.recommendedProvider(ctx))
+
+		message := "No languages detected"
+		if len(analysis.Languages) > 0 {
+			message = fmt.Sprintf("Detected %s", analysis.Languages[0].Name)
+		}
+		if analysis.Recommendation.DevcontainerPath != "" {
+			message += fmt.Sprintf("; create the workspace with devcontainerPath %s", analysis.Recommendation.DevcontainerPath)
+		} else {
+			message += fmt.Sprintf("; no devcontainer.json, compose one with image %s", analysis.Recommendation.Image)
+		}
+
+		result := map[string]interface{}{
+			"sourceType": sourceType,
+			"analysis":   analysis,
+			"message":    message,
+			"durationMs": durationMs(start),
+		}
+		if len(warnings) > 0 {
+			result["warnings"] = warnings
+		}
+		return result, nil
+	})
+}
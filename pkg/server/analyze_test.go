@@ -0,0 +1,111 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeFiles creates files with their content below dir
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestAnalyzeDir(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"go.mod":                     "module example.com/api\n",
+		"main.go":                    "package main\n",
+		"internal/store/store.go":    "package store\n",
+		"web/package.json":           "{}",
+		"web/package-lock.json":      "{}",
+		"web/src/app.ts":             "",
+		"node_modules/left/index.js": "",
+		"Dockerfile":                 "FROM golang:1.22\nEXPOSE 8080 9090/tcp\n",
+		"docker-compose.yml":         "services: {}\n",
+	})
+
+	analysis, err := analyzeDir(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if want := []sourceLanguage{{"Go", 2}, {"TypeScript", 1}, {"JavaScript", 0}}; !reflect.DeepEqual(analysis.Languages, want) {
+		t.Errorf("Languages = %v, want %v", analysis.Languages, want)
+	}
+	if want := []string{"go modules", "npm"}; !reflect.DeepEqual(analysis.PackageManagers, want) {
+		t.Errorf("PackageManagers = %v, want %v", analysis.PackageManagers, want)
+	}
+	if !reflect.DeepEqual(analysis.ExposedPorts, []int{8080, 9090}) || len(analysis.Dockerfiles) != 1 || len(analysis.ComposeFiles) != 1 {
+		t.Errorf("Unexpected container files: %+v", analysis)
+	}
+
+	analysis.recommend("docker")
+	rec := analysis.Recommendation
+	if rec.Image != "mcr.microsoft.com/devcontainers/go:1" || rec.DevcontainerPath != "" || !reflect.DeepEqual(rec.AlternativeIDEs, []string{"goland"}) {
+		t.Errorf("Unexpected recommendation: %+v", rec)
+	}
+	if want := []string{"ghcr.io/devcontainers/features/node:1", "ghcr.io/devcontainers/features/docker-in-docker:2"}; !reflect.DeepEqual(rec.Features, want) {
+		t.Errorf("Features = %v, want %v", rec.Features, want)
+	}
+
+	// An existing devcontainer is preferred over composing one
+	writeFiles(t, dir, map[string]string{".devcontainer/devcontainer.json": "{}"})
+	analysis, err = analyzeDir(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	analysis.recommend("docker")
+	if rec := analysis.Recommendation; rec.DevcontainerPath != ".devcontainer/devcontainer.json" || rec.Image != "" || len(rec.ForwardPorts) != 0 {
+		t.Errorf("Expected the existing devcontainer to be recommended, got %+v", rec)
+	}
+}
+
+func TestAnalyzeSourceClonesRepository(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	repo := t.TempDir()
+	writeFiles(t, repo, map[string]string{"Cargo.toml": "[package]\n", "src/main.rs": "fn main() {}\n"})
+	for _, args := range [][]string{
+		{"init", "-q", "-b", "main"},
+		{"add", "."},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "init"},
+	} {
+		if output, err := exec.Command("git", append([]string{"-C", repo}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, output)
+		}
+	}
+
+	s := newTestServer(t, &fakeRunner{outputs: map[string]string{
+		"provider list --output json": `{"kubernetes":{}}`,
+	}})
+	analyze := s.MCP().GetHandler("devpod_analyzeSource")
+	result, err := analyze(context.Background(), json.RawMessage(`{"source":"file://`+filepath.ToSlash(repo)+`@main"}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	analysis := result.(map[string]interface{})["analysis"].(*sourceAnalysis)
+	if len(analysis.Languages) == 0 || analysis.Languages[0].Name != "Rust" || !reflect.DeepEqual(analysis.PackageManagers, []string{"cargo"}) {
+		t.Errorf("Unexpected analysis: %+v", analysis)
+	}
+	if rec := analysis.Recommendation; rec.Provider != "kubernetes" || rec.Image != "mcr.microsoft.com/devcontainers/rust:1" {
+		t.Errorf("Unexpected recommendation: %+v", rec)
+	}
+
+	if _, err := analyze(context.Background(), json.RawMessage(`{"source":"ubuntu:22.04"}`)); err == nil {
+		t.Error("Expected an image source to be rejected")
+	}
+}
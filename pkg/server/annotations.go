@@ -0,0 +1,188 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+// ToolAnnotations are the MCP behaviour hints of a tool. Clients use them to
+// ask for confirmation before destructive calls and to retry idempotent ones.
+// Every hint is sent because the protocol defaults differ per hint.
+type ToolAnnotations struct {
+	// ReadOnlyHint is set when the tool does not change any state
+	ReadOnlyHint bool `json:"readOnlyHint"`
+	// DestructiveHint is set when the tool may delete or overwrite data
+	DestructiveHint bool `json:"destructiveHint"`
+	// IdempotentHint is set when repeating a call with the same arguments
+	// has no further effect
+	IdempotentHint bool `json:"idempotentHint"`
+	// OpenWorldHint is set when the tool reaches beyond the server, i.e.
+	// runs devpod against providers, workspaces or the network
+	OpenWorldHint bool `json:"openWorldHint"`
+}
+
+var (
+	// readOnlyTool queries devpod, its providers or workspaces
+	readOnlyTool = ToolAnnotations{ReadOnlyHint: true, IdempotentHint: true, OpenWorldHint: true}
+	// readOnlyLocalTool only reads the server's own state
+	readOnlyLocalTool = ToolAnnotations{ReadOnlyHint: true, IdempotentHint: true}
+)
+
+// toolAnnotations are the hints of each tool, sent with tools/list. Tools
+// not listed are sent without annotations.
+var toolAnnotations = map[string]ToolAnnotations{
+	"echo": readOnlyLocalTool,
+
+	"devpod_listWorkspaces":   readOnlyTool,
+	"devpod_status":           readOnlyTool,
+	"devpod_statusAll":        readOnlyTool,
+	"devpod_workspaceStats":   readOnlyTool,
+	"devpod_listProcesses":    readOnlyTool,
+	"devpod_listOpenPorts":    readOnlyTool,
+	"devpod_networkInfo":      readOnlyTool,
+	"devpod_gitStatus":        readOnlyTool,
+	"devpod_getSSHConfig":     readOnlyTool,
+	"devpod_exportWorkspace":  readOnlyTool,
+	"devpod_analyzeSource":    readOnlyTool,
+	"devpod_listEnvironments": readOnlyTool,
+	"devpod_listProviders":    readOnlyTool,
+	"devpod_searchProviders":  readOnlyTool,
+	"devpod_estimateUsage":    readOnlyTool,
+	"devpod_checkUpgrade":     readOnlyTool,
+	"devpod_troubleshoot":     readOnlyTool,
+
+	"devpod_listPrebuilds":     readOnlyLocalTool,
+	"devpod_listSnapshots":     readOnlyLocalTool,
+	"devpod_listSSHHosts":      readOnlyLocalTool,
+	"devpod_prebuildStatus":    readOnlyLocalTool,
+	"devpod_listSchedules":     readOnlyLocalTool,
+	"devpod_listSecrets":       readOnlyLocalTool,
+	"devpod_version":           readOnlyLocalTool,
+	"devpod_checkAvailability": readOnlyLocalTool,
+	"devpod_getFullOutput":     readOnlyLocalTool,
+	// lists directories of the client's roots or the workspace root
+	"devpod_listLocalProjects":    readOnlyLocalTool,
+	"devpod_serverEvents":         readOnlyLocalTool,
+	"devpod_whoami":               readOnlyLocalTool,
+	"devpod_listRecentOperations": readOnlyLocalTool,
+
+	// ifExists=recreate deletes the existing workspace
+	"devpod_createWorkspace": {DestructiveHint: true, OpenWorldHint: true},
+	// overwrite replaces an existing devcontainer.json
+	"devpod_composeDevcontainer": {DestructiveHint: true, OpenWorldHint: true},
+	"devpod_cloneWorkspace":      {OpenWorldHint: true},
+	"devpod_importWorkspace":     {OpenWorldHint: true},
+	"devpod_createEnvironment":   {OpenWorldHint: true},
+	"devpod_deleteEnvironment":   {DestructiveHint: true, IdempotentHint: true, OpenWorldHint: true},
+	"devpod_startWorkspace":      {IdempotentHint: true, OpenWorldHint: true},
+	"devpod_openIDE":             {IdempotentHint: true, OpenWorldHint: true},
+	"devpod_stopWorkspace":       {IdempotentHint: true, OpenWorldHint: true},
+	"devpod_startAll":            {IdempotentHint: true, OpenWorldHint: true},
+	"devpod_stopAll":             {IdempotentHint: true, OpenWorldHint: true},
+	"devpod_deleteWorkspace":     {DestructiveHint: true, IdempotentHint: true, OpenWorldHint: true},
+	"devpod_gcWorkspaces":        {DestructiveHint: true, OpenWorldHint: true},
+	"devpod_tagWorkspace":        {IdempotentHint: true},
+	// undoing a start stops the workspace
+	"devpod_undoLastOperation": {DestructiveHint: true, OpenWorldHint: true},
+	// scheduled operations may stop or delete workspaces later
+	"devpod_scheduleOperation": {DestructiveHint: true, OpenWorldHint: true},
+	"devpod_cancelSchedule":    {DestructiveHint: true, IdempotentHint: true},
+
+	"devpod_triggerPrebuild": {OpenWorldHint: true},
+	"devpod_deletePrebuild":  {DestructiveHint: true, IdempotentHint: true},
+	// the image reference is overwritten when it already exists
+	"devpod_snapshotWorkspace": {DestructiveHint: true, OpenWorldHint: true},
+
+	"devpod_addProvider":        {OpenWorldHint: true},
+	"devpod_setProviderOptions": {IdempotentHint: true, OpenWorldHint: true},
+	"devpod_updateProvider":     {IdempotentHint: true, OpenWorldHint: true},
+	"devpod_installCLI":         {DestructiveHint: true, IdempotentHint: true, OpenWorldHint: true},
+	"devpod_setDefaults":        {IdempotentHint: true},
+	// apply changes the docker provider's options
+	"devpod_doctor": {IdempotentHint: true, OpenWorldHint: true},
+
+	// the configure tools change the options of an installed provider
+	"devpod_configureAWSProvider":   {IdempotentHint: true, OpenWorldHint: true},
+	"devpod_configureGCPProvider":   {IdempotentHint: true, OpenWorldHint: true},
+	"devpod_configureAzureProvider": {IdempotentHint: true, OpenWorldHint: true},
+
+	"devpod_addSSHHost": {IdempotentHint: true, OpenWorldHint: true},
+	// testing accepts and remembers the host key of an unknown host
+	"devpod_testSSHHost":   {IdempotentHint: true, OpenWorldHint: true},
+	"devpod_removeSSHHost": {DestructiveHint: true, IdempotentHint: true},
+
+	// commands run in the workspace may change anything in it
+	"devpod_ssh":              {DestructiveHint: true, OpenWorldHint: true},
+	"devpod_execTask":         {DestructiveHint: true, OpenWorldHint: true},
+	"devpod_runTests":         {DestructiveHint: true, OpenWorldHint: true},
+	"devpod_closeConnections": {IdempotentHint: true},
+	"devpod_openInBrowser":    {OpenWorldHint: true},
+	"devpod_killProcess":      {DestructiveHint: true, IdempotentHint: true, OpenWorldHint: true},
+	"devpod_gitPull":          {IdempotentHint: true, OpenWorldHint: true},
+	"devpod_gitCheckout":      {IdempotentHint: true, OpenWorldHint: true},
+	// overwrites files in the destination and deletes with delete
+	"devpod_syncDirectory": {DestructiveHint: true, IdempotentHint: true, OpenWorldHint: true},
+	// path overwrites an existing file
+	"devpod_exportLogsBundle": {DestructiveHint: true, OpenWorldHint: true},
+	// overwrite replaces an existing local file or directory
+	"devpod_fetchArtifact":         {DestructiveHint: true, OpenWorldHint: true},
+	"devpod_copyBetweenWorkspaces": {DestructiveHint: true, OpenWorldHint: true},
+
+	"devpod_setSecret":    {IdempotentHint: true},
+	"devpod_deleteSecret": {DestructiveHint: true, IdempotentHint: true},
+}
+
+// listedTool is a tools/list entry, a tool with its annotations
+type listedTool struct {
+	mcp.Tool
+	Annotations *ToolAnnotations `json:"annotations,omitempty"`
+}
+
+// toolsListResult is the result of tools/list
+type toolsListResult struct {
+	Tools []listedTool `json:"tools"`
+}
+
+// listTools returns the registered tools with their provider option schemas
+// and annotations
+func (s *Server) listTools(ctx context.Context) toolsListResult {
+	return annotateTools(s.withProviderSchemas(ctx, s.tools.list()))
+}
+
+// annotateTools pairs tools with their annotations
+func annotateTools(tools []mcp.Tool) toolsListResult {
+	listed := make([]listedTool, len(tools))
+	for i, tool := range tools {
+		listed[i] = listedTool{Tool: tool}
+		if annotations, ok := toolAnnotations[tool.Name]; ok {
+			listed[i].Annotations = &annotations
+		}
+	}
+	return toolsListResult{Tools: listed}
+}
+
+// toolCatalog is the machine-readable catalog written by WriteToolCatalog
+type toolCatalog struct {
+	Server struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	} `json:"server"`
+	toolsListResult
+}
+
+// WriteToolCatalog writes every tool with its description, input schema and
+// annotations as indented JSON, in the shape of a tools/list result. Provider
+// option schemas are left out since they depend on the providers configured
+// on this machine, so the catalog needs neither devpod nor a client.
+func (s *Server) WriteToolCatalog(w io.Writer) error {
+	catalog := toolCatalog{toolsListResult: annotateTools(s.tools.list())}
+	catalog.Server.Name = "mcp-server-devpod"
+	catalog.Server.Version = s.opts.Version
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(catalog)
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestEveryToolIsAnnotated(t *testing.T) {
+	s := newTestServer(t, &fakeRunner{})
+
+	result := s.listTools(context.Background())
+	for _, tool := range result.Tools {
+		if tool.Annotations == nil {
+			t.Errorf("Tool %s has no annotations", tool.Name)
+			continue
+		}
+		if tool.Annotations.ReadOnlyHint && tool.Annotations.DestructiveHint {
+			t.Errorf("Tool %s is marked read-only and destructive", tool.Name)
+		}
+	}
+	for name := range toolAnnotations {
+		if _, ok := s.tools.get(name); !ok && name != "devpod_openInBrowser" {
+			t.Errorf("Annotations for unknown tool %s", name)
+		}
+	}
+
+	data := mustJSON(t, result)
+	for _, want := range []string{
+		`"name":"devpod_deleteWorkspace"`,
+		`"annotations":{"readOnlyHint":false,"destructiveHint":true,"idempotentHint":true,"openWorldHint":true}`,
+		`"annotations":{"readOnlyHint":true,"destructiveHint":false,"idempotentHint":true,"openWorldHint":true}`,
+		`"inputSchema":{`,
+	} {
+		if !strings.Contains(data, want) {
+			t.Errorf("Expected tools/list to contain %s", want)
+		}
+	}
+}
+
+func TestWriteToolCatalog(t *testing.T) {
+	runner := &fakeRunner{}
+	s := newTestServer(t, runner)
+
+	var buf bytes.Buffer
+	if err := s.WriteToolCatalog(&buf); err != nil {
+		t.Fatalf("WriteToolCatalog failed: %v", err)
+	}
+	if len(runner.calls) != 0 {
+		t.Errorf("Expected no devpod commands, got %v", runner.calls)
+	}
+
+	var catalog struct {
+		Server struct {
+			Name string `json:"name"`
+		} `json:"server"`
+		Tools []struct {
+			Name        string                 `json:"name"`
+			Description string                 `json:"description"`
+			InputSchema map[string]interface{} `json:"inputSchema"`
+			Annotations *ToolAnnotations       `json:"annotations"`
+		} `json:"tools"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &catalog); err != nil {
+		t.Fatalf("Catalog is not JSON: %v\n%s", err, buf.String())
+	}
+	if catalog.Server.Name != "mcp-server-devpod" {
+		t.Errorf("Expected server name mcp-server-devpod, got %q", catalog.Server.Name)
+	}
+	if len(catalog.Tools) != len(s.tools.list()) {
+		t.Errorf("Expected %d tools, got %d", len(s.tools.list()), len(catalog.Tools))
+	}
+	for _, tool := range catalog.Tools {
+		if tool.Description == "" || tool.InputSchema == nil || tool.Annotations == nil {
+			t.Errorf("Incomplete catalog entry for %s", tool.Name)
+		}
+	}
+}
@@ -0,0 +1,354 @@
+package server
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+const (
+	// defaultArtifactBytes caps an artifact returned inline unless the
+	// caller asks for more
+	defaultArtifactBytes = 1 << 20
+	// maxArtifactBytes caps an artifact written to a local path; the
+	// transfer is held in memory
+	maxArtifactBytes = 256 << 20
+)
+
+// artifactMarker precedes the kind, mode, size and SHA-256 checksum of an
+// artifact in the output of artifactCommand
+const artifactMarker = "---devpod-artifact---"
+
+// artifact is a file, or a directory as a gzipped tarball, copied out of a
+// workspace
+type artifact struct {
+	// Kind is file or directory
+	Kind   string
+	Mode   os.FileMode
+	Size   int64
+	SHA256 string
+	// Data is empty when the artifact is over the limit it was fetched with
+	Data []byte
+}
+
+// artifactCommand prints the artifactMarker line for path, relative to the
+// workspace folder unless absolute, and then the file, or the directory as
+// a gzipped tarball, base64-encoded when it is at most limit bytes
+func artifactCommand(path string, limit int64) string {
+	return fmt.Sprintf(`p=%s; if [ -d "$p" ]; then t=$(mktemp) || exit 1; trap 'rm -f "$t"' EXIT; tar -czf "$t" -C "$p" . || exit 1; k=directory; m=755; `+
+		`elif [ -f "$p" ]; then t=$p; k=file; m=$(stat -c %%a "$p" 2>/dev/null || echo 644); `+
+		`else echo "$p: no such file or directory" >&2; exit 2; fi; `+
+		`n=$(wc -c < "$t") && s=$(sha256sum < "$t") || exit 1; printf '\n%s %%s %%s %%s %%s\n' "$k" "$m" $n "${s%%%% *}"; `+
+		`if [ $n -le %d ]; then base64 < "$t"; fi`, shellQuote(path), artifactMarker, limit)
+}
+
+// parseArtifact reads the output of artifactCommand and verifies the
+// content against the size and checksum the workspace reported
+func parseArtifact(output []byte) (*artifact, error) {
+	i := bytes.Index(output, []byte(artifactMarker+" "))
+	if i < 0 {
+		return nil, fmt.Errorf("the workspace did not describe the artifact")
+	}
+	header, content, _ := bytes.Cut(output[i+len(artifactMarker)+1:], []byte("\n"))
+	fields := strings.Fields(string(header))
+	if len(fields) != 4 {
+		return nil, fmt.Errorf("invalid artifact header %q", header)
+	}
+	mode, modeErr := strconv.ParseUint(fields[1], 8, 32)
+	size, sizeErr := strconv.ParseInt(fields[2], 10, 64)
+	if modeErr != nil || sizeErr != nil || len(fields[3]) != sha256.Size*2 {
+		return nil, fmt.Errorf("invalid artifact header %q; the workspace needs stat, wc and sha256sum", header)
+	}
+	a := &artifact{Kind: fields[0], Mode: os.FileMode(mode) & os.ModePerm, Size: size, SHA256: fields[3]}
+
+	encoded := bytes.Map(func(r rune) rune {
+		if r == '\n' || r == '\r' {
+			return -1
+		}
+		return r
+	}, content)
+	if len(encoded) == 0 {
+		if size == 0 {
+			a.Data = []byte{}
+		}
+		return a, nil
+	}
+	data := make([]byte, base64.StdEncoding.DecodedLen(len(encoded)))
+	n, err := base64.StdEncoding.Decode(data, encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid artifact content: %w", err)
+	}
+	data = data[:n]
+	sum := sha256.Sum256(data)
+	if int64(len(data)) != size || hex.EncodeToString(sum[:]) != a.SHA256 {
+		return nil, fmt.Errorf("checksum mismatch: received %d bytes with SHA-256 %x, the workspace sent %d bytes with SHA-256 %s", len(data), sum, size, a.SHA256)
+	}
+	a.Data = data
+	return a, nil
+}
+
+// writeArtifact writes a file to target, or extracts a directory into it,
+// whose parent directory must exist and may be given relative to the
+// client's roots. An existing target is only replaced with overwrite. It
+// returns the target and the number of files written, and the entries of a
+// directory other than files and directories, which are skipped.
+func (s *Server) writeArtifact(ctx context.Context, target string, a *artifact, overwrite bool) (string, int, []string, error) {
+	target, err := s.artifactTarget(ctx, target)
+	if err != nil {
+		return "", 0, nil, err
+	}
+	if info, err := os.Lstat(target); err == nil {
+		if !overwrite {
+			return "", 0, nil, fmt.Errorf("%s already exists; pass overwrite to replace it", target)
+		}
+		if info.IsDir() != (a.Kind == "directory") {
+			return "", 0, nil, fmt.Errorf("cannot replace %s with a %s", target, a.Kind)
+		}
+	}
+
+	// Write next to the target and rename, so a failed transfer leaves an
+	// existing target as it was
+	dir, name := filepath.Dir(target), filepath.Base(target)
+	if a.Kind == "file" {
+		tmp, err := os.CreateTemp(dir, "."+name+".fetch-*")
+		if err != nil {
+			return "", 0, nil, fmt.Errorf("failed to write %s: %w", target, err)
+		}
+		defer os.Remove(tmp.Name())
+		_, err = tmp.Write(a.Data)
+		if closeErr := tmp.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = os.Chmod(tmp.Name(), a.Mode)
+		}
+		if err == nil {
+			err = os.Rename(tmp.Name(), target)
+		}
+		if err != nil {
+			return "", 0, nil, fmt.Errorf("failed to write %s: %w", target, err)
+		}
+		return target, 1, nil, nil
+	}
+
+	tmp, err := os.MkdirTemp(dir, "."+name+".fetch-*")
+	if err != nil {
+		return "", 0, nil, fmt.Errorf("failed to write %s: %w", target, err)
+	}
+	defer os.RemoveAll(tmp)
+	files, skipped, err := extractTarball(a.Data, tmp)
+	if err != nil {
+		return "", 0, nil, fmt.Errorf("failed to extract into %s: %w", target, err)
+	}
+	if err := os.RemoveAll(target); err != nil {
+		return "", 0, nil, fmt.Errorf("failed to replace %s: %w", target, err)
+	}
+	if err := os.Rename(tmp, target); err != nil {
+		return "", 0, nil, fmt.Errorf("failed to write %s: %w", target, err)
+	}
+	return target, files, skipped, nil
+}
+
+// artifactTarget resolves a local path to write an artifact to like
+// writeBundle does
+func (s *Server) artifactTarget(ctx context.Context, target string) (string, error) {
+	name := filepath.Base(target)
+	if name == "." || name == ".." || strings.HasSuffix(target, "/") || strings.HasSuffix(target, `\`) {
+		return "", fmt.Errorf("invalid local path %q", target)
+	}
+	dir, err := s.resolveLocalSource(ctx, filepath.Dir(target))
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// extractTarball extracts the directories and regular files of a gzipped
+// tarball into dir, refusing entries that lead outside it. Other entries,
+// such as symlinks, are skipped and returned.
+func extractTarball(data []byte, dir string) (int, []string, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return 0, nil, err
+	}
+	tr := tar.NewReader(gz)
+	files := 0
+	var skipped []string
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return files, skipped, nil
+		}
+		if err != nil {
+			return files, skipped, err
+		}
+		name := path.Clean(strings.TrimPrefix(header.Name, "./"))
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return files, skipped, fmt.Errorf("entry %s is outside the directory", header.Name)
+		}
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		mode := os.FileMode(header.Mode) & os.ModePerm
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return files, skipped, err
+			}
+			if err := os.Chmod(target, mode|0o700); err != nil {
+				return files, skipped, err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				return files, skipped, err
+			}
+			f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+			if err != nil {
+				return files, skipped, err
+			}
+			_, err = io.Copy(f, tr)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return files, skipped, err
+			}
+			files++
+		default:
+			skipped = append(skipped, name)
+		}
+	}
+}
+
+// registerArtifactTools registers the tool that fetches artifacts out of
+// workspaces
+func (s *Server) registerArtifactTools() {
+	// Copy a build artifact out of a workspace
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_fetchArtifact",
+		Description: "Copy a file or directory, such as a compiled binary or coverage report, out of a running DevPod workspace after verifying its SHA-256 checksum. It is written to a local path, a directory extracted into it, or returned base64-encoded, a directory as a gzipped tarball.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "The name of the workspace",
+				},
+				"path": map[string]interface{}{
+					"type":        "string",
+					"description": "File or directory inside the workspace, relative to the workspace folder unless absolute, e.g. bin/app or coverage/",
+				},
+				"localPath": map[string]interface{}{
+					"type":        "string",
+					"description": "Local file or directory to write the artifact to, in an existing directory within the workspace root when one is set; relative paths resolve against the client's roots (optional, the artifact is returned base64-encoded otherwise)",
+				},
+				"overwrite": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Replace an existing localPath (default: false)",
+				},
+				"maxBytes": map[string]interface{}{
+					"type":        "integer",
+					"description": "Maximum size of an artifact returned base64-encoded, in bytes (default: 1048576)",
+				},
+			},
+			"required": []string{"name", "path"},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var fetchParams struct {
+			Name      string `json:"name"`
+			Path      string `json:"path"`
+			LocalPath string `json:"localPath,omitempty"`
+			Overwrite bool   `json:"overwrite,omitempty"`
+			MaxBytes  int64  `json:"maxBytes,omitempty"`
+		}
+
+		if err := json.Unmarshal(params, &fetchParams); err != nil {
+			return nil, mcp.NewInvalidParamsError("Invalid fetch artifact parameters")
+		}
+		if fetchParams.Name == "" {
+			return nil, mcp.NewInvalidParamsError("Workspace name is required")
+		}
+		if fetchParams.Path == "" {
+			return nil, mcp.NewInvalidParamsError("path is required")
+		}
+		if fetchParams.MaxBytes < 0 {
+			return nil, mcp.NewInvalidParamsError("maxBytes must not be negative")
+		}
+		limit := fetchParams.MaxBytes
+		if limit == 0 {
+			limit = defaultArtifactBytes
+		}
+		if fetchParams.LocalPath != "" {
+			// Fail before the transfer rather than after it
+			if _, err := s.artifactTarget(ctx, fetchParams.LocalPath); err != nil {
+				return nil, mcp.NewInvalidParamsError(err.Error())
+			}
+			limit = maxArtifactBytes
+		}
+		if err := s.requireRunning(ctx, fetchParams.Name); err != nil {
+			return nil, err
+		}
+
+		// The artifact is data for the server, not console output
+		output, stderr, err := s.run(WithOutputWriter(ctx, nil), []string{"ssh", fetchParams.Name, "--command", artifactCommand(fetchParams.Path, limit)})
+		if err != nil {
+			s.state(ctx).RecordEvent(fetchParams.Name, "error", fmt.Sprintf("failed to fetch %s: %v", fetchParams.Path, err))
+			return nil, newDevPodError("failed to fetch artifact", err, stderr)
+		}
+		artifact, err := parseArtifact(output)
+		if err != nil {
+			s.state(ctx).RecordEvent(fetchParams.Name, "error", fmt.Sprintf("failed to fetch %s: %v", fetchParams.Path, err))
+			return nil, fmt.Errorf("failed to fetch %s: %w", fetchParams.Path, err)
+		}
+		if artifact.Data == nil {
+			if fetchParams.LocalPath != "" {
+				return nil, mcp.NewInvalidParamsError(fmt.Sprintf("%s is %d bytes, over the %d-byte limit", fetchParams.Path, artifact.Size, limit))
+			}
+			return nil, mcp.NewInvalidParamsError(fmt.Sprintf("%s is %d bytes, over the %d-byte limit; raise maxBytes or pass a localPath", fetchParams.Path, artifact.Size, limit))
+		}
+		s.state(ctx).RecordEvent(fetchParams.Name, "command", fmt.Sprintf("Fetched %s (%d bytes)", fetchParams.Path, artifact.Size))
+		s.touchWorkspace(ctx, fetchParams.Name)
+
+		result := map[string]interface{}{
+			"name":     fetchParams.Name,
+			"path":     fetchParams.Path,
+			"kind":     artifact.Kind,
+			"bytes":    artifact.Size,
+			"sha256":   artifact.SHA256,
+			"verified": true,
+		}
+		if artifact.Kind == "directory" {
+			result["format"] = "tar.gz"
+		}
+		if fetchParams.LocalPath != "" {
+			target, files, skipped, err := s.writeArtifact(ctx, fetchParams.LocalPath, artifact, fetchParams.Overwrite)
+			if err != nil {
+				return nil, mcp.NewInvalidParamsError(err.Error())
+			}
+			delete(result, "format")
+			result["localPath"] = target
+			result["files"] = files
+			if len(skipped) > 0 {
+				result["skipped"] = skipped
+			}
+			result["message"] = fmt.Sprintf("Copied %s from %s to %s (%d file(s), SHA-256 verified)", fetchParams.Path, fetchParams.Name, target, files)
+			return result, nil
+		}
+		result["content"] = base64.StdEncoding.EncodeToString(artifact.Data)
+		result["encoding"] = "base64"
+		result["message"] = fmt.Sprintf("Fetched %s from %s (%d bytes, SHA-256 verified)", fetchParams.Path, fetchParams.Name, artifact.Size)
+		return result, nil
+	})
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseArtifact(t *testing.T) {
+	const sum = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	a, err := parseArtifact([]byte("motd\n" + artifactMarker + " file 755 5 " + sum + "\naGVs\nbG8=\n"))
+	if err != nil {
+		t.Fatalf("parseArtifact failed: %v", err)
+	}
+	if a.Kind != "file" || a.Mode != 0o755 || string(a.Data) != "hello" {
+		t.Errorf("Unexpected artifact %+v", a)
+	}
+
+	if _, err := parseArtifact([]byte(artifactMarker + " file 644 5 " + sum + "\naGVscA==\n")); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("Expected a checksum mismatch, got %v", err)
+	}
+	if a, err := parseArtifact([]byte(artifactMarker + " file 644 5000000 " + sum + "\n")); err != nil || a.Data != nil {
+		t.Errorf("Expected an artifact over the limit without data, got %+v, %v", a, err)
+	}
+	if _, err := parseArtifact([]byte("sh: sha256sum: not found\n")); err == nil {
+		t.Error("Expected output without a header to be rejected")
+	}
+}
+
+func TestFetchArtifact(t *testing.T) {
+	dir, local := t.TempDir(), t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "coverage", "html"), 0o755)
+	os.WriteFile(filepath.Join(dir, "coverage", "html", "index.html"), []byte("<h1>92%</h1>"), 0o644)
+	os.Symlink("/etc/passwd", filepath.Join(dir, "coverage", "passwd"))
+	os.MkdirAll(filepath.Join(dir, "bin"), 0o755)
+	os.WriteFile(filepath.Join(dir, "bin", "app"), []byte("#!/bin/sh\necho hi\n"), 0o755)
+
+	s := newTestServer(t, &shellRunner{dir: dir})
+	fetch := s.MCP().GetHandler("devpod_fetchArtifact")
+	run := func(args string) (map[string]interface{}, error) {
+		t.Helper()
+		result, err := fetch(context.Background(), json.RawMessage(args))
+		if err != nil {
+			return nil, err
+		}
+		return result.(map[string]interface{}), nil
+	}
+
+	result, err := run(`{"name":"api","path":"bin/app"}`)
+	if err != nil {
+		t.Fatalf("fetchArtifact failed: %v", err)
+	}
+	if content, _ := base64.StdEncoding.DecodeString(result["content"].(string)); string(content) != "#!/bin/sh\necho hi\n" || result["verified"] != true {
+		t.Errorf("Unexpected result %v", result)
+	}
+
+	app := filepath.Join(local, "app")
+	if _, err := run(fmt.Sprintf(`{"name":"api","path":"bin/app","localPath":%q}`, app)); err != nil {
+		t.Fatalf("fetchArtifact to a local path failed: %v", err)
+	}
+	if info, err := os.Stat(app); err != nil || info.Mode().Perm() != 0o755 {
+		t.Errorf("Expected an executable copy, got %v, %v", info, err)
+	}
+	if _, err := run(fmt.Sprintf(`{"name":"api","path":"bin/app","localPath":%q}`, app)); err == nil {
+		t.Error("Expected an existing file not to be overwritten")
+	}
+
+	report := filepath.Join(local, "coverage")
+	os.MkdirAll(filepath.Join(report, "stale"), 0o755)
+	result, err = run(fmt.Sprintf(`{"name":"api","path":"coverage","localPath":%q,"overwrite":true}`, report))
+	if err != nil {
+		t.Fatalf("fetchArtifact of a directory failed: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(report, "html", "index.html")); err != nil || string(data) != "<h1>92%</h1>" {
+		t.Errorf("Expected the directory to be extracted, got %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(report, "stale")); !os.IsNotExist(err) {
+		t.Error("Expected the directory to be replaced")
+	}
+	if skipped, _ := result["skipped"].([]string); len(skipped) != 1 || skipped[0] != "passwd" {
+		t.Errorf("Expected the symlink to be skipped, got %v", result)
+	}
+
+	if _, err := run(`{"name":"api","path":"coverage","maxBytes":10}`); err == nil || !strings.Contains(err.Error(), "over the 10-byte limit") {
+		t.Errorf("Expected the size limit to apply, got %v", err)
+	}
+	if _, err := run(`{"name":"api","path":"missing"}`); err == nil {
+		t.Error("Expected a missing path to fail")
+	}
+}
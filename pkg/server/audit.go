@@ -0,0 +1,97 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// auditFile is the name of the audit log in the data directory
+const auditFile = "audit.jsonl"
+
+// maxAuditBytes is the size beyond which the audit log is rotated to
+// audit.jsonl.1, replacing the previous rotation
+const maxAuditBytes = 10 << 20
+
+// auditLog appends finished tool calls to a JSON Lines file so the call
+// history survives restarts
+type auditLog struct {
+	mu   sync.Mutex
+	path string
+}
+
+// openAuditLog returns the audit log in dir, creating dir if needed
+func openAuditLog(dir string) (*auditLog, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+	return &auditLog{path: filepath.Join(dir, auditFile)}, nil
+}
+
+// append writes a finished call, rotating the file once it grows too large
+func (a *auditLog) append(invocation toolInvocation) error {
+	if a == nil {
+		return nil
+	}
+	line, err := json.Marshal(invocation)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if info, err := os.Stat(a.path); err == nil && info.Size()+int64(len(line)) > maxAuditBytes {
+		if err := os.Rename(a.path, a.path+".1"); err != nil {
+			return err
+		}
+	}
+	f, err := os.OpenFile(a.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// tail returns up to n of the most recently written calls that match keep,
+// or of all calls when keep is nil, oldest first. Lines that cannot be
+// parsed, e.g. one cut short by a crash, are skipped.
+func (a *auditLog) tail(n int, keep func(toolInvocation) bool) ([]toolInvocation, error) {
+	if a == nil {
+		return nil, nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	f, err := os.Open(a.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var invocations []toolInvocation
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		var invocation toolInvocation
+		if err := json.Unmarshal(scanner.Bytes(), &invocation); err != nil || (keep != nil && !keep(invocation)) {
+			continue
+		}
+		invocations = append(invocations, invocation)
+		if len(invocations) > n {
+			invocations = invocations[1:]
+		}
+	}
+	return invocations, scanner.Err()
+}
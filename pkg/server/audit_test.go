@@ -0,0 +1,78 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/protobomb/mcp-server-framework/pkg/transport"
+)
+
+func TestDataDirKeepsAuditLog(t *testing.T) {
+	runner := &fakeRunner{
+		outputs:  map[string]string{"list --output json": "[]"},
+		failures: map[string]string{"stop api": "boom"},
+	}
+	dir := t.TempDir()
+	s := New(transport.NewSTDIOTransport(), Options{Runner: runner, DataDir: dir})
+	ctx := context.Background()
+
+	if s.opts.StatePath != filepath.Join(dir, "state.json") {
+		t.Errorf("Expected the state file in the data directory, got %s", s.opts.StatePath)
+	}
+	if _, err := s.MCP().GetHandler("devpod_listWorkspaces")(ctx, json.RawMessage(`{}`)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := s.MCP().GetHandler("devpod_stopWorkspace")(ctx, json.RawMessage(`{"name":"api"}`)); err == nil {
+		t.Fatal("Expected the stop to fail")
+	}
+	running := s.invocations.start("devpod_createWorkspace", "", "")
+	s.invocations.interruptAll()
+	s.invocations.finish(running, nil)
+
+	if _, err := os.Stat(filepath.Join(dir, auditFile)); err != nil {
+		t.Fatalf("Expected an audit log: %v", err)
+	}
+
+	// The history survives a restart
+	s = New(transport.NewSTDIOTransport(), Options{Runner: runner, DataDir: dir})
+	_, recent := s.invocations.snapshot("")
+	if len(recent) != 3 {
+		t.Fatalf("Expected 3 restored calls, got %+v", recent)
+	}
+	if recent[0].Tool != "devpod_createWorkspace" || recent[0].Error != errInterrupted.Error() {
+		t.Errorf("Expected the interrupted call first, got %+v", recent[0])
+	}
+	if recent[1].Tool != "devpod_stopWorkspace" || recent[1].Error == "" {
+		t.Errorf("Expected the failed stop, got %+v", recent[1])
+	}
+	if recent[2].Tool != "devpod_listWorkspaces" || recent[2].Error != "" {
+		t.Errorf("Expected the listing, got %+v", recent[2])
+	}
+	if id := s.invocations.start("devpod_version", "", ""); id <= recent[0].ID {
+		t.Errorf("Expected call IDs to continue after %d, got %d", recent[0].ID, id)
+	}
+}
+
+func TestAuditLogRecordsTheUser(t *testing.T) {
+	runner := &fakeRunner{outputs: map[string]string{"list --output json": "[]"}}
+	dir := t.TempDir()
+	s := New(transport.NewSTDIOTransport(), Options{Runner: runner, DataDir: dir, UserHomeRoot: t.TempDir()})
+	for _, user := range []string{"alice", "bob"} {
+		if _, err := s.MCP().GetHandler("devpod_listWorkspaces")(WithUser(context.Background(), user), json.RawMessage(`{}`)); err != nil {
+			t.Fatalf("devpod_listWorkspaces failed: %v", err)
+		}
+	}
+
+	// The persisted entries name the user
+	s = New(transport.NewSTDIOTransport(), Options{Runner: runner, DataDir: dir})
+	_, recent := s.invocations.snapshot("alice")
+	if len(recent) != 1 || recent[0].User != "alice" {
+		t.Errorf("Expected only alice's call, got %+v", recent)
+	}
+	if _, recent := s.invocations.snapshot(""); len(recent) != 0 {
+		t.Errorf("Expected no calls without a user, got %+v", recent)
+	}
+}
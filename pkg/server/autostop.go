@@ -0,0 +1,132 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// activityTracker remembers when a tool call last named each workspace, for
+// schedules triggered by inactivity. Workspaces no call has named since the
+// server started count from its start.
+type activityTracker struct {
+	mu    sync.Mutex
+	last  map[workspaceKey]time.Time
+	busy  map[workspaceKey]int
+	since time.Time
+	clock func() time.Time
+}
+
+func newActivityTracker() *activityTracker {
+	return &activityTracker{last: make(map[workspaceKey]time.Time), busy: make(map[workspaceKey]int), since: time.Now(), clock: time.Now}
+}
+
+// begin records a tool call naming a workspace. The workspace is active
+// until the returned function is called when the call ends.
+func (a *activityTracker) begin(user, name string) func() {
+	workspace := workspaceKey{user, name}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.last[workspace] = a.clock()
+	a.busy[workspace]++
+	return func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		a.last[workspace] = a.clock()
+		if a.busy[workspace]--; a.busy[workspace] <= 0 {
+			delete(a.busy, workspace)
+		}
+	}
+}
+
+// inactive returns how long no tool call has named a workspace at now, or
+// 0 while a call naming it runs
+func (a *activityTracker) inactive(user, name string, now time.Time) time.Duration {
+	workspace := workspaceKey{user, name}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.busy[workspace] > 0 {
+		return 0
+	}
+	last, ok := a.last[workspace]
+	if !ok {
+		last = a.since
+	}
+	return now.Sub(last)
+}
+
+// workspaceArg returns the workspace a tool call names: the argument
+// workspaceLockParams lists for the tool, else name
+func workspaceArg(tool string, params json.RawMessage) string {
+	param, ok := workspaceLockParams[tool]
+	if !ok {
+		param = "name"
+	}
+	var args map[string]interface{}
+	_ = json.Unmarshal(params, &args)
+	workspace, _ := args[param].(string)
+	return workspace
+}
+
+// checkAutoStop validates an autoStopAfter argument; "0" turns auto-stop off
+func checkAutoStop(after string) error {
+	if after == "" || after == "0" {
+		return nil
+	}
+	if seconds, ok := parseDurationSeconds(after); !ok || seconds < int64(schedulerInterval/time.Second) {
+		return fmt.Errorf("autoStopAfter must be a duration of at least a minute such as 30m or 2h, or 0 to turn auto-stop off")
+	}
+	return nil
+}
+
+// applyAutoStop sets up the auto-stop of a workspace created or started
+// through the server: the stop schedule after autoStopAfter without tool
+// activity, replacing an earlier one, or, without autoStopAfter, after the
+// server's default unless the workspace has one. It returns the schedule in
+// effect, if any.
+func (s *Server) applyAutoStop(ctx context.Context, name, after string) *scheduledOperation {
+	store := s.state(ctx)
+	var current *scheduledOperation
+	for _, op := range store.Schedules() {
+		if op.Workspace != name || !op.autoStop() {
+			continue
+		}
+		if after == "" {
+			current = &op
+			break
+		}
+		store.DeleteSchedule(op.ID)
+	}
+	if after == "" && current == nil && s.opts.AutoStopAfter > 0 {
+		after = formatDuration(s.opts.AutoStopAfter)
+	}
+	if after == "" || after == "0" {
+		return current
+	}
+
+	op := scheduledOperation{
+		ID:          newScheduleID(),
+		Workspace:   name,
+		User:        UserName(ctx),
+		Action:      "stop",
+		InactiveFor: after,
+		Created:     time.Now().UTC(),
+	}
+	store.SetSchedule(op)
+	return &op
+}
+
+// formatDuration formats a duration without the zero units
+// time.Duration.String adds, e.g. 30m rather than 30m0s
+func formatDuration(d time.Duration) string {
+	text := d.String()
+	for _, zero := range []string{"m0s", "h0m"} {
+		if strings.HasSuffix(text, zero) {
+			text = strings.TrimSuffix(text, zero[1:])
+		}
+	}
+	return text
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/protobomb/mcp-server-framework/pkg/transport"
+)
+
+func TestAutoStop(t *testing.T) {
+	runner := &fakeRunner{outputs: map[string]string{
+		"list --output json":       `[{"id":"api"}]`,
+		"status api --output json": `{"state":"Running"}`,
+		"up api":                   "",
+		"stop api":                 "",
+	}}
+	s := New(transport.NewSTDIOTransport(), Options{Runner: runner, StatePath: filepath.Join(t.TempDir(), "state.json"), AutoStopAfter: time.Hour})
+	ctx := context.Background()
+	start := func(args string) map[string]interface{} {
+		t.Helper()
+		result, err := s.MCP().GetHandler("devpod_startWorkspace")(ctx, json.RawMessage(args))
+		if err != nil {
+			t.Fatalf("startWorkspace %s failed: %v", args, err)
+		}
+		return result.(map[string]interface{})
+	}
+	autoStops := func() []scheduledOperation {
+		var ops []scheduledOperation
+		for _, op := range s.store.Schedules() {
+			if op.autoStop() {
+				ops = append(ops, op)
+			}
+		}
+		return ops
+	}
+	stops := func() int {
+		count := 0
+		for _, call := range runner.calls {
+			if fmt.Sprint(call) == "[stop api]" {
+				count++
+			}
+		}
+		return count
+	}
+
+	if result := start(`{"name":"api"}`); result["autoStopAfter"] != "1h" {
+		t.Errorf("Expected the server default, got %v", result)
+	}
+	if result := start(`{"name":"api","autoStopAfter":"30m"}`); result["autoStopAfter"] != "30m" || len(autoStops()) != 1 {
+		t.Errorf("Expected the auto-stop to be replaced, got %v and %+v", result, autoStops())
+	}
+	if result := start(`{"name":"api"}`); result["autoStopAfter"] != "30m" {
+		t.Errorf("Expected the auto-stop to be kept, got %v", result)
+	}
+
+	now := time.Now()
+	s.runDueSchedules(ctx, now.Add(10*time.Minute))
+	if stops() != 0 {
+		t.Fatal("Expected an active workspace to keep running")
+	}
+	// A running call keeps the workspace active however long it takes
+	done := s.activity.begin("", "api")
+	s.runDueSchedules(ctx, now.Add(2*time.Hour))
+	if stops() != 0 {
+		t.Fatal("Expected a workspace in use to keep running")
+	}
+	done()
+	s.runDueSchedules(ctx, now.Add(31*time.Minute))
+	if stops() != 1 {
+		t.Fatalf("Expected the inactive workspace to be stopped, got calls %v", runner.calls)
+	}
+	if ops := autoStops(); len(ops) != 1 || ops[0].Runs != 1 {
+		t.Errorf("Expected the auto-stop to stay for the next start, got %+v", ops)
+	}
+
+	if result := start(`{"name":"api","autoStopAfter":"0"}`); result["autoStopAfter"] != nil || len(autoStops()) != 0 {
+		t.Errorf("Expected the auto-stop to be turned off, got %v and %+v", result, autoStops())
+	}
+	if _, err := s.MCP().GetHandler("devpod_startWorkspace")(ctx, json.RawMessage(`{"name":"api","autoStopAfter":"5s"}`)); err == nil {
+		t.Error("Expected an auto-stop under a minute to be rejected")
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	for d, want := range map[time.Duration]string{
+		30 * time.Minute:          "30m",
+		2 * time.Hour:             "2h",
+		90 * time.Minute:          "1h30m",
+		time.Minute + time.Second: "1m1s",
+	} {
+		if got := formatDuration(d); got != want {
+			t.Errorf("formatDuration(%s) = %s, want %s", d, got, want)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Circuit breaker defaults for BreakerPolicy fields left at zero
+const defaultBreakerCooldown = 30 * time.Second
+
+// BreakerPolicy fails devpod commands fast while the backend they depend on,
+// such as the docker daemon or the network, is down
+type BreakerPolicy struct {
+	// Threshold is the number of consecutive backend failures that open the
+	// breaker; 0 disables it
+	Threshold int
+	// Cooldown is how long the breaker stays open before a single command is
+	// let through to probe the backend (default: 30s)
+	Cooldown time.Duration
+}
+
+// Breaker states reported in devpod/backendStatus notifications
+const (
+	breakerClosed   = "closed"
+	breakerOpen     = "open"
+	breakerHalfOpen = "halfOpen"
+)
+
+// backendPatterns are lowercase output fragments of failures caused by an
+// unreachable backend rather than by the command itself
+var backendPatterns = []string{
+	"cannot connect to the docker daemon", "is the docker daemon running", "error during connect",
+	"connection refused", "network is unreachable", "no route to host", "i/o timeout",
+	"tls handshake timeout", "temporary failure in name resolution", "no such host",
+}
+
+// BackendUnavailableError is returned without running a devpod command while
+// the circuit breaker is open
+type BackendUnavailableError struct {
+	// Cause is the last backend failure before the breaker opened
+	Cause string
+	// RetryAfter is the time until the breaker lets a probe through
+	RetryAfter time.Duration
+}
+
+// Error implements the error interface
+func (e *BackendUnavailableError) Error() string {
+	message := "devpod backend is unavailable after repeated failures"
+	if e.Cause != "" {
+		message += " (last error: " + e.Cause + ")"
+	}
+	if e.RetryAfter > 0 {
+		message += fmt.Sprintf("; retry after %s", e.RetryAfter.Round(time.Second))
+	}
+	return message
+}
+
+// backendFailure reports whether a failed devpod command points at an
+// unavailable backend. Commands run over ssh are left out since the output
+// is the user's command's, and a cancelled call says nothing about the
+// backend.
+func backendFailure(args []string, err error, output []byte) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var limitErr *RateLimitError
+	if errors.As(err, &limitErr) {
+		return false
+	}
+	if len(args) > 0 && args[0] == "ssh" {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	lower := strings.ToLower(string(output))
+	for _, pattern := range backendPatterns {
+		if strings.Contains(lower, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// breakerStatus is the state of the circuit breaker
+type breakerStatus struct {
+	State string `json:"state"`
+	// Failures is the number of consecutive backend failures
+	Failures  int    `json:"failures"`
+	LastError string `json:"lastError,omitempty"`
+	// RetryAfterMs is the time until an open breaker lets a probe through
+	RetryAfterMs int64 `json:"retryAfterMs,omitempty"`
+}
+
+// circuitBreaker counts consecutive backend failures of devpod commands.
+// Once Threshold is reached it opens and rejects commands for Cooldown, then
+// half-opens to let one probe through: a probe that reaches the backend
+// closes it, one that fails opens it again.
+type circuitBreaker struct {
+	policy BreakerPolicy
+	// notify is called outside the lock whenever the state changes
+	notify func(breakerStatus)
+
+	mu        sync.Mutex
+	state     string
+	failures  int
+	lastError string
+	openUntil time.Time
+	probing   bool
+	now       func() time.Time
+}
+
+// newCircuitBreaker returns a closed breaker
+func newCircuitBreaker(policy BreakerPolicy, notify func(breakerStatus)) *circuitBreaker {
+	if policy.Cooldown <= 0 {
+		policy.Cooldown = defaultBreakerCooldown
+	}
+	return &circuitBreaker{policy: policy, notify: notify, state: breakerClosed, now: time.Now}
+}
+
+// allow returns a *BackendUnavailableError when a command must not run. The
+// first command after the cooldown becomes the probe.
+func (b *circuitBreaker) allow() error {
+	if b.policy.Threshold <= 0 {
+		return nil
+	}
+	b.mu.Lock()
+	var changed *breakerStatus
+	defer func() {
+		b.mu.Unlock()
+		if changed != nil && b.notify != nil {
+			b.notify(*changed)
+		}
+	}()
+
+	switch b.state {
+	case breakerOpen:
+		if wait := b.openUntil.Sub(b.now()); wait > 0 {
+			return &BackendUnavailableError{Cause: b.lastError, RetryAfter: wait}
+		}
+		b.state, b.probing = breakerHalfOpen, true
+		status := b.statusLocked()
+		changed = &status
+	case breakerHalfOpen:
+		if b.probing {
+			return &BackendUnavailableError{Cause: b.lastError}
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// record notes the outcome of a command allow let through
+func (b *circuitBreaker) record(args []string, err error, output []byte) {
+	if b.policy.Threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	var changed *breakerStatus
+	defer func() {
+		b.mu.Unlock()
+		if changed != nil && b.notify != nil {
+			b.notify(*changed)
+		}
+	}()
+
+	probe := b.state == breakerHalfOpen
+	if probe {
+		b.probing = false
+	}
+	switch {
+	case backendFailure(args, err, output):
+		b.failures++
+		b.lastError = failureSummary(err, output)
+		if probe || (b.state == breakerClosed && b.failures >= b.policy.Threshold) {
+			b.state = breakerOpen
+			b.openUntil = b.now().Add(b.policy.Cooldown)
+			status := b.statusLocked()
+			changed = &status
+		}
+	case errors.Is(err, context.Canceled):
+		// the probe was abandoned; the next command probes instead
+	default:
+		// the command reached the backend, even if it failed otherwise
+		b.failures, b.lastError = 0, ""
+		if b.state != breakerClosed {
+			b.state = breakerClosed
+			status := b.statusLocked()
+			changed = &status
+		}
+	}
+}
+
+// status returns the current state of the breaker
+func (b *circuitBreaker) status() breakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.statusLocked()
+}
+
+func (b *circuitBreaker) statusLocked() breakerStatus {
+	status := breakerStatus{State: b.state, Failures: b.failures, LastError: b.lastError}
+	if b.state == breakerOpen {
+		if wait := b.openUntil.Sub(b.now()); wait > 0 {
+			status.RetryAfterMs = int64(wait / time.Millisecond)
+		}
+	}
+	return status
+}
+
+// failureSummary returns the last line of a failure's output, or the error
+// when there is none
+func failureSummary(err error, output []byte) string {
+	lines := strings.Split(strings.TrimSpace(sanitizeOutput(string(output))), "\n")
+	if last := strings.TrimSpace(lines[len(lines)-1]); last != "" {
+		return last
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "command timed out"
+	}
+	return err.Error()
+}
+
+// backendStatusChanged tells clients that the breaker opened, half-opened
+// or closed, and keeps a trip for devpod_serverEvents
+func (s *Server) backendStatusChanged(status breakerStatus) {
+	switch status.State {
+	case breakerOpen:
+		cooldown := (time.Duration(status.RetryAfterMs) * time.Millisecond).Round(time.Second)
+		s.reportEvent("error", "breaker", fmt.Errorf("devpod commands fail fast for %s after %d consecutive backend failures: %s",
+			cooldown, status.Failures, status.LastError))
+	case breakerClosed:
+		infof("devpod backend recovered, circuit breaker closed")
+	}
+	if !s.running() {
+		return
+	}
+	if err := s.mcp.SendNotification("devpod/backendStatus", status); err != nil {
+		log.Printf("WARNING: failed to send backend status notification: %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+func TestBackendFailure(t *testing.T) {
+	failed := errors.New("exit status 1")
+	tests := []struct {
+		args   []string
+		err    error
+		output string
+		want   bool
+	}{
+		{[]string{"up", "api"}, failed, "Cannot connect to the Docker daemon at unix:///var/run/docker.sock", true},
+		{[]string{"up", "api"}, failed, "dial tcp: lookup api.example.com: no such host", true},
+		{[]string{"up", "api"}, context.DeadlineExceeded, "", true},
+		{[]string{"up", "api"}, failed, "provider not found", false},
+		{[]string{"ssh", "api"}, failed, "connection refused", false},
+		{[]string{"up", "api"}, context.Canceled, "", false},
+		{[]string{"up", "api"}, &RateLimitError{Reason: "busy"}, "", false},
+	}
+	for _, tt := range tests {
+		if got := backendFailure(tt.args, tt.err, []byte(tt.output)); got != tt.want {
+			t.Errorf("backendFailure(%v, %v, %q) = %v; want %v", tt.args, tt.err, tt.output, got, tt.want)
+		}
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Now()
+	var changes []string
+	b := newCircuitBreaker(BreakerPolicy{Threshold: 2, Cooldown: time.Minute}, func(status breakerStatus) {
+		changes = append(changes, status.State)
+	})
+	b.now = func() time.Time { return now }
+
+	args := []string{"up", "api"}
+	down := []byte("Cannot connect to the Docker daemon")
+	failed := errors.New("exit status 1")
+
+	// ordinary failures reach the backend and reset the count
+	b.record(args, failed, down)
+	b.record(args, failed, []byte("workspace not found"))
+	b.record(args, failed, down)
+	if status := b.status(); status.State != breakerClosed || status.Failures != 1 {
+		t.Fatalf("Expected a closed breaker with 1 failure, got %+v", status)
+	}
+
+	b.record(args, failed, down)
+	var backendErr *BackendUnavailableError
+	if err := b.allow(); !errors.As(err, &backendErr) || backendErr.RetryAfter != time.Minute {
+		t.Fatalf("Expected the open breaker to fail fast for a minute, got %v", err)
+	}
+
+	// after the cooldown a single probe is let through
+	now = now.Add(time.Minute)
+	if err := b.allow(); err != nil {
+		t.Fatalf("Expected the probe to be allowed, got %v", err)
+	}
+	if err := b.allow(); err == nil {
+		t.Fatal("Expected commands to fail fast while the probe runs")
+	}
+	b.record(args, failed, down)
+	if status := b.status(); status.State != breakerOpen {
+		t.Fatalf("Expected a failed probe to reopen the breaker, got %+v", status)
+	}
+
+	now = now.Add(time.Minute)
+	if err := b.allow(); err != nil {
+		t.Fatalf("Expected the probe to be allowed, got %v", err)
+	}
+	b.record(args, nil, nil)
+	if status := b.status(); status.State != breakerClosed || status.Failures != 0 {
+		t.Fatalf("Expected a successful probe to close the breaker, got %+v", status)
+	}
+
+	want := []string{breakerOpen, breakerHalfOpen, breakerOpen, breakerHalfOpen, breakerClosed}
+	if len(changes) != len(want) {
+		t.Fatalf("Expected state changes %v, got %v", want, changes)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Fatalf("Expected state changes %v, got %v", want, changes)
+		}
+	}
+}
+
+func TestCircuitBreakerDisabled(t *testing.T) {
+	b := newCircuitBreaker(BreakerPolicy{}, nil)
+	for i := 0; i < 10; i++ {
+		b.record([]string{"up"}, errors.New("exit status 1"), []byte("connection refused"))
+	}
+	if err := b.allow(); err != nil {
+		t.Errorf("Expected a disabled breaker to allow commands, got %v", err)
+	}
+}
+
+func TestToolCallFailsFastWhenBackendIsDown(t *testing.T) {
+	runner := &flakyRunner{failures: 100}
+	s := newTestServer(t, runner)
+	s.breaker = newCircuitBreaker(BreakerPolicy{Threshold: 2, Cooldown: time.Minute}, s.backendStatusChanged)
+	call := s.MCP().GetHandler("tools/call")
+
+	for i := 0; i < 3; i++ {
+		_, err := call(context.Background(), json.RawMessage(`{"name":"devpod_listWorkspaces","arguments":{}}`))
+		if err == nil {
+			t.Fatal("Expected listing to fail while docker is down")
+		}
+		if i < 2 {
+			continue
+		}
+		rpcErr, ok := err.(*mcp.RPCError)
+		if !ok || rpcErr.Code != categoryCodes[CategoryBackendUnavailable] {
+			t.Fatalf("Expected a BackendUnavailable error, got %v", err)
+		}
+		if data := rpcErr.Data.(map[string]interface{}); data["retryAfterMs"] == nil {
+			t.Errorf("Expected a retryAfterMs hint, got %v", data)
+		}
+	}
+	if runner.calls != 2 {
+		t.Errorf("Expected devpod to run twice before the breaker opened, got %d", runner.calls)
+	}
+	if events := s.events.list("", "breaker", "error", 0); len(events) != 1 {
+		t.Errorf("Expected the trip to be reported as an event, got %v", events)
+	}
+}
@@ -0,0 +1,282 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+// bulkFilter selects the workspaces a bulk operation applies to. Empty
+// criteria match every workspace.
+type bulkFilter struct {
+	// Names lists the workspaces to include
+	Names []string `json:"names,omitempty"`
+	// Match is a glob pattern on workspace names, e.g. feature-*
+	Match string `json:"match,omitempty"`
+	// Provider limits the operation to workspaces of a provider
+	Provider string `json:"provider,omitempty"`
+	// Tag limits the operation to workspaces with a tag
+	Tag string `json:"tag,omitempty"`
+}
+
+// validate checks the glob pattern of a filter
+func (f bulkFilter) validate() error {
+	if _, err := path.Match(f.Match, ""); err != nil {
+		return fmt.Errorf("invalid match pattern %q", f.Match)
+	}
+	return nil
+}
+
+// matches reports whether a workspace is selected by the filter
+func (f bulkFilter) matches(workspace DevPodWorkspace) bool {
+	if len(f.Names) > 0 {
+		found := false
+		for _, name := range f.Names {
+			if name == workspace.ID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if f.Match != "" {
+		if ok, _ := path.Match(f.Match, workspace.ID); !ok {
+			return false
+		}
+	}
+	if f.Tag != "" && !hasTag(workspace.Tags, f.Tag) {
+		return false
+	}
+	return f.Provider == "" || f.Provider == workspace.Provider.Name
+}
+
+// bulkResult reports what a bulk operation did to one workspace
+type bulkResult struct {
+	Name          string `json:"name"`
+	Provider      string `json:"provider,omitempty"`
+	PreviousState string `json:"previousState"`
+	// Skipped is set for workspaces already in the requested state
+	Skipped    bool   `json:"skipped"`
+	Success    bool   `json:"success"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"durationMs,omitempty"`
+}
+
+// bulkLifecycle starts or stops the workspaces selected by the filter
+// concurrently, within the command limits, and returns one result per
+// workspace sorted by name. Workspaces already in the target state are
+// skipped; with dryRun nothing is changed.
+func (s *Server) bulkLifecycle(ctx context.Context, action string, filter bulkFilter, dryRun bool) ([]bulkResult, error) {
+	workspaces, err := s.listWorkspaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var selected []DevPodWorkspace
+	for _, workspace := range workspaces {
+		if filter.matches(workspace) {
+			selected = append(selected, workspace)
+		}
+	}
+
+	results := make([]bulkResult, len(selected))
+	var wg sync.WaitGroup
+	for i, workspace := range selected {
+		wg.Add(1)
+		go func(i int, workspace DevPodWorkspace) {
+			defer wg.Done()
+			result := bulkResult{
+				Name:          workspace.ID,
+				Provider:      workspace.Provider.Name,
+				PreviousState: s.getWorkspaceState(ctx, workspace.ID),
+			}
+			defer func() { results[i] = result }()
+
+			target := "Running"
+			if action == "stop" {
+				target = "Stopped"
+			}
+			if result.PreviousState == target || (action == "stop" && result.PreviousState == "NotFound") {
+				result.Skipped = true
+				result.Success = true
+				return
+			}
+			if dryRun {
+				result.Success = true
+				return
+			}
+
+			release, err := s.lockWorkspace(ctx, workspace.ID, "devpod_"+action+"All")
+			if err != nil {
+				result.Error = err.Error()
+				return
+			}
+			defer release()
+
+			start := time.Now()
+			if action == "stop" {
+				_, err = s.stopWorkspace(ctx, workspace.ID, "Workspace stopped")
+			} else {
+				_, _, err = s.startWorkspace(ctx, workspace.ID, "")
+			}
+			result.DurationMs = durationMs(start)
+			if err != nil {
+				result.Error = err.Error()
+				return
+			}
+			result.Success = true
+		}(i, workspace)
+	}
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+	return results, nil
+}
+
+// summarizeBulk returns the counts and message of a bulk operation result
+func summarizeBulk(verb string, results []bulkResult, dryRun bool) map[string]interface{} {
+	var changed, skipped, failed int
+	for _, result := range results {
+		switch {
+		case result.Skipped:
+			skipped++
+		case result.Success:
+			changed++
+		default:
+			failed++
+		}
+	}
+
+	message := fmt.Sprintf("%d workspace(s) %s, %d skipped, %d failed", changed, verb, skipped, failed)
+	if dryRun {
+		message = fmt.Sprintf("%d workspace(s) would be %s, %d skipped", changed, verb, skipped)
+	}
+	return map[string]interface{}{
+		"workspaces": results,
+		"changed":    changed,
+		"skipped":    skipped,
+		"failed":     failed,
+		"success":    failed == 0,
+		"dryRun":     dryRun,
+		"message":    message,
+	}
+}
+
+// registerBulkTools registers the tools that start and stop all workspaces
+func (s *Server) registerBulkTools() {
+	// Stop all workspaces
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_stopAll",
+		Description: "Stop all running workspaces, or those matching the filters, and report the result per workspace",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"names": map[string]interface{}{
+					"type":        "array",
+					"items":       map[string]interface{}{"type": "string"},
+					"description": "Only stop these workspaces (optional)",
+				},
+				"match": map[string]interface{}{
+					"type":        "string",
+					"description": "Only stop workspaces whose name matches this glob pattern, e.g. feature-* (optional)",
+				},
+				"provider": map[string]interface{}{
+					"type":        "string",
+					"description": "Only stop workspaces of this provider (optional)",
+				},
+				"tag": map[string]interface{}{
+					"type":        "string",
+					"description": "Only stop workspaces with this tag (optional)",
+				},
+				"dryRun": map[string]interface{}{
+					"type":        "boolean",
+					"description": "List the workspaces that would be stopped without changing them",
+				},
+			},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var stopParams struct {
+			bulkFilter
+			DryRun bool `json:"dryRun,omitempty"`
+		}
+		if len(params) > 0 {
+			if err := json.Unmarshal(params, &stopParams); err != nil {
+				return nil, mcp.NewInvalidParamsError("Invalid stop all parameters")
+			}
+		}
+		if err := stopParams.validate(); err != nil {
+			return nil, mcp.NewInvalidParamsError(err.Error())
+		}
+
+		start := time.Now()
+		results, err := s.bulkLifecycle(ctx, "stop", stopParams.bulkFilter, stopParams.DryRun)
+		if err != nil {
+			return nil, newDevPodError("failed to list workspaces", err, nil)
+		}
+		result := summarizeBulk("stopped", results, stopParams.DryRun)
+		result["durationMs"] = durationMs(start)
+		return result, nil
+	})
+
+	// Start all workspaces
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_startAll",
+		Description: "Start all stopped workspaces, or those matching the filters, and report the result per workspace",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"names": map[string]interface{}{
+					"type":        "array",
+					"items":       map[string]interface{}{"type": "string"},
+					"description": "Only start these workspaces (optional)",
+				},
+				"match": map[string]interface{}{
+					"type":        "string",
+					"description": "Only start workspaces whose name matches this glob pattern, e.g. feature-* (optional)",
+				},
+				"provider": map[string]interface{}{
+					"type":        "string",
+					"description": "Only start workspaces of this provider (optional)",
+				},
+				"tag": map[string]interface{}{
+					"type":        "string",
+					"description": "Only start workspaces with this tag (optional)",
+				},
+				"dryRun": map[string]interface{}{
+					"type":        "boolean",
+					"description": "List the workspaces that would be started without changing them",
+				},
+			},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var startParams struct {
+			bulkFilter
+			DryRun bool `json:"dryRun,omitempty"`
+		}
+		if len(params) > 0 {
+			if err := json.Unmarshal(params, &startParams); err != nil {
+				return nil, mcp.NewInvalidParamsError("Invalid start all parameters")
+			}
+		}
+		if err := startParams.validate(); err != nil {
+			return nil, mcp.NewInvalidParamsError(err.Error())
+		}
+
+		start := time.Now()
+		results, err := s.bulkLifecycle(ctx, "start", startParams.bulkFilter, startParams.DryRun)
+		if err != nil {
+			return nil, newDevPodError("failed to list workspaces", err, nil)
+		}
+		result := summarizeBulk("started", results, startParams.DryRun)
+		result["durationMs"] = durationMs(start)
+		return result, nil
+	})
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestStopAllAndStartAll(t *testing.T) {
+	runner := &fakeRunner{
+		outputs: map[string]string{
+			"list --output json":       `[{"id":"api","provider":{"name":"docker"}},{"id":"web","provider":{"name":"docker"}},{"id":"db","provider":{"name":"docker"}},{"id":"ml","provider":{"name":"aws"}}]`,
+			"status api --output json": `{"state":"Running"}`,
+			"status web --output json": `{"state":"Stopped"}`,
+			"status db --output json":  `{"state":"Running"}`,
+			"status ml --output json":  `{"state":"Running"}`,
+			"stop api":                 "",
+		},
+		failures: map[string]string{
+			"stop db": "cannot stop",
+			"up web":  "no capacity",
+		},
+	}
+	s := newTestServer(t, runner)
+
+	result, err := s.MCP().GetHandler("devpod_stopAll")(context.Background(), json.RawMessage(`{"provider":"docker"}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	summary := result.(map[string]interface{})
+	if summary["changed"] != 1 || summary["skipped"] != 1 || summary["failed"] != 1 || summary["success"] != false {
+		t.Errorf("Unexpected summary: %v", summary)
+	}
+	results := summary["workspaces"].([]bulkResult)
+	if len(results) != 3 || results[0].Name != "api" || results[1].Name != "db" || results[2].Name != "web" {
+		t.Fatalf("Expected the docker workspaces by name, got %+v", results)
+	}
+	if !results[0].Success || results[0].PreviousState != "Running" {
+		t.Errorf("Expected api to be stopped, got %+v", results[0])
+	}
+	if results[1].Success || !strings.Contains(results[1].Error, "failed to stop workspace") {
+		t.Errorf("Expected db to fail, got %+v", results[1])
+	}
+	if !results[2].Skipped {
+		t.Errorf("Expected web to be skipped, got %+v", results[2])
+	}
+
+	result, err = s.MCP().GetHandler("devpod_startAll")(context.Background(), json.RawMessage(`{"match":"w*","dryRun":true}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	summary = result.(map[string]interface{})
+	if summary["changed"] != 1 || summary["dryRun"] != true {
+		t.Errorf("Unexpected dry run summary: %v", summary)
+	}
+	for _, call := range runner.calls {
+		if call[0] == "up" {
+			t.Errorf("Expected a dry run not to start workspaces, got %v", call)
+		}
+	}
+
+	if _, err := s.MCP().GetHandler("devpod_startAll")(context.Background(), json.RawMessage(`{"match":"["}`)); err == nil {
+		t.Error("Expected an invalid pattern to be rejected")
+	}
+}
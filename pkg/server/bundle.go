@@ -0,0 +1,337 @@
+package server
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+// maxLogTailBytes bounds the server log kept in memory for support bundles
+const maxLogTailBytes = 1 << 20
+
+// Support bundle defaults
+const (
+	// defaultBundleBytes caps a bundle returned inline unless the caller asks
+	// for more
+	defaultBundleBytes = 1 << 20
+	// defaultBundleAuditEntries is the number of recent tool calls included
+	defaultBundleAuditEntries = 200
+)
+
+// logTail keeps the most recent server log output
+type logTail struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+// Write implements io.Writer, dropping the oldest lines once the tail
+// outgrows maxLogTailBytes
+func (l *logTail) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.buf = append(l.buf, p...)
+	if over := len(l.buf) - maxLogTailBytes; over > 0 {
+		cut := over
+		if i := bytes.IndexByte(l.buf[over:], '\n'); i >= 0 {
+			cut += i + 1
+		}
+		l.buf = append([]byte(nil), l.buf[cut:]...)
+	}
+	return len(p), nil
+}
+
+// contents returns a copy of the kept log
+func (l *logTail) contents() []byte {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]byte(nil), l.buf...)
+}
+
+// history returns up to n of a user's most recent tool calls, oldest first,
+// from the audit log when one is attached and from memory otherwise
+func (l *invocationLog) history(user string, n int) ([]toolInvocation, error) {
+	l.mu.Lock()
+	audit := l.audit
+	var recent []toolInvocation
+	for _, invocation := range l.recent {
+		if invocation.User == user {
+			recent = append(recent, invocation)
+		}
+	}
+	l.mu.Unlock()
+
+	if audit != nil {
+		return audit.tail(n, func(invocation toolInvocation) bool { return invocation.User == user })
+	}
+	if len(recent) > n {
+		recent = recent[len(recent)-n:]
+	}
+	return recent, nil
+}
+
+// bundleFile is a file of a support bundle
+type bundleFile struct {
+	Name    string
+	Content []byte
+}
+
+// supportBundle collects the evidence written into a support bundle. Parts
+// that cannot be collected are noted in errors instead of failing the bundle.
+type supportBundle struct {
+	created time.Time
+	files   []bundleFile
+	errors  map[string]string
+}
+
+func (b *supportBundle) add(name string, content []byte) {
+	b.files = append(b.files, bundleFile{Name: name, Content: content})
+}
+
+func (b *supportBundle) addJSON(name string, value interface{}) {
+	content, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		b.errors[name] = err.Error()
+		return
+	}
+	b.add(name, append(content, '\n'))
+}
+
+// names returns the names of the bundle's files
+func (b *supportBundle) names() []string {
+	names := make([]string, 0, len(b.files))
+	for _, file := range b.files {
+		names = append(names, file.Name)
+	}
+	return names
+}
+
+// archive writes the files as a gzipped tarball under a directory named
+// after the bundle's creation time, masking credentials with redact
+func (b *supportBundle) archive(redact func(string) string) ([]byte, error) {
+	dir := "devpod-bundle-" + b.created.Format("20060102-150405")
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, file := range b.files {
+		content := []byte(redact(string(file.Content)))
+		if err := tw.WriteHeader(&tar.Header{
+			Name:    dir + "/" + file.Name,
+			Mode:    0o600,
+			Size:    int64(len(content)),
+			ModTime: b.created,
+		}); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(content); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// bundleFileName turns a workspace name into a safe file name
+func bundleFileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ':' || r < ' ' {
+			return '_'
+		}
+		return r
+	}, name)
+}
+
+// collectSupportBundle gathers the server log, recent tool calls, background
+// events, workspace status and `devpod troubleshoot` output of the named
+// workspaces, or of every workspace when names is empty. Calls, events and
+// workspaces are the calling user's. With several users the server log,
+// which holds everyone's activity, is left out.
+func (s *Server) collectSupportBundle(ctx context.Context, names []string, auditEntries int) *supportBundle {
+	bundle := &supportBundle{created: time.Now().UTC(), errors: make(map[string]string)}
+
+	version, err := s.devpodVersion(ctx)
+	if err != nil {
+		bundle.errors["devpodVersion"] = err.Error()
+	}
+
+	if s.multiUser() {
+		bundle.errors["server.log"] = "left out: the server log holds the activity of every user"
+	} else {
+		bundle.add("server.log", s.logs.contents())
+	}
+
+	user := UserName(ctx)
+	calls, err := s.invocations.history(user, auditEntries)
+	if err != nil {
+		bundle.errors["audit.jsonl"] = err.Error()
+	}
+	var audit bytes.Buffer
+	for _, call := range calls {
+		line, _ := json.Marshal(call)
+		audit.Write(append(line, '\n'))
+	}
+	bundle.add("audit.jsonl", audit.Bytes())
+
+	running, _ := s.invocations.snapshot(user)
+	bundle.addJSON("events.json", map[string]interface{}{
+		"events":       s.events.list(user, "", "", 0),
+		"backend":      s.breaker.status(),
+		"locks":        s.locks.list(user),
+		"runningCalls": running,
+	})
+
+	workspaces, err := s.listWorkspaces(ctx)
+	if err != nil {
+		bundle.errors["workspaces.json"] = err.Error()
+	} else {
+		bundle.addJSON("workspaces.json", workspaces)
+		if len(names) == 0 {
+			for _, workspace := range workspaces {
+				names = append(names, workspace.ID)
+			}
+		}
+	}
+
+	for _, name := range names {
+		file := "troubleshoot/" + bundleFileName(name) + ".txt"
+		// troubleshoot exits non-zero for broken workspaces, which is when it matters most
+		output, err := s.combinedOutput(ctx, []string{"troubleshoot", name})
+		if err != nil {
+			bundle.errors[file] = err.Error()
+			if len(bytes.TrimSpace(output)) == 0 {
+				continue
+			}
+		}
+		bundle.add(file, output)
+	}
+
+	bundle.addJSON("manifest.json", map[string]interface{}{
+		"created":       bundle.created,
+		"serverVersion": s.opts.Version,
+		"devpodVersion": version,
+		"os":            runtime.GOOS,
+		"arch":          runtime.GOARCH,
+		"hostPlatform":  hostPlatform(),
+		"files":         bundle.names(),
+		"errors":        bundle.errors,
+	})
+	return bundle
+}
+
+// writeBundle writes a bundle archive to path, whose directory must exist
+// and may be given relative to the client's roots
+func (s *Server) writeBundle(ctx context.Context, path string, archive []byte) (string, error) {
+	name := filepath.Base(path)
+	if name == "." || name == ".." || strings.HasSuffix(path, "/") || strings.HasSuffix(path, `\`) {
+		return "", fmt.Errorf("invalid bundle path %q", path)
+	}
+	dir, err := s.resolveLocalSource(ctx, filepath.Dir(path))
+	if err != nil {
+		return "", err
+	}
+	target := filepath.Join(dir, name)
+	if err := os.WriteFile(target, archive, 0o600); err != nil {
+		return "", fmt.Errorf("failed to write bundle: %w", err)
+	}
+	return target, nil
+}
+
+// registerBundleTools registers the tool that exports support bundles
+func (s *Server) registerBundleTools() {
+	// Collect evidence for a bug report
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_exportLogsBundle",
+		Description: "Collect the server log, recent tool calls, background events, workspace status and devpod troubleshoot output into a gzipped tarball for bug reports, written to a path or returned base64-encoded",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"names": map[string]interface{}{
+					"type":        "array",
+					"items":       map[string]interface{}{"type": "string"},
+					"description": "Workspaces to include devpod troubleshoot output for (optional, defaults to every workspace)",
+				},
+				"auditEntries": map[string]interface{}{
+					"type":        "integer",
+					"description": "Number of recent tool calls to include (default: 200)",
+				},
+				"path": map[string]interface{}{
+					"type":        "string",
+					"description": "Local file to write the bundle to, e.g. devpod-bundle.tar.gz; relative paths resolve against the client's roots (optional, the bundle is returned base64-encoded otherwise)",
+				},
+				"maxBytes": map[string]interface{}{
+					"type":        "integer",
+					"description": "Maximum size of a bundle returned base64-encoded, in bytes (default: 1048576)",
+				},
+			},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var bundleParams struct {
+			Names        []string `json:"names,omitempty"`
+			AuditEntries int      `json:"auditEntries,omitempty"`
+			Path         string   `json:"path,omitempty"`
+			MaxBytes     int      `json:"maxBytes,omitempty"`
+		}
+
+		if len(params) > 0 {
+			if err := json.Unmarshal(params, &bundleParams); err != nil {
+				return nil, mcp.NewInvalidParamsError("Invalid logs bundle parameters")
+			}
+		}
+		if bundleParams.AuditEntries < 0 || bundleParams.MaxBytes < 0 {
+			return nil, mcp.NewInvalidParamsError("auditEntries and maxBytes must not be negative")
+		}
+		if bundleParams.AuditEntries == 0 {
+			bundleParams.AuditEntries = defaultBundleAuditEntries
+		}
+		if bundleParams.MaxBytes == 0 {
+			bundleParams.MaxBytes = defaultBundleBytes
+		}
+
+		bundle := s.collectSupportBundle(ctx, bundleParams.Names, bundleParams.AuditEntries)
+		archive, err := bundle.archive(s.redactor.redact)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create bundle: %w", err)
+		}
+
+		result := map[string]interface{}{
+			"files":  bundle.names(),
+			"bytes":  len(archive),
+			"format": "tar.gz",
+		}
+		if len(bundle.errors) > 0 {
+			result["errors"] = bundle.errors
+		}
+		if bundleParams.Path != "" {
+			path, err := s.writeBundle(ctx, bundleParams.Path, archive)
+			if err != nil {
+				return nil, mcp.NewInvalidParamsError(err.Error())
+			}
+			result["path"] = path
+			result["message"] = fmt.Sprintf("Wrote a %d-byte bundle of %d file(s) to %s", len(archive), len(bundle.files), path)
+			return result, nil
+		}
+		if len(archive) > bundleParams.MaxBytes {
+			return nil, mcp.NewInvalidParamsError(fmt.Sprintf("The bundle is %d bytes, over the %d-byte limit; raise maxBytes, pass a path, or include fewer workspaces or auditEntries", len(archive), bundleParams.MaxBytes))
+		}
+		result["bundle"] = base64.StdEncoding.EncodeToString(archive)
+		result["encoding"] = "base64"
+		result["message"] = fmt.Sprintf("Collected a %d-byte bundle of %d file(s)", len(archive), len(bundle.files))
+		return result, nil
+	})
+}
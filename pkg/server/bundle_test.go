@@ -0,0 +1,144 @@
+package server
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// readBundle returns the files of a bundle archive by name, without the
+// directory they are under
+func readBundle(t *testing.T, archive []byte) map[string]string {
+	t.Helper()
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatalf("Bundle is not gzipped: %v", err)
+	}
+	files := make(map[string]string)
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Bundle is not a tarball: %v", err)
+		}
+		content, _ := io.ReadAll(tr)
+		_, name, _ := strings.Cut(header.Name, "/")
+		files[name] = string(content)
+	}
+	return files
+}
+
+func TestExportLogsBundle(t *testing.T) {
+	runner := &fakeRunner{outputs: map[string]string{
+		"list --output json": `[{"id":"api","source":{"gitRepository":"https://github.com/example/api"}}]`,
+		"troubleshoot api":   "## Workspace Status\nstate: Busy\ntoken: hunter2-secret\n",
+		"version":            "v0.6.15",
+	}}
+	s := newTestServer(t, runner)
+	s.redactor.add("hunter2-secret")
+	logger := log.New(s.RedactingWriter(io.Discard), "", 0)
+	logger.Printf("starting with hunter2-secret")
+
+	handler := s.MCP().GetHandler("devpod_exportLogsBundle")
+	result, err := handler(context.Background(), json.RawMessage(`{}`))
+	if err != nil {
+		t.Fatalf("devpod_exportLogsBundle failed: %v", err)
+	}
+	fields := result.(map[string]interface{})
+	archive, err := base64.StdEncoding.DecodeString(fields["bundle"].(string))
+	if err != nil {
+		t.Fatalf("Bundle is not base64: %v", err)
+	}
+	files := readBundle(t, archive)
+	for _, name := range []string{"manifest.json", "server.log", "audit.jsonl", "events.json", "workspaces.json", "troubleshoot/api.txt"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("Expected %s in the bundle, got %v", name, fields["files"])
+		}
+	}
+	if !strings.Contains(files["troubleshoot/api.txt"], "state: Busy") {
+		t.Errorf("Expected the troubleshoot output, got %q", files["troubleshoot/api.txt"])
+	}
+	for name, content := range files {
+		if strings.Contains(content, "hunter2-secret") {
+			t.Errorf("Expected credentials to be redacted from %s", name)
+		}
+	}
+	if !strings.Contains(files["server.log"], "starting with") {
+		t.Errorf("Expected the server log, got %q", files["server.log"])
+	}
+
+	// bundles over the inline limit must be written to a file
+	if _, err := handler(context.Background(), json.RawMessage(`{"maxBytes":10}`)); err == nil {
+		t.Error("Expected a bundle over maxBytes to be rejected")
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "bundle.tar.gz")
+	result, err = handler(context.Background(), json.RawMessage(fmt.Sprintf(`{"maxBytes":10,"path":%q}`, path)))
+	if err != nil {
+		t.Fatalf("devpod_exportLogsBundle with a path failed: %v", err)
+	}
+	written, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Bundle was not written: %v", err)
+	}
+	if len(written) != result.(map[string]interface{})["bytes"].(int) {
+		t.Errorf("Expected %v bytes, wrote %d", result.(map[string]interface{})["bytes"], len(written))
+	}
+}
+
+func TestLogTailKeepsWholeRecentLines(t *testing.T) {
+	var tail logTail
+	line := strings.Repeat("x", 1023) + "\n"
+	for i := 0; i < maxLogTailBytes/1024+10; i++ {
+		tail.Write([]byte(line))
+	}
+	contents := tail.contents()
+	if len(contents) > maxLogTailBytes || !bytes.HasPrefix(contents, []byte("x")) || len(contents)%1024 != 0 {
+		t.Errorf("Expected whole lines within %d bytes, got %d bytes", maxLogTailBytes, len(contents))
+	}
+}
+
+func TestSupportBundleOnlyHoldsTheCallersActivity(t *testing.T) {
+	runner := &fakeRunner{outputs: map[string]string{"list --output json": "[]", "version": "v0.6.15"}}
+	s := newTestServer(t, runner)
+	s.opts.UserHomeRoot = t.TempDir()
+	alice := WithUser(context.Background(), "alice")
+	bob := WithUser(context.Background(), "bob")
+	log.New(s.RedactingWriter(io.Discard), "", 0).Printf("bob did something")
+	for _, ctx := range []context.Context{alice, bob} {
+		if _, err := s.MCP().GetHandler("devpod_listWorkspaces")(ctx, json.RawMessage(`{}`)); err != nil {
+			t.Fatalf("devpod_listWorkspaces failed: %v", err)
+		}
+	}
+	s.reportUserEvent("bob", "warning", "scheduler", errors.New("scheduled stop of bob-api failed"))
+
+	result, err := s.MCP().GetHandler("devpod_exportLogsBundle")(alice, json.RawMessage(`{}`))
+	if err != nil {
+		t.Fatalf("devpod_exportLogsBundle failed: %v", err)
+	}
+	archive, _ := base64.StdEncoding.DecodeString(result.(map[string]interface{})["bundle"].(string))
+	files := readBundle(t, archive)
+	if _, ok := files["server.log"]; ok {
+		t.Error("Expected the server log to be left out with several users")
+	}
+	if audit := files["audit.jsonl"]; !strings.Contains(audit, `"user":"alice"`) || strings.Contains(audit, "bob") {
+		t.Errorf("Expected only alice's calls, got %s", audit)
+	}
+	if strings.Contains(files["events.json"], "bob") {
+		t.Errorf("Expected no events of bob, got %s", files["events.json"])
+	}
+}
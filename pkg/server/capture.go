@@ -0,0 +1,116 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Directions of captured messages
+const (
+	captureIn  = "in"
+	captureOut = "out"
+)
+
+// captureLog writes every JSON-RPC message the server receives or sends to
+// its own file, numbered in the order they passed through
+type captureLog struct {
+	dir    string
+	seq    atomic.Int64
+	redact func(string) string
+}
+
+// openCaptureLog returns a capture log writing to dir, creating dir if needed
+func openCaptureLog(dir string, redact func(string) string) (*captureLog, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create capture directory: %w", err)
+	}
+	return &captureLog{dir: dir, redact: redact}, nil
+}
+
+// capturedMessage is the content of a capture file
+type capturedMessage struct {
+	Seq       int64       `json:"seq"`
+	Time      time.Time   `json:"time"`
+	Direction string      `json:"direction"`
+	Session   string      `json:"session,omitempty"`
+	Message   interface{} `json:"message"`
+}
+
+// record writes a message as NNNNNN-direction-label.json, e.g.
+// 000003-in-tools_call.json. Messages that are not JSON are kept as strings.
+func (c *captureLog) record(ctx context.Context, direction string, message []byte) {
+	if c == nil {
+		return
+	}
+	seq := c.seq.Add(1)
+	captured := capturedMessage{Seq: seq, Time: time.Now().UTC(), Direction: direction, Session: SessionID(ctx)}
+
+	var decoded interface{}
+	if err := json.Unmarshal(message, &decoded); err != nil {
+		captured.Message = string(message)
+	} else {
+		maskCapturedSecrets(decoded)
+		captured.Message = decoded
+	}
+	content, err := json.MarshalIndent(captured, "", "  ")
+	if err != nil {
+		log.Printf("WARNING: failed to capture message: %v", err)
+		return
+	}
+
+	name := fmt.Sprintf("%06d-%s-%s.json", seq, direction, captureLabel(decoded))
+	if err := os.WriteFile(filepath.Join(c.dir, name), []byte(c.redact(string(content))+"\n"), 0o600); err != nil {
+		log.Printf("WARNING: failed to capture message: %v", err)
+	}
+}
+
+// captureLabel names a message by its method, e.g. tools_call, or as a
+// response or error
+func captureLabel(message interface{}) string {
+	fields, _ := message.(map[string]interface{})
+	switch method, _ := fields["method"].(string); {
+	case method != "":
+		return strings.Map(func(r rune) rune {
+			if r == '/' || r == '\\' || r == ':' || r == ' ' {
+				return '_'
+			}
+			return r
+		}, method)
+	case fields["error"] != nil:
+		return "error"
+	case fields["result"] != nil:
+		return "response"
+	}
+	return "message"
+}
+
+// maskCapturedSecrets masks credentials in arguments before the server has
+// seen them, which the redactor only learns once the call runs: secret
+// values passed to devpod_setSecret and credential-named env variables
+func maskCapturedSecrets(message interface{}) {
+	fields, _ := message.(map[string]interface{})
+	params, _ := fields["params"].(map[string]interface{})
+	args, _ := params["arguments"].(map[string]interface{})
+	if args == nil {
+		return
+	}
+	if params["name"] == "devpod_setSecret" {
+		if _, ok := args["value"]; ok {
+			args["value"] = redactedText
+		}
+	}
+	if env, ok := args["env"].(map[string]interface{}); ok {
+		for name := range env {
+			if secretEnvName.MatchString(name) {
+				env[name] = redactedText
+			}
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// channelTransport delivers the messages pushed to in
+type channelTransport struct {
+	recordingTransport
+	in chan []byte
+}
+
+func (c *channelTransport) Receive() <-chan []byte { return c.in }
+
+// capturedFiles returns the capture files in dir by name
+func capturedFiles(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Failed to read capture directory: %v", err)
+	}
+	files := make(map[string]string)
+	for _, entry := range entries {
+		content, _ := os.ReadFile(filepath.Join(dir, entry.Name()))
+		files[entry.Name()] = string(content)
+	}
+	return files
+}
+
+func TestCaptureLogRedactsAndNamesMessages(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "capture")
+	r := newRedactor()
+	r.add("known-secret")
+	capture, err := openCaptureLog(dir, r.redact)
+	if err != nil {
+		t.Fatalf("openCaptureLog failed: %v", err)
+	}
+
+	ctx := WithSessionID(context.Background(), "session-1")
+	capture.record(ctx, captureIn, []byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"devpod_setSecret","arguments":{"name":"db","value":"s3cr3t-value"}}}`))
+	capture.record(ctx, captureIn, []byte(`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"devpod_startWorkspace","arguments":{"name":"api","env":{"NPM_TOKEN":"npm-token-value","DOCKER_HOST":"tcp://builder:2376"}}}}`))
+	capture.record(ctx, captureOut, []byte(`{"jsonrpc":"2.0","id":2,"result":{"output":"uses known-secret"}}`))
+	capture.record(context.Background(), captureIn, []byte(`not json`))
+
+	files := capturedFiles(t, dir)
+	for _, name := range []string{"000001-in-tools_call.json", "000002-in-tools_call.json", "000003-out-response.json", "000004-in-message.json"} {
+		if _, ok := files[name]; !ok {
+			t.Fatalf("Expected capture file %s, got %v", name, files)
+		}
+	}
+	for name, content := range files {
+		for _, secret := range []string{"s3cr3t-value", "npm-token-value", "known-secret"} {
+			if strings.Contains(content, secret) {
+				t.Errorf("Expected %s to be redacted from %s", secret, name)
+			}
+		}
+	}
+	if first := files["000001-in-tools_call.json"]; !strings.Contains(first, `"session": "session-1"`) || !strings.Contains(first, "\n    \"method\": \"tools/call\"") {
+		t.Errorf("Expected a pretty-printed message with its session, got %s", first)
+	}
+	if !strings.Contains(files["000002-in-tools_call.json"], "tcp://builder:2376") {
+		t.Error("Expected variables that are not credentials to be kept")
+	}
+	if !strings.Contains(files["000004-in-message.json"], `"message": "not json"`) {
+		t.Errorf("Expected invalid JSON to be kept as a string, got %s", files["000004-in-message.json"])
+	}
+}
+
+func TestClientTransportCapturesTraffic(t *testing.T) {
+	dir := t.TempDir()
+	capture, err := openCaptureLog(dir, newRedactor().redact)
+	if err != nil {
+		t.Fatalf("openCaptureLog failed: %v", err)
+	}
+	inner := &channelTransport{in: make(chan []byte, 1)}
+	client := newClientTransport(inner, newClientRequests())
+	client.capture = capture
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := client.Start(ctx); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	inner.in <- []byte(`{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+	<-client.Receive()
+	if err := client.Send([]byte(`{"jsonrpc":"2.0","method":"notifications/message","params":{}}`)); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	files := capturedFiles(t, dir)
+	if _, ok := files["000001-in-notifications_initialized.json"]; !ok {
+		t.Errorf("Expected the received notification to be captured, got %v", files)
+	}
+	if _, ok := files["000002-out-notifications_message.json"]; !ok {
+		t.Errorf("Expected the sent notification to be captured, got %v", files)
+	}
+	if len(inner.sent) != 1 {
+		t.Errorf("Expected the message to reach the inner transport, sent %d", len(inner.sent))
+	}
+}
@@ -0,0 +1,179 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+// defaultProviderIndexURL is the GitHub API base under which provider
+// repositories are looked up
+const defaultProviderIndexURL = "https://api.github.com/repos"
+
+// catalogTTL is how long a provider's latest release is cached. GitHub allows
+// only 60 anonymous API requests per hour.
+const catalogTTL = time.Hour
+
+// catalogEntry is a provider from the community index
+type catalogEntry struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Source is what to pass as name to devpod_addProvider
+	Source string `json:"source"`
+	// Repository is the GitHub repository the provider is released from
+	Repository    string `json:"repository"`
+	Official      bool   `json:"official"`
+	LatestVersion string `json:"latestVersion,omitempty"`
+	ReleasedAt    string `json:"releasedAt,omitempty"`
+	ReleaseURL    string `json:"releaseUrl,omitempty"`
+	Installed     bool   `json:"installed"`
+	Error         string `json:"error,omitempty"`
+}
+
+// providerIndex lists the known DevPod providers. Official providers are
+// added by their short name; community ones by their repository.
+var providerIndex = []catalogEntry{
+	{Name: "docker", Description: "Run workspaces in containers of the local Docker daemon", Repository: "loft-sh/devpod", Official: true},
+	{Name: "kubernetes", Description: "Run workspaces as pods in a Kubernetes cluster", Repository: "loft-sh/devpod-provider-kubernetes", Official: true},
+	{Name: "ssh", Description: "Run workspaces on any machine reachable over SSH", Repository: "loft-sh/devpod-provider-ssh", Official: true},
+	{Name: "aws", Description: "Run workspaces on AWS EC2 instances", Repository: "loft-sh/devpod-provider-aws", Official: true},
+	{Name: "gcloud", Description: "Run workspaces on Google Cloud Compute Engine VMs", Repository: "loft-sh/devpod-provider-gcloud", Official: true},
+	{Name: "azure", Description: "Run workspaces on Azure virtual machines", Repository: "loft-sh/devpod-provider-azure", Official: true},
+	{Name: "digitalocean", Description: "Run workspaces on DigitalOcean droplets", Repository: "loft-sh/devpod-provider-digitalocean", Official: true},
+	{Name: "civo", Description: "Run workspaces on Civo instances", Repository: "loft-sh/devpod-provider-civo", Official: true},
+	{Name: "terraform", Description: "Provision workspace machines with Terraform", Repository: "loft-sh/devpod-provider-terraform", Official: true},
+	{Name: "hetzner", Description: "Run workspaces on Hetzner Cloud servers", Repository: "mrsimonemms/devpod-provider-hetzner"},
+	{Name: "ovhcloud", Description: "Run workspaces on OVHcloud public cloud instances", Repository: "alexandrevilain/devpod-provider-ovhcloud"},
+}
+
+// cachedRelease is the latest release of a provider repository
+type cachedRelease struct {
+	fetched time.Time
+	release devpodRelease
+	err     error
+}
+
+// providerCatalog caches the latest release of each provider repository
+type providerCatalog struct {
+	mu       sync.Mutex
+	releases map[string]cachedRelease
+}
+
+func newProviderCatalog() *providerCatalog {
+	return &providerCatalog{releases: make(map[string]cachedRelease)}
+}
+
+// latestRelease returns the cached latest release of repository, fetching it
+// when the cache entry is missing or stale
+func (s *Server) latestRelease(ctx context.Context, repository string) (devpodRelease, error) {
+	s.catalog.mu.Lock()
+	cached, ok := s.catalog.releases[repository]
+	s.catalog.mu.Unlock()
+	if ok && time.Since(cached.fetched) < catalogTTL {
+		return cached.release, cached.err
+	}
+
+	var release devpodRelease
+	err := getGitHubJSON(ctx, s.opts.ProviderIndexURL+"/"+repository+"/releases/latest", &release)
+	if ctx.Err() == nil {
+		s.catalog.mu.Lock()
+		s.catalog.releases[repository] = cachedRelease{fetched: time.Now(), release: release, err: err}
+		s.catalog.mu.Unlock()
+	}
+	return release, err
+}
+
+// searchProviders returns the index entries matching query, case-insensitively
+// by name or description, with their latest versions and whether they are
+// already installed. Releases are looked up concurrently.
+func (s *Server) searchProviders(ctx context.Context, query string, installed map[string]bool) []catalogEntry {
+	query = strings.ToLower(strings.TrimSpace(query))
+	entries := []catalogEntry{}
+	for _, entry := range providerIndex {
+		if query != "" && !strings.Contains(entry.Name, query) && !strings.Contains(strings.ToLower(entry.Description), query) {
+			continue
+		}
+		entry.Source = entry.Name
+		if !entry.Official {
+			entry.Source = entry.Repository
+		}
+		entry.Installed = installed[entry.Name]
+		entries = append(entries, entry)
+	}
+
+	var wg sync.WaitGroup
+	for i := range entries {
+		wg.Add(1)
+		go func(entry *catalogEntry) {
+			defer wg.Done()
+			release, err := s.latestRelease(ctx, entry.Repository)
+			if err != nil {
+				entry.Error = fmt.Sprintf("failed to look up latest release: %v", err)
+				return
+			}
+			entry.LatestVersion = release.TagName
+			entry.ReleasedAt = release.PublishedAt
+			entry.ReleaseURL = release.HTMLURL
+		}(&entries[i])
+	}
+	wg.Wait()
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Official && !entries[j].Official
+	})
+	return entries
+}
+
+// registerCatalogTools registers the tool that searches the provider catalog
+func (s *Server) registerCatalogTools() {
+	// Search provider catalog
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_searchProviders",
+		Description: "Search the community index of DevPod providers for their descriptions and latest versions",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"query": map[string]interface{}{
+					"type":        "string",
+					"description": "Only return providers whose name or description contains this text (optional)",
+				},
+			},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var searchParams struct {
+			Query string `json:"query,omitempty"`
+		}
+
+		if len(params) > 0 {
+			if err := json.Unmarshal(params, &searchParams); err != nil {
+				return nil, mcp.NewInvalidParamsError("Invalid search providers parameters")
+			}
+		}
+
+		// Mark providers that are already configured; the search works without devpod too
+		installed := make(map[string]bool)
+		if output, err := s.output(ctx, []string{"provider", "list", "--output", "json"}); err == nil {
+			var providers map[string]DevPodProvider
+			if err := json.Unmarshal(output, &providers); err == nil {
+				for name := range providers {
+					installed[name] = true
+				}
+			}
+		} else {
+			log.Printf("WARNING: failed to list installed providers: %v", err)
+		}
+
+		providers := s.searchProviders(ctx, searchParams.Query, installed)
+		return map[string]interface{}{
+			"providers": providers,
+			"message":   fmt.Sprintf("Found %d provider(s); pass source as name to devpod_addProvider", len(providers)),
+		}, nil
+	})
+}
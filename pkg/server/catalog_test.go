@@ -0,0 +1,51 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestSearchProviders(t *testing.T) {
+	var requests int32
+	index := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if strings.Contains(r.URL.Path, "hetzner") {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"tag_name":"v0.1.0","html_url":"https://example.com/release","published_at":"2026-01-02T00:00:00Z"}`)
+	}))
+	defer index.Close()
+
+	s := newTestServer(t, &fakeRunner{})
+	s.opts.ProviderIndexURL = index.URL
+
+	entries := s.searchProviders(context.Background(), "cloud", map[string]bool{"gcloud": true})
+	names := make(map[string]catalogEntry)
+	for _, entry := range entries {
+		names[entry.Name] = entry
+	}
+	if len(entries) != 3 || names["gcloud"].Name == "" || names["hetzner"].Name == "" || names["ovhcloud"].Name == "" {
+		t.Fatalf("Expected gcloud, hetzner and ovhcloud, got %+v", entries)
+	}
+	if !entries[0].Official {
+		t.Error("Expected official providers first")
+	}
+	if gcloud := names["gcloud"]; !gcloud.Installed || gcloud.LatestVersion != "v0.1.0" || gcloud.Source != "gcloud" {
+		t.Errorf("Unexpected gcloud entry %+v", gcloud)
+	}
+	if hetzner := names["hetzner"]; hetzner.Error == "" || hetzner.Source != "mrsimonemms/devpod-provider-hetzner" {
+		t.Errorf("Expected a lookup error for hetzner, got %+v", hetzner)
+	}
+
+	// Releases are cached between searches
+	s.searchProviders(context.Background(), "cloud", nil)
+	if got := atomic.LoadInt32(&requests); got != 3 {
+		t.Errorf("Expected 3 index requests, got %d", got)
+	}
+}
@@ -0,0 +1,234 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+// clientCapabilities records the optional features the client announced in initialize
+type clientCapabilities struct {
+	Elicitation *struct{} `json:"elicitation,omitempty"`
+	Sampling    *struct{} `json:"sampling,omitempty"`
+	Roots       *struct {
+		ListChanged bool `json:"listChanged,omitempty"`
+	} `json:"roots,omitempty"`
+}
+
+// clientRequests tracks server-initiated requests awaiting a client response
+type clientRequests struct {
+	nextID  int64
+	mu      sync.Mutex
+	pending map[string]pendingRequest
+}
+
+// pendingRequest is a server-initiated request awaiting its response. Only
+// the session it was sent to may answer it.
+type pendingRequest struct {
+	session  string
+	response chan clientResponse
+}
+
+// clientResponse is a JSON-RPC response sent by the client
+type clientResponse struct {
+	ID     interface{}     `json:"id"`
+	Method string          `json:"method,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *mcp.RPCError   `json:"error,omitempty"`
+}
+
+func newClientRequests() *clientRequests {
+	return &clientRequests{pending: make(map[string]pendingRequest)}
+}
+
+// deliver hands a client response from a session to the waiting request and
+// reports whether the message was consumed. Requests and notifications are
+// left alone. A response from another session than the request was sent to
+// is dropped, and the request keeps waiting.
+func (c *clientRequests) deliver(session string, message []byte) bool {
+	var response clientResponse
+	if err := json.Unmarshal(message, &response); err != nil || response.Method != "" || response.ID == nil {
+		return false
+	}
+
+	key := fmt.Sprint(response.ID)
+	c.mu.Lock()
+	request, ok := c.pending[key]
+	if ok && request.session == session {
+		delete(c.pending, key)
+	}
+	c.mu.Unlock()
+	switch {
+	case !ok:
+		log.Printf("WARNING: dropping response to unknown request %s", key)
+	case request.session != session:
+		log.Printf("WARNING: dropping response to request %s from another session", key)
+	default:
+		request.response <- response
+	}
+	return true
+}
+
+// clientTransport wraps a transport so responses to server-initiated requests
+// are routed to their callers instead of the request handlers. Because it
+// reads the inner transport independently, a handler blocked on a client
+// response does not stop that response from being received. Its messages
+// come from the single client of the empty session.
+type clientTransport struct {
+	mcp.Transport
+	requests *clientRequests
+	messages chan []byte
+	// capture records the messages passing through, when enabled
+	capture *captureLog
+}
+
+func newClientTransport(t mcp.Transport, requests *clientRequests) *clientTransport {
+	return &clientTransport{
+		Transport: t,
+		requests:  requests,
+		messages:  make(chan []byte, 100),
+	}
+}
+
+// Start starts the inner transport and begins routing its messages
+func (t *clientTransport) Start(ctx context.Context) error {
+	if err := t.Transport.Start(ctx); err != nil {
+		return err
+	}
+	go t.route(ctx)
+	return nil
+}
+
+// Send sends a message through the inner transport
+func (t *clientTransport) Send(message []byte) error {
+	t.capture.record(context.Background(), captureOut, message)
+	return t.Transport.Send(message)
+}
+
+// Receive returns the messages that are not client responses
+func (t *clientTransport) Receive() <-chan []byte {
+	return t.messages
+}
+
+func (t *clientTransport) route(ctx context.Context) {
+	inner := t.Transport.Receive()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case message, ok := <-inner:
+			if !ok {
+				return
+			}
+			t.capture.record(ctx, captureIn, message)
+			if t.requests.deliver("", message) {
+				continue
+			}
+			select {
+			case t.messages <- message:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// requestClient sends a request to the client of the calling session and
+// waits for its result. On transports with several sessions the request goes
+// to that session alone, and only its answer counts.
+func (s *Server) requestClient(ctx context.Context, method string, params interface{}) (json.RawMessage, error) {
+	paramsBytes, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal params: %w", err)
+	}
+	session := SessionID(ctx)
+	sender, addressable := s.transport.(sessionSender)
+	if addressable && session == "" {
+		return nil, fmt.Errorf("no client session to send the %s request to", method)
+	}
+
+	id := fmt.Sprintf("devpod-%d", atomic.AddInt64(&s.requests.nextID, 1))
+	ch := make(chan clientResponse, 1)
+	s.requests.mu.Lock()
+	s.requests.pending[id] = pendingRequest{session: session, response: ch}
+	s.requests.mu.Unlock()
+	defer func() {
+		s.requests.mu.Lock()
+		delete(s.requests.pending, id)
+		s.requests.mu.Unlock()
+	}()
+
+	message, err := json.Marshal(mcp.JSONRPCRequest{
+		JSONRPC: mcp.JSONRPCVersion,
+		ID:      id,
+		Method:  method,
+		Params:  paramsBytes,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	s.capture.record(ctx, captureOut, message)
+	if addressable {
+		err = sender.SendTo(session, message)
+	} else {
+		err = s.transport.Send(message)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to send %s request: %w", method, err)
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case response := <-ch:
+		if response.Error != nil {
+			return nil, response.Error
+		}
+		return response.Result, nil
+	}
+}
+
+// setClientCapabilities records the capabilities from an initialize request
+// with the session
+func (s *Server) setClientCapabilities(ctx context.Context, params json.RawMessage) {
+	var initParams struct {
+		Capabilities clientCapabilities `json:"capabilities"`
+	}
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &initParams); err != nil {
+			log.Printf("WARNING: failed to parse client capabilities: %v", err)
+		}
+	}
+
+	s.sessions.update(SessionID(ctx), func(state *sessionState) {
+		state.capabilities = initParams.Capabilities
+		state.roots = rootsCache{generation: state.roots.generation + 1}
+	})
+}
+
+// setSessionClient records the clientInfo name from an initialize request
+// with the session, where policies match it
+func (s *Server) setSessionClient(ctx context.Context, params json.RawMessage) {
+	var initParams struct {
+		ClientInfo struct {
+			Name string `json:"name"`
+		} `json:"clientInfo"`
+	}
+	if err := json.Unmarshal(params, &initParams); err != nil || initParams.ClientInfo.Name == "" {
+		return
+	}
+	s.sessions.update(SessionID(ctx), func(state *sessionState) {
+		state.Client = initParams.ClientInfo.Name
+	})
+}
+
+// capabilities returns the capabilities announced by the client of the
+// calling session
+func (s *Server) capabilities(ctx context.Context) clientCapabilities {
+	return s.session(ctx).capabilities
+}
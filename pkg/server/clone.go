@@ -0,0 +1,178 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+// sourceArg returns the `devpod up` source argument that recreates a
+// workspace source. branch and commit override the recorded git revision.
+func sourceArg(source DevPodWorkspaceSource, branch, commit string) (string, error) {
+	switch {
+	case source.GitRepository != "":
+		if branch == "" && commit == "" {
+			branch, commit = source.GitBranch, source.GitCommit
+		}
+		arg := source.GitRepository
+		switch {
+		case commit != "":
+			arg += "@sha256:" + commit
+		case branch != "":
+			arg += "@" + branch
+		case source.GitPRReference != "":
+			arg += "@" + source.GitPRReference
+		}
+		return arg, nil
+	case branch != "" || commit != "":
+		return "", fmt.Errorf("branch and commit only apply to git sources")
+	case source.LocalFolder != "":
+		return source.LocalFolder, nil
+	case source.Image != "":
+		return source.Image, nil
+	}
+	return "", fmt.Errorf("workspace has no recorded source")
+}
+
+// flattenOptions converts devpod workspace options into plain string values
+func flattenOptions(options map[string]interface{}) map[string]string {
+	flat := make(map[string]string, len(options))
+	for key := range options {
+		if value := providerOptionValue(options, key); value != "" {
+			flat[key] = value
+		}
+	}
+	return flat
+}
+
+// optionArgs converts options into repeated KEY=VALUE flags in key order
+func optionArgs(flag string, options map[string]string) []string {
+	keys := make([]string, 0, len(options))
+	for key := range options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var args []string
+	for _, key := range keys {
+		args = append(args, flag, fmt.Sprintf("%s=%s", key, options[key]))
+	}
+	return args
+}
+
+// registerCloneTools registers the tool that clones workspaces
+func (s *Server) registerCloneTools() {
+	// Clone workspace
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_cloneWorkspace",
+		Description: "Create a new workspace from the same source, provider and IDE options as an existing one",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "The name of the workspace to clone",
+				},
+				"newName": map[string]interface{}{
+					"type":        "string",
+					"description": "The name of the new workspace",
+				},
+				"branch": map[string]interface{}{
+					"type":        "string",
+					"description": "Check out this branch instead of the original's (optional)",
+				},
+				"commit": map[string]interface{}{
+					"type":        "string",
+					"description": "Check out this commit instead of the original's (optional)",
+				},
+				"provider": map[string]interface{}{
+					"type":        "string",
+					"description": "Use a different provider; its options are not copied (optional)",
+				},
+				"ide": map[string]interface{}{
+					"type":        "string",
+					"description": "Use a different IDE; its options are not copied (optional)",
+				},
+			},
+			"required": []string{"name", "newName"},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var cloneParams struct {
+			Name     string `json:"name"`
+			NewName  string `json:"newName"`
+			Branch   string `json:"branch,omitempty"`
+			Commit   string `json:"commit,omitempty"`
+			Provider string `json:"provider,omitempty"`
+			IDE      string `json:"ide,omitempty"`
+		}
+
+		if err := json.Unmarshal(params, &cloneParams); err != nil {
+			return nil, mcp.NewInvalidParamsError("Invalid clone workspace parameters")
+		}
+
+		if cloneParams.Name == "" || cloneParams.NewName == "" {
+			return nil, mcp.NewInvalidParamsError("Name and newName are required")
+		}
+
+		original, err := s.findWorkspace(ctx, cloneParams.Name)
+		if err != nil {
+			return nil, newDevPodError("failed to look up workspace", err, nil)
+		}
+		if original == nil {
+			return nil, mcp.NewInvalidParamsError(fmt.Sprintf("Workspace %s not found", cloneParams.Name))
+		}
+		if exists, err := s.workspaceExists(ctx, cloneParams.NewName); err != nil {
+			return nil, newDevPodError("failed to check for existing workspace", err, nil)
+		} else if exists {
+			return nil, mcp.NewInvalidParamsError(fmt.Sprintf("Workspace %s already exists", cloneParams.NewName))
+		}
+		if err := s.opts.Names.check(cloneParams.NewName); err != nil {
+			return nil, mcp.NewInvalidParamsError(err.Error())
+		}
+
+		source, err := sourceArg(original.Source, cloneParams.Branch, cloneParams.Commit)
+		if err != nil {
+			return nil, mcp.NewInvalidParamsError(fmt.Sprintf("Cannot clone %s: %v", cloneParams.Name, err))
+		}
+
+		// Copy provider and IDE options unless the caller picks a different one
+		spec, _ := s.specFromWorkspace(ctx, *original)
+		spec.Name = cloneParams.NewName
+		if cloneParams.Provider != "" && (spec.Provider == nil || cloneParams.Provider != spec.Provider.Name) {
+			spec.Provider = &specComponent{Name: cloneParams.Provider}
+		} else if spec.Provider != nil {
+			// Secrets are left out of exports but are fine to reuse on the same machine
+			spec.Provider.Options = flattenOptions(original.Provider.Options)
+		}
+		if cloneParams.IDE != "" && (spec.IDE == nil || cloneParams.IDE != spec.IDE.Name) {
+			spec.IDE = &specComponent{Name: cloneParams.IDE}
+		}
+
+		start := time.Now()
+		output, err := s.createFromSpec(ctx, spec, source, fmt.Sprintf("Workspace cloned from %s", cloneParams.Name))
+		if err != nil {
+			return nil, withPhases(newDevPodError("failed to clone workspace", err, output), output)
+		}
+
+		result := map[string]interface{}{
+			"name":       cloneParams.NewName,
+			"clonedFrom": cloneParams.Name,
+			"source":     source,
+			"message":    "Workspace cloned successfully",
+			"output":     string(output),
+			"phases":     parsePhases(string(output), false),
+			"durationMs": durationMs(start),
+		}
+		if details, err := s.describeWorkspace(ctx, cloneParams.NewName); err == nil {
+			result["workspace"] = details
+		} else {
+			log.Printf("WARNING: failed to describe workspace %s: %v", cloneParams.NewName, err)
+		}
+		return result, nil
+	})
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestSourceArg(t *testing.T) {
+	repo := DevPodWorkspaceSource{GitRepository: "github.com/acme/api", GitBranch: "main"}
+
+	tests := []struct {
+		source         DevPodWorkspaceSource
+		branch, commit string
+		want           string
+	}{
+		{repo, "", "", "github.com/acme/api@main"},
+		{repo, "feature", "", "github.com/acme/api@feature"},
+		{repo, "", "abc123", "github.com/acme/api@sha256:abc123"},
+		{DevPodWorkspaceSource{LocalFolder: "/src/api"}, "", "", "/src/api"},
+		{DevPodWorkspaceSource{Image: "golang:1.22"}, "", "", "golang:1.22"},
+	}
+	for _, tt := range tests {
+		got, err := sourceArg(tt.source, tt.branch, tt.commit)
+		if err != nil || got != tt.want {
+			t.Errorf("sourceArg(%+v, %q, %q) = %q, %v; want %q", tt.source, tt.branch, tt.commit, got, err, tt.want)
+		}
+	}
+
+	if _, err := sourceArg(DevPodWorkspaceSource{Image: "golang"}, "main", ""); err == nil {
+		t.Error("Expected an error when requesting a branch of an image source")
+	}
+}
+
+func TestCloneWorkspaceCopiesOptions(t *testing.T) {
+	runner := &fakeRunner{outputs: map[string]string{
+		"list --output json": `[{"id":"api","source":{"gitRepository":"github.com/acme/api","gitBranch":"main"},` +
+			`"provider":{"name":"aws","options":{"AWS_INSTANCE_TYPE":{"value":"t3.large"}}},"ide":{"name":"vscode"}}]`,
+	}}
+	s := newTestServer(t, runner)
+
+	_, err := s.MCP().GetHandler("devpod_cloneWorkspace")(context.Background(), json.RawMessage(`{"name":"api","newName":"api-2","branch":"fix"}`))
+	if err != nil {
+		t.Fatalf("devpod_cloneWorkspace failed: %v", err)
+	}
+
+	want := "up github.com/acme/api@fix --id api-2 --provider-option AWS_INSTANCE_TYPE=t3.large --provider aws --ide vscode"
+	for _, call := range runner.calls {
+		if call[0] == "up" {
+			if got := strings.Join(call, " "); got != want {
+				t.Errorf("Unexpected up command:\n got %s\nwant %s", got, want)
+			}
+			return
+		}
+	}
+	t.Error("Expected devpod up to be called")
+}
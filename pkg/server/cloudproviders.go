@@ -0,0 +1,477 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+// cloudProvider describes a guided setup of a cloud provider: the local
+// credentials it checks and the options it derives from them
+type cloudProvider struct {
+	Tool     string
+	Provider string
+	Title    string
+	// CLI is the cloud's command line tool used to check credentials
+	CLI string
+	// MachineType and DiskSize (GB) are the defaults of new workspaces
+	MachineType string
+	DiskSize    int
+	// Login is the command that fixes missing or expired credentials
+	Login string
+	// Params are the tool parameters besides machineType, diskSize and options
+	Params      map[string]interface{}
+	credentials func(s *Server, ctx context.Context, args cloudArgs) (*cloudCredentials, error)
+}
+
+// cloudArgs are the arguments of the configure tools
+type cloudArgs struct {
+	Region        string            `json:"region,omitempty"`
+	Zone          string            `json:"zone,omitempty"`
+	Profile       string            `json:"profile,omitempty"`
+	Project       string            `json:"project,omitempty"`
+	Subscription  string            `json:"subscription,omitempty"`
+	ResourceGroup string            `json:"resourceGroup,omitempty"`
+	MachineType   string            `json:"machineType,omitempty"`
+	DiskSize      int               `json:"diskSize,omitempty"`
+	Options       map[string]string `json:"options,omitempty"`
+}
+
+// cloudCredentials are the local credentials a provider will use
+type cloudCredentials struct {
+	// Source is where they come from, e.g. "environment" or "profile dev"
+	Source   string `json:"source"`
+	Identity string `json:"identity,omitempty"`
+	Account  string `json:"account,omitempty"`
+	// Verified is set when the cloud's CLI confirmed them; without the CLI
+	// they are only found, not checked
+	Verified bool `json:"verified"`
+	// Options are the provider options derived from the local setup
+	Options map[string]string `json:"-"`
+	// Optional options are set only when the provider declares them
+	Optional map[string]string `json:"-"`
+}
+
+// errNoCredentials is returned when no local credentials of a cloud are found
+var errNoCredentials = errors.New("no credentials found")
+
+// cloudProviders are the providers with a configure tool
+var cloudProviders = []cloudProvider{
+	{
+		Tool:        "devpod_configureAWSProvider",
+		Provider:    "aws",
+		Title:       "AWS",
+		CLI:         "aws",
+		MachineType: "t3.xlarge",
+		DiskSize:    40,
+		Login:       "aws configure, or aws sso login for SSO profiles",
+		Params: map[string]interface{}{
+			"region": map[string]interface{}{
+				"type":        "string",
+				"description": "AWS region, e.g. eu-west-1 (default: AWS_REGION, AWS_DEFAULT_REGION or the profile's region)",
+			},
+			"profile": map[string]interface{}{
+				"type":        "string",
+				"description": "Named profile from ~/.aws to use (default: AWS_PROFILE, else the access keys in the environment or the default profile)",
+			},
+		},
+		credentials: (*Server).awsCredentials,
+	},
+	{
+		Tool:        "devpod_configureGCPProvider",
+		Provider:    "gcloud",
+		Title:       "Google Cloud",
+		CLI:         "gcloud",
+		MachineType: "e2-standard-4",
+		DiskSize:    40,
+		Login:       "gcloud auth login and gcloud auth application-default login",
+		Params: map[string]interface{}{
+			"project": map[string]interface{}{
+				"type":        "string",
+				"description": "Project ID (default: CLOUDSDK_CORE_PROJECT, GOOGLE_CLOUD_PROJECT or the gcloud configuration's project)",
+			},
+			"zone": map[string]interface{}{
+				"type":        "string",
+				"description": "Compute zone, e.g. europe-west1-b (default: CLOUDSDK_COMPUTE_ZONE or the gcloud configuration's zone)",
+			},
+		},
+		credentials: (*Server).gcpCredentials,
+	},
+	{
+		Tool:        "devpod_configureAzureProvider",
+		Provider:    "azure",
+		Title:       "Azure",
+		CLI:         "az",
+		MachineType: "Standard_D4s_v5",
+		DiskSize:    40,
+		Login:       "az login",
+		Params: map[string]interface{}{
+			"subscription": map[string]interface{}{
+				"type":        "string",
+				"description": "Subscription ID (default: AZURE_SUBSCRIPTION_ID or the az CLI's current subscription)",
+			},
+			"resourceGroup": map[string]interface{}{
+				"type":        "string",
+				"description": "Resource group to create machines in (default: AZURE_DEFAULTS_GROUP)",
+			},
+			"region": map[string]interface{}{
+				"type":        "string",
+				"description": "Azure region, e.g. westeurope (default: AZURE_DEFAULTS_LOCATION)",
+			},
+		},
+		credentials: (*Server).azureCredentials,
+	},
+}
+
+// inputSchema returns the input schema of the provider's configure tool
+func (p cloudProvider) inputSchema() map[string]interface{} {
+	properties := map[string]interface{}{
+		"machineType": map[string]interface{}{
+			"type":        "string",
+			"description": fmt.Sprintf("Machine type of new workspaces (default: %s)", p.MachineType),
+		},
+		"diskSize": map[string]interface{}{
+			"type":        "integer",
+			"description": fmt.Sprintf("Disk size of new workspaces in GB (default: %d)", p.DiskSize),
+		},
+		"options": map[string]interface{}{
+			"type":        "object",
+			"description": "Further provider options, which override the derived ones",
+		},
+	}
+	for name, param := range p.Params {
+		properties[name] = param
+	}
+	return map[string]interface{}{"type": "object", "properties": properties}
+}
+
+// cloudCLI runs a cloud's command line tool without streaming its output
+func (s *Server) cloudCLI(ctx context.Context, name string, args ...string) ([]byte, []byte, error) {
+	runner, ok := s.opts.CloudRunners[name]
+	if !ok {
+		runner = &ExecRunner{Path: name}
+	}
+	return runner.Run(WithOutputWriter(ctx, nil), args)
+}
+
+// cloudCLIValue runs a cloud CLI query and returns its trimmed output, or ""
+// when it fails or prints nothing
+func (s *Server) cloudCLIValue(ctx context.Context, name string, args ...string) string {
+	stdout, _, err := s.cloudCLI(ctx, name, args...)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(stdout))
+}
+
+// firstNonEmpty returns the first non-empty value
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// awsCredentials checks the caller identity with the aws CLI and finds the
+// region. Without the CLI, access keys in the environment or a configured
+// profile are accepted unverified.
+func (s *Server) awsCredentials(ctx context.Context, args cloudArgs) (*cloudCredentials, error) {
+	profile := firstNonEmpty(args.Profile, os.Getenv("AWS_PROFILE"))
+	withProfile := func(cliArgs ...string) []string {
+		if profile != "" {
+			cliArgs = append(cliArgs, "--profile", profile)
+		}
+		return cliArgs
+	}
+
+	creds := &cloudCredentials{Source: "profile " + firstNonEmpty(profile, "default")}
+	if profile == "" && os.Getenv("AWS_ACCESS_KEY_ID") != "" {
+		creds.Source = "environment"
+	}
+	stdout, stderr, err := s.cloudCLI(ctx, "aws", withProfile("sts", "get-caller-identity", "--output", "json")...)
+	switch {
+	case errors.Is(err, exec.ErrNotFound):
+		if creds.Source != "environment" && !awsProfileConfigured(firstNonEmpty(profile, "default")) {
+			return nil, errNoCredentials
+		}
+	case err != nil:
+		return nil, &CommandError{Err: err, Stdout: stdout, Stderr: stderr}
+	default:
+		var identity struct {
+			Account string `json:"Account"`
+			Arn     string `json:"Arn"`
+		}
+		if err := json.Unmarshal(stdout, &identity); err != nil {
+			return nil, fmt.Errorf("failed to parse caller identity: %w", err)
+		}
+		creds.Identity, creds.Account, creds.Verified = identity.Arn, identity.Account, true
+	}
+
+	region := firstNonEmpty(args.Region, os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"))
+	if region == "" && creds.Verified {
+		region = s.cloudCLIValue(ctx, "aws", withProfile("configure", "get", "region")...)
+	}
+	creds.Options = map[string]string{}
+	if region != "" {
+		creds.Options["AWS_REGION"] = region
+	}
+	if profile != "" {
+		creds.Optional = map[string]string{"AWS_PROFILE": profile}
+	}
+	return creds, nil
+}
+
+// awsProfileConfigured reports whether the shared credentials or config file
+// has a section for the profile
+func awsProfileConfigured(profile string) bool {
+	home, _ := os.UserHomeDir()
+	files := map[string]string{
+		firstNonEmpty(os.Getenv("AWS_SHARED_CREDENTIALS_FILE"), filepath.Join(home, ".aws", "credentials")): "[" + profile + "]",
+		firstNonEmpty(os.Getenv("AWS_CONFIG_FILE"), filepath.Join(home, ".aws", "config")):                  "[profile " + profile + "]",
+	}
+	for path, section := range files {
+		file, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line == section || (profile == "default" && line == "[default]") {
+				file.Close()
+				return true
+			}
+		}
+		file.Close()
+	}
+	return false
+}
+
+// gcpCredentials checks that gcloud has an active account whose credentials
+// still issue tokens and finds the project and zone. Without gcloud, a
+// service account key in GOOGLE_APPLICATION_CREDENTIALS is accepted
+// unverified.
+func (s *Server) gcpCredentials(ctx context.Context, args cloudArgs) (*cloudCredentials, error) {
+	creds := &cloudCredentials{Source: "gcloud"}
+	var keyProject string
+	_, stderr, err := s.cloudCLI(ctx, "gcloud", "auth", "print-access-token", "--quiet")
+	switch {
+	case errors.Is(err, exec.ErrNotFound):
+		path := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+		data, readErr := os.ReadFile(path)
+		if path == "" || readErr != nil {
+			return nil, errNoCredentials
+		}
+		var key struct {
+			ClientEmail string `json:"client_email"`
+			ProjectID   string `json:"project_id"`
+		}
+		if err := json.Unmarshal(data, &key); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		creds.Source, creds.Identity, keyProject = "GOOGLE_APPLICATION_CREDENTIALS", key.ClientEmail, key.ProjectID
+	case err != nil:
+		return nil, &CommandError{Err: err, Stderr: stderr}
+	default:
+		creds.Identity = s.cloudCLIValue(ctx, "gcloud", "config", "get-value", "account")
+		creds.Verified = true
+	}
+
+	project := firstNonEmpty(args.Project, os.Getenv("CLOUDSDK_CORE_PROJECT"), os.Getenv("GOOGLE_CLOUD_PROJECT"), keyProject)
+	zone := firstNonEmpty(args.Zone, os.Getenv("CLOUDSDK_COMPUTE_ZONE"))
+	if creds.Verified {
+		project = firstNonEmpty(project, s.cloudCLIValue(ctx, "gcloud", "config", "get-value", "project"))
+		zone = firstNonEmpty(zone, s.cloudCLIValue(ctx, "gcloud", "config", "get-value", "compute/zone"))
+	}
+	creds.Account = project
+	creds.Options = map[string]string{}
+	if project != "" {
+		creds.Options["PROJECT"] = project
+	}
+	if zone != "" {
+		creds.Options["ZONE"] = zone
+	}
+	return creds, nil
+}
+
+// azureCredentials checks the az CLI's login and finds the subscription,
+// resource group and region. A service principal in AZURE_CLIENT_ID,
+// AZURE_CLIENT_SECRET and AZURE_TENANT_ID is accepted unverified without the
+// CLI.
+func (s *Server) azureCredentials(ctx context.Context, args cloudArgs) (*cloudCredentials, error) {
+	creds := &cloudCredentials{Source: "az CLI"}
+	var subscription string
+	stdout, stderr, err := s.cloudCLI(ctx, "az", "account", "show", "--output", "json")
+	servicePrincipal := os.Getenv("AZURE_CLIENT_ID") != "" && os.Getenv("AZURE_CLIENT_SECRET") != "" && os.Getenv("AZURE_TENANT_ID") != ""
+	switch {
+	case err != nil && servicePrincipal:
+		creds.Source, creds.Identity = "environment", os.Getenv("AZURE_CLIENT_ID")
+	case errors.Is(err, exec.ErrNotFound):
+		return nil, errNoCredentials
+	case err != nil:
+		return nil, &CommandError{Err: err, Stdout: stdout, Stderr: stderr}
+	default:
+		var account struct {
+			ID   string `json:"id"`
+			User struct {
+				Name string `json:"name"`
+			} `json:"user"`
+		}
+		if err := json.Unmarshal(stdout, &account); err != nil {
+			return nil, fmt.Errorf("failed to parse az account: %w", err)
+		}
+		creds.Identity, subscription, creds.Verified = account.User.Name, account.ID, true
+	}
+
+	subscription = firstNonEmpty(args.Subscription, os.Getenv("AZURE_SUBSCRIPTION_ID"), subscription)
+	creds.Account = subscription
+	creds.Options = map[string]string{}
+	for option, value := range map[string]string{
+		"AZURE_SUBSCRIPTION_ID": subscription,
+		"AZURE_RESOURCE_GROUP":  firstNonEmpty(args.ResourceGroup, os.Getenv("AZURE_DEFAULTS_GROUP")),
+		"AZURE_REGION":          firstNonEmpty(args.Region, os.Getenv("AZURE_DEFAULTS_LOCATION")),
+	} {
+		if value != "" {
+			creds.Options[option] = value
+		}
+	}
+	return creds, nil
+}
+
+// credentialsError reports local cloud credentials that are missing or that
+// the cloud's CLI rejects
+func (p cloudProvider) credentialsError(err error) *mcp.RPCError {
+	data := map[string]interface{}{
+		"category": CategoryAuthFailure,
+		"provider": p.Provider,
+		"hint":     fmt.Sprintf("log in with %s, or set the credentials in the server's environment", p.Login),
+	}
+	reason := err.Error()
+	var cmdErr *CommandError
+	if errors.As(err, &cmdErr) {
+		reason = p.CLI + " rejected them"
+		_, data["stderr"] = categorizeFailure(err, nil)
+	}
+	return mcp.NewRPCError(categoryCodes[CategoryAuthFailure],
+		fmt.Sprintf("%s credentials are not usable (%s): %s", p.Title, reason, CategoryAuthFailure), data)
+}
+
+// configure checks the local credentials of the cloud and adds its provider,
+// or reconfigures it when it is installed, with the options derived from
+// them and the machine defaults
+func (p cloudProvider) configure(ctx context.Context, s *Server, params json.RawMessage) (interface{}, error) {
+	var args cloudArgs
+	if err := json.Unmarshal(params, &args); err != nil {
+		return nil, mcp.NewInvalidParamsError(fmt.Sprintf("Invalid %s parameters", p.Tool))
+	}
+	if args.DiskSize < 0 {
+		return nil, mcp.NewInvalidParamsError("diskSize must not be negative")
+	}
+
+	start := time.Now()
+	creds, err := p.credentials(s, ctx, args)
+	if err != nil {
+		return nil, p.credentialsError(err)
+	}
+
+	mapping := resourceOptions[p.Provider]
+	options := map[string]string{
+		mapping.MachineType: firstNonEmpty(args.MachineType, p.MachineType),
+		mapping.DiskSize:    strconv.Itoa(p.DiskSize),
+	}
+	if args.DiskSize > 0 {
+		options[mapping.DiskSize] = strconv.Itoa(args.DiskSize)
+	}
+	for key, value := range creds.Options {
+		options[key] = value
+	}
+	derived := make(map[string]string, len(options))
+	for key, value := range options {
+		derived[key] = value
+	}
+	for key, value := range args.Options {
+		options[key] = value
+	}
+
+	installed, err := s.installedProviders(ctx)
+	if err != nil {
+		return nil, newDevPodError("failed to list providers", err, nil)
+	}
+	_, update := installed[p.Provider]
+	output, elicited, err := s.setupProvider(ctx, providerSetup{
+		Name:      p.Provider,
+		Options:   options,
+		Optional:  creds.Optional,
+		Elicit:    s.capabilities(ctx).Elicitation != nil,
+		Installed: update,
+	})
+	s.providerSchemaCache.invalidate()
+	if err != nil {
+		var cmdErr *CommandError
+		switch {
+		case errors.Is(err, errElicitationDeclined), errors.Is(err, errSecretOption), errors.Is(err, errInvalidOption):
+			return nil, mcp.NewInvalidParamsError(fmt.Sprintf("Provider %s was not configured: %v", p.Provider, err))
+		case errors.As(err, &cmdErr):
+			return nil, newDevPodError(fmt.Sprintf("failed to configure provider %s", p.Provider), err, output)
+		default:
+			return nil, fmt.Errorf("failed to configure provider %s: %w", p.Provider, err)
+		}
+	}
+
+	names := make([]string, 0, len(args.Options))
+	for key := range args.Options {
+		names = append(names, key)
+	}
+	sort.Strings(names)
+	action := "added"
+	if update {
+		action = "reconfigured"
+	}
+	result := map[string]interface{}{
+		"name":        p.Provider,
+		"added":       !update,
+		"credentials": creds,
+		"options":     derived,
+		"message":     fmt.Sprintf("Provider %s %s and made the default, using %s credentials from %s", p.Provider, action, p.Title, creds.Source),
+		"output":      string(output),
+		"durationMs":  durationMs(start),
+	}
+	if len(names) > 0 {
+		result["extraOptions"] = names
+	}
+	if len(elicited) > 0 {
+		result["elicitedOptions"] = elicited
+	}
+	if !creds.Verified {
+		result["warning"] = fmt.Sprintf("%s is not installed, so the credentials were found but not checked", p.CLI)
+	}
+	return result, nil
+}
+
+// registerCloudProviderTools registers a guided setup tool for each cloud
+// provider
+func (s *Server) registerCloudProviderTools() {
+	// Guided setup of cloud providers from local credentials
+	for _, provider := range cloudProviders {
+		provider := provider
+		s.RegisterTool(mcp.Tool{
+			Name:        provider.Tool,
+			Description: fmt.Sprintf("Set up the %s provider in one call: check the local %s credentials (environment or %s CLI login), derive the provider options from them, default new workspaces to %s machines with %d GB disks, and add the provider as the default, or reconfigure it when it is installed", provider.Provider, provider.Title, provider.CLI, provider.MachineType, provider.DiskSize),
+			InputSchema: provider.inputSchema(),
+		}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+			return provider.configure(ctx, s, params)
+		})
+	}
+}
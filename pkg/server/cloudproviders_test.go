@@ -0,0 +1,127 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+	"github.com/protobomb/mcp-server-framework/pkg/transport"
+)
+
+// missingCLI fails like an ExecRunner whose binary is not installed
+type missingCLI struct{}
+
+func (missingCLI) Run(ctx context.Context, args []string) ([]byte, []byte, error) {
+	return nil, nil, binaryNotFound(runtime.GOOS, "cli", exec.ErrNotFound)
+}
+
+// clearCloudEnv unsets the variables the configure tools read
+func clearCloudEnv(t *testing.T) {
+	t.Helper()
+	for _, name := range []string{
+		"AWS_PROFILE", "AWS_REGION", "AWS_DEFAULT_REGION", "AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY",
+		"CLOUDSDK_CORE_PROJECT", "GOOGLE_CLOUD_PROJECT", "CLOUDSDK_COMPUTE_ZONE", "GOOGLE_APPLICATION_CREDENTIALS",
+		"AZURE_CLIENT_ID", "AZURE_CLIENT_SECRET", "AZURE_TENANT_ID", "AZURE_SUBSCRIPTION_ID", "AZURE_DEFAULTS_GROUP", "AZURE_DEFAULTS_LOCATION",
+	} {
+		t.Setenv(name, "")
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(home, "credentials"))
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(home, "config"))
+}
+
+func TestConfigureAWSProvider(t *testing.T) {
+	clearCloudEnv(t)
+	t.Setenv("AWS_REGION", "eu-west-1")
+	runner := &fakeRunner{outputs: map[string]string{
+		"provider list --output json":        `{"docker":{}}`,
+		"provider options aws --output json": `{"AWS_REGION":{"required":true},"AWS_INSTANCE_TYPE":{"default":"c5.xlarge"},"AWS_DISK_SIZE":{"default":"80"}}`,
+	}}
+	aws := &fakeRunner{
+		outputs:  map[string]string{"sts get-caller-identity --output json": `{"Account":"123456789012","Arn":"arn:aws:iam::123456789012:user/dev"}`},
+		failures: map[string]string{"sts get-caller-identity --output json --profile expired": "Error when retrieving token from sso: Token has expired and refresh failed"},
+	}
+	s := New(transport.NewSTDIOTransport(), Options{
+		Runner:       runner,
+		StatePath:    filepath.Join(t.TempDir(), "state.json"),
+		CloudRunners: map[string]Runner{"aws": aws},
+	})
+	configure := s.MCP().GetHandler("devpod_configureAWSProvider")
+
+	result, err := configure(context.Background(), json.RawMessage(`{"machineType":"m6i.large"}`))
+	if err != nil {
+		t.Fatalf("devpod_configureAWSProvider failed: %v", err)
+	}
+	data := result.(map[string]interface{})
+	if creds := data["credentials"].(*cloudCredentials); !creds.Verified || creds.Account != "123456789012" || creds.Source != "profile default" {
+		t.Errorf("Unexpected credentials %+v", creds)
+	}
+	if data["added"] != true || fmt.Sprint(data["options"]) != "map[AWS_DISK_SIZE:40 AWS_INSTANCE_TYPE:m6i.large AWS_REGION:eu-west-1]" {
+		t.Errorf("Unexpected result %v", data)
+	}
+	calls := fmt.Sprint(runner.calls)
+	if !strings.Contains(calls, "[provider add aws --use=false]") || !strings.Contains(calls, "[provider use aws -o AWS_DISK_SIZE=40 -o AWS_INSTANCE_TYPE=m6i.large -o AWS_REGION=eu-west-1]") {
+		t.Errorf("Expected the provider to be added and configured, got calls %v", calls)
+	}
+
+	// Rejected credentials fail before devpod is touched
+	runner.calls = nil
+	_, err = configure(context.Background(), json.RawMessage(`{"profile":"expired"}`))
+	var rpcErr *mcp.RPCError
+	if !errors.As(err, &rpcErr) || rpcErr.Code != categoryCodes[CategoryAuthFailure] || !strings.Contains(fmt.Sprint(rpcErr.Data), "Token has expired") {
+		t.Errorf("Expected an AuthFailure with aws's error, got %v", err)
+	}
+	if len(runner.calls) != 0 {
+		t.Errorf("Expected no devpod commands, got %v", runner.calls)
+	}
+}
+
+func TestConfigureProviderWithoutCLI(t *testing.T) {
+	clearCloudEnv(t)
+	runner := &fakeRunner{outputs: map[string]string{
+		"provider list --output json":           `{"gcloud":{}}`,
+		"provider options gcloud --output json": `{"PROJECT":{"required":true},"ZONE":{"required":true,"value":"europe-west1-b"},"MACHINE_TYPE":{},"DISK_SIZE":{}}`,
+		"provider options azure --output json":  `{}`,
+	}}
+	s := New(transport.NewSTDIOTransport(), Options{
+		Runner:       runner,
+		StatePath:    filepath.Join(t.TempDir(), "state.json"),
+		CloudRunners: map[string]Runner{"gcloud": missingCLI{}, "az": missingCLI{}},
+	})
+	ctx := context.Background()
+
+	var rpcErr *mcp.RPCError
+	if _, err := s.MCP().GetHandler("devpod_configureAzureProvider")(ctx, json.RawMessage(`{}`)); !errors.As(err, &rpcErr) || !strings.Contains(rpcErr.Message, "no credentials found") {
+		t.Errorf("Expected missing Azure credentials to be reported, got %v", err)
+	}
+
+	key := filepath.Join(t.TempDir(), "key.json")
+	if err := os.WriteFile(key, []byte(`{"type":"service_account","client_email":"devpod@acme.iam.gserviceaccount.com","project_id":"acme-dev"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", key)
+	result, err := s.MCP().GetHandler("devpod_configureGCPProvider")(ctx, json.RawMessage(`{"diskSize":100}`))
+	if err != nil {
+		t.Fatalf("devpod_configureGCPProvider failed: %v", err)
+	}
+	data := result.(map[string]interface{})
+	if creds := data["credentials"].(*cloudCredentials); creds.Verified || creds.Identity != "devpod@acme.iam.gserviceaccount.com" {
+		t.Errorf("Unexpected credentials %+v", creds)
+	}
+	if data["added"] != false || data["warning"] == nil {
+		t.Errorf("Expected the installed provider to be reconfigured with a warning, got %v", data)
+	}
+	calls := fmt.Sprint(runner.calls)
+	if strings.Contains(calls, "provider add") || !strings.Contains(calls, "[provider use gcloud -o DISK_SIZE=100 -o MACHINE_TYPE=e2-standard-4 -o PROJECT=acme-dev]") {
+		t.Errorf("Expected the installed provider to be reconfigured, got calls %v", calls)
+	}
+}
@@ -0,0 +1,162 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+// maxCompletionValues is the most values a completion/complete result
+// carries, as the protocol allows
+const maxCompletionValues = 100
+
+// Sources of argument completions
+const (
+	completeWorkspaces   = "workspaces"
+	completeProviders    = "providers"
+	completeEnvironments = "environments"
+	completeSecrets      = "secrets"
+)
+
+// argumentCompletions maps tool arguments to the values they complete from.
+// Arguments not listed complete from argumentDefaults.
+var argumentCompletions = map[string]map[string]string{
+	// names of things that do not exist yet
+	"devpod_createWorkspace":     {"name": ""},
+	"devpod_composeDevcontainer": {"name": ""},
+	"devpod_importWorkspace":     {"name": ""},
+	"devpod_createEnvironment":   {"name": ""},
+	"devpod_addProvider":         {"name": ""},
+	"devpod_setSecret":           {"name": ""},
+
+	"devpod_deleteEnvironment":  {"name": completeEnvironments},
+	"devpod_setProviderOptions": {"name": completeProviders},
+	"devpod_deleteSecret":       {"name": completeSecrets},
+}
+
+// argumentDefaults are the completions of arguments by name in every tool
+var argumentDefaults = map[string]string{
+	"name":       completeWorkspaces,
+	"workspace":  completeWorkspaces,
+	"workspaces": completeWorkspaces,
+	"provider":   completeProviders,
+}
+
+// completionRef is what completion/complete completes an argument of
+type completionRef struct {
+	// Type is ref/resource, ref/prompt, or ref/tool for tool arguments
+	Type string `json:"type"`
+	Name string `json:"name"`
+	URI  string `json:"uri"`
+}
+
+// completionSource returns the source of the values of an argument, or ""
+// when the argument has none
+func completionSource(ref completionRef, argument string) string {
+	switch ref.Type {
+	case "ref/tool":
+		tool := ref.Name
+		if current, ok := strings.CutPrefix(tool, legacyToolPrefix); ok {
+			tool = "devpod_" + current
+		}
+		if source, ok := argumentCompletions[tool][argument]; ok {
+			return source
+		}
+		if strings.HasPrefix(tool, "devpod_") {
+			return argumentDefaults[argument]
+		}
+	case "ref/resource":
+		if ref.URI == timelineURI("{name}") && argument == "name" {
+			return completeWorkspaces
+		}
+	}
+	return ""
+}
+
+// completionValues returns the current values of a source. Failures leave
+// the values out rather than failing the completion.
+func (s *Server) completionValues(ctx context.Context, source string) []string {
+	seen := make(map[string]bool)
+	switch source {
+	case completeWorkspaces:
+		if workspaces, err := s.listWorkspaces(ctx); err == nil {
+			for _, workspace := range workspaces {
+				seen[workspace.ID] = true
+			}
+		} else {
+			log.Printf("WARNING: failed to list workspaces for completion: %v", err)
+		}
+	case completeProviders:
+		output, err := s.output(ctx, []string{"provider", "list", "--output", "json"})
+		var names map[string]bool
+		if err == nil {
+			names, err = providerNames(output)
+		}
+		if err != nil {
+			log.Printf("WARNING: failed to list providers for completion: %v", err)
+		}
+		seen = names
+	case completeEnvironments:
+		for _, env := range s.state(ctx).Environments() {
+			seen[env.Name] = true
+		}
+	case completeSecrets:
+		for _, secret := range s.state(ctx).Secrets() {
+			seen[secret.Name] = true
+		}
+	}
+
+	values := make([]string, 0, len(seen))
+	for value := range seen {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+	return values
+}
+
+// complete answers a completion/complete request with the values of the
+// argument that start with what the user typed so far, ignoring case
+func (s *Server) complete(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var completeParams struct {
+		Ref      completionRef `json:"ref"`
+		Argument struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		} `json:"argument"`
+	}
+	if err := json.Unmarshal(params, &completeParams); err != nil {
+		return nil, mcp.NewInvalidParamsError("Invalid completion parameters")
+	}
+	switch completeParams.Ref.Type {
+	case "ref/tool", "ref/resource", "ref/prompt":
+	default:
+		return nil, mcp.NewInvalidParamsError(fmt.Sprintf("Unsupported completion reference type %q", completeParams.Ref.Type))
+	}
+
+	matches := []string{}
+	if source := completionSource(completeParams.Ref, completeParams.Argument.Name); source != "" {
+		prefix := strings.ToLower(completeParams.Argument.Value)
+		for _, value := range s.completionValues(ctx, source) {
+			if strings.HasPrefix(strings.ToLower(value), prefix) {
+				matches = append(matches, value)
+			}
+		}
+	}
+
+	total := len(matches)
+	if total > maxCompletionValues {
+		matches = matches[:maxCompletionValues]
+	}
+	return map[string]interface{}{
+		"completion": map[string]interface{}{
+			"values":  matches,
+			"total":   total,
+			"hasMore": total > maxCompletionValues,
+		},
+	}, nil
+}
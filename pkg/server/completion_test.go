@@ -0,0 +1,47 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestCompleteArguments(t *testing.T) {
+	runner := &fakeRunner{outputs: map[string]string{
+		"list --output json":          `[{"id":"web"},{"id":"worker"},{"id":"api"}]`,
+		"provider list --output json": `{"docker":{},"digitalocean":{},"aws":{}}`,
+	}}
+	s := newTestServer(t, runner)
+	s.store.SetEnvironment(environment{Name: "staging", Workspaces: []string{"web"}})
+
+	tests := []struct {
+		params string
+		want   string
+	}{
+		{`{"ref":{"type":"ref/tool","name":"devpod_startWorkspace"},"argument":{"name":"name","value":"W"}}`, "[web worker]"},
+		{`{"ref":{"type":"ref/tool","name":"devpod.ssh"},"argument":{"name":"name","value":""}}`, "[api web worker]"},
+		{`{"ref":{"type":"ref/tool","name":"devpod_createWorkspace"},"argument":{"name":"provider","value":"d"}}`, "[digitalocean docker]"},
+		{`{"ref":{"type":"ref/tool","name":"devpod_setProviderOptions"},"argument":{"name":"name","value":"a"}}`, "[aws]"},
+		{`{"ref":{"type":"ref/tool","name":"devpod_deleteEnvironment"},"argument":{"name":"name","value":""}}`, "[staging]"},
+		{`{"ref":{"type":"ref/tool","name":"devpod_createWorkspace"},"argument":{"name":"name","value":"w"}}`, "[]"},
+		{`{"ref":{"type":"ref/resource","uri":"devpod://workspace/{name}/timeline"},"argument":{"name":"name","value":"a"}}`, "[api]"},
+		{`{"ref":{"type":"ref/prompt","name":"setup"},"argument":{"name":"name","value":""}}`, "[]"},
+	}
+	complete := s.MCP().GetHandler("completion/complete")
+	for _, tt := range tests {
+		result, err := complete(context.Background(), json.RawMessage(tt.params))
+		if err != nil {
+			t.Errorf("completion/complete %s failed: %v", tt.params, err)
+			continue
+		}
+		completion := result.(map[string]interface{})["completion"].(map[string]interface{})
+		if values := fmt.Sprint(completion["values"]); values != tt.want || completion["hasMore"] != false {
+			t.Errorf("completion/complete %s = %v; want %s", tt.params, completion, tt.want)
+		}
+	}
+
+	if _, err := complete(context.Background(), json.RawMessage(`{"ref":{"type":"ref/unknown"},"argument":{"name":"name"}}`)); err == nil {
+		t.Error("Expected unknown reference types to be rejected")
+	}
+}
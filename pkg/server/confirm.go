@@ -0,0 +1,182 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// confirmTimeout bounds how long a destructive call waits for the user to
+// confirm it
+const confirmTimeout = 5 * time.Minute
+
+// destructiveAction describes in words what a call PolicyOptions.Confirm
+// asks the user about would delete or replace, or returns "" when the call,
+// with its arguments, deletes or replaces nothing
+func (s *Server) destructiveAction(ctx context.Context, tool string, args map[string]interface{}) string {
+	switch tool {
+	case "devpod_deleteWorkspace":
+		return fmt.Sprintf("delete workspace %v", args["name"])
+	case "devpod_deleteEnvironment":
+		return fmt.Sprintf("delete environment %v and all of its workspaces", args["name"])
+	case "devpod_deleteSecret":
+		return fmt.Sprintf("delete secret %v", args["name"])
+	case "devpod_deletePrebuild":
+		return fmt.Sprintf("cancel and forget prebuild %v", args["id"])
+	case "devpod_removeSSHHost":
+		return fmt.Sprintf("forget SSH host %v", args["name"])
+	case "devpod_cancelSchedule":
+		return fmt.Sprintf("cancel schedule %v", args["id"])
+	case "devpod_createWorkspace":
+		if args["ifExists"] == "recreate" {
+			return fmt.Sprintf("recreate workspace %v, discarding its container and everything outside the workspace folder", args["name"])
+		}
+	case "devpod_importWorkspace":
+		// A spec may come from anywhere, and its provider options are
+		// applied to the provider
+		spec, _ := args["spec"].(map[string]interface{})
+		name := args["name"]
+		if name == nil || name == "" {
+			name = spec["name"]
+		}
+		if provider, _ := spec["provider"].(map[string]interface{}); len(provider) > 0 {
+			if options, _ := provider["options"].(map[string]interface{}); len(options) > 0 {
+				return fmt.Sprintf("import workspace %v from a spec, setting %d option(s) of provider %v from it", name, len(options), provider["name"])
+			}
+		}
+		return fmt.Sprintf("import workspace %v from a spec", name)
+	case "devpod_undoLastOperation":
+		id, _ := args["id"].(string)
+		name, _ := args["name"].(string)
+		op, err := s.operationToUndo(ctx, id, name)
+		if err != nil {
+			return "undo the most recent operation"
+		}
+		switch op.Event {
+		case "deleted":
+			return fmt.Sprintf("undo %s by recreating workspace %s from its recorded spec, tags and note", op.ID, op.Workspace)
+		case "started":
+			return fmt.Sprintf("undo %s by stopping workspace %s", op.ID, op.Workspace)
+		}
+		return fmt.Sprintf("undo %s (%s %s)", op.ID, op.Event, op.Workspace)
+	case "devpod_scheduleOperation":
+		if args["action"] == "delete" {
+			return fmt.Sprintf("schedule workspace %v to be deleted", args["name"])
+		}
+	case "devpod_gcWorkspaces":
+		policy, _ := args["policy"].(string)
+		if policy == "" {
+			policy = s.opts.GCPolicy
+		}
+		if policy == "delete" && args["dryRun"] != true {
+			maxIdle, _ := args["maxIdle"].(string)
+			if maxIdle == "" {
+				maxIdle = formatDuration(s.opts.GCMaxIdle)
+			}
+			return fmt.Sprintf("delete every workspace unused for longer than %s", maxIdle)
+		}
+	case "devpod_snapshotWorkspace":
+		if image, _ := args["image"].(string); image != "" {
+			return fmt.Sprintf("commit workspace %v to image %s, replacing any image with that reference", args["name"], image)
+		}
+	case "devpod_composeDevcontainer":
+		if args["overwrite"] == true {
+			return fmt.Sprintf("overwrite the devcontainer.json in %v", args["path"])
+		}
+	case "devpod_syncDirectory":
+		if args["delete"] == true && args["dryRun"] != true {
+			if args["direction"] == syncPull {
+				return fmt.Sprintf("delete files in %v that are not in %v of workspace %v", args["localPath"], args["remotePath"], args["name"])
+			}
+			return fmt.Sprintf("delete files in %v of workspace %v that are not in %v", args["remotePath"], args["name"], args["localPath"])
+		}
+	case "devpod_fetchArtifact":
+		if args["overwrite"] == true && args["localPath"] != nil {
+			return fmt.Sprintf("overwrite %v with %v from workspace %v", args["localPath"], args["path"], args["name"])
+		}
+	case "devpod_copyBetweenWorkspaces":
+		if args["overwrite"] == true {
+			return fmt.Sprintf("overwrite %v in workspace %v with %v from workspace %v", args["destinationPath"], args["destination"], args["path"], args["source"])
+		}
+	case "devpod_exportLogsBundle":
+		if path, _ := args["path"].(string); path != "" {
+			if dir, err := s.resolveLocalSource(ctx, filepath.Dir(path)); err == nil {
+				if _, err := os.Stat(filepath.Join(dir, filepath.Base(path))); err == nil {
+					return fmt.Sprintf("overwrite %s with the logs bundle", path)
+				}
+			}
+		}
+	case "devpod_setSecret":
+		for _, secret := range s.state(ctx).Secrets() {
+			if secret.Name == args["name"] {
+				return fmt.Sprintf("replace the value of secret %v", args["name"])
+			}
+		}
+	case "devpod_installCLI":
+		return "install the devpod CLI into the managed directory, replacing any binary there"
+	}
+	return ""
+}
+
+// confirmDestructive asks the user to confirm a destructive action through
+// elicitation, which the client shows to the user. Sampling is not used: its
+// reply comes from a model, not the user. The question goes to the calling
+// session alone, and only its answer counts. Anything but an explicit yes,
+// including a client without elicitation, denies the call.
+func (s *Server) confirmDestructive(ctx context.Context, input policyInput, action string) policyDecision {
+	denied := func(reason string) policyDecision {
+		return policyDecision{Reason: reason, Source: "confirmation", Rule: -1}
+	}
+	if s.capabilities(ctx).Elicitation == nil {
+		return denied(fmt.Sprintf("confirmation required to %s, but the client does not support elicitation", action))
+	}
+	ctx, cancel := context.WithTimeout(ctx, confirmTimeout)
+	defer cancel()
+
+	confirmed, err := s.elicitConfirmation(ctx, input.Tool, action)
+	if err != nil {
+		s.reportUserEvent(UserName(ctx), "warning", "confirmation", fmt.Errorf("failed to confirm %s: %w", input.Tool, err))
+		return denied(fmt.Sprintf("could not confirm to %s: %v", action, err))
+	}
+	if !confirmed {
+		infof("The user declined to %s (%s)", action, input.Tool)
+		return denied("the user declined to " + action)
+	}
+	infof("The user confirmed to %s (%s)", action, input.Tool)
+	return policyDecision{Allow: true, Source: "confirmation", Rule: -1}
+}
+
+// elicitConfirmation asks the user a yes/no question with elicitation/create
+func (s *Server) elicitConfirmation(ctx context.Context, tool, action string) (bool, error) {
+	raw, err := s.requestClient(ctx, "elicitation/create", map[string]interface{}{
+		"message": fmt.Sprintf("%s is about to %s. This cannot be undone.", tool, action),
+		"requestedSchema": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"confirm": map[string]interface{}{
+					"type":        "boolean",
+					"title":       "Confirm",
+					"description": fmt.Sprintf("Yes, %s", action),
+				},
+			},
+			"required": []string{"confirm"},
+		},
+	})
+	if err != nil {
+		return false, err
+	}
+
+	var result struct {
+		Action  string `json:"action"`
+		Content struct {
+			Confirm bool `json:"confirm"`
+		} `json:"content"`
+	}
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return false, fmt.Errorf("failed to parse elicitation result: %w", err)
+	}
+	return result.Action == "accept" && result.Content.Confirm, nil
+}
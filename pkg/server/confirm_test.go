@@ -0,0 +1,223 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+	"github.com/protobomb/mcp-server-framework/pkg/transport"
+)
+
+func TestDestructiveAction(t *testing.T) {
+	s := newTestServer(t, &fakeRunner{})
+	s.store.SetSecret(storedSecret{Name: "db"})
+	for _, tc := range []struct {
+		tool string
+		args map[string]interface{}
+		want string
+	}{
+		{"devpod_deleteWorkspace", map[string]interface{}{"name": "api"}, "delete workspace api"},
+		{"devpod_createWorkspace", map[string]interface{}{"name": "api", "ifExists": "recreate"}, "recreate workspace api"},
+		{"devpod_createWorkspace", map[string]interface{}{"name": "api", "ifExists": "start"}, ""},
+		{"devpod_gcWorkspaces", map[string]interface{}{"policy": "delete", "maxIdle": "12h"}, "delete every workspace unused for longer than 12h"},
+		{"devpod_gcWorkspaces", map[string]interface{}{"policy": "delete", "dryRun": true}, ""},
+		{"devpod_gcWorkspaces", map[string]interface{}{}, ""},
+		{"devpod_stopWorkspace", map[string]interface{}{"name": "api"}, ""},
+		{"devpod_scheduleOperation", map[string]interface{}{"name": "api", "action": "delete", "idleFor": "7d"}, "schedule workspace api to be deleted"},
+		{"devpod_scheduleOperation", map[string]interface{}{"name": "api", "action": "stop", "cron": "0 19 * * *"}, ""},
+		{"devpod_undoLastOperation", map[string]interface{}{}, "undo the most recent operation"},
+		{"devpod_importWorkspace", map[string]interface{}{"spec": map[string]interface{}{"name": "api", "provider": map[string]interface{}{"name": "aws", "options": map[string]interface{}{"AWS_REGION": "us-east-1"}}}}, "import workspace api from a spec, setting 1 option(s) of provider aws"},
+		{"devpod_importWorkspace", map[string]interface{}{"name": "copy", "spec": map[string]interface{}{"name": "api"}}, "import workspace copy from a spec"},
+		{"devpod_syncDirectory", map[string]interface{}{"name": "api", "localPath": "out", "remotePath": "/w/out", "direction": "pull", "delete": true}, "delete files in out that are not in /w/out"},
+		{"devpod_syncDirectory", map[string]interface{}{"name": "api", "delete": true, "dryRun": true}, ""},
+		{"devpod_fetchArtifact", map[string]interface{}{"name": "api", "path": "bin/app", "localPath": "app", "overwrite": true}, "overwrite app with bin/app"},
+		{"devpod_fetchArtifact", map[string]interface{}{"name": "api", "path": "bin/app", "localPath": "app"}, ""},
+		{"devpod_setSecret", map[string]interface{}{"name": "new", "value": "x"}, ""},
+		{"devpod_setSecret", map[string]interface{}{"name": "db", "value": "x"}, "replace the value of secret db"},
+	} {
+		if got := s.destructiveAction(context.Background(), tc.tool, tc.args); !strings.HasPrefix(got, tc.want) || (tc.want == "") != (got == "") {
+			t.Errorf("%s %v: expected %q, got %q", tc.tool, tc.args, tc.want, got)
+		}
+	}
+}
+
+// confirmedArgs are arguments under which each destructive tool deletes or
+// replaces something
+var confirmedArgs = map[string]map[string]interface{}{
+	"devpod_createWorkspace":       {"name": "api", "ifExists": "recreate"},
+	"devpod_composeDevcontainer":   {"path": "api", "overwrite": true},
+	"devpod_gcWorkspaces":          {"policy": "delete"},
+	"devpod_scheduleOperation":     {"name": "api", "action": "delete"},
+	"devpod_snapshotWorkspace":     {"name": "api", "image": "registry.example.com/api:snap"},
+	"devpod_syncDirectory":         {"name": "api", "delete": true},
+	"devpod_fetchArtifact":         {"name": "api", "localPath": "app", "overwrite": true},
+	"devpod_copyBetweenWorkspaces": {"source": "a", "destination": "b", "overwrite": true},
+	"devpod_exportLogsBundle":      {"path": "existing.tar.gz"},
+}
+
+// commandTools run the client's commands, which the server cannot judge
+var commandTools = map[string]bool{"devpod_ssh": true, "devpod_execTask": true, "devpod_runTests": true, "devpod_killProcess": true}
+
+func TestEveryDestructiveToolIsConfirmed(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "existing.tar.gz"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	s := New(transport.NewSTDIOTransport(), Options{Runner: &fakeRunner{}, StatePath: filepath.Join(t.TempDir(), "state.json"), WorkspaceRoot: dir})
+	for tool, annotations := range toolAnnotations {
+		if !annotations.DestructiveHint || commandTools[tool] {
+			continue
+		}
+		args := confirmedArgs[tool]
+		if args == nil {
+			args = map[string]interface{}{"name": "api", "id": "op-1"}
+		}
+		if s.destructiveAction(context.Background(), tool, args) == "" {
+			t.Errorf("Expected %s with %v to need confirmation", tool, args)
+		}
+	}
+}
+
+func TestConfirmDestructive(t *testing.T) {
+	runner := &fakeRunner{}
+	clientIn, serverOut := io.Pipe()
+	serverIn, clientOut := io.Pipe()
+	s := New(transport.NewSTDIOTransportWithIO(serverIn, serverOut), Options{
+		Runner:    runner,
+		StatePath: filepath.Join(t.TempDir(), "state.json"),
+		Policy:    PolicyOptions{Confirm: true},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := s.Start(ctx); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer s.Stop()
+
+	// Act as a client answering each confirmation request with the next reply
+	replies := make(chan interface{}, 1)
+	methods := make(chan string, 10)
+	go func() {
+		scanner := bufio.NewScanner(clientIn)
+		for scanner.Scan() {
+			var request struct {
+				ID     interface{} `json:"id"`
+				Method string      `json:"method"`
+			}
+			if json.Unmarshal(scanner.Bytes(), &request) != nil || (request.Method != "elicitation/create" && request.Method != "sampling/createMessage") {
+				continue
+			}
+			methods <- request.Method
+			response, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": request.ID, "result": <-replies})
+			clientOut.Write(append(response, '\n'))
+		}
+	}()
+
+	deleteWorkspace := s.MCP().GetHandler("devpod_deleteWorkspace")
+	deleted := func() bool {
+		runner.mu.Lock()
+		defer runner.mu.Unlock()
+		for _, call := range runner.calls {
+			if call[0] == "delete" {
+				return true
+			}
+		}
+		return false
+	}
+	denied := func(err error, reason string) {
+		t.Helper()
+		var rpcErr *mcp.RPCError
+		if !errors.As(err, &rpcErr) || rpcErr.Code != categoryCodes[CategoryPermissionDenied] || !strings.Contains(rpcErr.Message, reason) {
+			t.Errorf("Expected the call to be denied because %s, got %v", reason, err)
+		}
+		if deleted() {
+			t.Fatal("Expected nothing to be deleted")
+		}
+	}
+
+	_, err := deleteWorkspace(ctx, json.RawMessage(`{"name":"api"}`))
+	denied(err, "does not support elicitation")
+
+	s.setClientCapabilities(ctx, json.RawMessage(`{"capabilities":{"elicitation":{}}}`))
+	replies <- map[string]interface{}{"action": "accept", "content": map[string]interface{}{"confirm": false}}
+	_, err = deleteWorkspace(ctx, json.RawMessage(`{"name":"api"}`))
+	denied(err, "the user declined to delete workspace api")
+	if method := <-methods; method != "elicitation/create" {
+		t.Errorf("Expected elicitation, got %s", method)
+	}
+
+	// Calls that destroy nothing are not asked about
+	if _, err := s.MCP().GetHandler("devpod_stopWorkspace")(ctx, json.RawMessage(`{"name":"api"}`)); err != nil {
+		t.Fatalf("devpod_stopWorkspace failed: %v", err)
+	}
+
+	// A model's reply through sampling is not the user's approval
+	s.setClientCapabilities(ctx, json.RawMessage(`{"capabilities":{"sampling":{}}}`))
+	_, err = deleteWorkspace(ctx, json.RawMessage(`{"name":"api"}`))
+	denied(err, "does not support elicitation")
+
+	s.setClientCapabilities(ctx, json.RawMessage(`{"capabilities":{"elicitation":{},"sampling":{}}}`))
+	replies <- map[string]interface{}{"action": "accept", "content": map[string]interface{}{"confirm": true}}
+	if _, err := deleteWorkspace(ctx, json.RawMessage(`{"name":"api"}`)); err != nil {
+		t.Fatalf("Expected the confirmed delete to run, got %v", err)
+	}
+	if method := <-methods; method != "elicitation/create" || !deleted() {
+		t.Errorf("Expected the workspace deleted after elicitation, got %s and calls %v", method, runner.calls)
+	}
+	if len(methods) > 0 {
+		t.Errorf("Expected sampling never to be asked, got %s", <-methods)
+	}
+}
+
+func TestConfirmationOnlyCountsTheCallingSession(t *testing.T) {
+	runner := &fakeRunner{outputs: map[string]string{"delete api": ""}}
+	transport := &queueTransport{messages: make(chan sessionMessage, 10)}
+	s := New(transport, Options{
+		Runner:    runner,
+		StatePath: filepath.Join(t.TempDir(), "state.json"),
+		Policy:    PolicyOptions{Confirm: true},
+	})
+	a := WithSessionID(context.Background(), "a")
+	s.setClientCapabilities(a, json.RawMessage(`{"capabilities":{"elicitation":{}}}`))
+	s.setClientCapabilities(WithSessionID(context.Background(), "b"), json.RawMessage(`{"capabilities":{"elicitation":{}}}`))
+
+	results := make(chan error, 1)
+	go func() {
+		_, err := s.MCP().GetHandler("devpod_deleteWorkspace")(a, json.RawMessage(`{"name":"api"}`))
+		results <- err
+	}()
+	sent := <-transport.messages
+	if sent.session != "a" {
+		t.Fatalf("Expected the confirmation to go to session a only, got %q", sent.session)
+	}
+	var request struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(sent.message, &request); err != nil {
+		t.Fatalf("Failed to parse request: %v", err)
+	}
+	reply := func(confirm bool) []byte {
+		return []byte(`{"jsonrpc":"2.0","id":"` + request.ID + `","result":{"action":"accept","content":{"confirm":` + strconv.FormatBool(confirm) + `}}}`)
+	}
+
+	// Another session's approval does not count
+	s.requests.deliver("b", reply(true))
+	s.requests.deliver("a", reply(false))
+	var rpcErr *mcp.RPCError
+	if err := <-results; !errors.As(err, &rpcErr) || !strings.Contains(rpcErr.Message, "declined") {
+		t.Errorf("Expected session a's refusal to deny the call, got %v", err)
+	}
+	if calls := fmt.Sprint(runner.calls); strings.Contains(calls, "delete") {
+		t.Errorf("Expected nothing to be deleted, got calls %s", calls)
+	}
+}
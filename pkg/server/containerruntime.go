@@ -0,0 +1,384 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+// containerRuntimes are the docker-compatible CLIs the docker provider can
+// run containers with, in the order auto detection prefers them
+var containerRuntimes = []string{"docker", "podman", "nerdctl"}
+
+// ValidContainerRuntime reports whether name selects a container runtime:
+// one of containerRuntimes, or auto
+func ValidContainerRuntime(name string) bool {
+	if name == "auto" {
+		return true
+	}
+	for _, runtime := range containerRuntimes {
+		if name == runtime {
+			return true
+		}
+	}
+	return false
+}
+
+// runtimeStatus is what detection found out about a container runtime
+type runtimeStatus struct {
+	Name      string `json:"name"`
+	Installed bool   `json:"installed"`
+	// Working is set when the CLI reaches its daemon, or podman its
+	// storage, so it can run containers
+	Working  bool   `json:"working"`
+	Version  string `json:"version,omitempty"`
+	Rootless bool   `json:"rootless"`
+	// Socket is podman's docker-compatible API socket, which docker compose
+	// needs for devcontainers built from compose files
+	Socket string `json:"socket,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// parseRuntimeInfo interprets `<cli> info --format '{{json .}}'`. Docker and
+// nerdctl print docker's layout, podman its own.
+func parseRuntimeInfo(name string, output []byte) (runtimeStatus, error) {
+	var info struct {
+		ServerVersion   string   `json:"ServerVersion"`
+		SecurityOptions []string `json:"SecurityOptions"`
+		Host            struct {
+			Security struct {
+				Rootless bool `json:"rootless"`
+			} `json:"security"`
+			RemoteSocket struct {
+				Path   string `json:"path"`
+				Exists bool   `json:"exists"`
+			} `json:"remoteSocket"`
+		} `json:"host"`
+		Version struct {
+			Version string `json:"Version"`
+		} `json:"version"`
+	}
+	if err := json.Unmarshal(output, &info); err != nil {
+		return runtimeStatus{}, fmt.Errorf("failed to parse %s info: %w", name, err)
+	}
+
+	status := runtimeStatus{
+		Name:      name,
+		Installed: true,
+		Working:   true,
+		Version:   firstNonEmpty(info.ServerVersion, info.Version.Version),
+		Rootless:  info.Host.Security.Rootless,
+	}
+	for _, option := range info.SecurityOptions {
+		if strings.Contains(option, "name=rootless") {
+			status.Rootless = true
+		}
+	}
+	if info.Host.RemoteSocket.Exists && info.Host.RemoteSocket.Path != "" {
+		status.Socket = "unix://" + strings.TrimPrefix(info.Host.RemoteSocket.Path, "unix://")
+	}
+	return status, nil
+}
+
+// runtimeCLI runs a container runtime's CLI without streaming its output
+func (s *Server) runtimeCLI(ctx context.Context, name string, args ...string) ([]byte, []byte, error) {
+	runner, ok := s.opts.RuntimeRunners[name]
+	if !ok {
+		runner = &ExecRunner{Path: name}
+	}
+	return runner.Run(WithOutputWriter(ctx, nil), args)
+}
+
+// detectRuntime checks whether a container runtime is installed and works
+func (s *Server) detectRuntime(ctx context.Context, name string) runtimeStatus {
+	stdout, stderr, err := s.runtimeCLI(ctx, name, "info", "--format", "{{json .}}")
+	switch {
+	case errors.Is(err, exec.ErrNotFound):
+		return runtimeStatus{Name: name}
+	case err != nil:
+		problem := strings.TrimSpace(sanitizeOutput(string(stderr)))
+		if problem == "" {
+			problem = err.Error()
+		}
+		return runtimeStatus{Name: name, Installed: true, Error: problem}
+	}
+	status, err := parseRuntimeInfo(name, stdout)
+	if err != nil {
+		return runtimeStatus{Name: name, Installed: true, Error: err.Error()}
+	}
+	return status
+}
+
+// detectRuntimes checks every container runtime
+func (s *Server) detectRuntimes(ctx context.Context) []runtimeStatus {
+	statuses := make([]runtimeStatus, len(containerRuntimes))
+	for i, name := range containerRuntimes {
+		statuses[i] = s.detectRuntime(ctx, name)
+	}
+	return statuses
+}
+
+// pickRuntime returns the status of the wanted runtime, or with auto the
+// first one that works; ok is unset when auto finds none
+func pickRuntime(wanted string, statuses []runtimeStatus) (runtimeStatus, bool) {
+	for _, status := range statuses {
+		if status.Name == wanted || (wanted == "auto" && status.Working) {
+			return status, true
+		}
+	}
+	return runtimeStatus{}, false
+}
+
+// runtimeProviderOptions returns the docker provider options that make it run
+// containers with a runtime. DOCKER_HOST is only set for podman, whose API
+// socket docker compose talks to; it is left alone otherwise so a remote
+// docker host keeps working.
+func runtimeProviderOptions(status runtimeStatus) map[string]string {
+	options := map[string]string{"DOCKER_PATH": status.Name}
+	if status.Name == "podman" && status.Socket != "" {
+		options["DOCKER_HOST"] = status.Socket
+	}
+	return options
+}
+
+// applyRuntimeOptions sets the options of the docker provider that differ
+// from the wanted ones and returns those it changed. A missing docker
+// provider is left alone.
+func (s *Server) applyRuntimeOptions(ctx context.Context, wanted map[string]string) (map[string]string, error) {
+	installed, err := s.installedProviders(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := installed["docker"]; !ok {
+		return nil, nil
+	}
+	current, err := s.providerOptions(ctx, "docker")
+	if err != nil {
+		return nil, err
+	}
+
+	changed := make(map[string]string)
+	for name, value := range wanted {
+		if firstNonEmpty(current[name].Value, current[name].Default) != value {
+			changed[name] = value
+		}
+	}
+	if len(changed) == 0 {
+		return changed, nil
+	}
+	names := make([]string, 0, len(changed))
+	for name := range changed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	args := []string{"provider", "set-options", "docker"}
+	for _, name := range names {
+		args = append(args, "-o", fmt.Sprintf("%s=%s", name, changed[name]))
+	}
+	if output, err := s.combinedOutput(ctx, args); err != nil {
+		return nil, &CommandError{Args: args, Stdout: output, Err: err}
+	}
+	s.providerSchemaCache.invalidate()
+	return changed, nil
+}
+
+// useContainerRuntime points the docker provider, and the docker commands of
+// the server itself, at the runtime ContainerRuntime selects
+func (s *Server) useContainerRuntime(ctx context.Context) error {
+	statuses := s.detectRuntimes(ctx)
+	status, ok := pickRuntime(s.opts.ContainerRuntime, statuses)
+	if !ok {
+		return fmt.Errorf("no container runtime works: %s", runtimeProblems(statuses))
+	}
+	if !status.Working {
+		s.reportEvent("warning", "runtime", fmt.Errorf("container runtime %s does not work: %s", status.Name, firstNonEmpty(status.Error, "not installed")))
+	}
+	if s.opts.DockerRunner == nil {
+		s.docker = &ExecRunner{Path: status.Name}
+	}
+	s.containerRuntime = status.Name
+
+	changed, err := s.applyRuntimeOptions(ctx, runtimeProviderOptions(status))
+	if err != nil {
+		return err
+	}
+	if len(changed) > 0 {
+		infof("Configured the docker provider for %s", status.Name)
+	}
+	return nil
+}
+
+// runtimeProblems summarizes why runtimes do not work
+func runtimeProblems(statuses []runtimeStatus) string {
+	var problems []string
+	for _, status := range statuses {
+		switch {
+		case !status.Installed:
+			problems = append(problems, status.Name+" is not installed")
+		case !status.Working:
+			problems = append(problems, fmt.Sprintf("%s: %s", status.Name, status.Error))
+		}
+	}
+	return strings.Join(problems, "; ")
+}
+
+// diagnoseRuntime lists the problems that keep the docker provider from
+// running containers with its configured DOCKER_PATH and DOCKER_HOST, and
+// the runtime it should use instead
+func diagnoseRuntime(statuses []runtimeStatus, dockerPath, dockerHost string) ([]string, string) {
+	var problems []string
+	current, known := pickRuntime(filepath.Base(firstNonEmpty(dockerPath, "docker")), statuses)
+	recommended := ""
+	if known && current.Working {
+		recommended = current.Name
+	} else if working, ok := pickRuntime("auto", statuses); ok {
+		recommended = working.Name
+	}
+
+	switch {
+	case recommended == "":
+		problems = append(problems, "no container runtime works ("+runtimeProblems(statuses)+"); install docker or podman, or start its daemon")
+	case !known:
+		problems = append(problems, fmt.Sprintf("the docker provider runs %s, which was not checked; %s works", dockerPath, recommended))
+	case !current.Working:
+		reason := "is not installed"
+		if current.Installed {
+			reason = "does not work: " + current.Error
+		}
+		problems = append(problems, fmt.Sprintf("the docker provider runs %s, which %s; %s works, so use it with apply or -container-runtime %s", current.Name, reason, recommended, recommended))
+	}
+
+	if host, ok := strings.CutPrefix(dockerHost, "unix://"); ok {
+		if _, err := os.Stat(host); err != nil {
+			problems = append(problems, fmt.Sprintf("DOCKER_HOST points at %s, which does not exist", host))
+		}
+	}
+	for _, status := range statuses {
+		if status.Name == "podman" && status.Working && status.Rootless && status.Socket == "" && recommended == "podman" {
+			problems = append(problems, "the podman API socket is not running, which devcontainers built from compose files need; start it with systemctl --user enable --now podman.socket")
+		}
+	}
+	return problems, recommended
+}
+
+// registerDoctorTools registers the tool that diagnoses the container runtime
+func (s *Server) registerDoctorTools() {
+	// Diagnose the container runtime of the docker provider
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_doctor",
+		Description: "Diagnose the local setup: whether the DevPod CLI works, which container runtimes (docker, podman, nerdctl) are installed, working and rootless, and whether the docker provider is configured for one that works. With apply, points the docker provider at the recommended runtime.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"apply": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Set the docker provider's DOCKER_PATH, and DOCKER_HOST for podman, for the recommended or given runtime (default: false)",
+				},
+				"runtime": map[string]interface{}{
+					"type":        "string",
+					"enum":        containerRuntimes,
+					"description": "Runtime to apply instead of the recommended one (optional, needs apply)",
+				},
+			},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var doctorParams struct {
+			Apply   bool   `json:"apply,omitempty"`
+			Runtime string `json:"runtime,omitempty"`
+		}
+
+		if len(params) > 0 {
+			if err := json.Unmarshal(params, &doctorParams); err != nil {
+				return nil, mcp.NewInvalidParamsError("Invalid doctor parameters")
+			}
+		}
+		if doctorParams.Runtime != "" && (!doctorParams.Apply || doctorParams.Runtime == "auto" || !ValidContainerRuntime(doctorParams.Runtime)) {
+			return nil, mcp.NewInvalidParamsError("runtime must be one of docker, podman or nerdctl and needs apply")
+		}
+
+		result := map[string]interface{}{}
+		devpod := map[string]interface{}{"available": false}
+		if version, err := s.devpodVersion(ctx); err == nil {
+			devpod["available"] = true
+			devpod["version"] = version
+		} else {
+			devpod["error"] = err.Error()
+		}
+		result["devpod"] = devpod
+
+		statuses := s.detectRuntimes(ctx)
+		result["runtimes"] = statuses
+		if s.containerRuntime != "" {
+			result["configuredRuntime"] = s.containerRuntime
+		}
+
+		// The docker provider's options say which runtime it runs
+		var dockerPath, dockerHost string
+		provider := map[string]interface{}{"installed": false}
+		if devpod["available"] == true {
+			if installed, err := s.installedProviders(ctx); err == nil {
+				if _, ok := installed["docker"]; ok {
+					provider["installed"] = true
+					if options, err := s.providerOptions(ctx, "docker"); err == nil {
+						dockerPath = firstNonEmpty(options["DOCKER_PATH"].Value, options["DOCKER_PATH"].Default)
+						dockerHost = firstNonEmpty(options["DOCKER_HOST"].Value, options["DOCKER_HOST"].Default)
+						provider["dockerPath"] = firstNonEmpty(dockerPath, "docker")
+						if dockerHost != "" {
+							provider["dockerHost"] = dockerHost
+						}
+					}
+				}
+			}
+		}
+		result["dockerProvider"] = provider
+
+		problems, recommended := diagnoseRuntime(statuses, dockerPath, dockerHost)
+		if devpod["available"] != true {
+			problems = append([]string{"the DevPod CLI does not work; install it with devpod_installCLI"}, problems...)
+		} else if provider["installed"] != true {
+			problems = append(problems, "the docker provider is not installed; add it with devpod_addProvider")
+		}
+		if recommended != "" {
+			result["recommendedRuntime"] = recommended
+		}
+
+		if doctorParams.Apply {
+			target := firstNonEmpty(doctorParams.Runtime, recommended)
+			if target == "" {
+				return nil, mcp.NewInvalidParamsError("No container runtime works, so there is none to apply")
+			}
+			if provider["installed"] != true {
+				return nil, mcp.NewInvalidParamsError("The docker provider is not installed")
+			}
+			status, _ := pickRuntime(target, statuses)
+			status.Name = target
+			changed, err := s.applyRuntimeOptions(ctx, runtimeProviderOptions(status))
+			if err != nil {
+				return nil, newDevPodError("failed to configure the docker provider", err, nil)
+			}
+			result["applied"] = changed
+			provider["dockerPath"] = target
+			problems, _ = diagnoseRuntime(statuses, target, firstNonEmpty(changed["DOCKER_HOST"], dockerHost))
+		}
+
+		if problems == nil {
+			problems = []string{}
+		}
+		result["problems"] = problems
+		result["healthy"] = len(problems) == 0
+		if len(problems) == 0 {
+			result["message"] = "No problems found"
+		} else {
+			result["message"] = fmt.Sprintf("Found %d problem(s)", len(problems))
+		}
+		return result, nil
+	})
+}
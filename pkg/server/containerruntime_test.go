@@ -0,0 +1,92 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/protobomb/mcp-server-framework/pkg/transport"
+)
+
+func TestParseRuntimeInfo(t *testing.T) {
+	docker, err := parseRuntimeInfo("docker", []byte(`{"ServerVersion":"27.1.1","SecurityOptions":["name=seccomp,profile=builtin","name=rootless"]}`))
+	if err != nil || docker.Version != "27.1.1" || !docker.Rootless || !docker.Working || docker.Socket != "" {
+		t.Errorf("Unexpected docker status %+v (%v)", docker, err)
+	}
+	podman, err := parseRuntimeInfo("podman", []byte(`{"host":{"security":{"rootless":true},"remoteSocket":{"path":"/run/user/1000/podman/podman.sock","exists":true}},"version":{"Version":"4.9.3"}}`))
+	if err != nil || podman.Version != "4.9.3" || !podman.Rootless || podman.Socket != "unix:///run/user/1000/podman/podman.sock" {
+		t.Errorf("Unexpected podman status %+v (%v)", podman, err)
+	}
+	if _, err := parseRuntimeInfo("nerdctl", []byte("not json")); err == nil {
+		t.Error("Expected unparsable info to fail")
+	}
+}
+
+func TestDiagnoseRuntime(t *testing.T) {
+	statuses := []runtimeStatus{
+		{Name: "docker", Installed: true, Error: "Cannot connect to the Docker daemon at unix:///var/run/docker.sock"},
+		{Name: "podman", Installed: true, Working: true, Rootless: true},
+		{Name: "nerdctl"},
+	}
+	problems, recommended := diagnoseRuntime(statuses, "", "")
+	if recommended != "podman" || len(problems) != 2 || !strings.Contains(problems[0], "docker provider runs docker, which does not work") || !strings.Contains(problems[1], "podman.socket") {
+		t.Errorf("Unexpected diagnosis %q, %v", recommended, problems)
+	}
+
+	statuses[1].Socket = "unix:///run/user/1000/podman/podman.sock"
+	missing := filepath.Join(t.TempDir(), "podman.sock")
+	problems, recommended = diagnoseRuntime(statuses, "/usr/bin/podman", "unix://"+missing)
+	if recommended != "podman" || len(problems) != 1 || !strings.Contains(problems[0], missing) {
+		t.Errorf("Expected only the missing socket, got %q, %v", recommended, problems)
+	}
+
+	if problems, recommended := diagnoseRuntime(statuses[:1], "", ""); recommended != "" || !strings.Contains(fmt.Sprint(problems), "no container runtime works") {
+		t.Errorf("Expected no working runtime, got %q, %v", recommended, problems)
+	}
+}
+
+func TestDoctorAppliesRuntime(t *testing.T) {
+	runner := &fakeRunner{outputs: map[string]string{
+		"version":                               "v0.6.15",
+		"provider list --output json":           `{"docker":{}}`,
+		"provider options docker --output json": `{"DOCKER_PATH":{"default":"docker"},"DOCKER_HOST":{}}`,
+	}}
+	docker := &fakeRunner{failures: map[string]string{"info --format {{json .}}": "Cannot connect to the Docker daemon at unix:///var/run/docker.sock. Is the docker daemon running?"}}
+	// The socket must exist on this machine for the check of DOCKER_HOST
+	socket := filepath.Join(t.TempDir(), "podman.sock")
+	if err := os.WriteFile(socket, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	podman := &fakeRunner{outputs: map[string]string{"info --format {{json .}}": fmt.Sprintf(`{"host":{"security":{"rootless":true},"remoteSocket":{"path":%q,"exists":true}},"version":{"Version":"4.9.3"}}`, socket)}}
+	s := New(transport.NewSTDIOTransport(), Options{
+		Runner:         runner,
+		StatePath:      filepath.Join(t.TempDir(), "state.json"),
+		RuntimeRunners: map[string]Runner{"docker": docker, "podman": podman, "nerdctl": missingCLI{}},
+	})
+	doctor := s.MCP().GetHandler("devpod_doctor")
+
+	result, err := doctor(context.Background(), json.RawMessage(`{}`))
+	if err != nil {
+		t.Fatalf("devpod_doctor failed: %v", err)
+	}
+	data := result.(map[string]interface{})
+	if data["recommendedRuntime"] != "podman" || data["healthy"] != false || !strings.Contains(fmt.Sprint(data["problems"]), "Cannot connect to the Docker daemon") {
+		t.Errorf("Unexpected diagnosis %v", data)
+	}
+
+	result, err = doctor(context.Background(), json.RawMessage(`{"apply":true}`))
+	if err != nil {
+		t.Fatalf("devpod_doctor with apply failed: %v", err)
+	}
+	data = result.(map[string]interface{})
+	if data["healthy"] != true || fmt.Sprint(data["applied"]) != "map[DOCKER_HOST:unix://"+socket+" DOCKER_PATH:podman]" {
+		t.Errorf("Expected podman to be applied, got %v", data)
+	}
+	if calls := fmt.Sprint(runner.calls); !strings.Contains(calls, "[provider set-options docker -o DOCKER_HOST=unix://"+socket+" -o DOCKER_PATH=podman]") {
+		t.Errorf("Expected the docker provider to be configured, got calls %v", calls)
+	}
+}
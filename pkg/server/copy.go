@@ -0,0 +1,151 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+// receiveCommand reads an artifact fetched with artifactCommand on stdin and
+// writes it to path, relative to the workspace folder unless absolute, whose
+// parent directory must exist. The content is checked against the size and
+// checksum the source workspace reported before it replaces anything, and a
+// directory's tarball is extracted into place. An existing path is only
+// replaced with overwrite.
+func receiveCommand(path string, a *artifact, overwrite bool) string {
+	exists := `echo "$p already exists; pass overwrite to replace it" >&2; exit 3`
+	if overwrite {
+		exists = `:`
+	}
+	place := fmt.Sprintf(`chmod %o "$t" && mv -f "$t" "$p"`, a.Mode)
+	mismatch := `[ -d "$p" ] && [ ! -L "$p" ]`
+	if a.Kind == "directory" {
+		place = `x=$(mktemp -d "$d/.copy-XXXXXX") && tar -xzf "$t" -C "$x" && chmod 755 "$x" && rm -rf "$p" && mv "$x" "$p"`
+		mismatch = `! [ -d "$p" ]`
+	}
+	return fmt.Sprintf(`p=%s; d=$(dirname "$p"); x=; `+
+		`if ! [ -d "$d" ]; then echo "$d: no such directory" >&2; exit 2; fi; `+
+		`if [ -e "$p" ] || [ -L "$p" ]; then if %s; then echo "cannot replace $p with a %s" >&2; exit 3; fi; %s; fi; `+
+		`t=$(mktemp "$d/.copy-XXXXXX") || exit 1; trap 'rm -rf "$t" "$x"' EXIT; cat > "$t" || exit 1; `+
+		`n=$(wc -c < "$t") && s=$(sha256sum < "$t") || exit 1; `+
+		`if [ "$n" != %d ] || [ "${s%%%% *}" != %s ]; then echo "checksum mismatch: received $n bytes with SHA-256 ${s%%%% *}, the source sent %d bytes with SHA-256 %s" >&2; exit 1; fi; `+
+		`%s`, shellQuote(path), mismatch, a.Kind, exists, a.Size, a.SHA256, a.Size, a.SHA256, place)
+}
+
+// copyBetweenWorkspaces copies a file or directory from one running
+// workspace to another through the server. It is fetched like
+// devpod_fetchArtifact fetches it, and so held in memory and limited to
+// maxArtifactBytes, then sent to the destination on the stdin of devpod ssh.
+func (s *Server) copyBetweenWorkspaces(ctx context.Context, source, path, destination, destinationPath string, overwrite bool) (*artifact, error) {
+	// The artifact is data for the server, not console output
+	ctx = WithOutputWriter(ctx, nil)
+	output, stderr, err := s.run(ctx, []string{"ssh", source, "--command", artifactCommand(path, maxArtifactBytes)})
+	if err != nil {
+		return nil, newDevPodError(fmt.Sprintf("failed to read %s from %s", path, source), err, stderr)
+	}
+	a, err := parseArtifact(output)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s from %s: %w", path, source, err)
+	}
+	if a.Data == nil {
+		return nil, mcp.NewInvalidParamsError(fmt.Sprintf("%s is %d bytes, over the %d-byte limit", path, a.Size, maxArtifactBytes))
+	}
+
+	stdout, stderr, err := s.run(WithCommandInput(ctx, a.Data), []string{"ssh", destination, "--command", receiveCommand(destinationPath, a, overwrite)})
+	if err != nil {
+		return nil, newDevPodError(fmt.Sprintf("failed to write %s to %s", destinationPath, destination), err, append(stderr, stdout...))
+	}
+	return a, nil
+}
+
+// registerCopyTools registers the tool that copies artifacts between workspaces
+func (s *Server) registerCopyTools() {
+	// Copy an artifact between workspaces
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_copyBetweenWorkspaces",
+		Description: "Copy a file or directory from one running DevPod workspace to another through the server, e.g. a build output from a build workspace into a test workspace. The copy is checked against the source's SHA-256 checksum before it is written.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"source": map[string]interface{}{
+					"type":        "string",
+					"description": "The name of the workspace to copy from",
+				},
+				"path": map[string]interface{}{
+					"type":        "string",
+					"description": "File or directory inside the source workspace, relative to its workspace folder unless absolute, e.g. dist/ or bin/app",
+				},
+				"destination": map[string]interface{}{
+					"type":        "string",
+					"description": "The name of the workspace to copy to",
+				},
+				"destinationPath": map[string]interface{}{
+					"type":        "string",
+					"description": "File or directory to write in the destination workspace, in an existing directory, relative to its workspace folder unless absolute (optional, defaults to path)",
+				},
+				"overwrite": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Replace an existing destinationPath (default: false)",
+				},
+			},
+			"required": []string{"source", "path", "destination"},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var copyParams struct {
+			Source          string `json:"source"`
+			Path            string `json:"path"`
+			Destination     string `json:"destination"`
+			DestinationPath string `json:"destinationPath,omitempty"`
+			Overwrite       bool   `json:"overwrite,omitempty"`
+		}
+
+		if err := json.Unmarshal(params, &copyParams); err != nil {
+			return nil, mcp.NewInvalidParamsError("Invalid copy parameters")
+		}
+		if copyParams.Source == "" || copyParams.Destination == "" {
+			return nil, mcp.NewInvalidParamsError("source and destination workspaces are required")
+		}
+		if copyParams.Path == "" {
+			return nil, mcp.NewInvalidParamsError("path is required")
+		}
+		if copyParams.DestinationPath == "" {
+			copyParams.DestinationPath = copyParams.Path
+		}
+		if copyParams.Source == copyParams.Destination && path.Clean(copyParams.Path) == path.Clean(copyParams.DestinationPath) {
+			return nil, mcp.NewInvalidParamsError("cannot copy a path onto itself")
+		}
+		for _, name := range []string{copyParams.Source, copyParams.Destination} {
+			if err := s.requireRunning(ctx, name); err != nil {
+				return nil, err
+			}
+		}
+
+		start := time.Now()
+		artifact, err := s.copyBetweenWorkspaces(ctx, copyParams.Source, copyParams.Path, copyParams.Destination, copyParams.DestinationPath, copyParams.Overwrite)
+		if err != nil {
+			s.state(ctx).RecordEvent(copyParams.Destination, "error", fmt.Sprintf("failed to copy %s from %s: %v", copyParams.Path, copyParams.Source, err))
+			return nil, err
+		}
+		s.state(ctx).RecordEvent(copyParams.Source, "command", fmt.Sprintf("Copied %s to %s (%d bytes)", copyParams.Path, copyParams.Destination, artifact.Size))
+		s.state(ctx).RecordEvent(copyParams.Destination, "command", fmt.Sprintf("Received %s from %s as %s (%d bytes)", copyParams.Path, copyParams.Source, copyParams.DestinationPath, artifact.Size))
+		s.touchWorkspace(ctx, copyParams.Source)
+		s.touchWorkspace(ctx, copyParams.Destination)
+
+		return map[string]interface{}{
+			"source":          copyParams.Source,
+			"path":            copyParams.Path,
+			"destination":     copyParams.Destination,
+			"destinationPath": copyParams.DestinationPath,
+			"kind":            artifact.Kind,
+			"bytes":           artifact.Size,
+			"sha256":          artifact.SHA256,
+			"verified":        true,
+			"durationMs":      durationMs(start),
+			"message":         fmt.Sprintf("Copied %s from %s to %s in %s (%d bytes, SHA-256 verified)", copyParams.Path, copyParams.Source, copyParams.DestinationPath, copyParams.Destination, artifact.Size),
+		}, nil
+	})
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+// workspaceShells runs the commands of devpod ssh with sh in a directory per
+// workspace, keeping their stderr
+type workspaceShells map[string]string
+
+func (r workspaceShells) Run(ctx context.Context, args []string) ([]byte, []byte, error) {
+	if len(args) != 4 || args[0] != "ssh" || args[2] != "--command" {
+		return (&fakeRunner{}).Run(ctx, args)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "sh", "-c", args[3])
+	cmd.Dir = r[args[1]]
+	cmd.Stdin = bytes.NewReader(CommandInput(ctx))
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err := cmd.Run()
+	return stdout.Bytes(), stderr.Bytes(), err
+}
+
+// copyFailure returns the stderr a failed copy reported
+func copyFailure(err error) string {
+	var rpcErr *mcp.RPCError
+	if !errors.As(err, &rpcErr) {
+		return fmt.Sprint(err)
+	}
+	return fmt.Sprint(rpcErr.Data)
+}
+
+func TestCopyBetweenWorkspaces(t *testing.T) {
+	build, test := t.TempDir(), t.TempDir()
+	os.MkdirAll(filepath.Join(build, "dist", "assets"), 0o755)
+	os.WriteFile(filepath.Join(build, "dist", "assets", "app.js"), []byte("console.log(1)\n"), 0o644)
+	os.MkdirAll(filepath.Join(build, "bin"), 0o755)
+	os.WriteFile(filepath.Join(build, "bin", "app"), []byte("#!/bin/sh\necho hi\n"), 0o755)
+	os.WriteFile(filepath.Join(build, "empty"), nil, 0o600)
+
+	s := newTestServer(t, workspaceShells{"build": build, "test": test})
+	run := func(args string) (map[string]interface{}, error) {
+		t.Helper()
+		result, err := s.MCP().GetHandler("devpod_copyBetweenWorkspaces")(context.Background(), json.RawMessage(args))
+		if err != nil {
+			return nil, err
+		}
+		return result.(map[string]interface{}), nil
+	}
+
+	if _, err := run(`{"source":"build","path":"bin/app","destination":"test","destinationPath":"app"}`); err != nil {
+		t.Fatalf("devpod_copyBetweenWorkspaces failed: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(test, "app")); err != nil || string(data) != "#!/bin/sh\necho hi\n" {
+		t.Errorf("Expected the file to be copied, got %q, %v", data, err)
+	}
+	if info, err := os.Stat(filepath.Join(test, "app")); err != nil || info.Mode().Perm() != 0o755 {
+		t.Errorf("Expected the copy to stay executable, got %v, %v", info, err)
+	}
+	if _, err := run(`{"source":"build","path":"bin/app","destination":"test","destinationPath":"app"}`); err == nil || !strings.Contains(copyFailure(err), "pass overwrite") {
+		t.Errorf("Expected an existing file not to be overwritten, got %v", err)
+	}
+	if _, err := run(`{"source":"build","path":"empty","destination":"test"}`); err != nil {
+		t.Errorf("Expected an empty file to be copied, got %v", err)
+	}
+
+	os.MkdirAll(filepath.Join(test, "dist", "stale"), 0o755)
+	result, err := run(`{"source":"build","path":"dist","destination":"test","overwrite":true}`)
+	if err != nil {
+		t.Fatalf("devpod_copyBetweenWorkspaces of a directory failed: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(test, "dist", "assets", "app.js")); err != nil || string(data) != "console.log(1)\n" || result["kind"] != "directory" {
+		t.Errorf("Expected the directory to be copied, got %q, %v, %v", data, err, result)
+	}
+	if _, err := os.Stat(filepath.Join(test, "dist", "stale")); !os.IsNotExist(err) {
+		t.Error("Expected the directory to be replaced")
+	}
+	if _, err := run(`{"source":"build","path":"bin/app","destination":"test","destinationPath":"dist","overwrite":true}`); err == nil || !strings.Contains(copyFailure(err), "cannot replace") {
+		t.Errorf("Expected a directory not to be replaced with a file, got %v", err)
+	}
+	if _, err := run(`{"source":"build","path":"bin/app","destination":"test","destinationPath":"missing/app"}`); err == nil || !strings.Contains(copyFailure(err), "no such directory") {
+		t.Errorf("Expected a missing parent directory to fail, got %v", err)
+	}
+	if entries, _ := os.ReadDir(test); len(entries) != 3 {
+		t.Errorf("Expected no temporary files to be left behind, got %v", entries)
+	}
+	if _, err := run(`{"source":"build","path":"bin/app","destination":"build"}`); err == nil {
+		t.Error("Expected a copy onto itself to be rejected")
+	}
+}
+
+func TestReceiveCommandVerifiesChecksum(t *testing.T) {
+	dir := t.TempDir()
+	a := &artifact{Kind: "file", Mode: 0o644, Size: 5, SHA256: strings.Repeat("0", 64), Data: []byte("hello")}
+	s := newTestServer(t, &shellRunner{dir: dir})
+	if _, _, err := s.run(WithCommandInput(context.Background(), a.Data), []string{"ssh", "api", "--command", receiveCommand("out", a, false)}); err == nil {
+		t.Error("Expected a checksum mismatch to fail")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "out")); !os.IsNotExist(err) {
+		t.Errorf("Expected nothing to be written, got %v", err)
+	}
+}
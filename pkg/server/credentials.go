@@ -0,0 +1,84 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// normalizeCredentialScope validates a git credential scope such as
+// "github.com/our-org" and strips any URL scheme or trailing slash
+func normalizeCredentialScope(scope string) (string, error) {
+	scope = strings.TrimSpace(scope)
+	scope = strings.TrimPrefix(scope, "https://")
+	scope = strings.TrimPrefix(scope, "http://")
+	scope = strings.TrimSuffix(scope, "/")
+	if scope == "" || strings.ContainsAny(scope, " \t\n@?#") || strings.HasPrefix(scope, "/") {
+		return "", fmt.Errorf("invalid git credential scope %q (expected host or host/path)", scope)
+	}
+	return scope, nil
+}
+
+// normalizeCredentialScopes validates and normalizes a list of scopes
+func normalizeCredentialScopes(scopes []string) ([]string, error) {
+	normalized := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		scope, err := normalizeCredentialScope(scope)
+		if err != nil {
+			return nil, err
+		}
+		normalized = append(normalized, scope)
+	}
+	return normalized, nil
+}
+
+// gitCredentialEnv returns environment variables that restrict the given git
+// credential helpers to the scoped hosts/paths. DevPod resolves injected git
+// credentials by running `git credential fill` on the host, so resetting the
+// helper list and re-adding the helpers only for scoped URLs prevents the
+// workspace from obtaining credentials for any other repository.
+func gitCredentialEnv(scopes, helpers []string) []string {
+	type entry struct{ key, value string }
+	entries := []entry{
+		{"credential.helper", ""},
+		{"credential.useHttpPath", "true"},
+	}
+	for _, scope := range scopes {
+		for _, helper := range helpers {
+			entries = append(entries, entry{fmt.Sprintf("credential.https://%s.helper", scope), helper})
+		}
+	}
+
+	env := []string{fmt.Sprintf("GIT_CONFIG_COUNT=%d", len(entries))}
+	for i, e := range entries {
+		env = append(env,
+			fmt.Sprintf("GIT_CONFIG_KEY_%d=%s", i, e.key),
+			fmt.Sprintf("GIT_CONFIG_VALUE_%d=%s", i, e.value))
+	}
+	return env
+}
+
+// hostCredentialHelpers returns the git credential helpers configured on the host
+func (s *Server) hostCredentialHelpers(ctx context.Context) []string {
+	output, _, err := s.git.Run(ctx, []string{"config", "--get-all", "credential.helper"})
+	if err != nil {
+		return nil
+	}
+
+	var helpers []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if helper := strings.TrimSpace(line); helper != "" {
+			helpers = append(helpers, helper)
+		}
+	}
+	return helpers
+}
+
+// withCredentialScopes attaches the scoped git credential environment for a
+// workspace to ctx. Workspaces without scopes are returned unchanged.
+func (s *Server) withCredentialScopes(ctx context.Context, scopes []string) context.Context {
+	if len(scopes) == 0 {
+		return ctx
+	}
+	return WithCommandEnv(ctx, gitCredentialEnv(scopes, s.hostCredentialHelpers(ctx)))
+}
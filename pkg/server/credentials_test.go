@@ -0,0 +1,100 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/protobomb/mcp-server-framework/pkg/transport"
+)
+
+func TestNormalizeCredentialScope(t *testing.T) {
+	scope, err := normalizeCredentialScope("https://github.com/our-org/")
+	if err != nil || scope != "github.com/our-org" {
+		t.Errorf("Expected github.com/our-org, got %q (err=%v)", scope, err)
+	}
+
+	for _, invalid := range []string{"", "/org", "user@github.com"} {
+		if _, err := normalizeCredentialScope(invalid); err == nil {
+			t.Errorf("Expected %q to be rejected", invalid)
+		}
+	}
+}
+
+func TestGitCredentialEnv(t *testing.T) {
+	env := gitCredentialEnv([]string{"github.com/our-org"}, []string{"osxkeychain"})
+
+	expected := []string{
+		"GIT_CONFIG_COUNT=3",
+		"GIT_CONFIG_KEY_0=credential.helper",
+		"GIT_CONFIG_VALUE_0=",
+		"GIT_CONFIG_KEY_1=credential.useHttpPath",
+		"GIT_CONFIG_VALUE_1=true",
+		"GIT_CONFIG_KEY_2=credential.https://github.com/our-org.helper",
+		"GIT_CONFIG_VALUE_2=osxkeychain",
+	}
+	if len(env) != len(expected) {
+		t.Fatalf("Expected %d variables, got %d: %v", len(expected), len(env), env)
+	}
+	for i := range expected {
+		if env[i] != expected[i] {
+			t.Errorf("Variable %d: expected %q, got %q", i, expected[i], env[i])
+		}
+	}
+}
+
+func TestSSHCommandsAreCredentialScoped(t *testing.T) {
+	var sshEnv [][]string
+	runner := runnerFunc(func(ctx context.Context, args []string) ([]byte, []byte, error) {
+		switch args[0] {
+		case "ssh":
+			sshEnv = append(sshEnv, CommandEnv(ctx))
+			return []byte(gitStatusMarker + "\n"), nil, nil
+		case "status":
+			return []byte(`{"id":"api","state":"Running"}`), nil, nil
+		}
+		return nil, nil, nil
+	})
+	var gitArgs []string
+	s := New(transport.NewSTDIOTransport(), Options{
+		Runner: runner,
+		GitRunner: runnerFunc(func(ctx context.Context, args []string) ([]byte, []byte, error) {
+			gitArgs = args
+			return []byte("osxkeychain\n"), nil, nil
+		}),
+		StatePath: filepath.Join(t.TempDir(), "state.json"),
+	})
+	s.state(context.Background()).SetCredentialScopes("api", []string{"github.com/our-org"})
+
+	for _, call := range []struct{ tool, params string }{
+		{"devpod_ssh", `{"name":"api","command":"git fetch"}`},
+		{"devpod_gitPull", `{"name":"api"}`},
+	} {
+		if _, err := s.MCP().GetHandler(call.tool)(context.Background(), json.RawMessage(call.params)); err != nil {
+			t.Fatalf("%s failed: %v", call.tool, err)
+		}
+	}
+	if strings.Join(gitArgs, " ") != "config --get-all credential.helper" {
+		t.Errorf("Expected the helpers to be looked up through the git runner, got %v", gitArgs)
+	}
+	if len(sshEnv) != 2 {
+		t.Fatalf("Expected 2 ssh calls, got %d", len(sshEnv))
+	}
+	for i, env := range sshEnv {
+		if !slices.Contains(env, "GIT_CONFIG_KEY_2=credential.https://github.com/our-org.helper") || !slices.Contains(env, "GIT_CONFIG_VALUE_2=osxkeychain") {
+			t.Errorf("Expected ssh call %d to be scoped to github.com/our-org, got %v", i, env)
+		}
+	}
+
+	// Workspaces without scopes keep the host's helpers
+	sshEnv = nil
+	if _, err := s.MCP().GetHandler("devpod_ssh")(context.Background(), json.RawMessage(`{"name":"web","command":"true"}`)); err != nil {
+		t.Fatalf("devpod_ssh failed: %v", err)
+	}
+	if len(sshEnv) != 1 || strings.Contains(strings.Join(sshEnv[0], " "), "GIT_CONFIG_") {
+		t.Errorf("Expected an unscoped workspace to get no git config, got %v", sshEnv)
+	}
+}
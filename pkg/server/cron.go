@@ -0,0 +1,167 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronMacros are the shorthand cron expressions
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// cronNames are the month and weekday names accepted in cron fields
+var cronNames = map[string]int{
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+}
+
+// cronField is the set of values a cron field matches, one bit per value
+type cronField uint64
+
+func (f cronField) has(value int) bool {
+	return f&(1<<uint(value)) != 0
+}
+
+// cronSchedule is a parsed five-field cron expression: minute, hour, day of
+// month, month and day of week
+type cronSchedule struct {
+	minute, hour, dom, month, dow cronField
+	// domAny and dowAny are set for "*" day fields. When both day fields are
+	// restricted, a day matching either one matches, as in cron.
+	domAny, dowAny bool
+}
+
+// parseCron parses a cron expression such as "0 19 * * 1-5" or "@daily".
+// Fields accept *, values, ranges, lists and steps (*/15, 1-5/2); months and
+// weekdays also accept names (jan, mon). Weekday 7 is Sunday.
+func parseCron(expr string) (cronSchedule, error) {
+	spec := strings.ToLower(strings.TrimSpace(expr))
+	if macro, ok := cronMacros[spec]; ok {
+		spec = macro
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return cronSchedule{}, fmt.Errorf("cron expression %q must have 5 fields: minute hour day-of-month month day-of-week", expr)
+	}
+
+	var c cronSchedule
+	var err error
+	if c.minute, err = parseCronField(fields[0], 0, 59); err != nil {
+		return cronSchedule{}, fmt.Errorf("invalid minute field: %w", err)
+	}
+	if c.hour, err = parseCronField(fields[1], 0, 23); err != nil {
+		return cronSchedule{}, fmt.Errorf("invalid hour field: %w", err)
+	}
+	if c.dom, err = parseCronField(fields[2], 1, 31); err != nil {
+		return cronSchedule{}, fmt.Errorf("invalid day-of-month field: %w", err)
+	}
+	if c.month, err = parseCronField(fields[3], 1, 12); err != nil {
+		return cronSchedule{}, fmt.Errorf("invalid month field: %w", err)
+	}
+	if c.dow, err = parseCronField(fields[4], 0, 7); err != nil {
+		return cronSchedule{}, fmt.Errorf("invalid day-of-week field: %w", err)
+	}
+	if c.dow.has(7) {
+		c.dow |= 1
+	}
+	c.domAny = fields[2] == "*"
+	c.dowAny = fields[4] == "*"
+	return c, nil
+}
+
+// parseCronField parses a comma-separated cron field with values in [min, max]
+func parseCronField(field string, min, max int) (cronField, error) {
+	var set cronField
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+			rangePart, step = part[:i], n
+		}
+
+		low, high := min, max
+		if rangePart != "*" {
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if low, err = parseCronValue(bounds[0], min, max); err != nil {
+				return 0, err
+			}
+			high = low
+			if len(bounds) == 2 {
+				if high, err = parseCronValue(bounds[1], min, max); err != nil {
+					return 0, err
+				}
+			} else if step > 1 {
+				// "5/15" means from 5 through the end of the range
+				high = max
+			}
+			if high < low {
+				return 0, fmt.Errorf("invalid range %q", rangePart)
+			}
+		}
+		for value := low; value <= high; value += step {
+			set |= 1 << uint(value)
+		}
+	}
+	return set, nil
+}
+
+// parseCronValue parses a number or name within [min, max]
+func parseCronValue(value string, min, max int) (int, error) {
+	n, ok := cronNames[value]
+	if !ok {
+		var err error
+		if n, err = strconv.Atoi(value); err != nil {
+			return 0, fmt.Errorf("invalid value %q", value)
+		}
+	}
+	if n < min || n > max {
+		return 0, fmt.Errorf("value %d out of range %d-%d", n, min, max)
+	}
+	return n, nil
+}
+
+// dayMatches reports whether the day of t matches the day fields
+func (c cronSchedule) dayMatches(t time.Time) bool {
+	dom, dow := c.dom.has(t.Day()), c.dow.has(int(t.Weekday()))
+	if !c.domAny && !c.dowAny {
+		return dom || dow
+	}
+	return dom && dow
+}
+
+// next returns the first time after t the schedule matches, in t's location.
+// It reports false for expressions that never match, such as 30 February.
+func (c cronSchedule) next(t time.Time) (time.Time, bool) {
+	loc := t.Location()
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, loc).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case !c.month.has(int(t.Month())):
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case !c.hour.has(t.Hour()):
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		case !c.minute.has(t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,383 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Protobomb/mcp-server-devpod/pkg/httptransport"
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+// errInterrupted marks tool calls that were running when the server stopped
+var errInterrupted = errors.New("interrupted by server shutdown")
+
+// maxRecentInvocations caps the finished tool calls kept for the dashboard
+const maxRecentInvocations = 50
+
+// dashboardTimeout bounds the devpod calls made to render the dashboard
+const dashboardTimeout = 20 * time.Second
+
+// toolInvocation is a tool call, running or finished
+type toolInvocation struct {
+	ID      int64  `json:"id"`
+	Tool    string `json:"tool"`
+	Session string `json:"session,omitempty"`
+	// User is the authenticated user that made the call
+	User    string    `json:"user,omitempty"`
+	Started time.Time `json:"started"`
+	// DurationMs is set once the call finished
+	DurationMs *int64 `json:"durationMs,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// invocationLog tracks the running tool calls and the most recent finished
+// ones, and writes finished calls to the audit log when one is attached
+type invocationLog struct {
+	mu      sync.Mutex
+	nextID  int64
+	running map[int64]toolInvocation
+	recent  []toolInvocation
+	audit   *auditLog
+}
+
+func newInvocationLog() *invocationLog {
+	return &invocationLog{running: make(map[int64]toolInvocation)}
+}
+
+// start records a call a user's session began and returns its ID
+func (l *invocationLog) start(tool, session, user string) int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.nextID++
+	l.running[l.nextID] = toolInvocation{ID: l.nextID, Tool: tool, Session: session, User: user, Started: time.Now().UTC()}
+	return l.nextID
+}
+
+// finish moves a call to the finished ones
+func (l *invocationLog) finish(id int64, err error) {
+	l.mu.Lock()
+	invocation, ok := l.running[id]
+	if !ok {
+		l.mu.Unlock()
+		return
+	}
+	delete(l.running, id)
+	duration := durationMs(invocation.Started)
+	invocation.DurationMs = &duration
+	if err != nil {
+		invocation.Error = err.Error()
+	}
+	l.recent = append(l.recent, invocation)
+	if len(l.recent) > maxRecentInvocations {
+		l.recent = l.recent[len(l.recent)-maxRecentInvocations:]
+	}
+	audit := l.audit
+	l.mu.Unlock()
+
+	if err := audit.append(invocation); err != nil {
+		log.Printf("WARNING: failed to write audit log: %v", err)
+	}
+}
+
+// interruptAll finishes the running calls as interrupted, so the audit log
+// shows the calls a shutdown cut short
+func (l *invocationLog) interruptAll() {
+	l.mu.Lock()
+	ids := make([]int64, 0, len(l.running))
+	for id := range l.running {
+		ids = append(ids, id)
+	}
+	l.mu.Unlock()
+
+	for _, id := range ids {
+		l.finish(id, errInterrupted)
+	}
+}
+
+// restore attaches the audit log and loads the calls it recorded before a
+// restart as the most recent ones
+func (l *invocationLog) restore(audit *auditLog) error {
+	invocations, err := audit.tail(maxRecentInvocations, nil)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.audit = audit
+	l.recent = append(invocations, l.recent...)
+	for _, invocation := range l.recent {
+		if invocation.ID > l.nextID {
+			l.nextID = invocation.ID
+		}
+	}
+	return err
+}
+
+// snapshot returns a user's running calls, oldest first, and finished ones,
+// most recent first
+func (l *invocationLog) snapshot(user string) ([]toolInvocation, []toolInvocation) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	running := make([]toolInvocation, 0, len(l.running))
+	for _, invocation := range l.running {
+		if invocation.User == user {
+			running = append(running, invocation)
+		}
+	}
+	sort.Slice(running, func(i, j int) bool { return running[i].ID < running[j].ID })
+	recent := make([]toolInvocation, 0, len(l.recent))
+	for i := len(l.recent) - 1; i >= 0; i-- {
+		if l.recent[i].User == user {
+			recent = append(recent, l.recent[i])
+		}
+	}
+	return running, recent
+}
+
+// invocationKey is the context key for the ID of the running tool call
+type invocationKey struct{}
+
+// invocationID returns the dashboard ID of the tool call of ctx, or 0 outside
+// of tool calls
+func invocationID(ctx context.Context) int64 {
+	id, _ := ctx.Value(invocationKey{}).(int64)
+	return id
+}
+
+// trackHandler wraps a tool handler so its calls are listed on the dashboard
+// and keep the workspace they name active for auto-stop
+func (s *Server) trackHandler(tool string, handler mcp.Handler) mcp.Handler {
+	return func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		id := s.invocations.start(tool, SessionID(ctx), UserName(ctx))
+		if workspace := workspaceArg(tool, params); workspace != "" {
+			defer s.activity.begin(UserName(ctx), workspace)()
+		}
+		ctx, span := s.tracer.startSpan(ctx, tool, spanKindServer)
+		span.setAttribute("mcp.tool.name", tool)
+		span.setAttribute("mcp.tool.args_hash", argsHash(params))
+		span.setAttribute("devpod.job_id", id)
+		if session := SessionID(ctx); session != "" {
+			span.setAttribute("mcp.session.id", session)
+		}
+		result, err := handler(context.WithValue(ctx, invocationKey{}, id), params)
+		var rpcErr *mcp.RPCError
+		if errors.As(err, &rpcErr) {
+			span.setAttribute("rpc.jsonrpc.error_code", rpcErr.Code)
+		}
+		span.finish(err)
+		s.invocations.finish(id, err)
+		return result, err
+	}
+}
+
+// dashboardWorkspace is a workspace row of the dashboard
+type dashboardWorkspace struct {
+	Name        string `json:"name"`
+	Provider    string `json:"provider"`
+	IDE         string `json:"ide,omitempty"`
+	State       string `json:"state"`
+	IdleSeconds *int64 `json:"idleSeconds,omitempty"`
+}
+
+// dashboardProvider is a provider row of the dashboard
+type dashboardProvider struct {
+	Name        string `json:"name"`
+	Version     string `json:"version,omitempty"`
+	Initialized bool   `json:"initialized"`
+}
+
+// dashboardData is everything the dashboard page shows
+type dashboardData struct {
+	Version    string               `json:"version"`
+	Generated  time.Time            `json:"generated"`
+	Workspaces []dashboardWorkspace `json:"workspaces"`
+	Providers  []dashboardProvider  `json:"providers"`
+	// Running lists the tool calls in progress and Recent the last finished
+	Running   []toolInvocation `json:"running"`
+	Recent    []toolInvocation `json:"recent"`
+	Prebuilds []prebuild       `json:"prebuilds"`
+	// Warnings lists the sources that could not be read
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// dashboardData collects the dashboard from devpod and the server state
+func (s *Server) dashboardData(ctx context.Context) dashboardData {
+	data := dashboardData{
+		Version:    s.opts.Version,
+		Generated:  time.Now().UTC(),
+		Workspaces: []dashboardWorkspace{},
+		Providers:  []dashboardProvider{},
+		Prebuilds:  []prebuild{},
+	}
+	data.Running, data.Recent = s.invocations.snapshot(UserName(ctx))
+
+	workspaces, err := s.listWorkspaces(ctx)
+	if err != nil {
+		data.Warnings = append(data.Warnings, "workspaces: "+err.Error())
+	}
+	data.Workspaces = make([]dashboardWorkspace, len(workspaces))
+	var wg sync.WaitGroup
+	for i, workspace := range workspaces {
+		wg.Add(1)
+		go func(i int, workspace DevPodWorkspace) {
+			defer wg.Done()
+			data.Workspaces[i] = dashboardWorkspace{
+				Name:        workspace.ID,
+				Provider:    workspace.Provider.Name,
+				IDE:         workspace.IDE.Name,
+				State:       s.getWorkspaceState(ctx, workspace.ID),
+				IdleSeconds: workspace.IdleSeconds,
+			}
+		}(i, workspace)
+	}
+	wg.Wait()
+
+	output, err := s.output(ctx, []string{"provider", "list", "--output", "json"})
+	var providers map[string]DevPodProvider
+	if err == nil {
+		err = json.Unmarshal(output, &providers)
+	}
+	if err != nil {
+		data.Warnings = append(data.Warnings, "providers: "+err.Error())
+	}
+	for name, provider := range providers {
+		data.Providers = append(data.Providers, dashboardProvider{
+			Name:        name,
+			Version:     provider.Config.Version,
+			Initialized: provider.State.Initialized,
+		})
+	}
+	sort.Slice(data.Providers, func(i, j int) bool { return data.Providers[i].Name < data.Providers[j].Name })
+
+	for _, build := range s.state(ctx).Prebuilds() {
+		if build.Status == prebuildRunning {
+			build.Output = ""
+			data.Prebuilds = append(data.Prebuilds, build)
+		}
+	}
+	return data
+}
+
+// DashboardHandler returns an HTTP handler serving a status page of the
+// workspaces, providers, tool calls and running prebuilds. The page polls
+// the same URL with ?format=json. It is read-only and unauthenticated, so
+// only serve it where the MCP endpoints themselves are reachable. Behind
+// authentication, it shows the requesting user's workspaces and calls.
+func (s *Server) DashboardHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if r.URL.Query().Get("format") != "json" {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Header().Set("Content-Security-Policy", "default-src 'none'; script-src 'unsafe-inline'; style-src 'unsafe-inline'; connect-src 'self'")
+			if _, err := w.Write([]byte(dashboardPage)); err != nil {
+				log.Printf("WARNING: failed to write dashboard: %v", err)
+			}
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), dashboardTimeout)
+		defer cancel()
+		if user := httptransport.RequestUser(r); user != "" {
+			ctx = WithUser(ctx, user)
+		}
+		encoded, err := json.Marshal(s.dashboardData(ctx))
+		if err != nil {
+			http.Error(w, "Internal error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if _, err := w.Write([]byte(s.redactor.redact(string(encoded)))); err != nil {
+			log.Printf("WARNING: failed to write dashboard data: %v", err)
+		}
+	})
+}
+
+// dashboardPage renders the dashboard data. Values are inserted as text, never
+// as markup.
+const dashboardPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>DevPod MCP Server</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 2em; color: #222; }
+h1 { font-size: 1.4em; }
+h2 { font-size: 1.1em; margin-top: 2em; }
+table { border-collapse: collapse; min-width: 40em; }
+th, td { text-align: left; padding: 0.3em 0.8em; border-bottom: 1px solid #ddd; }
+.muted { color: #888; }
+.error { color: #b00020; }
+</style>
+</head>
+<body>
+<h1>DevPod MCP Server <span id="version" class="muted"></span></h1>
+<p class="muted">Updated <span id="generated">never</span></p>
+<p id="warnings" class="error"></p>
+<h2>Workspaces</h2>
+<table><thead><tr><th>Name</th><th>Provider</th><th>IDE</th><th>State</th><th>Idle</th></tr></thead><tbody id="workspaces"></tbody></table>
+<h2>Providers</h2>
+<table><thead><tr><th>Name</th><th>Version</th><th>Initialized</th></tr></thead><tbody id="providers"></tbody></table>
+<h2>Running</h2>
+<table><thead><tr><th>Tool</th><th>Session</th><th>Started</th></tr></thead><tbody id="running"></tbody></table>
+<h2>Prebuilds</h2>
+<table><thead><tr><th>ID</th><th>Source</th><th>Repository</th><th>Started</th></tr></thead><tbody id="prebuilds"></tbody></table>
+<h2>Recent tool calls</h2>
+<table><thead><tr><th>Tool</th><th>Session</th><th>Started</th><th>Duration</th><th>Error</th></tr></thead><tbody id="recent"></tbody></table>
+<script>
+function duration(seconds) {
+  if (seconds == null) return "";
+  if (seconds < 60) return seconds + "s";
+  if (seconds < 3600) return Math.floor(seconds / 60) + "m";
+  return Math.floor(seconds / 3600) + "h";
+}
+function time(value) { return new Date(value).toLocaleTimeString(); }
+function fill(id, rows) {
+  var body = document.getElementById(id);
+  body.replaceChildren();
+  if (rows.length === 0) {
+    var td = document.createElement("td");
+    td.className = "muted";
+    td.colSpan = body.parentNode.querySelectorAll("th").length;
+    td.textContent = "none";
+    body.appendChild(document.createElement("tr")).appendChild(td);
+    return;
+  }
+  rows.forEach(function (cells) {
+    var tr = document.createElement("tr");
+    cells.forEach(function (cell) {
+      var td = document.createElement("td");
+      td.textContent = cell == null ? "" : String(cell);
+      tr.appendChild(td);
+    });
+    body.appendChild(tr);
+  });
+}
+function refresh() {
+  fetch(location.pathname + "?format=json", {cache: "no-store"})
+    .then(function (response) { return response.json(); })
+    .then(function (data) {
+      document.getElementById("version").textContent = data.version;
+      document.getElementById("generated").textContent = time(data.generated);
+      document.getElementById("warnings").textContent = (data.warnings || []).join("; ");
+      fill("workspaces", data.workspaces.map(function (w) { return [w.name, w.provider, w.ide, w.state, duration(w.idleSeconds)]; }));
+      fill("providers", data.providers.map(function (p) { return [p.name, p.version, p.initialized ? "yes" : "no"]; }));
+      fill("running", data.running.map(function (c) { return [c.tool, c.session, time(c.started)]; }));
+      fill("prebuilds", data.prebuilds.map(function (b) { return [b.id, b.source, b.repository, time(b.started)]; }));
+      fill("recent", data.recent.map(function (c) { return [c.tool, c.session, time(c.started), c.durationMs + " ms", c.error]; }));
+    })
+    .catch(function (err) { document.getElementById("warnings").textContent = "Refresh failed: " + err; });
+}
+refresh();
+setInterval(refresh, 5000);
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDashboard(t *testing.T) {
+	runner := &fakeRunner{outputs: map[string]string{
+		"list --output json":          `[{"id":"api","provider":{"name":"docker"},"ide":{"name":"vscode"}}]`,
+		"status api --output json":    `{"state":"Running"}`,
+		"provider list --output json": `{"docker":{"config":{"name":"docker","version":"v0.5.0"},"state":{"initialized":true}}}`,
+	}}
+	s := newTestServer(t, runner)
+
+	ctx := WithSessionID(context.Background(), "session-a")
+	if _, err := s.MCP().GetHandler("devpod_listWorkspaces")(ctx, json.RawMessage(`{}`)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := s.MCP().GetHandler("devpod_stopWorkspace")(ctx, json.RawMessage(`{}`)); err == nil {
+		t.Fatal("Expected an error without a name")
+	}
+
+	rec := httptest.NewRecorder()
+	s.DashboardHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/dashboard?format=json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+	var data dashboardData
+	if err := json.Unmarshal(rec.Body.Bytes(), &data); err != nil {
+		t.Fatalf("Failed to parse dashboard data: %v", err)
+	}
+
+	if len(data.Workspaces) != 1 || data.Workspaces[0] != (dashboardWorkspace{Name: "api", Provider: "docker", IDE: "vscode", State: "Running"}) {
+		t.Errorf("Unexpected workspaces: %+v", data.Workspaces)
+	}
+	if len(data.Providers) != 1 || !data.Providers[0].Initialized || data.Providers[0].Version != "v0.5.0" {
+		t.Errorf("Unexpected providers: %+v", data.Providers)
+	}
+	if len(data.Running) != 0 || len(data.Recent) != 2 {
+		t.Fatalf("Expected 2 finished calls, got running %+v recent %+v", data.Running, data.Recent)
+	}
+	stop, list := data.Recent[0], data.Recent[1]
+	if stop.Tool != "devpod_stopWorkspace" || stop.Error == "" || stop.Session != "session-a" || stop.DurationMs == nil {
+		t.Errorf("Unexpected most recent call: %+v", stop)
+	}
+	if list.Tool != "devpod_listWorkspaces" || list.Error != "" {
+		t.Errorf("Unexpected first call: %+v", list)
+	}
+
+	rec = httptest.NewRecorder()
+	s.DashboardHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/dashboard", nil))
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") || !strings.Contains(rec.Body.String(), `id="workspaces"`) {
+		t.Errorf("Expected the dashboard page, got %q", rec.Header().Get("Content-Type"))
+	}
+}
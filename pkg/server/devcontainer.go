@@ -0,0 +1,321 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+// defaultDevcontainerImage is the base image of composed devcontainers that
+// do not name one
+const defaultDevcontainerImage = "mcr.microsoft.com/devcontainers/base:ubuntu"
+
+// featureID matches devcontainer feature references, e.g.
+// ghcr.io/devcontainers/features/go:1 or ./local-feature
+var featureID = regexp.MustCompile(`^[A-Za-z0-9._/:@-]+$`)
+
+// devcontainerConfig is the subset of devcontainer.json that
+// devpod_composeDevcontainer writes
+type devcontainerConfig struct {
+	Name              string                            `json:"name,omitempty"`
+	Image             string                            `json:"image"`
+	Features          map[string]map[string]interface{} `json:"features,omitempty"`
+	ForwardPorts      []int                             `json:"forwardPorts,omitempty"`
+	PostCreateCommand string                            `json:"postCreateCommand,omitempty"`
+	ContainerEnv      map[string]string                 `json:"containerEnv,omitempty"`
+	RemoteUser        string                            `json:"remoteUser,omitempty"`
+}
+
+// parseFeatures accepts features as a list of IDs or as an object of IDs and
+// their options
+func parseFeatures(raw json.RawMessage) (map[string]map[string]interface{}, error) {
+	features := make(map[string]map[string]interface{})
+	if len(raw) == 0 || string(raw) == "null" {
+		return features, nil
+	}
+
+	var ids []string
+	if err := json.Unmarshal(raw, &ids); err == nil {
+		for _, id := range ids {
+			features[id] = map[string]interface{}{}
+		}
+	} else if err := json.Unmarshal(raw, &features); err != nil {
+		return nil, fmt.Errorf("features must be a list of feature IDs or an object of feature IDs and their options")
+	}
+
+	for id, options := range features {
+		if !featureID.MatchString(id) {
+			return nil, fmt.Errorf("invalid feature %q", id)
+		}
+		if options == nil {
+			features[id] = map[string]interface{}{}
+		}
+	}
+	return features, nil
+}
+
+// validate checks the values devcontainer tooling would reject
+func (c devcontainerConfig) validate() error {
+	if strings.TrimSpace(c.Image) == "" || strings.ContainsAny(c.Image, " \t\n") {
+		return fmt.Errorf("invalid image %q", c.Image)
+	}
+	for _, port := range c.ForwardPorts {
+		if port < 1 || port > 65535 {
+			return fmt.Errorf("invalid port %d", port)
+		}
+	}
+	return nil
+}
+
+// encode returns the devcontainer.json content, features and ports in order
+func (c devcontainerConfig) encode() ([]byte, error) {
+	ports := append([]int{}, c.ForwardPorts...)
+	sort.Ints(ports)
+	c.ForwardPorts = ports
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(c); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// composeTarget resolves the folder a devcontainer is written into like a
+// local source. A folder that does not exist yet is created when its parent
+// does.
+func (s *Server) composeTarget(ctx context.Context, path string) (string, bool, error) {
+	if dir, err := s.resolveLocalSource(ctx, path); err == nil {
+		return dir, false, nil
+	}
+
+	trimmed := strings.TrimRight(path, `/\`)
+	name := filepath.Base(trimmed)
+	if trimmed == "" || name == "." || name == ".." || name == "~" {
+		return "", false, fmt.Errorf("invalid folder %q", path)
+	}
+	parent, err := s.resolveLocalSource(ctx, filepath.Dir(trimmed))
+	if err != nil {
+		return "", false, err
+	}
+	dir := filepath.Join(parent, name)
+	if _, err := os.Lstat(dir); err == nil {
+		return "", false, fmt.Errorf("%s exists and is not a directory", path)
+	}
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		return "", false, fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	return dir, true, nil
+}
+
+// writeDevcontainer writes .devcontainer/devcontainer.json into dir and
+// returns its path. An existing file is only replaced with overwrite.
+func writeDevcontainer(dir string, content []byte, overwrite bool) (string, error) {
+	path := filepath.Join(dir, ".devcontainer", "devcontainer.json")
+	if !overwrite {
+		if _, err := os.Stat(path); err == nil {
+			return "", fmt.Errorf("%s already exists (set overwrite to replace it)", path)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", fmt.Errorf("failed to create .devcontainer: %w", err)
+	}
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		return "", fmt.Errorf("failed to write devcontainer.json: %w", err)
+	}
+	return path, nil
+}
+
+// registerDevcontainerTools registers the tool that composes devcontainer.json
+// files
+func (s *Server) registerDevcontainerTools() {
+	// Compose devcontainer
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_composeDevcontainer",
+		Description: "Write a .devcontainer/devcontainer.json built from a base image, features, forwarded ports and a post-create command into a new or existing local folder, and optionally create a workspace from it",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"path": map[string]interface{}{
+					"type":        "string",
+					"description": "The folder to write into; a missing folder is created inside an existing one",
+				},
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "The display name of the devcontainer (optional)",
+				},
+				"image": map[string]interface{}{
+					"type":        "string",
+					"description": "The base image (default: " + defaultDevcontainerImage + ")",
+				},
+				"features": map[string]interface{}{
+					"type":        []string{"array", "object"},
+					"description": "Devcontainer features as a list of IDs, e.g. [\"ghcr.io/devcontainers/features/go:1\"], or an object of IDs and their options, e.g. {\"ghcr.io/devcontainers/features/node:1\": {\"version\": \"20\"}} (optional)",
+				},
+				"forwardPorts": map[string]interface{}{
+					"type":        "array",
+					"items":       map[string]interface{}{"type": "integer"},
+					"description": "Container ports to forward (optional)",
+				},
+				"postCreateCommand": map[string]interface{}{
+					"type":        "string",
+					"description": "Command run once after the container is created, e.g. npm install (optional)",
+				},
+				"containerEnv": map[string]interface{}{
+					"type":                 "object",
+					"additionalProperties": map[string]interface{}{"type": "string"},
+					"description":          "Environment variables of the container (optional)",
+				},
+				"remoteUser": map[string]interface{}{
+					"type":        "string",
+					"description": "The user tools run as in the container (optional)",
+				},
+				"overwrite": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Replace an existing devcontainer.json (default: false)",
+				},
+				"workspace": map[string]interface{}{
+					"type":        "string",
+					"description": "Create a workspace with this name from the folder once the file is written (optional)",
+				},
+				"provider": map[string]interface{}{
+					"type":        "string",
+					"description": "The provider of the created workspace (optional)",
+				},
+				"ide": map[string]interface{}{
+					"type":        "string",
+					"description": "The IDE of the created workspace (optional)",
+				},
+			},
+			"required": []string{"path"},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var composeParams struct {
+			Path              string            `json:"path"`
+			Name              string            `json:"name,omitempty"`
+			Image             string            `json:"image,omitempty"`
+			Features          json.RawMessage   `json:"features,omitempty"`
+			ForwardPorts      []int             `json:"forwardPorts,omitempty"`
+			PostCreateCommand string            `json:"postCreateCommand,omitempty"`
+			ContainerEnv      map[string]string `json:"containerEnv,omitempty"`
+			RemoteUser        string            `json:"remoteUser,omitempty"`
+			Overwrite         bool              `json:"overwrite,omitempty"`
+			Workspace         string            `json:"workspace,omitempty"`
+			Provider          string            `json:"provider,omitempty"`
+			IDE               string            `json:"ide,omitempty"`
+		}
+
+		if err := json.Unmarshal(params, &composeParams); err != nil {
+			return nil, mcp.NewInvalidParamsError("Invalid compose devcontainer parameters")
+		}
+
+		if composeParams.Path == "" {
+			return nil, mcp.NewInvalidParamsError("Path is required")
+		}
+		if composeParams.Workspace == "" && (composeParams.Provider != "" || composeParams.IDE != "") {
+			return nil, mcp.NewInvalidParamsError("provider and ide only apply when workspace is set")
+		}
+		if composeParams.Image == "" {
+			composeParams.Image = defaultDevcontainerImage
+		}
+		features, err := parseFeatures(composeParams.Features)
+		if err != nil {
+			return nil, mcp.NewInvalidParamsError(err.Error())
+		}
+		config := devcontainerConfig{
+			Name:              composeParams.Name,
+			Image:             composeParams.Image,
+			Features:          features,
+			ForwardPorts:      composeParams.ForwardPorts,
+			PostCreateCommand: composeParams.PostCreateCommand,
+			ContainerEnv:      composeParams.ContainerEnv,
+			RemoteUser:        composeParams.RemoteUser,
+		}
+		if err := config.validate(); err != nil {
+			return nil, mcp.NewInvalidParamsError(err.Error())
+		}
+		content, err := config.encode()
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode devcontainer.json: %w", err)
+		}
+
+		if composeParams.Workspace != "" {
+			if exists, err := s.workspaceExists(ctx, composeParams.Workspace); err != nil {
+				return nil, newDevPodError("failed to check for existing workspace", err, nil)
+			} else if exists {
+				return nil, mcp.NewInvalidParamsError(fmt.Sprintf("Workspace %s already exists", composeParams.Workspace))
+			}
+			if err := s.opts.Names.check(composeParams.Workspace); err != nil {
+				return nil, mcp.NewInvalidParamsError(err.Error())
+			}
+		}
+
+		dir, created, err := s.composeTarget(ctx, composeParams.Path)
+		if err != nil {
+			return nil, mcp.NewInvalidParamsError(err.Error())
+		}
+		path, err := writeDevcontainer(dir, content, composeParams.Overwrite)
+		if err != nil {
+			if created {
+				os.Remove(dir)
+			}
+			return nil, mcp.NewInvalidParamsError(err.Error())
+		}
+
+		result := map[string]interface{}{
+			"folder":        dir,
+			"folderCreated": created,
+			"path":          path,
+			"devcontainer":  json.RawMessage(content),
+			"message":       fmt.Sprintf("Wrote %s", path),
+		}
+		if composeParams.Workspace == "" {
+			return result, nil
+		}
+
+		// Create the workspace like devpod_createWorkspace with a local source
+		defaults := s.session(ctx)
+		if composeParams.Provider == "" {
+			composeParams.Provider = defaults.Provider
+		}
+		if composeParams.IDE == "" {
+			composeParams.IDE = defaults.IDE
+		}
+		spec := &workspaceSpec{Name: composeParams.Workspace}
+		if composeParams.Provider != "" {
+			spec.Provider = &specComponent{Name: composeParams.Provider}
+		}
+		if composeParams.IDE != "" {
+			spec.IDE = &specComponent{Name: composeParams.IDE}
+		}
+
+		start := time.Now()
+		output, err := s.createFromSpec(ctx, spec, dir, "Workspace created from a composed devcontainer")
+		if err != nil {
+			return nil, withPhases(newDevPodError(fmt.Sprintf("wrote %s but failed to create workspace", path), err, output), output)
+		}
+		result["name"] = composeParams.Workspace
+		result["message"] = fmt.Sprintf("Wrote %s and created workspace %s", path, composeParams.Workspace)
+		result["output"] = string(output)
+		result["phases"] = parsePhases(string(output), false)
+		result["durationMs"] = durationMs(start)
+		if details, err := s.describeWorkspace(ctx, composeParams.Workspace); err == nil {
+			result["workspace"] = details
+		} else {
+			log.Printf("WARNING: failed to describe workspace %s: %v", composeParams.Workspace, err)
+		}
+		return result, nil
+	})
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/protobomb/mcp-server-framework/pkg/transport"
+)
+
+func TestParseFeatures(t *testing.T) {
+	features, err := parseFeatures(json.RawMessage(`["ghcr.io/devcontainers/features/go:1"]`))
+	if err != nil || !reflect.DeepEqual(features, map[string]map[string]interface{}{"ghcr.io/devcontainers/features/go:1": {}}) {
+		t.Errorf("Unexpected features %v (%v)", features, err)
+	}
+	features, err = parseFeatures(json.RawMessage(`{"ghcr.io/devcontainers/features/node:1":{"version":"20"}}`))
+	if err != nil || features["ghcr.io/devcontainers/features/node:1"]["version"] != "20" {
+		t.Errorf("Unexpected features %v (%v)", features, err)
+	}
+	for _, raw := range []string{`"go"`, `["has space"]`, `{"x":1}`} {
+		if _, err := parseFeatures(json.RawMessage(raw)); err == nil {
+			t.Errorf("Expected %s to be rejected", raw)
+		}
+	}
+}
+
+func TestComposeDevcontainer(t *testing.T) {
+	root := t.TempDir()
+	runner := &fakeRunner{outputs: map[string]string{"list --output json": "[]"}}
+	s := New(transport.NewSTDIOTransport(), Options{
+		Runner:        runner,
+		StatePath:     filepath.Join(t.TempDir(), "state.json"),
+		WorkspaceRoot: root,
+	})
+	ctx := context.Background()
+	compose := s.MCP().GetHandler("devpod_composeDevcontainer")
+
+	result, err := compose(ctx, json.RawMessage(`{
+		"path": "api",
+		"image": "mcr.microsoft.com/devcontainers/go:1.22",
+		"features": {"ghcr.io/devcontainers/features/node:1": {"version": "20"}},
+		"forwardPorts": [8080, 3000],
+		"postCreateCommand": "go mod download && npm ci",
+		"workspace": "api",
+		"provider": "docker"
+	}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	res := result.(map[string]interface{})
+	if res["folderCreated"] != true {
+		t.Errorf("Expected the folder to be created, got %v", res)
+	}
+
+	data, err := os.ReadFile(filepath.Join(root, "api", ".devcontainer", "devcontainer.json"))
+	if err != nil {
+		t.Fatalf("Expected devcontainer.json: %v", err)
+	}
+	var written devcontainerConfig
+	if err := json.Unmarshal(data, &written); err != nil {
+		t.Fatalf("Invalid devcontainer.json: %v", err)
+	}
+	if written.Image != "mcr.microsoft.com/devcontainers/go:1.22" || !reflect.DeepEqual(written.ForwardPorts, []int{3000, 8080}) ||
+		written.PostCreateCommand != "go mod download && npm ci" || written.Features["ghcr.io/devcontainers/features/node:1"]["version"] != "20" {
+		t.Errorf("Unexpected devcontainer.json: %s", data)
+	}
+
+	dir, _ := filepath.EvalSymlinks(filepath.Join(root, "api"))
+	want := []string{"up", dir, "--id", "api", "--provider", "docker"}
+	var created bool
+	for _, call := range runner.calls {
+		if reflect.DeepEqual(call, want) {
+			created = true
+		}
+	}
+	if !created {
+		t.Errorf("Expected %v, got calls %v", want, runner.calls)
+	}
+
+	if _, err := compose(ctx, json.RawMessage(`{"path":"api"}`)); err == nil {
+		t.Error("Expected an existing devcontainer.json to be kept without overwrite")
+	}
+	if _, err := compose(ctx, json.RawMessage(`{"path":"api","overwrite":true}`)); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	for _, params := range []string{
+		`{"path":"../outside"}`,
+		`{"path":"missing/nested"}`,
+		`{"path":"web","forwardPorts":[70000]}`,
+		`{"path":"web","provider":"docker"}`,
+	} {
+		if _, err := compose(ctx, json.RawMessage(params)); err == nil {
+			t.Errorf("Expected %s to be rejected", params)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "web")); !os.IsNotExist(err) {
+		t.Error("Expected rejected calls to leave no folder behind")
+	}
+}
@@ -0,0 +1,505 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"time"
+)
+
+// Runner executes devpod CLI commands. The default ExecRunner shells out to
+// the devpod binary; embedders can supply their own for sandboxing or tests.
+type Runner interface {
+	// Run executes devpod with the given arguments and returns stdout and stderr
+	Run(ctx context.Context, args []string) (stdout []byte, stderr []byte, err error)
+}
+
+// commandEnvKey is the context key for extra devpod environment variables
+type commandEnvKey struct{}
+
+// WithCommandEnv returns a context whose devpod invocations receive the given
+// additional environment variables ("KEY=value")
+func WithCommandEnv(ctx context.Context, env []string) context.Context {
+	// Copied so contexts derived from the same parent never share, and
+	// overwrite, one backing array
+	return context.WithValue(ctx, commandEnvKey{}, append(append([]string(nil), CommandEnv(ctx)...), env...))
+}
+
+// CommandEnv returns the extra environment variables attached to ctx. Custom
+// Runner implementations should pass them to the devpod process.
+func CommandEnv(ctx context.Context) []string {
+	env, _ := ctx.Value(commandEnvKey{}).([]string)
+	return env
+}
+
+// outputWriterKey is the context key for the writer devpod output is copied
+// to while it runs
+type outputWriterKey struct{}
+
+// WithOutputWriter returns a context whose devpod invocations copy their
+// stdout and stderr to w as it is produced, e.g. to stream it to the client.
+// A nil w stops copying.
+func WithOutputWriter(ctx context.Context, w io.Writer) context.Context {
+	return context.WithValue(ctx, outputWriterKey{}, w)
+}
+
+// OutputWriter returns the writer attached to ctx, or nil if none. Custom
+// Runner implementations should copy the devpod output to it as it arrives;
+// writes may come from several goroutines.
+func OutputWriter(ctx context.Context) io.Writer {
+	w, _ := ctx.Value(outputWriterKey{}).(io.Writer)
+	return w
+}
+
+// commandInputKey is the context key for the data devpod reads on stdin
+type commandInputKey struct{}
+
+// WithCommandInput returns a context whose devpod invocations read data on
+// stdin, e.g. a file sent into a workspace with devpod ssh. Each attempt of a
+// retried command reads it from the start.
+func WithCommandInput(ctx context.Context, data []byte) context.Context {
+	return context.WithValue(ctx, commandInputKey{}, data)
+}
+
+// CommandInput returns the stdin data attached to ctx, or nil if none.
+// Custom Runner implementations should pass it to the devpod process.
+func CommandInput(ctx context.Context) []byte {
+	data, _ := ctx.Value(commandInputKey{}).([]byte)
+	return data
+}
+
+// ExecRunner runs the devpod binary as a subprocess
+type ExecRunner struct {
+	// Path is the devpod binary to execute (default: "devpod" resolved via PATH)
+	Path string
+}
+
+// Run implements Runner
+func (r *ExecRunner) Run(ctx context.Context, args []string) ([]byte, []byte, error) {
+	path, err := r.resolve()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cmd := exec.CommandContext(ctx, path, args...)
+
+	// Set environment variables
+	cmd.Env = append(os.Environ(), CommandEnv(ctx)...)
+	if data := CommandInput(ctx); data != nil {
+		cmd.Stdin = bytes.NewReader(data)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if w := OutputWriter(ctx); w != nil {
+		cmd.Stdout = io.MultiWriter(&stdout, w)
+		cmd.Stderr = io.MultiWriter(&stderr, w)
+	}
+
+	err = cmd.Run()
+	return stdout.Bytes(), stderr.Bytes(), err
+}
+
+// resolve returns the binary Run executes, looked up on PATH when Path has
+// no directory. On Windows the lookup adds extensions such as .exe.
+func (r *ExecRunner) resolve() (string, error) {
+	name := r.Path
+	if name == "" {
+		name = "devpod"
+	}
+	path, err := exec.LookPath(name)
+	if err == nil {
+		return path, nil
+	}
+	return "", binaryNotFound(runtime.GOOS, name, err)
+}
+
+// binaryNotFound explains why a binary could not be resolved on goos
+func binaryNotFound(goos, name string, err error) error {
+	if errors.Is(err, exec.ErrDot) {
+		return fmt.Errorf("%s resolves to the current directory, which is not searched for binaries; use an absolute path: %w", name, err)
+	}
+	if goos == "windows" && filepath.Ext(name) == "" {
+		return fmt.Errorf("%s.exe not found on PATH or it is not executable: %w", name, err)
+	}
+	return fmt.Errorf("%s not found on PATH or it is not executable: %w", name, err)
+}
+
+// DevPodWorkspace represents a DevPod workspace
+type DevPodWorkspace struct {
+	ID                string                  `json:"id"`
+	UID               string                  `json:"uid"`
+	Picture           string                  `json:"picture,omitempty"`
+	Provider          DevPodWorkspaceProvider `json:"provider"`
+	Machine           map[string]interface{}  `json:"machine"`
+	IDE               DevPodWorkspaceIDE      `json:"ide"`
+	Source            DevPodWorkspaceSource   `json:"source"`
+	CreationTimestamp string                  `json:"creationTimestamp"`
+	LastUsed          string                  `json:"lastUsed"`
+	Context           string                  `json:"context"`
+
+	// AgeSeconds and IdleSeconds are derived from CreationTimestamp and
+	// LastUsed so clients don't have to parse timestamps themselves
+	AgeSeconds  *int64 `json:"ageSeconds,omitempty"`
+	IdleSeconds *int64 `json:"idleSeconds,omitempty"`
+
+	// Tags and Note are attached through devpod_tagWorkspace and kept by
+	// this server, not devpod
+	Tags []string `json:"tags,omitempty"`
+	Note string   `json:"note,omitempty"`
+	// CreatedBy is the client that created the workspace through this server
+	CreatedBy *workspaceCreator `json:"createdBy,omitempty"`
+}
+
+// normalizeTimes fills in the numeric age and idle fields relative to now
+func (w *DevPodWorkspace) normalizeTimes(now time.Time) {
+	if seconds, ok := secondsSince(w.CreationTimestamp, now); ok {
+		w.AgeSeconds = &seconds
+	}
+	if seconds, ok := secondsSince(w.LastUsed, now); ok {
+		w.IdleSeconds = &seconds
+	}
+}
+
+// DevPodWorkspaceProvider represents the provider configuration for a workspace
+type DevPodWorkspaceProvider struct {
+	Name    string                 `json:"name"`
+	Options map[string]interface{} `json:"options"`
+}
+
+// DevPodWorkspaceIDE represents the IDE configuration for a workspace
+type DevPodWorkspaceIDE struct {
+	Name    string                 `json:"name"`
+	Options map[string]interface{} `json:"options,omitempty"`
+}
+
+// DevPodWorkspaceSource represents the source configuration for a workspace
+type DevPodWorkspaceSource struct {
+	Image          string `json:"image,omitempty"`
+	GitRepository  string `json:"gitRepository,omitempty"`
+	GitBranch      string `json:"gitBranch,omitempty"`
+	GitCommit      string `json:"gitCommit,omitempty"`
+	GitPRReference string `json:"gitPRReference,omitempty"`
+	LocalFolder    string `json:"localFolder,omitempty"`
+}
+
+// DevPodProvider represents a DevPod provider
+type DevPodProvider struct {
+	Config DevPodProviderConfig `json:"config"`
+	State  DevPodProviderState  `json:"state"`
+	// Default is set on the provider devpod uses when none is given
+	Default bool `json:"default,omitempty"`
+}
+
+// DevPodProviderConfig represents the configuration of a DevPod provider
+type DevPodProviderConfig struct {
+	Name         string                 `json:"name"`
+	Version      string                 `json:"version"`
+	Description  string                 `json:"description"`
+	Icon         string                 `json:"icon,omitempty"`
+	Home         string                 `json:"home,omitempty"`
+	Source       map[string]interface{} `json:"source"`
+	OptionGroups []interface{}          `json:"optionGroups"`
+	Options      map[string]interface{} `json:"options"`
+	Agent        map[string]interface{} `json:"agent"`
+	Exec         map[string]interface{} `json:"exec"`
+}
+
+// DevPodProviderState represents the state of a DevPod provider
+type DevPodProviderState struct {
+	Initialized       bool                   `json:"initialized"`
+	Options           map[string]interface{} `json:"options"`
+	CreationTimestamp string                 `json:"creationTimestamp"`
+}
+
+// executeDevPodCommandWithDebug executes a DevPod command with comprehensive debug logging
+func (s *Server) executeDevPodCommandWithDebug(ctx context.Context, args []string) ([]byte, error) {
+	// Provider options passed as arguments and command output may hold credentials
+	argsStr := s.redactor.redact(fmt.Sprint(args))
+	debugf("Executing devpod command with args: %s", argsStr)
+
+	// Capture both stdout and stderr separately for better debugging
+	stdoutBytes, stderrBytes, err := s.run(ctx, args)
+	stdoutStr := s.redactor.redact(string(stdoutBytes))
+	stderrStr := s.redactor.redact(string(stderrBytes))
+
+	debugf("Command completed with error: %v", err)
+	debugf("Command stdout (%d bytes): %q", len(stdoutBytes), stdoutStr)
+	debugf("Command stderr (%d bytes): %q", len(stderrBytes), stderrStr)
+
+	if err != nil {
+		log.Printf("ERROR: devpod command failed: %v", err)
+		return nil, &CommandError{Args: args, Stdout: stdoutBytes, Stderr: stderrBytes, Err: err}
+	}
+
+	debugf("Command completed successfully, returning %d bytes", len(stdoutBytes))
+	return stdoutBytes, nil
+}
+
+// commandContext returns a devpod command as it runs for the caller: in the
+// devpod context selected for the session or user and with the user's
+// DEVPOD_HOME
+func (s *Server) commandContext(ctx context.Context, args []string) (context.Context, []string, error) {
+	// devpod ssh forwards git credentials like devpod up, so it gets the
+	// scoping chosen when the workspace was created
+	if len(args) > 1 && args[0] == "ssh" {
+		ctx = s.withCredentialScopes(ctx, s.state(ctx).CredentialScopes(args[1]))
+	}
+	if devpodContext := s.devpodContext(ctx); devpodContext != "" {
+		args = append(append([]string{}, args...), "--context", devpodContext)
+	}
+	// Added last so per-call env cannot point devpod at another user's home
+	env, err := s.userEnv(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(env) > 0 {
+		ctx = WithCommandEnv(ctx, env)
+	}
+	return ctx, args, nil
+}
+
+// devpodContext returns the devpod context selected for the session or
+// user, or "" for the default one
+func (s *Server) devpodContext(ctx context.Context) string {
+	if devpodContext := s.session(ctx).Context; devpodContext != "" {
+		return devpodContext
+	}
+	return s.user(ctx).Context
+}
+
+// run executes a devpod command through the runner once the concurrency
+// limits allow it, as commandContext prepares it
+func (s *Server) run(ctx context.Context, args []string) ([]byte, []byte, error) {
+	return s.runWith(ctx, s.runner, args)
+}
+
+// runWith is run with another runner standing in for devpod, such as the
+// SSH pool
+func (s *Server) runWith(ctx context.Context, runner Runner, args []string) ([]byte, []byte, error) {
+	ctx, args, err := s.commandContext(ctx, args)
+	if err != nil {
+		return nil, nil, err
+	}
+	// JSON output is data for the server, not console output
+	if OutputWriter(ctx) != nil && jsonOutput(args) {
+		ctx = WithOutputWriter(ctx, nil)
+	}
+
+	// Transient failures are retried with exponential backoff, giving up
+	// the concurrency slot while waiting
+	for attempt := 1; ; attempt++ {
+		stdout, stderr, err := s.runOnce(ctx, runner, args)
+		if err == nil || attempt >= s.opts.Retry.MaxAttempts || ctx.Err() != nil || !s.opts.Retry.retryable(args, err, append(stderr, stdout...)) {
+			recordAttempts(ctx, attempt)
+			return stdout, stderr, err
+		}
+		delay := s.opts.Retry.delay(attempt)
+		// Only the subcommand: the arguments may hold provider options
+		log.Printf("WARNING: devpod %s failed with a transient error (attempt %d of %d), retrying in %s: %s",
+			devpodSubcommand(args), attempt, s.opts.Retry.MaxAttempts, delay.Round(time.Millisecond), s.redactor.redact(err.Error()))
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			recordAttempts(ctx, attempt)
+			return stdout, stderr, err
+		}
+	}
+}
+
+// runOnce runs a devpod command in a slot of the concurrency limiter, unless
+// the circuit breaker is open
+func (s *Server) runOnce(ctx context.Context, runner Runner, args []string) ([]byte, []byte, error) {
+	command := devpodSubcommand(args)
+	ctx, span := s.tracer.startSpan(ctx, "devpod "+command, spanKindClient)
+	span.setAttribute("devpod.command", command)
+	if err := s.breaker.allow(); err != nil {
+		span.finish(err)
+		return nil, nil, err
+	}
+	queued := time.Now()
+	release, err := s.limiter.acquire(ctx)
+	span.setAttribute("devpod.queue_ms", durationMs(queued))
+	if err != nil {
+		s.breaker.record(args, context.Canceled, nil)
+		span.finish(err)
+		return nil, nil, err
+	}
+	defer release()
+	stdout, stderr, err := runner.Run(ctx, args)
+	s.breaker.record(args, err, append(stderr, stdout...))
+	traceCommand(span, err)
+	return stdout, stderr, err
+}
+
+// combinedOutput runs a devpod command and returns stdout followed by stderr
+// as plain text, without terminal escapes and progress redraws
+func (s *Server) combinedOutput(ctx context.Context, args []string) ([]byte, error) {
+	stdout, stderr, err := s.run(ctx, args)
+	return []byte(sanitizeOutput(string(append(stdout, stderr...)))), err
+}
+
+// output runs a devpod command and returns only stdout
+func (s *Server) output(ctx context.Context, args []string) ([]byte, error) {
+	stdout, _, err := s.run(ctx, args)
+	return stdout, err
+}
+
+func (s *Server) checkDevPodAvailable(ctx context.Context) error {
+	infof("Checking DevPod availability...")
+
+	s.resetDevPodVersion()
+	version, err := s.devpodAvailable(ctx)
+	if err != nil {
+		infof("DevPod not available: %v", err)
+		return fmt.Errorf("DevPod binary not found or not executable: %w", err)
+	}
+
+	infof("DevPod %s is available", version)
+	return nil
+}
+
+// defaultProviderOptions holds the options used when bootstrapping a provider.
+// Values are only applied when the corresponding environment variable is set.
+var defaultProviderOptions = map[string]map[string]string{
+	"docker": {
+		"DOCKER_HOST": "DEVPOD_DOCKER_HOST",
+	},
+	"kubernetes": {
+		"KUBERNETES_NAMESPACE": "DEVPOD_KUBERNETES_NAMESPACE",
+	},
+}
+
+// bootstrapProvider adds the given provider when no providers are configured yet
+func (s *Server) bootstrapProvider(ctx context.Context, name string) error {
+	infof("Checking whether provider bootstrap is needed for %s", name)
+
+	output, err := s.executeDevPodCommandWithDebug(ctx, []string{"provider", "list", "--output", "json"})
+	if err != nil {
+		return fmt.Errorf("failed to list providers: %w", err)
+	}
+
+	count := 0
+	var providersMap map[string]DevPodProvider
+	if err := json.Unmarshal(output, &providersMap); err == nil {
+		count = len(providersMap)
+	} else if s.opts.StrictJSON {
+		return unsupportedOutputError("provider list", err)
+	} else if providers, ok := parseTextProviderList(string(output))["providers"].([]map[string]string); ok {
+		count = len(providers)
+	}
+
+	if count > 0 {
+		infof("Found %d configured provider(s), skipping bootstrap", count)
+		return nil
+	}
+
+	args := []string{"provider", "add", name}
+	for option, envVar := range defaultProviderOptions[name] {
+		if value := os.Getenv(envVar); value != "" {
+			args = append(args, "-o", fmt.Sprintf("%s=%s", option, value))
+		}
+	}
+
+	if _, err := s.executeDevPodCommandWithDebug(ctx, args); err != nil {
+		return fmt.Errorf("failed to add provider %s: %w", name, err)
+	}
+
+	infof("Bootstrapped provider %s", name)
+	return nil
+}
+
+// unsupportedOutputError reports a devpod command that did not produce JSON
+// output while strict JSON mode is enabled
+func unsupportedOutputError(command string, err error) error {
+	return fmt.Errorf("unsupported devpod version: `devpod %s --output json` did not return JSON (%v)", command, err)
+}
+
+// WorkspaceDetails combines a workspace's `devpod list` entry with its current state
+type WorkspaceDetails struct {
+	DevPodWorkspace
+	State string `json:"state"`
+}
+
+// listWorkspaces returns every workspace from `devpod list` with its numeric
+// age and idle fields filled in
+func (s *Server) listWorkspaces(ctx context.Context) ([]DevPodWorkspace, error) {
+	output, err := s.output(ctx, []string{"list", "--output", "json"})
+	if err != nil {
+		return nil, err
+	}
+
+	var workspaces []DevPodWorkspace
+	if err := json.Unmarshal(output, &workspaces); err != nil {
+		return nil, fmt.Errorf("failed to parse workspace list: %w", err)
+	}
+
+	now := time.Now()
+	for i := range workspaces {
+		workspaces[i].normalizeTimes(now)
+	}
+	s.attachMetadata(ctx, workspaces)
+	return workspaces, nil
+}
+
+// findWorkspace returns the `devpod list` entry for a workspace, or nil if it does not exist
+func (s *Server) findWorkspace(ctx context.Context, name string) (*DevPodWorkspace, error) {
+	workspaces, err := s.listWorkspaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range workspaces {
+		if workspaces[i].ID == name {
+			return &workspaces[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// workspaceExists reports whether devpod knows a workspace with the given ID
+func (s *Server) workspaceExists(ctx context.Context, name string) (bool, error) {
+	workspace, err := s.findWorkspace(ctx, name)
+	return workspace != nil, err
+}
+
+// describeWorkspace returns the structured metadata and state of a workspace
+func (s *Server) describeWorkspace(ctx context.Context, name string) (*WorkspaceDetails, error) {
+	workspace, err := s.findWorkspace(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if workspace == nil {
+		return nil, fmt.Errorf("workspace %s not found", name)
+	}
+
+	return &WorkspaceDetails{
+		DevPodWorkspace: *workspace,
+		State:           s.getWorkspaceState(ctx, name),
+	}, nil
+}
+
+// getWorkspaceState returns the state reported by `devpod status`, or "Unknown"
+func (s *Server) getWorkspaceState(ctx context.Context, name string) string {
+	output, err := s.output(ctx, []string{"status", name, "--output", "json"})
+	if err != nil {
+		return "Unknown"
+	}
+
+	var status struct {
+		State string `json:"state"`
+	}
+	if err := json.Unmarshal(output, &status); err != nil || status.State == "" {
+		return "Unknown"
+	}
+	return status.State
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// elicitableTypes are the property types elicitation/create can ask for
+var elicitableTypes = map[string]bool{"string": true, "number": true, "integer": true, "boolean": true}
+
+// missingArguments returns the required properties of a tool's input schema
+// that args lacks, and whether the user could be asked for all of them:
+// elicitation only asks for strings, numbers and booleans
+func missingArguments(schema map[string]interface{}, args map[string]interface{}) ([]string, bool) {
+	properties, _ := schema["properties"].(map[string]interface{})
+	var missing []string
+	elicitable := true
+	for _, name := range schemaStrings(schema["required"]) {
+		if value, ok := args[name]; ok && value != nil {
+			continue
+		}
+		missing = append(missing, name)
+		property, _ := properties[name].(map[string]interface{})
+		if kind, _ := property["type"].(string); !elicitableTypes[kind] {
+			elicitable = false
+		}
+	}
+	return missing, elicitable
+}
+
+// elicitArguments asks the user for the missing required arguments of a
+// tool call in a single elicitation/create request and adds the values to
+// args. It returns errElicitationDeclined unless the user provides all of
+// them.
+func (s *Server) elicitArguments(ctx context.Context, tool string, schema map[string]interface{}, args map[string]interface{}, missing []string) error {
+	properties, _ := schema["properties"].(map[string]interface{})
+	requested := make(map[string]interface{}, len(missing))
+	for _, name := range missing {
+		declared, _ := properties[name].(map[string]interface{})
+		property := map[string]interface{}{"type": declared["type"], "title": name}
+		for _, key := range []string{"description", "enum", "default", "minimum", "maximum"} {
+			if value, ok := declared[key]; ok {
+				property[key] = value
+			}
+		}
+		requested[name] = property
+	}
+
+	raw, err := s.requestClient(ctx, "elicitation/create", map[string]interface{}{
+		"message": fmt.Sprintf("%s needs %s", tool, strings.Join(missing, ", ")),
+		"requestedSchema": map[string]interface{}{
+			"type":       "object",
+			"properties": requested,
+			"required":   missing,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	var result struct {
+		Action  string                 `json:"action"`
+		Content map[string]interface{} `json:"content"`
+	}
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return fmt.Errorf("failed to parse elicitation result: %w", err)
+	}
+	if result.Action != "accept" {
+		return errElicitationDeclined
+	}
+	for _, name := range missing {
+		value := result.Content[name]
+		if text, ok := value.(string); ok {
+			value = strings.TrimSpace(text)
+		}
+		if value == nil || value == "" {
+			return errElicitationDeclined
+		}
+		args[name] = value
+	}
+	return nil
+}
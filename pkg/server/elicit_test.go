@@ -0,0 +1,133 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+	"github.com/protobomb/mcp-server-framework/pkg/transport"
+)
+
+func TestMissingArguments(t *testing.T) {
+	schema := map[string]interface{}{
+		"properties": map[string]interface{}{
+			"name":  map[string]interface{}{"type": "string"},
+			"count": map[string]interface{}{"type": "integer"},
+			"names": map[string]interface{}{"type": "array"},
+		},
+		"required": []string{"name", "count"},
+	}
+	if missing, elicitable := missingArguments(schema, map[string]interface{}{"count": 2.0}); fmt.Sprint(missing) != "[name]" || !elicitable {
+		t.Errorf("Expected name to be elicitable, got %v %v", missing, elicitable)
+	}
+	if missing, _ := missingArguments(schema, map[string]interface{}{"name": "api", "count": 2.0}); len(missing) != 0 {
+		t.Errorf("Expected nothing missing, got %v", missing)
+	}
+	schema["required"] = []string{"names"}
+	if missing, elicitable := missingArguments(schema, nil); fmt.Sprint(missing) != "[names]" || elicitable {
+		t.Errorf("Expected an array not to be elicitable, got %v %v", missing, elicitable)
+	}
+}
+
+func TestToolCallElicitsMissingArguments(t *testing.T) {
+	runner := &fakeRunner{outputs: map[string]string{"stop api": ""}}
+	clientIn, serverOut := io.Pipe()
+	serverIn, clientOut := io.Pipe()
+	s := New(transport.NewSTDIOTransportWithIO(serverIn, serverOut), Options{
+		Runner:    runner,
+		StatePath: filepath.Join(t.TempDir(), "state.json"),
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := s.Start(ctx); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer s.Stop()
+
+	// Act as a client answering each elicitation with the next reply
+	replies := make(chan interface{}, 1)
+	requests := make(chan json.RawMessage, 10)
+	go func() {
+		scanner := bufio.NewScanner(clientIn)
+		for scanner.Scan() {
+			var request struct {
+				ID     interface{}     `json:"id"`
+				Method string          `json:"method"`
+				Params json.RawMessage `json:"params"`
+			}
+			if json.Unmarshal(scanner.Bytes(), &request) != nil || request.Method != "elicitation/create" {
+				continue
+			}
+			requests <- request.Params
+			response, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": request.ID, "result": <-replies})
+			clientOut.Write(append(response, '\n'))
+		}
+	}()
+
+	call := s.MCP().GetHandler("tools/call")
+	stop := json.RawMessage(`{"name":"devpod_stopWorkspace","arguments":{}}`)
+	invalidParams := func(err error) bool {
+		rpcErr, ok := err.(*mcp.RPCError)
+		return ok && rpcErr.Code == mcp.InvalidParams && strings.Contains(rpcErr.Message, "name is required")
+	}
+
+	if _, err := call(ctx, stop); !invalidParams(err) {
+		t.Errorf("Expected InvalidParams without elicitation support, got %v", err)
+	}
+
+	s.setClientCapabilities(ctx, json.RawMessage(`{"capabilities":{"elicitation":{}}}`))
+	replies <- map[string]interface{}{"action": "decline"}
+	if _, err := call(ctx, stop); !invalidParams(err) {
+		t.Errorf("Expected InvalidParams when the user declines, got %v", err)
+	}
+	<-requests
+
+	replies <- map[string]interface{}{"action": "accept", "content": map[string]interface{}{"name": " api "}}
+	result, err := call(ctx, stop)
+	if err != nil {
+		t.Fatalf("devpod_stopWorkspace failed: %v", err)
+	}
+	var params struct {
+		RequestedSchema struct {
+			Properties map[string]map[string]interface{} `json:"properties"`
+			Required   []string                          `json:"required"`
+		} `json:"requestedSchema"`
+	}
+	if err := json.Unmarshal(<-requests, &params); err != nil || fmt.Sprint(params.RequestedSchema.Required) != "[name]" || params.RequestedSchema.Properties["name"]["type"] != "string" {
+		t.Errorf("Unexpected elicitation request: %+v, %v", params, err)
+	}
+	if text := fmt.Sprint(result); !strings.Contains(text, "elicitedArguments:[name]") {
+		t.Errorf("Expected the elicited arguments in the result, got %s", text)
+	}
+	if calls := fmt.Sprint(runner.calls); !strings.Contains(calls, "[stop api]") {
+		t.Errorf("Expected the elicited name to be used, got calls %v", calls)
+	}
+}
+
+func TestToolCallChecksPolicyBeforeEliciting(t *testing.T) {
+	transport := &queueTransport{messages: make(chan sessionMessage, 10)}
+	s := New(transport, Options{
+		Runner:    &fakeRunner{},
+		StatePath: filepath.Join(t.TempDir(), "state.json"),
+		Policy: PolicyOptions{Rules: &PolicyRules{Rules: []PolicyRule{
+			{Effect: policyDeny, Tools: []string{"devpod_stopWorkspace"}, Reason: "stopping is disabled"},
+		}}},
+	})
+	ctx := WithSessionID(context.Background(), "a")
+	s.setClientCapabilities(ctx, json.RawMessage(`{"capabilities":{"elicitation":{}}}`))
+
+	_, err := s.MCP().GetHandler("tools/call")(ctx, json.RawMessage(`{"name":"devpod_stopWorkspace","arguments":{}}`))
+	if rpcErr, ok := err.(*mcp.RPCError); !ok || rpcErr.Code != categoryCodes[CategoryPermissionDenied] {
+		t.Errorf("Expected the call to be denied, got %v", err)
+	}
+	if len(transport.messages) > 0 {
+		t.Errorf("Expected the user not to be asked for a denied call, got %s", (<-transport.messages).message)
+	}
+}
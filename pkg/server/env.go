@@ -0,0 +1,136 @@
+package server
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DefaultAllowedEnv are the variables tools accept in their env argument
+// unless Options.AllowedEnv says otherwise: the docker daemon to use, proxy
+// settings and the selection of cloud accounts, profiles and regions
+var DefaultAllowedEnv = []string{
+	"DOCKER_HOST", "DOCKER_CONTEXT", "DOCKER_TLS_VERIFY", "DOCKER_CERT_PATH",
+	"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY", "http_proxy", "https_proxy", "no_proxy",
+	"KUBECONFIG",
+	"AWS_PROFILE", "AWS_REGION", "AWS_DEFAULT_REGION",
+	"CLOUDSDK_CORE_PROJECT", "CLOUDSDK_COMPUTE_ZONE", "GOOGLE_CLOUD_PROJECT",
+	"AZURE_SUBSCRIPTION_ID",
+}
+
+// envName matches environment variable names
+var envName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// secretEnvName matches the names of variables whose values are credentials
+var secretEnvName = regexp.MustCompile(`(?i)PASSWORD|SECRET|TOKEN|ACCESS_KEY|API_KEY|PRIVATE_KEY|CREDENTIAL`)
+
+// envProperty is the env argument added to the schema of tools that run
+// devpod commands which change state
+var envProperty = map[string]interface{}{
+	"type":                 "object",
+	"additionalProperties": map[string]interface{}{"type": "string"},
+	"description":          "Environment variables for the devpod commands of this call, e.g. DOCKER_HOST or HTTPS_PROXY; only variables the server allows are accepted (optional)",
+}
+
+// ParseAllowedEnv parses a comma-separated list of variable names. A name
+// ending in * allows every variable with that prefix.
+func ParseAllowedEnv(list string) ([]string, error) {
+	allowed := []string{}
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !envName.MatchString(strings.TrimSuffix(name, "*")) {
+			return nil, fmt.Errorf("invalid environment variable name %q", name)
+		}
+		allowed = append(allowed, name)
+	}
+	return allowed, nil
+}
+
+// acceptsEnv reports whether a tool takes the env argument: tools that run
+// devpod against providers or workspaces and change state
+func acceptsEnv(tool string) bool {
+	annotations, ok := toolAnnotations[tool]
+	return ok && !annotations.ReadOnlyHint && annotations.OpenWorldHint
+}
+
+// withEnvProperty returns the input schema of a tool with the env argument
+// added when the tool accepts it
+func withEnvProperty(tool string, schema map[string]interface{}) map[string]interface{} {
+	if !acceptsEnv(tool) || schema == nil {
+		return schema
+	}
+	properties, _ := schema["properties"].(map[string]interface{})
+	if _, ok := properties["env"]; ok {
+		return schema
+	}
+	extended := make(map[string]interface{}, len(properties)+1)
+	for name, property := range properties {
+		extended[name] = property
+	}
+	extended["env"] = envProperty
+	copied := make(map[string]interface{}, len(schema))
+	for key, value := range schema {
+		copied[key] = value
+	}
+	copied["properties"] = extended
+	return copied
+}
+
+// allowedEnv returns the variables tools accept
+func (s *Server) allowedEnv() []string {
+	if s.opts.AllowedEnv == nil {
+		return DefaultAllowedEnv
+	}
+	return s.opts.AllowedEnv
+}
+
+// envAllowed reports whether name matches an entry of allowed
+func envAllowed(name string, allowed []string) bool {
+	for _, entry := range allowed {
+		if prefix, ok := strings.CutSuffix(entry, "*"); (ok && strings.HasPrefix(name, prefix)) || entry == name {
+			return true
+		}
+	}
+	return false
+}
+
+// callEnv checks the env argument of a tool call against the allowlist and
+// returns it as KEY=value pairs, sorted by name
+func (s *Server) callEnv(value interface{}) ([]string, error) {
+	vars, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("env must be an object of strings")
+	}
+	allowed := s.allowedEnv()
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	env := make([]string, 0, len(names))
+	for _, name := range names {
+		value, ok := vars[name].(string)
+		if !ok {
+			return nil, fmt.Errorf("env %s must be a string", name)
+		}
+		if !envName.MatchString(name) || strings.ContainsRune(value, 0) {
+			return nil, fmt.Errorf("invalid environment variable %q", name)
+		}
+		if !envAllowed(name, allowed) {
+			if len(allowed) == 0 {
+				return nil, fmt.Errorf("env %s is not allowed; the server accepts no environment variables", name)
+			}
+			return nil, fmt.Errorf("env %s is not allowed; allowed: %s", name, strings.Join(allowed, ", "))
+		}
+		if secretEnvName.MatchString(name) {
+			s.redactor.add(value)
+		}
+		env = append(env, name+"="+value)
+	}
+	return env, nil
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+func TestParseAllowedEnv(t *testing.T) {
+	allowed, err := ParseAllowedEnv(" DOCKER_HOST, AWS_* ,")
+	if err != nil || strings.Join(allowed, ",") != "DOCKER_HOST,AWS_*" {
+		t.Errorf("ParseAllowedEnv = %v, %v", allowed, err)
+	}
+	if _, err := ParseAllowedEnv("BAD-NAME"); err == nil {
+		t.Error("Expected an invalid name to be rejected")
+	}
+}
+
+func TestCallEnv(t *testing.T) {
+	s := newTestServer(t, &fakeRunner{})
+	s.opts.AllowedEnv = []string{"DOCKER_HOST", "AWS_*"}
+
+	env, err := s.callEnv(map[string]interface{}{"DOCKER_HOST": "tcp://builder:2376", "AWS_SESSION_TOKEN": "session-token-value"})
+	if err != nil || strings.Join(env, " ") != "AWS_SESSION_TOKEN=session-token-value DOCKER_HOST=tcp://builder:2376" {
+		t.Fatalf("callEnv = %v, %v", env, err)
+	}
+	if redacted := s.redactor.redact("token session-token-value"); strings.Contains(redacted, "session-token-value") {
+		t.Errorf("Expected the credential to be redacted, got %s", redacted)
+	}
+
+	for _, vars := range []map[string]interface{}{{"PATH": "/tmp"}, {"DOCKER_HOST": 1}, {"1BAD": "x"}} {
+		if _, err := s.callEnv(vars); err == nil {
+			t.Errorf("Expected %v to be rejected", vars)
+		}
+	}
+}
+
+func TestWithCommandEnvCopiesParent(t *testing.T) {
+	// A parent env with spare capacity, as appending to it leaves
+	env := make([]string, 1, 8)
+	env[0] = "NPM_TOKEN=parent"
+	parent := context.WithValue(context.Background(), commandEnvKey{}, env)
+
+	var wg sync.WaitGroup
+	derived := make([][]string, 2)
+	for i := range derived {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			home := fmt.Sprintf("DEVPOD_HOME=/homes/user-%d", i)
+			for n := 0; n < 100; n++ {
+				derived[i] = CommandEnv(WithCommandEnv(parent, []string{home}))
+			}
+		}(i)
+	}
+	wg.Wait()
+	for i, env := range derived {
+		if want := fmt.Sprintf("NPM_TOKEN=parent DEVPOD_HOME=/homes/user-%d", i); strings.Join(env, " ") != want {
+			t.Errorf("Expected %s, got %v", want, env)
+		}
+	}
+	if got := CommandEnv(parent); len(got) != 1 {
+		t.Errorf("Expected the parent env to stay as it was, got %v", got)
+	}
+}
+
+func TestToolCallPassesEnv(t *testing.T) {
+	runner := &envRunner{}
+	s := newTestServer(t, runner)
+	call := s.MCP().GetHandler("tools/call")
+
+	_, err := call(context.Background(), json.RawMessage(`{"name":"devpod_stopWorkspace","arguments":{"name":"api","env":{"DOCKER_HOST":"tcp://builder:2376"}}}`))
+	if err != nil {
+		t.Fatalf("devpod_stopWorkspace failed: %v", err)
+	}
+	if runner.env != "DOCKER_HOST=tcp://builder:2376" {
+		t.Errorf("Expected DOCKER_HOST to reach devpod, got %q", runner.env)
+	}
+
+	_, err = call(context.Background(), json.RawMessage(`{"name":"devpod_stopWorkspace","arguments":{"name":"api","env":{"LD_PRELOAD":"/tmp/evil.so"}}}`))
+	if rpcErr, ok := err.(*mcp.RPCError); !ok || rpcErr.Code != mcp.InvalidParams {
+		t.Errorf("Expected a variable outside the allowlist to be rejected, got %v", err)
+	}
+
+	for _, tool := range s.tools.list() {
+		properties := tool.InputSchema["properties"].(map[string]interface{})
+		if _, ok := properties["env"]; acceptsEnv(tool.Name) && !ok {
+			t.Errorf("Expected %s to take env", tool.Name)
+		}
+		if _, ok := properties["env"]; tool.Name == "devpod_listWorkspaces" && ok {
+			t.Error("Expected read-only tools not to take env")
+		}
+	}
+}
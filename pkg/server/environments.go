@@ -0,0 +1,378 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+// environmentMember is one workspace requested as part of an environment
+type environmentMember struct {
+	Name     string `json:"name,omitempty"`
+	Source   string `json:"source"`
+	Provider string `json:"provider,omitempty"`
+	IDE      string `json:"ide,omitempty"`
+}
+
+// memberResult reports what happened to one environment workspace
+type memberResult struct {
+	Name    string `json:"name"`
+	Source  string `json:"source,omitempty"`
+	State   string `json:"state,omitempty"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+	// FailedPhase is the devpod up phase a failed creation stopped in
+	FailedPhase string `json:"failedPhase,omitempty"`
+	DurationMs  int64  `json:"durationMs,omitempty"`
+}
+
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// memberName derives a workspace name from the environment name and the
+// last path element of the source, e.g. shop + github.com/acme/cart.git@main
+// becomes shop-cart
+func memberName(env, source string) string {
+	base := sourceBaseName(source)
+	if base == "" {
+		return ""
+	}
+	return env + "-" + base
+}
+
+// createEnvironment creates all members concurrently and returns one result
+// per member in request order. Members that already exist are reported as
+// failures rather than adopted, so deleting the environment never removes
+// workspaces it did not create.
+func (s *Server) createEnvironment(ctx context.Context, members []environmentMember) ([]memberResult, error) {
+	workspaces, err := s.listWorkspaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[string]bool, len(workspaces))
+	for _, workspace := range workspaces {
+		existing[workspace.ID] = true
+	}
+
+	results := make([]memberResult, len(members))
+	var wg sync.WaitGroup
+	for i, member := range members {
+		wg.Add(1)
+		go func(i int, member environmentMember) {
+			defer wg.Done()
+			result := memberResult{Name: member.Name, Source: member.Source}
+			start := time.Now()
+			defer func() {
+				result.DurationMs = durationMs(start)
+				results[i] = result
+			}()
+
+			if existing[member.Name] {
+				result.Error = fmt.Sprintf("workspace %s already exists", member.Name)
+				return
+			}
+			release, err := s.lockWorkspace(ctx, member.Name, "devpod_createEnvironment")
+			if err != nil {
+				result.Error = err.Error()
+				return
+			}
+			defer release()
+
+			spec := &workspaceSpec{Name: member.Name}
+			if member.Provider != "" {
+				spec.Provider = &specComponent{Name: member.Provider}
+			}
+			if member.IDE != "" {
+				spec.IDE = &specComponent{Name: member.IDE}
+			}
+			if output, err := s.createFromSpec(ctx, spec, member.Source, "Workspace created as part of an environment"); err != nil {
+				result.Error = newDevPodError("failed to create workspace", err, output).Error()
+				result.FailedPhase = failedPhase(parsePhases(string(output), true))
+				return
+			}
+			result.Success = true
+		}(i, member)
+	}
+	wg.Wait()
+	return results, nil
+}
+
+// deleteEnvironment deletes the given workspaces concurrently and returns one
+// result per workspace in order
+func (s *Server) deleteEnvironment(ctx context.Context, workspaces []string, force bool) []memberResult {
+	results := make([]memberResult, len(workspaces))
+	var wg sync.WaitGroup
+	for i, name := range workspaces {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			start := time.Now()
+			result := memberResult{Name: name}
+
+			args := []string{"delete", name}
+			if force {
+				args = append(args, "--force")
+			}
+			release, err := s.lockWorkspace(ctx, name, "devpod_deleteEnvironment")
+			if err != nil {
+				result.Error = err.Error()
+				result.DurationMs = durationMs(start)
+				results[i] = result
+				return
+			}
+			defer release()
+			deleted := s.captureDeletedByName(ctx, name)
+			if output, err := s.combinedOutput(ctx, args); err != nil {
+				s.state(ctx).RecordEvent(name, "error", fmt.Sprintf("delete failed: %v", err))
+				result.Error = newDevPodError("failed to delete workspace", err, output).Error()
+			} else {
+				s.recordDeletion(ctx, name, "Workspace deleted with its environment", deleted)
+				result.Success = true
+			}
+			result.DurationMs = durationMs(start)
+			results[i] = result
+		}(i, name)
+	}
+	wg.Wait()
+	return results
+}
+
+// registerEnvironmentTools registers the tools that manage environments of
+// several workspaces
+func (s *Server) registerEnvironmentTools() {
+	// Create environment
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_createEnvironment",
+		Description: "Create a named group of workspaces from several repositories concurrently",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "The name of the environment",
+				},
+				"workspaces": map[string]interface{}{
+					"type":        "array",
+					"description": "The workspaces to create",
+					"items": map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"source": map[string]interface{}{
+								"type":        "string",
+								"description": "The source for the workspace (git repo, local path, or image)",
+							},
+							"name": map[string]interface{}{
+								"type":        "string",
+								"description": "The workspace name (default: <environment>-<repository>)",
+							},
+							"provider": map[string]interface{}{
+								"type":        "string",
+								"description": "The provider for this workspace (optional)",
+							},
+							"ide": map[string]interface{}{
+								"type":        "string",
+								"description": "The IDE for this workspace (optional)",
+							},
+						},
+						"required": []string{"source"},
+					},
+				},
+				"provider": map[string]interface{}{
+					"type":        "string",
+					"description": "The default provider for all workspaces (optional)",
+				},
+				"ide": map[string]interface{}{
+					"type":        "string",
+					"description": "The default IDE for all workspaces (optional)",
+				},
+			},
+			"required": []string{"name", "workspaces"},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var envParams struct {
+			Name       string              `json:"name"`
+			Workspaces []environmentMember `json:"workspaces"`
+			Provider   string              `json:"provider,omitempty"`
+			IDE        string              `json:"ide,omitempty"`
+		}
+
+		if err := json.Unmarshal(params, &envParams); err != nil {
+			return nil, mcp.NewInvalidParamsError("Invalid create environment parameters")
+		}
+
+		if envParams.Name == "" || len(envParams.Workspaces) == 0 {
+			return nil, mcp.NewInvalidParamsError("Name and at least one workspace are required")
+		}
+		if _, ok := s.state(ctx).Environment(envParams.Name); ok {
+			return nil, mcp.NewInvalidParamsError(fmt.Sprintf("Environment %s already exists", envParams.Name))
+		}
+
+		defaults := s.session(ctx)
+		if envParams.Provider == "" {
+			envParams.Provider = defaults.Provider
+		}
+		if envParams.IDE == "" {
+			envParams.IDE = defaults.IDE
+		}
+
+		seen := make(map[string]bool)
+		for i := range envParams.Workspaces {
+			member := &envParams.Workspaces[i]
+			if member.Source == "" {
+				return nil, mcp.NewInvalidParamsError(fmt.Sprintf("Workspace %d has no source", i+1))
+			}
+			if member.Name == "" {
+				member.Name = memberName(envParams.Name, member.Source)
+			}
+			source, err := s.localSourceArg(ctx, member.Source)
+			if err != nil {
+				return nil, mcp.NewInvalidParamsError(err.Error())
+			}
+			member.Source = source
+			if member.Name == "" {
+				return nil, mcp.NewInvalidParamsError(fmt.Sprintf("Cannot derive a name for %s, set name explicitly", member.Source))
+			}
+			if seen[member.Name] {
+				return nil, mcp.NewInvalidParamsError(fmt.Sprintf("Duplicate workspace name %s", member.Name))
+			}
+			if err := s.opts.Names.check(member.Name); err != nil {
+				return nil, mcp.NewInvalidParamsError(err.Error())
+			}
+			seen[member.Name] = true
+			if member.Provider == "" {
+				member.Provider = envParams.Provider
+			}
+			if member.IDE == "" {
+				member.IDE = envParams.IDE
+			}
+		}
+
+		start := time.Now()
+		results, err := s.createEnvironment(ctx, envParams.Workspaces)
+		if err != nil {
+			return nil, newDevPodError("failed to list workspaces", err, nil)
+		}
+
+		var created []string
+		for _, result := range results {
+			if result.Success {
+				created = append(created, result.Name)
+			}
+		}
+		if len(created) > 0 {
+			s.state(ctx).SetEnvironment(environment{Name: envParams.Name, Workspaces: created, Created: time.Now().UTC()})
+		}
+
+		message := "Environment created successfully"
+		switch {
+		case len(created) == 0:
+			message = "No workspaces could be created; the environment was not recorded"
+		case len(created) < len(results):
+			message = fmt.Sprintf("Environment created with %d of %d workspaces", len(created), len(results))
+		}
+		return map[string]interface{}{
+			"name":       envParams.Name,
+			"workspaces": results,
+			"success":    len(created) == len(results),
+			"message":    message,
+			"durationMs": durationMs(start),
+		}, nil
+	})
+
+	// List environments
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_listEnvironments",
+		Description: "List workspace environments and the state of their workspaces",
+		InputSchema: map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		environments := []map[string]interface{}{}
+		for _, env := range s.state(ctx).Environments() {
+			members := make([]memberResult, 0, len(env.Workspaces))
+			for _, name := range env.Workspaces {
+				members = append(members, memberResult{Name: name, State: s.getWorkspaceState(ctx, name), Success: true})
+			}
+			environments = append(environments, map[string]interface{}{
+				"name":       env.Name,
+				"created":    env.Created,
+				"workspaces": members,
+			})
+		}
+
+		return map[string]interface{}{
+			"environments": environments,
+			"count":        len(environments),
+		}, nil
+	})
+
+	// Delete environment
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_deleteEnvironment",
+		Description: "Delete all workspaces of an environment concurrently and forget the environment",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "The name of the environment",
+				},
+				"force": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Force delete the workspaces",
+				},
+			},
+			"required": []string{"name"},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var deleteParams struct {
+			Name  string `json:"name"`
+			Force bool   `json:"force,omitempty"`
+		}
+
+		if err := json.Unmarshal(params, &deleteParams); err != nil {
+			return nil, mcp.NewInvalidParamsError("Invalid delete environment parameters")
+		}
+
+		if deleteParams.Name == "" {
+			return nil, mcp.NewInvalidParamsError("Environment name is required")
+		}
+
+		env, ok := s.state(ctx).Environment(deleteParams.Name)
+		if !ok {
+			return nil, mcp.NewInvalidParamsError(fmt.Sprintf("Environment %s not found", deleteParams.Name))
+		}
+
+		start := time.Now()
+		results := s.deleteEnvironment(ctx, env.Workspaces, deleteParams.Force)
+
+		// Keep the workspaces that could not be deleted so a retry can finish the job
+		var remaining []string
+		for _, result := range results {
+			if !result.Success {
+				remaining = append(remaining, result.Name)
+			}
+		}
+		message := "Environment deleted successfully"
+		if len(remaining) == 0 {
+			s.state(ctx).DeleteEnvironment(env.Name)
+		} else {
+			env.Workspaces = remaining
+			s.state(ctx).SetEnvironment(env)
+			message = fmt.Sprintf("%d of %d workspaces could not be deleted and remain in the environment", len(remaining), len(results))
+		}
+
+		return map[string]interface{}{
+			"name":       env.Name,
+			"workspaces": results,
+			"success":    len(remaining) == 0,
+			"message":    message,
+			"durationMs": durationMs(start),
+		}, nil
+	})
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestMemberName(t *testing.T) {
+	tests := map[string]string{
+		"github.com/acme/cart":          "shop-cart",
+		"github.com/acme/Cart.git@main": "shop-cart",
+		"git@github.com:acme/billing":   "shop-billing",
+		"github.com/acme/api@sha256:ab": "shop-api",
+		"/src/checkout_api/":            "shop-checkout-api",
+		"@@@":                           "",
+	}
+	for source, want := range tests {
+		if got := memberName("shop", source); got != want {
+			t.Errorf("memberName(shop, %q) = %q, want %q", source, got, want)
+		}
+	}
+}
+
+func TestEnvironmentLifecycle(t *testing.T) {
+	runner := &fakeRunner{
+		outputs: map[string]string{"list --output json": `[{"id":"shop-legacy"}]`},
+		failures: map[string]string{
+			"up github.com/acme/billing --id shop-billing --provider docker": "error: quota exceeded",
+		},
+	}
+	s := newTestServer(t, runner)
+
+	result, err := s.MCP().GetHandler("devpod_createEnvironment")(context.Background(), json.RawMessage(`{
+		"name": "shop",
+		"provider": "docker",
+		"workspaces": [
+			{"source": "github.com/acme/cart"},
+			{"source": "github.com/acme/billing"},
+			{"source": "github.com/acme/legacy"}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("devpod_createEnvironment failed: %v", err)
+	}
+	results := result.(map[string]interface{})["workspaces"].([]memberResult)
+	if !results[0].Success || results[1].Success || results[2].Success {
+		t.Fatalf("Expected only shop-cart to be created, got %+v", results)
+	}
+
+	env, ok := s.store.Environment("shop")
+	if !ok || len(env.Workspaces) != 1 || env.Workspaces[0] != "shop-cart" {
+		t.Fatalf("Expected environment with shop-cart, got %+v", env)
+	}
+
+	if _, err := s.MCP().GetHandler("devpod_deleteEnvironment")(context.Background(), json.RawMessage(`{"name":"shop"}`)); err != nil {
+		t.Fatalf("devpod_deleteEnvironment failed: %v", err)
+	}
+	if _, ok := s.store.Environment("shop"); ok {
+		t.Error("Expected the environment to be forgotten after delete")
+	}
+
+	var deleted bool
+	for _, call := range runner.calls {
+		if len(call) == 2 && call[0] == "delete" && call[1] == "shop-cart" {
+			deleted = true
+		}
+		if call[0] == "delete" && call[1] == "shop-legacy" {
+			t.Error("Expected pre-existing workspace to be left alone")
+		}
+	}
+	if !deleted {
+		t.Error("Expected shop-cart to be deleted")
+	}
+}
@@ -0,0 +1,177 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+// Error categories reported in the data of devpod command failures
+const (
+	CategoryCommandFailed     = "CommandFailed"
+	CategoryProviderNotFound  = "ProviderNotFound"
+	CategoryWorkspaceNotFound = "WorkspaceNotFound"
+	CategoryDockerUnavailable = "DockerUnavailable"
+	CategoryAuthFailure       = "AuthFailure"
+	CategoryTimeout           = "Timeout"
+	CategoryQuotaExceeded     = "QuotaExceeded"
+	CategoryRateLimited       = "RateLimited"
+	// CategoryUnsupportedVersion is reported when the installed devpod CLI is
+	// too old for a tool
+	CategoryUnsupportedVersion = "UnsupportedVersion"
+	// CategoryBackendUnavailable is reported without running devpod while the
+	// circuit breaker is open
+	CategoryBackendUnavailable = "BackendUnavailable"
+	// CategoryPlatformMismatch is reported when an image is not published for
+	// the platform a workspace runs as
+	CategoryPlatformMismatch = "PlatformMismatch"
+	// CategoryConflict is reported when another operation holds a workspace
+	CategoryConflict = "Conflict"
+	// CategoryPermissionDenied is reported when the policy denies a tool call
+	CategoryPermissionDenied = "PermissionDenied"
+)
+
+// JSON-RPC error codes for each category, taken from the implementation-defined
+// server error range
+var categoryCodes = map[string]int{
+	CategoryCommandFailed:      -32000,
+	CategoryProviderNotFound:   -32001,
+	CategoryWorkspaceNotFound:  -32002,
+	CategoryDockerUnavailable:  -32003,
+	CategoryAuthFailure:        -32004,
+	CategoryTimeout:            -32005,
+	CategoryQuotaExceeded:      -32006,
+	CategoryRateLimited:        -32007,
+	CategoryUnsupportedVersion: -32008,
+	CategoryBackendUnavailable: -32009,
+	CategoryPlatformMismatch:   -32010,
+	CategoryConflict:           -32011,
+	CategoryPermissionDenied:   -32012,
+}
+
+// maxStderrExcerpt bounds the stderr excerpt included in error data
+const maxStderrExcerpt = 2048
+
+// categoryPatterns maps lowercase output fragments to categories, checked in order
+var categoryPatterns = []struct {
+	category string
+	patterns []string
+}{
+	{CategoryPlatformMismatch, []string{"no matching manifest for", "does not match the specified platform", "does not match the detected host platform", "exec format error"}},
+	{CategoryDockerUnavailable, []string{"cannot connect to the docker daemon", "is the docker daemon running", "docker: command not found", "docker not found", "error during connect"}},
+	{CategoryProviderNotFound, []string{"provider not found", "couldn't find provider", "provider doesn't exist", "provider does not exist"}},
+	{CategoryWorkspaceNotFound, []string{"workspace not found", "couldn't find workspace", "workspace doesn't exist", "workspace does not exist"}},
+	{CategoryAuthFailure, []string{"authentication failed", "permission denied (publickey", "could not read username", "unauthorized", "access denied", "invalid credentials"}},
+	{CategoryQuotaExceeded, []string{"quota", "limit exceeded", "insufficient capacity", "resource_exhausted"}},
+	{CategoryTimeout, []string{"timed out", "timeout", "deadline exceeded"}},
+}
+
+// CommandError is returned when a devpod command exits unsuccessfully
+type CommandError struct {
+	Args   []string
+	Stdout []byte
+	Stderr []byte
+	Err    error
+}
+
+// Error implements the error interface
+func (e *CommandError) Error() string {
+	return fmt.Sprintf("devpod command failed: %v, stdout: %s, stderr: %s", e.Err, e.Stdout, e.Stderr)
+}
+
+// Unwrap returns the underlying execution error
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+// classifyOutput returns the error category matching the command output
+func classifyOutput(output string) string {
+	lower := strings.ToLower(output)
+	for _, entry := range categoryPatterns {
+		for _, pattern := range entry.patterns {
+			if strings.Contains(lower, pattern) {
+				return entry.category
+			}
+		}
+	}
+	return CategoryCommandFailed
+}
+
+// categorizeFailure returns the category of a failed devpod invocation and a
+// trimmed excerpt of its output. output is the captured command output when
+// err is not a *CommandError.
+func categorizeFailure(err error, output []byte) (string, string) {
+	var limitErr *RateLimitError
+	if errors.As(err, &limitErr) {
+		return CategoryRateLimited, limitErr.Error()
+	}
+	var backendErr *BackendUnavailableError
+	if errors.As(err, &backendErr) {
+		return CategoryBackendUnavailable, backendErr.Error()
+	}
+	var quotaErr *QuotaExceededError
+	if errors.As(err, &quotaErr) {
+		return CategoryQuotaExceeded, quotaErr.Error()
+	}
+
+	var cmdErr *CommandError
+	if errors.As(err, &cmdErr) {
+		output = cmdErr.Stderr
+		if len(output) == 0 {
+			output = cmdErr.Stdout
+		}
+	}
+	excerpt := strings.TrimSpace(sanitizeOutput(string(output)))
+
+	category := classifyOutput(excerpt)
+	if errors.Is(err, context.DeadlineExceeded) {
+		category = CategoryTimeout
+	}
+
+	if len(excerpt) > maxStderrExcerpt {
+		excerpt = "..." + excerpt[len(excerpt)-maxStderrExcerpt:]
+	}
+	return category, excerpt
+}
+
+// newDevPodError converts a failed devpod invocation into a categorized RPC
+// error whose data carries the exit code and a trimmed stderr excerpt. output
+// is the captured command output when err is not a *CommandError.
+func newDevPodError(action string, err error, output []byte) *mcp.RPCError {
+	category, excerpt := categorizeFailure(err, output)
+
+	exitCode := -1
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		exitCode = exitErr.ExitCode()
+	}
+
+	data := map[string]interface{}{
+		"category": category,
+		"exitCode": exitCode,
+		"stderr":   excerpt,
+	}
+	var limitErr *RateLimitError
+	if errors.As(err, &limitErr) && limitErr.RetryAfter > 0 {
+		data["retryAfterMs"] = int64(limitErr.RetryAfter / time.Millisecond)
+	}
+	var backendErr *BackendUnavailableError
+	if errors.As(err, &backendErr) && backendErr.RetryAfter > 0 {
+		data["retryAfterMs"] = int64(backendErr.RetryAfter / time.Millisecond)
+	}
+	var quotaErr *QuotaExceededError
+	if errors.As(err, &quotaErr) {
+		data["quota"] = quotaErr.Quota
+	}
+	if category == CategoryPlatformMismatch {
+		data["hostPlatform"] = hostPlatform()
+		data["hint"] = "the image is not published for the platform the workspace runs as; use a multi-arch image, set platform to one the image supports to run it under emulation, or pick a provider with matching machines"
+	}
+
+	return mcp.NewRPCError(categoryCodes[category], fmt.Sprintf("%s: %s", action, category), data)
+}
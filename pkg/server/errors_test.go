@@ -0,0 +1,50 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestClassifyOutput(t *testing.T) {
+	tests := map[string]string{
+		"Cannot connect to the Docker daemon at unix:///var/run/docker.sock": CategoryDockerUnavailable,
+		"fatal: provider not found: aws":                                     CategoryProviderNotFound,
+		"couldn't find workspace my-ws":                                      CategoryWorkspaceNotFound,
+		"git@github.com: Permission denied (publickey).":                     CategoryAuthFailure,
+		"VcpuLimitExceeded: quota reached":                                   CategoryQuotaExceeded,
+		"dial tcp: i/o timeout":                                              CategoryTimeout,
+		"something unexpected happened":                                      CategoryCommandFailed,
+	}
+
+	for output, expected := range tests {
+		if got := classifyOutput(output); got != expected {
+			t.Errorf("classifyOutput(%q) = %s, expected %s", output, got, expected)
+		}
+	}
+}
+
+func TestNewDevPodErrorData(t *testing.T) {
+	err := &CommandError{
+		Args:   []string{"up", "test1"},
+		Stderr: []byte("workspace not found\n"),
+		Err:    fmt.Errorf("exit status 1"),
+	}
+
+	rpcErr := newDevPodError("failed to start workspace", err, nil)
+	if rpcErr.Code != categoryCodes[CategoryWorkspaceNotFound] {
+		t.Errorf("Unexpected error code %d", rpcErr.Code)
+	}
+
+	data, ok := rpcErr.Data.(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected map error data, got %T", rpcErr.Data)
+	}
+	if data["category"] != CategoryWorkspaceNotFound || data["stderr"] != "workspace not found" || data["exitCode"] != -1 {
+		t.Errorf("Unexpected error data: %v", data)
+	}
+
+	if timeout := newDevPodError("failed", context.DeadlineExceeded, nil); timeout.Code != categoryCodes[CategoryTimeout] {
+		t.Errorf("Expected timeout category for deadline errors, got code %d", timeout.Code)
+	}
+}
@@ -0,0 +1,173 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+// maxServerEvents bounds the number of background events kept in memory
+const maxServerEvents = 500
+
+// serverEvent is a warning or error raised by a background subsystem
+type serverEvent struct {
+	Time     time.Time `json:"time"`
+	LastSeen time.Time `json:"lastSeen"`
+	Level    string    `json:"level"`
+	Source   string    `json:"source"`
+	Message  string    `json:"message"`
+	// User is the authenticated user whose call or workspace the event is
+	// about, "" for events of the whole server
+	User string `json:"user,omitempty"`
+	// Count is the number of consecutive times the source reported this message
+	Count int `json:"count"`
+}
+
+// eventLog is a bounded in-memory log of background events
+type eventLog struct {
+	mu     sync.Mutex
+	events []serverEvent
+}
+
+// add records an event of a user and reports whether it is new. Repeating
+// the previous message of the same source only bumps its count so a
+// persistently failing subsystem doesn't flood the log or the client.
+func (l *eventLog) add(user, level, source, message string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	for i := len(l.events) - 1; i >= 0; i-- {
+		if l.events[i].Source != source || l.events[i].User != user {
+			continue
+		}
+		if l.events[i].Message == message && l.events[i].Level == level {
+			l.events[i].Count++
+			l.events[i].LastSeen = now
+			return false
+		}
+		break
+	}
+
+	l.events = append(l.events, serverEvent{
+		Time:     now,
+		LastSeen: now,
+		Level:    level,
+		Source:   source,
+		Message:  message,
+		User:     user,
+		Count:    1,
+	})
+	if len(l.events) > maxServerEvents {
+		l.events = l.events[len(l.events)-maxServerEvents:]
+	}
+	return true
+}
+
+// list returns up to limit of the most recent events of the server and a
+// user, oldest first, optionally filtered by source and level
+func (l *eventLog) list(user, source, level string, limit int) []serverEvent {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	events := []serverEvent{}
+	for i := len(l.events) - 1; i >= 0 && (limit <= 0 || len(events) < limit); i-- {
+		event := l.events[i]
+		if (event.User == "" || event.User == user) && (source == "" || event.Source == source) && (level == "" || event.Level == level) {
+			events = append(events, event)
+		}
+	}
+	for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
+		events[i], events[j] = events[j], events[i]
+	}
+	return events
+}
+
+// reportEvent logs a background event, keeps it for devpod_serverEvents and,
+// once the transport is running, sends it to clients as a notifications/message
+// log entry. level is an MCP logging level such as "warning" or "error".
+func (s *Server) reportEvent(level, source string, err error) {
+	s.reportUserEvent("", level, source, err)
+}
+
+// reportUserEvent reports an event about a user's call or workspace, which
+// only that user sees; "" reports an event of the whole server
+func (s *Server) reportUserEvent(user, level, source string, err error) {
+	message := err.Error()
+	log.Printf("WARNING: %s: %s", source, message)
+
+	if !s.events.add(user, level, source, message) || !s.running() {
+		return
+	}
+	params := map[string]interface{}{
+		"level":  level,
+		"logger": "devpod/" + source,
+		"data": map[string]interface{}{
+			"source":  source,
+			"message": message,
+		},
+	}
+	if user != "" {
+		err = s.notifyUser(user, "notifications/message", params)
+	} else {
+		err = s.mcp.SendNotification("notifications/message", params)
+	}
+	if err != nil {
+		log.Printf("WARNING: failed to send %s event notification: %v", source, err)
+	}
+}
+
+// registerEventTools registers the tool that lists server events
+func (s *Server) registerEventTools() {
+	// List background subsystem events
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_serverEvents",
+		Description: "List warnings and errors raised by background subsystems such as the workspace watcher",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"source": map[string]interface{}{
+					"type":        "string",
+					"description": "Only return events from this subsystem, e.g. watcher (optional)",
+				},
+				"level": map[string]interface{}{
+					"type":        "string",
+					"enum":        []string{"warning", "error"},
+					"description": "Only return events with this level (optional)",
+				},
+				"limit": map[string]interface{}{
+					"type":        "integer",
+					"description": "Maximum number of recent events to return (default: 50)",
+				},
+			},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var eventParams struct {
+			Source string `json:"source,omitempty"`
+			Level  string `json:"level,omitempty"`
+			Limit  int    `json:"limit,omitempty"`
+		}
+
+		if len(params) > 0 {
+			if err := json.Unmarshal(params, &eventParams); err != nil {
+				return nil, mcp.NewInvalidParamsError("Invalid server events parameters")
+			}
+		}
+		if eventParams.Limit <= 0 {
+			eventParams.Limit = 50
+		}
+
+		events := s.events.list(UserName(ctx), eventParams.Source, eventParams.Level, eventParams.Limit)
+		return map[string]interface{}{
+			"events":  events,
+			"backend": s.breaker.status(),
+			"locks":   s.locks.list(UserName(ctx)),
+			"message": fmt.Sprintf("Found %d event(s)", len(events)),
+		}, nil
+	})
+}
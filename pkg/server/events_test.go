@@ -0,0 +1,66 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestEventLogCollapsesRepeats(t *testing.T) {
+	var l eventLog
+	if !l.add("", "warning", "watcher", "poll failed") {
+		t.Error("Expected first event to be new")
+	}
+	if l.add("", "warning", "watcher", "poll failed") {
+		t.Error("Expected repeated event to be collapsed")
+	}
+	l.add("", "error", "bootstrap", "provider bootstrap failed")
+
+	events := l.list("", "", "", 0)
+	if len(events) != 2 || events[0].Source != "watcher" || events[0].Count != 2 {
+		t.Errorf("Unexpected events: %+v", events)
+	}
+	if events := l.list("", "bootstrap", "", 0); len(events) != 1 {
+		t.Errorf("Expected 1 bootstrap event, got %+v", events)
+	}
+	if events := l.list("", "", "", 1); len(events) != 1 || events[0].Source != "bootstrap" {
+		t.Errorf("Expected most recent event, got %+v", events)
+	}
+}
+
+func TestServerEventsTool(t *testing.T) {
+	s := newTestServer(t, &fakeRunner{})
+	s.reportEvent("warning", "watcher", errors.New("workspace watcher poll failed"))
+
+	result, err := s.MCP().GetHandler("devpod_serverEvents")(context.Background(), json.RawMessage(`{"source":"watcher"}`))
+	if err != nil {
+		t.Fatalf("devpod_serverEvents failed: %v", err)
+	}
+
+	events, ok := result.(map[string]interface{})["events"].([]serverEvent)
+	if !ok || len(events) != 1 || events[0].Message != "workspace watcher poll failed" {
+		t.Errorf("Unexpected result: %v", result)
+	}
+}
+
+func TestServerEventsOfOtherUsersAreHidden(t *testing.T) {
+	s := newTestServer(t, &fakeRunner{})
+	s.reportEvent("warning", "watcher", errors.New("workspace watcher poll failed"))
+	s.reportUserEvent("alice", "warning", "scheduler", errors.New("scheduled stop of api failed"))
+
+	events := func(ctx context.Context) []serverEvent {
+		t.Helper()
+		result, err := s.MCP().GetHandler("devpod_serverEvents")(ctx, json.RawMessage(`{}`))
+		if err != nil {
+			t.Fatalf("devpod_serverEvents failed: %v", err)
+		}
+		return result.(map[string]interface{})["events"].([]serverEvent)
+	}
+	if got := events(WithUser(context.Background(), "alice")); len(got) != 2 || got[1].User != "alice" {
+		t.Errorf("Expected alice to see the server's and her own event, got %+v", got)
+	}
+	if got := events(WithUser(context.Background(), "bob")); len(got) != 1 || got[0].Source != "watcher" {
+		t.Errorf("Expected bob to see only the server's event, got %+v", got)
+	}
+}
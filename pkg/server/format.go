@@ -0,0 +1,535 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// Result formats of the text content of tool results
+const (
+	// FormatJSON renders results as JSON
+	FormatJSON = "json"
+	// FormatMarkdown renders results as markdown with lists of objects,
+	// such as workspace lists, as tables
+	FormatMarkdown = "markdown"
+	// FormatText renders results as plain text, message first
+	FormatText = "text"
+)
+
+// resultFormats are the values of the format argument
+var resultFormats = []string{FormatJSON, FormatMarkdown, FormatText}
+
+// ValidResultFormat reports whether format is a result format
+func ValidResultFormat(format string) bool {
+	for _, valid := range resultFormats {
+		if format == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// formatProperty is the schema of the format argument every tool takes
+var formatProperty = map[string]interface{}{
+	"type":        "string",
+	"enum":        resultFormats,
+	"description": "How to render the result: json, markdown with tables for lists, or plain text (optional, defaults to the server's -result-format)",
+}
+
+// withFormatProperty returns the input schema of a tool with the format
+// argument added
+func withFormatProperty(schema map[string]interface{}) map[string]interface{} {
+	if schema == nil {
+		return schema
+	}
+	properties, _ := schema["properties"].(map[string]interface{})
+	if _, ok := properties["format"]; ok {
+		return schema
+	}
+	extended := make(map[string]interface{}, len(properties)+1)
+	for name, property := range properties {
+		extended[name] = property
+	}
+	extended["format"] = formatProperty
+	copied := make(map[string]interface{}, len(schema))
+	for key, value := range schema {
+		copied[key] = value
+	}
+	copied["properties"] = extended
+	return copied
+}
+
+// renderResult renders a tool result as the text content of a tools/call
+// response. The empty format keeps Go's print format of the result, which
+// clients written against earlier versions expect.
+func renderResult(result interface{}, format string) (string, error) {
+	if format == "" {
+		return fmt.Sprintf("%v", result), nil
+	}
+	encoded, fields, err := decodeResult(result)
+	if err != nil {
+		return "", err
+	}
+	if format == FormatJSON {
+		return string(encoded), nil
+	}
+	if format == FormatMarkdown {
+		return renderMarkdown(fields), nil
+	}
+	return renderText(fields), nil
+}
+
+// maxSummaryNames caps the entries a result summary names
+const maxSummaryNames = 10
+
+// structuredTool reports whether the results of a tool carry their full data
+// as a JSON resource next to a short summary: the list and status tools
+func structuredTool(name string) bool {
+	return strings.HasPrefix(name, "devpod_list") || strings.HasPrefix(name, "devpod_status") || strings.HasSuffix(name, "Status")
+}
+
+// resultURI names the JSON resource of a tool result. It is not listed or
+// readable with resources/read; the data travels in the result itself.
+func resultURI(tool string) string {
+	return "devpod://result/" + tool
+}
+
+// resultContent returns the content blocks of a tools/call response. List and
+// status tools answer with a summary, or the result rendered in the format
+// the call requested, and the full result as an application/json resource;
+// other tools with the result rendered in the format, which may be the
+// server's default.
+func resultContent(tool string, result interface{}, format string, requested bool) []map[string]interface{} {
+	text, err := renderResult(result, format)
+	if err != nil {
+		log.Printf("WARNING: failed to render the result of %s as %s: %v", tool, format, err)
+		text = fmt.Sprintf("%v", result)
+	}
+	if !structuredTool(tool) {
+		return []map[string]interface{}{{"type": "text", "text": text}}
+	}
+	encoded, fields, err := decodeResult(result)
+	if err != nil {
+		log.Printf("WARNING: failed to encode the result of %s: %v", tool, err)
+		return []map[string]interface{}{{"type": "text", "text": text}}
+	}
+	if summary := summarizeResult(fields); !requested && summary != "" {
+		text = summary
+	}
+	return []map[string]interface{}{
+		{"type": "text", "text": text},
+		{
+			"type": "resource",
+			"resource": map[string]interface{}{
+				"uri":      resultURI(tool),
+				"mimeType": "application/json",
+				"text":     string(encoded),
+			},
+		},
+	}
+}
+
+// summarizeResult describes result fields in a few lines: the summary the
+// result carries, or its message and a count of each list, e.g.
+// "3 workspaces: 2 running, 1 stopped", or else its scalars
+func summarizeResult(fields map[string]interface{}) string {
+	if summary, ok := fields["summary"].(string); ok && summary != "" {
+		return summary
+	}
+	var lines []string
+	if message, ok := fields["message"].(string); ok && message != "" {
+		lines = append(lines, message)
+	} else if line := entrySummary(fields); line != "" {
+		lines = append(lines, line)
+	} else if line := scalarSummary(fields); line != "" {
+		lines = append(lines, line)
+	}
+	for _, key := range fieldOrder(fields) {
+		switch value := fields[key].(type) {
+		case []interface{}:
+			lines = append(lines, listSummary(key, value, fields))
+		case map[string]interface{}:
+			// Objects keyed by name, such as providers, count as lists
+			if list, ok := namedObjects(value); ok {
+				lines = append(lines, listSummary(key, list, fields))
+			}
+		}
+	}
+	if len(lines) == 0 {
+		return renderText(fields)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// entrySummary names an object and its state, e.g. "api: Running", if it has
+// both
+func entrySummary(fields map[string]interface{}) string {
+	name, _ := fields["name"].(string)
+	if name == "" {
+		name, _ = fields["id"].(string)
+	}
+	state, _ := fields["state"].(string)
+	if state == "" {
+		state, _ = fields["status"].(string)
+	}
+	if name == "" || state == "" || strings.Contains(state, "\n") {
+		return ""
+	}
+	return name + ": " + state
+}
+
+// countFields are left out of summaries, which count the lists themselves
+var countFields = map[string]bool{"count": true, "total": true, "nextCursor": true}
+
+// scalarSummary renders the single-line scalars of an object on one line,
+// e.g. name=api, branch=main, ahead=0
+func scalarSummary(fields map[string]interface{}) string {
+	scalars := map[string]interface{}{}
+	for key, value := range fields {
+		if countFields[key] {
+			continue
+		}
+		switch v := value.(type) {
+		case []interface{}, map[string]interface{}:
+			continue
+		case string:
+			if strings.Contains(v, "\n") {
+				continue
+			}
+		}
+		scalars[key] = value
+	}
+	return textRow(scalars)
+}
+
+// singular returns the singular of a plural field name, e.g. workspace for
+// workspaces, process for processes and entry for entries
+func singular(key string) string {
+	switch {
+	case strings.HasSuffix(key, "ies"):
+		return strings.TrimSuffix(key, "ies") + "y"
+	case strings.HasSuffix(key, "sses"), strings.HasSuffix(key, "xes"), strings.HasSuffix(key, "ches"), strings.HasSuffix(key, "shes"):
+		return strings.TrimSuffix(key, "es")
+	case strings.HasSuffix(key, "s") && !strings.HasSuffix(key, "ss") && !strings.HasSuffix(key, "us"):
+		return strings.TrimSuffix(key, "s")
+	}
+	return key
+}
+
+// namedObjects returns the entries of a non-empty object whose values are
+// all objects as a list, each with its key as name
+func namedObjects(object map[string]interface{}) ([]interface{}, bool) {
+	if len(object) == 0 {
+		return nil, false
+	}
+	list := make([]interface{}, 0, len(object))
+	for _, name := range fieldOrder(object) {
+		entry, ok := object[name].(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		named := map[string]interface{}{"name": name}
+		for key, value := range entry {
+			if key != "name" {
+				named[key] = value
+			}
+		}
+		list = append(list, named)
+	}
+	return list, true
+}
+
+// listSummary counts a list of the result, by state when its entries have
+// one and naming them otherwise
+func listSummary(key string, list []interface{}, fields map[string]interface{}) string {
+	count := fmt.Sprintf("%d %s", len(list), key)
+	if len(list) == 1 {
+		count = "1 " + singular(key)
+	}
+	if total, ok := fields["total"].(float64); ok && int(total) > len(list) {
+		count = fmt.Sprintf("%d of %s %s", len(list), jsonNumber(total), key)
+	}
+	if len(list) == 0 {
+		return count
+	}
+
+	states := map[string]int{}
+	var names []string
+	for _, entry := range list {
+		row, ok := entry.(map[string]interface{})
+		if !ok {
+			names = append(names, inlineValue(entry))
+			continue
+		}
+		state, _ := row["state"].(string)
+		if state == "" {
+			state, _ = row["status"].(string)
+		}
+		if state != "" && !strings.Contains(state, "\n") {
+			states[strings.ToLower(state)]++
+		}
+		name, _ := row["name"].(string)
+		if name == "" {
+			name, _ = row["id"].(string)
+		}
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+
+	var parts []string
+	if len(states) > 0 {
+		for state, n := range states {
+			parts = append(parts, fmt.Sprintf("%d %s", n, state))
+		}
+		sort.Slice(parts, func(i, j int) bool {
+			return strings.SplitN(parts[i], " ", 2)[1] < strings.SplitN(parts[j], " ", 2)[1]
+		})
+	} else {
+		if len(names) > maxSummaryNames {
+			names = append(names[:maxSummaryNames], fmt.Sprintf("%d more", len(names)-maxSummaryNames))
+		}
+		parts = names
+	}
+	if _, ok := fields["nextCursor"]; ok {
+		parts = append(parts, "more with nextCursor")
+	}
+	if len(parts) == 0 {
+		return count
+	}
+	return count + ": " + strings.Join(parts, ", ")
+}
+
+// decodeResult returns a result as JSON and decoded into generic fields;
+// results that are not objects become the field result
+func decodeResult(result interface{}) ([]byte, map[string]interface{}, error) {
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode result: %w", err)
+	}
+	var generic interface{}
+	if err := json.Unmarshal(encoded, &generic); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode result: %w", err)
+	}
+	fields, ok := generic.(map[string]interface{})
+	if !ok {
+		fields = map[string]interface{}{"result": generic}
+	}
+	return encoded, fields, nil
+}
+
+// renderMarkdown renders result fields as markdown: the message as a
+// paragraph, scalars as a list, lists of objects as tables and multi-line
+// text such as command output as code blocks
+func renderMarkdown(fields map[string]interface{}) string {
+	var b strings.Builder
+	if message, ok := fields["message"].(string); ok {
+		b.WriteString(message + "\n\n")
+	}
+	var sections []string
+	for _, key := range fieldOrder(fields) {
+		if key == "message" {
+			continue
+		}
+		switch value := fields[key].(type) {
+		case []interface{}:
+			if rows, ok := objectRows(value); ok {
+				sections = append(sections, fmt.Sprintf("### %s\n\n%s", key, markdownTable(rows)))
+				continue
+			}
+			fmt.Fprintf(&b, "- **%s**: %s\n", key, inlineValue(value))
+		case map[string]interface{}:
+			var nested strings.Builder
+			for _, name := range fieldOrder(value) {
+				fmt.Fprintf(&nested, "- **%s**: %s\n", name, inlineValue(value[name]))
+			}
+			sections = append(sections, fmt.Sprintf("### %s\n\n%s", key, nested.String()))
+		case string:
+			if strings.Contains(value, "\n") {
+				sections = append(sections, fmt.Sprintf("### %s\n\n%s\n", key, codeBlock(value)))
+				continue
+			}
+			fmt.Fprintf(&b, "- **%s**: %s\n", key, inlineValue(value))
+		default:
+			fmt.Fprintf(&b, "- **%s**: %s\n", key, inlineValue(value))
+		}
+	}
+	for _, section := range sections {
+		b.WriteString("\n" + section)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// renderText renders result fields as plain text: the message, a summary the
+// result carries, the scalars as key: value lines and one line per entry of
+// lists of objects
+func renderText(fields map[string]interface{}) string {
+	var lines []string
+	for _, key := range []string{"message", "summary"} {
+		if text, ok := fields[key].(string); ok && text != "" {
+			lines = append(lines, text)
+		}
+	}
+	for _, key := range fieldOrder(fields) {
+		if key == "message" || key == "summary" {
+			continue
+		}
+		switch value := fields[key].(type) {
+		case []interface{}:
+			if rows, ok := objectRows(value); ok {
+				lines = append(lines, fmt.Sprintf("%s (%d):", key, len(rows)))
+				for _, row := range rows {
+					lines = append(lines, "- "+textRow(row))
+				}
+				continue
+			}
+			lines = append(lines, fmt.Sprintf("%s: %s", key, inlineValue(value)))
+		case map[string]interface{}:
+			lines = append(lines, fmt.Sprintf("%s: %s", key, textRow(value)))
+		case string:
+			if strings.Contains(value, "\n") {
+				lines = append(lines, key+":", strings.TrimRight(value, "\n"))
+				continue
+			}
+			lines = append(lines, fmt.Sprintf("%s: %s", key, value))
+		default:
+			lines = append(lines, fmt.Sprintf("%s: %s", key, inlineValue(value)))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// textRow renders an object on one line, e.g. name=api, state=Running
+func textRow(row map[string]interface{}) string {
+	parts := make([]string, 0, len(row))
+	for _, key := range fieldOrder(row) {
+		if value := row[key]; value != nil {
+			parts = append(parts, fmt.Sprintf("%s=%s", key, inlineValue(value)))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// leadingFields are listed before the other fields, which follow in
+// alphabetical order
+var leadingFields = []string{"name", "id", "state", "status"}
+
+// fieldOrder returns the keys of an object with the identifying ones first
+func fieldOrder(fields map[string]interface{}) []string {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	rank := func(key string) int {
+		for i, leading := range leadingFields {
+			if key == leading {
+				return i
+			}
+		}
+		return len(leadingFields)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if rank(keys[i]) != rank(keys[j]) {
+			return rank(keys[i]) < rank(keys[j])
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+// objectRows returns a non-empty list whose entries are all objects
+func objectRows(list []interface{}) ([]map[string]interface{}, bool) {
+	if len(list) == 0 {
+		return nil, false
+	}
+	rows := make([]map[string]interface{}, len(list))
+	for i, entry := range list {
+		row, ok := entry.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		rows[i] = row
+	}
+	return rows, true
+}
+
+// markdownTable renders objects as a table with a column per field any of
+// them has
+func markdownTable(rows []map[string]interface{}) string {
+	union := map[string]interface{}{}
+	for _, row := range rows {
+		for key := range row {
+			union[key] = nil
+		}
+	}
+	columns := fieldOrder(union)
+
+	var b strings.Builder
+	b.WriteString("| " + strings.Join(columns, " | ") + " |\n")
+	b.WriteString("|" + strings.Repeat(" --- |", len(columns)) + "\n")
+	for _, row := range rows {
+		cells := make([]string, len(columns))
+		for i, column := range columns {
+			if value, ok := row[column]; ok && value != nil {
+				cells[i] = tableCell(inlineValue(value))
+			}
+		}
+		b.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+	}
+	return b.String()
+}
+
+// tableCell escapes text for a markdown table cell
+func tableCell(text string) string {
+	text = strings.ReplaceAll(text, "|", `\|`)
+	return strings.ReplaceAll(strings.ReplaceAll(text, "\r", ""), "\n", "<br>")
+}
+
+// inlineValue renders a decoded JSON value on one line: scalars as they are,
+// lists of scalars comma-separated and anything nested as compact JSON
+func inlineValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return jsonNumber(v)
+	case bool:
+		return fmt.Sprint(v)
+	case []interface{}:
+		parts := make([]string, len(v))
+		for i, entry := range v {
+			switch entry.(type) {
+			case map[string]interface{}, []interface{}:
+				encoded, _ := json.Marshal(v)
+				return string(encoded)
+			}
+			parts[i] = inlineValue(entry)
+		}
+		return strings.Join(parts, ", ")
+	}
+	encoded, _ := json.Marshal(value)
+	return string(encoded)
+}
+
+// jsonNumber formats a decoded JSON number without an exponent for whole
+// numbers, e.g. 1048576 rather than 1.048576e+06
+func jsonNumber(n float64) string {
+	if n == float64(int64(n)) {
+		return fmt.Sprintf("%d", int64(n))
+	}
+	return fmt.Sprint(n)
+}
+
+// codeBlock fences text, using a longer fence than any it contains
+func codeBlock(text string) string {
+	fence := "```"
+	for strings.Contains(text, fence) {
+		fence += "`"
+	}
+	return fence + "\n" + strings.TrimRight(text, "\n") + "\n" + fence
+}
@@ -0,0 +1,153 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRenderResult(t *testing.T) {
+	result := map[string]interface{}{
+		"message": "2 workspace(s)",
+		"total":   2,
+		"workspaces": []DevPodWorkspace{
+			{ID: "api", Provider: DevPodWorkspaceProvider{Name: "docker"}, Tags: []string{"team-a", "ci"}},
+			{ID: "web|ui", Note: "line one\nline two"},
+		},
+		"output": "step 1\nstep 2\n",
+	}
+
+	text, err := renderResult(result, FormatJSON)
+	if err != nil || !strings.HasPrefix(text, `{"message":"2 workspace(s)"`) {
+		t.Errorf("Unexpected JSON %s (%v)", text, err)
+	}
+
+	text, err = renderResult(result, FormatMarkdown)
+	if err != nil {
+		t.Fatalf("renderResult failed: %v", err)
+	}
+	for _, want := range []string{
+		"2 workspace(s)\n\n- **total**: 2\n",
+		"### workspaces\n\n| id |",
+		"| api |",
+		"team-a, ci",
+		`| web\|ui |`,
+		"line one<br>line two",
+		"### output\n\n```\nstep 1\nstep 2\n```",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected %q in the markdown:\n%s", want, text)
+		}
+	}
+
+	text, err = renderResult(result, FormatText)
+	if err != nil {
+		t.Fatalf("renderResult failed: %v", err)
+	}
+	for _, want := range []string{"2 workspace(s)\n", "total: 2", "workspaces (2):\n- id=api, ", "tags=team-a, ci", "output:\nstep 1\nstep 2"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected %q in the text:\n%s", want, text)
+		}
+	}
+
+	if text, _ := renderResult(map[string]interface{}{"name": "api"}, ""); text != "map[name:api]" {
+		t.Errorf("Expected the default format to stay Go's print format, got %s", text)
+	}
+}
+
+func TestResultContent(t *testing.T) {
+	result := map[string]interface{}{
+		"workspaces": []map[string]interface{}{
+			{"name": "api", "state": "Running"},
+			{"name": "web", "state": "Running"},
+			{"name": "db", "state": "Stopped"},
+		},
+		"total": 3,
+	}
+	content := resultContent("devpod_listWorkspaces", result, "", false)
+	if len(content) != 2 || content[0]["text"] != "3 workspaces: 2 running, 1 stopped" {
+		t.Fatalf("Expected a summary and the data, got %v", content)
+	}
+	resource := content[1]["resource"].(map[string]interface{})
+	if content[1]["type"] != "resource" || resource["mimeType"] != "application/json" || resource["uri"] != "devpod://result/devpod_listWorkspaces" {
+		t.Errorf("Expected a JSON resource, got %v", content[1])
+	}
+	if data := resource["text"].(string); !strings.Contains(data, `{"name":"db","state":"Stopped"}`) {
+		t.Errorf("Expected the full result in the resource, got %s", data)
+	}
+
+	// A format renders the text block, and other tools keep a single block
+	if content := resultContent("devpod_listWorkspaces", result, FormatMarkdown, true); len(content) != 2 || !strings.Contains(content[0]["text"].(string), "| api | Running |") {
+		t.Errorf("Expected the markdown next to the data, got %v", content)
+	}
+	// The server's default format does not replace the summary
+	if content := resultContent("devpod_listWorkspaces", result, FormatMarkdown, false); content[0]["text"] != "3 workspaces: 2 running, 1 stopped" {
+		t.Errorf("Expected the summary with a default format, got %v", content[0]["text"])
+	}
+	if content := resultContent("devpod_startWorkspace", map[string]interface{}{"name": "api"}, FormatJSON, false); len(content) != 1 || content[0]["text"] != `{"name":"api"}` {
+		t.Errorf("Expected the default format to apply to other tools, got %v", content)
+	}
+	if content := resultContent("devpod_startWorkspace", map[string]interface{}{"name": "api"}, "", false); len(content) != 1 || content[0]["text"] != "map[name:api]" {
+		t.Errorf("Expected only the text of a tool that is not a list, got %v", content)
+	}
+}
+
+func TestSummarizeResult(t *testing.T) {
+	names := make([]interface{}, 12)
+	for i := range names {
+		names[i] = map[string]interface{}{"id": fmt.Sprintf("h%d", i)}
+	}
+	tests := []struct {
+		fields map[string]interface{}
+		want   string
+	}{
+		{map[string]interface{}{"summary": "1 workspace(s)\n- api", "message": "1 workspace(s)"}, "1 workspace(s)\n- api"},
+		{map[string]interface{}{"message": "Found 2 host(s)", "hosts": []interface{}{"a", "b"}, "count": 2.0}, "Found 2 host(s)\n2 hosts: a, b"},
+		{map[string]interface{}{"name": "api", "state": "Running", "ide": map[string]interface{}{"name": "vscode"}}, "api: Running"},
+		{map[string]interface{}{"name": "api", "branch": "main", "files": []interface{}{}}, "name=api, branch=main\n0 files"},
+		{map[string]interface{}{"hosts": names, "total": 20.0, "nextCursor": "12"}, "12 of 20 hosts: h0, h1, h2, h3, h4, h5, h6, h7, h8, h9, 2 more, more with nextCursor"},
+		{map[string]interface{}{"providers": map[string]interface{}{"docker": map[string]interface{}{"default": true}}}, "1 provider: docker"},
+	}
+	for _, tt := range tests {
+		if got := summarizeResult(tt.fields); got != tt.want {
+			t.Errorf("summarizeResult(%v) = %q, want %q", tt.fields, got, tt.want)
+		}
+	}
+}
+
+func TestSingular(t *testing.T) {
+	for plural, want := range map[string]string{"workspaces": "workspace", "processes": "process", "entries": "entry", "openPorts": "openPort", "matches": "match", "status": "status", "address": "address"} {
+		if got := singular(plural); got != want {
+			t.Errorf("singular(%s) = %s, want %s", plural, got, want)
+		}
+	}
+}
+
+func TestToolCallSummaryWithDefaultFormat(t *testing.T) {
+	s := newTestServer(t, &fakeRunner{outputs: map[string]string{"list --output json": `[{"id":"api"}]`}})
+	s.opts.ResultFormat = FormatMarkdown
+	call := s.MCP().GetHandler("tools/call")
+
+	text := func(args string) string {
+		t.Helper()
+		result, err := call(context.Background(), json.RawMessage(`{"name":"devpod_listWorkspaces","arguments":`+args+`}`))
+		if err != nil {
+			t.Fatalf("devpod_listWorkspaces failed: %v", err)
+		}
+		return result.(map[string]interface{})["content"].([]map[string]interface{})[0]["text"].(string)
+	}
+	if got := text(`{}`); got != "1 workspace: api" {
+		t.Errorf("Expected the summary despite -result-format, got %s", got)
+	}
+	if got := text(`{"format":"markdown"}`); !strings.Contains(got, "| api |") {
+		t.Errorf("Expected the requested format, got %s", got)
+	}
+}
+
+func TestCodeBlockFence(t *testing.T) {
+	if got := codeBlock("```go\nx\n```"); !strings.HasPrefix(got, "````\n") || !strings.HasSuffix(got, "\n````") {
+		t.Errorf("Expected a longer fence, got %s", got)
+	}
+}
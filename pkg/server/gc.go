@@ -0,0 +1,206 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+// gcEntry reports what garbage collection did with one stale workspace
+type gcEntry struct {
+	Name        string `json:"name"`
+	State       string `json:"state"`
+	IdleSeconds int64  `json:"idleSeconds"`
+	Action      string `json:"action"`
+	Reclaimed   bool   `json:"reclaimed"`
+	Error       string `json:"error,omitempty"`
+}
+
+// gcReport summarizes a garbage collection run
+type gcReport struct {
+	Policy  string    `json:"policy"`
+	MaxIdle string    `json:"maxIdle"`
+	DryRun  bool      `json:"dryRun"`
+	Entries []gcEntry `json:"workspaces"`
+}
+
+// collectWorkspaces stops or deletes workspaces unused for longer than maxIdle,
+// judged by their lastUsed timestamp. policy is "stop" or "delete"; stopping
+// skips workspaces that are not running. Unless createdBy is empty, only
+// workspaces whose creator matches it are collected. With dryRun nothing is
+// changed.
+func (s *Server) collectWorkspaces(ctx context.Context, policy string, maxIdle time.Duration, createdBy string, dryRun bool) (*gcReport, error) {
+	workspaces, err := s.listWorkspaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+	workspaces = s.filterCreatedBy(ctx, workspaces, createdBy)
+
+	report := &gcReport{Policy: policy, MaxIdle: maxIdle.String(), DryRun: dryRun, Entries: []gcEntry{}}
+	for _, workspace := range workspaces {
+		if workspace.IdleSeconds == nil || time.Duration(*workspace.IdleSeconds)*time.Second < maxIdle {
+			continue
+		}
+
+		entry := gcEntry{
+			Name:        workspace.ID,
+			State:       s.getWorkspaceState(ctx, workspace.ID),
+			IdleSeconds: *workspace.IdleSeconds,
+			Action:      policy,
+		}
+		if policy == "stop" && entry.State != "Running" {
+			continue
+		}
+		if dryRun {
+			report.Entries = append(report.Entries, entry)
+			continue
+		}
+
+		args := []string{"stop", workspace.ID}
+		event := "stopped"
+		var deleted *deletedWorkspace
+		if policy == "delete" {
+			args = []string{"delete", workspace.ID, "--force"}
+			event = "deleted"
+			deleted = s.captureDeleted(ctx, &workspace)
+		}
+		idle := (time.Duration(entry.IdleSeconds) * time.Second).String()
+		release, err := s.lockWorkspace(ctx, workspace.ID, "garbage collection")
+		if err != nil {
+			// a workspace something else is working on is not idle
+			continue
+		}
+		output, err := s.combinedOutput(ctx, args)
+		release()
+		if err != nil {
+			entry.Error = newDevPodError("garbage collection failed", err, output).Error()
+			s.state(ctx).RecordEvent(workspace.ID, "error", fmt.Sprintf("garbage collection %s failed: %v", policy, err))
+		} else {
+			entry.Reclaimed = true
+			s.recordChange(ctx, workspace.ID, event, fmt.Sprintf("Workspace %s by garbage collection after %s idle", event, idle), deleted)
+		}
+		report.Entries = append(report.Entries, entry)
+	}
+
+	sort.Slice(report.Entries, func(i, j int) bool {
+		return report.Entries[i].IdleSeconds > report.Entries[j].IdleSeconds
+	})
+	return report, nil
+}
+
+// runGC periodically collects the stale workspaces of every user until ctx
+// is cancelled
+func (s *Server) runGC(ctx context.Context, interval, maxIdle time.Duration, policy string) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		// Each user's workspaces are collected with the user's DEVPOD_HOME
+		for _, user := range s.stateUsers() {
+			report, err := s.collectWorkspaces(WithUser(ctx, user), policy, maxIdle, "", false)
+			if err != nil {
+				s.reportUserEvent(user, "warning", "gc", fmt.Errorf("workspace garbage collection failed: %w", err))
+				continue
+			}
+			for _, entry := range report.Entries {
+				if entry.Error != "" {
+					s.reportUserEvent(user, "warning", "gc", fmt.Errorf("failed to %s %s: %s", policy, entry.Name, entry.Error))
+				} else {
+					infof("Garbage collection: %s %s after %ds idle", policy, entry.Name, entry.IdleSeconds)
+				}
+			}
+		}
+	}
+}
+
+// registerGCTools registers the tool that collects stale workspaces
+func (s *Server) registerGCTools() {
+	// Collect stale workspaces
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_gcWorkspaces",
+		Description: "Stop or delete workspaces that have not been used for longer than a threshold",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"maxIdle": map[string]interface{}{
+					"type":        "string",
+					"description": "Collect workspaces whose lastUsed is older than this, e.g. 12h (default: server -gc-max-idle)",
+				},
+				"policy": map[string]interface{}{
+					"type":        "string",
+					"enum":        []string{"stop", "delete"},
+					"description": "Stop running workspaces or delete them (default: server -gc-policy)",
+				},
+				"createdBy": map[string]interface{}{
+					"type":        "string",
+					"description": "Only collect workspaces created through this server by this session ID, client name or user; @me for this session (optional)",
+				},
+				"dryRun": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Only report what would be collected",
+				},
+			},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var gcParams struct {
+			MaxIdle   string `json:"maxIdle,omitempty"`
+			Policy    string `json:"policy,omitempty"`
+			CreatedBy string `json:"createdBy,omitempty"`
+			DryRun    bool   `json:"dryRun,omitempty"`
+		}
+
+		if len(params) > 0 {
+			if err := json.Unmarshal(params, &gcParams); err != nil {
+				return nil, mcp.NewInvalidParamsError("Invalid garbage collection parameters")
+			}
+		}
+
+		maxIdle := s.opts.GCMaxIdle
+		if gcParams.MaxIdle != "" {
+			d, err := time.ParseDuration(gcParams.MaxIdle)
+			if err != nil || d <= 0 {
+				return nil, mcp.NewInvalidParamsError("maxIdle must be a positive duration such as 12h")
+			}
+			maxIdle = d
+		}
+
+		switch gcParams.Policy {
+		case "":
+			gcParams.Policy = s.opts.GCPolicy
+		case "stop", "delete":
+		default:
+			return nil, mcp.NewInvalidParamsError("policy must be one of: stop, delete")
+		}
+
+		report, err := s.collectWorkspaces(ctx, gcParams.Policy, maxIdle, gcParams.CreatedBy, gcParams.DryRun)
+		if err != nil {
+			return nil, newDevPodError("failed to collect workspaces", err, nil)
+		}
+
+		reclaimed := 0
+		for _, entry := range report.Entries {
+			if entry.Reclaimed {
+				reclaimed++
+			}
+		}
+		message := fmt.Sprintf("Reclaimed %d of %d stale workspace(s)", reclaimed, len(report.Entries))
+		if gcParams.DryRun {
+			message = fmt.Sprintf("Would %s %d stale workspace(s)", gcParams.Policy, len(report.Entries))
+		}
+
+		return map[string]interface{}{
+			"report":  report,
+			"message": message,
+		}, nil
+	})
+}
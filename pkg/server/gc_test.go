@@ -0,0 +1,42 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCollectWorkspaces(t *testing.T) {
+	stale := time.Now().Add(-48 * time.Hour).UTC().Format(time.RFC3339)
+	fresh := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	runner := &fakeRunner{outputs: map[string]string{
+		"list --output json":           fmt.Sprintf(`[{"id":"old","lastUsed":%q},{"id":"stopped","lastUsed":%q},{"id":"new","lastUsed":%q}]`, stale, stale, fresh),
+		"status old --output json":     `{"state":"Running"}`,
+		"status stopped --output json": `{"state":"Stopped"}`,
+		"status new --output json":     `{"state":"Running"}`,
+	}}
+	s := newTestServer(t, runner)
+
+	report, err := s.collectWorkspaces(context.Background(), "stop", 24*time.Hour, "", false)
+	if err != nil {
+		t.Fatalf("collectWorkspaces failed: %v", err)
+	}
+	if len(report.Entries) != 1 || report.Entries[0].Name != "old" || !report.Entries[0].Reclaimed {
+		t.Errorf("Expected only the stale running workspace to be stopped, got %+v", report.Entries)
+	}
+
+	report, err = s.collectWorkspaces(context.Background(), "delete", 24*time.Hour, "", true)
+	if err != nil {
+		t.Fatalf("collectWorkspaces failed: %v", err)
+	}
+	if len(report.Entries) != 2 {
+		t.Errorf("Expected both stale workspaces in the delete dry run, got %+v", report.Entries)
+	}
+	for _, call := range runner.calls {
+		if call[0] == "delete" {
+			t.Errorf("Dry run must not delete workspaces, ran %s", strings.Join(call, " "))
+		}
+	}
+}
@@ -0,0 +1,337 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+// gitStatusCommand prints the branch headers and changed files of the
+// repository in the current directory in a stable, parseable format
+const gitStatusCommand = `git status --porcelain=v2 --branch`
+
+// gitStatusMarker separates the output of a git operation from the status
+// printed after it
+const gitStatusMarker = "---devpod-git-status---"
+
+// gitFile is a changed file in a workspace repository
+type gitFile struct {
+	Path string `json:"path"`
+	// OrigPath is the previous path of a renamed or copied file
+	OrigPath string `json:"origPath,omitempty"`
+	// Status is modified, added, deleted, renamed, copied, typechange,
+	// untracked or conflicted
+	Status string `json:"status"`
+	// Staged is set when the change is in the index
+	Staged bool `json:"staged"`
+}
+
+// gitStatus is the state of a workspace repository
+type gitStatus struct {
+	Branch   string    `json:"branch,omitempty"`
+	Commit   string    `json:"commit,omitempty"`
+	Detached bool      `json:"detached"`
+	Upstream string    `json:"upstream,omitempty"`
+	Ahead    int       `json:"ahead"`
+	Behind   int       `json:"behind"`
+	Dirty    bool      `json:"dirty"`
+	Files    []gitFile `json:"files"`
+}
+
+// gitStatusNames maps porcelain status letters to status names
+var gitStatusNames = map[byte]string{
+	'M': "modified",
+	'A': "added",
+	'D': "deleted",
+	'R': "renamed",
+	'C': "copied",
+	'T': "typechange",
+}
+
+// parseGitStatus interprets the output of gitStatusCommand
+func parseGitStatus(output string) gitStatus {
+	status := gitStatus{Files: []gitFile{}}
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if len(line) < 2 {
+			continue
+		}
+		switch line[0] {
+		case '#':
+			fields := strings.Fields(line)
+			if len(fields) < 3 {
+				continue
+			}
+			switch fields[1] {
+			case "branch.oid":
+				if fields[2] != "(initial)" {
+					status.Commit = fields[2]
+				}
+			case "branch.head":
+				if fields[2] == "(detached)" {
+					status.Detached = true
+				} else {
+					status.Branch = fields[2]
+				}
+			case "branch.upstream":
+				status.Upstream = fields[2]
+			case "branch.ab":
+				status.Ahead, _ = strconv.Atoi(strings.TrimPrefix(fields[2], "+"))
+				if len(fields) > 3 {
+					status.Behind, _ = strconv.Atoi(strings.TrimPrefix(fields[3], "-"))
+				}
+			}
+		case '1':
+			if parts := strings.SplitN(line, " ", 9); len(parts) == 9 {
+				status.Files = append(status.Files, changedFile(parts[1], parts[8], ""))
+			}
+		case '2':
+			if parts := strings.SplitN(line, " ", 10); len(parts) == 10 {
+				path, orig, _ := strings.Cut(parts[9], "\t")
+				status.Files = append(status.Files, changedFile(parts[1], path, orig))
+			}
+		case 'u':
+			if parts := strings.SplitN(line, " ", 11); len(parts) == 11 {
+				status.Files = append(status.Files, gitFile{Path: parts[10], Status: "conflicted"})
+			}
+		case '?':
+			status.Files = append(status.Files, gitFile{Path: line[2:], Status: "untracked"})
+		}
+	}
+	status.Dirty = len(status.Files) > 0
+	return status
+}
+
+// changedFile describes a tracked file from its two porcelain status
+// letters, the index and worktree state
+func changedFile(xy, path, orig string) gitFile {
+	file := gitFile{Path: path, OrigPath: orig, Status: "modified"}
+	if len(xy) != 2 {
+		return file
+	}
+	file.Staged = xy[0] != '.'
+	letter := xy[0]
+	if !file.Staged {
+		letter = xy[1]
+	}
+	if name, ok := gitStatusNames[letter]; ok {
+		file.Status = name
+	}
+	return file
+}
+
+// splitGitOutput separates the output of a git operation from the status
+// printed after gitStatusMarker
+func splitGitOutput(output string) (string, gitStatus) {
+	operation, status, _ := strings.Cut(output, gitStatusMarker)
+	return strings.TrimSpace(operation), parseGitStatus(status)
+}
+
+// gitCommand runs a git operation in the workspace repository, or in dir
+// below it, and prints the repository status after it succeeds. Without an
+// operation only the status is printed.
+func gitCommand(dir, operation string) string {
+	command := "echo " + gitStatusMarker + " && " + gitStatusCommand
+	if operation != "" {
+		command = operation + " 2>&1 && " + command
+	}
+	if dir != "" {
+		command = "cd " + shellQuote(dir) + " && " + command
+	}
+	return command
+}
+
+// shellQuote quotes a value as a single POSIX shell word
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// validGitRef rejects branch names git would read as options or that could
+// not name a branch
+func validGitRef(ref string) error {
+	if ref == "" {
+		return fmt.Errorf("branch is required")
+	}
+	if strings.HasPrefix(ref, "-") || strings.ContainsAny(ref, " \t\n~^:?*[\\") || strings.Contains(ref, "..") {
+		return fmt.Errorf("invalid branch name %q", ref)
+	}
+	return nil
+}
+
+// runGit runs a git operation in a running workspace and returns its output
+// with the repository status after it. Operations are recorded in the
+// workspace timeline.
+func (s *Server) runGit(ctx context.Context, name, dir, operation, action string) (interface{}, error) {
+	if err := s.requireRunning(ctx, name); err != nil {
+		return nil, err
+	}
+
+	output, err := s.combinedOutput(ctx, []string{"ssh", name, "--command", gitCommand(dir, operation)})
+	if err != nil {
+		if operation != "" {
+			s.state(ctx).RecordEvent(name, "error", fmt.Sprintf("%s failed: %v", operation, err))
+		}
+		return nil, newDevPodError(action, err, output)
+	}
+	if operation != "" {
+		s.state(ctx).RecordEvent(name, "command", fmt.Sprintf("Executed %q", operation))
+	}
+	s.touchWorkspace(ctx, name)
+
+	operationOutput, status := splitGitOutput(string(output))
+	result := map[string]interface{}{
+		"name":     name,
+		"branch":   status.Branch,
+		"commit":   status.Commit,
+		"detached": status.Detached,
+		"upstream": status.Upstream,
+		"ahead":    status.Ahead,
+		"behind":   status.Behind,
+		"dirty":    status.Dirty,
+		"files":    status.Files,
+	}
+	if operation != "" {
+		result["output"] = operationOutput
+	}
+	return result, nil
+}
+
+// registerGitTools registers the tools that inspect and change the git state of
+// workspace projects
+func (s *Server) registerGitTools() {
+	// Show the git state of the workspace project
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_gitStatus",
+		Description: "Get the git branch, ahead/behind counts and changed files of the project inside a running DevPod workspace",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "The name of the workspace",
+				},
+				"path": map[string]interface{}{
+					"type":        "string",
+					"description": "Repository directory inside the workspace (default: the project folder)",
+				},
+			},
+			"required": []string{"name"},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var gitParams struct {
+			Name string `json:"name"`
+			Path string `json:"path"`
+		}
+
+		if err := json.Unmarshal(params, &gitParams); err != nil {
+			return nil, mcp.NewInvalidParamsError("Invalid git status parameters")
+		}
+
+		if gitParams.Name == "" {
+			return nil, mcp.NewInvalidParamsError("Workspace name is required")
+		}
+
+		return s.runGit(ctx, gitParams.Name, gitParams.Path, "", "failed to get git status")
+	})
+
+	// Pull the workspace project
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_gitPull",
+		Description: "Pull the current branch of the project inside a running DevPod workspace and return the resulting git state",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "The name of the workspace",
+				},
+				"path": map[string]interface{}{
+					"type":        "string",
+					"description": "Repository directory inside the workspace (default: the project folder)",
+				},
+				"rebase": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Rebase local commits onto the upstream instead of only fast-forwarding (default: false)",
+				},
+			},
+			"required": []string{"name"},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var gitParams struct {
+			Name   string `json:"name"`
+			Path   string `json:"path"`
+			Rebase bool   `json:"rebase"`
+		}
+
+		if err := json.Unmarshal(params, &gitParams); err != nil {
+			return nil, mcp.NewInvalidParamsError("Invalid git pull parameters")
+		}
+
+		if gitParams.Name == "" {
+			return nil, mcp.NewInvalidParamsError("Workspace name is required")
+		}
+
+		// Never create merge commits on the user's behalf
+		operation := "git pull --ff-only"
+		if gitParams.Rebase {
+			operation = "git pull --rebase"
+		}
+		return s.runGit(ctx, gitParams.Name, gitParams.Path, operation, "failed to pull")
+	})
+
+	// Switch the branch of the workspace project
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_gitCheckout",
+		Description: "Check out a branch of the project inside a running DevPod workspace and return the resulting git state",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "The name of the workspace",
+				},
+				"branch": map[string]interface{}{
+					"type":        "string",
+					"description": "The branch to check out",
+				},
+				"create": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Create the branch from the current commit (default: false)",
+				},
+				"path": map[string]interface{}{
+					"type":        "string",
+					"description": "Repository directory inside the workspace (default: the project folder)",
+				},
+			},
+			"required": []string{"name", "branch"},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var gitParams struct {
+			Name   string `json:"name"`
+			Branch string `json:"branch"`
+			Create bool   `json:"create"`
+			Path   string `json:"path"`
+		}
+
+		if err := json.Unmarshal(params, &gitParams); err != nil {
+			return nil, mcp.NewInvalidParamsError("Invalid git checkout parameters")
+		}
+
+		if gitParams.Name == "" {
+			return nil, mcp.NewInvalidParamsError("Workspace name is required")
+		}
+		if err := validGitRef(gitParams.Branch); err != nil {
+			return nil, mcp.NewInvalidParamsError(err.Error())
+		}
+
+		operation := "git checkout " + shellQuote(gitParams.Branch)
+		if gitParams.Create {
+			operation = "git checkout -b " + shellQuote(gitParams.Branch)
+		}
+		return s.runGit(ctx, gitParams.Name, gitParams.Path, operation, "failed to check out "+gitParams.Branch)
+	})
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+const gitStatusOutput = `# branch.oid 4f2a1c9e
+# branch.head feature/login
+# branch.upstream origin/feature/login
+# branch.ab +2 -3
+1 .M N... 100644 100644 100644 abc abc src/app file.go
+1 A. N... 000000 100644 100644 000 def new.go
+2 R. N... 100644 100644 100644 abc abc R100 renamed.go	old.go
+u UU N... 100644 100644 100644 100644 a b c conflict.go
+? notes.txt
+`
+
+func TestParseGitStatus(t *testing.T) {
+	status := parseGitStatus(gitStatusOutput)
+	if status.Branch != "feature/login" || status.Upstream != "origin/feature/login" || status.Ahead != 2 || status.Behind != 3 || !status.Dirty {
+		t.Errorf("Unexpected status %+v", status)
+	}
+	expected := []gitFile{
+		{Path: "src/app file.go", Status: "modified"},
+		{Path: "new.go", Status: "added", Staged: true},
+		{Path: "renamed.go", OrigPath: "old.go", Status: "renamed", Staged: true},
+		{Path: "conflict.go", Status: "conflicted"},
+		{Path: "notes.txt", Status: "untracked"},
+	}
+	if len(status.Files) != len(expected) {
+		t.Fatalf("Expected %d files, got %+v", len(expected), status.Files)
+	}
+	for i, file := range expected {
+		if status.Files[i] != file {
+			t.Errorf("File %d: expected %+v, got %+v", i, file, status.Files[i])
+		}
+	}
+
+	if clean := parseGitStatus("# branch.oid (initial)\n# branch.head (detached)\n"); !clean.Detached || clean.Dirty || clean.Commit != "" {
+		t.Errorf("Unexpected clean status %+v", clean)
+	}
+}
+
+func TestGitCheckout(t *testing.T) {
+	checkout := gitCommand("/workspaces/it's", "git checkout -b 'fix'")
+	runner := &fakeRunner{outputs: map[string]string{
+		"status api --output json":      `{"id":"api","state":"Running"}`,
+		"ssh api --command " + checkout: "Switched to a new branch 'fix'\n" + gitStatusMarker + "\n# branch.head fix\n",
+	}}
+	s := newTestServer(t, runner)
+	handler := s.MCP().GetHandler("devpod_gitCheckout")
+
+	result, err := handler(context.Background(), json.RawMessage(`{"name":"api","branch":"fix","create":true,"path":"/workspaces/it's"}`))
+	if err != nil {
+		t.Fatalf("devpod_gitCheckout failed: %v", err)
+	}
+	fields := result.(map[string]interface{})
+	if fields["branch"] != "fix" || fields["output"] != "Switched to a new branch 'fix'" {
+		t.Errorf("Unexpected result %v", fields)
+	}
+
+	if _, err := handler(context.Background(), json.RawMessage(`{"name":"api","branch":"--orphan"}`)); err == nil {
+		t.Error("Expected an option-like branch name to be rejected")
+	}
+}
@@ -0,0 +1,338 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+func (s *Server) registerMCPHandlers() {
+	server := s.mcp
+
+	debugf("Registering initialize handler")
+	// Register initialize handler to advertise resources alongside tools
+	server.RegisterHandler("initialize", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		debugf("initialize called")
+		s.setClientCapabilities(ctx, params)
+		s.setSessionClient(ctx, params)
+		serverInfo := map[string]interface{}{
+			"name":    "mcp-server-devpod",
+			"version": s.opts.Version,
+		}
+		if version, err := s.devpodVersion(ctx); err == nil {
+			serverInfo["devpodVersion"] = version
+		}
+		return map[string]interface{}{
+			"protocolVersion": "2024-11-05",
+			"capabilities": map[string]interface{}{
+				"tools": map[string]interface{}{
+					"listChanged": true,
+				},
+				"resources":   map[string]interface{}{},
+				"logging":     map[string]interface{}{},
+				"completions": map[string]interface{}{},
+			},
+			"serverInfo": serverInfo,
+		}, nil
+	})
+
+	// Clients announce changes to the folders they share; the roots of the
+	// session are requested again on next use
+	server.RegisterNotificationHandler("notifications/roots/list_changed", func(ctx context.Context, params json.RawMessage) error {
+		s.invalidateRoots(ctx)
+		return nil
+	})
+
+	debugf("Registering prompts/list handler")
+	// Register prompts/list handler (required by Claude Desktop)
+	server.RegisterHandler("prompts/list", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		debugf("prompts/list called")
+		// Return empty prompts list since we don't provide any prompts
+		return map[string]interface{}{
+			"prompts": []interface{}{},
+		}, nil
+	})
+
+	debugf("Registering resources/list handler")
+	// Register resources/list handler (optional but good practice)
+	server.RegisterHandler("resources/list", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		debugf("resources/list called")
+		resources := []map[string]interface{}{}
+		for _, name := range s.state(ctx).Workspaces() {
+			resources = append(resources, map[string]interface{}{
+				"uri":         timelineURI(name),
+				"name":        fmt.Sprintf("%s timeline", name),
+				"description": fmt.Sprintf("Event timeline for DevPod workspace %s", name),
+				"mimeType":    "application/json",
+			})
+		}
+		return map[string]interface{}{
+			"resources": resources,
+		}, nil
+	})
+
+	debugf("Registering resources/templates/list handler")
+	server.RegisterHandler("resources/templates/list", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		return map[string]interface{}{
+			"resourceTemplates": []map[string]interface{}{
+				{
+					"uriTemplate": "devpod://workspace/{name}/timeline",
+					"name":        "Workspace timeline",
+					"description": "Events recorded for a DevPod workspace (created, started, stopped, commands, errors)",
+					"mimeType":    "application/json",
+				},
+			},
+		}, nil
+	})
+
+	debugf("Registering completion/complete handler")
+	// Offer workspace, provider, environment and secret names for tool and
+	// resource template arguments
+	server.RegisterHandler("completion/complete", s.complete)
+
+	debugf("Registering resources/read handler")
+	server.RegisterHandler("resources/read", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var readParams struct {
+			URI string `json:"uri"`
+		}
+
+		if err := json.Unmarshal(params, &readParams); err != nil {
+			return nil, mcp.NewInvalidParamsError("Invalid resource read parameters")
+		}
+
+		name, ok := parseTimelineURI(readParams.URI)
+		if !ok {
+			return nil, mcp.NewInvalidParamsError(fmt.Sprintf("Unknown resource: %s", readParams.URI))
+		}
+
+		text, err := json.MarshalIndent(map[string]interface{}{
+			"workspace": name,
+			"events":    s.state(ctx).Timeline(name),
+		}, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode timeline: %w", err)
+		}
+
+		return map[string]interface{}{
+			"contents": []map[string]interface{}{
+				{
+					"uri":      readParams.URI,
+					"mimeType": "application/json",
+					"text":     string(text),
+				},
+			},
+		}, nil
+	})
+
+	// Echo tool (from framework)
+	s.RegisterTool(mcp.Tool{
+		Name:        "echo",
+		Description: "Echo back the provided message",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"message": map[string]interface{}{
+					"type":        "string",
+					"description": "The message to echo back",
+				},
+			},
+			"required": []string{"message"},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var echoParams struct {
+			Message string `json:"message"`
+		}
+		if err := json.Unmarshal(params, &echoParams); err != nil {
+			return nil, mcp.NewInvalidParamsError("Missing or invalid 'message' parameter for echo tool")
+		}
+		return fmt.Sprintf("Echo: %s", echoParams.Message), nil
+	})
+
+	debugf("Registering tools/list handler")
+	// Override the default tools/list handler to list the registered tools
+	server.RegisterHandler("tools/list", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		debugf("tools/list called")
+		return s.listTools(ctx), nil
+	})
+}
+
+// registerDevPodHandlers registers the tools of each feature, in its own
+// file, and routes tools/call to them
+func (s *Server) registerDevPodHandlers() {
+	server := s.mcp
+
+	debugf("Registering DevPod handlers")
+
+	s.registerLifecycleTools()
+	s.registerDevcontainerTools()
+	s.registerAnalyzeTools()
+	s.registerLocalProjectTools()
+	s.registerCloneTools()
+	s.registerSpecTools()
+	s.registerEnvironmentTools()
+	s.registerPrebuildTools()
+	s.registerSnapshotTools()
+	s.registerIDETools()
+	s.registerHistoryTools()
+	s.registerBulkTools()
+	s.registerTagTools()
+	s.registerScheduleTools()
+	s.registerProviderTools()
+	s.registerCatalogTools()
+	s.registerCloudProviderTools()
+	s.registerSSHHostTools()
+	s.registerSSHTools()
+	s.registerTaskTools()
+	s.registerTestTools()
+	s.registerSSHConfigTools()
+	s.registerSyncTools()
+	s.registerArtifactTools()
+	s.registerCopyTools()
+	s.registerUsageTools()
+	s.registerVersionTools()
+	s.registerDoctorTools()
+	s.registerInstallTools()
+	s.registerUpgradeTools()
+	s.registerGCTools()
+	s.registerSessionTools()
+	s.registerUserTools()
+	s.registerTroubleshootTools()
+	s.registerBundleTools()
+	s.registerOutputTools()
+	s.registerEventTools()
+	s.registerStatusTools()
+	s.registerStatusAllTools()
+	s.registerStatsTools()
+	s.registerProcessTools()
+	s.registerPortTools()
+	s.registerNetworkTools()
+	s.registerGitTools()
+	s.registerSecretTools()
+
+	// Custom tools/call handler to route tool calls to our DevPod handlers
+	server.RegisterHandler("tools/call", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var callParams struct {
+			Name      string                 `json:"name"`
+			Arguments map[string]interface{} `json:"arguments"`
+			Meta      struct {
+				ProgressToken interface{} `json:"progressToken"`
+				Traceparent   string      `json:"traceparent"`
+			} `json:"_meta"`
+		}
+
+		if err := json.Unmarshal(params, &callParams); err != nil {
+			return nil, mcp.NewInvalidParamsError("Invalid tool call parameters")
+		}
+		if callParams.Meta.Traceparent != "" {
+			ctx = withTraceParent(ctx, callParams.Meta.Traceparent)
+		}
+
+		// Only registered tools are callable, never other methods. Deprecated
+		// names keep working so older client configurations don't break.
+		tool, alias, ok := s.tools.resolve(callParams.Name)
+		if !ok {
+			return nil, mcp.NewInvalidParamsError(fmt.Sprintf("Unknown tool: %s", callParams.Name))
+		}
+		if alias != "" {
+			log.Printf("WARNING: deprecated tool name %s called, use %s", alias, tool.Name)
+			callParams.Name = tool.Name
+		}
+		// Ask the user for missing required arguments rather than failing,
+		// when the client can prompt for them. Calls the policy denies are
+		// refused first, so nobody is asked for values of a call that
+		// cannot run.
+		var elicited []string
+		if missing, elicitable := missingArguments(tool.InputSchema, callParams.Arguments); len(missing) > 0 && elicitable && s.capabilities(ctx).Elicitation != nil {
+			if err := s.checkPolicy(ctx, tool.Name, callParams.Arguments); err != nil {
+				return nil, err
+			}
+			if callParams.Arguments == nil {
+				callParams.Arguments = make(map[string]interface{})
+			}
+			if err := s.elicitArguments(ctx, tool.Name, tool.InputSchema, callParams.Arguments, missing); err != nil {
+				if !errors.Is(err, errElicitationDeclined) {
+					s.reportUserEvent(UserName(ctx), "warning", "elicitation", fmt.Errorf("failed to elicit arguments of %s: %w", tool.Name, err))
+				}
+				return nil, mcp.NewInvalidParamsError(fmt.Sprintf("Invalid arguments for %s: %s is required (%v)", callParams.Name, strings.Join(missing, ", "), err))
+			}
+			elicited = missing
+		}
+		if err := validateArguments(tool.InputSchema, callParams.Arguments); err != nil {
+			return nil, mcp.NewInvalidParamsError(fmt.Sprintf("Invalid arguments for %s: %v", callParams.Name, err))
+		}
+
+		// Fail clearly instead of with a flag parse error of an old CLI
+		if err := s.requireDevPod(ctx, callParams.Name); err != nil {
+			return nil, err
+		}
+
+		// Per-call environment variables go to the devpod commands, not to
+		// the handler, so their values never show up in its debug logs
+		var env []string
+		if value, ok := callParams.Arguments["env"]; ok && acceptsEnv(tool.Name) {
+			parsed, err := s.callEnv(value)
+			if err != nil {
+				return nil, mcp.NewInvalidParamsError(fmt.Sprintf("Invalid arguments for %s: %v", callParams.Name, err))
+			}
+			env = parsed
+			delete(callParams.Arguments, "env")
+		}
+
+		// The result format is applied here, not by the handler
+		format, requested := callParams.Arguments["format"].(string)
+		if !requested {
+			format = s.opts.ResultFormat
+		}
+		delete(callParams.Arguments, "format")
+
+		// Convert arguments back to JSON for the handler
+		argsBytes, err := json.Marshal(callParams.Arguments)
+		if err != nil {
+			return nil, mcp.NewInvalidParamsError("Failed to marshal tool arguments")
+		}
+
+		// Call the handler, streaming devpod's console output while it runs
+		streamCtx, finishStream := s.streamOutput(ctx, tool.Name, callParams.Meta.ProgressToken)
+		streamCtx, attempts := withAttempts(streamCtx)
+		if len(env) > 0 {
+			streamCtx = WithCommandEnv(streamCtx, env)
+		}
+		result, err := tool.handler(streamCtx, argsBytes)
+		finishStream()
+		// Report retries of transient failures with the outcome
+		if n := attempts.retried(); n > 0 {
+			var rpcErr *mcp.RPCError
+			if data, ok := result.(map[string]interface{}); ok {
+				data["attempts"] = n
+			} else if errors.As(err, &rpcErr) {
+				if data, ok := rpcErr.Data.(map[string]interface{}); ok {
+					data["attempts"] = n
+				}
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if data, ok := result.(map[string]interface{}); ok && len(elicited) > 0 {
+			data["elicitedArguments"] = elicited
+		}
+		s.limitOutput(ctx, tool.Name, result)
+
+		// Wrap the result in the expected ToolsCallResult format
+		callResult := map[string]interface{}{
+			"content": resultContent(tool.Name, result, format, requested),
+		}
+		if alias != "" {
+			callResult["_meta"] = map[string]interface{}{
+				"deprecated": fmt.Sprintf("Tool name %s is deprecated; use %s", alias, tool.Name),
+				"toolName":   tool.Name,
+			}
+		}
+		return callResult, nil
+	})
+}
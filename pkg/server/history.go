@@ -0,0 +1,352 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+// maxOperations bounds the number of recent operations kept for undo
+const maxOperations = 100
+
+// operation is a workspace lifecycle change made through the server, kept
+// with what it takes to undo it
+type operation struct {
+	ID        string    `json:"id"`
+	Time      time.Time `json:"time"`
+	Workspace string    `json:"workspace"`
+	// Event is created, started, stopped or deleted
+	Event   string `json:"event"`
+	Message string `json:"message,omitempty"`
+	Session string `json:"session,omitempty"`
+	User    string `json:"user,omitempty"`
+	// Deleted describes a deleted workspace so it can be recreated
+	Deleted *deletedWorkspace `json:"deleted,omitempty"`
+	// Undoes is the operation this one undid, and UndoneBy the one that
+	// undid this one
+	Undoes   string `json:"undoes,omitempty"`
+	UndoneBy string `json:"undoneBy,omitempty"`
+}
+
+// deletedWorkspace is what the server knew about a workspace before it was
+// deleted
+type deletedWorkspace struct {
+	Spec *workspaceSpec `json:"spec"`
+	// OmittedOptions are provider options marked as passwords, which the
+	// spec leaves out
+	OmittedOptions []string `json:"omittedOptions,omitempty"`
+	Tags           []string `json:"tags,omitempty"`
+	Note           string   `json:"note,omitempty"`
+	Host           string   `json:"host,omitempty"`
+	// CreatedBy is who created the workspace, restored with it
+	CreatedBy *workspaceCreator `json:"createdBy,omitempty"`
+}
+
+// newOperationID returns a random operation ID
+func newOperationID() string {
+	bytes := make([]byte, 6)
+	if _, err := rand.Read(bytes); err != nil {
+		return fmt.Sprintf("op-%d", time.Now().UnixNano())
+	}
+	return "op-" + hex.EncodeToString(bytes)
+}
+
+// undoable reports whether the operation can be undone and, if not, why
+func (op operation) undoable() (bool, string) {
+	switch {
+	case op.UndoneBy != "":
+		return false, "already undone by " + op.UndoneBy
+	case op.Event == "created":
+		return false, "undoing a create would delete the workspace; use devpod_deleteWorkspace"
+	case op.Event == "deleted" && op.Deleted == nil:
+		return false, "the workspace could not be described before it was deleted"
+	}
+	return true, ""
+}
+
+// captureDeleted describes a workspace about to be deleted so the deletion
+// can be undone. It returns nil if the workspace is unknown.
+func (s *Server) captureDeleted(ctx context.Context, workspace *DevPodWorkspace) *deletedWorkspace {
+	if workspace == nil {
+		return nil
+	}
+	spec, omitted := s.specFromWorkspace(ctx, *workspace)
+	store := s.state(ctx)
+	metadata := store.Metadata(workspace.ID)
+	deleted := &deletedWorkspace{Spec: spec, OmittedOptions: omitted, Tags: metadata.Tags, Note: metadata.Note, CreatedBy: store.Creator(workspace.ID)}
+	for _, host := range store.SSHHosts() {
+		for _, name := range host.Workspaces {
+			if name == workspace.ID {
+				deleted.Host = host.Name
+			}
+		}
+	}
+	return deleted
+}
+
+// captureDeletedByName describes a workspace about to be deleted like
+// captureDeleted, looking it up first. Failures only cost the undo.
+func (s *Server) captureDeletedByName(ctx context.Context, name string) *deletedWorkspace {
+	workspace, err := s.findWorkspace(ctx, name)
+	if err != nil {
+		log.Printf("WARNING: failed to describe workspace %s before deleting it, the deletion cannot be undone: %v", name, err)
+		return nil
+	}
+	return s.captureDeleted(ctx, workspace)
+}
+
+// undoOperation reverses op: a deleted workspace is recreated from its spec
+// with its tags, note and host, a stopped one started and a started one
+// stopped. It returns the devpod output.
+func (s *Server) undoOperation(ctx context.Context, op operation) ([]byte, error) {
+	message := fmt.Sprintf("Undid %s (%s)", op.ID, op.Message)
+	switch op.Event {
+	case "deleted":
+		spec := *op.Deleted.Spec
+		source, err := sourceArg(spec.Source, "", "")
+		if err != nil {
+			return nil, fmt.Errorf("cannot recreate %s: %w", op.Workspace, err)
+		}
+		if exists, err := s.workspaceExists(ctx, op.Workspace); err != nil {
+			return nil, newDevPodError("failed to check for existing workspace", err, nil)
+		} else if exists {
+			return nil, fmt.Errorf("a workspace named %s exists again", op.Workspace)
+		}
+		output, err := s.createFromSpec(withUndo(ctx, op.ID), &spec, source, message)
+		if err != nil {
+			return output, withPhases(newDevPodError("failed to recreate workspace", err, output), output)
+		}
+		if len(op.Deleted.Tags) > 0 || op.Deleted.Note != "" {
+			s.state(ctx).SetMetadata(op.Workspace, workspaceMetadata{Tags: op.Deleted.Tags, Note: op.Deleted.Note, Updated: time.Now().UTC()})
+		}
+		if op.Deleted.Host != "" {
+			s.state(ctx).SetWorkspaceHost(op.Workspace, op.Deleted.Host)
+		}
+		if op.Deleted.CreatedBy != nil {
+			s.state(ctx).SetCreator(op.Workspace, op.Deleted.CreatedBy)
+		}
+		return output, nil
+	case "stopped":
+		output, _, err := s.startWorkspace(withUndo(ctx, op.ID), op.Workspace, "")
+		return output, err
+	case "started":
+		return s.stopWorkspace(withUndo(ctx, op.ID), op.Workspace, message)
+	}
+	return nil, fmt.Errorf("cannot undo %s", op.Event)
+}
+
+// undoKey is the context key for the operation a change undoes
+type undoKey struct{}
+
+// withUndo returns a context whose lifecycle changes undo the operation id
+func withUndo(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, undoKey{}, id)
+}
+
+// undoing returns the operation the changes made with ctx undo, if any
+func undoing(ctx context.Context) string {
+	id, _ := ctx.Value(undoKey{}).(string)
+	return id
+}
+
+// operationView is an operation as devpod_listRecentOperations reports it
+type operationView struct {
+	ID        string    `json:"id"`
+	Time      time.Time `json:"time"`
+	Workspace string    `json:"workspace"`
+	Event     string    `json:"event"`
+	Message   string    `json:"message,omitempty"`
+	Session   string    `json:"session,omitempty"`
+	User      string    `json:"user,omitempty"`
+	// Source is what a deleted workspace would be recreated from
+	Source   string `json:"source,omitempty"`
+	Undoes   string `json:"undoes,omitempty"`
+	UndoneBy string `json:"undoneBy,omitempty"`
+	Undoable bool   `json:"undoable"`
+	// Reason says why the operation cannot be undone
+	Reason string `json:"reason,omitempty"`
+}
+
+// view describes op for listing
+func (op operation) view() operationView {
+	undoable, reason := op.undoable()
+	view := operationView{
+		ID:        op.ID,
+		Time:      op.Time,
+		Workspace: op.Workspace,
+		Event:     op.Event,
+		Message:   op.Message,
+		Session:   op.Session,
+		User:      op.User,
+		Undoes:    op.Undoes,
+		UndoneBy:  op.UndoneBy,
+		Undoable:  undoable,
+		Reason:    reason,
+	}
+	if op.Deleted != nil && op.Deleted.Spec != nil {
+		view.Source, _ = sourceArg(op.Deleted.Spec.Source, "", "")
+	}
+	return view
+}
+
+// recentOperations returns the recent operations of the user of ctx, most
+// recent first, on workspace unless empty
+func (s *Server) recentOperations(ctx context.Context, workspace string) []operation {
+	var operations []operation
+	for _, op := range s.state(ctx).Operations() {
+		if workspace == "" || op.Workspace == workspace {
+			operations = append(operations, op)
+		}
+	}
+	return operations
+}
+
+// operationToUndo picks the operation to undo: the one with the given ID, or
+// the most recent one that is not itself an undo or undone
+func (s *Server) operationToUndo(ctx context.Context, id, workspace string) (operation, error) {
+	for _, op := range s.recentOperations(ctx, workspace) {
+		if id != "" && op.ID == id {
+			return op, nil
+		}
+		if id == "" && op.Undoes == "" && op.UndoneBy == "" {
+			return op, nil
+		}
+	}
+	if id != "" {
+		return operation{}, fmt.Errorf("operation %s not found", id)
+	}
+	return operation{}, fmt.Errorf("no operation to undo")
+}
+
+// registerHistoryTools registers the tools that list and undo recent operations
+func (s *Server) registerHistoryTools() {
+	// List recent operations
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_listRecentOperations",
+		Description: "List the recent workspace creations, starts, stops and deletions made through this server, most recent first, and whether each can be undone with devpod_undoLastOperation",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "Only list operations on this workspace (optional)",
+				},
+				"limit": map[string]interface{}{
+					"type":        "integer",
+					"description": "Maximum number of operations to return (default: 20)",
+				},
+			},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var listParams struct {
+			Name  string `json:"name,omitempty"`
+			Limit int    `json:"limit,omitempty"`
+		}
+
+		if len(params) > 0 {
+			if err := json.Unmarshal(params, &listParams); err != nil {
+				return nil, mcp.NewInvalidParamsError("Invalid list operations parameters")
+			}
+		}
+		if listParams.Limit < 0 {
+			return nil, mcp.NewInvalidParamsError("limit must not be negative")
+		}
+		if listParams.Limit == 0 {
+			listParams.Limit = 20
+		}
+
+		operations := s.recentOperations(ctx, listParams.Name)
+		total := len(operations)
+		if len(operations) > listParams.Limit {
+			operations = operations[:listParams.Limit]
+		}
+		views := make([]operationView, len(operations))
+		for i, op := range operations {
+			views[i] = op.view()
+		}
+
+		return map[string]interface{}{
+			"operations": views,
+			"count":      len(views),
+			"total":      total,
+			"message":    fmt.Sprintf("%d of %d recent operation(s)", len(views), total),
+		}, nil
+	})
+
+	// Undo an operation
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_undoLastOperation",
+		Description: "Best-effort undo of a recent operation made through this server: a deleted workspace is recreated from its recorded source, provider and options, a stopped one started and a started one stopped. Files inside a deleted workspace are not restored.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"id": map[string]interface{}{
+					"type":        "string",
+					"description": "The operation to undo, from devpod_listRecentOperations (optional, defaults to the most recent one not undone)",
+				},
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "Undo the most recent operation on this workspace (optional)",
+				},
+			},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var undoParams struct {
+			ID   string `json:"id,omitempty"`
+			Name string `json:"name,omitempty"`
+		}
+
+		if len(params) > 0 {
+			if err := json.Unmarshal(params, &undoParams); err != nil {
+				return nil, mcp.NewInvalidParamsError("Invalid undo parameters")
+			}
+		}
+
+		op, err := s.operationToUndo(ctx, undoParams.ID, undoParams.Name)
+		if err != nil {
+			return nil, mcp.NewInvalidParamsError(err.Error())
+		}
+		release, err := s.lockWorkspace(ctx, op.Workspace, "devpod_undoLastOperation")
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		// Another call may have undone it while this one waited for the lock
+		if op, err = s.operationToUndo(ctx, op.ID, ""); err != nil {
+			return nil, mcp.NewInvalidParamsError(err.Error())
+		}
+		if ok, reason := op.undoable(); !ok {
+			return nil, mcp.NewInvalidParamsError(fmt.Sprintf("Operation %s (%s %s) cannot be undone: %s", op.ID, op.Event, op.Workspace, reason))
+		}
+
+		start := time.Now()
+		output, err := s.undoOperation(ctx, op)
+		if err != nil {
+			return nil, err
+		}
+
+		result := map[string]interface{}{
+			"undone":     op.ID,
+			"event":      op.Event,
+			"name":       op.Workspace,
+			"output":     string(output),
+			"durationMs": durationMs(start),
+			"message":    fmt.Sprintf("Undid %s of workspace %s", op.Event, op.Workspace),
+		}
+		if op.Event == "deleted" {
+			result["message"] = fmt.Sprintf("Recreated workspace %s; files that were only inside the deleted workspace are not restored", op.Workspace)
+			if len(op.Deleted.OmittedOptions) > 0 {
+				result["omittedOptions"] = op.Deleted.OmittedOptions
+				result["warning"] = "password provider options were not recorded and were not set again: " + strings.Join(op.Deleted.OmittedOptions, ", ")
+			}
+		}
+		return result, nil
+	})
+}
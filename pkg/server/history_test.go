@@ -0,0 +1,76 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestUndoDeletedWorkspace(t *testing.T) {
+	runner := &fakeRunner{outputs: map[string]string{
+		"list --output json":                    `[{"id":"api","source":{"gitRepository":"https://github.com/acme/api","gitBranch":"main"},"provider":{"name":"docker","options":{"DOCKER_HOST":{"value":"tcp://build:2375"},"TOKEN":{"value":"secret"}}},"ide":{"name":"vscode"}}]`,
+		"provider options docker --output json": `{"DOCKER_HOST":{},"TOKEN":{"password":true}}`,
+	}}
+	s := newTestServer(t, runner)
+	ctx := context.Background()
+	call := func(tool, args string) (map[string]interface{}, error) {
+		t.Helper()
+		result, err := s.MCP().GetHandler(tool)(ctx, json.RawMessage(args))
+		if err != nil {
+			return nil, err
+		}
+		return result.(map[string]interface{}), nil
+	}
+
+	s.store.SetMetadata("api", workspaceMetadata{Tags: []string{"ticket-1234"}, Note: "keep"})
+	if _, err := call("devpod_stopWorkspace", `{"name":"api"}`); err != nil {
+		t.Fatalf("devpod_stopWorkspace failed: %v", err)
+	}
+	if _, err := call("devpod_deleteWorkspace", `{"name":"api"}`); err != nil {
+		t.Fatalf("devpod_deleteWorkspace failed: %v", err)
+	}
+	runner.outputs["list --output json"] = `[]`
+
+	result, err := call("devpod_listRecentOperations", `{}`)
+	if err != nil {
+		t.Fatalf("devpod_listRecentOperations failed: %v", err)
+	}
+	operations := result["operations"].([]operationView)
+	if len(operations) != 2 || operations[0].Event != "deleted" || !operations[0].Undoable || operations[0].Source != "https://github.com/acme/api@main" {
+		t.Fatalf("Unexpected operations %+v", operations)
+	}
+
+	result, err = call("devpod_undoLastOperation", `{}`)
+	if err != nil {
+		t.Fatalf("devpod_undoLastOperation failed: %v", err)
+	}
+	if calls := fmt.Sprint(runner.calls); !strings.Contains(calls, "[up https://github.com/acme/api@main --id api --provider-option DOCKER_HOST=tcp://build:2375 --provider docker --ide vscode]") {
+		t.Errorf("Expected the workspace to be recreated, got calls %v", calls)
+	}
+	if fmt.Sprint(result["omittedOptions"]) != "[TOKEN]" {
+		t.Errorf("Expected the password option to be reported, got %v", result)
+	}
+	if metadata := s.store.Metadata("api"); fmt.Sprint(metadata.Tags) != "[ticket-1234]" || metadata.Note != "keep" {
+		t.Errorf("Expected the tags and note to be restored, got %+v", metadata)
+	}
+
+	// The recreation undid the deletion, so the stop is undone next
+	recent := s.recentOperations(ctx, "")
+	if recent[0].Event != "created" || recent[0].Undoes != recent[1].ID || recent[1].UndoneBy != recent[0].ID {
+		t.Errorf("Expected the undo to be linked to the deletion, got %+v", recent)
+	}
+	if _, err := call("devpod_undoLastOperation", `{}`); err != nil {
+		t.Fatalf("devpod_undoLastOperation of the stop failed: %v", err)
+	}
+	if last := runner.calls[len(runner.calls)-1]; fmt.Sprint(last) != "[up api]" {
+		t.Errorf("Expected the workspace to be started, got %v", last)
+	}
+	if _, err := call("devpod_undoLastOperation", `{}`); err == nil || !strings.Contains(err.Error(), "no operation to undo") {
+		t.Errorf("Expected nothing left to undo, got %v", err)
+	}
+	if _, err := call("devpod_undoLastOperation", fmt.Sprintf(`{"id":%q}`, recent[0].ID)); err == nil || !strings.Contains(err.Error(), "would delete the workspace") {
+		t.Errorf("Expected a creation not to be undone, got %v", err)
+	}
+}
@@ -0,0 +1,424 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+// sshHost is a machine registered for the ssh provider under a friendly name
+type sshHost struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+	User    string `json:"user,omitempty"`
+	Port    int    `json:"port,omitempty"`
+	// IdentityFile is the private key ssh uses, on the server's machine
+	IdentityFile string `json:"identityFile,omitempty"`
+	// DockerPath is the docker binary on the host (default: docker)
+	DockerPath  string `json:"dockerPath,omitempty"`
+	Description string `json:"description,omitempty"`
+	// Workspaces were created on the host through the server
+	Workspaces []string   `json:"workspaces,omitempty"`
+	LastCheck  *hostCheck `json:"lastCheck,omitempty"`
+	Added      time.Time  `json:"added"`
+}
+
+// hostCheck is the outcome of testing a host
+type hostCheck struct {
+	Checked   time.Time `json:"checked"`
+	Reachable bool      `json:"reachable"`
+	OS        string    `json:"os,omitempty"`
+	Arch      string    `json:"arch,omitempty"`
+	// Sudo is set when the user is root or may sudo without a password
+	Sudo bool `json:"sudo"`
+	// Docker is set when the docker binary is installed, DockerAccess when
+	// the user can reach its daemon
+	Docker        bool   `json:"docker"`
+	DockerAccess  bool   `json:"dockerAccess"`
+	DockerVersion string `json:"dockerVersion,omitempty"`
+	// Ready is set when devpod can run workspaces on the host
+	Ready     bool     `json:"ready"`
+	Problems  []string `json:"problems,omitempty"`
+	LatencyMs int64    `json:"latencyMs"`
+}
+
+// hostName matches the friendly names of hosts
+var hostName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// hostMarker precedes the facts the host test prints
+const hostMarker = "---devpod-host---"
+
+// hostConnectTimeout bounds how long the host test waits for a connection
+const hostConnectTimeout = 10 * time.Second
+
+// validate checks a host before it is registered. Values that ssh or the
+// provider would read as options or split into several are rejected.
+func (h sshHost) validate() error {
+	if !hostName.MatchString(h.Name) {
+		return fmt.Errorf("name must start with a letter or digit and contain only letters, digits, '.', '_' and '-'")
+	}
+	for field, value := range map[string]string{"address": h.Address, "user": h.User, "identityFile": h.IdentityFile, "dockerPath": h.DockerPath} {
+		if strings.HasPrefix(value, "-") || strings.ContainsAny(value, " \t\n\"'") {
+			return fmt.Errorf("%s must not start with '-' or contain spaces or quotes", field)
+		}
+	}
+	if h.Address == "" || strings.Contains(h.Address, "@") {
+		return fmt.Errorf("address is required and takes no user; set user instead")
+	}
+	if strings.Contains(h.User, "@") {
+		return fmt.Errorf("user must not contain '@'")
+	}
+	if h.Port < 0 || h.Port > 65535 {
+		return fmt.Errorf("port must be between 1 and 65535")
+	}
+	if h.IdentityFile != "" {
+		if _, err := os.Stat(expandHome(h.IdentityFile)); err != nil {
+			return fmt.Errorf("identityFile: %v", err)
+		}
+	}
+	return nil
+}
+
+// expandHome replaces a leading ~/ with the user's home directory
+func expandHome(path string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	return path
+}
+
+// destination returns the user@address ssh connects to
+func (h sshHost) destination() string {
+	if h.User == "" {
+		return h.Address
+	}
+	return h.User + "@" + h.Address
+}
+
+// providerOptions returns the options of the ssh provider that target the host
+func (h sshHost) providerOptions() map[string]string {
+	options := map[string]string{"HOST": h.destination()}
+	if h.Port != 0 {
+		options["PORT"] = strconv.Itoa(h.Port)
+	}
+	if h.IdentityFile != "" {
+		options["EXTRA_FLAGS"] = "-i " + expandHome(h.IdentityFile)
+	}
+	if h.DockerPath != "" {
+		options["DOCKER_PATH"] = h.DockerPath
+	}
+	return options
+}
+
+// hostTestCommand prints whether the user can sudo and reach docker
+func hostTestCommand(dockerPath string) string {
+	if dockerPath == "" {
+		dockerPath = "docker"
+	}
+	return fmt.Sprintf(`printf '\n%[1]s\n'; echo "uid=$(id -u)"; echo "os=$(uname -s)"; echo "arch=$(uname -m)"; `+
+		`if sudo -n true 2>/dev/null; then echo sudo=yes; else echo sudo=no; fi; `+
+		`if command -v %[2]s >/dev/null 2>&1; then echo docker=yes; echo "dockerVersion=$(%[2]s version --format '{{.Server.Version}}' 2>/dev/null)"; else echo docker=no; fi`,
+		hostMarker, shellQuote(dockerPath))
+}
+
+// parseHostCheck reads the facts hostTestCommand printed
+func parseHostCheck(output string) (hostCheck, bool) {
+	_, facts, ok := strings.Cut(output, hostMarker+"\n")
+	if !ok {
+		return hostCheck{}, false
+	}
+	check := hostCheck{Reachable: true}
+	for _, line := range strings.Split(facts, "\n") {
+		key, value, _ := strings.Cut(strings.TrimSpace(line), "=")
+		switch key {
+		case "uid":
+			check.Sudo = check.Sudo || value == "0"
+		case "os":
+			check.OS = value
+		case "arch":
+			check.Arch = value
+		case "sudo":
+			check.Sudo = check.Sudo || value == "yes"
+		case "docker":
+			check.Docker = value == "yes"
+		case "dockerVersion":
+			check.DockerVersion = value
+			check.DockerAccess = value != ""
+		}
+	}
+
+	switch {
+	case check.DockerAccess:
+	case check.Docker && check.Sudo:
+		check.Problems = append(check.Problems, "the user cannot reach the docker daemon without sudo; add it to the docker group for faster starts")
+	case check.Docker:
+		check.Problems = append(check.Problems, "the user cannot reach the docker daemon and cannot sudo; add it to the docker group")
+	case check.Sudo:
+		check.Problems = append(check.Problems, "docker is not installed; devpod installs it with sudo when the first workspace starts")
+	default:
+		check.Problems = append(check.Problems, "docker is not installed and the user cannot sudo to install it")
+	}
+	if check.OS != "" && check.OS != "Linux" {
+		check.Problems = append(check.Problems, fmt.Sprintf("the host runs %s; the ssh provider needs Linux", check.OS))
+	}
+	check.Ready = (check.DockerAccess || check.Sudo) && (check.OS == "" || check.OS == "Linux")
+	return check, true
+}
+
+// testHost connects to a host with ssh and checks that devpod can run
+// workspaces there. Unknown host keys are accepted and remembered, like
+// the first connection of the ssh provider would.
+func (s *Server) testHost(ctx context.Context, host sshHost) hostCheck {
+	args := []string{"-o", "BatchMode=yes", "-o", "StrictHostKeyChecking=accept-new",
+		"-o", fmt.Sprintf("ConnectTimeout=%d", int(hostConnectTimeout/time.Second))}
+	if host.Port != 0 {
+		args = append(args, "-p", strconv.Itoa(host.Port))
+	}
+	if host.IdentityFile != "" {
+		args = append(args, "-i", expandHome(host.IdentityFile))
+	}
+	args = append(args, host.destination(), hostTestCommand(host.DockerPath))
+
+	ctx, cancel := context.WithTimeout(WithOutputWriter(ctx, nil), 2*hostConnectTimeout)
+	defer cancel()
+	start := time.Now()
+	stdout, stderr, err := s.sshCLI.Run(ctx, args)
+
+	check, ok := parseHostCheck(string(stdout))
+	check.Checked = time.Now().UTC()
+	check.LatencyMs = durationMs(start)
+	if !ok {
+		// ssh explains failures to connect or authenticate on stderr
+		problem := strings.TrimSpace(sanitizeOutput(string(stderr)))
+		switch {
+		case problem != "":
+		case err != nil:
+			problem = err.Error()
+		default:
+			problem = "the host did not run the test command"
+		}
+		check.Problems = []string{problem}
+	}
+	return check
+}
+
+// hostReadiness completes a message about a host with the outcome of its test
+func hostReadiness(check hostCheck) string {
+	if check.Ready {
+		return " and ready for workspaces"
+	}
+	return fmt.Sprintf(", but not ready for workspaces: %s", strings.Join(check.Problems, "; "))
+}
+
+// ensureSSHProvider adds the ssh provider, without configuring it, when it
+// is not installed. Workspaces on registered hosts pass their options to
+// devpod up.
+func (s *Server) ensureSSHProvider(ctx context.Context) error {
+	installed, err := s.installedProviders(ctx)
+	if err != nil {
+		return err
+	}
+	if _, ok := installed["ssh"]; ok {
+		return nil
+	}
+	if output, err := s.combinedOutput(ctx, []string{"provider", "add", "ssh", "--use=false"}); err != nil {
+		return &CommandError{Args: []string{"provider", "add", "ssh"}, Stdout: output, Err: err}
+	}
+	s.providerSchemaCache.invalidate()
+	return nil
+}
+
+// registerSSHHostTools registers the tools that manage the hosts of the ssh
+// provider
+func (s *Server) registerSSHHostTools() {
+	// Register a host of the ssh provider
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_addSSHHost",
+		Description: "Register a machine for the ssh provider under a friendly name, which devpod_createWorkspace accepts as host instead of raw provider options. Registering a name again replaces its settings.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "Friendly name of the host, e.g. build-box",
+				},
+				"address": map[string]interface{}{
+					"type":        "string",
+					"description": "Hostname or IP address, or a Host alias of the server's ~/.ssh/config",
+				},
+				"user": map[string]interface{}{
+					"type":        "string",
+					"description": "User to log in as (default: ssh's default for the host)",
+				},
+				"port": map[string]interface{}{
+					"type":        "integer",
+					"description": "SSH port (default: 22)",
+				},
+				"identityFile": map[string]interface{}{
+					"type":        "string",
+					"description": "Private key on the server's machine, e.g. ~/.ssh/id_ed25519 (default: ssh's keys and agent)",
+				},
+				"dockerPath": map[string]interface{}{
+					"type":        "string",
+					"description": "Path of the docker binary on the host (default: docker)",
+				},
+				"description": map[string]interface{}{
+					"type":        "string",
+					"description": "What the host is for (optional)",
+				},
+				"test": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Test the host like devpod_testSSHHost after registering it (default: true)",
+				},
+			},
+			"required": []string{"name", "address"},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var addParams struct {
+			sshHost
+			Test *bool `json:"test,omitempty"`
+		}
+		if err := json.Unmarshal(params, &addParams); err != nil {
+			return nil, mcp.NewInvalidParamsError("Invalid add SSH host parameters")
+		}
+		host := addParams.sshHost
+		if err := host.validate(); err != nil {
+			return nil, mcp.NewInvalidParamsError(fmt.Sprintf("Invalid host %s: %v", host.Name, err))
+		}
+
+		existing, replaced := s.state(ctx).SSHHost(host.Name)
+		host.Workspaces, host.LastCheck, host.Added = existing.Workspaces, nil, time.Now().UTC()
+		if replaced {
+			host.Added = existing.Added
+		}
+		message := fmt.Sprintf("Host %s registered", host.Name)
+		if addParams.Test == nil || *addParams.Test {
+			check := s.testHost(ctx, host)
+			host.LastCheck = &check
+			message += hostReadiness(check)
+		}
+		s.state(ctx).SetSSHHost(host)
+
+		return map[string]interface{}{
+			"host":     host,
+			"replaced": replaced,
+			"message":  message,
+		}, nil
+	})
+
+	// List the hosts of the ssh provider
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_listSSHHosts",
+		Description: "List the machines registered for the ssh provider with their settings, the result of their last test and the workspaces created on them",
+		InputSchema: map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		hosts := s.state(ctx).SSHHosts()
+		return map[string]interface{}{
+			"hosts":   hosts,
+			"count":   len(hosts),
+			"message": fmt.Sprintf("Found %d host(s)", len(hosts)),
+		}, nil
+	})
+
+	// Test a host of the ssh provider
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_testSSHHost",
+		Description: "Check that a registered host is ready for workspaces: that ssh connects without prompting, whether the user can sudo without a password, and whether docker is installed and reachable",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "The name of the host",
+				},
+			},
+			"required": []string{"name"},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var testParams struct {
+			Name string `json:"name"`
+		}
+		if err := json.Unmarshal(params, &testParams); err != nil {
+			return nil, mcp.NewInvalidParamsError("Invalid test SSH host parameters")
+		}
+		host, ok := s.state(ctx).SSHHost(testParams.Name)
+		if !ok {
+			return nil, mcp.NewInvalidParamsError(fmt.Sprintf("Host %s not found", testParams.Name))
+		}
+
+		check := s.testHost(ctx, host)
+		host.LastCheck = &check
+		s.state(ctx).SetSSHHost(host)
+		return map[string]interface{}{
+			"name":    host.Name,
+			"check":   check,
+			"message": "Host " + host.Name + hostReadiness(check),
+		}, nil
+	})
+
+	// Remove a host of the ssh provider
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_removeSSHHost",
+		Description: "Forget a registered host of the ssh provider. Hosts with workspaces created on them are kept unless force is set; the workspaces and the machine itself are left alone.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "The name of the host",
+				},
+				"force": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Remove the host even though workspaces on it still exist (default: false)",
+				},
+			},
+			"required": []string{"name"},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var removeParams struct {
+			Name  string `json:"name"`
+			Force bool   `json:"force,omitempty"`
+		}
+		if err := json.Unmarshal(params, &removeParams); err != nil {
+			return nil, mcp.NewInvalidParamsError("Invalid remove SSH host parameters")
+		}
+		host, ok := s.state(ctx).SSHHost(removeParams.Name)
+		if !ok {
+			return nil, mcp.NewInvalidParamsError(fmt.Sprintf("Host %s not found", removeParams.Name))
+		}
+
+		// Workspaces deleted outside the server are still listed
+		var remaining []string
+		for _, name := range host.Workspaces {
+			if workspace, err := s.findWorkspace(ctx, name); err != nil || workspace != nil {
+				remaining = append(remaining, name)
+			}
+		}
+		if len(remaining) > 0 && !removeParams.Force {
+			return nil, mcp.NewInvalidParamsError(fmt.Sprintf("Host %s still has workspaces %s (delete them first or set force)", host.Name, strings.Join(remaining, ", ")))
+		}
+
+		s.state(ctx).DeleteSSHHost(host.Name)
+		result := map[string]interface{}{
+			"name":    host.Name,
+			"message": fmt.Sprintf("Host %s removed", host.Name),
+		}
+		if len(remaining) > 0 {
+			result["workspaces"] = remaining
+		}
+		return result, nil
+	})
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/protobomb/mcp-server-framework/pkg/transport"
+)
+
+// sshStub answers every ssh invocation with the same output
+type sshStub struct {
+	stdout string
+	calls  [][]string
+}
+
+func (r *sshStub) Run(ctx context.Context, args []string) ([]byte, []byte, error) {
+	r.calls = append(r.calls, args)
+	return []byte(r.stdout), nil, nil
+}
+
+func TestParseHostCheck(t *testing.T) {
+	tests := []struct {
+		output  string
+		ready   bool
+		problem string
+	}{
+		{"motd\n" + hostMarker + "\nuid=1000\nos=Linux\narch=x86_64\nsudo=no\ndocker=yes\ndockerVersion=27.1.1\n", true, ""},
+		{hostMarker + "\nuid=0\nos=Linux\nsudo=no\ndocker=no\n", true, "devpod installs it with sudo"},
+		{hostMarker + "\nuid=1000\nos=Linux\nsudo=no\ndocker=yes\ndockerVersion=\n", false, "cannot sudo"},
+		{hostMarker + "\nuid=501\nos=Darwin\nsudo=yes\ndocker=yes\ndockerVersion=27.1.1\n", false, "needs Linux"},
+	}
+	for _, tt := range tests {
+		check, ok := parseHostCheck(tt.output)
+		if !ok || !check.Reachable || check.Ready != tt.ready || !strings.Contains(strings.Join(check.Problems, "; "), tt.problem) {
+			t.Errorf("parseHostCheck(%q) = %+v", tt.output, check)
+		}
+	}
+	if _, ok := parseHostCheck("Permission denied (publickey)."); ok {
+		t.Error("Expected output without the marker to be rejected")
+	}
+}
+
+func TestSSHHostValidate(t *testing.T) {
+	for _, host := range []sshHost{
+		{Name: "-box", Address: "10.0.0.5"},
+		{Name: "box", Address: "dev@10.0.0.5"},
+		{Name: "box", Address: "-oProxyCommand=sh"},
+		{Name: "box", Address: "10.0.0.5", User: "dev user"},
+		{Name: "box", Address: "10.0.0.5", Port: 70000},
+		{Name: "box", Address: "10.0.0.5", IdentityFile: filepath.Join(t.TempDir(), "missing")},
+	} {
+		if err := host.validate(); err == nil {
+			t.Errorf("Expected %+v to be rejected", host)
+		}
+	}
+
+	host := sshHost{Name: "box", Address: "10.0.0.5", User: "dev", Port: 2222, DockerPath: "/usr/bin/docker"}
+	if err := host.validate(); err != nil {
+		t.Fatalf("Expected %+v to be valid, got %v", host, err)
+	}
+	if options := fmt.Sprint(host.providerOptions()); options != "map[DOCKER_PATH:/usr/bin/docker HOST:dev@10.0.0.5 PORT:2222]" {
+		t.Errorf("Unexpected provider options %s", options)
+	}
+}
+
+func TestSSHHostWorkspaces(t *testing.T) {
+	runner := &fakeRunner{outputs: map[string]string{
+		"list --output json":                 `[]`,
+		"provider list --output json":        `{"docker":{}}`,
+		"provider options ssh --output json": `{"HOST":{"required":true},"PORT":{},"EXTRA_FLAGS":{},"DOCKER_PATH":{}}`,
+	}}
+	ssh := &sshStub{stdout: hostMarker + "\nuid=1000\nos=Linux\nsudo=yes\ndocker=yes\ndockerVersion=27.1.1\n"}
+	s := New(transport.NewSTDIOTransport(), Options{
+		Runner:    runner,
+		SSHRunner: ssh,
+		StatePath: filepath.Join(t.TempDir(), "state.json"),
+	})
+	ctx := context.Background()
+
+	result, err := s.MCP().GetHandler("devpod_addSSHHost")(ctx, json.RawMessage(`{"name":"build-box","address":"10.0.0.5","user":"dev","port":2222}`))
+	if err != nil {
+		t.Fatalf("devpod_addSSHHost failed: %v", err)
+	}
+	if host := result.(map[string]interface{})["host"].(sshHost); host.LastCheck == nil || !host.LastCheck.Ready || host.LastCheck.DockerVersion != "27.1.1" {
+		t.Errorf("Expected the host to be tested and ready, got %+v", host)
+	}
+	if args := fmt.Sprint(ssh.calls[0][:9]); args != "[-o BatchMode=yes -o StrictHostKeyChecking=accept-new -o ConnectTimeout=10 -p 2222 dev@10.0.0.5]" {
+		t.Errorf("Unexpected ssh arguments %s", args)
+	}
+
+	// The host supplies the ssh provider's options, which are added first
+	if _, err := s.MCP().GetHandler("devpod_createWorkspace")(ctx, json.RawMessage(`{"name":"api","source":"github.com/acme/api","host":"build-box"}`)); err != nil {
+		t.Fatalf("devpod_createWorkspace failed: %v", err)
+	}
+	calls := fmt.Sprint(runner.calls)
+	if !strings.Contains(calls, "[provider add ssh --use=false]") || !strings.Contains(calls, "[up github.com/acme/api --id api --provider-option HOST=dev@10.0.0.5 --provider-option PORT=2222 --provider ssh]") {
+		t.Errorf("Expected the workspace to be created on the host, got calls %v", calls)
+	}
+	if host, _ := s.store.SSHHost("build-box"); fmt.Sprint(host.Workspaces) != "[api]" {
+		t.Errorf("Expected the workspace to be recorded on the host, got %v", host.Workspaces)
+	}
+	if _, err := s.MCP().GetHandler("devpod_createWorkspace")(ctx, json.RawMessage(`{"name":"web","source":"github.com/acme/web","host":"build-box","provider":"docker"}`)); err == nil {
+		t.Error("Expected a host with another provider to be rejected")
+	}
+
+	// Removal is refused while the workspace exists
+	runner.outputs["list --output json"] = `[{"id":"api","provider":{"name":"ssh"}}]`
+	remove := s.MCP().GetHandler("devpod_removeSSHHost")
+	if _, err := remove(ctx, json.RawMessage(`{"name":"build-box"}`)); err == nil || !strings.Contains(err.Error(), "still has workspaces api") {
+		t.Errorf("Expected removal to be refused, got %v", err)
+	}
+	runner.outputs["list --output json"] = `[]`
+	s.recordLifecycle(ctx, "api", "deleted", "Workspace deleted")
+	if _, err := remove(ctx, json.RawMessage(`{"name":"build-box"}`)); err != nil {
+		t.Errorf("devpod_removeSSHHost failed: %v", err)
+	}
+	if hosts := s.store.SSHHosts(); len(hosts) != 0 {
+		t.Errorf("Expected no hosts, got %+v", hosts)
+	}
+}
@@ -0,0 +1,377 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+// browserIDEs are the IDEs devpod serves to a browser on a local port. devpod
+// up keeps running for them to forward the port.
+var browserIDEs = map[string]bool{
+	"openvscode":      true,
+	"jupyternotebook": true,
+	"rstudio":         true,
+}
+
+// ideLinks are the URLs that open a desktop IDE connected to a workspace
+// through the SSH host devpod configures for it, by IDE
+var ideLinks = map[string]string{
+	"vscode":          "vscode://vscode-remote/ssh-remote+%s.devpod/workspaces/%s",
+	"vscode-insiders": "vscode-insiders://vscode-remote/ssh-remote+%s.devpod/workspaces/%s",
+	"cursor":          "cursor://vscode-remote/ssh-remote+%s.devpod/workspaces/%s",
+	"codium":          "vscodium://vscode-remote/ssh-remote+%s.devpod/workspaces/%s",
+}
+
+// ideURLPattern matches the local URL devpod prints once a browser IDE is
+// reachable, e.g. http://localhost:10800/?folder=/workspaces/api
+var ideURLPattern = regexp.MustCompile(`https?://(?:localhost|127\.0\.0\.1):\d+[^\s"'<>\x1b]*`)
+
+// maxIDEOutput bounds the devpod output kept while waiting for the URL
+const maxIDEOutput = 64 << 10
+
+// ideTunnel is a running devpod up that forwards a browser IDE of a
+// workspace to a local port
+type ideTunnel struct {
+	Workspace string    `json:"workspace"`
+	IDE       string    `json:"ide"`
+	URL       string    `json:"url"`
+	User      string    `json:"user,omitempty"`
+	Started   time.Time `json:"started"`
+	// token grants access to the IDE through the HTTP transport's proxy
+	token  string
+	cancel context.CancelFunc
+	// done is closed when devpod exits
+	done chan struct{}
+}
+
+// alive reports whether devpod still forwards the IDE
+func (t *ideTunnel) alive() bool {
+	select {
+	case <-t.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// ideTunnels tracks the browser IDE tunnels by user and workspace
+type ideTunnels struct {
+	mu      sync.Mutex
+	tunnels map[workspaceKey]*ideTunnel
+}
+
+func newIDETunnels() *ideTunnels {
+	return &ideTunnels{tunnels: make(map[workspaceKey]*ideTunnel)}
+}
+
+// get returns the live tunnel of a user's workspace
+func (t *ideTunnels) get(user, workspace string) (*ideTunnel, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tunnel, ok := t.tunnels[workspaceKey{user, workspace}]
+	if !ok || !tunnel.alive() {
+		return nil, false
+	}
+	return tunnel, true
+}
+
+// withToken returns the live tunnel of a workspace that token grants
+// access to, whichever user it belongs to
+func (t *ideTunnels) withToken(workspace, token string) (*ideTunnel, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for key, tunnel := range t.tunnels {
+		if key.workspace == workspace && tunnel.alive() && subtle.ConstantTimeCompare([]byte(tunnel.token), []byte(token)) == 1 {
+			return tunnel, true
+		}
+	}
+	return nil, false
+}
+
+// set replaces the tunnel of a workspace, closing the previous one
+func (t *ideTunnels) set(tunnel *ideTunnel) {
+	key := workspaceKey{tunnel.User, tunnel.Workspace}
+	t.mu.Lock()
+	previous := t.tunnels[key]
+	t.tunnels[key] = tunnel
+	t.mu.Unlock()
+	if previous != nil && previous != tunnel {
+		previous.cancel()
+	}
+}
+
+// close stops the tunnel of a user's workspace, reporting whether one was
+// running
+func (t *ideTunnels) close(user, workspace string) bool {
+	key := workspaceKey{user, workspace}
+	t.mu.Lock()
+	tunnel, ok := t.tunnels[key]
+	delete(t.tunnels, key)
+	t.mu.Unlock()
+	if !ok {
+		return false
+	}
+	tunnel.cancel()
+	return tunnel.alive()
+}
+
+// closeUser stops every tunnel of a user and returns the affected workspace
+// names
+func (t *ideTunnels) closeUser(user string) []string {
+	return t.closeMatching(func(key workspaceKey) bool { return key.user == user })
+}
+
+// closeAll stops every tunnel of every user
+func (t *ideTunnels) closeAll() {
+	t.closeMatching(func(workspaceKey) bool { return true })
+}
+
+func (t *ideTunnels) closeMatching(match func(workspaceKey) bool) []string {
+	t.mu.Lock()
+	var tunnels []*ideTunnel
+	for key, tunnel := range t.tunnels {
+		if match(key) {
+			tunnels = append(tunnels, tunnel)
+			delete(t.tunnels, key)
+		}
+	}
+	t.mu.Unlock()
+	closed := []string{}
+	for _, tunnel := range tunnels {
+		if tunnel.alive() {
+			closed = append(closed, tunnel.Workspace)
+		}
+		tunnel.cancel()
+	}
+	sort.Strings(closed)
+	return closed
+}
+
+// urlWatcher receives devpod output and reports the first IDE URL in it
+type urlWatcher struct {
+	mu     sync.Mutex
+	output []byte
+	found  chan string
+	sent   bool
+}
+
+func newURLWatcher() *urlWatcher {
+	return &urlWatcher{found: make(chan string, 1)}
+}
+
+// Write implements io.Writer
+func (w *urlWatcher) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.output = append(w.output, p...)
+	if len(w.output) > maxIDEOutput {
+		w.output = w.output[len(w.output)-maxIDEOutput:]
+	}
+	if !w.sent {
+		if url := ideURLPattern.FindString(sanitizeOutput(string(w.output))); url != "" {
+			w.sent = true
+			w.found <- url
+		}
+	}
+	return len(p), nil
+}
+
+// contents returns the output received so far
+func (w *urlWatcher) contents() []byte {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]byte(nil), w.output...)
+}
+
+// openBrowserIDE starts a browser IDE in a workspace and returns the tunnel
+// forwarding it once devpod prints its URL, or the running tunnel when the
+// same IDE is already forwarded. devpod keeps running outside the
+// concurrency limits, since it lasts as long as the IDE is used, until the
+// workspace stops, devpod_closeConnections closes it or the server stops.
+func (s *Server) openBrowserIDE(ctx context.Context, name, ide string, open bool) (*ideTunnel, bool, error) {
+	if tunnel, ok := s.ides.get(UserName(ctx), name); ok && tunnel.IDE == ide {
+		return tunnel, true, nil
+	}
+
+	// The tunnel outlives the call but keeps its session and user
+	tunnelCtx, cancel := context.WithCancel(WithUser(WithSessionID(context.Background(), SessionID(ctx)), UserName(ctx)))
+	runCtx, args, cleanup, err := s.startArgs(tunnelCtx, name, ide, "--open-ide="+strconv.FormatBool(open))
+	if err != nil {
+		cancel()
+		return nil, false, err
+	}
+	runCtx, args, err = s.commandContext(runCtx, args)
+	if err != nil {
+		cleanup()
+		cancel()
+		return nil, false, err
+	}
+
+	token := make([]byte, 24)
+	if _, err := io.ReadFull(rand.Reader, token); err != nil {
+		cleanup()
+		cancel()
+		return nil, false, err
+	}
+	watcher := newURLWatcher()
+	tunnel := &ideTunnel{Workspace: name, IDE: ide, User: UserName(ctx), Started: time.Now().UTC(), token: hex.EncodeToString(token), cancel: cancel, done: make(chan struct{})}
+	var runErr error
+	go func() {
+		defer close(tunnel.done)
+		defer cancel()
+		_, _, runErr = s.runner.Run(WithOutputWriter(runCtx, watcher), args)
+	}()
+
+	select {
+	case tunnel.URL = <-watcher.found:
+		cleanup()
+	case <-tunnel.done:
+		cleanup()
+		output := []byte(sanitizeOutput(string(watcher.contents())))
+		if runErr == nil {
+			runErr = fmt.Errorf("devpod exited without printing the %s URL", ide)
+		}
+		return nil, false, withPhases(newDevPodError("failed to start IDE", runErr, output), output)
+	case <-ctx.Done():
+		cancel()
+		<-tunnel.done
+		cleanup()
+		return nil, false, ctx.Err()
+	}
+
+	s.ides.set(tunnel)
+	s.recordLifecycle(ctx, name, "started", fmt.Sprintf("Workspace started with %s", ide))
+	s.touchWorkspace(ctx, name)
+	return tunnel, false, nil
+}
+
+// ideLink returns the URL opening a desktop IDE connected to a workspace, or
+// "" when the IDE has none
+func ideLink(ide, name string) string {
+	link, ok := ideLinks[ide]
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf(link, name, name)
+}
+
+// registerIDETools registers the tool that opens an IDE on a workspace
+func (s *Server) registerIDETools() {
+	// Start a workspace with an IDE and return how to reach it
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_openIDE",
+		Description: "Start a workspace with an IDE and return its connection URL: browser IDEs such as openvscode are forwarded to a local URL the user can open, desktop IDEs such as VS Code get a link that connects them over SSH",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "The name of the workspace",
+				},
+				"ide": map[string]interface{}{
+					"type":        "string",
+					"description": "The IDE, e.g. openvscode, vscode or goland (optional, defaults to the workspace's IDE, then the session default)",
+				},
+				"open": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Also open the IDE on the machine the server runs on; requires the server to run with -open-browser (default: false)",
+				},
+			},
+			"required": []string{"name"},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var ideParams struct {
+			Name string `json:"name"`
+			IDE  string `json:"ide,omitempty"`
+			Open bool   `json:"open,omitempty"`
+		}
+
+		if err := json.Unmarshal(params, &ideParams); err != nil {
+			return nil, mcp.NewInvalidParamsError("Invalid open IDE parameters")
+		}
+		if ideParams.Name == "" {
+			return nil, mcp.NewInvalidParamsError("Workspace name is required")
+		}
+		if ideParams.Open && !s.opts.OpenBrowser {
+			return nil, mcp.NewInvalidParamsError("open requires the server to run with -open-browser")
+		}
+
+		ide := ideParams.IDE
+		if ide == "" {
+			workspace, err := s.findWorkspace(ctx, ideParams.Name)
+			if err != nil {
+				return nil, newDevPodError("failed to look up workspace", err, nil)
+			}
+			if workspace == nil {
+				return nil, mcp.NewInvalidParamsError(fmt.Sprintf("Workspace %s not found", ideParams.Name))
+			}
+			ide = workspace.IDE.Name
+		}
+		if ide == "" {
+			ide = s.session(ctx).IDE
+		}
+		if ide == "" || ide == "none" {
+			return nil, mcp.NewInvalidParamsError("ide is required: the workspace has no IDE configured")
+		}
+
+		start := time.Now()
+		if browserIDEs[ide] {
+			tunnel, reused, err := s.openBrowserIDE(ctx, ideParams.Name, ide, ideParams.Open)
+			if err != nil {
+				return nil, err
+			}
+			message := fmt.Sprintf("%s is running at %s", ide, tunnel.URL)
+			if reused {
+				message = fmt.Sprintf("%s is already running at %s", ide, tunnel.URL)
+			}
+			result := map[string]interface{}{
+				"name":       ideParams.Name,
+				"ide":        ide,
+				"browser":    true,
+				"url":        tunnel.URL,
+				"reused":     reused,
+				"durationMs": durationMs(start),
+				"message":    message,
+			}
+			if proxyURL := s.ideProxyURL(tunnel); proxyURL != "" {
+				result["proxyUrl"] = proxyURL
+				result["message"] = message + "; remote users open proxyUrl"
+			}
+			return result, nil
+		}
+
+		output, warnings, err := s.startWorkspace(ctx, ideParams.Name, ide, "--open-ide="+strconv.FormatBool(ideParams.Open))
+		if err != nil {
+			return nil, err
+		}
+		result := map[string]interface{}{
+			"name":       ideParams.Name,
+			"ide":        ide,
+			"browser":    false,
+			"output":     string(output),
+			"phases":     parsePhases(string(output), false),
+			"durationMs": durationMs(start),
+			"message":    fmt.Sprintf("Workspace started with %s", ide),
+		}
+		if link := ideLink(ide, ideParams.Name); link != "" {
+			result["url"] = link
+			result["message"] = fmt.Sprintf("Workspace started with %s; open %s to connect", ide, link)
+		}
+		if len(warnings) > 0 {
+			result["warnings"] = warnings
+		}
+		return result, nil
+	})
+}
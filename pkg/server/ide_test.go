@@ -0,0 +1,92 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// tunnelRunner prints a browser IDE URL for devpod up and keeps running
+// until cancelled, like devpod forwarding the IDE port
+type tunnelRunner struct {
+	fakeRunner
+	ups int
+}
+
+func (r *tunnelRunner) Run(ctx context.Context, args []string) ([]byte, []byte, error) {
+	if args[0] != "up" {
+		return r.fakeRunner.Run(ctx, args)
+	}
+	r.mu.Lock()
+	r.ups++
+	r.calls = append(r.calls, args)
+	r.mu.Unlock()
+	OutputWriter(ctx).Write([]byte("info Starting openvscode in browser mode at \x1b[1mhttp://localhost:10800/?folder=/workspaces/api\x1b[0m\n"))
+	<-ctx.Done()
+	return nil, nil, ctx.Err()
+}
+
+func TestOpenBrowserIDE(t *testing.T) {
+	runner := &tunnelRunner{fakeRunner: fakeRunner{outputs: map[string]string{
+		"list --output json": `[{"id":"api","ide":{"name":"openvscode"},"provider":{"name":"docker"}}]`,
+	}}}
+	s := newTestServer(t, runner)
+	call := s.MCP().GetHandler("tools/call")
+
+	result, err := s.MCP().GetHandler("devpod_openIDE")(context.Background(), json.RawMessage(`{"name":"api"}`))
+	if err != nil {
+		t.Fatalf("openIDE failed: %v", err)
+	}
+	opened := result.(map[string]interface{})
+	if opened["url"] != "http://localhost:10800/?folder=/workspaces/api" || opened["ide"] != "openvscode" || opened["browser"] != true {
+		t.Fatalf("Unexpected result %v", opened)
+	}
+	if got := strings.Join(runner.calls[len(runner.calls)-1], " "); got != "up api --ide openvscode --open-ide=false" {
+		t.Errorf("Expected devpod up with the workspace's IDE, got %s", got)
+	}
+
+	// the running tunnel is reused
+	result, err = s.MCP().GetHandler("devpod_openIDE")(context.Background(), json.RawMessage(`{"name":"api","ide":"openvscode"}`))
+	if err != nil || result.(map[string]interface{})["reused"] != true || runner.ups != 1 {
+		t.Fatalf("Expected the tunnel to be reused, got %v, %v after %d up(s)", result, err, runner.ups)
+	}
+
+	tunnel, ok := s.ides.get("", "api")
+	if !ok {
+		t.Fatal("Expected a running tunnel")
+	}
+	if _, err := call(context.Background(), json.RawMessage(`{"name":"devpod_closeConnections","arguments":{"name":"api"}}`)); err != nil {
+		t.Fatalf("closeConnections failed: %v", err)
+	}
+	select {
+	case <-tunnel.done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected closeConnections to stop devpod")
+	}
+}
+
+func TestOpenDesktopIDE(t *testing.T) {
+	runner := &fakeRunner{outputs: map[string]string{"list --output json": "[]"}}
+	s := newTestServer(t, runner)
+	open := s.MCP().GetHandler("devpod_openIDE")
+
+	result, err := open(context.Background(), json.RawMessage(`{"name":"api","ide":"vscode"}`))
+	if err != nil {
+		t.Fatalf("openIDE failed: %v", err)
+	}
+	if url := result.(map[string]interface{})["url"]; url != "vscode://vscode-remote/ssh-remote+api.devpod/workspaces/api" {
+		t.Errorf("Expected a VS Code remote link, got %v", url)
+	}
+	if got := strings.Join(runner.calls[len(runner.calls)-1], " "); !strings.HasPrefix(got, "up api --ide vscode --open-ide=false") {
+		t.Errorf("Expected devpod up without opening the IDE, got %s", got)
+	}
+
+	if _, err := open(context.Background(), json.RawMessage(`{"name":"api","ide":"vscode","open":true}`)); err == nil {
+		t.Error("Expected open to require -open-browser")
+	}
+	if _, err := open(context.Background(), json.RawMessage(`{"name":"missing"}`)); err == nil {
+		t.Error("Expected a missing workspace to be rejected")
+	}
+}
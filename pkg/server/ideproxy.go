@@ -0,0 +1,156 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+
+	"github.com/Protobomb/mcp-server-devpod/pkg/httptransport"
+)
+
+// ideTokenParam is the query parameter carrying a tunnel's access token in
+// the proxy URL devpod_openIDE returns
+const ideTokenParam = "token"
+
+// ideCookie is the name of the cookie holding the access token of a
+// workspace's IDE once the proxy URL has been opened
+func ideCookie(workspace string) string {
+	return "devpod_ide_" + workspace
+}
+
+// ideProxyURL returns the URL a browser IDE is reached at through the proxy,
+// or "" when the proxy is disabled. It carries the access token and the query
+// of the IDE's own URL, such as openvscode's folder.
+func (s *Server) ideProxyURL(tunnel *ideTunnel) string {
+	if s.opts.IDEProxyURL == "" {
+		return ""
+	}
+	query := url.Values{}
+	if local, err := url.Parse(tunnel.URL); err == nil {
+		query = local.Query()
+	}
+	query.Set(ideTokenParam, tunnel.token)
+	return strings.TrimRight(s.opts.IDEProxyURL, "/") + "/" + url.PathEscape(tunnel.Workspace) + "/ide/?" + query.Encode()
+}
+
+// IDEProxyHandler returns an HTTP handler proxying /workspaces/<name>/ide/ to
+// the browser IDE devpod_openIDE forwarded for the workspace, including its
+// WebSockets. Serve it with the HTTP transport's HandleBrowser at
+// /workspaces/, with the base path stripped, and set Options.IDEProxyURL to
+// where it is reached.
+//
+// A request is let through when the transport authenticated the user the
+// IDE was opened by, or when it carries the IDE's access token: the proxy
+// URL has it as a query parameter, which is swapped for a cookie on first
+// use so the IDE's own requests carry it.
+func (s *Server) IDEProxyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rest, _ := strings.CutPrefix(r.URL.Path, "/workspaces/")
+		name, rest, _ := strings.Cut(rest, "/")
+		if name == "" || (rest != "ide" && !strings.HasPrefix(rest, "ide/")) {
+			http.NotFound(w, r)
+			return
+		}
+		if rest == "ide" {
+			// The IDE's relative URLs need the trailing slash
+			target := "ide/"
+			if r.URL.RawQuery != "" {
+				target += "?" + r.URL.RawQuery
+			}
+			redirect(w, target)
+			return
+		}
+
+		user := httptransport.RequestUser(r)
+		var tunnel *ideTunnel
+		var ok bool
+		if user != "" {
+			tunnel, ok = s.ides.get(user, name)
+		}
+		if !ok {
+			token := r.URL.Query().Get(ideTokenParam)
+			if token == "" {
+				if cookie, err := r.Cookie(ideCookie(name)); err == nil {
+					token = cookie.Value
+				}
+			}
+			if token != "" {
+				tunnel, ok = s.ides.withToken(name, token)
+			}
+			// Authenticated users only reach their own IDEs
+			if ok && user != "" && tunnel.User != user {
+				ok = false
+			}
+		}
+		if !ok {
+			if user != "" {
+				http.Error(w, "No IDE is open for workspace "+name+"; open one with devpod_openIDE", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		query := r.URL.Query()
+		if query.Has(ideTokenParam) {
+			// Keep the token out of the address bar, history and the IDE
+			http.SetCookie(w, &http.Cookie{
+				Name:     ideCookie(name),
+				Value:    query.Get(ideTokenParam),
+				Path:     "/",
+				HttpOnly: true,
+				Secure:   r.TLS != nil || forwardedProto(r) == "https",
+				SameSite: http.SameSiteLaxMode,
+			})
+			query.Del(ideTokenParam)
+			target := "./"
+			if i := strings.LastIndex(r.URL.Path, "/"); i < len(r.URL.Path)-1 {
+				target = r.URL.Path[i+1:]
+			}
+			if len(query) > 0 {
+				target += "?" + query.Encode()
+			}
+			redirect(w, target)
+			return
+		}
+
+		local, err := url.Parse(tunnel.URL)
+		if err != nil {
+			http.Error(w, "Invalid IDE URL", http.StatusBadGateway)
+			return
+		}
+		path := strings.TrimPrefix(rest, "ide")
+		proxy := &httputil.ReverseProxy{Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(&url.URL{Scheme: local.Scheme, Host: local.Host})
+			pr.Out.URL.Path, pr.Out.URL.RawPath = path, ""
+			// IDEs check WebSocket origins against the host the browser sees
+			pr.Out.Host = pr.In.Host
+			pr.SetXForwarded()
+			// The server's credentials are not the IDE's business
+			pr.Out.Header.Del("Authorization")
+			pr.Out.Header.Del("Cookie")
+			for _, cookie := range pr.In.Cookies() {
+				if cookie.Name != ideCookie(name) {
+					pr.Out.AddCookie(cookie)
+				}
+			}
+		}}
+		proxy.ServeHTTP(w, r)
+	})
+}
+
+// redirect sends the browser to a URL relative to the request's. Unlike
+// http.Redirect it keeps the URL relative, so it resolves below the prefix
+// a reverse proxy in front of the server strips.
+func redirect(w http.ResponseWriter, target string) {
+	w.Header().Set("Location", target)
+	w.WriteHeader(http.StatusFound)
+}
+
+// forwardedProto returns the scheme a proxy in front of the server reports
+// the browser used
+func forwardedProto(r *http.Request) string {
+	proto, _, _ := strings.Cut(r.Header.Get("X-Forwarded-Proto"), ",")
+	return strings.TrimSpace(proto)
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Protobomb/mcp-server-devpod/pkg/httptransport"
+	"github.com/protobomb/mcp-server-framework/pkg/transport"
+)
+
+func TestIDEProxy(t *testing.T) {
+	ide := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s|%s|%s", r.URL.Path, r.Header.Get("Cookie"), r.Header.Get("Authorization"))
+	}))
+	defer ide.Close()
+
+	s := New(transport.NewSTDIOTransport(), Options{
+		Runner:      &fakeRunner{},
+		StatePath:   filepath.Join(t.TempDir(), "state.json"),
+		IDEProxyURL: "https://mcp.example.com/workspaces",
+	})
+	tunnel := &ideTunnel{Workspace: "api", IDE: "openvscode", URL: ide.URL + "/?folder=/workspaces/api", User: "alice", token: "secret", cancel: func() {}, done: make(chan struct{})}
+	s.ides.set(tunnel)
+	if got := s.ideProxyURL(tunnel); got != "https://mcp.example.com/workspaces/api/ide/?folder=%2Fworkspaces%2Fapi&token=secret" {
+		t.Errorf("Unexpected proxy URL %s", got)
+	}
+
+	streams := httptransport.NewStreamsWithOptions(":0", httptransport.Options{Authenticate: func(r *http.Request) (string, error) {
+		if user := r.Header.Get("X-User"); user != "" {
+			return user, nil
+		}
+		return "", errors.New("missing user")
+	}})
+	streams.HandleBrowser("/workspaces/", s.IDEProxyHandler())
+	handler := streams.Handler()
+	get := func(path string, headers ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		for i := 0; i < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := get("/workspaces/api/ide/"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected requests without credentials to be refused, got %d", rec.Code)
+	}
+	if rec := get("/workspaces/api/ide/?token=wrong"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected a wrong token to be refused, got %d", rec.Code)
+	}
+
+	rec := get("/workspaces/api/ide/?folder=/workspaces/api&token=secret")
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != "./?folder=%2Fworkspaces%2Fapi" {
+		t.Fatalf("Expected a redirect dropping the token, got %d to %s", rec.Code, rec.Header().Get("Location"))
+	}
+	if cookie := rec.Header().Get("Set-Cookie"); !strings.HasPrefix(cookie, "devpod_ide_api=secret;") || !strings.Contains(cookie, "HttpOnly") {
+		t.Errorf("Expected the token in a cookie, got %s", cookie)
+	}
+
+	rec = get("/workspaces/api/ide/static/main.js", "Cookie", "devpod_ide_api=secret; theme=dark", "Authorization", "Bearer server-token")
+	if rec.Code != http.StatusOK || rec.Body.String() != "/static/main.js|theme=dark|" {
+		t.Errorf("Expected the request proxied without the server's credentials, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// the user who opened the IDE needs no token, other users get none
+	if rec := get("/workspaces/api/ide/", "X-User", "alice"); rec.Code != http.StatusOK {
+		t.Errorf("Expected the IDE's user to be let through, got %d", rec.Code)
+	}
+	if rec := get("/workspaces/api/ide/", "X-User", "bob", "Cookie", "devpod_ide_api=secret"); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected another user to be refused, got %d", rec.Code)
+	}
+
+	if rec := get("/workspaces/api/ide"); rec.Code != http.StatusFound || rec.Header().Get("Location") != "ide/" {
+		t.Errorf("Expected a redirect to the trailing slash, got %d to %s", rec.Code, rec.Header().Get("Location"))
+	}
+}
@@ -0,0 +1,243 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+// maxChecksumFileSize bounds the checksum files read from a release
+const maxChecksumFileSize = 1 << 20
+
+// installResult describes an installed devpod CLI
+type installResult struct {
+	Version string `json:"version"`
+	Path    string `json:"path"`
+	Asset   string `json:"asset"`
+	SHA256  string `json:"sha256"`
+}
+
+// defaultInstallDir returns the managed directory devpod is installed into
+func defaultInstallDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "mcp-server-devpod", "bin")
+}
+
+// installPath returns the path of the managed devpod binary in dir
+func installPath(dir string) string {
+	name := "devpod"
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	return filepath.Join(dir, name)
+}
+
+// useInstallDir puts dir first on PATH so devpod commands, and the devpod
+// processes they spawn, find the managed binary
+func useInstallDir(dir string) error {
+	path := os.Getenv("PATH")
+	for _, entry := range filepath.SplitList(path) {
+		if entry == dir {
+			return nil
+		}
+	}
+	if path == "" {
+		return os.Setenv("PATH", dir)
+	}
+	return os.Setenv("PATH", dir+string(os.PathListSeparator)+path)
+}
+
+// fetchBytes downloads a small file such as a checksum list
+func fetchBytes(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download failed: %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxChecksumFileSize))
+}
+
+// parseChecksum finds the SHA-256 of asset in a checksum file. Files list
+// "<hash>  <name>" lines; a file with a lone hash belongs to a single asset.
+func parseChecksum(data []byte, asset string) (string, bool) {
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 1 && len(fields[0]) == sha256.Size*2:
+			return strings.ToLower(fields[0]), true
+		case len(fields) >= 2 && strings.TrimPrefix(fields[1], "*") == asset:
+			return strings.ToLower(fields[0]), true
+		}
+	}
+	return "", false
+}
+
+// releaseChecksum returns the published SHA-256 of asset, read from
+// "<asset>.sha256" or "checksums.txt" in the release
+func releaseChecksum(ctx context.Context, release *devpodRelease, asset string) (string, error) {
+	for _, name := range []string{asset + ".sha256", "checksums.txt"} {
+		for _, candidate := range release.Assets {
+			if candidate.Name != name {
+				continue
+			}
+			data, err := fetchBytes(ctx, candidate.BrowserDownloadURL)
+			if err != nil {
+				return "", fmt.Errorf("failed to download %s: %w", name, err)
+			}
+			if sum, ok := parseChecksum(data, asset); ok {
+				return sum, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("release %s publishes no checksum for %s", release.TagName, asset)
+}
+
+// installCLI downloads the devpod release for this platform into the managed
+// install directory, verifies it against the release checksum and puts the
+// directory on PATH. An empty tag installs the latest release.
+func (s *Server) installCLI(ctx context.Context, tag string) (*installResult, error) {
+	s.installMu.Lock()
+	defer s.installMu.Unlock()
+
+	dir := s.opts.InstallDir
+	if dir == "" {
+		return nil, fmt.Errorf("no install directory available")
+	}
+	release, err := s.fetchRelease(ctx, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	asset := releaseAssetName()
+	assetURL := ""
+	for _, candidate := range release.Assets {
+		if candidate.Name == asset {
+			assetURL = candidate.BrowserDownloadURL
+		}
+	}
+	if assetURL == "" {
+		return nil, fmt.Errorf("release %s has no %s asset", release.TagName, asset)
+	}
+	want, err := releaseChecksum(ctx, release, asset)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create install directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, ".devpod-download-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create download file: %w", err)
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	if err := downloadFile(ctx, assetURL, tmp.Name(), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", asset, err)
+	}
+	data, err := os.ReadFile(tmp.Name())
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	if got := hex.EncodeToString(sum[:]); got != want {
+		return nil, fmt.Errorf("checksum mismatch for %s: expected %s, got %s", asset, want, got)
+	}
+
+	path := installPath(dir)
+	if err := os.Chmod(tmp.Name(), 0o755); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return nil, fmt.Errorf("failed to install devpod: %w", err)
+	}
+	if err := useInstallDir(dir); err != nil {
+		return nil, fmt.Errorf("failed to add %s to PATH: %w", dir, err)
+	}
+	s.resetDevPodVersion()
+
+	infof("Installed devpod %s to %s", release.TagName, path)
+	return &installResult{Version: release.TagName, Path: path, Asset: asset, SHA256: want}, nil
+}
+
+// registerInstallTools registers the tool that installs the devpod CLI
+func (s *Server) registerInstallTools() {
+	// Install the devpod CLI into the managed directory
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_installCLI",
+		Description: "Download and install the DevPod CLI for this platform into the server's managed directory, verifying its checksum",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"version": map[string]interface{}{
+					"type":        "string",
+					"description": "Release tag to install, e.g. v0.6.0 (optional, defaults to the latest release)",
+				},
+				"force": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Install even when a working DevPod CLI is already available (default: false)",
+				},
+			},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var installParams struct {
+			Version string `json:"version,omitempty"`
+			Force   bool   `json:"force,omitempty"`
+		}
+
+		if len(params) > 0 {
+			if err := json.Unmarshal(params, &installParams); err != nil {
+				return nil, mcp.NewInvalidParamsError("Invalid install parameters")
+			}
+		}
+
+		if !installParams.Force {
+			s.resetDevPodVersion()
+			if version, err := s.devpodVersion(ctx); err == nil {
+				return map[string]interface{}{
+					"installed":     false,
+					"devpodVersion": version,
+					"message":       fmt.Sprintf("devpod %s is already installed; set force to install anyway", version),
+				}, nil
+			}
+		}
+
+		result, err := s.installCLI(ctx, installParams.Version)
+		if err != nil {
+			s.reportEvent("error", "install", fmt.Errorf("devpod installation failed: %w", err))
+			return nil, fmt.Errorf("devpod installation failed: %w", err)
+		}
+		version, err := s.devpodVersion(ctx)
+		if err != nil {
+			return nil, newDevPodError("installed devpod does not run", err, nil)
+		}
+
+		return map[string]interface{}{
+			"installed":     true,
+			"install":       result,
+			"devpodVersion": version,
+			"message":       fmt.Sprintf("Installed devpod %s to %s", result.Version, result.Path),
+		}, nil
+	})
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseChecksum(t *testing.T) {
+	sum := strings.Repeat("ab", sha256.Size)
+	list := []byte("0000  devpod-darwin-arm64\n" + sum + " *devpod-linux-amd64\n")
+	if got, ok := parseChecksum(list, "devpod-linux-amd64"); !ok || got != sum {
+		t.Errorf("Expected %s from the checksum list, got %q", sum, got)
+	}
+	if got, ok := parseChecksum([]byte(strings.ToUpper(sum)+"\n"), "devpod-linux-amd64"); !ok || got != sum {
+		t.Errorf("Expected %s from a single checksum file, got %q", sum, got)
+	}
+	if _, ok := parseChecksum(list, "devpod-windows-amd64.exe"); ok {
+		t.Error("Expected no checksum for an unlisted asset")
+	}
+}
+
+func TestInstallCLIVerifiesChecksum(t *testing.T) {
+	t.Setenv("PATH", os.Getenv("PATH"))
+	binary := []byte("#!/bin/sh\necho v0.6.0\n")
+	sum := sha256.Sum256(binary)
+	checksum := hex.EncodeToString(sum[:])
+
+	var release *httptest.Server
+	release = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		asset := releaseAssetName()
+		switch r.URL.Path {
+		case "/releases/latest":
+			fmt.Fprintf(w, `{"tag_name":"v0.6.0","assets":[{"name":%q,"browser_download_url":%q},{"name":%q,"browser_download_url":%q}]}`,
+				asset, release.URL+"/download/"+asset, asset+".sha256", release.URL+"/download/"+asset+".sha256")
+		case "/download/" + asset:
+			w.Write(binary)
+		case "/download/" + asset + ".sha256":
+			fmt.Fprintf(w, "%s  %s\n", checksum, asset)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer release.Close()
+
+	s := newTestServer(t, &fakeRunner{})
+	s.opts.ReleaseURL = release.URL + "/releases"
+	s.opts.InstallDir = filepath.Join(t.TempDir(), "bin")
+
+	result, err := s.installCLI(context.Background(), "")
+	if err != nil {
+		t.Fatalf("installCLI failed: %v", err)
+	}
+	if result.Version != "v0.6.0" || result.SHA256 != checksum {
+		t.Errorf("Unexpected install result %+v", result)
+	}
+	if data, err := os.ReadFile(result.Path); err != nil || string(data) != string(binary) {
+		t.Errorf("Expected the release binary at %s, got %q (%v)", result.Path, data, err)
+	}
+	if !strings.HasPrefix(os.Getenv("PATH"), s.opts.InstallDir) {
+		t.Errorf("Expected %s first on PATH, got %s", s.opts.InstallDir, os.Getenv("PATH"))
+	}
+
+	// A tampered binary is rejected and the installed one kept
+	binary = []byte("tampered")
+	if _, err := s.installCLI(context.Background(), ""); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("Expected a checksum mismatch, got %v", err)
+	}
+	if data, _ := os.ReadFile(result.Path); string(data) == "tampered" {
+		t.Error("Expected the tampered binary not to be installed")
+	}
+}
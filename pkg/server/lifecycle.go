@@ -0,0 +1,941 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+// lifecycleStates are the workspace states reached by each lifecycle event
+var lifecycleStates = map[string]string{
+	"created": "Running",
+	"started": "Running",
+	"stopped": "Stopped",
+	"deleted": "NotFound",
+}
+
+// lifecycleEvent is a devpod/workspaceLifecycle notification
+type lifecycleEvent struct {
+	Name string `json:"name"`
+	// Event is created, started, stopped or deleted
+	Event string `json:"event"`
+	State string `json:"state"`
+	// Source is "server" for changes made through this server and
+	// "external" for ones the workspace watcher observed, e.g. devpod CLI use
+	Source string `json:"source"`
+	// Session is the client session whose call made the change
+	Session string `json:"session,omitempty"`
+	// Message says why the server made the change, e.g. that a schedule
+	// stopped the workspace
+	Message string    `json:"message,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// lifecycleTracker remembers the state each workspace was last announced
+// in, so the watcher does not announce changes made through the server again
+type lifecycleTracker struct {
+	mu     sync.Mutex
+	states map[workspaceKey]string
+}
+
+// swap records the announced state of a workspace of a user and returns the
+// previous one
+func (l *lifecycleTracker) swap(user, name, state string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.states == nil {
+		l.states = make(map[workspaceKey]string)
+	}
+	previous := l.states[workspaceKey{user, name}]
+	l.states[workspaceKey{user, name}] = state
+	return previous
+}
+
+// lifecycleEventFor returns the lifecycle event a watcher-observed state
+// change amounts to, or "" for changes such as Busy that are not one
+func lifecycleEventFor(change workspaceChange) string {
+	switch {
+	case change.State == "NotFound":
+		return "deleted"
+	case change.PreviousState == "" || change.PreviousState == "NotFound":
+		return "created"
+	case change.State == "Running":
+		return "started"
+	case change.State == "Stopped":
+		return "stopped"
+	}
+	return ""
+}
+
+// recordLifecycle records a lifecycle event made through the server in the
+// workspace timeline and the recent operations of the user of ctx, and
+// announces it to the user's sessions
+func (s *Server) recordLifecycle(ctx context.Context, name, event, message string) {
+	s.recordChange(ctx, name, event, message, nil)
+}
+
+// recordDeletion records a deletion made through the server like
+// recordLifecycle, keeping what captureDeleted described for undo
+func (s *Server) recordDeletion(ctx context.Context, name, message string, deleted *deletedWorkspace) {
+	s.recordChange(ctx, name, "deleted", message, deleted)
+}
+
+func (s *Server) recordChange(ctx context.Context, name, event, message string, deleted *deletedWorkspace) {
+	store := s.state(ctx)
+	store.RecordEvent(name, event, message)
+	store.RecordOperation(operation{
+		ID:        newOperationID(),
+		Time:      time.Now().UTC(),
+		Workspace: name,
+		Event:     event,
+		Message:   message,
+		Session:   SessionID(ctx),
+		User:      UserName(ctx),
+		Deleted:   deleted,
+		Undoes:    undoing(ctx),
+	})
+	if event == "created" {
+		store.SetCreator(name, s.creator(ctx))
+	}
+	if event == "deleted" {
+		// A workspace the user creates later under the same name starts
+		// untagged, unscheduled, on no registered host and without a creator
+		store.SetMetadata(name, workspaceMetadata{})
+		store.SetCreator(name, nil)
+		store.DeleteWorkspaceSchedules(name)
+		store.SetWorkspaceHost(name, "")
+	}
+	if event == "stopped" || event == "deleted" {
+		s.ides.close(UserName(ctx), name)
+	}
+	state := lifecycleStates[event]
+	s.lifecycle.swap(UserName(ctx), name, state)
+	s.announceLifecycle(UserName(ctx), lifecycleEvent{
+		Name:    name,
+		Event:   event,
+		State:   state,
+		Source:  "server",
+		Session: SessionID(ctx),
+		Message: message,
+		Time:    time.Now().UTC(),
+	})
+}
+
+// observeLifecycle announces a lifecycle event for a state change the
+// watcher observed in a user's workspaces, unless the server already
+// announced it
+func (s *Server) observeLifecycle(user string, change workspaceChange) {
+	event := lifecycleEventFor(change)
+	if event == "" || s.lifecycle.swap(user, change.Name, change.State) == change.State {
+		return
+	}
+	s.announceLifecycle(user, lifecycleEvent{
+		Name:   change.Name,
+		Event:  event,
+		State:  change.State,
+		Source: "external",
+		Time:   time.Now().UTC(),
+	})
+}
+
+// announceLifecycle sends a lifecycle notification to the sessions of the
+// user whose workspace changed
+func (s *Server) announceLifecycle(user string, event lifecycleEvent) {
+	if !s.running() {
+		return
+	}
+	if err := s.notifyUser(user, "devpod/workspaceLifecycle", event); err != nil {
+		log.Printf("WARNING: failed to send workspace lifecycle notification: %v", err)
+	}
+	if err := s.notifyUser(user, "notifications/resources/updated", map[string]interface{}{
+		"uri": timelineURI(event.Name),
+	}); err != nil {
+		log.Printf("WARNING: failed to send resource update notification: %v", err)
+	}
+}
+
+// startArgs returns the devpod up command starting an existing workspace
+// with the credential scoping chosen at creation and its secrets. cleanup
+// removes the secret files once devpod has read them.
+func (s *Server) startArgs(ctx context.Context, name, ide string, extra ...string) (context.Context, []string, func(), error) {
+	args := []string{"up", name}
+	if ide != "" {
+		args = append(args, "--ide", ide)
+	}
+	args = append(args, extra...)
+
+	// Reapply the credential scoping chosen when the workspace was created
+	ctx = s.withCredentialScopes(ctx, s.state(ctx).CredentialScopes(name))
+
+	secretArgs, cleanup, err := s.secretUpArgs(ctx, name)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to prepare secrets: %w", err)
+	}
+	return ctx, append(args, secretArgs...), cleanup, nil
+}
+
+// startWorkspace runs devpod up for an existing workspace as startArgs
+// prepares it, records the outcome and writes its file secrets. It returns
+// the devpod output and warnings about secrets that could not be written.
+func (s *Server) startWorkspace(ctx context.Context, name, ide string, extra ...string) ([]byte, []string, error) {
+	ctx, args, cleanup, err := s.startArgs(ctx, name, ide, extra...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer cleanup()
+
+	output, err := s.combinedOutput(ctx, args)
+	if err != nil {
+		s.state(ctx).RecordEvent(name, "error", fmt.Sprintf("start failed: %v", err))
+		return output, nil, withPhases(newDevPodError("failed to start workspace", err, output), output)
+	}
+	s.recordLifecycle(ctx, name, "started", "Workspace started")
+	s.touchWorkspace(ctx, name)
+	return output, s.injectSecretFiles(ctx, name), nil
+}
+
+// stopWorkspace stops a workspace and records the outcome with message
+func (s *Server) stopWorkspace(ctx context.Context, name, message string) ([]byte, error) {
+	output, err := s.combinedOutput(ctx, []string{"stop", name})
+	if err != nil {
+		s.state(ctx).RecordEvent(name, "error", fmt.Sprintf("stop failed: %v", err))
+		return output, newDevPodError("failed to stop workspace", err, output)
+	}
+	s.recordLifecycle(ctx, name, "stopped", message)
+	s.touchWorkspace(ctx, name)
+	return output, nil
+}
+
+// registerLifecycleTools registers the tools that list, create, start, stop and
+// delete workspaces
+func (s *Server) registerLifecycleTools() {
+	// List workspaces
+	debugf("Registering devpod_listWorkspaces handler")
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_listWorkspaces",
+		Description: "List all DevPod workspaces",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"cursor": map[string]interface{}{
+					"type":        "string",
+					"description": "The nextCursor of a previous page (optional)",
+				},
+				"limit": map[string]interface{}{
+					"type":        "integer",
+					"description": "Maximum number of workspaces to return; all are returned when omitted",
+				},
+				"tag": map[string]interface{}{
+					"type":        "string",
+					"description": "Only list workspaces with this tag (optional)",
+				},
+				"createdBy": map[string]interface{}{
+					"type":        "string",
+					"description": "Only list workspaces created through this server by this session ID, client name or user; @me for this session (optional)",
+				},
+			},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		debugf("devpod_listWorkspaces called with params: %s", string(params))
+
+		var listParams struct {
+			pageParams
+			Tag       string `json:"tag,omitempty"`
+			CreatedBy string `json:"createdBy,omitempty"`
+		}
+		if len(params) > 0 {
+			if err := json.Unmarshal(params, &listParams); err != nil {
+				return nil, mcp.NewInvalidParamsError("Invalid list workspaces parameters")
+			}
+		}
+
+		if _, err := s.devpodAvailable(ctx); err != nil {
+			log.Printf("ERROR: DevPod is not available on this system: %v", err)
+			return nil, fmt.Errorf("DevPod is not available on this system: %w", err)
+		}
+
+		output, err := s.executeDevPodCommandWithDebug(ctx, []string{"list", "--output", "json"})
+		if err != nil {
+			log.Printf("ERROR: devpod_listWorkspaces failed: %v", err)
+			return nil, newDevPodError("failed to list workspaces", err, nil)
+		}
+
+		var workspaces []DevPodWorkspace
+		if err := json.Unmarshal(output, &workspaces); err != nil {
+			if s.opts.StrictJSON {
+				return nil, unsupportedOutputError("list", err)
+			}
+			debugf("JSON parsing failed, trying text parsing. Error: %v", err)
+			// If JSON parsing fails, try to parse the text output
+			textResult := parseTextWorkspaceList(string(output))
+			result := map[string]interface{}{
+				"workspaces": textResult,
+			}
+			debugf("devpod_listWorkspaces returning text-parsed result: %v", result)
+			return result, nil
+		}
+
+		now := time.Now()
+		for i := range workspaces {
+			workspaces[i].normalizeTimes(now)
+		}
+		s.attachMetadata(ctx, workspaces)
+		if listParams.Tag != "" {
+			tagged := []DevPodWorkspace{}
+			for _, workspace := range workspaces {
+				if hasTag(workspace.Tags, listParams.Tag) {
+					tagged = append(tagged, workspace)
+				}
+			}
+			workspaces = tagged
+		}
+		workspaces = s.filterCreatedBy(ctx, workspaces, listParams.CreatedBy)
+
+		result := map[string]interface{}{
+			"workspaces": workspaces,
+		}
+		if listParams.enabled() {
+			sort.Slice(workspaces, func(i, j int) bool { return workspaces[i].ID < workspaces[j].ID })
+			ids := make([]string, len(workspaces))
+			for i, workspace := range workspaces {
+				ids[i] = workspace.ID
+			}
+			from, to, next, err := listParams.page(ids)
+			if err != nil {
+				return nil, mcp.NewInvalidParamsError(err.Error())
+			}
+			result["workspaces"] = workspaces[from:to]
+			result["total"] = len(workspaces)
+			if next != "" {
+				result["nextCursor"] = next
+			}
+		}
+		debugf("devpod_listWorkspaces returning JSON-parsed result: %v", result)
+		return result, nil
+	})
+
+	// Create workspace
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_createWorkspace",
+		Description: "Create a new DevPod workspace",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "The name of the workspace",
+				},
+				"source": map[string]interface{}{
+					"type":        "string",
+					"description": "The source git repository, local path or container image such as ubuntu:22.04 (required unless the template provides one)",
+				},
+				"sourceType": map[string]interface{}{
+					"type":        "string",
+					"enum":        []string{"git", "local", "image"},
+					"description": "Treat source as this type instead of detecting it (optional)",
+				},
+				"verifyImage": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Check that an image source exists in its registry, and is published for the workspace's platform, before creating the workspace (default: false)",
+				},
+				"platform": map[string]interface{}{
+					"type":        "string",
+					"description": "Platform the docker provider runs the workspace container as, e.g. linux/amd64 on an arm64 host to use emulation (optional, defaults to the host's)",
+				},
+				"template": map[string]interface{}{
+					"type":        "string",
+					"description": "A configured template supplying defaults and an ordered provider preference list (optional)",
+				},
+				"provider": map[string]interface{}{
+					"type":        "string",
+					"description": "The provider to use (optional)",
+				},
+				"host": map[string]interface{}{
+					"type":        "string",
+					"description": "A host registered with devpod_addSSHHost to create the workspace on with the ssh provider (optional)",
+				},
+				"ide": map[string]interface{}{
+					"type":        "string",
+					"description": "The IDE to use (optional)",
+				},
+				"gitCredentialScopes": map[string]interface{}{
+					"type":        "array",
+					"items":       map[string]interface{}{"type": "string"},
+					"description": "Only forward git credentials for these hosts/paths, e.g. github.com/our-org (optional)",
+				},
+				"devcontainerPath": map[string]interface{}{
+					"type":        "string",
+					"description": "Path of the devcontainer.json to use, relative to the source (optional)",
+				},
+				"machineType": map[string]interface{}{
+					"type":        "string",
+					"description": "Provider machine type, e.g. t3.xlarge on aws (optional, needs a provider)",
+				},
+				"diskSize": map[string]interface{}{
+					"type":        "integer",
+					"description": "Disk size in GB (optional, needs a provider)",
+				},
+				"gpu": map[string]interface{}{
+					"type":        "integer",
+					"description": "Number of GPUs; picks a GPU machine type for one GPU when machineType is omitted (optional, needs a provider)",
+				},
+				"providerOptions": map[string]interface{}{
+					"type":                 "object",
+					"additionalProperties": map[string]interface{}{"type": "string"},
+					"description":          "Provider options passed through as they are (optional)",
+				},
+				"ifExists": map[string]interface{}{
+					"type":        "string",
+					"enum":        []string{"fail", "start", "recreate"},
+					"description": "What to do when the workspace already exists (default: fail)",
+				},
+				"autoStopAfter": map[string]interface{}{
+					"type":        "string",
+					"description": "Stop the workspace once no tool call has named it for this long, e.g. 30m; 0 turns auto-stop off (optional, defaults to the workspace's current auto-stop, then the server's -auto-stop-after)",
+				},
+				"autoName": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Generate an unused name complying with the server's naming policy from the source instead of passing name (optional)",
+				},
+			},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var createParams struct {
+			Name     string `json:"name"`
+			Source   string `json:"source"`
+			Provider string `json:"provider,omitempty"`
+			IDE      string `json:"ide,omitempty"`
+			IfExists string `json:"ifExists,omitempty"`
+			Template string `json:"template,omitempty"`
+			// Host is a registered host of the ssh provider
+			Host string `json:"host,omitempty"`
+			// SourceType overrides the detected git, local or image source type
+			SourceType  string `json:"sourceType,omitempty"`
+			VerifyImage bool   `json:"verifyImage,omitempty"`
+			Platform    string `json:"platform,omitempty"`
+			// GitCredentialScopes limits injected git credentials to these hosts/paths
+			GitCredentialScopes []string `json:"gitCredentialScopes,omitempty"`
+			DevcontainerPath    string   `json:"devcontainerPath,omitempty"`
+			AutoStopAfter       string   `json:"autoStopAfter,omitempty"`
+			AutoName            bool     `json:"autoName,omitempty"`
+			workspaceResources
+		}
+
+		if err := json.Unmarshal(params, &createParams); err != nil {
+			return nil, mcp.NewInvalidParamsError("Invalid create workspace parameters")
+		}
+
+		// Fill in omitted parameters from the template and take its provider preference list
+		providers := []string{createParams.Provider}
+		if createParams.Template != "" {
+			template, ok := s.opts.Templates[createParams.Template]
+			if !ok {
+				return nil, mcp.NewInvalidParamsError(fmt.Sprintf("Unknown template: %s", createParams.Template))
+			}
+			if createParams.Source == "" {
+				createParams.Source = template.Source
+			}
+			if createParams.IDE == "" {
+				createParams.IDE = template.IDE
+			}
+			if createParams.Provider == "" && len(template.Providers) > 0 {
+				providers = template.Providers
+			}
+		}
+
+		// Fall back to the session defaults set with devpod_setDefaults
+		defaults := s.session(ctx)
+		if createParams.IDE == "" {
+			createParams.IDE = defaults.IDE
+		}
+		if providers[0] == "" && defaults.Provider != "" {
+			createParams.Provider = defaults.Provider
+			providers = []string{defaults.Provider}
+		}
+
+		if createParams.AutoName && createParams.Name != "" {
+			return nil, mcp.NewInvalidParamsError("Pass either name or autoName")
+		}
+		if createParams.AutoName && createParams.IfExists != "" {
+			return nil, mcp.NewInvalidParamsError("ifExists does not apply to generated names")
+		}
+		if (createParams.Name == "" && !createParams.AutoName) || createParams.Source == "" {
+			return nil, mcp.NewInvalidParamsError("Name and source are required")
+		}
+
+		// A registered host supplies the options of the ssh provider; options
+		// given explicitly take precedence
+		var host sshHost
+		if createParams.Host != "" {
+			var ok bool
+			if host, ok = s.state(ctx).SSHHost(createParams.Host); !ok {
+				return nil, mcp.NewInvalidParamsError(fmt.Sprintf("Host %s not found (register it with devpod_addSSHHost)", createParams.Host))
+			}
+			if createParams.Provider != "" && createParams.Provider != "ssh" {
+				return nil, mcp.NewInvalidParamsError(fmt.Sprintf("host needs the ssh provider, not %s", createParams.Provider))
+			}
+			createParams.Provider = "ssh"
+			providers = []string{"ssh"}
+			options := host.providerOptions()
+			for key, value := range createParams.ProviderOptions {
+				options[key] = value
+			}
+			createParams.ProviderOptions = options
+		}
+		if err := checkAutoStop(createParams.AutoStopAfter); err != nil {
+			return nil, mcp.NewInvalidParamsError(err.Error())
+		}
+
+		scopes, err := normalizeCredentialScopes(createParams.GitCredentialScopes)
+		if err != nil {
+			return nil, mcp.NewInvalidParamsError(err.Error())
+		}
+
+		// Image sources are validated here; devpod would report them as
+		// unreachable git repositories
+		sourceType, source := classifySource(createParams.Source)
+		switch createParams.SourceType {
+		case "":
+			if s.inLocalRoot(ctx, createParams.Source) {
+				sourceType = sourceLocal
+			}
+		case sourceGit, sourceLocal, sourceImage:
+			sourceType = createParams.SourceType
+		default:
+			return nil, mcp.NewInvalidParamsError("sourceType must be one of: git, local, image")
+		}
+		var warnings []string
+		platform := ""
+		if createParams.Platform != "" {
+			if platform, err = normalizePlatform(createParams.Platform); err != nil {
+				return nil, mcp.NewInvalidParamsError(err.Error())
+			}
+			if platform != hostPlatform() {
+				warnings = append(warnings, fmt.Sprintf("platform %s differs from the host's %s, so docker runs the workspace under emulation", platform, hostPlatform()))
+			}
+		}
+		if sourceType == sourceLocal {
+			if source, err = s.resolveLocalSource(ctx, source); err != nil {
+				return nil, mcp.NewInvalidParamsError(err.Error())
+			}
+		}
+		if sourceType == sourceImage {
+			ref, err := parseImageRef(source)
+			if err != nil {
+				return nil, mcp.NewInvalidParamsError(fmt.Sprintf("%v (set sourceType if it is not an image)", err))
+			}
+			if createParams.VerifyImage {
+				platforms, err := checkImage(ctx, ref)
+				if errors.Is(err, errImageNotFound) {
+					return nil, mcp.NewInvalidParamsError(err.Error())
+				} else if err != nil {
+					warnings = append(warnings, fmt.Sprintf("image not verified: %v", err))
+				}
+				// Only the docker provider is known to run on the host
+				target := platform
+				if target == "" && (providers[0] == "" || providers[0] == "docker") {
+					target = hostPlatform()
+				}
+				if target != "" && len(platforms) > 0 && !platformSupported(target, platforms) {
+					return nil, platformMismatchError(source, target, platforms)
+				}
+			}
+		} else if createParams.VerifyImage {
+			return nil, mcp.NewInvalidParamsError(fmt.Sprintf("verifyImage only applies to image sources; %s is a %s source", createParams.Source, sourceType))
+		}
+
+		switch createParams.IfExists {
+		case "":
+			createParams.IfExists = "fail"
+		case "fail", "start", "recreate":
+		default:
+			return nil, mcp.NewInvalidParamsError("ifExists must be one of: fail, start, recreate")
+		}
+
+		if createParams.AutoName {
+			workspaces, err := s.listWorkspaces(ctx)
+			if err != nil {
+				return nil, newDevPodError("failed to list workspaces", err, nil)
+			}
+			// lockHandler had no name to lock, so the generated one is locked
+			// here. A name another call holds, such as a concurrent autoName
+			// creation from the same source, is taken too.
+			for {
+				if createParams.Name, err = s.opts.Names.autoName(sourceType, source, workspaces); err != nil {
+					return nil, mcp.NewInvalidParamsError(err.Error())
+				}
+				release, holder := s.locks.acquire(ctx, createParams.Name, "devpod_createWorkspace", 0)
+				if holder == nil {
+					defer release()
+					break
+				}
+				workspaces = append(workspaces, DevPodWorkspace{ID: createParams.Name})
+			}
+		}
+		existing, err := s.findWorkspace(ctx, createParams.Name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check for existing workspace: %w", err)
+		}
+		exists := existing != nil
+		// Existing workspaces keep the names they were created with
+		if !exists {
+			if err := s.opts.Names.check(createParams.Name); err != nil {
+				return nil, mcp.NewInvalidParamsError(fmt.Sprintf("%v (set autoName to generate a compliant name)", err))
+			}
+		}
+
+		var args []string
+		action := "created"
+		switch {
+		case !exists:
+			// The provider is added per attempt by upWithFailover
+			args = []string{"up", source, "--id", createParams.Name}
+		case createParams.IfExists == "start":
+			// Reuse the existing workspace instead of running up against the source again
+			action = "started"
+			args = []string{"up", createParams.Name}
+		case createParams.IfExists == "recreate":
+			action = "recreated"
+			args = []string{"up", source, "--id", createParams.Name, "--recreate"}
+		default:
+			return nil, mcp.NewInvalidParamsError(fmt.Sprintf("Workspace %s already exists (set ifExists to start or recreate)", createParams.Name))
+		}
+		if createParams.IDE != "" {
+			args = append(args, "--ide", createParams.IDE)
+		}
+
+		// Resources and the devcontainer only apply when the container is (re)built
+		var providerArgs map[string][]string
+		if action == "started" && createParams.Host != "" {
+			return nil, mcp.NewInvalidParamsError("host only applies when creating or recreating a workspace")
+		}
+		if action == "started" && (createParams.DevcontainerPath != "" || !createParams.workspaceResources.empty()) {
+			return nil, mcp.NewInvalidParamsError("devcontainerPath and resource options only apply when creating or recreating a workspace")
+		}
+		if action == "recreated" && createParams.Host != "" && existing.Provider.Name != "ssh" {
+			return nil, mcp.NewInvalidParamsError(fmt.Sprintf("Workspace %s runs on provider %s; delete it to move it to host %s", createParams.Name, existing.Provider.Name, host.Name))
+		}
+		if createParams.Host != "" {
+			if err := s.ensureSSHProvider(ctx); err != nil {
+				return nil, newDevPodError("failed to add the ssh provider", err, nil)
+			}
+		}
+		if createParams.DevcontainerPath != "" {
+			args = append(args, "--devcontainer-path", createParams.DevcontainerPath)
+		}
+		if !createParams.workspaceResources.empty() {
+			targets := providers
+			if action == "recreated" {
+				targets = []string{existing.Provider.Name}
+			}
+			if providerArgs, err = s.resourceArgs(ctx, createParams.workspaceResources, targets); err != nil {
+				return nil, mcp.NewInvalidParamsError(fmt.Sprintf("Invalid resource options: %v", err))
+			}
+			if action == "recreated" {
+				args = append(args, providerArgs[existing.Provider.Name]...)
+			}
+		}
+
+		if len(scopes) > 0 {
+			s.state(ctx).SetCredentialScopes(createParams.Name, scopes)
+		} else if action != "started" {
+			s.state(ctx).SetCredentialScopes(createParams.Name, nil)
+		}
+		ctx = s.withCredentialScopes(ctx, s.state(ctx).CredentialScopes(createParams.Name))
+		if platform != "" {
+			ctx = WithCommandEnv(ctx, platformEnv(platform))
+		}
+
+		secretArgs, cleanup, err := s.secretUpArgs(ctx, createParams.Name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to prepare secrets: %w", err)
+		}
+		defer cleanup()
+		args = append(args, secretArgs...)
+
+		// Enforce the quotas per provider; providers of a template the quotas
+		// rule out are skipped
+		if action != "started" {
+			targets := providers
+			if action == "recreated" {
+				targets = []string{existing.Provider.Name}
+			}
+			var allowed []string
+			var rejected error
+			for _, target := range targets {
+				// Invalid resource options were rejected above
+				options, _ := createParams.workspaceResources.providerOptions(target)
+				release, err := s.reserveQuota(ctx, quotaRequest{Name: createParams.Name, Provider: target, Options: options, GPU: createParams.GPU})
+				if err != nil {
+					if rejected == nil {
+						rejected = err
+					}
+					if len(targets) > 1 {
+						warnings = append(warnings, fmt.Sprintf("provider %s skipped: %v", target, err))
+					}
+					continue
+				}
+				defer release()
+				allowed = append(allowed, target)
+			}
+			if len(allowed) == 0 {
+				s.state(ctx).RecordEvent(createParams.Name, "error", fmt.Sprintf("create rejected: %v", rejected))
+				return nil, newDevPodError("failed to create workspace", rejected, nil)
+			}
+			if action == "created" {
+				providers = allowed
+			}
+		}
+
+		// Enforce the hourly creation budget; reusing an existing workspace is free
+		if action != "started" {
+			if err := s.limiter.allowCreate(time.Now()); err != nil {
+				s.state(ctx).RecordEvent(createParams.Name, "error", fmt.Sprintf("create rejected: %v", err))
+				return nil, newDevPodError("failed to create workspace", err, nil)
+			}
+		}
+
+		start := time.Now()
+		var output []byte
+		var attempts []providerAttempt
+		provider := createParams.Provider
+		if action == "created" {
+			output, provider, attempts, err = s.upWithFailover(ctx, createParams.Name, args, providers, providerArgs)
+		} else {
+			output, err = s.combinedOutput(ctx, args)
+		}
+		if err != nil {
+			s.state(ctx).RecordEvent(createParams.Name, "error", fmt.Sprintf("create failed: %v", err))
+			return nil, withPhases(newDevPodError("failed to create workspace", err, output), output)
+		}
+		if provider != "" {
+			s.recordLifecycle(ctx, createParams.Name, action, fmt.Sprintf("Workspace %s on provider %s", action, provider))
+		} else {
+			s.recordLifecycle(ctx, createParams.Name, action, "Workspace "+action)
+		}
+		if createParams.Host != "" {
+			s.state(ctx).SetWorkspaceHost(createParams.Name, host.Name)
+		}
+		s.touchWorkspace(ctx, createParams.Name)
+		warnings = append(warnings, s.injectSecretFiles(ctx, createParams.Name)...)
+		autoStop := s.applyAutoStop(ctx, createParams.Name, createParams.AutoStopAfter)
+
+		message := "Workspace created successfully"
+		if action == "started" {
+			message = "Existing workspace started successfully"
+		} else if action == "recreated" {
+			message = "Workspace recreated successfully"
+		}
+
+		result := map[string]interface{}{
+			"name":       createParams.Name,
+			"created":    action != "started",
+			"reused":     action == "started",
+			"action":     action,
+			"sourceType": sourceType,
+			"message":    message,
+			"output":     string(output),
+			"phases":     parsePhases(string(output), false),
+			"durationMs": durationMs(start),
+		}
+		if len(warnings) > 0 {
+			result["warnings"] = warnings
+		}
+		if platform != "" {
+			result["platform"] = platform
+		}
+		if provider != "" {
+			result["provider"] = provider
+		}
+		if createParams.Host != "" {
+			result["host"] = host.Name
+		}
+		if len(attempts) > 0 {
+			result["failedAttempts"] = attempts
+		}
+		if autoStop != nil {
+			result["autoStopAfter"] = autoStop.InactiveFor
+			result["autoStopSchedule"] = autoStop.ID
+		}
+		if details, err := s.describeWorkspace(ctx, createParams.Name); err == nil {
+			if details.Provider.Name != "" {
+				result["provider"] = details.Provider.Name
+			}
+			result["workspace"] = details
+		} else {
+			log.Printf("WARNING: failed to describe workspace %s: %v", createParams.Name, err)
+		}
+		return result, nil
+	})
+
+	// Start workspace
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_startWorkspace",
+		Description: "Start a DevPod workspace",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "The name of the workspace",
+				},
+				"ide": map[string]interface{}{
+					"type":        "string",
+					"description": "The IDE to use (optional)",
+				},
+				"autoStopAfter": map[string]interface{}{
+					"type":        "string",
+					"description": "Stop the workspace once no tool call has named it for this long, e.g. 30m; 0 turns auto-stop off (optional, defaults to the workspace's current auto-stop, then the server's -auto-stop-after)",
+				},
+			},
+			"required": []string{"name"},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var startParams struct {
+			Name          string `json:"name"`
+			IDE           string `json:"ide,omitempty"`
+			AutoStopAfter string `json:"autoStopAfter,omitempty"`
+		}
+
+		if err := json.Unmarshal(params, &startParams); err != nil {
+			return nil, mcp.NewInvalidParamsError("Invalid start workspace parameters")
+		}
+
+		if startParams.Name == "" {
+			return nil, mcp.NewInvalidParamsError("Workspace name is required")
+		}
+		if err := checkAutoStop(startParams.AutoStopAfter); err != nil {
+			return nil, mcp.NewInvalidParamsError(err.Error())
+		}
+
+		start := time.Now()
+		output, warnings, err := s.startWorkspace(ctx, startParams.Name, startParams.IDE)
+		if err != nil {
+			return nil, err
+		}
+
+		result := map[string]interface{}{
+			"name":       startParams.Name,
+			"message":    "Workspace started successfully",
+			"output":     string(output),
+			"phases":     parsePhases(string(output), false),
+			"durationMs": durationMs(start),
+		}
+		if autoStop := s.applyAutoStop(ctx, startParams.Name, startParams.AutoStopAfter); autoStop != nil {
+			result["autoStopAfter"] = autoStop.InactiveFor
+			result["autoStopSchedule"] = autoStop.ID
+		}
+		if len(warnings) > 0 {
+			result["warnings"] = warnings
+		}
+		if details, err := s.describeWorkspace(ctx, startParams.Name); err == nil {
+			result["workspace"] = details
+		} else {
+			log.Printf("WARNING: failed to describe workspace %s: %v", startParams.Name, err)
+		}
+		return result, nil
+	})
+
+	// Stop workspace
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_stopWorkspace",
+		Description: "Stop a DevPod workspace",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "The name of the workspace",
+				},
+			},
+			"required": []string{"name"},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var stopParams struct {
+			Name string `json:"name"`
+		}
+
+		if err := json.Unmarshal(params, &stopParams); err != nil {
+			return nil, mcp.NewInvalidParamsError("Invalid stop workspace parameters")
+		}
+
+		if stopParams.Name == "" {
+			return nil, mcp.NewInvalidParamsError("Workspace name is required")
+		}
+
+		start := time.Now()
+		output, err := s.stopWorkspace(ctx, stopParams.Name, "Workspace stopped")
+		if err != nil {
+			return nil, err
+		}
+
+		return map[string]interface{}{
+			"name":       stopParams.Name,
+			"message":    "Workspace stopped successfully",
+			"output":     string(output),
+			"durationMs": durationMs(start),
+		}, nil
+	})
+
+	// Delete workspace
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_deleteWorkspace",
+		Description: "Delete a DevPod workspace",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "The name of the workspace",
+				},
+				"force": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Force deletion without confirmation",
+				},
+			},
+			"required": []string{"name"},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var deleteParams struct {
+			Name  string `json:"name"`
+			Force bool   `json:"force,omitempty"`
+		}
+
+		if err := json.Unmarshal(params, &deleteParams); err != nil {
+			return nil, mcp.NewInvalidParamsError("Invalid delete workspace parameters")
+		}
+
+		if deleteParams.Name == "" {
+			return nil, mcp.NewInvalidParamsError("Workspace name is required")
+		}
+
+		args := []string{"delete", deleteParams.Name}
+		if deleteParams.Force {
+			args = append(args, "--force")
+		}
+
+		start := time.Now()
+		deleted := s.captureDeletedByName(ctx, deleteParams.Name)
+		output, err := s.combinedOutput(ctx, args)
+		if err != nil {
+			s.state(ctx).RecordEvent(deleteParams.Name, "error", fmt.Sprintf("delete failed: %v", err))
+			return nil, newDevPodError("failed to delete workspace", err, output)
+		}
+		s.recordDeletion(ctx, deleteParams.Name, "Workspace deleted", deleted)
+		s.forgetWorkspace(ctx, deleteParams.Name)
+
+		return map[string]interface{}{
+			"name":       deleteParams.Name,
+			"message":    "Workspace deleted successfully",
+			"output":     string(output),
+			"durationMs": durationMs(start),
+		}, nil
+	})
+}
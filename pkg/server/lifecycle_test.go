@@ -0,0 +1,88 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// recordingTransport keeps the messages the server sends
+type recordingTransport struct {
+	mu   sync.Mutex
+	sent [][]byte
+}
+
+func (r *recordingTransport) Start(ctx context.Context) error { return nil }
+func (r *recordingTransport) Stop() error                     { return nil }
+func (r *recordingTransport) Close() error                    { return nil }
+func (r *recordingTransport) Receive() <-chan []byte          { return nil }
+
+func (r *recordingTransport) Send(message []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sent = append(r.sent, message)
+	return nil
+}
+
+// lifecycleEvents returns the lifecycle notifications sent so far
+func (r *recordingTransport) lifecycleEvents(t *testing.T) []lifecycleEvent {
+	t.Helper()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var events []lifecycleEvent
+	for _, message := range r.sent {
+		var notification struct {
+			Method string         `json:"method"`
+			Params lifecycleEvent `json:"params"`
+		}
+		if err := json.Unmarshal(message, &notification); err != nil {
+			t.Fatalf("Failed to parse notification: %v", err)
+		}
+		if notification.Method == "devpod/workspaceLifecycle" {
+			events = append(events, notification.Params)
+		}
+	}
+	return events
+}
+
+func TestLifecycleNotifications(t *testing.T) {
+	transport := &recordingTransport{}
+	s := New(transport, Options{
+		Runner:    &fakeRunner{outputs: map[string]string{"stop ws1": ""}},
+		StatePath: filepath.Join(t.TempDir(), "state.json"),
+	})
+	s.started.Store(true)
+
+	ctx := WithSessionID(context.Background(), "session-a")
+	if _, err := s.MCP().GetHandler("devpod_stopWorkspace")(ctx, json.RawMessage(`{"name":"ws1"}`)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// The watcher sees the stop made through the server and stays quiet, then
+	// sees the workspace started and deleted from outside
+	s.observeLifecycle("", workspaceChange{Name: "ws1", PreviousState: "Running", State: "Stopped"})
+	s.observeLifecycle("", workspaceChange{Name: "ws1", PreviousState: "Stopped", State: "Busy"})
+	s.observeLifecycle("", workspaceChange{Name: "ws1", PreviousState: "Busy", State: "Running"})
+	s.observeLifecycle("", workspaceChange{Name: "ws1", PreviousState: "Running", State: "NotFound"})
+
+	events := transport.lifecycleEvents(t)
+	expected := []lifecycleEvent{
+		{Name: "ws1", Event: "stopped", State: "Stopped", Source: "server", Session: "session-a", Message: "Workspace stopped"},
+		{Name: "ws1", Event: "started", State: "Running", Source: "external"},
+		{Name: "ws1", Event: "deleted", State: "NotFound", Source: "external"},
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("Expected %d lifecycle notifications, got %+v", len(expected), events)
+	}
+	for i, event := range events {
+		if event.Time.IsZero() {
+			t.Errorf("Event %d: expected a time", i)
+		}
+		event.Time = expected[i].Time
+		if event != expected[i] {
+			t.Errorf("Event %d: expected %+v, got %+v", i, expected[i], event)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Limits caps how much devpod work clients can trigger. Zero values disable
+// the corresponding limit.
+type Limits struct {
+	// MaxConcurrent caps devpod commands running at once across all clients
+	MaxConcurrent int
+	// MaxConcurrentPerSession caps devpod commands running at once per client session
+	MaxConcurrentPerSession int
+	// MaxCreatesPerHour caps workspace creations in any rolling hour
+	MaxCreatesPerHour int
+	// QueueTimeout is how long a command waits for a free slot before it is
+	// rejected (default: 30s)
+	QueueTimeout time.Duration
+}
+
+// RateLimitError is returned when a limit rejects a devpod command
+type RateLimitError struct {
+	Reason string
+	// RetryAfter is a hint for when the call may succeed, or 0 if unknown
+	RetryAfter time.Duration
+}
+
+// Error implements the error interface
+func (e *RateLimitError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("%s; retry after %s", e.Reason, e.RetryAfter.Round(time.Second))
+	}
+	return e.Reason
+}
+
+// limiter enforces Limits with semaphores for concurrency and a rolling
+// window of creation times
+type limiter struct {
+	limits   Limits
+	global   chan struct{}
+	mu       sync.Mutex
+	sessions map[string]chan struct{}
+	creates  []time.Time
+}
+
+func newLimiter(limits Limits) *limiter {
+	if limits.QueueTimeout <= 0 {
+		limits.QueueTimeout = 30 * time.Second
+	}
+	l := &limiter{
+		limits:   limits,
+		sessions: make(map[string]chan struct{}),
+	}
+	if limits.MaxConcurrent > 0 {
+		l.global = make(chan struct{}, limits.MaxConcurrent)
+	}
+	return l
+}
+
+// acquire waits for a global and a per-session command slot, queueing for at
+// most QueueTimeout. The returned function releases the slots.
+func (l *limiter) acquire(ctx context.Context) (func(), error) {
+	timer := time.NewTimer(l.limits.QueueTimeout)
+	defer timer.Stop()
+
+	var slots []chan struct{}
+	release := func() {
+		for _, slot := range slots {
+			<-slot
+		}
+	}
+
+	if session := l.sessionSlots(SessionID(ctx)); session != nil {
+		if err := l.wait(ctx, timer, session, "too many concurrent devpod commands for this session"); err != nil {
+			return nil, err
+		}
+		slots = append(slots, session)
+	}
+	if l.global != nil {
+		if err := l.wait(ctx, timer, l.global, "too many concurrent devpod commands"); err != nil {
+			release()
+			return nil, err
+		}
+		slots = append(slots, l.global)
+	}
+	return release, nil
+}
+
+// wait takes a slot from sem, giving up when the queue timer fires
+func (l *limiter) wait(ctx context.Context, timer *time.Timer, sem chan struct{}, reason string) error {
+	select {
+	case sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return &RateLimitError{Reason: fmt.Sprintf("%s (waited %s)", reason, l.limits.QueueTimeout)}
+	}
+}
+
+// sessionSlots returns the semaphore of a session, or nil when per-session
+// limits are disabled or the call has no session
+func (l *limiter) sessionSlots(session string) chan struct{} {
+	if l.limits.MaxConcurrentPerSession <= 0 || session == "" {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	sem, ok := l.sessions[session]
+	if !ok {
+		sem = make(chan struct{}, l.limits.MaxConcurrentPerSession)
+		l.sessions[session] = sem
+	}
+	return sem
+}
+
+// removeSession drops the semaphore of an ended session
+func (l *limiter) removeSession(session string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.sessions, session)
+}
+
+// allowCreate records a workspace creation, or rejects it when the hourly
+// budget is spent
+func (l *limiter) allowCreate(now time.Time) error {
+	if l.limits.MaxCreatesPerHour <= 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	cutoff := now.Add(-time.Hour)
+	recent := l.creates[:0]
+	for _, t := range l.creates {
+		if t.After(cutoff) {
+			recent = append(recent, t)
+		}
+	}
+	l.creates = recent
+
+	if len(l.creates) >= l.limits.MaxCreatesPerHour {
+		return &RateLimitError{
+			Reason:     fmt.Sprintf("workspace creation limit of %d per hour reached", l.limits.MaxCreatesPerHour),
+			RetryAfter: l.creates[0].Add(time.Hour).Sub(now),
+		}
+	}
+	l.creates = append(l.creates, now)
+	return nil
+}
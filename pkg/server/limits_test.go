@@ -0,0 +1,69 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLimiterRejectsWhenQueueTimesOut(t *testing.T) {
+	l := newLimiter(Limits{MaxConcurrent: 1, QueueTimeout: 10 * time.Millisecond})
+
+	release, err := l.acquire(context.Background())
+	if err != nil {
+		t.Fatalf("First acquire failed: %v", err)
+	}
+
+	var limitErr *RateLimitError
+	if _, err := l.acquire(context.Background()); !errors.As(err, &limitErr) {
+		t.Fatalf("Expected RateLimitError while the slot is held, got %v", err)
+	}
+
+	release()
+	release, err = l.acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Acquire after release failed: %v", err)
+	}
+	release()
+}
+
+func TestLimiterPerSession(t *testing.T) {
+	l := newLimiter(Limits{MaxConcurrentPerSession: 1, QueueTimeout: 10 * time.Millisecond})
+	alice := WithSessionID(context.Background(), "alice")
+
+	release, err := l.acquire(alice)
+	if err != nil {
+		t.Fatalf("First acquire failed: %v", err)
+	}
+	defer release()
+
+	if _, err := l.acquire(alice); err == nil {
+		t.Error("Expected the second command in the same session to be rejected")
+	}
+	other, err := l.acquire(WithSessionID(context.Background(), "bob"))
+	if err != nil {
+		t.Errorf("Expected another session to get a slot, got %v", err)
+	} else {
+		other()
+	}
+}
+
+func TestLimiterCreatesPerHour(t *testing.T) {
+	l := newLimiter(Limits{MaxCreatesPerHour: 2})
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		if err := l.allowCreate(now); err != nil {
+			t.Fatalf("Create %d rejected: %v", i, err)
+		}
+	}
+
+	var limitErr *RateLimitError
+	if err := l.allowCreate(now.Add(time.Minute)); !errors.As(err, &limitErr) || limitErr.RetryAfter != 59*time.Minute {
+		t.Errorf("Expected rejection with 59m retry hint, got %v", err)
+	}
+	if err := l.allowCreate(now.Add(time.Hour + time.Second)); err != nil {
+		t.Errorf("Expected budget to recover after an hour, got %v", err)
+	}
+}
@@ -0,0 +1,172 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+// workspaceLockParams are the tools that create, start, stop or delete a
+// workspace, with the argument naming it. They hold the workspace's lock
+// while they run. devpod_createWorkspace with autoName locks the name it
+// generates itself.
+var workspaceLockParams = map[string]string{
+	"devpod_createWorkspace":   "name",
+	"devpod_startWorkspace":    "name",
+	"devpod_openIDE":           "name",
+	"devpod_stopWorkspace":     "name",
+	"devpod_deleteWorkspace":   "name",
+	"devpod_cloneWorkspace":    "newName",
+	"devpod_importWorkspace":   "name",
+	"devpod_snapshotWorkspace": "name",
+}
+
+// workspaceLock is an operation holding a workspace
+type workspaceLock struct {
+	Workspace string `json:"workspace"`
+	// User is the authenticated user whose workspace is held
+	User string `json:"user,omitempty"`
+	// Operation is the tool or background task holding the workspace
+	Operation string `json:"operation"`
+	// JobID is the ID of the tool call on the dashboard and in the audit
+	// log, or 0 for background tasks
+	JobID   int64     `json:"jobId,omitempty"`
+	Session string    `json:"session,omitempty"`
+	Since   time.Time `json:"since"`
+	// released is closed when the lock is released
+	released chan struct{}
+}
+
+// workspaceLocks serializes the operations on each workspace
+type workspaceLocks struct {
+	mu    sync.Mutex
+	held  map[workspaceKey]*workspaceLock
+	clock func() time.Time
+}
+
+func newWorkspaceLocks() *workspaceLocks {
+	return &workspaceLocks{held: make(map[workspaceKey]*workspaceLock), clock: time.Now}
+}
+
+// acquire takes the lock of a workspace of the user of ctx, waiting up to wait for the
+// operation holding it to finish. It returns the lock holding the workspace
+// when the wait ran out.
+func (l *workspaceLocks) acquire(ctx context.Context, workspace, operation string, wait time.Duration) (func(), *workspaceLock) {
+	var timeout <-chan time.Time
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	key := workspaceKey{UserName(ctx), workspace}
+	for {
+		l.mu.Lock()
+		holder, busy := l.held[key]
+		if !busy {
+			lock := &workspaceLock{
+				Workspace: workspace,
+				User:      key.user,
+				Operation: operation,
+				JobID:     invocationID(ctx),
+				Session:   SessionID(ctx),
+				Since:     l.clock().UTC(),
+				released:  make(chan struct{}),
+			}
+			l.held[key] = lock
+			l.mu.Unlock()
+			return func() { l.release(lock) }, nil
+		}
+		copied := *holder
+		l.mu.Unlock()
+
+		if wait <= 0 {
+			return nil, &copied
+		}
+		select {
+		case <-holder.released:
+		case <-timeout:
+			return nil, &copied
+		case <-ctx.Done():
+			return nil, &copied
+		}
+	}
+}
+
+// release frees a workspace taken by acquire
+func (l *workspaceLocks) release(lock *workspaceLock) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	key := workspaceKey{lock.User, lock.Workspace}
+	if l.held[key] == lock {
+		delete(l.held, key)
+		close(lock.released)
+	}
+}
+
+// list returns the held locks of a user's workspaces by workspace name
+func (l *workspaceLocks) list(user string) []workspaceLock {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	locks := make([]workspaceLock, 0, len(l.held))
+	for key, lock := range l.held {
+		if key.user == user {
+			locks = append(locks, *lock)
+		}
+	}
+	sort.Slice(locks, func(i, j int) bool { return locks[i].Workspace < locks[j].Workspace })
+	return locks
+}
+
+// conflictError reports that a workspace is held by another operation
+func conflictError(operation string, holder *workspaceLock) *mcp.RPCError {
+	message := fmt.Sprintf("%s cannot run on workspace %s while %s is in progress", operation, holder.Workspace, holder.Operation)
+	if holder.JobID != 0 {
+		message = fmt.Sprintf("%s cannot run on workspace %s while %s (job %d) is in progress", operation, holder.Workspace, holder.Operation, holder.JobID)
+	}
+	return mcp.NewRPCError(categoryCodes[CategoryConflict], fmt.Sprintf("%s: %s", message, CategoryConflict), map[string]interface{}{
+		"category":  CategoryConflict,
+		"workspace": holder.Workspace,
+		"operation": holder.Operation,
+		"jobId":     holder.JobID,
+		"since":     holder.Since,
+		"heldForMs": durationMs(holder.Since),
+	})
+}
+
+// lockWorkspace takes the lock of a workspace for an operation, waiting up
+// to the configured lock wait, or returns a Conflict error
+func (s *Server) lockWorkspace(ctx context.Context, workspace, operation string) (func(), error) {
+	release, holder := s.locks.acquire(ctx, workspace, operation, s.opts.LockWait)
+	if holder != nil {
+		return nil, conflictError(operation, holder)
+	}
+	return release, nil
+}
+
+// lockHandler wraps the handler of a tool listed in workspaceLockParams so
+// it runs while holding the lock of the workspace it names
+func (s *Server) lockHandler(tool string, handler mcp.Handler) mcp.Handler {
+	param, ok := workspaceLockParams[tool]
+	if !ok {
+		return handler
+	}
+	return func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var args map[string]interface{}
+		_ = json.Unmarshal(params, &args)
+		workspace, _ := args[param].(string)
+		if workspace == "" {
+			return handler(ctx, params)
+		}
+		release, err := s.lockWorkspace(ctx, workspace, tool)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		return handler(ctx, params)
+	}
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+func TestWorkspaceLocks(t *testing.T) {
+	locks := newWorkspaceLocks()
+	ctx := context.WithValue(context.Background(), invocationKey{}, int64(7))
+
+	release, holder := locks.acquire(ctx, "api", "devpod_createWorkspace", 0)
+	if holder != nil {
+		t.Fatalf("Expected the lock to be free, held by %+v", holder)
+	}
+	if _, holder := locks.acquire(context.Background(), "api", "devpod_deleteWorkspace", 0); holder == nil || holder.JobID != 7 || holder.Operation != "devpod_createWorkspace" {
+		t.Fatalf("Expected the create to hold the workspace, got %+v", holder)
+	}
+	if other, holder := locks.acquire(context.Background(), "web", "devpod_deleteWorkspace", 0); holder != nil {
+		t.Fatalf("Expected other workspaces to be free, held by %+v", holder)
+	} else {
+		other()
+	}
+
+	// a waiting operation runs once the holder finishes
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		release()
+	}()
+	next, holder := locks.acquire(context.Background(), "api", "devpod_deleteWorkspace", time.Second)
+	if holder != nil {
+		t.Fatalf("Expected the wait to end with the lock, held by %+v", holder)
+	}
+	next()
+	if held := locks.list(""); len(held) != 0 {
+		t.Errorf("Expected no held locks, got %v", held)
+	}
+}
+
+// blockingRunner holds devpod up until unblocked
+type blockingRunner struct {
+	fakeRunner
+	started chan struct{}
+	unblock chan struct{}
+}
+
+func (r *blockingRunner) Run(ctx context.Context, args []string) ([]byte, []byte, error) {
+	if args[0] == "up" {
+		close(r.started)
+		<-r.unblock
+	}
+	return r.fakeRunner.Run(ctx, args)
+}
+
+func TestDeleteConflictsWithRunningCreate(t *testing.T) {
+	runner := &blockingRunner{
+		fakeRunner: fakeRunner{outputs: map[string]string{"list --output json": "[]"}},
+		started:    make(chan struct{}),
+		unblock:    make(chan struct{}),
+	}
+	s := newTestServer(t, runner)
+	call := s.MCP().GetHandler("tools/call")
+
+	created := make(chan error)
+	go func() {
+		_, err := call(context.Background(), json.RawMessage(`{"name":"devpod_createWorkspace","arguments":{"name":"api","source":"ubuntu:22.04"}}`))
+		created <- err
+	}()
+	<-runner.started
+
+	_, err := call(context.Background(), json.RawMessage(`{"name":"devpod_deleteWorkspace","arguments":{"name":"api"}}`))
+	rpcErr, ok := err.(*mcp.RPCError)
+	if !ok || rpcErr.Code != categoryCodes[CategoryConflict] {
+		t.Fatalf("Expected a Conflict error, got %v", err)
+	}
+	data := rpcErr.Data.(map[string]interface{})
+	if data["operation"] != "devpod_createWorkspace" || data["jobId"].(int64) == 0 {
+		t.Errorf("Expected the create and its job ID, got %v", data)
+	}
+
+	close(runner.unblock)
+	if err := <-created; err != nil {
+		t.Fatalf("devpod_createWorkspace failed: %v", err)
+	}
+	for _, args := range runner.calls {
+		if args[0] == "delete" {
+			t.Error("Expected the conflicting delete not to run")
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"sync"
+	"sync/atomic"
+)
+
+// Verbosity selects how much the server logs
+type Verbosity int32
+
+const (
+	// VerbosityQuiet logs warnings and errors only. Some STDIO clients treat
+	// chatty stderr as a failure, so this is the default.
+	VerbosityQuiet Verbosity = iota
+	// VerbosityInfo adds lifecycle messages such as startup, background jobs
+	// and workspace state changes
+	VerbosityInfo
+	// VerbosityDebug adds every devpod command with its output, each handled
+	// message and the tracing of the MCP framework
+	VerbosityDebug
+)
+
+var (
+	verbosity atomic.Int32
+	// logOutputMu guards logOutput, where leveled messages go; nil means
+	// the standard logger's output
+	logOutputMu sync.RWMutex
+	logOutput   io.Writer
+)
+
+// SetVerbosity sets how much the server logs
+func SetVerbosity(v Verbosity) {
+	verbosity.Store(int32(v))
+}
+
+// SetLogOutput sends the log to w. Below VerbosityDebug, lines other
+// packages write through the standard logger, such as the MCP framework's
+// trace of every message, are only kept when they report a problem.
+func SetLogOutput(w io.Writer) {
+	logOutputMu.Lock()
+	logOutput = w
+	logOutputMu.Unlock()
+	log.SetOutput(&problemFilter{w: w})
+}
+
+// Logf logs a message when the verbosity is at least v. Warnings and
+// errors are logged with log.Printf instead, whatever the verbosity.
+func Logf(v Verbosity, format string, args ...interface{}) {
+	if Verbosity(verbosity.Load()) < v {
+		return
+	}
+	logOutputMu.RLock()
+	w := logOutput
+	logOutputMu.RUnlock()
+	if w == nil {
+		w = log.Writer()
+	}
+	_ = log.New(w, log.Prefix(), log.Flags()).Output(2, fmt.Sprintf(format, args...))
+}
+
+// infof logs a lifecycle message
+func infof(format string, args ...interface{}) {
+	Logf(VerbosityInfo, format, args...)
+}
+
+// debugf logs a debugging message
+func debugf(format string, args ...interface{}) {
+	Logf(VerbosityDebug, format, args...)
+}
+
+// problemLine matches log lines that report a warning, an error or a crash
+var problemLine = regexp.MustCompile(`(?i)warn|error|fail|panic|fatal|unable|cannot|denied|dropping`)
+
+// problemFilter drops log lines that don't report a problem unless the
+// verbosity is VerbosityDebug
+type problemFilter struct {
+	w io.Writer
+}
+
+func (f *problemFilter) Write(p []byte) (int, error) {
+	if Verbosity(verbosity.Load()) < VerbosityDebug && !problemLine.Match(p) {
+		return len(p), nil
+	}
+	if _, err := f.w.Write(p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
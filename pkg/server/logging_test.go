@@ -0,0 +1,51 @@
+package server
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestVerbosityGatesLogOutput(t *testing.T) {
+	var buf bytes.Buffer
+	SetLogOutput(&buf)
+	defer func() {
+		SetVerbosity(VerbosityQuiet)
+		SetLogOutput(os.Stderr)
+	}()
+
+	logAll := func() string {
+		buf.Reset()
+		log.Printf("Parsed as request: method=tools/call, id=1")
+		log.Printf("WARNING: watcher: poll failed")
+		infof("Watching workspace state every 30s")
+		debugf("Executing devpod command with args: [list]")
+		return buf.String()
+	}
+
+	cases := []struct {
+		verbosity Verbosity
+		shown     []string
+		hidden    []string
+	}{
+		{VerbosityQuiet, []string{"WARNING"}, []string{"Parsed as request", "Watching", "Executing"}},
+		{VerbosityInfo, []string{"WARNING", "Watching"}, []string{"Parsed as request", "Executing"}},
+		{VerbosityDebug, []string{"WARNING", "Watching", "Parsed as request", "Executing"}, nil},
+	}
+	for _, tc := range cases {
+		SetVerbosity(tc.verbosity)
+		output := logAll()
+		for _, text := range tc.shown {
+			if !strings.Contains(output, text) {
+				t.Errorf("Expected %q at verbosity %d, got %q", text, tc.verbosity, output)
+			}
+		}
+		for _, text := range tc.hidden {
+			if strings.Contains(output, text) {
+				t.Errorf("Expected no %q at verbosity %d, got %q", text, tc.verbosity, output)
+			}
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxNameLength is the longest workspace ID devpod derives from a source,
+// which generated names keep to when the policy sets no shorter limit
+const maxNameLength = 48
+
+// validNamePrefix matches the prefixes generated names can start with
+var validNamePrefix = regexp.MustCompile(`^[a-z0-9-]*$`)
+
+// NamePolicy constrains the names of new workspaces; the zero value allows
+// any name devpod accepts
+type NamePolicy struct {
+	// Pattern must match the whole name
+	Pattern *regexp.Regexp
+	// Prefix is what every name starts with, e.g. ai-
+	Prefix string
+	// MaxLength caps the length of names (0 disables)
+	MaxLength int
+}
+
+// NewNamePolicy builds a name policy from the -workspace-name-* flags. The
+// pattern is anchored so it matches whole names.
+func NewNamePolicy(pattern, prefix string, maxLength int) (NamePolicy, error) {
+	policy := NamePolicy{Prefix: prefix, MaxLength: maxLength}
+	if pattern != "" {
+		re, err := regexp.Compile(`^(?:` + pattern + `)$`)
+		if err != nil {
+			return NamePolicy{}, fmt.Errorf("invalid name pattern: %w", err)
+		}
+		policy.Pattern = re
+	}
+	if !validNamePrefix.MatchString(prefix) {
+		return NamePolicy{}, fmt.Errorf("invalid name prefix %q: only lowercase letters, digits and dashes", prefix)
+	}
+	if maxLength < 0 || (maxLength > 0 && maxLength <= len(prefix)) {
+		return NamePolicy{}, fmt.Errorf("invalid maximum name length %d: must be 0 or longer than the prefix", maxLength)
+	}
+	return policy, nil
+}
+
+// check reports why a new workspace may not be named name
+func (p NamePolicy) check(name string) error {
+	if !strings.HasPrefix(name, p.Prefix) {
+		return fmt.Errorf("workspace name %s must start with %s", name, p.Prefix)
+	}
+	if p.MaxLength > 0 && len(name) > p.MaxLength {
+		return fmt.Errorf("workspace name %s is %d characters long, names may have at most %d", name, len(name), p.MaxLength)
+	}
+	if p.Pattern != nil && !p.Pattern.MatchString(name) {
+		return fmt.Errorf("workspace name %s does not match the naming pattern %s", name, strings.TrimSuffix(strings.TrimPrefix(p.Pattern.String(), "^(?:"), ")$"))
+	}
+	return nil
+}
+
+// generate derives a name complying with the policy from base, e.g. ai-api
+// for api with the prefix ai-. Names taken reports are avoided by appending
+// -2, -3 and so on.
+func (p NamePolicy) generate(base string, taken func(string) bool) (string, error) {
+	if base == "" {
+		base = "workspace"
+	}
+	limit := maxNameLength
+	if p.MaxLength > 0 && p.MaxLength < limit {
+		limit = p.MaxLength
+	}
+	for i := 1; i <= 100; i++ {
+		suffix := ""
+		if i > 1 {
+			suffix = "-" + strconv.Itoa(i)
+		}
+		stem := p.Prefix + base
+		if room := limit - len(suffix); len(stem) > room {
+			stem = stem[:room]
+		}
+		name := strings.TrimRight(stem, "-") + suffix
+		if err := p.check(name); err != nil {
+			return "", fmt.Errorf("cannot generate a compliant name: %w", err)
+		}
+		if !taken(name) {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("cannot generate a free name from %s", base)
+}
+
+// sourceBaseName returns the last path element of a source as a workspace
+// name, e.g. cart for github.com/acme/cart.git@main
+func sourceBaseName(source string) string {
+	base := strings.TrimRight(source, "/")
+	if i := strings.Index(base[strings.LastIndex(base, "/")+1:], "@"); i >= 0 {
+		// Drop a branch, commit or PR reference
+		base = base[:strings.LastIndex(base, "/")+1+i]
+	}
+	base = path.Base(strings.ReplaceAll(base, ":", "/"))
+	base = strings.TrimSuffix(base, ".git")
+	return strings.Trim(invalidNameChars.ReplaceAllString(strings.ToLower(base), "-"), "-")
+}
+
+// autoName generates a name for a workspace created from a source of the
+// given type that complies with the policy and none of the workspaces has.
+// Images are named after their repository, e.g. go for
+// mcr.microsoft.com/devcontainers/go:1.
+func (p NamePolicy) autoName(sourceType, source string, workspaces []DevPodWorkspace) (string, error) {
+	if sourceType == sourceImage {
+		source = splitImageRef(source).Repository
+	}
+	existing := make(map[string]bool, len(workspaces))
+	for _, workspace := range workspaces {
+		existing[workspace.ID] = true
+	}
+	return p.generate(sourceBaseName(source), func(name string) bool { return existing[name] })
+}
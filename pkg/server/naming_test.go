@@ -0,0 +1,150 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/protobomb/mcp-server-framework/pkg/transport"
+)
+
+func TestNewNamePolicy(t *testing.T) {
+	policy, err := NewNamePolicy(`[a-z][a-z0-9-]*`, "ai-", 20)
+	if err != nil {
+		t.Fatalf("NewNamePolicy failed: %v", err)
+	}
+	tests := []struct {
+		name string
+		ok   bool
+	}{
+		{"ai-api", true},
+		{"api", false},
+		{"ai-Api", false},
+		{"ai-" + strings.Repeat("x", 18), false},
+	}
+	for _, tt := range tests {
+		if err := policy.check(tt.name); (err == nil) != tt.ok {
+			t.Errorf("check(%s) = %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+	if err := policy.check("api"); err == nil || !strings.Contains(err.Error(), "must start with ai-") {
+		t.Errorf("Expected the prefix to be reported, got %v", err)
+	}
+
+	for _, invalid := range []struct {
+		pattern, prefix string
+		maxLength       int
+	}{{"[a-z", "", 0}, {"", "AI_", 0}, {"", "ai-", 3}, {"", "", -1}} {
+		if _, err := NewNamePolicy(invalid.pattern, invalid.prefix, invalid.maxLength); err == nil {
+			t.Errorf("Expected %+v to be rejected", invalid)
+		}
+	}
+}
+
+func TestNamePolicyAutoName(t *testing.T) {
+	policy := NamePolicy{Prefix: "ai-", MaxLength: 12}
+	workspaces := []DevPodWorkspace{{ID: "ai-api"}, {ID: "ai-api-2"}}
+	tests := []struct {
+		sourceType, source, want string
+	}{
+		{sourceGit, "https://github.com/acme/api.git", "ai-api-3"},
+		{sourceGit, "github.com/acme/Web_App@main", "ai-web-app"},
+		{sourceGit, "github.com/acme/payments-service", "ai-payments"},
+		{sourceLocal, "/home/dev/projects/cart", "ai-cart"},
+		{sourceImage, "mcr.microsoft.com/devcontainers/go:1", "ai-go"},
+		{sourceLocal, "/", "ai-workspace"},
+	}
+	for _, tt := range tests {
+		if got, err := policy.autoName(tt.sourceType, tt.source, workspaces); err != nil || got != tt.want {
+			t.Errorf("autoName(%s) = %q, %v, want %q", tt.source, got, err, tt.want)
+		}
+	}
+
+	// Shortened names leave room for the suffix
+	short := NamePolicy{MaxLength: 8}
+	if got, err := short.autoName(sourceGit, "github.com/acme/frontend", []DevPodWorkspace{{ID: "frontend"}}); err != nil || got != "fronte-2" {
+		t.Errorf("Expected the name to be shortened for its suffix, got %q, %v", got, err)
+	}
+	strict := mustNamePolicy(t, `[a-z]+`)
+	if _, err := strict.autoName(sourceGit, "github.com/acme/web-app", nil); err == nil {
+		t.Error("Expected a name the pattern rules out not to be generated")
+	}
+}
+
+func mustNamePolicy(t *testing.T, pattern string) NamePolicy {
+	t.Helper()
+	policy, err := NewNamePolicy(pattern, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return policy
+}
+
+func TestCreateWorkspaceNamePolicy(t *testing.T) {
+	runner := &fakeRunner{outputs: map[string]string{
+		"list --output json": `[{"id":"ai-api"},{"id":"legacy"}]`,
+	}}
+	s := New(transport.NewSTDIOTransport(), Options{
+		Runner:    runner,
+		StatePath: filepath.Join(t.TempDir(), "state.json"),
+		Names:     NamePolicy{Prefix: "ai-"},
+	})
+	create := s.MCP().GetHandler("devpod_createWorkspace")
+	ctx := context.Background()
+
+	if _, err := create(ctx, json.RawMessage(`{"name":"api","source":"github.com/acme/api"}`)); err == nil || !strings.Contains(err.Error(), "must start with ai-") {
+		t.Errorf("Expected the name to be rejected, got %v", err)
+	}
+	result, err := create(ctx, json.RawMessage(`{"autoName":true,"source":"github.com/acme/api"}`))
+	if err != nil {
+		t.Fatalf("devpod_createWorkspace with autoName failed: %v", err)
+	}
+	if name := result.(map[string]interface{})["name"]; name != "ai-api-2" {
+		t.Errorf("Expected a free compliant name, got %v", name)
+	}
+	if calls := fmt.Sprint(runner.calls); !strings.Contains(calls, "--id ai-api-2") {
+		t.Errorf("Expected the generated name to be created, got calls %v", calls)
+	}
+	// Existing workspaces keep their names
+	if _, err := create(ctx, json.RawMessage(`{"name":"legacy","source":"github.com/acme/legacy","ifExists":"start"}`)); err != nil {
+		t.Errorf("Expected an existing workspace to be started, got %v", err)
+	}
+	if _, err := create(ctx, json.RawMessage(`{"name":"ai-web","autoName":true,"source":"github.com/acme/web"}`)); err == nil {
+		t.Error("Expected name and autoName together to be rejected")
+	}
+}
+
+func TestAutoNamedCreationHoldsTheGeneratedName(t *testing.T) {
+	var s *Server
+	var held []workspaceLock
+	runner := runnerFunc(func(ctx context.Context, args []string) ([]byte, []byte, error) {
+		switch args[0] {
+		case "list":
+			return []byte(`[]`), nil, nil
+		case "up":
+			held = s.locks.list("")
+		}
+		return nil, nil, nil
+	})
+	s = newTestServer(t, runner)
+	// Another creation is holding the first name
+	release, _ := s.locks.acquire(context.Background(), "api", "devpod_createWorkspace", 0)
+	defer release()
+
+	result, err := s.MCP().GetHandler("devpod_createWorkspace")(context.Background(), json.RawMessage(`{"autoName":true,"source":"github.com/acme/api"}`))
+	if err != nil {
+		t.Fatalf("devpod_createWorkspace with autoName failed: %v", err)
+	}
+	if name := result.(map[string]interface{})["name"]; name != "api-2" {
+		t.Errorf("Expected the held name to be skipped, got %v", name)
+	}
+	if len(held) != 2 || held[1].Workspace != "api-2" || held[1].Operation != "devpod_createWorkspace" {
+		t.Errorf("Expected api-2 to be locked while it was created, got %+v", held)
+	}
+	if locks := s.locks.list(""); len(locks) != 1 {
+		t.Errorf("Expected the generated name to be released afterwards, got %+v", locks)
+	}
+}
@@ -0,0 +1,432 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+// maxNetworkTargets bounds the addresses one networkInfo call tests
+const maxNetworkTargets = 10
+
+// networkSection precedes each section of the network probe's output
+const networkSection = "---network:"
+
+// schemePorts are the default ports of URL schemes agents commonly test,
+// such as the connection strings of databases
+var schemePorts = map[string]int{
+	"http":       80,
+	"https":      443,
+	"postgres":   5432,
+	"postgresql": 5432,
+	"mysql":      3306,
+	"redis":      6379,
+	"mongodb":    27017,
+	"amqp":       5672,
+}
+
+// networkTarget is an address whose reachability is tested from inside a
+// workspace
+type networkTarget struct {
+	Raw  string
+	Host string
+	Port int
+	// URL is set for http and https targets, which are also requested
+	URL string
+}
+
+// parseNetworkTarget accepts a URL, such as https://api.example.com or
+// postgres://db:5432/app, or a host:port address
+func parseNetworkTarget(raw string) (networkTarget, error) {
+	target := networkTarget{Raw: raw}
+	if strings.Contains(raw, "://") {
+		parsed, err := url.Parse(raw)
+		if err != nil || parsed.Hostname() == "" {
+			return target, fmt.Errorf("invalid URL %q", raw)
+		}
+		target.Host = parsed.Hostname()
+		target.Port = schemePorts[parsed.Scheme]
+		if port := parsed.Port(); port != "" {
+			target.Port, _ = strconv.Atoi(port)
+		}
+		if target.Port == 0 {
+			return target, fmt.Errorf("%q has no port and %s has no default port", raw, parsed.Scheme)
+		}
+		if parsed.Scheme == "http" || parsed.Scheme == "https" {
+			parsed.User = nil
+			target.URL = parsed.String()
+		}
+	} else {
+		host, port, err := net.SplitHostPort(raw)
+		if err != nil {
+			return target, fmt.Errorf("%q is neither a URL nor a host:port address", raw)
+		}
+		target.Host = host
+		target.Port, _ = strconv.Atoi(port)
+	}
+	if target.Port <= 0 || target.Port > 65535 {
+		return target, fmt.Errorf("%q has an invalid port", raw)
+	}
+	if strings.HasPrefix(target.Host, "-") || strings.ContainsAny(target.Host, " \t\n\"'") {
+		return target, fmt.Errorf("%q has an invalid host", raw)
+	}
+	return target, nil
+}
+
+// networkCommand prints the container's hostname and addresses, its DNS
+// configuration, its listening ports and, for each target, the addresses the
+// host resolves to, whether a TCP connection succeeds and the HTTP status of
+// URLs. Checks whose tool is missing from the image print "unknown".
+func networkCommand(targets []networkTarget) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `echo '%[1]shost---'; echo "hostname=$(hostname 2>/dev/null)"; `+
+		`echo "addresses=$(hostname -I 2>/dev/null || ip -o addr show scope global 2>/dev/null | awk '{sub("/.*","",$4); printf "%%s ", $4}')"; `+
+		`echo '%[1]sdns---'; cat /etc/resolv.conf 2>/dev/null; echo '%[1]sports---'; %[2]s; `, networkSection, portsCommand)
+	for i, target := range targets {
+		host, port := shellQuote(target.Host), strconv.Itoa(target.Port)
+		fmt.Fprintf(&b, `echo '%starget:%d---'; `, networkSection, i)
+		fmt.Fprintf(&b, `if command -v getent >/dev/null 2>&1; then echo "dns=$(getent hosts %s | awk '{printf "%%s ", $1}')"; else echo dns=unknown; fi; `, host)
+		fmt.Fprintf(&b, `if command -v nc >/dev/null 2>&1; then nc -z -w 3 %[1]s %[2]s >/dev/null 2>&1 && echo tcp=ok || echo tcp=fail; `+
+			`elif command -v bash >/dev/null 2>&1 && command -v timeout >/dev/null 2>&1; then timeout 3 bash -c 'exec 3<>"/dev/tcp/$0/$1"' %[1]s %[2]s 2>/dev/null && echo tcp=ok || echo tcp=fail; `+
+			`else echo tcp=unknown; fi; `, host, port)
+		if target.URL != "" {
+			fmt.Fprintf(&b, `if command -v curl >/dev/null 2>&1; then code=$(curl -s -o /dev/null -m 5 -w '%%{http_code} %%{time_total}' %s); echo "curl=$? http=$code"; else echo http=unknown; fi; `, shellQuote(target.URL))
+		}
+	}
+	b.WriteString("true")
+	return b.String()
+}
+
+// dnsConfig is the resolver configuration inside a workspace
+type dnsConfig struct {
+	Nameservers []string `json:"nameservers"`
+	Search      []string `json:"search,omitempty"`
+	Options     []string `json:"options,omitempty"`
+}
+
+// reachability is the outcome of testing a target from inside a workspace
+type reachability struct {
+	Target string `json:"target"`
+	Host   string `json:"host"`
+	Port   int    `json:"port"`
+	// Resolved and Reachable are left out when the image lacks the tools to
+	// check them
+	Resolved  *bool    `json:"resolved,omitempty"`
+	Addresses []string `json:"addresses,omitempty"`
+	// Reachable is whether a TCP connection to the port succeeded
+	Reachable  *bool   `json:"reachable,omitempty"`
+	HTTPStatus int     `json:"httpStatus,omitempty"`
+	LatencyMs  float64 `json:"latencyMs,omitempty"`
+	Error      string  `json:"error,omitempty"`
+	Hint       string  `json:"hint,omitempty"`
+}
+
+// networkInfo is what the network probe found inside a workspace
+type networkInfo struct {
+	Hostname     string
+	Addresses    []string
+	DNS          dnsConfig
+	Ports        []openPort
+	Reachability []reachability
+}
+
+// curlErrors explains the curl exit codes of failed requests
+var curlErrors = map[string]string{
+	"6":  "could not resolve host",
+	"7":  "connection refused",
+	"28": "timed out",
+	"35": "TLS handshake failed",
+	"52": "empty reply from server",
+	"56": "connection reset",
+	"60": "certificate not trusted",
+}
+
+// parseNetworkInfo interprets the output of networkCommand for the targets
+func parseNetworkInfo(output string, targets []networkTarget) networkInfo {
+	sections := make(map[string][]string)
+	current := ""
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if name, ok := strings.CutPrefix(line, networkSection); ok && strings.HasSuffix(name, "---") {
+			current = strings.TrimSuffix(name, "---")
+			sections[current] = []string{}
+			continue
+		}
+		if current != "" {
+			sections[current] = append(sections[current], line)
+		}
+	}
+
+	info := networkInfo{Addresses: []string{}, DNS: dnsConfig{Nameservers: []string{}}, Reachability: []reachability{}}
+	for _, line := range sections["host"] {
+		key, value, _ := strings.Cut(line, "=")
+		switch key {
+		case "hostname":
+			info.Hostname = strings.TrimSpace(value)
+		case "addresses":
+			info.Addresses = append(info.Addresses, strings.Fields(value)...)
+		}
+	}
+	for _, line := range sections["dns"] {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "nameserver":
+			info.DNS.Nameservers = append(info.DNS.Nameservers, fields[1])
+		case "search", "domain":
+			info.DNS.Search = append(info.DNS.Search, fields[1:]...)
+		case "options":
+			info.DNS.Options = append(info.DNS.Options, fields[1:]...)
+		}
+	}
+	info.Ports = parsePorts(strings.Join(sections["ports"], "\n"))
+
+	for i, target := range targets {
+		result := reachability{Target: target.Raw, Host: target.Host, Port: target.Port}
+		for _, line := range sections[fmt.Sprintf("target:%d", i)] {
+			key, value, _ := strings.Cut(line, "=")
+			switch key {
+			case "dns":
+				if value == "unknown" {
+					continue
+				}
+				result.Addresses = strings.Fields(value)
+				resolved := len(result.Addresses) > 0
+				result.Resolved = &resolved
+			case "tcp":
+				if value == "unknown" {
+					continue
+				}
+				reachable := value == "ok"
+				result.Reachable = &reachable
+			case "curl":
+				// curl=<exit> http=<status> <seconds>
+				exit, rest, _ := strings.Cut(value, " http=")
+				fields := strings.Fields(rest)
+				if len(fields) == 2 {
+					result.HTTPStatus, _ = strconv.Atoi(fields[0])
+					if seconds, err := strconv.ParseFloat(fields[1], 64); err == nil && result.HTTPStatus != 0 {
+						result.LatencyMs = float64(int64(seconds*10000)) / 10
+					}
+				}
+				if exit != "0" {
+					result.Error = curlErrors[exit]
+					if result.Error == "" {
+						result.Error = "request failed with curl exit code " + exit
+					}
+				}
+			}
+		}
+		if _, ok := sections[fmt.Sprintf("target:%d", i)]; !ok {
+			result.Error = "not tested"
+		}
+		result.Hint = reachabilityHint(result)
+		info.Reachability = append(info.Reachability, result)
+	}
+	return info
+}
+
+// reachabilityHint suggests the usual cause of a failed test
+func reachabilityHint(result reachability) string {
+	loopback := result.Host == "localhost" || strings.HasPrefix(result.Host, "127.") || result.Host == "::1"
+	switch {
+	case result.Resolved != nil && !*result.Resolved:
+		return "the name does not resolve inside the workspace; containers of another compose project or docker network are not resolvable by name, and host.docker.internal reaches the docker host"
+	case result.Reachable == nil || *result.Reachable:
+		if result.HTTPStatus >= 500 {
+			return "the server is reachable but failing"
+		}
+		return ""
+	case loopback:
+		return "localhost inside the workspace is the container itself; use the service's container name or host.docker.internal to reach a service on another container or the host"
+	default:
+		return fmt.Sprintf("nothing accepts connections on port %d at that address, or a firewall drops them; check that the service listens on 0.0.0.0 rather than 127.0.0.1", result.Port)
+	}
+}
+
+// containerNetwork is a docker network the workspace container is attached to
+type containerNetwork struct {
+	Name        string   `json:"name"`
+	IPAddress   string   `json:"ipAddress,omitempty"`
+	Gateway     string   `json:"gateway,omitempty"`
+	Aliases     []string `json:"aliases,omitempty"`
+	IPv6Address string   `json:"ipv6Address,omitempty"`
+}
+
+// exposedPort is a container port docker exposes, with its bindings on the
+// docker host when it is published
+type exposedPort struct {
+	Port      string   `json:"port"`
+	Published []string `json:"published,omitempty"`
+}
+
+// parseContainerNetworks interprets the NetworkSettings of docker inspect
+func parseContainerNetworks(data []byte) ([]containerNetwork, []exposedPort, error) {
+	var settings struct {
+		Networks map[string]struct {
+			IPAddress         string   `json:"IPAddress"`
+			Gateway           string   `json:"Gateway"`
+			GlobalIPv6Address string   `json:"GlobalIPv6Address"`
+			Aliases           []string `json:"Aliases"`
+		} `json:"Networks"`
+		Ports map[string][]struct {
+			HostIP   string `json:"HostIp"`
+			HostPort string `json:"HostPort"`
+		} `json:"Ports"`
+	}
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse docker inspect output: %w", err)
+	}
+
+	networks := make([]containerNetwork, 0, len(settings.Networks))
+	for name, network := range settings.Networks {
+		networks = append(networks, containerNetwork{
+			Name:        name,
+			IPAddress:   network.IPAddress,
+			Gateway:     network.Gateway,
+			Aliases:     network.Aliases,
+			IPv6Address: network.GlobalIPv6Address,
+		})
+	}
+	sort.Slice(networks, func(i, j int) bool { return networks[i].Name < networks[j].Name })
+
+	ports := make([]exposedPort, 0, len(settings.Ports))
+	for port, bindings := range settings.Ports {
+		exposed := exposedPort{Port: port}
+		for _, binding := range bindings {
+			exposed.Published = append(exposed.Published, net.JoinHostPort(binding.HostIP, binding.HostPort))
+		}
+		ports = append(ports, exposed)
+	}
+	sort.Slice(ports, func(i, j int) bool { return ports[i].Port < ports[j].Port })
+	return networks, ports, nil
+}
+
+// inspectContainerNetworks returns the docker networks and exposed ports of
+// a docker-provider workspace's container
+func (s *Server) inspectContainerNetworks(ctx context.Context, workspace *DevPodWorkspace) ([]containerNetwork, []exposedPort, error) {
+	container, err := s.workspaceContainer(ctx, workspace)
+	if err != nil {
+		return nil, nil, err
+	}
+	output, stderr, err := s.runDocker(ctx, workspace, []string{"inspect", "--format", "{{json .NetworkSettings}}", container})
+	if err != nil {
+		return nil, nil, fmt.Errorf("docker inspect failed: %v: %s", err, strings.TrimSpace(string(stderr)))
+	}
+	return parseContainerNetworks(output)
+}
+
+// registerNetworkTools registers the tool that describes the network of a
+// workspace
+func (s *Server) registerNetworkTools() {
+	// Describe the network of a workspace
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_networkInfo",
+		Description: "Describe the network of a running DevPod workspace: the container's addresses, DNS configuration, listening and exposed ports, and whether given URLs or host:port addresses resolve and accept connections from inside it, e.g. to debug an app that cannot reach its database",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "The name of the workspace",
+				},
+				"targets": map[string]interface{}{
+					"type":        "array",
+					"items":       map[string]interface{}{"type": "string"},
+					"description": fmt.Sprintf("URLs or host:port addresses to test from inside the workspace, e.g. postgres://db:5432 or https://api.example.com (optional, at most %d)", maxNetworkTargets),
+				},
+			},
+			"required": []string{"name"},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var networkParams struct {
+			Name    string   `json:"name"`
+			Targets []string `json:"targets,omitempty"`
+		}
+
+		if err := json.Unmarshal(params, &networkParams); err != nil {
+			return nil, mcp.NewInvalidParamsError("Invalid network info parameters")
+		}
+
+		if networkParams.Name == "" {
+			return nil, mcp.NewInvalidParamsError("Workspace name is required")
+		}
+		if len(networkParams.Targets) > maxNetworkTargets {
+			return nil, mcp.NewInvalidParamsError(fmt.Sprintf("At most %d targets can be tested at once", maxNetworkTargets))
+		}
+		targets := make([]networkTarget, 0, len(networkParams.Targets))
+		for _, raw := range networkParams.Targets {
+			target, err := parseNetworkTarget(raw)
+			if err != nil {
+				return nil, mcp.NewInvalidParamsError(err.Error())
+			}
+			targets = append(targets, target)
+		}
+
+		workspace, err := s.findWorkspace(ctx, networkParams.Name)
+		if err != nil {
+			return nil, newDevPodError("failed to look up workspace", err, nil)
+		}
+		if workspace == nil {
+			return nil, mcp.NewInvalidParamsError(fmt.Sprintf("Workspace %s not found", networkParams.Name))
+		}
+		if err := s.requireRunning(ctx, networkParams.Name); err != nil {
+			return nil, err
+		}
+
+		output, err := s.combinedOutput(ctx, []string{"ssh", networkParams.Name, "--command", networkCommand(targets)})
+		if err != nil {
+			return nil, newDevPodError("failed to inspect the workspace network", err, output)
+		}
+		s.touchWorkspace(ctx, networkParams.Name)
+
+		info := parseNetworkInfo(string(output), targets)
+		markForwarded(info.Ports)
+		result := map[string]interface{}{
+			"name":         networkParams.Name,
+			"hostname":     info.Hostname,
+			"addresses":    info.Addresses,
+			"dns":          info.DNS,
+			"ports":        info.Ports,
+			"reachability": info.Reachability,
+		}
+		var warnings []string
+		if len(info.Addresses) == 0 {
+			warnings = append(warnings, "container addresses not available")
+		}
+		// Only docker knows the networks and published ports of the container
+		if workspace.Provider.Name == "docker" {
+			if networks, exposed, err := s.inspectContainerNetworks(ctx, workspace); err == nil {
+				result["networks"] = networks
+				result["exposedPorts"] = exposed
+			} else {
+				warnings = append(warnings, fmt.Sprintf("docker networks not available: %v", err))
+			}
+		}
+		if len(warnings) > 0 {
+			result["warnings"] = warnings
+		}
+
+		reachable := 0
+		for _, target := range info.Reachability {
+			if target.Reachable != nil && *target.Reachable && target.Error == "" {
+				reachable++
+			}
+		}
+		message := fmt.Sprintf("Found %d address(es) and %d listening port(s)", len(info.Addresses), len(info.Ports))
+		if len(targets) > 0 {
+			message += fmt.Sprintf("; %d of %d target(s) reachable", reachable, len(targets))
+		}
+		result["message"] = message
+		return result, nil
+	})
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseNetworkTarget(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{"postgres://app:secret@db/app", "db:5432"},
+		{"redis://cache:6380", "cache:6380"},
+		{"https://user:pw@api.example.com/health", "api.example.com:443 https://api.example.com/health"},
+		{"[::1]:8080", "::1:8080"},
+		{"db:5432", "db:5432"},
+		{"db", ""},
+		{"ftp://files.example.com", ""},
+		{"-oProxy:22", ""},
+	}
+	for _, tt := range tests {
+		target, err := parseNetworkTarget(tt.raw)
+		got := ""
+		if err == nil {
+			got = fmt.Sprintf("%s:%d", target.Host, target.Port)
+			if target.URL != "" {
+				got += " " + target.URL
+			}
+		}
+		if got != tt.want {
+			t.Errorf("parseNetworkTarget(%q) = %q (%v), want %q", tt.raw, got, err, tt.want)
+		}
+	}
+}
+
+func TestParseNetworkInfo(t *testing.T) {
+	targets := []networkTarget{{Raw: "db:5432", Host: "db", Port: 5432}, {Raw: "localhost:3000", Host: "localhost", Port: 3000}, {Raw: "https://api.example.com", Host: "api.example.com", Port: 443, URL: "https://api.example.com"}}
+	output := strings.Join([]string{
+		"---network:host---", "hostname=4f1c2a", "addresses=172.18.0.3 ",
+		"---network:dns---", "# generated", "nameserver 127.0.0.11", "search corp.example.com", "options ndots:0",
+		"---network:ports---", `LISTEN 0 4096 0.0.0.0:8080 0.0.0.0:* users:(("node",pid=120,fd=20))`,
+		"---network:target:0---", "dns=", "tcp=fail",
+		"---network:target:1---", "dns=127.0.0.1 ", "tcp=fail",
+		"---network:target:2---", "dns=unknown", "tcp=ok", "curl=0 http=503 0.120500",
+	}, "\n")
+
+	info := parseNetworkInfo(output, targets)
+	if info.Hostname != "4f1c2a" || fmt.Sprint(info.Addresses) != "[172.18.0.3]" || fmt.Sprint(info.DNS) != "{[127.0.0.11] [corp.example.com] [ndots:0]}" {
+		t.Errorf("Unexpected host and DNS %+v", info)
+	}
+	if len(info.Ports) != 1 || info.Ports[0].Port != 8080 {
+		t.Errorf("Unexpected ports %+v", info.Ports)
+	}
+	db, local, api := info.Reachability[0], info.Reachability[1], info.Reachability[2]
+	if *db.Resolved || *db.Reachable || !strings.Contains(db.Hint, "does not resolve") {
+		t.Errorf("Expected db not to resolve, got %+v", db)
+	}
+	if !*local.Resolved || *local.Reachable || !strings.Contains(local.Hint, "container itself") {
+		t.Errorf("Expected the localhost hint, got %+v", local)
+	}
+	if api.Resolved != nil || !*api.Reachable || api.HTTPStatus != 503 || api.LatencyMs != 120.5 || !strings.Contains(api.Hint, "failing") {
+		t.Errorf("Unexpected HTTP result %+v", api)
+	}
+}
+
+func TestNetworkInfoTool(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := listener.Addr().String()
+	listener.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	runner := &shellRunner{dir: t.TempDir(), fakeRunner: fakeRunner{outputs: map[string]string{
+		"list --output json":       `[{"id":"api","provider":{"name":"ssh"}}]`,
+		"status api --output json": `{"state":"Running"}`,
+	}}}
+	s := newTestServer(t, runner)
+
+	result, err := s.MCP().GetHandler("devpod_networkInfo")(context.Background(), json.RawMessage(fmt.Sprintf(`{"name":"api","targets":[%q,%q]}`, server.URL, closed)))
+	if err != nil {
+		t.Fatalf("devpod_networkInfo failed: %v", err)
+	}
+	data := result.(map[string]interface{})
+	reachability := data["reachability"].([]reachability)
+	// The checks run where the test does, which may lack nc, bash or curl
+	if open := reachability[0]; open.Error != "" || (open.Reachable != nil && !*open.Reachable) {
+		t.Errorf("Expected %s to be reachable, got %+v", server.URL, open)
+	}
+	if shut := reachability[1]; shut.Reachable != nil && *shut.Reachable {
+		t.Errorf("Expected %s to be unreachable, got %+v", closed, shut)
+	}
+	if data["networks"] != nil || !strings.Contains(data["message"].(string), "target(s) reachable") {
+		t.Errorf("Unexpected result %v", data)
+	}
+
+	if _, err := s.MCP().GetHandler("devpod_networkInfo")(context.Background(), json.RawMessage(`{"name":"api","targets":["db"]}`)); err == nil {
+		t.Error("Expected a target without a port to be rejected")
+	}
+}
@@ -0,0 +1,233 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+// defaultMaxOutputBytes caps the command output in a tool result unless
+// configured otherwise
+const defaultMaxOutputBytes = 16 << 10
+
+// maxStoredOutputBytes bounds the total size of the full outputs kept for
+// devpod_getFullOutput; the oldest are dropped first
+const maxStoredOutputBytes = 16 << 20
+
+// storedOutput is the complete output of a truncated tool result
+type storedOutput struct {
+	ID   string
+	Tool string
+	// User is the authenticated user whose call produced the output, the only
+	// one who may read it
+	User    string
+	Output  string
+	Created time.Time
+}
+
+// outputStore keeps the full outputs of recent truncated results in memory
+type outputStore struct {
+	mu      sync.Mutex
+	outputs map[string]storedOutput
+	order   []string
+	size    int
+}
+
+func newOutputStore() *outputStore {
+	return &outputStore{outputs: make(map[string]storedOutput)}
+}
+
+// newOutputID returns a random output ID, so outputs cannot be guessed
+func newOutputID() string {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return fmt.Sprintf("out-%d", time.Now().UnixNano())
+	}
+	return "out-" + hex.EncodeToString(bytes)
+}
+
+// put stores the output of a user's call and returns its ID, or "" when it
+// is too large to keep
+func (o *outputStore) put(user, tool, output string) string {
+	if len(output) > maxStoredOutputBytes {
+		return ""
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for o.size+len(output) > maxStoredOutputBytes && len(o.order) > 0 {
+		oldest := o.order[0]
+		o.order = o.order[1:]
+		o.size -= len(o.outputs[oldest].Output)
+		delete(o.outputs, oldest)
+	}
+
+	id := newOutputID()
+	o.outputs[id] = storedOutput{ID: id, Tool: tool, User: user, Output: output, Created: time.Now().UTC()}
+	o.order = append(o.order, id)
+	o.size += len(output)
+	return id
+}
+
+// get returns an output stored for a user. Other users' outputs are not
+// found.
+func (o *outputStore) get(user, id string) (storedOutput, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	output, ok := o.outputs[id]
+	if !ok || output.User != user {
+		return storedOutput{}, false
+	}
+	return output, true
+}
+
+// truncateOutput keeps the head and tail of output within maxBytes, where
+// progress and errors usually are, and marks what was left out
+func truncateOutput(output string, maxBytes int) string {
+	if len(output) <= maxBytes {
+		return output
+	}
+	head := maxBytes / 2
+	for head > 0 && !utf8.RuneStart(output[head]) {
+		head--
+	}
+	tail := len(output) - (maxBytes - head)
+	for tail < len(output) && !utf8.RuneStart(output[tail]) {
+		tail++
+	}
+	return fmt.Sprintf("%s\n... [%d bytes truncated] ...\n%s", output[:head], tail-head, output[tail:])
+}
+
+// outputLimit returns the maximum output size of a tool's results; 0 means
+// unlimited
+func (s *Server) outputLimit(tool string) int {
+	limit, ok := s.opts.ToolOutputLimits[tool]
+	if !ok {
+		limit = s.opts.MaxOutputBytes
+	}
+	if limit < 0 {
+		return 0
+	}
+	return limit
+}
+
+// limitOutput truncates the output of a tool result to the tool's limit. The
+// full output is kept for devpod_getFullOutput and the result is marked with
+// truncated, outputBytes and outputId.
+func (s *Server) limitOutput(ctx context.Context, tool string, result interface{}) {
+	fields, ok := result.(map[string]interface{})
+	// devpod_getFullOutput pages through stored outputs itself
+	if !ok || tool == "devpod_getFullOutput" {
+		return
+	}
+	output, ok := fields["output"].(string)
+	limit := s.outputLimit(tool)
+	if !ok || limit == 0 || len(output) <= limit {
+		return
+	}
+
+	fields["output"] = truncateOutput(output, limit)
+	fields["truncated"] = true
+	fields["outputBytes"] = len(output)
+	if id := s.outputs.put(UserName(ctx), tool, output); id != "" {
+		fields["outputId"] = id
+	}
+}
+
+// ParseOutputLimits parses per-tool output limits written as
+// "tool=bytes,tool=bytes"
+func ParseOutputLimits(spec string) (map[string]int, error) {
+	limits := make(map[string]int)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		tool, value, ok := strings.Cut(entry, "=")
+		if !ok || tool == "" {
+			return nil, fmt.Errorf("invalid output limit %q, expected tool=bytes", entry)
+		}
+		limit, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid output limit for %s: %w", tool, err)
+		}
+		limits[tool] = limit
+	}
+	return limits, nil
+}
+
+// registerOutputTools registers the tool that returns stored full outputs
+func (s *Server) registerOutputTools() {
+	// Retrieve the full output of a truncated result
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_getFullOutput",
+		Description: "Retrieve the complete output of an earlier tool result that was truncated, by its outputId",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"id": map[string]interface{}{
+					"type":        "string",
+					"description": "The outputId of the truncated result",
+				},
+				"offset": map[string]interface{}{
+					"type":        "integer",
+					"description": "Byte offset to start from (default: 0)",
+				},
+				"maxBytes": map[string]interface{}{
+					"type":        "integer",
+					"description": "Maximum number of bytes to return (optional, defaults to the rest of the output)",
+				},
+			},
+			"required": []string{"id"},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var outputParams struct {
+			ID       string `json:"id"`
+			Offset   int    `json:"offset,omitempty"`
+			MaxBytes int    `json:"maxBytes,omitempty"`
+		}
+
+		if err := json.Unmarshal(params, &outputParams); err != nil {
+			return nil, mcp.NewInvalidParamsError("Invalid get full output parameters")
+		}
+
+		if outputParams.ID == "" {
+			return nil, mcp.NewInvalidParamsError("Output ID is required")
+		}
+		if outputParams.Offset < 0 || outputParams.MaxBytes < 0 {
+			return nil, mcp.NewInvalidParamsError("offset and maxBytes must not be negative")
+		}
+
+		stored, ok := s.outputs.get(UserName(ctx), outputParams.ID)
+		if !ok {
+			return nil, mcp.NewInvalidParamsError(fmt.Sprintf("Output %s not found; only recent outputs are kept", outputParams.ID))
+		}
+		output := stored.Output
+		if outputParams.Offset > len(output) {
+			outputParams.Offset = len(output)
+		}
+		output = output[outputParams.Offset:]
+		if outputParams.MaxBytes > 0 && len(output) > outputParams.MaxBytes {
+			output = output[:outputParams.MaxBytes]
+		}
+
+		return map[string]interface{}{
+			"id":         stored.ID,
+			"tool":       stored.Tool,
+			"output":     output,
+			"offset":     outputParams.Offset,
+			"totalBytes": len(stored.Output),
+			"hasMore":    outputParams.Offset+len(output) < len(stored.Output),
+			"created":    stored.Created.Format(time.RFC3339),
+		}, nil
+	})
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestTruncateOutput(t *testing.T) {
+	output := "start\n" + strings.Repeat("progress\n", 100) + "error: disk full\n"
+
+	truncated := truncateOutput(output, 40)
+	if !strings.HasPrefix(truncated, "start\n") || !strings.HasSuffix(truncated, "error: disk full\n") {
+		t.Errorf("Expected head and tail to be kept, got %q", truncated)
+	}
+	if !strings.Contains(truncated, "bytes truncated") {
+		t.Errorf("Expected a truncation marker, got %q", truncated)
+	}
+	if got := truncateOutput("short", 40); got != "short" {
+		t.Errorf("Expected short output unchanged, got %q", got)
+	}
+}
+
+func TestParseOutputLimits(t *testing.T) {
+	limits, err := ParseOutputLimits("devpod_ssh=65536, devpod_createWorkspace=-1")
+	if err != nil || limits["devpod_ssh"] != 65536 || limits["devpod_createWorkspace"] != -1 {
+		t.Errorf("Unexpected limits %v (%v)", limits, err)
+	}
+	if _, err := ParseOutputLimits("devpod_ssh"); err == nil {
+		t.Error("Expected an entry without a limit to be rejected")
+	}
+}
+
+func TestToolCallTruncatesOutput(t *testing.T) {
+	output := strings.Repeat("x", 300)
+	runner := &fakeRunner{outputs: map[string]string{"ssh api --command ls": output}}
+	s := newTestServer(t, runner)
+	s.opts.ToolOutputLimits = map[string]int{"devpod_ssh": 100}
+
+	result, err := s.MCP().GetHandler("devpod_ssh")(context.Background(), json.RawMessage(`{"name":"api","command":"ls"}`))
+	if err != nil {
+		t.Fatalf("devpod_ssh failed: %v", err)
+	}
+	s.limitOutput(context.Background(), "devpod_ssh", result)
+	fields := result.(map[string]interface{})
+	if fields["truncated"] != true || fields["outputBytes"] != 300 || len(fields["output"].(string)) > 140 {
+		t.Fatalf("Expected the output to be truncated, got %v", fields)
+	}
+
+	full, err := s.MCP().GetHandler("devpod_getFullOutput")(context.Background(), json.RawMessage(`{"id":"`+fields["outputId"].(string)+`","offset":200}`))
+	if err != nil {
+		t.Fatalf("devpod_getFullOutput failed: %v", err)
+	}
+	page := full.(map[string]interface{})
+	if page["output"] != output[200:] || page["totalBytes"] != 300 || page["hasMore"] != false {
+		t.Errorf("Unexpected full output page %v", page)
+	}
+}
+
+func TestOutputStoreEvictsOldest(t *testing.T) {
+	store := newOutputStore()
+	first := store.put("", "devpod_ssh", strings.Repeat("a", maxStoredOutputBytes/2+1))
+	second := store.put("", "devpod_ssh", strings.Repeat("b", maxStoredOutputBytes/2+1))
+
+	if _, ok := store.get("", first); ok {
+		t.Error("Expected the oldest output to be evicted")
+	}
+	if _, ok := store.get("", second); !ok {
+		t.Error("Expected the newest output to be kept")
+	}
+}
+
+func TestStoredOutputsBelongToTheirUser(t *testing.T) {
+	store := newOutputStore()
+	id := store.put("alice", "devpod_ssh", "alice's output")
+	if !strings.HasPrefix(id, "out-") || len(id) < 20 || id == store.put("alice", "devpod_ssh", "more") {
+		t.Errorf("Expected random output IDs, got %s", id)
+	}
+	if _, ok := store.get("bob", id); ok {
+		t.Error("Expected bob not to read alice's output")
+	}
+	if _, ok := store.get("", id); ok {
+		t.Error("Expected an unauthenticated call not to read alice's output")
+	}
+	if output, ok := store.get("alice", id); !ok || output.Output != "alice's output" {
+		t.Errorf("Expected alice to read her output, got %+v", output)
+	}
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"context"
+	"time"
+)
+
+// createdByMe is the createdBy filter value naming the calling session
+const createdByMe = "@me"
+
+// workspaceCreator records which client created a workspace through the
+// server, so shared deployments can tell whose workspaces are whose
+type workspaceCreator struct {
+	// Session is the client session whose call created the workspace
+	Session string `json:"session,omitempty"`
+	// Client is the clientInfo name the session announced in initialize
+	Client string `json:"client,omitempty"`
+	// User is the authenticated user in multi-user mode
+	User string    `json:"user,omitempty"`
+	Time time.Time `json:"time"`
+}
+
+// creator describes the client making the call of ctx
+func (s *Server) creator(ctx context.Context) *workspaceCreator {
+	return &workspaceCreator{
+		Session: SessionID(ctx),
+		Client:  s.session(ctx).Client,
+		User:    UserName(ctx),
+		Time:    time.Now().UTC(),
+	}
+}
+
+// createdBy reports whether the workspace creator matches the filter: a
+// session ID, client name or user, or @me for the calling session. Workspaces
+// not created through the server match no filter.
+func createdBy(ctx context.Context, creator *workspaceCreator, filter string) bool {
+	if creator == nil {
+		return false
+	}
+	if filter == createdByMe {
+		return creator.Session == SessionID(ctx) && creator.User == UserName(ctx)
+	}
+	return filter == creator.Session || filter == creator.Client || filter == creator.User
+}
+
+// filterCreatedBy returns the workspaces whose creator matches the filter,
+// all of them when it is empty
+func (s *Server) filterCreatedBy(ctx context.Context, workspaces []DevPodWorkspace, filter string) []DevPodWorkspace {
+	if filter == "" {
+		return workspaces
+	}
+	matching := []DevPodWorkspace{}
+	for _, workspace := range workspaces {
+		if createdBy(ctx, s.state(ctx).Creator(workspace.ID), filter) {
+			matching = append(matching, workspace)
+		}
+	}
+	return matching
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCreatedByFilters(t *testing.T) {
+	stale := time.Now().Add(-48 * time.Hour).UTC().Format(time.RFC3339)
+	runner := &fakeRunner{outputs: map[string]string{"list --output json": `[]`}}
+	s := newTestServer(t, runner)
+	agentA := WithSessionID(context.Background(), "session-a")
+	agentB := WithSessionID(context.Background(), "session-b")
+	s.sessions.update("session-a", func(state *sessionState) { state.Client = "agent-a" })
+	s.sessions.update("session-b", func(state *sessionState) { state.Client = "agent-b" })
+
+	create := s.MCP().GetHandler("devpod_createWorkspace")
+	if _, err := create(agentA, json.RawMessage(`{"name":"a-api","source":"github.com/acme/api"}`)); err != nil {
+		t.Fatalf("devpod_createWorkspace failed: %v", err)
+	}
+	if _, err := create(agentB, json.RawMessage(`{"name":"b-api","source":"github.com/acme/api"}`)); err != nil {
+		t.Fatalf("devpod_createWorkspace failed: %v", err)
+	}
+	runner.outputs["list --output json"] = fmt.Sprintf(`[{"id":"a-api","lastUsed":%q},{"id":"b-api","lastUsed":%q},{"id":"manual","lastUsed":%q}]`, stale, stale, stale)
+	if creator := s.store.Creator("a-api"); creator == nil || creator.Session != "session-a" || creator.Client != "agent-a" {
+		t.Errorf("Expected the creator to be recorded, got %+v", creator)
+	}
+
+	list := s.MCP().GetHandler("devpod_listWorkspaces")
+	names := func(ctx context.Context, filter string) string {
+		t.Helper()
+		result, err := list(ctx, json.RawMessage(fmt.Sprintf(`{"createdBy":%q}`, filter)))
+		if err != nil {
+			t.Fatalf("devpod_listWorkspaces failed: %v", err)
+		}
+		var ids []string
+		for _, workspace := range result.(map[string]interface{})["workspaces"].([]DevPodWorkspace) {
+			ids = append(ids, workspace.ID)
+		}
+		return strings.Join(ids, ",")
+	}
+	tests := []struct {
+		ctx    context.Context
+		filter string
+		want   string
+	}{
+		{agentA, "", "a-api,b-api,manual"},
+		{agentA, "@me", "a-api"},
+		{agentB, "@me", "b-api"},
+		{agentA, "agent-b", "b-api"},
+		{agentA, "session-a", "a-api"},
+		{agentA, "nobody", ""},
+	}
+	for _, tt := range tests {
+		if got := names(tt.ctx, tt.filter); got != tt.want {
+			t.Errorf("createdBy %q listed %q, want %q", tt.filter, got, tt.want)
+		}
+	}
+
+	result, err := s.MCP().GetHandler("devpod_gcWorkspaces")(agentB, json.RawMessage(`{"policy":"delete","createdBy":"@me"}`))
+	if err != nil {
+		t.Fatalf("devpod_gcWorkspaces failed: %v", err)
+	}
+	if report := result.(map[string]interface{})["report"].(*gcReport); len(report.Entries) != 1 || report.Entries[0].Name != "b-api" {
+		t.Errorf("Expected only the session's own workspace to be collected, got %+v", report.Entries)
+	}
+	for _, call := range runner.calls {
+		if call[0] == "delete" && call[1] != "b-api" {
+			t.Errorf("Expected only b-api to be deleted, ran %s", strings.Join(call, " "))
+		}
+	}
+	if creator := s.store.Creator("b-api"); creator != nil {
+		t.Errorf("Expected the creator of a deleted workspace to be forgotten, got %+v", creator)
+	}
+}
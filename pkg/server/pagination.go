@@ -0,0 +1,53 @@
+package server
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+)
+
+// pageParams are the cursor and limit arguments accepted by list tools
+type pageParams struct {
+	Cursor string `json:"cursor,omitempty"`
+	Limit  int    `json:"limit,omitempty"`
+}
+
+// enabled reports whether the caller asked for a page rather than everything
+func (p pageParams) enabled() bool {
+	return p.Cursor != "" || p.Limit > 0
+}
+
+// encodeCursor returns the opaque cursor that resumes after key
+func encodeCursor(key string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(key))
+}
+
+// page selects the slice of keys, which must be sorted, that a request
+// covers. It returns the bounds and the cursor of the next page, which is
+// empty on the last page. Keys are names rather than offsets so that pages
+// stay consistent when items are added or removed between calls.
+func (p pageParams) page(keys []string) (int, int, string, error) {
+	if p.Limit < 0 {
+		return 0, 0, "", fmt.Errorf("limit must not be negative")
+	}
+
+	from := 0
+	if p.Cursor != "" {
+		after, err := base64.RawURLEncoding.DecodeString(p.Cursor)
+		if err != nil || len(after) == 0 {
+			return 0, 0, "", fmt.Errorf("invalid cursor")
+		}
+		from = sort.Search(len(keys), func(i int) bool { return keys[i] > string(after) })
+	}
+
+	to := len(keys)
+	if p.Limit > 0 && from+p.Limit < to {
+		to = from + p.Limit
+	}
+
+	next := ""
+	if to < len(keys) {
+		next = encodeCursor(keys[to-1])
+	}
+	return from, to, next, nil
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestPageParams(t *testing.T) {
+	keys := []string{"a", "b", "c", "d", "e"}
+
+	from, to, next, err := pageParams{Limit: 2}.page(keys)
+	if err != nil || from != 0 || to != 2 || next == "" {
+		t.Fatalf("first page = %d, %d, %q, %v", from, to, next, err)
+	}
+	from, to, next, err = pageParams{Cursor: next, Limit: 2}.page(keys)
+	if err != nil || from != 2 || to != 4 || next == "" {
+		t.Fatalf("second page = %d, %d, %q, %v", from, to, next, err)
+	}
+	from, to, next, err = pageParams{Cursor: next, Limit: 2}.page(keys)
+	if err != nil || from != 4 || to != 5 || next != "" {
+		t.Fatalf("last page = %d, %d, %q, %v", from, to, next, err)
+	}
+
+	// A cursor stays valid when the item it points at is removed
+	from, _, _, err = pageParams{Cursor: encodeCursor("b")}.page([]string{"a", "c"})
+	if err != nil || from != 1 {
+		t.Errorf("Expected to resume at c, got %d, %v", from, err)
+	}
+
+	if _, _, _, err := (pageParams{Cursor: "!"}).page(keys); err == nil {
+		t.Error("Expected an error for a malformed cursor")
+	}
+}
+
+func TestListWorkspacesPagination(t *testing.T) {
+	runner := &fakeRunner{outputs: map[string]string{
+		"list --output json": `[{"id":"c"},{"id":"a"},{"id":"b"}]`,
+	}}
+	s := newTestServer(t, runner)
+	handler := s.MCP().GetHandler("devpod_listWorkspaces")
+
+	var names []string
+	cursor := ""
+	for {
+		params, _ := json.Marshal(map[string]interface{}{"cursor": cursor, "limit": 2})
+		result, err := handler(context.Background(), params)
+		if err != nil {
+			t.Fatalf("devpod_listWorkspaces failed: %v", err)
+		}
+		page := result.(map[string]interface{})
+		for _, workspace := range page["workspaces"].([]DevPodWorkspace) {
+			names = append(names, workspace.ID)
+		}
+		next, ok := page["nextCursor"].(string)
+		if !ok {
+			break
+		}
+		cursor = next
+	}
+
+	if len(names) != 3 || names[0] != "a" || names[1] != "b" || names[2] != "c" {
+		t.Errorf("Expected a, b, c across pages, got %v", names)
+	}
+}
@@ -0,0 +1,58 @@
+package server
+
+import "strings"
+
+// Helper function to parse text workspace list output
+func parseTextWorkspaceList(output string) map[string]interface{} {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	workspaces := []map[string]string{}
+
+	for _, line := range lines {
+		if line == "" || strings.HasPrefix(line, "NAME") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) >= 3 {
+			workspace := map[string]string{
+				"name":     fields[0],
+				"status":   fields[1],
+				"provider": fields[2],
+			}
+			if len(fields) > 3 {
+				workspace["ide"] = fields[3]
+			}
+			workspaces = append(workspaces, workspace)
+		}
+	}
+
+	return map[string]interface{}{
+		"workspaces": workspaces,
+	}
+}
+
+// Helper function to parse text provider list output
+func parseTextProviderList(output string) map[string]interface{} {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	providers := []map[string]string{}
+
+	for _, line := range lines {
+		if line == "" || strings.HasPrefix(line, "NAME") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) >= 2 {
+			provider := map[string]string{
+				"name":    fields[0],
+				"version": fields[1],
+			}
+			if len(fields) > 2 && fields[2] == "*" {
+				provider["default"] = "true"
+			}
+			providers = append(providers, provider)
+		}
+	}
+
+	return map[string]interface{}{
+		"providers": providers,
+	}
+}
@@ -0,0 +1,162 @@
+package server
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+// Provisioning phases of `devpod up`, in the order they normally run
+const (
+	phaseClone     = "clone"
+	phaseBuild     = "build"
+	phaseContainer = "container"
+	phaseAgent     = "agent"
+	phaseIDE       = "ide"
+)
+
+// phasePatterns maps lowercase fragments of devpod up log messages to the
+// phase they belong to, checked in order
+var phasePatterns = []struct {
+	phase    string
+	patterns []string
+}{
+	{phaseAgent, []string{"inject", "agent", "setup workspace", "setting up workspace", "credentials server"}},
+	{phaseIDE, []string{"vscode", "openvscode", "jetbrains", "jupyter", "install ide", "ide server", "extension", "starting ide", "in browser mode"}},
+	{phaseClone, []string{"clone", "cloning", "checkout", "git repository", "fetching repository"}},
+	{phaseContainer, []string{"create container", "creating container", "start container", "starting container", "run container", "running container", "starting devcontainer", "start devcontainer", "container started", "docker run", "create devcontainer", "creating devcontainer"}},
+	{phaseBuild, []string{"build", "pulling", "pull image", "resolving image", "resolve image", "prebuild", "dockerfile", "features"}},
+}
+
+// upLogLine matches a devpod log line: a clock time, a level and the message
+var upLogLine = regexp.MustCompile(`^\[?(\d{2}:\d{2}:\d{2})\]?\s+(debug|info|warn|warning|error|fatal|done)\s+(.*)$`)
+
+// dockerBuildStep matches the step lines BuildKit prints during image builds
+var dockerBuildStep = regexp.MustCompile(`^#\d+ `)
+
+// provisioningPhase summarizes one phase of a devpod up run
+type provisioningPhase struct {
+	Name string `json:"name"`
+	// Status is completed, failed or running when the run ended in the phase
+	Status    string `json:"status"`
+	StartTime string `json:"startTime,omitempty"`
+	// DurationMs is only known when devpod logged timestamps
+	DurationMs *int64 `json:"durationMs,omitempty"`
+	Lines      int    `json:"lines"`
+	// LastMessage is the last log message of the phase, usually the error of
+	// a failed phase
+	LastMessage string   `json:"lastMessage,omitempty"`
+	Errors      []string `json:"errors,omitempty"`
+
+	start, end time.Time
+}
+
+// classifyPhase returns the phase a log message belongs to, or "" when it
+// does not indicate one
+func classifyPhase(message string) string {
+	if dockerBuildStep.MatchString(message) {
+		return phaseBuild
+	}
+	lower := strings.ToLower(message)
+	for _, entry := range phasePatterns {
+		for _, pattern := range entry.patterns {
+			if strings.Contains(lower, pattern) {
+				return entry.phase
+			}
+		}
+	}
+	return ""
+}
+
+// parsePhases splits devpod up output into provisioning phases. A line that
+// does not indicate a phase belongs to the current one. The last phase is
+// failed when the command failed; error lines mark their phase failed too.
+func parsePhases(output string, failed bool) []provisioningPhase {
+	var phases []provisioningPhase
+	var lastTime time.Time
+	dayOffset := time.Duration(0)
+
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		message, level := line, ""
+		var at time.Time
+		if match := upLogLine.FindStringSubmatch(line); match != nil {
+			message, level = match[3], match[2]
+			if clock, err := time.Parse("15:04:05", match[1]); err == nil {
+				// Runs that cross midnight start the clock over
+				if !lastTime.IsZero() && clock.Add(dayOffset).Before(lastTime) {
+					dayOffset += 24 * time.Hour
+				}
+				at = clock.Add(dayOffset)
+				lastTime = at
+			}
+		}
+
+		phase := classifyPhase(message)
+		if len(phases) == 0 && phase == "" {
+			continue
+		}
+		if phase != "" && (len(phases) == 0 || phases[len(phases)-1].Name != phase) {
+			if len(phases) > 0 && !at.IsZero() {
+				phases[len(phases)-1].end = at
+			}
+			phases = append(phases, provisioningPhase{Name: phase, Status: "completed", start: at})
+		}
+
+		current := &phases[len(phases)-1]
+		current.Lines++
+		current.LastMessage = message
+		if current.start.IsZero() {
+			current.start = at
+		}
+		if !at.IsZero() {
+			current.end = at
+		}
+		if level == "error" || level == "fatal" {
+			current.Status = "failed"
+			current.Errors = append(current.Errors, message)
+		}
+	}
+
+	for i := range phases {
+		phase := &phases[i]
+		if !phase.start.IsZero() {
+			phase.StartTime = phase.start.Format("15:04:05")
+			duration := phase.end.Sub(phase.start).Milliseconds()
+			phase.DurationMs = &duration
+		}
+	}
+	if failed && len(phases) > 0 {
+		phases[len(phases)-1].Status = "failed"
+	}
+	return phases
+}
+
+// failedPhase returns the name of the first failed phase, or ""
+func failedPhase(phases []provisioningPhase) string {
+	for _, phase := range phases {
+		if phase.Status == "failed" {
+			return phase.Name
+		}
+	}
+	return ""
+}
+
+// withPhases adds the provisioning phases of a failed devpod up run to the
+// data of its error
+func withPhases(rpcErr *mcp.RPCError, output []byte) *mcp.RPCError {
+	data, ok := rpcErr.Data.(map[string]interface{})
+	phases := parsePhases(string(output), true)
+	if !ok || len(phases) == 0 {
+		return rpcErr
+	}
+	data["phases"] = phases
+	data["failedPhase"] = failedPhase(phases)
+	return rpcErr
+}
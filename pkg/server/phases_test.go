@@ -0,0 +1,52 @@
+package server
+
+import (
+	"errors"
+	"testing"
+)
+
+const upOutput = `09:15:02 info Workspace api does not exist yet, creating...
+09:15:03 info Cloning repository github.com/acme/api
+09:15:08 info Resolving image...
+09:15:09 info Building devcontainer...
+#5 [2/4] RUN apt-get update
+09:16:10 info Creating devcontainer...
+09:16:20 info Inject devpod agent into container
+09:16:25 info Setup workspace
+09:16:30 info Starting openvscode in browser mode
+09:16:32 done Successfully started vscode`
+
+func TestParsePhases(t *testing.T) {
+	phases := parsePhases(upOutput, false)
+
+	names := []string{phaseClone, phaseBuild, phaseContainer, phaseAgent, phaseIDE}
+	if len(phases) != len(names) {
+		t.Fatalf("Expected %d phases, got %+v", len(names), phases)
+	}
+	for i, name := range names {
+		if phases[i].Name != name || phases[i].Status != "completed" {
+			t.Errorf("Phase %d: expected completed %s, got %+v", i, name, phases[i])
+		}
+	}
+	if build := phases[1]; build.DurationMs == nil || *build.DurationMs != 62000 || build.Lines != 3 {
+		t.Errorf("Unexpected build phase %+v", build)
+	}
+}
+
+func TestParsePhasesFailure(t *testing.T) {
+	output := `23:59:58 info Building devcontainer...
+00:00:04 error build failed: exit code 100`
+
+	phases := parsePhases(output, true)
+	if len(phases) != 1 || phases[0].Status != "failed" || phases[0].LastMessage != "build failed: exit code 100" {
+		t.Fatalf("Unexpected phases %+v", phases)
+	}
+	if phases[0].DurationMs == nil || *phases[0].DurationMs != 6000 {
+		t.Errorf("Expected the duration across midnight to be 6s, got %v", phases[0].DurationMs)
+	}
+
+	rpcErr := withPhases(newDevPodError("failed to create workspace", errors.New("exit status 1"), []byte(output)), []byte(output))
+	if data := rpcErr.Data.(map[string]interface{}); data["failedPhase"] != phaseBuild {
+		t.Errorf("Expected the build phase to be reported as failed, got %v", data["failedPhase"])
+	}
+}
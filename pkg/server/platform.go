@@ -0,0 +1,118 @@
+package server
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+// platformArchs are the architectures container images are published for
+var platformArchs = map[string]bool{
+	"amd64": true, "arm64": true, "arm": true, "386": true, "ppc64le": true, "s390x": true, "riscv64": true,
+}
+
+// archAliases maps the names uname and distributions use to Go's
+var archAliases = map[string]string{
+	"x86_64":  "amd64",
+	"x86-64":  "amd64",
+	"aarch64": "arm64",
+	"armhf":   "arm/v7",
+	"armel":   "arm/v6",
+	"i386":    "386",
+	"i686":    "386",
+}
+
+// hostPlatform returns the platform containers of the server's machine run
+// as, which is where the docker provider runs workspaces
+func hostPlatform() string {
+	return "linux/" + runtime.GOARCH
+}
+
+// normalizePlatform validates an os/arch[/variant] platform such as
+// linux/arm64 and returns it in the form registries list, accepting aliases
+// such as aarch64 or x86_64
+func normalizePlatform(platform string) (string, error) {
+	parts := strings.Split(strings.ToLower(strings.TrimSpace(platform)), "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" {
+		return "", fmt.Errorf("invalid platform %q: expected os/arch or os/arch/variant, e.g. linux/arm64", platform)
+	}
+	if alias, ok := archAliases[parts[1]]; ok {
+		aliased := strings.Split(alias, "/")
+		parts[1] = aliased[0]
+		if len(aliased) > 1 && len(parts) == 2 {
+			parts = append(parts, aliased[1])
+		}
+	}
+	if !platformArchs[parts[1]] {
+		return "", fmt.Errorf("invalid platform %q: unknown architecture %s", platform, parts[1])
+	}
+	if len(parts) == 3 && parts[2] == "" {
+		parts = parts[:2]
+	}
+	// arm64 images are v8 unless stated otherwise
+	if parts[1] == "arm64" && len(parts) == 3 && parts[2] == "v8" {
+		parts = parts[:2]
+	}
+	return strings.Join(parts, "/"), nil
+}
+
+// normalizePlatforms normalizes a list of platforms
+func normalizePlatforms(platforms []string) ([]string, error) {
+	normalized := make([]string, 0, len(platforms))
+	for _, platform := range platforms {
+		p, err := normalizePlatform(platform)
+		if err != nil {
+			return nil, err
+		}
+		normalized = append(normalized, p)
+	}
+	return normalized, nil
+}
+
+// platformSupported reports whether an image published for the available
+// platforms runs natively as platform. A platform without a variant accepts
+// any variant.
+func platformSupported(platform string, available []string) bool {
+	for _, candidate := range available {
+		candidate, err := normalizePlatform(candidate)
+		if err != nil {
+			continue
+		}
+		if candidate == platform || strings.HasPrefix(candidate, platform+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// platformMismatchError reports that an image is not published for the
+// platform a workspace would run as, with ways around it
+func platformMismatchError(image, platform string, available []string) *mcp.RPCError {
+	sorted := append([]string{}, available...)
+	sort.Strings(sorted)
+	suggestions := []string{fmt.Sprintf("use a multi-arch image that is published for %s", platform)}
+	if len(sorted) > 0 {
+		suggestions = append(suggestions,
+			fmt.Sprintf("set platform to %s to run the image under emulation; the docker host needs QEMU binfmt handlers, e.g. from `docker run --privileged --rm tonistiigi/binfmt --install all`, and runs it markedly slower", sorted[0]),
+			fmt.Sprintf("create the workspace on a provider whose machines are %s", strings.SplitN(sorted[0], "/", 3)[1]))
+	}
+	return mcp.NewRPCError(categoryCodes[CategoryPlatformMismatch],
+		fmt.Sprintf("image %s is not published for %s: %s", image, platform, CategoryPlatformMismatch),
+		map[string]interface{}{
+			"category":           CategoryPlatformMismatch,
+			"image":              image,
+			"platform":           platform,
+			"hostPlatform":       hostPlatform(),
+			"availablePlatforms": sorted,
+			"suggestions":        suggestions,
+		})
+}
+
+// platformEnv returns the environment that makes docker pull and run images
+// as platform
+func platformEnv(platform string) []string {
+	return []string{"DOCKER_DEFAULT_PLATFORM=" + platform}
+}
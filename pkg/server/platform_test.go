@@ -0,0 +1,93 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+func TestNormalizePlatform(t *testing.T) {
+	tests := []struct {
+		platform, want string
+		ok             bool
+	}{
+		{"linux/arm64", "linux/arm64", true},
+		{"Linux/AArch64", "linux/arm64", true},
+		{"linux/x86_64", "linux/amd64", true},
+		{"linux/armhf", "linux/arm/v7", true},
+		{"linux/arm64/v8", "linux/arm64", true},
+		{"arm64", "", false},
+		{"linux/sparc", "", false},
+	}
+	for _, tt := range tests {
+		got, err := normalizePlatform(tt.platform)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("normalizePlatform(%q) = %q, %v; want %q", tt.platform, got, err, tt.want)
+		}
+	}
+}
+
+func TestPlatformSupported(t *testing.T) {
+	available := []string{"linux/amd64", "linux/arm/v7"}
+	if !platformSupported("linux/arm", available) {
+		t.Error("Expected linux/arm to match linux/arm/v7")
+	}
+	if platformSupported("linux/arm64", available) {
+		t.Error("Expected linux/arm64 not to be supported")
+	}
+}
+
+func TestCreateWorkspaceRejectsImageForOtherPlatform(t *testing.T) {
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"manifests":[{"platform":{"os":"linux","architecture":"s390x"}}]}`)
+	}))
+	defer registry.Close()
+	image := strings.TrimPrefix(registry.URL, "http://") + "/team/dev:1.0"
+
+	runner := &fakeRunner{outputs: map[string]string{"list --output json": "[]"}}
+	s := newTestServer(t, runner)
+	args, _ := json.Marshal(map[string]interface{}{"name": "api", "source": image, "verifyImage": true})
+	_, err := s.MCP().GetHandler("devpod_createWorkspace")(context.Background(), args)
+
+	rpcErr, ok := err.(*mcp.RPCError)
+	if !ok || rpcErr.Code != categoryCodes[CategoryPlatformMismatch] {
+		t.Fatalf("Expected a PlatformMismatch error, got %v", err)
+	}
+	data := rpcErr.Data.(map[string]interface{})
+	if data["platform"] != hostPlatform() || len(data["suggestions"].([]string)) != 3 {
+		t.Errorf("Expected the host platform and suggestions, got %v", data)
+	}
+	for _, call := range runner.calls {
+		if call[0] == "up" {
+			t.Error("Expected the workspace not to be created")
+		}
+	}
+}
+
+func TestCreateWorkspacePassesPlatform(t *testing.T) {
+	runner := &envRunner{fakeRunner: fakeRunner{outputs: map[string]string{"list --output json": "[]"}}}
+	s := newTestServer(t, runner)
+	result, err := s.MCP().GetHandler("devpod_createWorkspace")(context.Background(),
+		json.RawMessage(`{"name":"api","source":"ubuntu:22.04","platform":"linux/x86_64"}`))
+	if err != nil {
+		t.Fatalf("devpod_createWorkspace failed: %v", err)
+	}
+	if platform := result.(map[string]interface{})["platform"]; platform != "linux/amd64" {
+		t.Errorf("Expected the normalized platform, got %v", platform)
+	}
+	if !strings.Contains(runner.env, "DOCKER_DEFAULT_PLATFORM=linux/amd64") {
+		t.Errorf("Expected docker to be told the platform, got %v", runner.env)
+	}
+
+	_, err = s.MCP().GetHandler("devpod_createWorkspace")(context.Background(),
+		json.RawMessage(`{"name":"web","source":"ubuntu:22.04","platform":"arm64"}`))
+	if rpcErr, ok := err.(*mcp.RPCError); !ok || rpcErr.Code != mcp.InvalidParams {
+		t.Errorf("Expected an invalid platform to be rejected, got %v", err)
+	}
+}
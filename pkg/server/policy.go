@@ -0,0 +1,335 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"time"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+// defaultPolicyTimeout bounds a policy webhook request
+const defaultPolicyTimeout = 5 * time.Second
+
+// Policy rule effects
+const (
+	policyAllow = "allow"
+	policyDeny  = "deny"
+)
+
+// PolicyOptions authorizes tool calls before they run. A call runs when the
+// rules allow it and the webhook, if any, allows it too.
+type PolicyOptions struct {
+	// Rules are the built-in rules; nil allows every call
+	Rules *PolicyRules
+	// Webhook is a URL every call the rules allow is posted to, e.g. an OPA
+	// decision endpoint such as http://opa:8181/v1/data/devpod/allow
+	Webhook string
+	// Timeout bounds a webhook request (default: 5s). Calls are denied when
+	// the webhook fails or times out.
+	Timeout time.Duration
+	// Confirm asks the user, through the client's elicitation support, to
+	// confirm calls that delete or replace workspaces, secrets, files or
+	// other state once the rules and webhook allow them
+	Confirm bool
+}
+
+// PolicyRules is a rules file: the first rule matching a call decides, and
+// calls no rule matches get the default effect
+type PolicyRules struct {
+	// Default is allow or deny (default: allow)
+	Default string       `json:"default,omitempty"`
+	Rules   []PolicyRule `json:"rules"`
+}
+
+// PolicyRule matches calls by tool, caller and arguments. Patterns use
+// path.Match syntax, e.g. devpod_delete* or prod-*; an empty list matches
+// anything.
+type PolicyRule struct {
+	// Effect is allow or deny
+	Effect string `json:"effect"`
+	// Tools are tool name patterns
+	Tools []string `json:"tools,omitempty"`
+	// Sessions are client session ID patterns; STDIO calls have the session ""
+	Sessions []string `json:"sessions,omitempty"`
+	// Clients are patterns of the clientInfo name announced in initialize
+	Clients []string `json:"clients,omitempty"`
+	// Users are patterns of the authenticated user name; unauthenticated
+	// calls have the user ""
+	Users []string `json:"users,omitempty"`
+	// Arguments maps argument names to patterns their values must match
+	Arguments map[string]string `json:"arguments,omitempty"`
+	// Destructive, when set, matches tools by their destructiveHint
+	Destructive *bool `json:"destructive,omitempty"`
+	// Reason is reported to the client when the rule denies a call
+	Reason string `json:"reason,omitempty"`
+}
+
+// LoadPolicyRules reads a JSON rules file
+func LoadPolicyRules(path string) (*PolicyRules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rules PolicyRules
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse policy file %s: %w", path, err)
+	}
+	if err := rules.validate(); err != nil {
+		return nil, fmt.Errorf("invalid policy file %s: %w", path, err)
+	}
+	return &rules, nil
+}
+
+func (r *PolicyRules) validate() error {
+	if r.Default != "" && r.Default != policyAllow && r.Default != policyDeny {
+		return fmt.Errorf("default must be allow or deny, got %q", r.Default)
+	}
+	for i, rule := range r.Rules {
+		if rule.Effect != policyAllow && rule.Effect != policyDeny {
+			return fmt.Errorf("rule %d: effect must be allow or deny, got %q", i, rule.Effect)
+		}
+		patterns := append(append(append(append([]string{}, rule.Tools...), rule.Sessions...), rule.Clients...), rule.Users...)
+		for _, pattern := range rule.Arguments {
+			patterns = append(patterns, pattern)
+		}
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("rule %d: invalid pattern %q", i, pattern)
+			}
+		}
+	}
+	return nil
+}
+
+// policyInput is what a policy decides on
+type policyInput struct {
+	Tool        string                 `json:"tool"`
+	Arguments   map[string]interface{} `json:"arguments"`
+	Session     string                 `json:"session"`
+	Client      string                 `json:"client,omitempty"`
+	User        string                 `json:"user,omitempty"`
+	Annotations *ToolAnnotations       `json:"annotations,omitempty"`
+}
+
+// policyDecision is the outcome of a policy check
+type policyDecision struct {
+	Allow  bool
+	Reason string
+	// Source is rules or webhook
+	Source string
+	// Rule is the index of the deciding rule, or -1 for the default
+	Rule int
+}
+
+// decide returns the effect of the first rule matching input
+func (r *PolicyRules) decide(input policyInput) policyDecision {
+	for i, rule := range r.Rules {
+		if rule.matches(input) {
+			return policyDecision{Allow: rule.Effect == policyAllow, Reason: rule.Reason, Source: "rules", Rule: i}
+		}
+	}
+	return policyDecision{Allow: r.Default != policyDeny, Reason: "denied by default", Source: "rules", Rule: -1}
+}
+
+func (rule PolicyRule) matches(input policyInput) bool {
+	if !matchesAny(rule.Tools, input.Tool) || !matchesAny(rule.Sessions, input.Session) || !matchesAny(rule.Clients, input.Client) || !matchesAny(rule.Users, input.User) {
+		return false
+	}
+	if rule.Destructive != nil && (input.Annotations == nil || input.Annotations.DestructiveHint != *rule.Destructive) {
+		return false
+	}
+	for name, pattern := range rule.Arguments {
+		value, ok := input.Arguments[name]
+		if !ok {
+			return false
+		}
+		if matched, _ := path.Match(pattern, fmt.Sprint(value)); !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesAny reports whether value matches one of patterns, or patterns is empty
+func matchesAny(patterns []string, value string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, value); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// askWebhook posts input to the policy webhook as {"input": ...}. It accepts
+// OPA's {"result": bool} and {"result": {"allow": bool, "reason": string}}
+// as well as a bare {"allow": bool, "reason": string}.
+func (s *Server) askWebhook(ctx context.Context, input policyInput) (policyDecision, error) {
+	timeout := s.opts.Policy.Timeout
+	if timeout <= 0 {
+		timeout = defaultPolicyTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	body, err := json.Marshal(map[string]interface{}{"input": input})
+	if err != nil {
+		return policyDecision{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.opts.Policy.Webhook, bytes.NewReader(body))
+	if err != nil {
+		return policyDecision{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if traceparent := traceParent(ctx); traceparent != "" {
+		req.Header.Set("traceparent", traceparent)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return policyDecision{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return policyDecision{}, fmt.Errorf("policy webhook responded %s", resp.Status)
+	}
+
+	type verdict struct {
+		Allow  *bool  `json:"allow"`
+		Reason string `json:"reason"`
+	}
+	var response struct {
+		verdict
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return policyDecision{}, fmt.Errorf("invalid policy webhook response: %w", err)
+	}
+	result := response.verdict
+	if len(response.Result) > 0 {
+		var allow bool
+		if err := json.Unmarshal(response.Result, &allow); err == nil {
+			result = verdict{Allow: &allow}
+		} else if err := json.Unmarshal(response.Result, &result); err != nil {
+			return policyDecision{}, fmt.Errorf("invalid policy webhook result: %w", err)
+		}
+	}
+	if result.Allow == nil {
+		return policyDecision{}, fmt.Errorf("policy webhook response has no decision")
+	}
+	return policyDecision{Allow: *result.Allow, Reason: result.Reason, Source: "webhook", Rule: -1}, nil
+}
+
+// authorize checks a call against the rules and then the webhook. A webhook
+// that cannot be asked denies the call.
+func (s *Server) authorize(ctx context.Context, input policyInput) policyDecision {
+	if rules := s.opts.Policy.Rules; rules != nil {
+		if decision := rules.decide(input); !decision.Allow {
+			return decision
+		}
+	}
+	if s.opts.Policy.Webhook == "" {
+		return policyDecision{Allow: true}
+	}
+	decision, err := s.askWebhook(ctx, input)
+	if err != nil {
+		s.reportUserEvent(UserName(ctx), "error", "policy", err)
+		return policyDecision{Reason: "policy webhook unavailable", Source: "webhook", Rule: -1}
+	}
+	return decision
+}
+
+// permissionDeniedError reports a call a policy denied
+func permissionDeniedError(input policyInput, decision policyDecision) *mcp.RPCError {
+	message := fmt.Sprintf("%s denied by policy", input.Tool)
+	if decision.Reason != "" {
+		message += " (" + decision.Reason + ")"
+	}
+	data := map[string]interface{}{
+		"category": CategoryPermissionDenied,
+		"tool":     input.Tool,
+		"session":  input.Session,
+		"source":   decision.Source,
+		"reason":   decision.Reason,
+	}
+	if input.Client != "" {
+		data["client"] = input.Client
+	}
+	if input.User != "" {
+		data["user"] = input.User
+	}
+	if decision.Rule >= 0 {
+		data["rule"] = decision.Rule
+	}
+	return mcp.NewRPCError(categoryCodes[CategoryPermissionDenied], fmt.Sprintf("%s: %s", message, CategoryPermissionDenied), data)
+}
+
+// policyInput describes a call to the policy, with secrets in its arguments
+// masked
+func (s *Server) policyInput(ctx context.Context, tool string, params json.RawMessage) policyInput {
+	input := policyInput{Tool: tool, Session: SessionID(ctx), Client: s.session(ctx).Client, User: UserName(ctx)}
+	// Decoded separately so masking cannot change the arguments the
+	// handler receives
+	_ = json.Unmarshal(params, &input.Arguments)
+	if input.Arguments == nil {
+		input.Arguments = map[string]interface{}{}
+	}
+	maskCapturedSecrets(map[string]interface{}{
+		"params": map[string]interface{}{"name": tool, "arguments": input.Arguments},
+	})
+	if annotations, ok := toolAnnotations[tool]; ok {
+		input.Annotations = &annotations
+	}
+	return input
+}
+
+// checkPolicy returns the error a call the rules or webhook deny fails with,
+// so it can be refused before the user is asked for anything. Rules on an
+// argument the call lacks do not match it.
+func (s *Server) checkPolicy(ctx context.Context, tool string, args map[string]interface{}) error {
+	if s.opts.Policy.Rules == nil && s.opts.Policy.Webhook == "" {
+		return nil
+	}
+	params, err := json.Marshal(args)
+	if err != nil {
+		return err
+	}
+	input := s.policyInput(ctx, tool, params)
+	if decision := s.authorize(ctx, input); !decision.Allow {
+		return permissionDeniedError(input, decision)
+	}
+	return nil
+}
+
+// policyHandler wraps a tool's handler so it only runs when the policy
+// allows the call and, for destructive calls under Confirm, the user
+// confirms it
+func (s *Server) policyHandler(tool string, handler mcp.Handler) mcp.Handler {
+	return func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		if s.opts.Policy.Rules == nil && s.opts.Policy.Webhook == "" && !s.opts.Policy.Confirm {
+			return handler(ctx, params)
+		}
+		input := s.policyInput(ctx, tool, params)
+		if decision := s.authorize(ctx, input); !decision.Allow {
+			return nil, permissionDeniedError(input, decision)
+		}
+		if s.opts.Policy.Confirm {
+			// Asked with the unmasked arguments, which name what is destroyed
+			var args map[string]interface{}
+			_ = json.Unmarshal(params, &args)
+			if action := s.destructiveAction(ctx, tool, args); action != "" {
+				if decision := s.confirmDestructive(ctx, input, action); !decision.Allow {
+					return nil, permissionDeniedError(input, decision)
+				}
+			}
+		}
+		return handler(ctx, params)
+	}
+}
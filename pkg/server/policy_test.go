@@ -0,0 +1,124 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+	"github.com/protobomb/mcp-server-framework/pkg/transport"
+)
+
+func newPolicyServer(t *testing.T, runner Runner, policy PolicyOptions) *Server {
+	t.Helper()
+	return New(transport.NewSTDIOTransport(), Options{
+		Runner:    runner,
+		StatePath: filepath.Join(t.TempDir(), "state.json"),
+		Policy:    policy,
+	})
+}
+
+func TestLoadPolicyRules(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "valid.json")
+	os.WriteFile(valid, []byte(`{"default":"deny","rules":[{"effect":"allow","tools":["devpod_list*"]}]}`), 0o600)
+	rules, err := LoadPolicyRules(valid)
+	if err != nil || len(rules.Rules) != 1 {
+		t.Fatalf("Expected one rule, got %+v, %v", rules, err)
+	}
+
+	for _, content := range []string{
+		`{"rules":[{"effect":"block"}]}`,
+		`{"default":"maybe"}`,
+		`{"rules":[{"effect":"deny","tools":["devpod_[x"]}]}`,
+	} {
+		invalid := filepath.Join(dir, "invalid.json")
+		os.WriteFile(invalid, []byte(content), 0o600)
+		if _, err := LoadPolicyRules(invalid); err == nil {
+			t.Errorf("Expected %s to be rejected", content)
+		}
+	}
+}
+
+func TestPolicyDeniesByClientAndArguments(t *testing.T) {
+	destructive := true
+	runner := &fakeRunner{outputs: map[string]string{"list --output json": "[]"}}
+	s := newPolicyServer(t, runner, PolicyOptions{Rules: &PolicyRules{Rules: []PolicyRule{
+		{Effect: policyDeny, Destructive: &destructive, Clients: []string{"ci-*"}, Reason: "agents cannot delete"},
+		{Effect: policyDeny, Tools: []string{"devpod_stopWorkspace"}, Arguments: map[string]string{"name": "prod-*"}},
+	}}})
+	ctx := WithSessionID(context.Background(), "agent")
+	if _, err := s.MCP().GetHandler("initialize")(ctx, json.RawMessage(`{"clientInfo":{"name":"ci-bot"}}`)); err != nil {
+		t.Fatalf("initialize failed: %v", err)
+	}
+	call := s.MCP().GetHandler("tools/call")
+
+	if _, err := call(ctx, json.RawMessage(`{"name":"devpod_listWorkspaces","arguments":{}}`)); err != nil {
+		t.Fatalf("Expected listWorkspaces to be allowed, got %v", err)
+	}
+
+	_, err := call(ctx, json.RawMessage(`{"name":"devpod_deleteWorkspace","arguments":{"name":"api"}}`))
+	rpcErr, ok := err.(*mcp.RPCError)
+	if !ok || rpcErr.Code != categoryCodes[CategoryPermissionDenied] {
+		t.Fatalf("Expected a PermissionDenied error, got %v", err)
+	}
+	data := rpcErr.Data.(map[string]interface{})
+	if data["client"] != "ci-bot" || data["session"] != "agent" || data["rule"] != 0 || data["reason"] != "agents cannot delete" {
+		t.Errorf("Unexpected error data %v", data)
+	}
+	for _, args := range runner.calls {
+		if args[0] == "delete" {
+			t.Errorf("Expected the denied call not to run devpod, ran %v", args)
+		}
+	}
+
+	// other sessions are not ci clients
+	if _, err := call(context.Background(), json.RawMessage(`{"name":"devpod_deleteWorkspace","arguments":{"name":"api"}}`)); err != nil {
+		t.Errorf("Expected deletion to be allowed for other clients, got %v", err)
+	}
+	if _, err := call(context.Background(), json.RawMessage(`{"name":"devpod_stopWorkspace","arguments":{"name":"prod-api"}}`)); err == nil {
+		t.Error("Expected stopping a prod workspace to be denied")
+	}
+}
+
+func TestPolicyWebhook(t *testing.T) {
+	var inputs []policyInput
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Input policyInput `json:"input"`
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		inputs = append(inputs, request.Input)
+		if request.Input.Tool == "devpod_setSecret" {
+			w.Write([]byte(`{"result":{"allow":false,"reason":"secrets are managed centrally"}}`))
+			return
+		}
+		w.Write([]byte(`{"result":true}`))
+	}))
+	defer webhook.Close()
+
+	s := newPolicyServer(t, &fakeRunner{outputs: map[string]string{"list --output json": "[]"}}, PolicyOptions{Webhook: webhook.URL})
+	call := s.MCP().GetHandler("tools/call")
+
+	if _, err := call(context.Background(), json.RawMessage(`{"name":"devpod_listWorkspaces","arguments":{}}`)); err != nil {
+		t.Fatalf("Expected the webhook to allow listWorkspaces, got %v", err)
+	}
+	_, err := call(context.Background(), json.RawMessage(`{"name":"devpod_setSecret","arguments":{"name":"token","value":"hunter2"}}`))
+	if err == nil || !strings.Contains(err.Error(), "secrets are managed centrally") {
+		t.Fatalf("Expected the webhook to deny setSecret, got %v", err)
+	}
+	if len(inputs) != 2 || inputs[1].Arguments["value"] != redactedText || inputs[1].Annotations == nil {
+		t.Errorf("Expected masked arguments and annotations, got %+v", inputs)
+	}
+
+	// an unreachable webhook denies
+	webhook.Close()
+	if _, err := call(context.Background(), json.RawMessage(`{"name":"devpod_listWorkspaces","arguments":{}}`)); err == nil || !strings.Contains(err.Error(), "webhook unavailable") {
+		t.Errorf("Expected calls to be denied while the webhook is down, got %v", err)
+	}
+}
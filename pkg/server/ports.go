@@ -0,0 +1,262 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+// portsCommand lists listening TCP sockets inside a workspace with the
+// processes owning them, using ss or, in images without it, netstat
+const portsCommand = `ss -ltnpH 2>/dev/null || netstat -ltnp 2>/dev/null`
+
+// ssProcess matches the owning process in ss output, e.g.
+// users:(("node",pid=120,fd=20))
+var ssProcess = regexp.MustCompile(`users:\(\("([^"]+)",pid=(\d+)`)
+
+// netstatProcess matches the owning process in netstat output, e.g. 120/node
+var netstatProcess = regexp.MustCompile(`^(\d+)/(.+)$`)
+
+// forwardDialTimeout bounds the check for a local forward of a port
+const forwardDialTimeout = 300 * time.Millisecond
+
+// openPort is a TCP port listening inside a workspace
+type openPort struct {
+	Port    int    `json:"port"`
+	Address string `json:"address"`
+	// LoopbackOnly is set for ports bound to 127.0.0.1 or ::1 inside the
+	// container, which only tunnels reach
+	LoopbackOnly bool   `json:"loopbackOnly"`
+	Process      string `json:"process,omitempty"`
+	PID          int    `json:"pid,omitempty"`
+	// Forwarded is set when the port accepts connections on this machine's
+	// localhost, usually because devpod forwards it
+	Forwarded bool `json:"forwarded"`
+	// URL is the candidate localhost URL of a forwarded port
+	URL string `json:"url,omitempty"`
+}
+
+// parsePorts interprets the output of portsCommand. Both ss and netstat
+// print the local address in the fourth column; header lines are skipped.
+func parsePorts(output string) []openPort {
+	byPort := make(map[int]openPort)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+		address := fields[3]
+		i := strings.LastIndex(address, ":")
+		if i < 0 {
+			continue
+		}
+		port, err := strconv.Atoi(address[i+1:])
+		if err != nil || port <= 0 {
+			continue
+		}
+		host := strings.Trim(address[:i], "[]")
+		current := openPort{
+			Port:         port,
+			Address:      address,
+			LoopbackOnly: host == "127.0.0.1" || host == "::1" || host == "localhost",
+		}
+		if match := ssProcess.FindStringSubmatch(line); match != nil {
+			current.Process = match[1]
+			current.PID, _ = strconv.Atoi(match[2])
+		} else if match := netstatProcess.FindStringSubmatch(fields[len(fields)-1]); match != nil {
+			current.PID, _ = strconv.Atoi(match[1])
+			current.Process = match[2]
+		}
+
+		// A port bound to IPv4 and IPv6 is listed once, preferring a
+		// wildcard address and a known process
+		if previous, ok := byPort[port]; ok {
+			if current.Process == "" {
+				current.Process, current.PID = previous.Process, previous.PID
+			}
+			if !previous.LoopbackOnly {
+				current.Address, current.LoopbackOnly = previous.Address, false
+			}
+		}
+		byPort[port] = current
+	}
+
+	ports := make([]openPort, 0, len(byPort))
+	for _, port := range byPort {
+		ports = append(ports, port)
+	}
+	sort.Slice(ports, func(i, j int) bool { return ports[i].Port < ports[j].Port })
+	return ports
+}
+
+// markForwarded checks which ports accept connections on localhost and sets
+// their candidate URLs
+func markForwarded(ports []openPort) {
+	var wg sync.WaitGroup
+	for i := range ports {
+		wg.Add(1)
+		go func(port *openPort) {
+			defer wg.Done()
+			address := net.JoinHostPort("localhost", strconv.Itoa(port.Port))
+			conn, err := net.DialTimeout("tcp", address, forwardDialTimeout)
+			if err != nil {
+				return
+			}
+			conn.Close()
+			port.Forwarded = true
+			port.URL = "http://" + address
+		}(&ports[i])
+	}
+	wg.Wait()
+}
+
+// browserURL validates a URL for devpod_openInBrowser. Only http and https
+// URLs are opened, so the tool cannot launch arbitrary handlers.
+func browserURL(raw string) (string, error) {
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Host == "" {
+		return "", fmt.Errorf("invalid URL %q", raw)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return "", fmt.Errorf("only http and https URLs can be opened, got %q", parsed.Scheme)
+	}
+	return parsed.String(), nil
+}
+
+// openURL opens a URL with the desktop's default handler
+func openURL(target string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", target)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", target)
+	default:
+		cmd = exec.Command("xdg-open", target)
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	// Reap the opener without waiting for it
+	go func() { _ = cmd.Wait() }()
+	return nil
+}
+
+// registerPortTools registers the tools that list open ports and open them in a
+// browser
+func (s *Server) registerPortTools() {
+	// List ports listening inside a workspace
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_listOpenPorts",
+		Description: "List the TCP ports listening inside a running DevPod workspace, whether they are forwarded to localhost and their candidate URLs",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "The name of the workspace",
+				},
+			},
+			"required": []string{"name"},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var portsParams struct {
+			Name string `json:"name"`
+		}
+
+		if err := json.Unmarshal(params, &portsParams); err != nil {
+			return nil, mcp.NewInvalidParamsError("Invalid list ports parameters")
+		}
+
+		if portsParams.Name == "" {
+			return nil, mcp.NewInvalidParamsError("Workspace name is required")
+		}
+
+		if err := s.requireRunning(ctx, portsParams.Name); err != nil {
+			return nil, err
+		}
+
+		output, err := s.combinedOutput(ctx, []string{"ssh", portsParams.Name, "--command", portsCommand})
+		if err != nil {
+			return nil, newDevPodError("failed to list workspace ports", err, output)
+		}
+		s.touchWorkspace(ctx, portsParams.Name)
+
+		ports := parsePorts(string(output))
+		markForwarded(ports)
+		urls := []string{}
+		for _, port := range ports {
+			if port.URL != "" {
+				urls = append(urls, port.URL)
+			}
+		}
+		return map[string]interface{}{
+			"name":    portsParams.Name,
+			"ports":   ports,
+			"urls":    urls,
+			"message": fmt.Sprintf("Found %d listening port(s), %d forwarded", len(ports), len(urls)),
+		}, nil
+	})
+
+	// Opening URLs acts on the server's machine, so it is opt-in
+	if s.opts.OpenBrowser {
+		s.RegisterTool(mcp.Tool{
+			Name:        "devpod_openInBrowser",
+			Description: "Open a workspace URL, such as a forwarded dev server, in the default browser of the machine the server runs on",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"url": map[string]interface{}{
+						"type":        "string",
+						"description": "The http or https URL to open",
+					},
+					"port": map[string]interface{}{
+						"type":        "integer",
+						"description": "Open http://localhost:<port> instead of a URL",
+					},
+				},
+			},
+		}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+			var openParams struct {
+				URL  string `json:"url"`
+				Port int    `json:"port"`
+			}
+
+			if err := json.Unmarshal(params, &openParams); err != nil {
+				return nil, mcp.NewInvalidParamsError("Invalid open in browser parameters")
+			}
+
+			target := openParams.URL
+			if target == "" {
+				if openParams.Port <= 0 || openParams.Port > 65535 {
+					return nil, mcp.NewInvalidParamsError("url or a valid port is required")
+				}
+				target = fmt.Sprintf("http://localhost:%d", openParams.Port)
+			}
+			target, err := browserURL(target)
+			if err != nil {
+				return nil, mcp.NewInvalidParamsError(err.Error())
+			}
+
+			if err := s.opts.BrowserOpener(target); err != nil {
+				return nil, fmt.Errorf("failed to open %s: %w", target, err)
+			}
+			return map[string]interface{}{
+				"url":     target,
+				"message": "Opened " + target,
+			}, nil
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/protobomb/mcp-server-framework/pkg/transport"
+)
+
+func TestParsePorts(t *testing.T) {
+	ss := `LISTEN 0 511 0.0.0.0:3000 0.0.0.0:* users:(("node",pid=120,fd=20))
+LISTEN 0 511 [::]:3000 [::]:*
+LISTEN 0 128 127.0.0.1:5432 0.0.0.0:* users:(("postgres",pid=80,fd=5))
+`
+	ports := parsePorts(ss)
+	if len(ports) != 2 {
+		t.Fatalf("Expected 2 ports, got %+v", ports)
+	}
+	if p := ports[0]; p.Port != 3000 || p.Process != "node" || p.PID != 120 || p.LoopbackOnly {
+		t.Errorf("Unexpected port %+v", p)
+	}
+	if p := ports[1]; p.Port != 5432 || !p.LoopbackOnly {
+		t.Errorf("Expected 5432 to be loopback only, got %+v", p)
+	}
+
+	netstat := `Active Internet connections (only servers)
+Proto Recv-Q Send-Q Local Address           Foreign Address         State       PID/Program name
+tcp        0      0 0.0.0.0:8080            0.0.0.0:*               LISTEN      42/python3
+`
+	if ports := parsePorts(netstat); len(ports) != 1 || ports[0].Port != 8080 || ports[0].Process != "python3" || ports[0].PID != 42 {
+		t.Errorf("Unexpected netstat ports %+v", ports)
+	}
+}
+
+func TestListOpenPortsMarksForwards(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen locally: %v", err)
+	}
+	defer listener.Close()
+	forwarded := listener.Addr().(*net.TCPAddr).Port
+
+	runner := &fakeRunner{outputs: map[string]string{
+		"status api --output json":          `{"id":"api","state":"Running"}`,
+		"ssh api --command " + portsCommand: fmt.Sprintf("LISTEN 0 511 0.0.0.0:%d 0.0.0.0:*\n", forwarded),
+	}}
+	s := newTestServer(t, runner)
+
+	result, err := s.MCP().GetHandler("devpod_listOpenPorts")(context.Background(), json.RawMessage(`{"name":"api"}`))
+	if err != nil {
+		t.Fatalf("devpod_listOpenPorts failed: %v", err)
+	}
+	urls := result.(map[string]interface{})["urls"].([]string)
+	if want := fmt.Sprintf("http://localhost:%d", forwarded); len(urls) != 1 || urls[0] != want {
+		t.Errorf("Expected %s, got %v", want, urls)
+	}
+}
+
+func TestOpenInBrowserIsOptIn(t *testing.T) {
+	if newTestServer(t, &fakeRunner{}).MCP().GetHandler("devpod_openInBrowser") != nil {
+		t.Fatal("Expected devpod_openInBrowser to be disabled by default")
+	}
+
+	var opened []string
+	s := New(transport.NewSTDIOTransport(), Options{
+		Runner:      &fakeRunner{},
+		StatePath:   filepath.Join(t.TempDir(), "state.json"),
+		OpenBrowser: true,
+		BrowserOpener: func(url string) error {
+			opened = append(opened, url)
+			return nil
+		},
+	})
+	open := s.MCP().GetHandler("devpod_openInBrowser")
+	if _, err := open(context.Background(), json.RawMessage(`{"port":3000}`)); err != nil {
+		t.Fatalf("devpod_openInBrowser failed: %v", err)
+	}
+	if _, err := open(context.Background(), json.RawMessage(`{"url":"file:///etc/passwd"}`)); err == nil {
+		t.Error("Expected a file URL to be rejected")
+	}
+	if len(opened) != 1 || opened[0] != "http://localhost:3000" {
+		t.Errorf("Unexpected opened URLs %v", opened)
+	}
+}
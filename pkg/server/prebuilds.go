@@ -0,0 +1,329 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+// Prebuild statuses
+const (
+	prebuildRunning     = "running"
+	prebuildSucceeded   = "succeeded"
+	prebuildFailed      = "failed"
+	prebuildCancelled   = "cancelled"
+	prebuildInterrupted = "interrupted"
+)
+
+// maxPrebuildOutput caps the build output kept per prebuild
+const maxPrebuildOutput = 4096
+
+// prebuildJobs tracks the builds running in the background so they can be
+// cancelled
+type prebuildJobs struct {
+	mu      sync.Mutex
+	cancels map[string]context.CancelFunc
+}
+
+func newPrebuildJobs() *prebuildJobs {
+	return &prebuildJobs{cancels: make(map[string]context.CancelFunc)}
+}
+
+// cancel stops a running build and reports whether one was running
+func (j *prebuildJobs) cancel(id string) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	cancel, ok := j.cancels[id]
+	if ok {
+		cancel()
+		delete(j.cancels, id)
+	}
+	return ok
+}
+
+// cancelAll stops every running build
+func (j *prebuildJobs) cancelAll() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for id, cancel := range j.cancels {
+		cancel()
+		delete(j.cancels, id)
+	}
+}
+
+// newPrebuildID returns a random prebuild ID
+func newPrebuildID() string {
+	bytes := make([]byte, 6)
+	if _, err := rand.Read(bytes); err != nil {
+		return fmt.Sprintf("pb-%d", time.Now().UnixNano())
+	}
+	return "pb-" + hex.EncodeToString(bytes)
+}
+
+// buildArgs returns the `devpod build` arguments of a prebuild
+func (build prebuild) buildArgs() []string {
+	args := []string{"build", build.Source, "--repository", build.Repository}
+	for _, platform := range build.Platforms {
+		args = append(args, "--platform", platform)
+	}
+	if build.Provider != "" {
+		args = append(args, "--provider", build.Provider)
+	}
+	return args
+}
+
+// tail returns at most the last n bytes of output
+func tail(output []byte, n int) string {
+	if len(output) > n {
+		output = output[len(output)-n:]
+	}
+	return string(output)
+}
+
+// startPrebuild records a prebuild and runs `devpod build` in the background.
+// The build keeps the session and user of ctx so it runs in the session's
+// context and with the user's DEVPOD_HOME.
+func (s *Server) startPrebuild(ctx context.Context, build prebuild) prebuild {
+	build.ID = newPrebuildID()
+	build.Status = prebuildRunning
+	build.Started = time.Now().UTC()
+	s.state(ctx).SetPrebuild(build)
+
+	buildCtx, cancel := context.WithCancel(WithUser(WithSessionID(context.Background(), SessionID(ctx)), UserName(ctx)))
+	s.prebuilds.mu.Lock()
+	s.prebuilds.cancels[build.ID] = cancel
+	s.prebuilds.mu.Unlock()
+
+	go func(build prebuild) {
+		defer s.prebuilds.cancel(build.ID)
+
+		output, err := s.combinedOutput(buildCtx, build.buildArgs())
+		finished := time.Now().UTC()
+		build.Finished = &finished
+		build.Output = tail(output, maxPrebuildOutput)
+		switch {
+		case buildCtx.Err() != nil:
+			build.Status = prebuildCancelled
+		case err != nil:
+			build.Status = prebuildFailed
+			build.Error = newDevPodError("prebuild failed", err, output).Error()
+			s.reportUserEvent(UserName(ctx), "warning", "prebuild", fmt.Errorf("prebuild %s of %s failed: %w", build.ID, build.Source, err))
+		default:
+			build.Status = prebuildSucceeded
+		}
+
+		// A deleted prebuild stays deleted
+		if _, ok := s.state(ctx).Prebuild(build.ID); ok {
+			s.state(ctx).SetPrebuild(build)
+		}
+	}(build)
+	return build
+}
+
+// registerPrebuildTools registers the tools that manage prebuilt images
+func (s *Server) registerPrebuildTools() {
+	// Trigger prebuild
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_triggerPrebuild",
+		Description: "Build and push the devcontainer image of a repository in the background so later workspaces start from a prebuild",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"source": map[string]interface{}{
+					"type":        "string",
+					"description": "The source repository or path to build",
+				},
+				"repository": map[string]interface{}{
+					"type":        "string",
+					"description": "The image repository to push the prebuild to, e.g. ghcr.io/acme/prebuilds",
+				},
+				"provider": map[string]interface{}{
+					"type":        "string",
+					"description": "The provider to build with (optional)",
+				},
+				"platforms": map[string]interface{}{
+					"type":        "array",
+					"items":       map[string]interface{}{"type": "string"},
+					"description": "Platforms to build for, e.g. linux/amd64 (optional)",
+				},
+			},
+			"required": []string{"source", "repository"},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var buildParams struct {
+			Source     string   `json:"source"`
+			Repository string   `json:"repository"`
+			Provider   string   `json:"provider,omitempty"`
+			Platforms  []string `json:"platforms,omitempty"`
+		}
+
+		if err := json.Unmarshal(params, &buildParams); err != nil {
+			return nil, mcp.NewInvalidParamsError("Invalid trigger prebuild parameters")
+		}
+
+		if buildParams.Source == "" || buildParams.Repository == "" {
+			return nil, mcp.NewInvalidParamsError("Source and repository are required")
+		}
+		platforms, err := normalizePlatforms(buildParams.Platforms)
+		if err != nil {
+			return nil, mcp.NewInvalidParamsError(err.Error())
+		}
+		source, err := s.localSourceArg(ctx, buildParams.Source)
+		if err != nil {
+			return nil, mcp.NewInvalidParamsError(err.Error())
+		}
+		buildParams.Source = source
+		if buildParams.Provider == "" {
+			buildParams.Provider = s.session(ctx).Provider
+		}
+
+		build := s.startPrebuild(ctx, prebuild{
+			Source:     buildParams.Source,
+			Repository: buildParams.Repository,
+			Provider:   buildParams.Provider,
+			Platforms:  platforms,
+		})
+		return map[string]interface{}{
+			"id":       build.ID,
+			"prebuild": build,
+			"message":  fmt.Sprintf("Prebuild %s started; poll devpod_prebuildStatus for the result", build.ID),
+		}, nil
+	})
+
+	// List prebuilds
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_listPrebuilds",
+		Description: "List prebuilds triggered through this server, most recent first",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"source": map[string]interface{}{
+					"type":        "string",
+					"description": "Only list prebuilds of this source (optional)",
+				},
+				"status": map[string]interface{}{
+					"type":        "string",
+					"enum":        []string{"running", "succeeded", "failed", "cancelled", "interrupted"},
+					"description": "Only list prebuilds with this status (optional)",
+				},
+			},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var listParams struct {
+			Source string `json:"source,omitempty"`
+			Status string `json:"status,omitempty"`
+		}
+
+		if len(params) > 0 {
+			if err := json.Unmarshal(params, &listParams); err != nil {
+				return nil, mcp.NewInvalidParamsError("Invalid list prebuilds parameters")
+			}
+		}
+
+		builds := []prebuild{}
+		for _, build := range s.state(ctx).Prebuilds() {
+			if (listParams.Source != "" && build.Source != listParams.Source) || (listParams.Status != "" && build.Status != listParams.Status) {
+				continue
+			}
+			// Keep the list small; the output is available per prebuild
+			build.Output = ""
+			builds = append(builds, build)
+		}
+		return map[string]interface{}{
+			"prebuilds": builds,
+			"count":     len(builds),
+		}, nil
+	})
+
+	// Prebuild status
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_prebuildStatus",
+		Description: "Get the status and output of a prebuild",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"id": map[string]interface{}{
+					"type":        "string",
+					"description": "The prebuild ID returned by devpod_triggerPrebuild",
+				},
+			},
+			"required": []string{"id"},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var statusParams struct {
+			ID string `json:"id"`
+		}
+
+		if err := json.Unmarshal(params, &statusParams); err != nil {
+			return nil, mcp.NewInvalidParamsError("Invalid prebuild status parameters")
+		}
+
+		if statusParams.ID == "" {
+			return nil, mcp.NewInvalidParamsError("Prebuild ID is required")
+		}
+
+		build, ok := s.state(ctx).Prebuild(statusParams.ID)
+		if !ok {
+			return nil, mcp.NewInvalidParamsError(fmt.Sprintf("Prebuild %s not found", statusParams.ID))
+		}
+
+		end := time.Now()
+		if build.Finished != nil {
+			end = *build.Finished
+		}
+		return map[string]interface{}{
+			"prebuild":        build,
+			"durationSeconds": int64(end.Sub(build.Started).Seconds()),
+		}, nil
+	})
+
+	// Delete prebuild
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_deletePrebuild",
+		Description: "Cancel a running prebuild and forget it; pushed images stay in the registry",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"id": map[string]interface{}{
+					"type":        "string",
+					"description": "The prebuild ID",
+				},
+			},
+			"required": []string{"id"},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var deleteParams struct {
+			ID string `json:"id"`
+		}
+
+		if err := json.Unmarshal(params, &deleteParams); err != nil {
+			return nil, mcp.NewInvalidParamsError("Invalid delete prebuild parameters")
+		}
+
+		if deleteParams.ID == "" {
+			return nil, mcp.NewInvalidParamsError("Prebuild ID is required")
+		}
+
+		if _, ok := s.state(ctx).Prebuild(deleteParams.ID); !ok {
+			return nil, mcp.NewInvalidParamsError(fmt.Sprintf("Prebuild %s not found", deleteParams.ID))
+		}
+		s.state(ctx).DeletePrebuild(deleteParams.ID)
+		cancelled := s.prebuilds.cancel(deleteParams.ID)
+
+		message := "Prebuild deleted; its image stays in the registry"
+		if cancelled {
+			message = "Running prebuild cancelled and deleted"
+		}
+		return map[string]interface{}{
+			"id":        deleteParams.ID,
+			"cancelled": cancelled,
+			"message":   message,
+		}, nil
+	})
+}
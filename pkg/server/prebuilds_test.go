@@ -0,0 +1,83 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// waitForPrebuild polls until the prebuild leaves the running state
+func waitForPrebuild(t *testing.T, s *Server, id string) prebuild {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if build, ok := s.store.Prebuild(id); ok && build.Status != prebuildRunning {
+			return build
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Prebuild %s did not finish", id)
+	return prebuild{}
+}
+
+func TestPrebuildLifecycle(t *testing.T) {
+	runner := &fakeRunner{
+		outputs: map[string]string{
+			"build github.com/acme/api --repository ghcr.io/acme/prebuilds --platform linux/amd64": "pushed ghcr.io/acme/prebuilds:devpod-abc",
+		},
+		failures: map[string]string{
+			"build github.com/acme/web --repository ghcr.io/acme/prebuilds": "error: unauthorized",
+		},
+	}
+	s := newTestServer(t, runner)
+
+	trigger := s.MCP().GetHandler("devpod_triggerPrebuild")
+	result, err := trigger(context.Background(), json.RawMessage(`{"source":"github.com/acme/api","repository":"ghcr.io/acme/prebuilds","platforms":["linux/amd64"]}`))
+	if err != nil {
+		t.Fatalf("devpod_triggerPrebuild failed: %v", err)
+	}
+	ok := waitForPrebuild(t, s, result.(map[string]interface{})["id"].(string))
+	if ok.Status != prebuildSucceeded || ok.Finished == nil || ok.Output == "" {
+		t.Errorf("Expected a succeeded prebuild with output, got %+v", ok)
+	}
+
+	result, err = trigger(context.Background(), json.RawMessage(`{"source":"github.com/acme/web","repository":"ghcr.io/acme/prebuilds"}`))
+	if err != nil {
+		t.Fatalf("devpod_triggerPrebuild failed: %v", err)
+	}
+	failed := waitForPrebuild(t, s, result.(map[string]interface{})["id"].(string))
+	if failed.Status != prebuildFailed || failed.Error == "" {
+		t.Errorf("Expected a failed prebuild with an error, got %+v", failed)
+	}
+
+	list, err := s.MCP().GetHandler("devpod_listPrebuilds")(context.Background(), json.RawMessage(`{"status":"failed"}`))
+	if err != nil {
+		t.Fatalf("devpod_listPrebuilds failed: %v", err)
+	}
+	if builds := list.(map[string]interface{})["prebuilds"].([]prebuild); len(builds) != 1 || builds[0].ID != failed.ID {
+		t.Errorf("Expected only the failed prebuild, got %+v", builds)
+	}
+
+	if _, err := s.MCP().GetHandler("devpod_deletePrebuild")(context.Background(), json.RawMessage(`{"id":"`+failed.ID+`"}`)); err != nil {
+		t.Fatalf("devpod_deletePrebuild failed: %v", err)
+	}
+	if _, ok := s.store.Prebuild(failed.ID); ok {
+		t.Error("Expected the prebuild to be forgotten")
+	}
+}
+
+func TestRunningPrebuildsAreInterruptedOnRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	store, _ := openStateStore(path)
+	store.SetPrebuild(prebuild{ID: "pb-1", Status: prebuildRunning})
+
+	reopened, err := openStateStore(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen state store: %v", err)
+	}
+	if build, _ := reopened.Prebuild("pb-1"); build.Status != prebuildInterrupted {
+		t.Errorf("Expected the prebuild to be interrupted, got %s", build.Status)
+	}
+}
@@ -0,0 +1,281 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+// processListCommand lists every process inside a workspace, one per line
+const processListCommand = `ps -eo pid=,ppid=,user=,pcpu=,pmem=,rss=,etimes=,args= 2>/dev/null`
+
+// killSignals are the signals devpod_killProcess may send
+var killSignals = []string{"TERM", "INT", "HUP", "KILL"}
+
+// workspaceProcess is a process running inside a workspace
+type workspaceProcess struct {
+	PID            int     `json:"pid"`
+	PPID           int     `json:"ppid"`
+	User           string  `json:"user"`
+	CPUPercent     float64 `json:"cpuPercent"`
+	MemPercent     float64 `json:"memPercent"`
+	RSSBytes       int64   `json:"rssBytes"`
+	ElapsedSeconds int64   `json:"elapsedSeconds"`
+	Command        string  `json:"command"`
+}
+
+// parseProcesses interprets the output of processListCommand. The ps process
+// itself and the shell running it are left out.
+func parseProcesses(output string) []workspaceProcess {
+	processes := []workspaceProcess{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 8 {
+			continue
+		}
+		pid, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		command := strings.Join(fields[7:], " ")
+		if strings.Contains(command, processListCommand) || strings.HasPrefix(command, "ps -eo pid=") {
+			continue
+		}
+		ppid, _ := strconv.Atoi(fields[1])
+		cpu, _ := strconv.ParseFloat(fields[3], 64)
+		mem, _ := strconv.ParseFloat(fields[4], 64)
+		rss, _ := strconv.ParseInt(fields[5], 10, 64)
+		elapsed, _ := strconv.ParseInt(fields[6], 10, 64)
+		processes = append(processes, workspaceProcess{
+			PID:            pid,
+			PPID:           ppid,
+			User:           fields[2],
+			CPUPercent:     cpu,
+			MemPercent:     mem,
+			RSSBytes:       rss * 1024,
+			ElapsedSeconds: elapsed,
+			Command:        command,
+		})
+	}
+	return processes
+}
+
+// sortProcesses orders processes by cpu, memory or age, busiest or oldest
+// first, and otherwise by PID
+func sortProcesses(processes []workspaceProcess, by string) {
+	sort.SliceStable(processes, func(i, j int) bool {
+		a, b := processes[i], processes[j]
+		switch by {
+		case "cpu":
+			if a.CPUPercent != b.CPUPercent {
+				return a.CPUPercent > b.CPUPercent
+			}
+		case "memory":
+			if a.RSSBytes != b.RSSBytes {
+				return a.RSSBytes > b.RSSBytes
+			}
+		case "age":
+			if a.ElapsedSeconds != b.ElapsedSeconds {
+				return a.ElapsedSeconds > b.ElapsedSeconds
+			}
+		}
+		return a.PID < b.PID
+	})
+}
+
+// requireRunning fails unless the workspace is running. Commands over ssh
+// would otherwise start a stopped workspace as a side effect. A status that
+// cannot be parsed is not held against the workspace.
+func (s *Server) requireRunning(ctx context.Context, name string) error {
+	output, stderr, err := s.run(ctx, []string{"status", name, "--output", "json"})
+	if err != nil {
+		return newDevPodError("failed to get workspace status", err, stderr)
+	}
+	var status struct {
+		State string `json:"state"`
+	}
+	if err := json.Unmarshal(output, &status); err == nil && status.State != "" && status.State != "Running" {
+		return mcp.NewInvalidParamsError(fmt.Sprintf("Workspace %s is not running (state: %s)", name, status.State))
+	}
+	return nil
+}
+
+// killCommand sends a signal to a process and reports whether it is still
+// alive shortly after, so callers know when to escalate to KILL
+func killCommand(pid int, signal string) string {
+	return fmt.Sprintf(`kill -s %s %d && sleep 1 && if kill -0 %d 2>/dev/null; then echo alive; else echo exited; fi`, signal, pid, pid)
+}
+
+// registerProcessTools registers the tools that list and signal processes
+// inside workspaces
+func (s *Server) registerProcessTools() {
+	// List processes inside a workspace
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_listProcesses",
+		Description: "List the processes running inside a DevPod workspace, e.g. to find runaway dev servers or stuck builds",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "The name of the workspace",
+				},
+				"filter": map[string]interface{}{
+					"type":        "string",
+					"description": "Only list processes whose command line contains this text (case-insensitive)",
+				},
+				"sortBy": map[string]interface{}{
+					"type":        "string",
+					"description": "Order by CPU usage, memory usage, age or PID (default: cpu)",
+					"enum":        []string{"cpu", "memory", "age", "pid"},
+				},
+				"limit": map[string]interface{}{
+					"type":        "integer",
+					"description": "Maximum number of processes to return (default: 50)",
+				},
+			},
+			"required": []string{"name"},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var listParams struct {
+			Name   string `json:"name"`
+			Filter string `json:"filter"`
+			SortBy string `json:"sortBy"`
+			Limit  int    `json:"limit"`
+		}
+
+		if err := json.Unmarshal(params, &listParams); err != nil {
+			return nil, mcp.NewInvalidParamsError("Invalid list processes parameters")
+		}
+
+		if listParams.Name == "" {
+			return nil, mcp.NewInvalidParamsError("Workspace name is required")
+		}
+		if listParams.SortBy == "" {
+			listParams.SortBy = "cpu"
+		}
+		if listParams.Limit <= 0 {
+			listParams.Limit = 50
+		}
+
+		if err := s.requireRunning(ctx, listParams.Name); err != nil {
+			return nil, err
+		}
+
+		output, err := s.combinedOutput(ctx, []string{"ssh", listParams.Name, "--command", processListCommand})
+		if err != nil {
+			return nil, newDevPodError("failed to list workspace processes", err, output)
+		}
+		s.touchWorkspace(ctx, listParams.Name)
+
+		processes := parseProcesses(string(output))
+		if listParams.Filter != "" {
+			filter := strings.ToLower(listParams.Filter)
+			matched := processes[:0]
+			for _, process := range processes {
+				if strings.Contains(strings.ToLower(process.Command), filter) {
+					matched = append(matched, process)
+				}
+			}
+			processes = matched
+		}
+		sortProcesses(processes, listParams.SortBy)
+
+		total := len(processes)
+		if len(processes) > listParams.Limit {
+			processes = processes[:listParams.Limit]
+		}
+		return map[string]interface{}{
+			"name":      listParams.Name,
+			"processes": processes,
+			"total":     total,
+			"message":   fmt.Sprintf("Found %d process(es)", total),
+		}, nil
+	})
+
+	// Send a signal to a process inside a workspace
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_killProcess",
+		Description: "Stop a process inside a DevPod workspace by sending it a signal",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "The name of the workspace",
+				},
+				"pid": map[string]interface{}{
+					"type":        "integer",
+					"description": "The ID of the process, as listed by devpod_listProcesses",
+				},
+				"signal": map[string]interface{}{
+					"type":        "string",
+					"description": "The signal to send (default: TERM)",
+					"enum":        killSignals,
+				},
+			},
+			"required": []string{"name", "pid"},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var killParams struct {
+			Name   string `json:"name"`
+			PID    int    `json:"pid"`
+			Signal string `json:"signal"`
+		}
+
+		if err := json.Unmarshal(params, &killParams); err != nil {
+			return nil, mcp.NewInvalidParamsError("Invalid kill process parameters")
+		}
+
+		if killParams.Name == "" {
+			return nil, mcp.NewInvalidParamsError("Workspace name is required")
+		}
+		// PID 1 is the container's init; stopping it stops the workspace
+		if killParams.PID <= 1 {
+			return nil, mcp.NewInvalidParamsError("pid must be a process other than the container init (PID 1)")
+		}
+		if killParams.Signal == "" {
+			killParams.Signal = "TERM"
+		}
+		valid := false
+		for _, signal := range killSignals {
+			if killParams.Signal == signal {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return nil, mcp.NewInvalidParamsError(fmt.Sprintf("signal must be one of: %s", strings.Join(killSignals, ", ")))
+		}
+
+		if err := s.requireRunning(ctx, killParams.Name); err != nil {
+			return nil, err
+		}
+
+		output, err := s.combinedOutput(ctx, []string{"ssh", killParams.Name, "--command", killCommand(killParams.PID, killParams.Signal)})
+		if err != nil {
+			s.state(ctx).RecordEvent(killParams.Name, "error", fmt.Sprintf("kill %d failed: %v", killParams.PID, err))
+			return nil, newDevPodError(fmt.Sprintf("failed to signal process %d", killParams.PID), err, output)
+		}
+		s.state(ctx).RecordEvent(killParams.Name, "command", fmt.Sprintf("Sent SIG%s to process %d", killParams.Signal, killParams.PID))
+		s.touchWorkspace(ctx, killParams.Name)
+
+		exited := strings.Contains(string(output), "exited")
+		message := fmt.Sprintf("Process %d exited after SIG%s", killParams.PID, killParams.Signal)
+		if !exited {
+			message = fmt.Sprintf("Process %d is still running after SIG%s; send KILL to force it", killParams.PID, killParams.Signal)
+		}
+		return map[string]interface{}{
+			"name":    killParams.Name,
+			"pid":     killParams.PID,
+			"signal":  killParams.Signal,
+			"exited":  exited,
+			"message": message,
+		}, nil
+	})
+}
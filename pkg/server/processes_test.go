@@ -0,0 +1,69 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+const processOutput = `    1     0 root      0.0  0.0  1024  3600 /sbin/docker-init -- sleep infinity
+  120     1 vscode   12.5  1.0 51200  600 node server.js
+  340   120 vscode   88.0  4.0 204800 60 npm run build
+  400     1 vscode    0.0  0.0  2048     0 ps -eo pid=,ppid=,user=,pcpu=,pmem=,rss=,etimes=,args=
+`
+
+func TestParseProcesses(t *testing.T) {
+	processes := parseProcesses(processOutput)
+	if len(processes) != 3 {
+		t.Fatalf("Expected 3 processes without ps itself, got %+v", processes)
+	}
+	if p := processes[2]; p.PID != 340 || p.PPID != 120 || p.User != "vscode" || p.RSSBytes != 204800*1024 || p.ElapsedSeconds != 60 || p.Command != "npm run build" {
+		t.Errorf("Unexpected process %+v", p)
+	}
+
+	sortProcesses(processes, "cpu")
+	if processes[0].PID != 340 {
+		t.Errorf("Expected the busiest process first, got %d", processes[0].PID)
+	}
+	sortProcesses(processes, "age")
+	if processes[0].PID != 1 {
+		t.Errorf("Expected the oldest process first, got %d", processes[0].PID)
+	}
+}
+
+func TestListAndKillProcesses(t *testing.T) {
+	runner := &fakeRunner{outputs: map[string]string{
+		"status api --output json":                      `{"id":"api","state":"Running"}`,
+		"ssh api --command " + processListCommand:       processOutput,
+		"ssh api --command " + killCommand(340, "TERM"): "exited\n",
+		"ssh api --command " + killCommand(120, "INT"):  "alive\n",
+	}}
+	s := newTestServer(t, runner)
+
+	result, err := s.MCP().GetHandler("devpod_listProcesses")(context.Background(), json.RawMessage(`{"name":"api","filter":"NPM"}`))
+	if err != nil {
+		t.Fatalf("devpod_listProcesses failed: %v", err)
+	}
+	if processes := result.(map[string]interface{})["processes"].([]workspaceProcess); len(processes) != 1 || processes[0].PID != 340 {
+		t.Errorf("Expected the filtered npm process, got %+v", processes)
+	}
+
+	kill := s.MCP().GetHandler("devpod_killProcess")
+	result, err = kill(context.Background(), json.RawMessage(`{"name":"api","pid":340}`))
+	if err != nil {
+		t.Fatalf("devpod_killProcess failed: %v", err)
+	}
+	if result.(map[string]interface{})["exited"] != true {
+		t.Errorf("Expected the process to have exited, got %v", result)
+	}
+	result, err = kill(context.Background(), json.RawMessage(`{"name":"api","pid":120,"signal":"INT"}`))
+	if err != nil || result.(map[string]interface{})["exited"] != false {
+		t.Errorf("Expected the process to survive SIGINT, got %v, %v", result, err)
+	}
+
+	for _, params := range []string{`{"name":"api","pid":1}`, `{"name":"api","pid":340,"signal":"STOP"}`} {
+		if _, err := kill(context.Background(), json.RawMessage(params)); err == nil {
+			t.Errorf("Expected %s to be rejected", params)
+		}
+	}
+}
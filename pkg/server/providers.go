@@ -0,0 +1,877 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+// DevPodProviderOption describes one option from `devpod provider options`
+type DevPodProviderOption struct {
+	Value       string `json:"value,omitempty"`
+	Default     string `json:"default,omitempty"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+	Password    bool   `json:"password,omitempty"`
+	// Enum lists the allowed values, when the provider restricts them
+	Enum optionEnum `json:"enum,omitempty"`
+}
+
+// optionEnum is the list of allowed option values. DevPod releases print
+// either plain strings or objects with a value and a display name.
+type optionEnum []string
+
+// UnmarshalJSON implements json.Unmarshaler
+func (e *optionEnum) UnmarshalJSON(data []byte) error {
+	var items []json.RawMessage
+	if err := json.Unmarshal(data, &items); err != nil {
+		return err
+	}
+	values := make(optionEnum, 0, len(items))
+	for _, item := range items {
+		var value string
+		if err := json.Unmarshal(item, &value); err != nil {
+			var object struct {
+				Value string `json:"value"`
+			}
+			if err := json.Unmarshal(item, &object); err != nil {
+				return err
+			}
+			value = object.Value
+		}
+		values = append(values, value)
+	}
+	*e = values
+	return nil
+}
+
+var (
+	// errElicitationDeclined is returned when the user declines or cancels a prompt
+	errElicitationDeclined = errors.New("the user declined to provide a value")
+	// errSecretOption is returned for missing password options, which must not be elicited
+	errSecretOption = errors.New("the option is a secret and must be passed in options")
+	// errInvalidOption is returned for options the provider does not accept
+	errInvalidOption = errors.New("invalid provider options")
+)
+
+// providerOptions returns the option schema and current values of a provider
+func (s *Server) providerOptions(ctx context.Context, name string) (map[string]DevPodProviderOption, error) {
+	output, err := s.output(ctx, []string{"provider", "options", name, "--output", "json"})
+	if err != nil {
+		return nil, err
+	}
+
+	var options map[string]DevPodProviderOption
+	if err := json.Unmarshal(output, &options); err != nil {
+		return nil, fmt.Errorf("failed to parse provider options: %w", err)
+	}
+	for _, option := range options {
+		if option.Password {
+			s.redactor.add(option.Value)
+		}
+	}
+	return options, nil
+}
+
+// missingRequiredOptions lists the required options that have neither a value
+// nor a default, in name order
+func missingRequiredOptions(options map[string]DevPodProviderOption, given map[string]string) []string {
+	var missing []string
+	for name, option := range options {
+		if !option.Required || option.Value != "" || option.Default != "" || given[name] != "" {
+			continue
+		}
+		missing = append(missing, name)
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// elicitOption asks the user of the calling session for a single provider
+// option value. The call has passed the policy by then.
+func (s *Server) elicitOption(ctx context.Context, provider, name string, option DevPodProviderOption) (string, error) {
+	property := map[string]interface{}{
+		"type":  "string",
+		"title": name,
+	}
+	if option.Description != "" {
+		property["description"] = option.Description
+	}
+	if option.Default != "" {
+		property["default"] = option.Default
+	}
+
+	raw, err := s.requestClient(ctx, "elicitation/create", map[string]interface{}{
+		"message": fmt.Sprintf("Provider %s needs a value for %s", provider, name),
+		"requestedSchema": map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{name: property},
+			"required":   []string{name},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var result struct {
+		Action  string                 `json:"action"`
+		Content map[string]interface{} `json:"content"`
+	}
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return "", fmt.Errorf("failed to parse elicitation result: %w", err)
+	}
+	if result.Action != "accept" {
+		return "", errElicitationDeclined
+	}
+
+	value := strings.TrimSpace(fmt.Sprint(result.Content[name]))
+	if result.Content[name] == nil || value == "" {
+		return "", errElicitationDeclined
+	}
+	return value, nil
+}
+
+// addProviderChecked adds a provider without configuring it, validates the
+// given options against the options it declares, then configures it. With
+// elicit, the user is asked for each missing required option; without, missing
+// required options fail the call. The provider is removed again when an option
+// is invalid or the user declines a prompt. It returns the command output and
+// the names of the options that were elicited.
+func (s *Server) addProviderChecked(ctx context.Context, name string, options map[string]string, elicit bool) ([]byte, []string, error) {
+	return s.setupProvider(ctx, providerSetup{Name: name, Options: options, Elicit: elicit})
+}
+
+// providerSetup describes a provider to add or reconfigure with setupProvider
+type providerSetup struct {
+	Name    string
+	Options map[string]string
+	// Optional options are only set when the provider declares them
+	Optional map[string]string
+	// Elicit asks the user for missing required options
+	Elicit bool
+	// Installed reconfigures an installed provider instead of adding it; it
+	// is never removed when the setup fails
+	Installed bool
+}
+
+// setupProvider adds a provider unless it is installed, checks the options
+// against the ones it declares, asks for missing required options when
+// elicitation is enabled, and configures it as the default provider like a
+// plain add. A provider it added is removed again when any step fails.
+func (s *Server) setupProvider(ctx context.Context, setup providerSetup) ([]byte, []string, error) {
+	name := setup.Name
+	remove := func() {
+		if !setup.Installed {
+			s.removeProvider(ctx, name)
+		}
+	}
+	if !setup.Installed {
+		if output, err := s.executeDevPodCommandWithDebug(ctx, []string{"provider", "add", name, "--use=false"}); err != nil {
+			return output, nil, err
+		}
+	}
+
+	schema, err := s.providerOptions(ctx, name)
+	if err != nil {
+		remove()
+		return nil, nil, err
+	}
+	values := make(map[string]string, len(setup.Options)+len(setup.Optional))
+	for key, value := range setup.Optional {
+		if _, ok := schema[key]; ok {
+			values[key] = value
+		}
+	}
+	for key, value := range setup.Options {
+		values[key] = value
+	}
+	if err := checkProviderOptions(schema, values); err != nil {
+		remove()
+		return nil, nil, fmt.Errorf("%w: %v", errInvalidOption, err)
+	}
+
+	var elicited []string
+	missing := missingRequiredOptions(schema, values)
+	if !setup.Elicit && len(missing) > 0 {
+		remove()
+		return nil, nil, fmt.Errorf("%w: missing required options %s", errInvalidOption, strings.Join(missing, ", "))
+	}
+	for _, option := range missing {
+		if schema[option].Password {
+			// Secrets must never be collected through elicitation
+			remove()
+			return nil, elicited, fmt.Errorf("option %s: %w", option, errSecretOption)
+		}
+
+		value, err := s.elicitOption(ctx, name, option, schema[option])
+		if err != nil {
+			remove()
+			return nil, elicited, fmt.Errorf("option %s: %w", option, err)
+		}
+		values[option] = value
+		elicited = append(elicited, option)
+	}
+
+	// Apply the options and make the provider the default like a plain add would
+	args := []string{"provider", "use", name}
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if schema[key].Password {
+			s.redactor.add(values[key])
+		}
+		args = append(args, "-o", fmt.Sprintf("%s=%s", key, values[key]))
+	}
+	output, err := s.executeDevPodCommandWithDebug(ctx, args)
+	if err != nil {
+		remove()
+	}
+	return output, elicited, err
+}
+
+// removeProvider deletes a partially configured provider, logging failures
+func (s *Server) removeProvider(ctx context.Context, name string) {
+	if _, err := s.combinedOutput(ctx, []string{"provider", "delete", name}); err != nil {
+		log.Printf("WARNING: failed to remove provider %s: %v", name, err)
+	}
+}
+
+// providerSchemaTTL is how long the option schemas of installed providers
+// are reused for tools/list
+const providerSchemaTTL = time.Minute
+
+// providerSchemaCache holds the option schemas of the installed providers
+type providerSchemaCache struct {
+	mu      sync.Mutex
+	fetched time.Time
+	schemas map[string]map[string]DevPodProviderOption
+	// known holds the schemas of single providers looked up to validate
+	// options
+	known map[string]knownOptions
+}
+
+// knownOptions is the option schema of one provider and when it was read
+type knownOptions struct {
+	fetched time.Time
+	options map[string]DevPodProviderOption
+}
+
+// invalidate makes the next lookup fetch the schemas again
+func (c *providerSchemaCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.fetched = time.Time{}
+	c.known = nil
+}
+
+// knownProviderOptions returns the options a provider declares, from the
+// cached schemas when they are fresh
+func (s *Server) knownProviderOptions(ctx context.Context, name string) (map[string]DevPodProviderOption, error) {
+	cache := &s.providerSchemaCache
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if options, ok := cache.schemas[name]; ok && time.Since(cache.fetched) < providerSchemaTTL {
+		return options, nil
+	}
+	if known, ok := cache.known[name]; ok && time.Since(known.fetched) < providerSchemaTTL {
+		return known.options, nil
+	}
+
+	options, err := s.providerOptions(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if cache.known == nil {
+		cache.known = make(map[string]knownOptions)
+	}
+	cache.known[name] = knownOptions{fetched: time.Now(), options: options}
+	return options, nil
+}
+
+// providerSchemas returns the option schemas of all installed providers by
+// provider name. Providers whose options cannot be read are left out.
+func (s *Server) providerSchemas(ctx context.Context) map[string]map[string]DevPodProviderOption {
+	s.providerSchemaCache.mu.Lock()
+	defer s.providerSchemaCache.mu.Unlock()
+	cache := &s.providerSchemaCache
+	if cache.schemas != nil && time.Since(cache.fetched) < providerSchemaTTL {
+		return cache.schemas
+	}
+
+	schemas := make(map[string]map[string]DevPodProviderOption)
+	output, err := s.output(ctx, []string{"provider", "list", "--output", "json"})
+	var providers map[string]DevPodProvider
+	if err == nil {
+		err = json.Unmarshal(output, &providers)
+	}
+	if err != nil {
+		log.Printf("WARNING: failed to list providers for option schemas: %v", err)
+	}
+	for name := range providers {
+		options, err := s.providerOptions(ctx, name)
+		if err != nil {
+			log.Printf("WARNING: failed to read %s provider options: %v", name, err)
+			continue
+		}
+		schemas[name] = options
+	}
+	cache.schemas = schemas
+	cache.fetched = time.Now()
+	return schemas
+}
+
+// providerOptionProperties describes the options of the installed providers
+// as JSON schema properties. Options shared by providers are merged; their
+// description names every provider that has them.
+func providerOptionProperties(schemas map[string]map[string]DevPodProviderOption) map[string]interface{} {
+	providerNames := make([]string, 0, len(schemas))
+	for name := range schemas {
+		providerNames = append(providerNames, name)
+	}
+	sort.Strings(providerNames)
+
+	properties := make(map[string]interface{})
+	descriptions := make(map[string][]string)
+	for _, provider := range providerNames {
+		for name, option := range schemas[provider] {
+			property, ok := properties[name].(map[string]interface{})
+			if !ok {
+				property = map[string]interface{}{"type": "string"}
+				properties[name] = property
+			}
+			description := provider
+			if option.Description != "" {
+				description += ": " + option.Description
+			}
+			var notes []string
+			if option.Required {
+				notes = append(notes, "required")
+			}
+			if option.Password {
+				notes = append(notes, "secret")
+			}
+			if option.Default != "" {
+				notes = append(notes, "default "+option.Default)
+			}
+			if len(notes) > 0 {
+				description += " (" + strings.Join(notes, ", ") + ")"
+			}
+			descriptions[name] = append(descriptions[name], description)
+			// Enums only hold when every provider with the option agrees
+			if len(option.Enum) > 0 && len(descriptions[name]) == 1 {
+				property["enum"] = []string(option.Enum)
+			} else if len(descriptions[name]) > 1 {
+				delete(property, "enum")
+			}
+		}
+	}
+	for name, property := range properties {
+		property.(map[string]interface{})["description"] = strings.Join(descriptions[name], "; ")
+	}
+	return properties
+}
+
+// providerOptionTools are the tools whose options property is described by
+// the option schemas of the installed providers
+var providerOptionTools = map[string]bool{
+	"devpod_addProvider":        true,
+	"devpod_setProviderOptions": true,
+}
+
+// withProviderSchemas returns the tool listings with the options of the
+// installed providers filled into the tools that take provider options. The
+// registered listings are not modified.
+func (s *Server) withProviderSchemas(ctx context.Context, tools []mcp.Tool) []mcp.Tool {
+	var schemas map[string]map[string]DevPodProviderOption
+	for i, tool := range tools {
+		if !providerOptionTools[tool.Name] {
+			continue
+		}
+		if schemas == nil {
+			schemas = s.providerSchemas(ctx)
+		}
+		if len(schemas) == 0 {
+			return tools
+		}
+
+		properties, _ := tool.InputSchema["properties"].(map[string]interface{})
+		inputSchema := make(map[string]interface{}, len(tool.InputSchema))
+		for key, value := range tool.InputSchema {
+			inputSchema[key] = value
+		}
+		copied := make(map[string]interface{}, len(properties))
+		for key, value := range properties {
+			copied[key] = value
+		}
+		options := map[string]interface{}{
+			"type":                 "object",
+			"description":          "Provider-specific options. The properties list the options of the installed providers.",
+			"properties":           providerOptionProperties(schemas),
+			"additionalProperties": map[string]interface{}{"type": "string"},
+		}
+		copied["options"] = options
+		// Options can only be set on installed providers
+		if tool.Name == "devpod_setProviderOptions" {
+			names := make([]string, 0, len(schemas))
+			for name := range schemas {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			name, _ := copied["name"].(map[string]interface{})
+			enumerated := map[string]interface{}{"enum": names}
+			for key, value := range name {
+				if key != "enum" {
+					enumerated[key] = value
+				}
+			}
+			copied["name"] = enumerated
+		}
+		inputSchema["properties"] = copied
+		tool.InputSchema = inputSchema
+		tools[i] = tool
+	}
+	return tools
+}
+
+// checkProviderOptions validates option names and enum values against a
+// provider's schema. Every unknown option is reported, with the option that
+// was probably meant when one is close.
+func checkProviderOptions(schema map[string]DevPodProviderOption, options map[string]string) error {
+	names := make([]string, 0, len(options))
+	for name := range options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	known := make([]string, 0, len(schema))
+	for name := range schema {
+		known = append(known, name)
+	}
+	sort.Strings(known)
+
+	var unknown []string
+	for _, name := range names {
+		if _, ok := schema[name]; ok {
+			continue
+		}
+		if suggestion := suggestOption(name, known); suggestion != "" {
+			name += fmt.Sprintf(" (did you mean %s?)", suggestion)
+		}
+		unknown = append(unknown, name)
+	}
+	if len(unknown) == 1 {
+		return fmt.Errorf("unknown option %s; available: %s", unknown[0], strings.Join(known, ", "))
+	}
+	if len(unknown) > 1 {
+		return fmt.Errorf("unknown options %s; available: %s", strings.Join(unknown, ", "), strings.Join(known, ", "))
+	}
+
+	for _, name := range names {
+		option := schema[name]
+		if len(option.Enum) == 0 {
+			continue
+		}
+		valid := false
+		for _, allowed := range option.Enum {
+			if options[name] == allowed {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return fmt.Errorf("option %s must be one of: %s", name, strings.Join(option.Enum, ", "))
+		}
+	}
+	return nil
+}
+
+// suggestOption returns the known option closest to an unknown one: the same
+// name in another case, a name that only adds or drops a provider prefix such
+// as AWS_, or one within a few typos. It returns "" when none is close.
+func suggestOption(name string, known []string) string {
+	upper := strings.ToUpper(name)
+	for _, option := range known {
+		if option == upper || strings.HasSuffix(option, "_"+upper) || strings.HasSuffix(upper, "_"+option) {
+			return option
+		}
+	}
+
+	best, bestDistance := "", len(upper)/3+1
+	for _, option := range known {
+		if distance := editDistance(upper, option); distance < bestDistance {
+			best, bestDistance = option, distance
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = previous[j-1] + cost
+			if previous[j]+1 < current[j] {
+				current[j] = previous[j] + 1
+			}
+			if current[j-1]+1 < current[j] {
+				current[j] = current[j-1] + 1
+			}
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+// registerProviderTools registers the tools that list, add and configure
+// providers
+func (s *Server) registerProviderTools() {
+	// List providers
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_listProviders",
+		Description: "List all DevPod providers",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"cursor": map[string]interface{}{
+					"type":        "string",
+					"description": "The nextCursor of a previous page (optional)",
+				},
+				"limit": map[string]interface{}{
+					"type":        "integer",
+					"description": "Maximum number of providers to return; all are returned when omitted",
+				},
+			},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var pageParams pageParams
+		if len(params) > 0 {
+			if err := json.Unmarshal(params, &pageParams); err != nil {
+				return nil, mcp.NewInvalidParamsError("Invalid list providers parameters")
+			}
+		}
+
+		output, err := s.executeDevPodCommandWithDebug(ctx, []string{"provider", "list", "--output", "json"})
+		if err != nil {
+			log.Printf("ERROR: devpod_listProviders failed: %v", err)
+			return nil, newDevPodError("failed to list providers", err, nil)
+		}
+
+		// DevPod provider list returns an object with provider names as keys
+		var providersMap map[string]DevPodProvider
+		if err := json.Unmarshal(output, &providersMap); err != nil {
+			if s.opts.StrictJSON {
+				return nil, unsupportedOutputError("provider list", err)
+			}
+			debugf("JSON parsing failed, trying text parsing. Error: %v", err)
+			// If JSON parsing fails, try to parse the text output
+			textResult := parseTextProviderList(string(output))
+			result := map[string]interface{}{
+				"providers": textResult,
+			}
+			debugf("devpod_listProviders returning text-parsed result: %v", result)
+			return result, nil
+		}
+
+		result := map[string]interface{}{
+			"providers": providersMap,
+		}
+		if pageParams.enabled() {
+			names := make([]string, 0, len(providersMap))
+			for name := range providersMap {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			from, to, next, err := pageParams.page(names)
+			if err != nil {
+				return nil, mcp.NewInvalidParamsError(err.Error())
+			}
+			page := make(map[string]DevPodProvider, to-from)
+			for _, name := range names[from:to] {
+				page[name] = providersMap[name]
+			}
+			result["providers"] = page
+			result["total"] = len(providersMap)
+			if next != "" {
+				result["nextCursor"] = next
+			}
+		}
+		debugf("devpod_listProviders returning JSON-parsed result: %v", result)
+		return result, nil
+	})
+
+	// Add provider
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_addProvider",
+		Description: "Add a new DevPod provider",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "The name of the provider",
+				},
+				"options": map[string]interface{}{
+					"type":        "object",
+					"description": "Provider-specific options",
+				},
+			},
+			"required": []string{"name"},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		debugf("devpod_addProvider called with params: %s", s.redactor.redact(string(params)))
+
+		var addParams struct {
+			Name    string            `json:"name"`
+			Options map[string]string `json:"options,omitempty"`
+		}
+
+		if err := json.Unmarshal(params, &addParams); err != nil {
+			log.Printf("ERROR: Failed to unmarshal addProvider params: %v", err)
+			return nil, mcp.NewInvalidParamsError("Invalid add provider parameters")
+		}
+
+		if addParams.Name == "" {
+			log.Printf("ERROR: Provider name is required")
+			return nil, mcp.NewInvalidParamsError("Provider name is required")
+		}
+
+		args := []string{"provider", "add", addParams.Name}
+		for key, value := range addParams.Options {
+			args = append(args, "-o", fmt.Sprintf("%s=%s", key, value))
+		}
+
+		debugf("Executing devpod provider add with args: %s", s.redactor.redact(fmt.Sprint(args)))
+
+		start := time.Now()
+		var output []byte
+		var elicited []string
+		var err error
+		if elicit := s.capabilities(ctx).Elicitation != nil; elicit || len(addParams.Options) > 0 {
+			// Check the options against the ones the provider declares and,
+			// when the client can, ask the user for missing required options
+			// instead of failing
+			output, elicited, err = s.addProviderChecked(ctx, addParams.Name, addParams.Options, elicit)
+		} else {
+			output, err = s.executeDevPodCommandWithDebug(ctx, args)
+		}
+		if err != nil {
+			log.Printf("ERROR: devpod_addProvider failed: %v", err)
+			var cmdErr *CommandError
+			switch {
+			case errors.Is(err, errElicitationDeclined), errors.Is(err, errSecretOption), errors.Is(err, errInvalidOption):
+				return nil, mcp.NewInvalidParamsError(fmt.Sprintf("Provider %s was not added: %v", addParams.Name, err))
+			case errors.As(err, &cmdErr):
+				return nil, newDevPodError("failed to add provider", err, output)
+			default:
+				return nil, fmt.Errorf("failed to add provider: %w", err)
+			}
+		}
+
+		s.providerSchemaCache.invalidate()
+
+		result := map[string]interface{}{
+			"name":       addParams.Name,
+			"message":    "Provider added successfully",
+			"output":     string(output),
+			"durationMs": durationMs(start),
+		}
+		if len(elicited) > 0 {
+			result["elicitedOptions"] = elicited
+		}
+
+		debugf("devpod_addProvider returning result: %v", result)
+		return result, nil
+	})
+
+	// Set provider options
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_setProviderOptions",
+		Description: "Change options of an installed DevPod provider. The options property lists the options the installed providers accept.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "The name of the provider",
+				},
+				"options": map[string]interface{}{
+					"type":        "object",
+					"description": "Provider-specific options",
+				},
+			},
+			"required": []string{"name", "options"},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var setParams struct {
+			Name    string            `json:"name"`
+			Options map[string]string `json:"options"`
+		}
+
+		if err := json.Unmarshal(params, &setParams); err != nil {
+			return nil, mcp.NewInvalidParamsError("Invalid set provider options parameters")
+		}
+
+		if setParams.Name == "" {
+			return nil, mcp.NewInvalidParamsError("Provider name is required")
+		}
+		if len(setParams.Options) == 0 {
+			return nil, mcp.NewInvalidParamsError("At least one option is required")
+		}
+
+		schema, err := s.knownProviderOptions(ctx, setParams.Name)
+		if err != nil {
+			return nil, newDevPodError(fmt.Sprintf("failed to read options of provider %s", setParams.Name), err, nil)
+		}
+		if err := checkProviderOptions(schema, setParams.Options); err != nil {
+			return nil, mcp.NewInvalidParamsError(fmt.Sprintf("Invalid options for provider %s: %v", setParams.Name, err))
+		}
+
+		args := []string{"provider", "set-options", setParams.Name}
+		names := make([]string, 0, len(setParams.Options))
+		for name := range setParams.Options {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if schema[name].Password {
+				s.redactor.add(setParams.Options[name])
+			}
+			args = append(args, "-o", fmt.Sprintf("%s=%s", name, setParams.Options[name]))
+		}
+
+		output, err := s.combinedOutput(ctx, args)
+		if err != nil {
+			return nil, newDevPodError("failed to set provider options", err, output)
+		}
+		s.providerSchemaCache.invalidate()
+
+		return map[string]interface{}{
+			"name":    setParams.Name,
+			"options": names,
+			"message": fmt.Sprintf("Set %d option(s) of provider %s", len(names), setParams.Name),
+			"output":  string(output),
+		}, nil
+	})
+
+	// Update provider
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_updateProvider",
+		Description: "Update an installed DevPod provider to its latest release or pin it to a version, or check the installed providers for available updates",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "The provider to update; required unless checkOnly is set",
+				},
+				"version": map[string]interface{}{
+					"type":        "string",
+					"description": "Release to install, e.g. v0.0.8, for providers released on GitHub (default: the latest release)",
+				},
+				"checkOnly": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Only report available updates, for the named provider or all installed providers (default: false)",
+				},
+			},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var updateParams struct {
+			Name      string `json:"name"`
+			Version   string `json:"version"`
+			CheckOnly bool   `json:"checkOnly"`
+		}
+
+		if len(params) > 0 {
+			if err := json.Unmarshal(params, &updateParams); err != nil {
+				return nil, mcp.NewInvalidParamsError("Invalid update provider parameters")
+			}
+		}
+
+		if updateParams.Name == "" && !updateParams.CheckOnly {
+			return nil, mcp.NewInvalidParamsError("Provider name is required unless checkOnly is set")
+		}
+		if updateParams.CheckOnly && updateParams.Version != "" {
+			return nil, mcp.NewInvalidParamsError("version cannot be combined with checkOnly")
+		}
+
+		providers, err := s.installedProviders(ctx)
+		if err != nil {
+			return nil, newDevPodError("failed to list providers", err, nil)
+		}
+		provider, installed := providers[updateParams.Name]
+		if updateParams.Name != "" && !installed {
+			return nil, mcp.NewInvalidParamsError(fmt.Sprintf("Provider %s is not installed", updateParams.Name))
+		}
+
+		if updateParams.CheckOnly {
+			if updateParams.Name != "" {
+				providers = map[string]DevPodProvider{updateParams.Name: provider}
+			}
+			updates := s.checkProviderUpdates(ctx, providers)
+			available := 0
+			for _, update := range updates {
+				if update.UpdateAvailable {
+					available++
+				}
+			}
+			return map[string]interface{}{
+				"providers": updates,
+				"available": available,
+				"message":   fmt.Sprintf("%d of %d provider(s) can be updated", available, len(updates)),
+			}, nil
+		}
+
+		args := []string{"provider", "update", updateParams.Name}
+		if updateParams.Version != "" {
+			source, err := pinnedSource(updateParams.Name, provider, updateParams.Version)
+			if err != nil {
+				return nil, mcp.NewInvalidParamsError(err.Error())
+			}
+			args = append(args, source)
+		}
+		output, err := s.combinedOutput(ctx, args)
+		if err != nil {
+			return nil, newDevPodError(fmt.Sprintf("failed to update provider %s", updateParams.Name), err, output)
+		}
+		s.providerSchemaCache.invalidate()
+
+		previous := provider.Config.Version
+		current := previous
+		if providers, err := s.installedProviders(ctx); err == nil {
+			current = providers[updateParams.Name].Config.Version
+		}
+		message := fmt.Sprintf("Provider %s is already at %s", updateParams.Name, current)
+		if current != previous {
+			message = fmt.Sprintf("Updated provider %s from %s to %s", updateParams.Name, previous, current)
+		}
+		return map[string]interface{}{
+			"name":            updateParams.Name,
+			"previousVersion": previous,
+			"version":         current,
+			"changed":         current != previous,
+			"pinned":          updateParams.Version != "",
+			"output":          string(output),
+			"message":         message,
+		}, nil
+	})
+}
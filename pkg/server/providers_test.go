@@ -0,0 +1,242 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+	"github.com/protobomb/mcp-server-framework/pkg/transport"
+)
+
+func TestMissingRequiredOptions(t *testing.T) {
+	options := map[string]DevPodProviderOption{
+		"REGION":   {Required: true},
+		"ZONE":     {Required: true, Default: "a"},
+		"PROFILE":  {Required: true, Value: "dev"},
+		"DISK":     {},
+		"ACCOUNT":  {Required: true},
+		"SUBNET":   {Required: true},
+		"IGNORED":  {Required: false},
+		"PASSWORD": {Required: true, Password: true},
+	}
+
+	missing := missingRequiredOptions(options, map[string]string{"SUBNET": "s-1"})
+	if fmt.Sprint(missing) != "[ACCOUNT PASSWORD REGION]" {
+		t.Errorf("Unexpected missing options: %v", missing)
+	}
+}
+
+func TestAddProviderElicitsMissingOptions(t *testing.T) {
+	runner := &fakeRunner{outputs: map[string]string{
+		"version":                                  "v0.5.0",
+		"provider options aws --output json":       `{"AWS_REGION":{"required":true,"description":"The AWS region"},"AWS_DISK":{"default":"40"}}`,
+		"provider use aws -o AWS_REGION=eu-west-1": "done",
+	}}
+
+	clientIn, serverOut := io.Pipe()
+	serverIn, clientOut := io.Pipe()
+	s := New(transport.NewSTDIOTransportWithIO(serverIn, serverOut), Options{
+		Runner:    runner,
+		StatePath: filepath.Join(t.TempDir(), "state.json"),
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := s.Start(ctx); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer s.Stop()
+
+	// Act as a client that accepts every elicitation with a fixed region
+	go func() {
+		scanner := bufio.NewScanner(clientIn)
+		for scanner.Scan() {
+			var request struct {
+				ID     interface{} `json:"id"`
+				Method string      `json:"method"`
+			}
+			if json.Unmarshal(scanner.Bytes(), &request) != nil || request.Method != "elicitation/create" {
+				continue
+			}
+			response, _ := json.Marshal(map[string]interface{}{
+				"jsonrpc": "2.0",
+				"id":      request.ID,
+				"result": map[string]interface{}{
+					"action":  "accept",
+					"content": map[string]interface{}{"AWS_REGION": "eu-west-1"},
+				},
+			})
+			clientOut.Write(append(response, '\n'))
+		}
+	}()
+
+	s.setClientCapabilities(ctx, json.RawMessage(`{"capabilities":{"elicitation":{}}}`))
+	result, err := s.MCP().GetHandler("devpod_addProvider")(ctx, json.RawMessage(`{"name":"aws"}`))
+	if err != nil {
+		t.Fatalf("devpod_addProvider failed: %v", err)
+	}
+
+	elicited, ok := result.(map[string]interface{})["elicitedOptions"].([]string)
+	if !ok || len(elicited) != 1 || elicited[0] != "AWS_REGION" {
+		t.Errorf("Unexpected result: %v", result)
+	}
+}
+
+func TestProviderOptionSchemas(t *testing.T) {
+	runner := &fakeRunner{outputs: map[string]string{
+		"provider list --output json":                  `{"aws":{},"gcloud":{}}`,
+		"provider options aws --output json":           `{"REGION":{"required":true,"description":"AWS region","enum":[{"value":"us-east-1"},{"value":"eu-west-1"}]},"DISK_SIZE":{"default":"40"}}`,
+		"provider options gcloud --output json":        `{"DISK_SIZE":{"default":"20"},"PROJECT":{"required":true}}`,
+		"provider set-options aws -o REGION=eu-west-1": "done",
+	}}
+	s := newTestServer(t, runner)
+
+	result, err := s.MCP().GetHandler("tools/list")(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("tools/list failed: %v", err)
+	}
+	var setOptions mcp.Tool
+	for _, tool := range result.(toolsListResult).Tools {
+		if tool.Name == "devpod_setProviderOptions" {
+			setOptions = tool.Tool
+		}
+	}
+	properties := setOptions.InputSchema["properties"].(map[string]interface{})
+	if names := properties["name"].(map[string]interface{})["enum"]; fmt.Sprint(names) != "[aws gcloud]" {
+		t.Errorf("Expected the installed providers as name enum, got %v", names)
+	}
+	options := properties["options"].(map[string]interface{})["properties"].(map[string]interface{})
+	region := options["REGION"].(map[string]interface{})
+	if fmt.Sprint(region["enum"]) != "[us-east-1 eu-west-1]" || region["description"] != "aws: AWS region (required)" {
+		t.Errorf("Unexpected REGION property %v", region)
+	}
+	if disk := options["DISK_SIZE"].(map[string]interface{}); disk["description"] != "aws (default 40); gcloud (default 20)" {
+		t.Errorf("Unexpected DISK_SIZE property %v", disk)
+	}
+
+	// The registered listing stays generic
+	tool, _ := s.tools.get("devpod_setProviderOptions")
+	if _, ok := tool.InputSchema["properties"].(map[string]interface{})["options"].(map[string]interface{})["properties"]; ok {
+		t.Error("Expected the registered schema not to be modified")
+	}
+
+	set := s.MCP().GetHandler("devpod_setProviderOptions")
+	if _, err := set(context.Background(), json.RawMessage(`{"name":"aws","options":{"REGION":"eu-west-1"}}`)); err != nil {
+		t.Errorf("devpod_setProviderOptions failed: %v", err)
+	}
+	for _, params := range []string{`{"name":"aws","options":{"REGOIN":"eu-west-1"}}`, `{"name":"aws","options":{"REGION":"mars-1"}}`} {
+		if _, err := set(context.Background(), json.RawMessage(params)); err == nil {
+			t.Errorf("Expected %s to be rejected", params)
+		}
+	}
+}
+
+func TestCheckProviderOptionsSuggests(t *testing.T) {
+	schema := map[string]DevPodProviderOption{
+		"AWS_REGION":        {},
+		"AWS_DISK_SIZE":     {},
+		"AWS_INSTANCE_TYPE": {},
+	}
+	tests := []struct {
+		options map[string]string
+		want    string
+	}{
+		{map[string]string{"AWS_REGOIN": "x"}, "did you mean AWS_REGION?"},
+		{map[string]string{"region": "x"}, "did you mean AWS_REGION?"},
+		{map[string]string{"DISK_SIZE": "x", "COLOR": "x"}, "unknown options COLOR, DISK_SIZE (did you mean AWS_DISK_SIZE?); available:"},
+	}
+	for _, tt := range tests {
+		err := checkProviderOptions(schema, tt.options)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("checkProviderOptions(%v) = %v; want %q", tt.options, err, tt.want)
+		}
+	}
+	if err := checkProviderOptions(schema, map[string]string{"AWS_REGION": "x"}); err != nil {
+		t.Errorf("Expected known options to pass, got %v", err)
+	}
+}
+
+func TestAddProviderValidatesOptions(t *testing.T) {
+	runner := &fakeRunner{outputs: map[string]string{
+		"provider options aws --output json": `{"AWS_REGION":{"required":true},"AWS_DISK_SIZE":{"default":"40"}}`,
+	}}
+	s := newTestServer(t, runner)
+	add := s.MCP().GetHandler("devpod_addProvider")
+
+	_, err := add(context.Background(), json.RawMessage(`{"name":"aws","options":{"AWS_REGOIN":"eu-west-1"}}`))
+	if err == nil || !strings.Contains(err.Error(), "did you mean AWS_REGION?") {
+		t.Fatalf("Expected the unknown option to be rejected with a suggestion, got %v", err)
+	}
+	if last := strings.Join(runner.calls[len(runner.calls)-1], " "); last != "provider delete aws" {
+		t.Errorf("Expected the provider to be removed again, got %s", last)
+	}
+
+	if _, err := add(context.Background(), json.RawMessage(`{"name":"aws","options":{"AWS_DISK_SIZE":"80","AWS_REGION":"eu-west-1"}}`)); err != nil {
+		t.Fatalf("devpod_addProvider failed: %v", err)
+	}
+	if last := strings.Join(runner.calls[len(runner.calls)-1], " "); last != "provider use aws -o AWS_DISK_SIZE=80 -o AWS_REGION=eu-west-1" {
+		t.Errorf("Expected the options to be applied, got %s", last)
+	}
+}
+
+func TestProviderOptionsAreElicitedFromTheCallingSession(t *testing.T) {
+	runner := &fakeRunner{outputs: map[string]string{
+		"version":                                  "v0.5.0",
+		"provider options aws --output json":       `{"AWS_REGION":{"required":true}}`,
+		"provider use aws -o AWS_REGION=eu-west-1": "done",
+	}}
+	transport := &queueTransport{messages: make(chan sessionMessage, 10)}
+	s := New(transport, Options{
+		Runner:    runner,
+		StatePath: filepath.Join(t.TempDir(), "state.json"),
+		Policy: PolicyOptions{Rules: &PolicyRules{Rules: []PolicyRule{
+			{Effect: policyDeny, Tools: []string{"devpod_addProvider"}, Users: []string{"guest"}},
+		}}},
+	})
+	a := WithSessionID(context.Background(), "a")
+	s.setClientCapabilities(a, json.RawMessage(`{"capabilities":{"elicitation":{}}}`))
+	addProvider := s.MCP().GetHandler("devpod_addProvider")
+
+	// A denied call asks nothing
+	guest := WithUser(WithSessionID(context.Background(), "g"), "guest")
+	s.setClientCapabilities(guest, json.RawMessage(`{"capabilities":{"elicitation":{}}}`))
+	if _, err := addProvider(guest, json.RawMessage(`{"name":"aws"}`)); err == nil {
+		t.Fatal("Expected the guest's call to be denied")
+	}
+	if len(transport.messages) > 0 {
+		t.Fatalf("Expected no prompt for a denied call, got %s", (<-transport.messages).message)
+	}
+
+	results := make(chan error, 1)
+	go func() {
+		_, err := addProvider(a, json.RawMessage(`{"name":"aws"}`))
+		results <- err
+	}()
+	sent := <-transport.messages
+	if sent.session != "a" {
+		t.Fatalf("Expected the prompt to go to session a only, got %q", sent.session)
+	}
+	var request struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(sent.message, &request); err != nil {
+		t.Fatalf("Failed to parse request: %v", err)
+	}
+	reply := func(region string) []byte {
+		return []byte(`{"jsonrpc":"2.0","id":"` + request.ID + `","result":{"action":"accept","content":{"AWS_REGION":"` + region + `"}}}`)
+	}
+	s.requests.deliver("b", reply("us-east-1"))
+	s.requests.deliver("a", reply("eu-west-1"))
+	if err := <-results; err != nil {
+		t.Fatalf("devpod_addProvider failed: %v", err)
+	}
+	if calls := fmt.Sprint(runner.calls); !strings.Contains(calls, "AWS_REGION=eu-west-1") || strings.Contains(calls, "us-east-1") {
+		t.Errorf("Expected only session a's answer to be used, got calls %s", calls)
+	}
+}
@@ -0,0 +1,215 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Quotas are guardrails on the workspaces clients can create, checked before
+// devpod runs. Zero values disable the corresponding quota.
+type Quotas struct {
+	// MaxWorkspaces caps the number of workspaces devpod knows
+	MaxWorkspaces int `json:"maxWorkspaces,omitempty"`
+	// MaxWorkspacesPerProvider caps the workspaces of each named provider
+	MaxWorkspacesPerProvider map[string]int `json:"maxWorkspacesPerProvider,omitempty"`
+	// AllowedProviders are patterns of the providers workspaces may be
+	// created on, e.g. docker or aws*; empty allows all
+	AllowedProviders []string `json:"allowedProviders,omitempty"`
+	// AllowedMachineTypes are patterns of the machine types that may be
+	// requested, e.g. t3.* or e2-standard-[24]; empty allows all
+	AllowedMachineTypes []string `json:"allowedMachineTypes,omitempty"`
+	// MaxDiskSize caps the requested disk size in GB
+	MaxDiskSize int `json:"maxDiskSize,omitempty"`
+	// MaxGPU caps the requested number of GPUs
+	MaxGPU int `json:"maxGpu,omitempty"`
+}
+
+// LoadQuotas reads a JSON quota file
+func LoadQuotas(path string) (Quotas, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Quotas{}, err
+	}
+	var quotas Quotas
+	if err := json.Unmarshal(data, &quotas); err != nil {
+		return Quotas{}, fmt.Errorf("failed to parse quota file %s: %w", path, err)
+	}
+	if err := quotas.validate(); err != nil {
+		return Quotas{}, fmt.Errorf("invalid quota file %s: %w", path, err)
+	}
+	return quotas, nil
+}
+
+func (q Quotas) validate() error {
+	if q.MaxWorkspaces < 0 || q.MaxDiskSize < 0 || q.MaxGPU < 0 {
+		return fmt.Errorf("maxWorkspaces, maxDiskSize and maxGpu must not be negative")
+	}
+	for provider, max := range q.MaxWorkspacesPerProvider {
+		if max < 0 {
+			return fmt.Errorf("maxWorkspacesPerProvider of %s must not be negative", provider)
+		}
+	}
+	for _, pattern := range append(append([]string{}, q.AllowedProviders...), q.AllowedMachineTypes...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q", pattern)
+		}
+	}
+	return nil
+}
+
+// empty reports whether no quota is set
+func (q Quotas) empty() bool {
+	return q.MaxWorkspaces == 0 && len(q.MaxWorkspacesPerProvider) == 0 && len(q.AllowedProviders) == 0 &&
+		len(q.AllowedMachineTypes) == 0 && q.MaxDiskSize == 0 && q.MaxGPU == 0
+}
+
+// QuotaExceededError is returned when a quota rejects a workspace creation
+type QuotaExceededError struct {
+	// Quota is the name of the quota, as in the quota file
+	Quota  string
+	Reason string
+}
+
+// Error implements the error interface
+func (e *QuotaExceededError) Error() string {
+	return e.Reason
+}
+
+// quotaRequest is a workspace creation as the quotas see it
+type quotaRequest struct {
+	Name string
+	// Provider is empty for devpod's default provider
+	Provider string
+	// Options are the provider options passed to devpod up
+	Options map[string]string
+	GPU     int
+}
+
+// quotaReservations holds the workspaces being created, which devpod may
+// not list yet, so concurrent creations cannot overshoot the counts
+type quotaReservations struct {
+	mu      sync.Mutex
+	pending map[string]string
+}
+
+// reserveQuota checks a creation against the quotas and, when they allow
+// it, counts it until the returned function is called once devpod has
+// created it or failed to
+func (s *Server) reserveQuota(ctx context.Context, req quotaRequest) (func(), error) {
+	quotas := s.opts.Quotas
+	if quotas.empty() {
+		return func() {}, nil
+	}
+
+	provider := req.Provider
+	if provider == "" && (len(quotas.AllowedProviders) > 0 || len(quotas.MaxWorkspacesPerProvider) > 0 || len(quotas.AllowedMachineTypes) > 0 || quotas.MaxDiskSize > 0) {
+		var err error
+		if provider, err = s.defaultProviderName(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if err := quotas.checkRequest(provider, req); err != nil {
+		return nil, err
+	}
+	if quotas.MaxWorkspaces == 0 && quotas.MaxWorkspacesPerProvider[provider] == 0 {
+		return func() {}, nil
+	}
+
+	s.reservations.mu.Lock()
+	defer s.reservations.mu.Unlock()
+	workspaces, err := s.listWorkspaces(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count workspaces: %w", err)
+	}
+	providers := make(map[string]string, len(workspaces)+len(s.reservations.pending))
+	for _, workspace := range workspaces {
+		providers[workspace.ID] = workspace.Provider.Name
+	}
+	for name, pending := range s.reservations.pending {
+		providers[name] = pending
+	}
+	// Recreating a workspace does not add one
+	delete(providers, req.Name)
+	if err := quotas.checkCounts(provider, providers); err != nil {
+		return nil, err
+	}
+
+	if s.reservations.pending == nil {
+		s.reservations.pending = make(map[string]string)
+	}
+	s.reservations.pending[req.Name] = provider
+	return func() {
+		s.reservations.mu.Lock()
+		defer s.reservations.mu.Unlock()
+		delete(s.reservations.pending, req.Name)
+	}, nil
+}
+
+// checkRequest checks the provider and machine size of a creation
+func (q Quotas) checkRequest(provider string, req quotaRequest) error {
+	if !matchesAny(q.AllowedProviders, provider) {
+		return &QuotaExceededError{Quota: "allowedProviders", Reason: fmt.Sprintf("provider %s is not allowed; allowed providers: %s", provider, strings.Join(q.AllowedProviders, ", "))}
+	}
+	if q.MaxGPU > 0 && req.GPU > q.MaxGPU {
+		return &QuotaExceededError{Quota: "maxGpu", Reason: fmt.Sprintf("%d GPUs requested, the quota allows %d", req.GPU, q.MaxGPU)}
+	}
+
+	mapping := resourceOptions[provider]
+	if machineType := req.Options[mapping.MachineType]; mapping.MachineType != "" && machineType != "" && !matchesAny(q.AllowedMachineTypes, machineType) {
+		return &QuotaExceededError{Quota: "allowedMachineTypes", Reason: fmt.Sprintf("machine type %s is not allowed; allowed machine types: %s", machineType, strings.Join(q.AllowedMachineTypes, ", "))}
+	}
+	if disk := req.Options[mapping.DiskSize]; q.MaxDiskSize > 0 && mapping.DiskSize != "" && disk != "" {
+		size, err := strconv.Atoi(strings.TrimSuffix(disk, mapping.DiskUnit))
+		if err != nil {
+			return &QuotaExceededError{Quota: "maxDiskSize", Reason: fmt.Sprintf("disk size %s cannot be checked against the quota of %d GB", disk, q.MaxDiskSize)}
+		}
+		if size > q.MaxDiskSize {
+			return &QuotaExceededError{Quota: "maxDiskSize", Reason: fmt.Sprintf("disk size of %d GB requested, the quota allows %d GB", size, q.MaxDiskSize)}
+		}
+	}
+	return nil
+}
+
+// checkCounts checks whether one more workspace fits next to the existing
+// ones, given as workspace name to provider
+func (q Quotas) checkCounts(provider string, providers map[string]string) error {
+	if q.MaxWorkspaces > 0 && len(providers) >= q.MaxWorkspaces {
+		return &QuotaExceededError{Quota: "maxWorkspaces", Reason: fmt.Sprintf("workspace quota of %d reached; delete a workspace first", q.MaxWorkspaces)}
+	}
+	max := q.MaxWorkspacesPerProvider[provider]
+	if max == 0 {
+		return nil
+	}
+	var names []string
+	for name, p := range providers {
+		if p == provider {
+			names = append(names, name)
+		}
+	}
+	if len(names) >= max {
+		sort.Strings(names)
+		return &QuotaExceededError{Quota: "maxWorkspacesPerProvider", Reason: fmt.Sprintf("quota of %d workspaces on provider %s reached (%s); delete one first", max, provider, strings.Join(names, ", "))}
+	}
+	return nil
+}
+
+// defaultProviderName returns the provider devpod uses when none is given
+func (s *Server) defaultProviderName(ctx context.Context) (string, error) {
+	providers, err := s.installedProviders(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to determine the default provider for the quotas: %w", err)
+	}
+	for name, provider := range providers {
+		if provider.Default {
+			return name, nil
+		}
+	}
+	return "", &QuotaExceededError{Quota: "allowedProviders", Reason: "no default provider is set, so the quotas cannot be checked; pass a provider"}
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+	"github.com/protobomb/mcp-server-framework/pkg/transport"
+)
+
+func TestLoadQuotas(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "quotas.json")
+	os.WriteFile(valid, []byte(`{"maxWorkspaces":5,"maxWorkspacesPerProvider":{"aws":2},"allowedMachineTypes":["t3.*"]}`), 0o600)
+	if quotas, err := LoadQuotas(valid); err != nil || quotas.MaxWorkspaces != 5 || quotas.MaxWorkspacesPerProvider["aws"] != 2 {
+		t.Errorf("Unexpected quotas %+v (%v)", quotas, err)
+	}
+	invalid := filepath.Join(dir, "invalid.json")
+	os.WriteFile(invalid, []byte(`{"allowedProviders":["[docker"]}`), 0o600)
+	if _, err := LoadQuotas(invalid); err == nil {
+		t.Error("Expected an invalid pattern to be rejected")
+	}
+}
+
+func TestQuotasCheckRequest(t *testing.T) {
+	quotas := Quotas{AllowedProviders: []string{"docker", "aws"}, AllowedMachineTypes: []string{"t3.*"}, MaxDiskSize: 100, MaxGPU: 1}
+	tests := []struct {
+		provider string
+		req      quotaRequest
+		quota    string
+	}{
+		{"aws", quotaRequest{Options: map[string]string{"AWS_INSTANCE_TYPE": "t3.large", "AWS_DISK_SIZE": "80"}}, ""},
+		{"gcloud", quotaRequest{}, "allowedProviders"},
+		{"aws", quotaRequest{Options: map[string]string{"AWS_INSTANCE_TYPE": "p4d.24xlarge"}}, "allowedMachineTypes"},
+		{"aws", quotaRequest{Options: map[string]string{"AWS_DISK_SIZE": "500"}}, "maxDiskSize"},
+		{"aws", quotaRequest{GPU: 2}, "maxGpu"},
+		{"docker", quotaRequest{Options: map[string]string{"AWS_INSTANCE_TYPE": "p4d.24xlarge"}}, ""},
+	}
+	for _, tt := range tests {
+		err := quotas.checkRequest(tt.provider, tt.req)
+		got := ""
+		var quotaErr *QuotaExceededError
+		if errors.As(err, &quotaErr) {
+			got = quotaErr.Quota
+		}
+		if got != tt.quota || (err == nil) != (tt.quota == "") {
+			t.Errorf("checkRequest(%s, %+v) = %v, want quota %q", tt.provider, tt.req, err, tt.quota)
+		}
+	}
+}
+
+func TestCreateWorkspaceQuotas(t *testing.T) {
+	runner := &fakeRunner{outputs: map[string]string{
+		"list --output json":          `[{"id":"a","provider":{"name":"aws"}},{"id":"b","provider":{"name":"docker"}}]`,
+		"provider list --output json": `{"docker":{"default":true},"aws":{}}`,
+	}}
+	s := New(transport.NewSTDIOTransport(), Options{
+		Runner:    runner,
+		StatePath: filepath.Join(t.TempDir(), "state.json"),
+		Quotas:    Quotas{MaxWorkspaces: 3, MaxWorkspacesPerProvider: map[string]int{"aws": 1}, AllowedProviders: []string{"docker", "aws"}},
+		Templates: map[string]Template{
+			"go": {Source: "github.com/acme/go", Providers: []string{"aws", "docker"}},
+		},
+	})
+	create := s.MCP().GetHandler("devpod_createWorkspace")
+	ctx := context.Background()
+
+	_, err := create(ctx, json.RawMessage(`{"name":"c","source":"github.com/acme/api","provider":"aws"}`))
+	var rpcErr *mcp.RPCError
+	if !errors.As(err, &rpcErr) || rpcErr.Code != categoryCodes[CategoryQuotaExceeded] || !strings.Contains(fmt.Sprint(rpcErr.Data), "quota:maxWorkspacesPerProvider") {
+		t.Fatalf("Expected the aws quota to be exceeded, got %v", err)
+	}
+	if _, err := create(ctx, json.RawMessage(`{"name":"c","source":"github.com/acme/api","provider":"gcloud"}`)); err == nil || !strings.Contains(err.Error(), CategoryQuotaExceeded) {
+		t.Errorf("Expected gcloud not to be allowed, got %v", err)
+	}
+
+	// The template's aws is full, so it falls back to docker right away
+	result, err := create(ctx, json.RawMessage(`{"name":"c","template":"go"}`))
+	if err != nil {
+		t.Fatalf("devpod_createWorkspace failed: %v", err)
+	}
+	if data := result.(map[string]interface{}); data["provider"] != "docker" || !strings.Contains(fmt.Sprint(data["warnings"]), "provider aws skipped") {
+		t.Errorf("Expected aws to be skipped, got %v", data)
+	}
+
+	runner.outputs["list --output json"] = `[{"id":"a","provider":{"name":"aws"}},{"id":"b","provider":{"name":"docker"}},{"id":"c","provider":{"name":"docker"}}]`
+	if _, err := create(ctx, json.RawMessage(`{"name":"d","source":"github.com/acme/api"}`)); err == nil || !strings.Contains(err.Error(), CategoryQuotaExceeded) {
+		t.Errorf("Expected the workspace quota to be exceeded, got %v", err)
+	}
+	// Recreating an existing workspace does not add one
+	if _, err := create(ctx, json.RawMessage(`{"name":"c","source":"github.com/acme/api","ifExists":"recreate"}`)); err != nil {
+		t.Errorf("Expected a recreation to fit the quota, got %v", err)
+	}
+	if calls := fmt.Sprint(runner.calls); strings.Contains(calls, "--id d") || strings.Contains(calls, "--provider aws") {
+		t.Errorf("Expected rejected creations not to run, got calls %v", calls)
+	}
+}
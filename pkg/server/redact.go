@@ -0,0 +1,170 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+// redactedText replaces masked values
+const redactedText = "[REDACTED]"
+
+// minRedactedValueLength keeps short known values, which would mask
+// unrelated text, from being redacted
+const minRedactedValueLength = 4
+
+// redactionRules mask values that look like credentials wherever they
+// appear. Replacements keep the surrounding key or scheme so the text stays
+// readable.
+var redactionRules = []struct {
+	pattern     *regexp.Regexp
+	replacement string
+}{
+	// Private key blocks, including JSON-escaped ones
+	{regexp.MustCompile(`(?s)-----BEGIN [A-Z0-9 ]*PRIVATE KEY-----.*?-----END [A-Z0-9 ]*PRIVATE KEY-----`), "[REDACTED PRIVATE KEY]"},
+	// AWS access key IDs
+	{regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`), redactedText},
+	// AWS secret keys in credentials files and environment listings
+	{regexp.MustCompile(`(?i)(aws_secret_access_key["']?\s*[=:]\s*["']?)[A-Za-z0-9/+=]{20,}`), "${1}" + redactedText},
+	// Bearer tokens in authorization headers
+	{regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9\-._~+/]{8,}=*`), "${1}" + redactedText},
+	// GitHub tokens
+	{regexp.MustCompile(`\b(?:ghp|gho|ghu|ghs|ghr|github_pat)_[A-Za-z0-9_]{20,}\b`), redactedText},
+	// Passwords in URLs
+	{regexp.MustCompile(`(://[^/\s:@"]+:)[^/\s@"]+@`), "${1}" + redactedText + "@"},
+	// Environment-style assignments of secret names, e.g. GITHUB_TOKEN=...
+	// or "DB_PASSWORD": "..."
+	{regexp.MustCompile(`\b([A-Z0-9_]*(?:PASSWORD|SECRET|TOKEN|ACCESS_KEY|API_KEY|PRIVATE_KEY)[A-Z0-9_]*["']?\s*[=:]\s*["']?)[^\s"',{}\[\]\\]+`), "${1}" + redactedText},
+}
+
+// redactor masks credentials in text: known secret values registered at run
+// time and values matching redactionRules
+type redactor struct {
+	mu     sync.RWMutex
+	values map[string]bool
+}
+
+func newRedactor() *redactor {
+	return &redactor{values: make(map[string]bool)}
+}
+
+// add registers secret values to mask from now on
+func (r *redactor) add(values ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, value := range values {
+		if len(value) >= minRedactedValueLength {
+			r.values[value] = true
+		}
+	}
+}
+
+// known returns the registered values, longest first so that values
+// containing others are masked whole
+func (r *redactor) known() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	values := make([]string, 0, len(r.values))
+	for value := range r.values {
+		values = append(values, value)
+	}
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+	return values
+}
+
+// redact masks the credentials in text
+func (r *redactor) redact(text string) string {
+	for _, value := range r.known() {
+		text = strings.ReplaceAll(text, value, redactedText)
+		// Values also appear JSON-escaped in serialized results
+		if encoded, err := json.Marshal(value); err == nil {
+			if escaped := string(encoded[1 : len(encoded)-1]); escaped != value {
+				text = strings.ReplaceAll(text, escaped, redactedText)
+			}
+		}
+	}
+	for _, rule := range redactionRules {
+		text = rule.pattern.ReplaceAllString(text, rule.replacement)
+	}
+	return text
+}
+
+// redactValue masks the credentials in a tool result or error data. Values
+// without credentials are returned unchanged; others are returned as their
+// redacted JSON form and reported as changed.
+func (r *redactor) redactValue(value interface{}) (interface{}, bool) {
+	if value == nil {
+		return nil, false
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return value, false
+	}
+	redacted := r.redact(string(encoded))
+	if redacted == string(encoded) {
+		return value, false
+	}
+	var decoded interface{}
+	if err := json.Unmarshal([]byte(redacted), &decoded); err != nil {
+		return redactedText, true
+	}
+	return decoded, true
+}
+
+// redactError masks the credentials in a handler error
+func (r *redactor) redactError(err error) error {
+	var rpcErr *mcp.RPCError
+	if errors.As(err, &rpcErr) {
+		message := r.redact(rpcErr.Message)
+		data, changed := r.redactValue(rpcErr.Data)
+		if message == rpcErr.Message && !changed {
+			return err
+		}
+		return &mcp.RPCError{Code: rpcErr.Code, Message: message, Data: data}
+	}
+	if message := r.redact(err.Error()); message != err.Error() {
+		return errors.New(message)
+	}
+	return err
+}
+
+// redactHandler wraps a tool handler so its results and errors never carry
+// credentials to the client
+func (s *Server) redactHandler(handler mcp.Handler) mcp.Handler {
+	return func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		result, err := handler(ctx, params)
+		if err != nil {
+			return nil, s.redactor.redactError(err)
+		}
+		result, _ = s.redactor.redactValue(result)
+		return result, nil
+	}
+}
+
+// redactingWriter masks credentials in everything written through it
+type redactingWriter struct {
+	redactor *redactor
+	w        io.Writer
+}
+
+func (w *redactingWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(w.w, w.redactor.redact(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// RedactingWriter returns a writer that masks credentials known to the
+// server before writing to w. Use it as the log output so debug logs of
+// devpod commands don't leak secrets; the server also keeps the tail of
+// what is written for devpod_exportLogsBundle.
+func (s *Server) RedactingWriter(w io.Writer) io.Writer {
+	return &redactingWriter{redactor: s.redactor, w: io.MultiWriter(w, s.logs)}
+}
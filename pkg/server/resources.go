@@ -0,0 +1,118 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+// workspaceResources are the machine resources requested for a new workspace
+type workspaceResources struct {
+	MachineType string `json:"machineType,omitempty"`
+	// DiskSize is in GB
+	DiskSize int `json:"diskSize,omitempty"`
+	// GPU is the number of GPUs
+	GPU int `json:"gpu,omitempty"`
+	// ProviderOptions are passed through to the provider as they are
+	ProviderOptions map[string]string `json:"providerOptions,omitempty"`
+}
+
+// providerResourceOptions names the options through which a provider takes
+// resource requests
+type providerResourceOptions struct {
+	MachineType string
+	DiskSize    string
+	// DiskUnit is appended to the disk size, e.g. "Gi" for Kubernetes volumes
+	DiskUnit string
+	// GPUMachineType is used when GPUs are requested without a machine type
+	GPUMachineType string
+	// GPUResources takes a Kubernetes resource list instead of a machine type
+	GPUResources string
+}
+
+// resourceOptions maps the resource arguments to each provider's options
+var resourceOptions = map[string]providerResourceOptions{
+	"aws":          {MachineType: "AWS_INSTANCE_TYPE", DiskSize: "AWS_DISK_SIZE", GPUMachineType: "g4dn.xlarge"},
+	"gcloud":       {MachineType: "MACHINE_TYPE", DiskSize: "DISK_SIZE", GPUMachineType: "g2-standard-4"},
+	"azure":        {MachineType: "AZURE_INSTANCE_SIZE", DiskSize: "AZURE_DISK_SIZE", GPUMachineType: "Standard_NC4as_T4_v3"},
+	"digitalocean": {MachineType: "DROPLET_SIZE", DiskSize: "DISK_SIZE", GPUMachineType: "gpu-h100x1-80gb"},
+	"kubernetes":   {DiskSize: "DISK_SIZE", DiskUnit: "Gi", GPUResources: "RESOURCES"},
+}
+
+// empty reports whether no resources were requested
+func (r workspaceResources) empty() bool {
+	return r.MachineType == "" && r.DiskSize == 0 && r.GPU == 0 && len(r.ProviderOptions) == 0
+}
+
+// providerOptions translates the request into options of the given provider
+func (r workspaceResources) providerOptions(provider string) (map[string]string, error) {
+	if r.DiskSize < 0 || r.GPU < 0 {
+		return nil, fmt.Errorf("diskSize and gpu must not be negative")
+	}
+
+	options := make(map[string]string, len(r.ProviderOptions)+3)
+	for key, value := range r.ProviderOptions {
+		options[key] = value
+	}
+	if r.MachineType == "" && r.DiskSize == 0 && r.GPU == 0 {
+		return options, nil
+	}
+
+	if provider == "" {
+		return nil, fmt.Errorf("machineType, diskSize and gpu need an explicit provider")
+	}
+	mapping, ok := resourceOptions[provider]
+	if !ok {
+		return nil, fmt.Errorf("provider %s does not support machineType, diskSize or gpu; pass its own options in providerOptions", provider)
+	}
+
+	machineType := r.MachineType
+	if r.GPU > 0 {
+		switch {
+		case mapping.GPUResources != "":
+			options[mapping.GPUResources] = fmt.Sprintf("limits.nvidia.com/gpu=%d", r.GPU)
+		case machineType == "" && r.GPU == 1:
+			machineType = mapping.GPUMachineType
+		case machineType == "":
+			return nil, fmt.Errorf("set machineType to a %s machine type with %d GPUs", provider, r.GPU)
+		}
+	}
+	if machineType != "" {
+		if mapping.MachineType == "" {
+			return nil, fmt.Errorf("provider %s does not support machineType", provider)
+		}
+		options[mapping.MachineType] = machineType
+	}
+	if r.DiskSize > 0 {
+		options[mapping.DiskSize] = fmt.Sprintf("%d%s", r.DiskSize, mapping.DiskUnit)
+	}
+	return options, nil
+}
+
+// resourceArgs returns the --provider-option flags for each provider. When
+// the provider publishes its option schema, options it does not know are
+// rejected so a typo does not silently create an undersized machine.
+func (s *Server) resourceArgs(ctx context.Context, resources workspaceResources, providers []string) (map[string][]string, error) {
+	args := make(map[string][]string, len(providers))
+	for _, provider := range providers {
+		options, err := resources.providerOptions(provider)
+		if err != nil {
+			return nil, err
+		}
+		if len(options) == 0 {
+			continue
+		}
+
+		if provider != "" {
+			if schema, err := s.knownProviderOptions(ctx, provider); err == nil {
+				if err := checkProviderOptions(schema, options); err != nil {
+					return nil, fmt.Errorf("provider %s: %v", provider, err)
+				}
+			} else {
+				log.Printf("WARNING: failed to read %s provider options, resource options are not validated: %v", provider, err)
+			}
+		}
+		args[provider] = optionArgs("--provider-option", options)
+	}
+	return args, nil
+}
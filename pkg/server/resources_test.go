@@ -0,0 +1,76 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestResourceProviderOptions(t *testing.T) {
+	tests := []struct {
+		provider  string
+		resources workspaceResources
+		want      map[string]string
+	}{
+		{"aws", workspaceResources{MachineType: "t3.xlarge", DiskSize: 80}, map[string]string{"AWS_INSTANCE_TYPE": "t3.xlarge", "AWS_DISK_SIZE": "80"}},
+		{"aws", workspaceResources{GPU: 1}, map[string]string{"AWS_INSTANCE_TYPE": "g4dn.xlarge"}},
+		{"kubernetes", workspaceResources{GPU: 2, DiskSize: 50}, map[string]string{"RESOURCES": "limits.nvidia.com/gpu=2", "DISK_SIZE": "50Gi"}},
+		{"docker", workspaceResources{ProviderOptions: map[string]string{"DOCKER_HOST": "tcp://gpu-box:2375"}}, map[string]string{"DOCKER_HOST": "tcp://gpu-box:2375"}},
+	}
+	for _, tt := range tests {
+		got, err := tt.resources.providerOptions(tt.provider)
+		if err != nil {
+			t.Errorf("%s %+v: unexpected error %v", tt.provider, tt.resources, err)
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s %+v = %v, want %v", tt.provider, tt.resources, got, tt.want)
+		}
+		for key, value := range tt.want {
+			if got[key] != value {
+				t.Errorf("%s %+v: %s = %q, want %q", tt.provider, tt.resources, key, got[key], value)
+			}
+		}
+	}
+
+	for _, tt := range []struct {
+		provider  string
+		resources workspaceResources
+	}{
+		{"", workspaceResources{MachineType: "t3.xlarge"}},
+		{"docker", workspaceResources{GPU: 1}},
+		{"aws", workspaceResources{GPU: 4}},
+		{"kubernetes", workspaceResources{MachineType: "large"}},
+	} {
+		if _, err := tt.resources.providerOptions(tt.provider); err == nil {
+			t.Errorf("Expected an error for %s %+v", tt.provider, tt.resources)
+		}
+	}
+}
+
+func TestCreateWorkspaceWithResources(t *testing.T) {
+	runner := &fakeRunner{outputs: map[string]string{
+		"list --output json":                 "[]",
+		"provider options aws --output json": `{"AWS_INSTANCE_TYPE":{},"AWS_DISK_SIZE":{}}`,
+	}}
+	s := newTestServer(t, runner)
+
+	_, err := s.MCP().GetHandler("devpod_createWorkspace")(context.Background(), json.RawMessage(
+		`{"name":"ml","source":"github.com/acme/ml","provider":"aws","gpu":1,"diskSize":100,"devcontainerPath":".devcontainer/gpu/devcontainer.json"}`))
+	if err != nil {
+		t.Fatalf("devpod_createWorkspace failed: %v", err)
+	}
+
+	want := "up github.com/acme/ml --id ml --devcontainer-path .devcontainer/gpu/devcontainer.json " +
+		"--provider-option AWS_DISK_SIZE=100 --provider-option AWS_INSTANCE_TYPE=g4dn.xlarge --provider aws"
+	for _, call := range runner.calls {
+		if call[0] == "up" {
+			if got := strings.Join(call, " "); got != want {
+				t.Errorf("Unexpected up command:\n got %s\nwant %s", got, want)
+			}
+			return
+		}
+	}
+	t.Error("Expected devpod up to be called")
+}
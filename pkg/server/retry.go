@@ -0,0 +1,142 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Retry defaults for RetryPolicy fields left at zero
+const (
+	defaultRetryBackoff    = time.Second
+	defaultRetryMaxBackoff = 30 * time.Second
+)
+
+// RetryPolicy retries devpod commands that fail with transient errors, such
+// as network timeouts, a docker daemon that is momentarily unavailable or
+// cloud API rate limits
+type RetryPolicy struct {
+	// MaxAttempts is the most times a command runs; 0 or 1 disables retries
+	MaxAttempts int
+	// Backoff is the delay before the first retry, doubled for each further
+	// retry (default: 1s)
+	Backoff time.Duration
+	// MaxBackoff caps the delay between attempts (default: 30s)
+	MaxBackoff time.Duration
+	// Mutating also retries commands that change workspaces or providers,
+	// such as up and delete, which may have partly run before failing
+	Mutating bool
+}
+
+// delay returns the wait before the given retry, counting from 1, with up
+// to a quarter of random jitter so clients retrying together spread out
+func (p RetryPolicy) delay(retry int) time.Duration {
+	backoff, limit := p.Backoff, p.MaxBackoff
+	if backoff <= 0 {
+		backoff = defaultRetryBackoff
+	}
+	if limit <= 0 {
+		limit = defaultRetryMaxBackoff
+	}
+	delay := backoff
+	for i := 1; i < retry && delay < limit; i++ {
+		delay *= 2
+	}
+	if delay > limit {
+		delay = limit
+	}
+	return delay + time.Duration(rand.Int63n(int64(delay)/4+1))
+}
+
+// transientPatterns are lowercase output fragments of transport failures
+// that are likely to pass when the command is repeated. They are specific
+// enough not to match build or test output that merely mentions a timeout.
+var transientPatterns = []string{
+	// network
+	"connection reset by peer", "connection refused", "i/o timeout", "tls handshake timeout",
+	"temporary failure in name resolution", "network is unreachable", "no route to host",
+	"connect: connection timed out", "unexpected eof",
+	// docker daemon restarting or busy
+	"cannot connect to the docker daemon", "is the docker daemon running", "error during connect",
+	// cloud API throttling and outages
+	"rate limit", "ratelimit", "too many requests", "throttl", "requestlimitexceeded",
+	"service unavailable", "bad gateway", "gateway timeout", "try again later",
+}
+
+// readOnlyCommands are the devpod subcommands that change nothing, so
+// repeating them is always safe
+var readOnlyCommands = map[string]bool{
+	"list": true, "status": true, "version": true, "troubleshoot": true,
+	"provider list": true, "provider options": true, "ide list": true,
+	"context list": true, "machine list": true,
+}
+
+// retryable reports whether a failed devpod command may be repeated. Our
+// own deadlines and cancellations are final, and commands run over ssh are
+// never repeated since the user's command may not be safe to run twice.
+// Other commands that change anything are repeated only when the policy
+// allows it, since a half-done up or delete may not be safe to run again.
+func (p RetryPolicy) retryable(args []string, err error, output []byte) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var limitErr *RateLimitError
+	if errors.As(err, &limitErr) {
+		return false
+	}
+	if len(args) > 0 && args[0] == "ssh" {
+		return false
+	}
+	if !p.Mutating && !readOnlyCommands[devpodSubcommand(args)] {
+		return false
+	}
+	lower := strings.ToLower(string(output))
+	for _, pattern := range transientPatterns {
+		if strings.Contains(lower, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// attemptsKey is the context key for the attempt count of a tool call
+type attemptsKey struct{}
+
+// callAttempts records the most attempts any devpod command of a tool call
+// needed
+type callAttempts struct {
+	mu  sync.Mutex
+	max int
+}
+
+// withAttempts returns a context that records the attempts of its devpod
+// commands
+func withAttempts(ctx context.Context) (context.Context, *callAttempts) {
+	attempts := &callAttempts{}
+	return context.WithValue(ctx, attemptsKey{}, attempts), attempts
+}
+
+// recordAttempts notes that a command of the call of ctx ran n times
+func recordAttempts(ctx context.Context, n int) {
+	if attempts, ok := ctx.Value(attemptsKey{}).(*callAttempts); ok {
+		attempts.mu.Lock()
+		if n > attempts.max {
+			attempts.max = n
+		}
+		attempts.mu.Unlock()
+	}
+}
+
+// retried returns the attempts of the command that needed the most, or 0
+// when every command succeeded or failed at once
+func (a *callAttempts) retried() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.max > 1 {
+		return a.max
+	}
+	return 0
+}
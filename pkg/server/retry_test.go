@@ -0,0 +1,117 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+func TestRetryable(t *testing.T) {
+	failed := errors.New("exit status 1")
+	tests := []struct {
+		args     []string
+		mutating bool
+		err      error
+		output   string
+		want     bool
+	}{
+		{[]string{"list", "--output", "json"}, false, failed, "Cannot connect to the Docker daemon at unix:///var/run/docker.sock", true},
+		{[]string{"list"}, false, failed, "Throttling: Rate exceeded", true},
+		{[]string{"provider", "list"}, false, failed, "dial tcp 10.0.0.1:443: i/o timeout", true},
+		{[]string{"status", "api"}, false, failed, "provider not found", false},
+		// Build and test output mentioning a timeout is not a transport failure
+		{[]string{"status", "api"}, false, failed, "test TestUpload timed out after 30s", false},
+		{[]string{"up", "api"}, true, failed, "step 4/9: timeout waiting for lock", false},
+		// Commands that change anything are repeated only when allowed
+		{[]string{"up", "api"}, false, failed, "dial tcp 10.0.0.1:443: i/o timeout", false},
+		{[]string{"delete", "api"}, false, failed, "connection refused", false},
+		{[]string{"provider", "add", "aws"}, false, failed, "Too Many Requests", false},
+		{[]string{"up", "api"}, true, failed, "dial tcp 10.0.0.1:443: i/o timeout", true},
+		{[]string{"ssh", "api"}, true, failed, "connection reset by peer", false},
+		{[]string{"list"}, false, context.DeadlineExceeded, "i/o timeout", false},
+		{[]string{"list"}, false, &RateLimitError{Reason: "busy"}, "", false},
+	}
+	for _, tt := range tests {
+		policy := RetryPolicy{MaxAttempts: 3, Mutating: tt.mutating}
+		if got := policy.retryable(tt.args, tt.err, []byte(tt.output)); got != tt.want {
+			t.Errorf("retryable(%v, mutating %v, %v, %q) = %v; want %v", tt.args, tt.mutating, tt.err, tt.output, got, tt.want)
+		}
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	policy := RetryPolicy{Backoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	for retry, want := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 3: 400 * time.Millisecond, 6: time.Second} {
+		if delay := policy.delay(retry); delay < want || delay > want+want/4 {
+			t.Errorf("delay(%d) = %s; want %s plus jitter", retry, delay, want)
+		}
+	}
+}
+
+// flakyRunner fails listing workspaces with a transient error a number of
+// times before it succeeds
+type flakyRunner struct {
+	mu       sync.Mutex
+	failures int
+	calls    int
+}
+
+func (r *flakyRunner) Run(ctx context.Context, args []string) ([]byte, []byte, error) {
+	if args[0] == "version" {
+		return []byte("v0.6.15"), nil, nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls++
+	if r.calls <= r.failures {
+		return nil, []byte("Cannot connect to the Docker daemon"), errors.New("exit status 1")
+	}
+	return []byte(`[]`), nil, nil
+}
+
+func TestToolCallReportsAttempts(t *testing.T) {
+	runner := &flakyRunner{failures: 2}
+	s := newTestServer(t, runner)
+	s.opts.Retry = RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond}
+	call := s.MCP().GetHandler("tools/call")
+
+	result, err := call(context.Background(), json.RawMessage(`{"name":"devpod_listWorkspaces","arguments":{}}`))
+	if err != nil {
+		t.Fatalf("Expected the call to succeed after retries, got %v", err)
+	}
+	data := result.(map[string]interface{})["content"].([]map[string]interface{})[1]["resource"].(map[string]interface{})["text"].(string)
+	if runner.calls != 3 || !strings.Contains(data, `"attempts":3`) {
+		t.Errorf("Expected 3 attempts to be reported, got %d calls and %s", runner.calls, data)
+	}
+
+	runner.calls, runner.failures = 0, 5
+	_, err = call(context.Background(), json.RawMessage(`{"name":"devpod_listWorkspaces","arguments":{}}`))
+	var rpcErr *mcp.RPCError
+	if !errors.As(err, &rpcErr) || rpcErr.Data.(map[string]interface{})["attempts"] != 3 || runner.calls != 3 {
+		t.Errorf("Expected the failure after 3 attempts to report them, got %v after %d calls", err, runner.calls)
+	}
+}
+
+func TestRetryWarningLeavesOutArguments(t *testing.T) {
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	defer log.SetOutput(os.Stderr)
+
+	s := newTestServer(t, &flakyRunner{failures: 1})
+	s.opts.Retry = RetryPolicy{MaxAttempts: 2, Backoff: time.Millisecond, Mutating: true}
+	if _, _, err := s.run(context.Background(), []string{"provider", "add", "aws", "-o", "TOKEN=plain-token-value"}); err != nil {
+		t.Fatalf("Expected the retry to succeed, got %v", err)
+	}
+	if text := logged.String(); !strings.Contains(text, "devpod provider add failed with a transient error") || strings.Contains(text, "plain-token-value") {
+		t.Errorf("Expected a warning naming only the subcommand, got %q", text)
+	}
+}
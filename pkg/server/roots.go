@@ -0,0 +1,321 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+// rootsTimeout bounds the wait for the client's roots/list response
+const rootsTimeout = 5 * time.Second
+
+// maxLocalProjects bounds the projects devpod_listLocalProjects returns
+const maxLocalProjects = 200
+
+// defaultProjectDepth is how many levels below a root projects are looked
+// for by default
+const defaultProjectDepth = 2
+
+// projectMarkers are files and directories at the top of a project
+// directory, in addition to the files of packageManagerFiles
+var projectMarkers = []string{".git", ".devcontainer.json", ".devcontainer", "package.json", "pyproject.toml", "Dockerfile"}
+
+// clientRoot is a directory the client shares with the server, e.g. a
+// folder open in the editor
+type clientRoot struct {
+	URI  string `json:"uri,omitempty"`
+	Name string `json:"name,omitempty"`
+	// Path is the local directory the URI points to
+	Path string `json:"path"`
+}
+
+// rootsCache holds a session's roots between roots/list requests
+type rootsCache struct {
+	roots []clientRoot
+	valid bool
+	// generation counts invalidations, so a list requested before a change
+	// is not cached
+	generation int
+}
+
+// invalidateRoots makes the next clientRoots call of a session ask its
+// client again
+func (s *Server) invalidateRoots(ctx context.Context) {
+	s.sessions.update(SessionID(ctx), func(state *sessionState) {
+		state.roots = rootsCache{generation: state.roots.generation + 1}
+	})
+}
+
+// clientRoots returns the roots of the calling session's client that are
+// local directories. Clients that did not announce the roots capability have
+// none. The roots are requested once per session and again after its client
+// reports a change. With a workspace root, client roots outside it are left
+// out.
+func (s *Server) clientRoots(ctx context.Context) []clientRoot {
+	if s.capabilities(ctx).Roots == nil {
+		return nil
+	}
+	cache := s.session(ctx).roots
+	if cache.valid {
+		return cache.roots
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, rootsTimeout)
+	defer cancel()
+	raw, err := s.requestClient(ctx, "roots/list", map[string]interface{}{})
+	if err != nil {
+		log.Printf("WARNING: failed to list client roots: %v", err)
+		return nil
+	}
+	var result struct {
+		Roots []struct {
+			URI  string `json:"uri"`
+			Name string `json:"name"`
+		} `json:"roots"`
+	}
+	if err := json.Unmarshal(raw, &result); err != nil {
+		log.Printf("WARNING: failed to parse client roots: %v", err)
+		return nil
+	}
+
+	var roots []clientRoot
+	for _, root := range result.Roots {
+		path, err := rootPath(root.URI)
+		if err != nil {
+			log.Printf("WARNING: ignoring client root %s: %v", root.URI, err)
+			continue
+		}
+		if info, err := os.Stat(path); err != nil || !info.IsDir() {
+			log.Printf("WARNING: ignoring client root %s: not a directory on this host", root.URI)
+			continue
+		}
+		if s.opts.WorkspaceRoot != "" && !withinDir(s.opts.WorkspaceRoot, path) {
+			log.Printf("WARNING: ignoring client root %s: outside the workspace root", root.URI)
+			continue
+		}
+		roots = append(roots, clientRoot{URI: root.URI, Name: root.Name, Path: path})
+	}
+	s.sessions.update(SessionID(ctx), func(state *sessionState) {
+		if state.roots.generation == cache.generation {
+			state.roots = rootsCache{roots: roots, valid: true, generation: cache.generation}
+		}
+	})
+	return roots
+}
+
+// rootPath returns the local path of a file:// root URI
+func rootPath(uri string) (string, error) {
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+	if parsed.Scheme != "file" {
+		return "", fmt.Errorf("unsupported scheme %q", parsed.Scheme)
+	}
+	if parsed.Host != "" && parsed.Host != "localhost" {
+		return "", fmt.Errorf("remote host %s", parsed.Host)
+	}
+	path := parsed.Path
+	// file:///C:/src is C:\src on Windows
+	if runtime.GOOS == "windows" && len(path) > 2 && path[0] == '/' && path[2] == ':' {
+		path = path[1:]
+	}
+	path = filepath.Clean(filepath.FromSlash(path))
+	if !filepath.IsAbs(path) {
+		return "", fmt.Errorf("not an absolute path")
+	}
+	return path, nil
+}
+
+// withinDir reports whether path is dir or below it once symlinks are
+// resolved
+func withinDir(dir, path string) bool {
+	resolvedDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return false
+	}
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(resolvedDir, resolved)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// localProject is a candidate project directory below a root
+type localProject struct {
+	Name string `json:"name"`
+	// Path is the absolute path, usable as a local workspace source
+	Path string `json:"path"`
+	// Root is the path of the root the project was found in
+	Root string `json:"root"`
+	// Markers are the project files found at the top of the directory
+	Markers      []string `json:"markers"`
+	Git          bool     `json:"git"`
+	Devcontainer bool     `json:"devcontainer"`
+	Languages    []string `json:"languages,omitempty"`
+}
+
+// detectProject reports the project markers of a directory, or nil when it
+// does not look like a project
+func detectProject(dir string) *localProject {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	project := &localProject{Name: filepath.Base(dir), Path: dir}
+	languages := make(map[string]bool)
+	for _, entry := range entries {
+		name := entry.Name()
+		marker := false
+		for _, m := range projectMarkers {
+			marker = marker || name == m
+		}
+		if manager, ok := packageManagerFiles[name]; ok {
+			marker = true
+			languages[manager[1]] = true
+		}
+		if !marker {
+			continue
+		}
+		switch name {
+		case ".git":
+			project.Git = true
+		case ".devcontainer.json":
+			project.Devcontainer = true
+		case ".devcontainer":
+			_, err := os.Stat(filepath.Join(dir, name, "devcontainer.json"))
+			project.Devcontainer = project.Devcontainer || err == nil
+		case "package.json":
+			languages["JavaScript"] = true
+		case "pyproject.toml":
+			languages["Python"] = true
+		}
+		project.Markers = append(project.Markers, name)
+	}
+	if len(project.Markers) == 0 {
+		return nil
+	}
+	for language := range languages {
+		project.Languages = append(project.Languages, language)
+	}
+	sort.Strings(project.Languages)
+	return project
+}
+
+// findLocalProjects walks root up to depth levels down and returns the
+// directories that look like projects. Projects are not searched for nested
+// projects, and hidden, dependency and build directories are skipped. It
+// reports whether the search stopped at limit projects.
+func findLocalProjects(root string, depth, limit int) ([]localProject, bool) {
+	var projects []localProject
+	var walk func(dir string, level int) bool
+	walk = func(dir string, level int) bool {
+		if project := detectProject(dir); project != nil {
+			if len(projects) == limit {
+				return false
+			}
+			project.Root = root
+			projects = append(projects, *project)
+			return true
+		}
+		if level == depth {
+			return true
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return true
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if !entry.IsDir() || strings.HasPrefix(name, ".") || skippedDirs[name] {
+				continue
+			}
+			if !walk(filepath.Join(dir, name), level+1) {
+				return false
+			}
+		}
+		return true
+	}
+	complete := walk(root, 0)
+	return projects, !complete
+}
+
+// registerLocalProjectTools registers the tool that finds projects in the
+// client's roots
+func (s *Server) registerLocalProjectTools() {
+	// List local projects
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_listLocalProjects",
+		Description: "List the project directories (git repositories, devcontainers, package manager manifests) in the folders the client shares as roots, or in the workspace root, as local sources for devpod_createWorkspace",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"depth": map[string]interface{}{
+					"type":        "integer",
+					"description": fmt.Sprintf("How many levels below each root to look (0-%d, default %d)", maxAnalyzeDepth, defaultProjectDepth),
+					"minimum":     0,
+					"maximum":     maxAnalyzeDepth,
+				},
+			},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var listParams struct {
+			Depth *int `json:"depth"`
+		}
+
+		if len(params) > 0 {
+			if err := json.Unmarshal(params, &listParams); err != nil {
+				return nil, mcp.NewInvalidParamsError("Invalid list local projects parameters")
+			}
+		}
+		depth := defaultProjectDepth
+		if listParams.Depth != nil {
+			depth = *listParams.Depth
+		}
+		if depth < 0 || depth > maxAnalyzeDepth {
+			return nil, mcp.NewInvalidParamsError(fmt.Sprintf("depth must be between 0 and %d", maxAnalyzeDepth))
+		}
+
+		roots := s.clientRoots(ctx)
+		if len(roots) == 0 && s.opts.WorkspaceRoot != "" {
+			roots = []clientRoot{{Name: "workspace root", Path: s.opts.WorkspaceRoot}}
+		}
+		if roots == nil {
+			roots = []clientRoot{}
+		}
+		projects := []localProject{}
+		truncated := false
+		for _, root := range roots {
+			found, stopped := findLocalProjects(root.Path, depth, maxLocalProjects-len(projects))
+			projects = append(projects, found...)
+			if stopped {
+				truncated = true
+				break
+			}
+		}
+
+		message := fmt.Sprintf("Found %d project(s) in %d root(s)", len(projects), len(roots))
+		if len(roots) == 0 {
+			message = "No roots to search: the client shares no roots and no workspace root is configured"
+		}
+		result := map[string]interface{}{
+			"roots":     roots,
+			"projects":  projects,
+			"count":     len(projects),
+			"truncated": truncated,
+			"message":   message,
+		}
+		return result, nil
+	})
+}
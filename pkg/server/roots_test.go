@@ -0,0 +1,193 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/protobomb/mcp-server-framework/pkg/transport"
+)
+
+func TestRootPath(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("POSIX paths")
+	}
+	tests := []struct {
+		uri  string
+		want string
+	}{
+		{"file:///home/dev/src", "/home/dev/src"},
+		{"file://localhost/home/dev/my%20app/", "/home/dev/my app"},
+		{"https://example.com/src", ""},
+		{"file://otherhost/src", ""},
+	}
+	for _, tt := range tests {
+		path, err := rootPath(tt.uri)
+		if path != tt.want || (tt.want == "") != (err != nil) {
+			t.Errorf("rootPath(%q) = %q, %v; want %q", tt.uri, path, err, tt.want)
+		}
+	}
+}
+
+func TestFindLocalProjects(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"api/go.mod":                          "module api",
+		"api/cmd/tool/go.mod":                 "module tool",
+		"web/package.json":                    "{}",
+		"web/.devcontainer/devcontainer.json": "{}",
+		"group/svc/pyproject.toml":            "",
+		"group/deep/er/Cargo.toml":            "",
+		"notes/readme.txt":                    "",
+		"node_modules/dep/package.json":       "{}",
+		".cache/thing/go.mod":                 "",
+	})
+
+	projects, truncated := findLocalProjects(root, defaultProjectDepth, maxLocalProjects)
+	if truncated {
+		t.Error("Expected the search to be complete")
+	}
+	var names []string
+	for _, project := range projects {
+		names = append(names, project.Name)
+	}
+	if fmt.Sprint(names) != "[api svc web]" {
+		t.Fatalf("Expected api, svc and web, got %v", names)
+	}
+	if web := projects[2]; !web.Devcontainer || fmt.Sprint(web.Languages) != "[JavaScript]" || web.Root != root {
+		t.Errorf("Unexpected web project %+v", web)
+	}
+
+	if projects, truncated := findLocalProjects(root, defaultProjectDepth, 1); len(projects) != 1 || !truncated {
+		t.Errorf("Expected the search to stop at the limit, got %d projects (truncated %v)", len(projects), truncated)
+	}
+}
+
+func TestClientRoots(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"app/.git/HEAD": "ref: refs/heads/main"})
+	resolvedRoot, _ := filepath.EvalSymlinks(root)
+
+	clientIn, serverOut := io.Pipe()
+	serverIn, clientOut := io.Pipe()
+	s := New(transport.NewSTDIOTransportWithIO(serverIn, serverOut), Options{
+		Runner:    &fakeRunner{},
+		StatePath: filepath.Join(t.TempDir(), "state.json"),
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := s.Start(ctx); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer s.Stop()
+
+	// Act as a client sharing one local root and one remote root
+	var requests atomic.Int32
+	go func() {
+		scanner := bufio.NewScanner(clientIn)
+		for scanner.Scan() {
+			var request struct {
+				ID     interface{} `json:"id"`
+				Method string      `json:"method"`
+			}
+			if json.Unmarshal(scanner.Bytes(), &request) != nil || request.Method != "roots/list" {
+				continue
+			}
+			requests.Add(1)
+			response, _ := json.Marshal(map[string]interface{}{
+				"jsonrpc": "2.0",
+				"id":      request.ID,
+				"result": map[string]interface{}{
+					"roots": []map[string]interface{}{
+						{"uri": (&url.URL{Scheme: "file", Path: filepath.ToSlash(root)}).String(), "name": "projects"},
+						{"uri": "https://example.com/repo"},
+					},
+				},
+			})
+			clientOut.Write(append(response, '\n'))
+		}
+	}()
+
+	s.setClientCapabilities(ctx, json.RawMessage(`{"capabilities":{"roots":{"listChanged":true}}}`))
+	if path, err := s.resolveLocalSource(ctx, "./app"); err != nil || path != filepath.Join(resolvedRoot, "app") {
+		t.Errorf("Expected ./app to resolve in the client root, got %s (%v)", path, err)
+	}
+	if path, err := s.localSourceArg(ctx, "app"); err != nil || path != filepath.Join(resolvedRoot, "app") {
+		t.Errorf("Expected the bare name to resolve in the client root, got %s (%v)", path, err)
+	}
+
+	result, err := s.MCP().GetHandler("devpod_listLocalProjects")(ctx, json.RawMessage(`{}`))
+	if err != nil {
+		t.Fatalf("devpod_listLocalProjects failed: %v", err)
+	}
+	listed := result.(map[string]interface{})
+	if roots := listed["roots"].([]clientRoot); len(roots) != 1 || roots[0].Name != "projects" {
+		t.Errorf("Expected only the local root, got %+v", roots)
+	}
+	if projects := listed["projects"].([]localProject); len(projects) != 1 || projects[0].Name != "app" || !projects[0].Git {
+		t.Errorf("Unexpected projects %+v", projects)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("Expected the roots to be requested once, got %d requests", n)
+	}
+
+	s.MCP().GetNotificationHandler("notifications/roots/list_changed")(ctx, nil)
+	s.clientRoots(ctx)
+	if n := requests.Load(); n != 2 {
+		t.Errorf("Expected the roots to be requested again after a change, got %d requests", n)
+	}
+}
+
+func TestClientRootsArePerSession(t *testing.T) {
+	roots := map[string]string{"a": t.TempDir(), "b": t.TempDir()}
+	transport := &queueTransport{messages: make(chan sessionMessage, 10)}
+	s := New(transport, Options{Runner: &fakeRunner{}, StatePath: filepath.Join(t.TempDir(), "state.json")})
+
+	// Act as the clients of both sessions, each sharing its own root
+	var mu sync.Mutex
+	requests := make(map[string]int)
+	go func() {
+		for sent := range transport.messages {
+			var request struct {
+				ID string `json:"id"`
+			}
+			json.Unmarshal(sent.message, &request)
+			mu.Lock()
+			requests[sent.session]++
+			mu.Unlock()
+			uri := (&url.URL{Scheme: "file", Path: filepath.ToSlash(roots[sent.session])}).String()
+			s.requests.deliver(sent.session, []byte(`{"jsonrpc":"2.0","id":"`+request.ID+`","result":{"roots":[{"uri":"`+uri+`"}]}}`))
+		}
+	}()
+	defer close(transport.messages)
+
+	sessions := map[string]context.Context{}
+	for id := range roots {
+		sessions[id] = WithSessionID(context.Background(), id)
+		s.setClientCapabilities(sessions[id], json.RawMessage(`{"capabilities":{"roots":{"listChanged":true}}}`))
+	}
+	for id, ctx := range sessions {
+		if got := s.clientRoots(ctx); len(got) != 1 || got[0].Path != roots[id] {
+			t.Errorf("Expected session %s to see only its root %s, got %+v", id, roots[id], got)
+		}
+	}
+
+	s.MCP().GetNotificationHandler("notifications/roots/list_changed")(sessions["a"], nil)
+	for _, ctx := range sessions {
+		s.clientRoots(ctx)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if requests["a"] != 2 || requests["b"] != 1 {
+		t.Errorf("Expected only session a to be asked again after its change, got %v", requests)
+	}
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"regexp"
+	"strings"
+)
+
+// ansiEscape matches terminal escape sequences: CSI sequences such as colors
+// and cursor movement, OSC sequences such as window titles and hyperlinks,
+// and two-character escapes
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)|\x1b[@-Z\\-_]`)
+
+// controlChars matches control characters other than newline and tab that
+// remain once escape sequences are removed
+var controlChars = regexp.MustCompile(`[\x00-\x08\x0b\x0c\x0e-\x1f\x7f]`)
+
+// spinnerLine matches a progress line drawn with a spinner glyph, capturing
+// its message without a trailing elapsed time such as "(3s)"
+var spinnerLine = regexp.MustCompile(`^\s*[⠋⠙⠹⠸⠼⠴⠦⠧⠇⠏⣾⣽⣻⢿⡿⣟⣯⣷|/\\-]\s+(.*?)(?:\s*\(\d+(?:\.\d+)?m?s\))?\s*$`)
+
+// sanitizeOutput turns terminal output into plain text: escape sequences and
+// control characters are removed, lines redrawn with carriage returns keep
+// only their final state, and runs of the same spinner line collapse into
+// the last one
+func sanitizeOutput(output string) string {
+	output = strings.ReplaceAll(output, "\r\n", "\n")
+	output = ansiEscape.ReplaceAllString(output, "")
+
+	lines := strings.Split(output, "\n")
+	cleaned := make([]string, 0, len(lines))
+	lastSpinner := ""
+	for _, line := range lines {
+		// A carriage return redraws the line; the terminal shows what was drawn last
+		if strings.Contains(line, "\r") {
+			segments := strings.Split(line, "\r")
+			line = ""
+			for i := len(segments) - 1; i >= 0; i-- {
+				if strings.TrimSpace(segments[i]) != "" {
+					line = segments[i]
+					break
+				}
+			}
+		}
+		line = controlChars.ReplaceAllString(line, "")
+
+		if match := spinnerLine.FindStringSubmatch(line); match != nil {
+			if match[1] == lastSpinner && len(cleaned) > 0 {
+				cleaned[len(cleaned)-1] = line
+				continue
+			}
+			lastSpinner = match[1]
+		} else {
+			lastSpinner = ""
+		}
+		cleaned = append(cleaned, line)
+	}
+	return strings.Join(cleaned, "\n")
+}
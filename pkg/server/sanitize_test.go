@@ -0,0 +1,21 @@
+package server
+
+import "testing"
+
+func TestSanitizeOutput(t *testing.T) {
+	tests := []struct {
+		name, output, want string
+	}{
+		{"colors", "\x1b[1;32minfo\x1b[0m Workspace started\r\n", "info Workspace started\n"},
+		{"hyperlink", "see \x1b]8;;https://devpod.sh\x07docs\x1b]8;;\x07", "see docs"},
+		{"progress", "Downloading 10%\rDownloading 55%\rDownloading 100%\ndone", "Downloading 100%\ndone"},
+		{"spinner", "⠋ Creating container (1s)\n⠙ Creating container (2s)\n⠹ Creating container (3s)\nContainer created", "⠹ Creating container (3s)\nContainer created"},
+		{"controls", "bell\x07 and\x08 nul\x00", "bell and nul"},
+		{"tabs", "a\tb\n", "a\tb\n"},
+	}
+	for _, tt := range tests {
+		if got := sanitizeOutput(tt.output); got != tt.want {
+			t.Errorf("%s: sanitizeOutput(%q) = %q; want %q", tt.name, tt.output, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,501 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+// schedulerInterval is how often the scheduler looks for due operations
+const schedulerInterval = time.Minute
+
+// scheduledOperation is an operation the scheduler runs on a workspace at
+// cron times, once at a given time, or once the workspace has been idle
+type scheduledOperation struct {
+	ID        string `json:"id"`
+	Workspace string `json:"workspace"`
+	// User is the authenticated user who scheduled the operation, whose
+	// DEVPOD_HOME and devpod context it runs with
+	User string `json:"user,omitempty"`
+	// Action is stop, start or delete
+	Action string `json:"action"`
+	// Cron is a five-field cron expression evaluated in Timezone
+	Cron     string `json:"cron,omitempty"`
+	Timezone string `json:"timezone,omitempty"`
+	// At runs the operation once at this time
+	At *time.Time `json:"at,omitempty"`
+	// IdleFor runs the operation whenever the workspace has been unused
+	// this long, e.g. "7 days"
+	IdleFor string `json:"idleFor,omitempty"`
+	// InactiveFor runs the operation whenever no tool call has named the
+	// workspace for this long, e.g. "30m". Auto-stop is a stop operation
+	// of this kind.
+	InactiveFor string     `json:"inactiveFor,omitempty"`
+	Created     time.Time  `json:"created"`
+	NextRun     *time.Time `json:"nextRun,omitempty"`
+	LastRun     *time.Time `json:"lastRun,omitempty"`
+	LastError   string     `json:"lastError,omitempty"`
+	Runs        int        `json:"runs"`
+}
+
+// newScheduleID returns a random schedule ID
+func newScheduleID() string {
+	bytes := make([]byte, 6)
+	if _, err := rand.Read(bytes); err != nil {
+		return fmt.Sprintf("sch-%d", time.Now().UnixNano())
+	}
+	return "sch-" + hex.EncodeToString(bytes)
+}
+
+// location returns the time zone the cron expression is evaluated in
+func (op scheduledOperation) location() (*time.Location, error) {
+	if op.Timezone == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(op.Timezone)
+}
+
+// idleThreshold returns the idle period of an idle-triggered operation
+func (op scheduledOperation) idleThreshold() (time.Duration, error) {
+	seconds, ok := parseDurationSeconds(op.IdleFor)
+	if !ok || seconds <= 0 {
+		return 0, fmt.Errorf("idleFor must be a positive duration such as 2h or 7 days")
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// inactivityThreshold returns the period of an inactivity-triggered
+// operation
+func (op scheduledOperation) inactivityThreshold() (time.Duration, error) {
+	seconds, ok := parseDurationSeconds(op.InactiveFor)
+	if !ok || seconds <= 0 {
+		return 0, fmt.Errorf("inactiveFor must be a positive duration such as 30m or 2h")
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// recurring reports whether an operation runs whenever its condition holds
+// rather than at set times
+func (op scheduledOperation) recurring() bool {
+	return op.IdleFor != "" || op.InactiveFor != ""
+}
+
+// autoStop reports whether an operation is the auto-stop of its workspace
+func (op scheduledOperation) autoStop() bool {
+	return op.Action == "stop" && op.InactiveFor != ""
+}
+
+// validate checks an operation has a known action and exactly one trigger
+func (op scheduledOperation) validate() error {
+	switch op.Action {
+	case "stop", "start", "delete":
+	default:
+		return fmt.Errorf("action must be one of: stop, start, delete")
+	}
+
+	triggers := 0
+	if op.Cron != "" {
+		triggers++
+		if _, err := parseCron(op.Cron); err != nil {
+			return err
+		}
+		if _, err := op.location(); err != nil {
+			return fmt.Errorf("unknown timezone %q", op.Timezone)
+		}
+	}
+	if op.At != nil {
+		triggers++
+	}
+	if op.IdleFor != "" {
+		triggers++
+		if _, err := op.idleThreshold(); err != nil {
+			return err
+		}
+		if op.Action == "start" {
+			return fmt.Errorf("idleFor cannot start a workspace; use cron or at")
+		}
+	}
+	if op.InactiveFor != "" {
+		triggers++
+		if _, err := op.inactivityThreshold(); err != nil {
+			return err
+		}
+		if op.Action == "start" {
+			return fmt.Errorf("inactiveFor cannot start a workspace; use cron or at")
+		}
+	}
+	if triggers != 1 {
+		return fmt.Errorf("exactly one of cron, at, idleFor or inactiveFor is required")
+	}
+	return nil
+}
+
+// scheduleNext sets the next run of a cron or one-time operation after t.
+// Recurring operations have no fixed next run.
+func (op *scheduledOperation) scheduleNext(t time.Time) {
+	op.NextRun = nil
+	switch {
+	case op.At != nil:
+		if op.Runs == 0 {
+			at := *op.At
+			op.NextRun = &at
+		}
+	case op.Cron != "":
+		schedule, err := parseCron(op.Cron)
+		if err != nil {
+			return
+		}
+		loc, err := op.location()
+		if err != nil {
+			return
+		}
+		if next, ok := schedule.next(t.In(loc)); ok {
+			next = next.UTC()
+			op.NextRun = &next
+		}
+	}
+}
+
+// runDueSchedules runs the operations of every user that are due at now,
+// concurrently within the command limits, and records their outcome. Each
+// runs as the user who scheduled it.
+func (s *Server) runDueSchedules(ctx context.Context, now time.Time) {
+	var due []scheduledOperation
+	// idle holds the idle seconds of the workspaces with idle schedules by user
+	idle := make(map[string]map[string]int64)
+	for _, user := range s.stateUsers() {
+		for _, op := range s.store.forUser(user).Schedules() {
+			op.User = user
+			switch {
+			case op.IdleFor != "":
+				if idle[op.User] == nil {
+					idle[op.User] = make(map[string]int64)
+				}
+				idle[op.User][op.Workspace] = -1
+				due = append(due, op)
+			case op.InactiveFor != "":
+				due = append(due, op)
+			case op.NextRun != nil && !op.NextRun.After(now):
+				due = append(due, op)
+			}
+		}
+	}
+	if len(due) == 0 {
+		return
+	}
+
+	for user, workspaceIdle := range idle {
+		workspaces, err := s.listWorkspaces(WithUser(ctx, user))
+		if err != nil {
+			s.reportUserEvent(user, "warning", "scheduler", fmt.Errorf("failed to list workspaces for idle schedules: %w", err))
+		}
+		for _, workspace := range workspaces {
+			if _, ok := workspaceIdle[workspace.ID]; ok && workspace.IdleSeconds != nil {
+				workspaceIdle[workspace.ID] = *workspace.IdleSeconds
+			}
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, op := range due {
+		if op.IdleFor != "" {
+			threshold, err := op.idleThreshold()
+			if err != nil || idle[op.User][op.Workspace] < 0 || time.Duration(idle[op.User][op.Workspace])*time.Second < threshold {
+				continue
+			}
+		}
+		if op.InactiveFor != "" {
+			threshold, err := op.inactivityThreshold()
+			if err != nil || s.activity.inactive(op.User, op.Workspace, now) < threshold {
+				continue
+			}
+		}
+		wg.Add(1)
+		go func(op scheduledOperation) {
+			defer wg.Done()
+			s.runSchedule(WithUser(ctx, op.User), op, now)
+		}(op)
+	}
+	wg.Wait()
+}
+
+// runSchedule runs a due operation and records its outcome. Operations that
+// will not run again are removed.
+func (s *Server) runSchedule(ctx context.Context, op scheduledOperation, now time.Time) {
+	ran, err := s.scheduledAction(ctx, op)
+	if !ran && err == nil && op.recurring() {
+		return
+	}
+	if ran || err != nil {
+		op.Runs++
+		op.LastRun = &now
+		op.LastError = ""
+		if err != nil {
+			op.LastError = err.Error()
+			s.reportUserEvent(op.User, "warning", "scheduler", fmt.Errorf("scheduled %s of %s failed: %w", op.Action, op.Workspace, err))
+		} else {
+			infof("Scheduler: %s %s (%s)", op.Action, op.Workspace, op.ID)
+		}
+	}
+
+	op.scheduleNext(now)
+	if op.NextRun == nil && !op.recurring() {
+		s.state(ctx).DeleteSchedule(op.ID)
+		return
+	}
+	s.state(ctx).UpdateSchedule(op)
+}
+
+// scheduledAction carries out a scheduled operation. It reports false when
+// the workspace is already in the requested state.
+func (s *Server) scheduledAction(ctx context.Context, op scheduledOperation) (bool, error) {
+	release, err := s.lockWorkspace(ctx, op.Workspace, "schedule "+op.ID)
+	if err != nil {
+		return false, err
+	}
+	defer release()
+
+	state := s.getWorkspaceState(ctx, op.Workspace)
+	switch op.Action {
+	case "stop":
+		if state != "Running" {
+			return false, nil
+		}
+		message := fmt.Sprintf("Workspace stopped by schedule %s", op.ID)
+		if op.InactiveFor != "" {
+			message = fmt.Sprintf("Workspace stopped after %s without tool activity (schedule %s)", op.InactiveFor, op.ID)
+		}
+		_, err := s.stopWorkspace(ctx, op.Workspace, message)
+		return true, err
+	case "start":
+		if state == "Running" {
+			return false, nil
+		}
+		_, _, err := s.startWorkspace(ctx, op.Workspace, "")
+		return true, err
+	case "delete":
+		if state == "NotFound" {
+			return false, nil
+		}
+		deleted := s.captureDeletedByName(ctx, op.Workspace)
+		output, err := s.combinedOutput(ctx, []string{"delete", op.Workspace, "--force"})
+		if err != nil {
+			s.state(ctx).RecordEvent(op.Workspace, "error", fmt.Sprintf("scheduled delete failed: %v", err))
+			return true, newDevPodError("failed to delete workspace", err, output)
+		}
+		s.recordDeletion(ctx, op.Workspace, fmt.Sprintf("Workspace deleted by schedule %s", op.ID), deleted)
+		return true, nil
+	}
+	return false, fmt.Errorf("unknown action %q", op.Action)
+}
+
+// runScheduler runs due scheduled operations until ctx is cancelled
+func (s *Server) runScheduler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.runDueSchedules(ctx, now.UTC())
+		}
+	}
+}
+
+// registerScheduleTools registers the tools that schedule operations
+func (s *Server) registerScheduleTools() {
+	// Schedule operation
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_scheduleOperation",
+		Description: "Schedule a workspace to be stopped, started or deleted at cron times (e.g. stop daily at 19:00), once at a given time, or once it has been idle for a period (e.g. delete after 7 days idle) or no tool call has named it for a period (e.g. stop after 30m without activity). Schedules are persisted and run by the server in the background.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "The name of the workspace",
+				},
+				"action": map[string]interface{}{
+					"type":        "string",
+					"enum":        []string{"stop", "start", "delete"},
+					"description": "What to do with the workspace",
+				},
+				"cron": map[string]interface{}{
+					"type":        "string",
+					"description": "Five-field cron expression (minute hour day-of-month month day-of-week), e.g. \"0 19 * * 1-5\" or @daily",
+				},
+				"timezone": map[string]interface{}{
+					"type":        "string",
+					"description": "IANA time zone the cron expression is evaluated in, e.g. Europe/Berlin (default: the server's local time zone)",
+				},
+				"at": map[string]interface{}{
+					"type":        "string",
+					"description": "RFC 3339 time to run the operation once, e.g. 2024-06-01T18:00:00Z",
+				},
+				"idleFor": map[string]interface{}{
+					"type":        "string",
+					"description": "Run the operation once the workspace has been unused this long, e.g. 2h or 7 days (stop or delete only)",
+				},
+				"inactiveFor": map[string]interface{}{
+					"type":        "string",
+					"description": "Run the operation once no tool call has named the workspace for this long, e.g. 30m (stop or delete only)",
+				},
+			},
+			"required": []string{"name", "action"},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var scheduleParams struct {
+			Name        string `json:"name"`
+			Action      string `json:"action"`
+			Cron        string `json:"cron,omitempty"`
+			Timezone    string `json:"timezone,omitempty"`
+			At          string `json:"at,omitempty"`
+			IdleFor     string `json:"idleFor,omitempty"`
+			InactiveFor string `json:"inactiveFor,omitempty"`
+		}
+
+		if err := json.Unmarshal(params, &scheduleParams); err != nil {
+			return nil, mcp.NewInvalidParamsError("Invalid schedule operation parameters")
+		}
+
+		if scheduleParams.Name == "" {
+			return nil, mcp.NewInvalidParamsError("Workspace name is required")
+		}
+
+		now := time.Now().UTC()
+		op := scheduledOperation{
+			ID:          newScheduleID(),
+			Workspace:   scheduleParams.Name,
+			User:        UserName(ctx),
+			Action:      scheduleParams.Action,
+			Cron:        scheduleParams.Cron,
+			Timezone:    scheduleParams.Timezone,
+			IdleFor:     scheduleParams.IdleFor,
+			InactiveFor: scheduleParams.InactiveFor,
+			Created:     now,
+		}
+		if scheduleParams.At != "" {
+			at, err := time.Parse(time.RFC3339, scheduleParams.At)
+			if err != nil {
+				return nil, mcp.NewInvalidParamsError("at must be an RFC 3339 time such as 2024-06-01T18:00:00Z")
+			}
+			if !at.After(now) {
+				return nil, mcp.NewInvalidParamsError("at must be in the future")
+			}
+			at = at.UTC()
+			op.At = &at
+		}
+		if op.Timezone != "" && op.Cron == "" {
+			return nil, mcp.NewInvalidParamsError("timezone only applies to cron schedules")
+		}
+		if err := op.validate(); err != nil {
+			return nil, mcp.NewInvalidParamsError(err.Error())
+		}
+		op.scheduleNext(now)
+		if op.Cron != "" && op.NextRun == nil {
+			return nil, mcp.NewInvalidParamsError(fmt.Sprintf("cron expression %q never matches", op.Cron))
+		}
+
+		exists, err := s.workspaceExists(ctx, op.Workspace)
+		if err != nil {
+			return nil, newDevPodError("failed to list workspaces", err, nil)
+		}
+		if !exists {
+			return nil, mcp.NewInvalidParamsError(fmt.Sprintf("Workspace %s not found", op.Workspace))
+		}
+
+		s.state(ctx).SetSchedule(op)
+		message := fmt.Sprintf("Scheduled %s of %s after %s idle", op.Action, op.Workspace, op.IdleFor)
+		if op.InactiveFor != "" {
+			message = fmt.Sprintf("Scheduled %s of %s after %s without tool activity", op.Action, op.Workspace, op.InactiveFor)
+		}
+		if op.NextRun != nil {
+			message = fmt.Sprintf("Scheduled %s of %s; next run at %s", op.Action, op.Workspace, op.NextRun.Format(time.RFC3339))
+		}
+		return map[string]interface{}{
+			"id":       op.ID,
+			"schedule": op,
+			"message":  message,
+		}, nil
+	})
+
+	// List schedules
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_listSchedules",
+		Description: "List scheduled workspace operations with their next and last runs",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "Only list the schedules of this workspace (optional)",
+				},
+			},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var listParams struct {
+			Name string `json:"name,omitempty"`
+		}
+
+		if len(params) > 0 {
+			if err := json.Unmarshal(params, &listParams); err != nil {
+				return nil, mcp.NewInvalidParamsError("Invalid list schedules parameters")
+			}
+		}
+
+		schedules := []scheduledOperation{}
+		for _, op := range s.state(ctx).Schedules() {
+			if listParams.Name == "" || op.Workspace == listParams.Name {
+				schedules = append(schedules, op)
+			}
+		}
+		return map[string]interface{}{
+			"schedules": schedules,
+			"count":     len(schedules),
+		}, nil
+	})
+
+	// Cancel schedule
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_cancelSchedule",
+		Description: "Cancel a scheduled workspace operation",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"id": map[string]interface{}{
+					"type":        "string",
+					"description": "The schedule ID returned by devpod_scheduleOperation",
+				},
+			},
+			"required": []string{"id"},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var cancelParams struct {
+			ID string `json:"id"`
+		}
+
+		if err := json.Unmarshal(params, &cancelParams); err != nil {
+			return nil, mcp.NewInvalidParamsError("Invalid cancel schedule parameters")
+		}
+
+		if cancelParams.ID == "" {
+			return nil, mcp.NewInvalidParamsError("Schedule ID is required")
+		}
+
+		if !s.state(ctx).DeleteSchedule(cancelParams.ID) {
+			return nil, mcp.NewInvalidParamsError(fmt.Sprintf("Schedule %s not found", cancelParams.ID))
+		}
+		return map[string]interface{}{
+			"id":      cancelParams.ID,
+			"message": "Schedule cancelled",
+		}, nil
+	})
+}
@@ -0,0 +1,147 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/protobomb/mcp-server-framework/pkg/transport"
+)
+
+func TestCronNext(t *testing.T) {
+	base := time.Date(2024, 3, 15, 18, 30, 0, 0, time.UTC) // a Friday
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"0 19 * * *", time.Date(2024, 3, 15, 19, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, 3, 15, 18, 45, 0, 0, time.UTC)},
+		{"0 9 * * mon-fri", time.Date(2024, 3, 18, 9, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2024, 3, 16, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 feb *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// Restricted day-of-month and day-of-week match either
+		{"0 0 20 * 7", time.Date(2024, 3, 17, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		schedule, err := parseCron(tt.expr)
+		if err != nil {
+			t.Errorf("parseCron(%q) failed: %v", tt.expr, err)
+			continue
+		}
+		if got, ok := schedule.next(base); !ok || !got.Equal(tt.want) {
+			t.Errorf("next(%q) = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+
+	if schedule, err := parseCron("0 0 30 2 *"); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	} else if _, ok := schedule.next(base); ok {
+		t.Error("Expected 30 February never to match")
+	}
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* * * * 8", "5-1 * * * *", "*/0 * * * *", "x * * * *"} {
+		if _, err := parseCron(expr); err == nil {
+			t.Errorf("Expected %q to be rejected", expr)
+		}
+	}
+}
+
+func TestScheduleOperation(t *testing.T) {
+	stale := time.Now().Add(-10 * 24 * time.Hour).UTC().Format(time.RFC3339)
+	runner := &fakeRunner{outputs: map[string]string{
+		"list --output json":       fmt.Sprintf(`[{"id":"api","lastUsed":%q},{"id":"web"},{"id":"old","lastUsed":%q}]`, stale, stale),
+		"status api --output json": `{"state":"Running"}`,
+		"status web --output json": `{"state":"Stopped"}`,
+		"status old --output json": `{"state":"Stopped"}`,
+		"stop api":                 "",
+		"delete old --force":       "",
+	}}
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	s := New(transport.NewSTDIOTransport(), Options{Runner: runner, StatePath: statePath})
+	ctx := context.Background()
+
+	schedule := s.MCP().GetHandler("devpod_scheduleOperation")
+	result, err := schedule(ctx, json.RawMessage(`{"name":"api","action":"stop","cron":"0 19 * * *","timezone":"UTC"}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	stop := result.(map[string]interface{})["schedule"].(scheduledOperation)
+	if stop.NextRun == nil || stop.NextRun.Hour() != 19 || stop.NextRun.Minute() != 0 {
+		t.Errorf("Expected the next run at 19:00, got %v", stop.NextRun)
+	}
+	if _, err := schedule(ctx, json.RawMessage(`{"name":"old","action":"delete","idleFor":"7 days"}`)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := schedule(ctx, json.RawMessage(`{"name":"web","action":"start","at":"2099-01-01T08:00:00Z"}`)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for _, params := range []string{
+		`{"name":"api","action":"stop"}`,
+		`{"name":"api","action":"stop","cron":"@daily","idleFor":"1h"}`,
+		`{"name":"api","action":"restart","cron":"@daily"}`,
+		`{"name":"api","action":"start","idleFor":"1h"}`,
+		`{"name":"api","action":"start","inactiveFor":"1h"}`,
+		`{"name":"api","action":"stop","cron":"@daily","timezone":"Mars/Olympus"}`,
+		`{"name":"api","action":"stop","at":"2000-01-01T00:00:00Z"}`,
+		`{"name":"missing","action":"stop","cron":"@daily"}`,
+	} {
+		if _, err := schedule(ctx, json.RawMessage(params)); err == nil {
+			t.Errorf("Expected %s to be rejected", params)
+		}
+	}
+
+	// Schedules survive a restart and run once due
+	s = New(transport.NewSTDIOTransport(), Options{Runner: runner, StatePath: statePath})
+	s.runDueSchedules(ctx, stop.NextRun.Add(time.Second))
+
+	schedules := s.store.Schedules()
+	if len(schedules) != 2 {
+		t.Fatalf("Expected the stop and start schedules to remain, got %+v", schedules)
+	}
+	for _, op := range schedules {
+		switch op.Workspace {
+		case "api":
+			if op.Runs != 1 || op.LastError != "" || op.NextRun == nil || !op.NextRun.After(*stop.NextRun) {
+				t.Errorf("Expected the stop to run and be rescheduled, got %+v", op)
+			}
+		case "web":
+			if op.Runs != 0 {
+				t.Errorf("Expected the start not to be due yet, got %+v", op)
+			}
+		default:
+			t.Errorf("Expected the idle delete to be removed with its workspace, got %+v", op)
+		}
+	}
+	var stopped, deleted bool
+	for _, call := range runner.calls {
+		switch fmt.Sprint(call) {
+		case "[stop api]":
+			stopped = true
+		case "[delete old --force]":
+			deleted = true
+		}
+	}
+	if !stopped || !deleted {
+		t.Errorf("Expected api to be stopped and old deleted, got calls %v", runner.calls)
+	}
+
+	result, err = s.MCP().GetHandler("devpod_listSchedules")(ctx, json.RawMessage(`{"name":"web"}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	listed := result.(map[string]interface{})["schedules"].([]scheduledOperation)
+	if len(listed) != 1 {
+		t.Fatalf("Expected one schedule for web, got %+v", listed)
+	}
+	cancel := s.MCP().GetHandler("devpod_cancelSchedule")
+	if _, err := cancel(ctx, json.RawMessage(fmt.Sprintf(`{"id":%q}`, listed[0].ID))); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := cancel(ctx, json.RawMessage(fmt.Sprintf(`{"id":%q}`, listed[0].ID))); err == nil {
+		t.Error("Expected cancelling twice to fail")
+	}
+}
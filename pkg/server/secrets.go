@@ -0,0 +1,437 @@
+package server
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+// secretKeyEnv names the environment variable holding the passphrase secrets
+// are encrypted with. Without it a random key is kept next to the state file.
+const secretKeyEnv = "DEVPOD_MCP_SECRET_KEY"
+
+// secretKeyFile is the name of the generated key file in the state directory
+const secretKeyFile = "secret.key"
+
+// secretName matches names usable as environment variables
+var secretName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// storedSecret is a secret as persisted in the state file. The value is
+// only ever held encrypted.
+type storedSecret struct {
+	Name string `json:"name"`
+	// Value is the AES-GCM sealed value with its nonce, base64 encoded
+	Value string `json:"value"`
+	// Env is the environment variable the secret is injected as
+	Env string `json:"env,omitempty"`
+	// File is the path inside the workspace the secret is written to
+	File string `json:"file,omitempty"`
+	// Workspaces limits injection to these workspaces; empty means all
+	Workspaces []string  `json:"workspaces,omitempty"`
+	Updated    time.Time `json:"updated"`
+}
+
+// secretInfo describes a secret without its value
+type secretInfo struct {
+	Name       string    `json:"name"`
+	Env        string    `json:"env,omitempty"`
+	File       string    `json:"file,omitempty"`
+	Workspaces []string  `json:"workspaces"`
+	Updated    time.Time `json:"updated"`
+}
+
+// info returns the listing of a secret
+func (secret storedSecret) info() secretInfo {
+	workspaces := secret.Workspaces
+	if workspaces == nil {
+		workspaces = []string{}
+	}
+	return secretInfo{Name: secret.Name, Env: secret.Env, File: secret.File, Workspaces: workspaces, Updated: secret.Updated}
+}
+
+// appliesTo reports whether the secret is injected into a workspace of its
+// user. Secrets without workspaces go into all of them.
+func (secret storedSecret) appliesTo(workspace string) bool {
+	if len(secret.Workspaces) == 0 {
+		return true
+	}
+	for _, name := range secret.Workspaces {
+		if name == workspace {
+			return true
+		}
+	}
+	return false
+}
+
+// secretKey returns the key secrets are encrypted with: a hash of
+// DEVPOD_MCP_SECRET_KEY when set, otherwise a random key generated once into
+// the state directory. Without a state directory secrets live in memory
+// only, so a key for this process suffices.
+func (s *Server) secretKey() ([]byte, error) {
+	s.secretMu.Lock()
+	defer s.secretMu.Unlock()
+	if s.secretKeyBytes != nil {
+		return s.secretKeyBytes, nil
+	}
+
+	if passphrase := os.Getenv(secretKeyEnv); passphrase != "" {
+		key := sha256.Sum256([]byte(passphrase))
+		s.secretKeyBytes = key[:]
+		return s.secretKeyBytes, nil
+	}
+
+	key := make([]byte, 32)
+	if s.opts.DataDir == "" {
+		if _, err := io.ReadFull(rand.Reader, key); err != nil {
+			return nil, err
+		}
+		s.secretKeyBytes = key
+		return key, nil
+	}
+
+	path := filepath.Join(s.opts.DataDir, secretKeyFile)
+	encoded, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		key, err = base64.StdEncoding.DecodeString(strings.TrimSpace(string(encoded)))
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("invalid secret key file %s", path)
+		}
+	case os.IsNotExist(err):
+		if _, err := io.ReadFull(rand.Reader, key); err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			return nil, fmt.Errorf("failed to create secret key directory: %w", err)
+		}
+		if err := os.WriteFile(path, []byte(base64.StdEncoding.EncodeToString(key)+"\n"), 0o600); err != nil {
+			return nil, fmt.Errorf("failed to write secret key file: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("failed to read secret key file: %w", err)
+	}
+	s.secretKeyBytes = key
+	return key, nil
+}
+
+// sealSecret encrypts a secret value
+func sealSecret(key []byte, value string) (string, error) {
+	gcm, err := secretCipher(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, []byte(value), nil)), nil
+}
+
+// openSecret decrypts a value sealed by sealSecret
+func openSecret(key []byte, sealed string) (string, error) {
+	gcm, err := secretCipher(key)
+	if err != nil {
+		return "", err
+	}
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil || len(data) < gcm.NonceSize() {
+		return "", errors.New("malformed secret")
+	}
+	value, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", errors.New("secret cannot be decrypted; was the secret key changed?")
+	}
+	return string(value), nil
+}
+
+func secretCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// workspaceSecrets returns the decrypted secrets the user of ctx injects
+// into a workspace
+func (s *Server) workspaceSecrets(ctx context.Context, workspace string) ([]storedSecret, error) {
+	var secrets []storedSecret
+	for _, secret := range s.state(ctx).Secrets() {
+		if !secret.appliesTo(workspace) {
+			continue
+		}
+		key, err := s.secretKey()
+		if err != nil {
+			return nil, err
+		}
+		value, err := openSecret(key, secret.Value)
+		if err != nil {
+			return nil, fmt.Errorf("secret %s: %w", secret.Name, err)
+		}
+		s.redactor.add(value)
+		secret.Value = value
+		secrets = append(secrets, secret)
+	}
+	return secrets, nil
+}
+
+// secretUpArgs returns the `devpod up` arguments that inject the environment
+// variable secrets of a workspace. The values are passed in a private
+// temporary file, never on the command line; cleanup removes it once devpod
+// has run.
+func (s *Server) secretUpArgs(ctx context.Context, workspace string) ([]string, func(), error) {
+	secrets, err := s.workspaceSecrets(ctx, workspace)
+	if err != nil {
+		return nil, func() {}, err
+	}
+
+	var lines []string
+	for _, secret := range secrets {
+		if secret.Env != "" {
+			lines = append(lines, secret.Env+"="+secret.Value)
+		}
+	}
+	if len(lines) == 0 {
+		return nil, func() {}, nil
+	}
+
+	file, err := os.CreateTemp("", "devpod-secrets-*.env")
+	if err != nil {
+		return nil, func() {}, fmt.Errorf("failed to write secrets file: %w", err)
+	}
+	cleanup := func() { os.Remove(file.Name()) }
+	_, err = file.WriteString(strings.Join(lines, "\n") + "\n")
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		cleanup()
+		return nil, func() {}, fmt.Errorf("failed to write secrets file: %w", err)
+	}
+	return []string{"--workspace-env-file", file.Name()}, cleanup, nil
+}
+
+// secretFileCommand writes the base64-encoded secret value it reads on stdin
+// to a path inside a workspace, readable only by the workspace user. Paths
+// starting with ~/ are relative to the user's home directory. The value is
+// never part of the command, which shows up in process lists.
+func secretFileCommand(path string) string {
+	target := shellQuote(path)
+	if strings.HasPrefix(path, "~/") {
+		target = `"$HOME"/` + shellQuote(strings.TrimPrefix(path, "~/"))
+	}
+	return fmt.Sprintf(`umask 077 && mkdir -p "$(dirname %s)" && base64 -d > %s`, target, target)
+}
+
+// injectSecretFiles writes the file secrets of a running workspace. Failures
+// are recorded in the timeline and returned as warnings naming the secret,
+// never its value.
+func (s *Server) injectSecretFiles(ctx context.Context, workspace string) []string {
+	secrets, err := s.workspaceSecrets(ctx, workspace)
+	if err != nil {
+		return []string{fmt.Sprintf("secrets not injected: %v", err)}
+	}
+
+	var warnings []string
+	for _, secret := range secrets {
+		if secret.File == "" {
+			continue
+		}
+		encoded := base64.StdEncoding.EncodeToString([]byte(secret.Value))
+		s.redactor.add(encoded)
+		if _, _, err := s.run(WithCommandInput(ctx, []byte(encoded)), []string{"ssh", workspace, "--command", secretFileCommand(secret.File)}); err != nil {
+			warning := fmt.Sprintf("secret %s not written to %s", secret.Name, secret.File)
+			s.state(ctx).RecordEvent(workspace, "error", warning)
+			warnings = append(warnings, warning)
+		}
+	}
+	return warnings
+}
+
+// validSecretFile checks a secret file path: absolute or relative to the
+// home directory
+func validSecretFile(path string) error {
+	if !strings.HasPrefix(path, "/") && !strings.HasPrefix(path, "~/") {
+		return fmt.Errorf("file must be an absolute path or start with ~/, got %q", path)
+	}
+	if strings.HasSuffix(path, "/") || strings.Contains(path, "\n") {
+		return fmt.Errorf("invalid secret file path %q", path)
+	}
+	return nil
+}
+
+// registerSecretTools registers the tools that store and delete secrets
+func (s *Server) registerSecretTools() {
+	// Store a secret for injection into workspaces
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_setSecret",
+		Description: "Store a secret on the server, encrypted at rest, and inject it into workspaces as an environment variable or file whenever they are created or started. Secret values are never returned.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "The name of the secret, e.g. OPENAI_API_KEY",
+				},
+				"value": map[string]interface{}{
+					"type":        "string",
+					"description": "The secret value (optional if fromEnv is set)",
+				},
+				"fromEnv": map[string]interface{}{
+					"type":        "string",
+					"description": "Read the value from this environment variable of the server, so it never passes through the conversation",
+				},
+				"env": map[string]interface{}{
+					"type":        "string",
+					"description": "Environment variable to inject the secret as (default: the secret name, unless file is set)",
+				},
+				"file": map[string]interface{}{
+					"type":        "string",
+					"description": "Path inside the workspace to write the secret to, absolute or starting with ~/",
+				},
+				"workspaces": map[string]interface{}{
+					"type":        "array",
+					"items":       map[string]interface{}{"type": "string"},
+					"description": "Only inject into these workspaces (default: all workspaces)",
+				},
+			},
+			"required": []string{"name"},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var secretParams struct {
+			Name       string   `json:"name"`
+			Value      string   `json:"value"`
+			FromEnv    string   `json:"fromEnv"`
+			Env        string   `json:"env"`
+			File       string   `json:"file"`
+			Workspaces []string `json:"workspaces"`
+		}
+
+		if err := json.Unmarshal(params, &secretParams); err != nil {
+			return nil, mcp.NewInvalidParamsError("Invalid secret parameters")
+		}
+
+		if !secretName.MatchString(secretParams.Name) {
+			return nil, mcp.NewInvalidParamsError("Secret name must consist of letters, digits and underscores and not start with a digit")
+		}
+		value := secretParams.Value
+		if secretParams.FromEnv != "" {
+			if value != "" {
+				return nil, mcp.NewInvalidParamsError("Set either value or fromEnv, not both")
+			}
+			var ok bool
+			if value, ok = os.LookupEnv(secretParams.FromEnv); !ok {
+				return nil, mcp.NewInvalidParamsError(fmt.Sprintf("Environment variable %s is not set on the server", secretParams.FromEnv))
+			}
+		}
+		if value == "" {
+			return nil, mcp.NewInvalidParamsError("value or fromEnv is required")
+		}
+		if secretParams.Env == "" && secretParams.File == "" {
+			secretParams.Env = secretParams.Name
+		}
+		if secretParams.Env != "" {
+			if !secretName.MatchString(secretParams.Env) {
+				return nil, mcp.NewInvalidParamsError(fmt.Sprintf("Invalid environment variable name %q", secretParams.Env))
+			}
+			// The env file devpod reads holds one variable per line
+			if strings.ContainsAny(value, "\r\n") {
+				return nil, mcp.NewInvalidParamsError("Multi-line secrets can only be injected as a file")
+			}
+		}
+		if secretParams.File != "" {
+			if err := validSecretFile(secretParams.File); err != nil {
+				return nil, mcp.NewInvalidParamsError(err.Error())
+			}
+		}
+
+		s.redactor.add(value)
+		key, err := s.secretKey()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load secret key: %w", err)
+		}
+		sealed, err := sealSecret(key, value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt secret: %w", err)
+		}
+		secret := storedSecret{
+			Name:       secretParams.Name,
+			Value:      sealed,
+			Env:        secretParams.Env,
+			File:       secretParams.File,
+			Workspaces: secretParams.Workspaces,
+			Updated:    time.Now().UTC(),
+		}
+		s.state(ctx).SetSecret(secret)
+
+		return map[string]interface{}{
+			"secret":  secret.info(),
+			"message": fmt.Sprintf("Secret %s stored; it is injected when workspaces are created or started", secret.Name),
+		}, nil
+	})
+
+	// List stored secrets
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_listSecrets",
+		Description: "List the secrets stored on the server and where they are injected, without their values",
+		InputSchema: map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		secrets := []secretInfo{}
+		for _, secret := range s.state(ctx).Secrets() {
+			secrets = append(secrets, secret.info())
+		}
+		return map[string]interface{}{
+			"secrets": secrets,
+			"message": fmt.Sprintf("Found %d secret(s)", len(secrets)),
+		}, nil
+	})
+
+	// Delete a stored secret
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_deleteSecret",
+		Description: "Delete a secret stored on the server. Workspaces keep values injected earlier until they are recreated.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "The name of the secret",
+				},
+			},
+			"required": []string{"name"},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var deleteParams struct {
+			Name string `json:"name"`
+		}
+
+		if err := json.Unmarshal(params, &deleteParams); err != nil {
+			return nil, mcp.NewInvalidParamsError("Invalid delete secret parameters")
+		}
+
+		if !s.state(ctx).DeleteSecret(deleteParams.Name) {
+			return nil, mcp.NewInvalidParamsError(fmt.Sprintf("Secret %s not found", deleteParams.Name))
+		}
+		return map[string]interface{}{
+			"name":    deleteParams.Name,
+			"message": fmt.Sprintf("Secret %s deleted", deleteParams.Name),
+		}, nil
+	})
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+// runnerFunc adapts a function to the Runner interface
+type runnerFunc func(ctx context.Context, args []string) ([]byte, []byte, error)
+
+func (f runnerFunc) Run(ctx context.Context, args []string) ([]byte, []byte, error) {
+	return f(ctx, args)
+}
+
+func TestSealSecret(t *testing.T) {
+	key := make([]byte, 32)
+	sealed, err := sealSecret(key, "s3cret")
+	if err != nil {
+		t.Fatalf("sealSecret failed: %v", err)
+	}
+	if strings.Contains(sealed, "s3cret") {
+		t.Fatal("Expected the sealed value not to contain the secret")
+	}
+	if value, err := openSecret(key, sealed); err != nil || value != "s3cret" {
+		t.Errorf("Expected s3cret, got %q, %v", value, err)
+	}
+	other := make([]byte, 32)
+	other[0] = 1
+	if _, err := openSecret(other, sealed); err == nil {
+		t.Error("Expected a different key to fail")
+	}
+}
+
+func TestSecretsAreInjectedOnStart(t *testing.T) {
+	t.Setenv("TEST_API_TOKEN", "tok-123")
+	t.Setenv(secretKeyEnv, "")
+	runner := &fakeRunner{}
+	s := newTestServer(t, runner)
+	set := s.MCP().GetHandler("devpod_setSecret")
+
+	result, err := set(context.Background(), json.RawMessage(`{"name":"API_TOKEN","fromEnv":"TEST_API_TOKEN","workspaces":["api"]}`))
+	if err != nil {
+		t.Fatalf("devpod_setSecret failed: %v", err)
+	}
+	if _, err := set(context.Background(), json.RawMessage(`{"name":"NPMRC","value":"//registry/:_authToken=abc\n","file":"~/.npmrc"}`)); err != nil {
+		t.Fatalf("devpod_setSecret failed: %v", err)
+	}
+	if _, err := set(context.Background(), json.RawMessage(`{"name":"MULTI","value":"a\nb"}`)); err == nil {
+		t.Error("Expected a multi-line env secret to be rejected")
+	}
+	state, _ := os.ReadFile(s.opts.StatePath)
+	for _, text := range []string{mustJSON(t, result), string(state)} {
+		if strings.Contains(text, "tok-123") || strings.Contains(text, "_authToken") {
+			t.Errorf("Expected no secret values, got %s", text)
+		}
+	}
+
+	// Read the env file while devpod up runs; it is removed afterwards
+	var envFile, envContent, fileInput string
+	s.runner = runnerFunc(func(ctx context.Context, args []string) ([]byte, []byte, error) {
+		if args[0] == "ssh" {
+			fileInput = string(CommandInput(ctx))
+		}
+		for i, arg := range args {
+			if arg == "--workspace-env-file" {
+				envFile = args[i+1]
+				content, _ := os.ReadFile(envFile)
+				envContent = string(content)
+			}
+		}
+		return runner.Run(ctx, args)
+	})
+	if _, err := s.MCP().GetHandler("devpod_startWorkspace")(context.Background(), json.RawMessage(`{"name":"api"}`)); err != nil {
+		t.Fatalf("devpod_startWorkspace failed: %v", err)
+	}
+	if envContent != "API_TOKEN=tok-123\n" {
+		t.Errorf("Unexpected env file content %q", envContent)
+	}
+	if _, err := os.Stat(envFile); !os.IsNotExist(err) {
+		t.Errorf("Expected the env file to be removed, got %v", err)
+	}
+	wrote := false
+	encoded := base64.StdEncoding.EncodeToString([]byte("//registry/:_authToken=abc\n"))
+	for _, call := range runner.calls {
+		if call[0] == "ssh" && strings.Contains(call[3], ".npmrc") {
+			wrote = true
+			if strings.Contains(call[3], encoded) || strings.Contains(call[3], "_authToken") {
+				t.Errorf("Expected the secret file value not to be on the command line, got %q", call[3])
+			}
+		}
+	}
+	if !wrote || fileInput != encoded {
+		t.Errorf("Expected the file secret to be written over ssh from stdin, got %q", fileInput)
+	}
+	if redacted := s.redactor.redact("ssh input " + encoded); strings.Contains(redacted, encoded) {
+		t.Errorf("Expected the encoded secret to be redacted, got %q", redacted)
+	}
+
+	// Secrets scoped to other workspaces are not injected
+	envFile = ""
+	if _, err := s.MCP().GetHandler("devpod_startWorkspace")(context.Background(), json.RawMessage(`{"name":"web"}`)); err != nil {
+		t.Fatalf("devpod_startWorkspace failed: %v", err)
+	}
+	if envFile != "" {
+		t.Error("Expected no env file for a workspace outside the secret's scope")
+	}
+}
@@ -0,0 +1,557 @@
+// Package server implements the DevPod MCP server. It registers all DevPod
+// tools on an MCP server bound to a caller-supplied transport, so the server
+// can run standalone or be embedded in a larger Go program.
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+	"github.com/protobomb/mcp-server-framework/pkg/transport"
+
+	"github.com/Protobomb/mcp-server-devpod/pkg/httptransport"
+)
+
+// Options configures a DevPod MCP server
+type Options struct {
+	// Version is reported in the initialize response (default: "dev")
+	Version string
+	// Runner executes devpod commands (default: the devpod binary on PATH)
+	Runner Runner
+	// DockerRunner executes docker commands against the containers of
+	// docker-provider workspaces (default: the docker binary on PATH)
+	DockerRunner Runner
+	// CloudRunners execute the aws, gcloud and az CLIs, by name, when the
+	// configure tools check cloud credentials (default: the binaries on PATH)
+	CloudRunners map[string]Runner
+	// RuntimeRunners execute the docker, podman and nerdctl CLIs, by name,
+	// when container runtimes are detected (default: the binaries on PATH)
+	RuntimeRunners map[string]Runner
+	// ContainerRuntime is the CLI the docker provider runs containers with:
+	// docker, podman, nerdctl, or auto for the first one that works. It is
+	// applied to the provider's options on start; empty leaves them alone.
+	ContainerRuntime string
+	// SSHRunner executes ssh to test the hosts registered for the ssh
+	// provider (default: the ssh binary on PATH)
+	SSHRunner Runner
+	// GitRunner executes git on the host to look up the credential helpers
+	// that scoped git credentials are limited to (default: the git binary
+	// on PATH)
+	GitRunner Runner
+	// DataDir holds the state file, the secret key and the audit log of tool
+	// calls (default: the directory of StatePath)
+	DataDir string
+	// CaptureDir receives a file for every JSON-RPC message received or sent,
+	// with credentials redacted, for debugging client compatibility; empty
+	// disables capturing
+	CaptureDir string
+	// StatePath is the state file location (default: state.json in DataDir,
+	// or under the user's config directory)
+	StatePath string
+	// SSHPool enables reuse of SSH control connections between calls
+	SSHPool bool
+	// SSHIdleTimeout closes pooled SSH connections after this idle period (default: 5m)
+	SSHIdleTimeout time.Duration
+	// SSHConfigPath is the SSH config devpod writes workspace hosts to
+	// (default: ~/.ssh/config)
+	SSHConfigPath string
+	// WatchInterval polls workspace state and notifies clients of changes (0 disables)
+	WatchInterval time.Duration
+	// StrictJSON fails instead of falling back to text parsing when devpod
+	// does not return JSON output
+	StrictJSON bool
+	// BootstrapProvider is added on start when no providers are configured
+	BootstrapProvider string
+	// Limits caps concurrent devpod commands and workspace creations
+	Limits Limits
+	// Quotas cap the workspaces clients can create and their size
+	Quotas Quotas
+	// Names constrains the names of new workspaces
+	Names NamePolicy
+	// ResultFormat is how tool results are rendered when a call does not
+	// pass format: json, markdown or text (default: Go's print format)
+	ResultFormat string
+	// Retry repeats devpod commands that fail with transient errors
+	Retry RetryPolicy
+	// Breaker fails devpod commands fast after consecutive backend failures
+	Breaker BreakerPolicy
+	// AllowedEnv are the variables tools accept in their env argument; a name
+	// ending in * allows a prefix (default: DefaultAllowedEnv, empty: none)
+	AllowedEnv []string
+	// Tracing exports OpenTelemetry spans of tool calls and devpod commands
+	Tracing TracingOptions
+	// LockWait is how long an operation on a workspace waits for another one
+	// to finish before failing with a Conflict error (default: 0, fail at once)
+	LockWait time.Duration
+	// Policy authorizes tool calls by tool, caller and arguments
+	Policy PolicyOptions
+	// Users are the accounts of a shared server, whose devpod commands run
+	// with their own DEVPOD_HOME and context
+	Users map[string]User
+	// UserHomeRoot holds a DEVPOD_HOME directory for every authenticated
+	// user without one configured in Users
+	UserHomeRoot string
+	// GCInterval runs workspace garbage collection in the background at this
+	// interval (0 disables)
+	GCInterval time.Duration
+	// GCMaxIdle is the lastUsed age beyond which workspaces are collected (default: 24h)
+	GCMaxIdle time.Duration
+	// GCPolicy is "stop" (default) or "delete"
+	GCPolicy string
+	// AutoStopAfter stops workspaces created or started through the server
+	// once no tool call has named them for this long, unless the call sets
+	// autoStopAfter (0 disables)
+	AutoStopAfter time.Duration
+	// ReleaseURL is the GitHub releases API used to check for devpod CLI
+	// updates (default: the loft-sh/devpod releases)
+	ReleaseURL string
+	// ProviderIndexURL is the GitHub API base used to look up the latest
+	// releases of community providers (default: https://api.github.com/repos)
+	ProviderIndexURL string
+	// MaxOutputBytes caps the devpod output included in tool results; longer
+	// output keeps its head and tail (default: 16 KiB, negative: unlimited)
+	MaxOutputBytes int
+	// ToolOutputLimits overrides MaxOutputBytes for individual tools
+	ToolOutputLimits map[string]int
+	// WorkspaceRoot confines local workspace sources to this directory and
+	// resolves relative paths against it (default: any path, relative to the
+	// working directory)
+	WorkspaceRoot string
+	// AutoInstall downloads the devpod CLI into InstallDir on start when it
+	// is missing
+	AutoInstall bool
+	// InstallDir is the managed directory devpod is installed into (default:
+	// under the user's cache directory)
+	InstallDir string
+	// Templates are named defaults selectable via the template parameter of
+	// devpod_createWorkspace
+	Templates map[string]Template
+	// OpenBrowser registers devpod_openInBrowser, which opens URLs on the
+	// machine the server runs on. Only useful for desktop setups.
+	OpenBrowser bool
+	// BrowserOpener opens URLs for devpod_openInBrowser (default: the
+	// desktop's default handler)
+	BrowserOpener func(url string) error
+	// IDEProxyURL is where IDEProxyHandler is reached, e.g.
+	// https://mcp.example.com/workspaces. When set, devpod_openIDE returns a
+	// proxyUrl below it for browser IDEs.
+	IDEProxyURL string
+}
+
+// Server is a DevPod MCP server bound to a transport
+type Server struct {
+	mcp       *mcp.Server
+	transport mcp.Transport
+	opts      Options
+	runner    Runner
+	docker    Runner
+	sshCLI    Runner
+	git       Runner
+	// containerRuntime is the runtime ContainerRuntime selected on start
+	containerRuntime string
+	store            *stateStore
+	pool             *sshPool
+	limiter          *limiter
+	reservations     quotaReservations
+	breaker          *circuitBreaker
+	locks            *workspaceLocks
+	events           *eventLog
+	requests         *clientRequests
+	sessions         *sessionStates
+	catalog          *providerCatalog
+	prebuilds        *prebuildJobs
+	ides             *ideTunnels
+	activity         *activityTracker
+	tools            *toolRegistry
+	outputs          *outputStore
+	installMu        sync.Mutex
+	versionMu        sync.Mutex
+	// providerSchemaCache holds provider option schemas for tools/list
+	providerSchemaCache providerSchemaCache
+	// lifecycle tracks the workspace states announced to clients
+	lifecycle lifecycleTracker
+	// redactor masks credentials in tool results and logs
+	redactor *redactor
+	// invocations tracks tool calls for the dashboard
+	invocations *invocationLog
+	// logs keeps the tail of the server log for support bundles
+	logs *logTail
+	// tracer exports spans, nil unless tracing is enabled
+	tracer *tracer
+	// capture writes the protocol messages, nil unless capturing is enabled
+	capture *captureLog
+	// secretKeyBytes is the key secrets are encrypted with, loaded on first use
+	secretMu       sync.Mutex
+	secretKeyBytes []byte
+	// cliVersion is the detected devpod CLI version, empty until detected
+	cliVersion string
+	// availabilityErr is the last failed detection, trusted until
+	// availabilityChecked plus availabilityRecheck
+	availabilityErr     error
+	availabilityChecked time.Time
+	started             atomic.Bool
+	cancel              context.CancelFunc
+}
+
+// New creates a DevPod MCP server on the given transport and registers all
+// DevPod tools. The transport is not started until Start is called.
+func New(t mcp.Transport, opts Options) *Server {
+	if opts.Version == "" {
+		opts.Version = "dev"
+	}
+	if opts.SSHIdleTimeout <= 0 {
+		opts.SSHIdleTimeout = 5 * time.Minute
+	}
+	if opts.StatePath == "" && opts.DataDir != "" {
+		opts.StatePath = filepath.Join(opts.DataDir, stateFile)
+	}
+	if opts.StatePath == "" {
+		opts.StatePath = defaultStatePath()
+	}
+	if opts.DataDir == "" && opts.StatePath != "" {
+		opts.DataDir = filepath.Dir(opts.StatePath)
+	}
+	if opts.GCMaxIdle <= 0 {
+		opts.GCMaxIdle = 24 * time.Hour
+	}
+	if opts.GCPolicy == "" {
+		opts.GCPolicy = "stop"
+	}
+	if opts.ReleaseURL == "" {
+		opts.ReleaseURL = defaultReleaseURL
+	}
+	if opts.ProviderIndexURL == "" {
+		opts.ProviderIndexURL = defaultProviderIndexURL
+	}
+	if opts.MaxOutputBytes == 0 {
+		opts.MaxOutputBytes = defaultMaxOutputBytes
+	}
+	if opts.InstallDir == "" {
+		opts.InstallDir = defaultInstallDir()
+	}
+	if opts.BrowserOpener == nil {
+		opts.BrowserOpener = openURL
+	}
+
+	requests := newClientRequests()
+	client := newClientTransport(t, requests)
+	s := &Server{
+		// Route client responses to server-initiated requests before the
+		// framework dispatches incoming messages
+		mcp:         mcp.NewServer(client),
+		transport:   t,
+		requests:    requests,
+		sessions:    newSessionStates(),
+		catalog:     newProviderCatalog(),
+		prebuilds:   newPrebuildJobs(),
+		ides:        newIDETunnels(),
+		activity:    newActivityTracker(),
+		tools:       newToolRegistry(),
+		outputs:     newOutputStore(),
+		redactor:    newRedactor(),
+		invocations: newInvocationLog(),
+		logs:        &logTail{},
+		opts:        opts,
+		runner:      opts.Runner,
+		docker:      opts.DockerRunner,
+		sshCLI:      opts.SSHRunner,
+		git:         opts.GitRunner,
+		limiter:     newLimiter(opts.Limits),
+		locks:       newWorkspaceLocks(),
+		events:      &eventLog{},
+	}
+	if s.runner == nil {
+		s.runner = &ExecRunner{}
+	}
+	if s.docker == nil {
+		s.docker = &ExecRunner{Path: "docker"}
+	}
+	if opts.ContainerRuntime != "" && opts.ContainerRuntime != "auto" && opts.DockerRunner == nil {
+		s.docker = &ExecRunner{Path: opts.ContainerRuntime}
+	}
+	if s.sshCLI == nil {
+		s.sshCLI = &ExecRunner{Path: "ssh"}
+	}
+	if s.git == nil {
+		s.git = &ExecRunner{Path: "git"}
+	}
+	s.breaker = newCircuitBreaker(opts.Breaker, s.backendStatusChanged)
+	s.tracer = newTracer(opts.Tracing, opts.Version, s.redactor.redact, func(err error) {
+		s.reportEvent("warning", "tracing", err)
+	})
+
+	// Keep SSH connections warm between devpod_ssh calls
+	if opts.SSHPool {
+		pool, err := newSSHPool(opts.SSHIdleTimeout)
+		if err != nil {
+			log.Printf("WARNING: SSH connection pooling disabled: %v", err)
+		} else {
+			s.pool = pool
+		}
+	}
+
+	// Open the state store used for workspace timelines
+	store, err := openStateStore(opts.StatePath)
+	if err != nil {
+		log.Printf("WARNING: state will not be persisted: %v", err)
+	}
+	s.store = store
+
+	// Keep the tool call history across restarts
+	if opts.DataDir != "" {
+		audit, err := openAuditLog(opts.DataDir)
+		if err == nil {
+			err = s.invocations.restore(audit)
+		}
+		if err != nil {
+			log.Printf("WARNING: tool calls will not be audited: %v", err)
+		}
+	}
+
+	// Keep a copy of every protocol message for offline debugging
+	if opts.CaptureDir != "" {
+		capture, err := openCaptureLog(opts.CaptureDir, s.redactor.redact)
+		if err != nil {
+			log.Printf("WARNING: protocol messages will not be captured: %v", err)
+		} else {
+			infof("Capturing protocol messages in %s", opts.CaptureDir)
+			s.capture = capture
+			client.capture = capture
+		}
+	}
+
+	// Register MCP protocol handlers BEFORE starting the server (to prevent override)
+	debugf("Registering MCP protocol handlers")
+	s.registerMCPHandlers()
+
+	// Register DevPod handlers BEFORE starting the server
+	debugf("Registering DevPod handlers")
+	s.registerDevPodHandlers()
+
+	// Set up message handler for HTTP-based transports
+	debugf("Setting up message handler")
+	s.setupMessageHandler()
+
+	return s
+}
+
+// MCP returns the underlying MCP server, e.g. to register additional handlers
+func (s *Server) MCP() *mcp.Server {
+	return s.mcp
+}
+
+// Start bootstraps the configured provider, starts the transport and launches
+// background workers. Workers stop when ctx is cancelled or Stop is called.
+func (s *Server) Start(ctx context.Context) error {
+	ctx, s.cancel = context.WithCancel(ctx)
+
+	// Prefer a previously installed managed CLI
+	if s.opts.AutoInstall && s.opts.InstallDir != "" {
+		if _, err := os.Stat(installPath(s.opts.InstallDir)); err == nil {
+			if err := useInstallDir(s.opts.InstallDir); err != nil {
+				log.Printf("WARNING: failed to add %s to PATH: %v", s.opts.InstallDir, err)
+			}
+		}
+	}
+
+	// Check DevPod availability early to provide clear error message
+	err := s.checkDevPodAvailable(ctx)
+	if err != nil && s.opts.AutoInstall {
+		infof("Installing the DevPod CLI into %s", s.opts.InstallDir)
+		if _, installErr := s.installCLI(ctx, ""); installErr != nil {
+			s.reportEvent("error", "install", fmt.Errorf("devpod installation failed: %w", installErr))
+		} else {
+			err = s.checkDevPodAvailable(ctx)
+		}
+	}
+	if err != nil {
+		s.reportEvent("error", "availability", err)
+		log.Printf("WARNING: DevPod tools will return errors when called")
+	} else if s.opts.BootstrapProvider != "" {
+		// Make fresh deployments usable without a manual devpod_addProvider call
+		if err := s.bootstrapProvider(ctx, s.opts.BootstrapProvider); err != nil {
+			s.reportEvent("warning", "bootstrap", fmt.Errorf("provider bootstrap failed: %w", err))
+		}
+	}
+	if err == nil && s.opts.ContainerRuntime != "" {
+		if err := s.useContainerRuntime(ctx); err != nil {
+			s.reportEvent("warning", "runtime", fmt.Errorf("failed to configure the container runtime: %w", err))
+		}
+	}
+
+	if err := s.mcp.Start(ctx); err != nil {
+		return err
+	}
+	s.started.Store(true)
+
+	if s.pool != nil {
+		go s.pool.cleanupLoop(ctx)
+	}
+	if s.tracer != nil {
+		infof("Exporting traces to %s", s.tracer.url)
+		go s.tracer.run(ctx)
+	}
+
+	// Start the workspace watcher once the transport can deliver notifications
+	if s.opts.WatchInterval > 0 {
+		infof("Watching workspace state every %s", s.opts.WatchInterval)
+		watcher := newWorkspaceWatcher(s, s.opts.WatchInterval)
+		go watcher.Run(ctx)
+	}
+
+	// Run scheduled operations, including ones persisted by earlier runs
+	go s.runScheduler(ctx, schedulerInterval)
+
+	// Reclaim stale workspaces in the background
+	if s.opts.AutoStopAfter > 0 {
+		infof("Stopping workspaces created or started without autoStopAfter after %s without tool activity", s.opts.AutoStopAfter)
+	}
+	if s.opts.GCInterval > 0 {
+		infof("Collecting workspaces idle for %s every %s (policy: %s)", s.opts.GCMaxIdle, s.opts.GCInterval, s.opts.GCPolicy)
+		go s.runGC(ctx, s.opts.GCInterval, s.opts.GCMaxIdle, s.opts.GCPolicy)
+	}
+
+	return nil
+}
+
+// Stop stops background workers, closes pooled connections and stops the transport
+func (s *Server) Stop() error {
+	s.started.Store(false)
+	if s.cancel != nil {
+		s.cancel()
+	}
+	if s.pool != nil {
+		s.pool.CloseAll()
+	}
+	s.prebuilds.cancelAll()
+	s.ides.closeAll()
+	s.invocations.interruptAll()
+	// Export the spans of the calls that finished before the shutdown
+	flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	s.tracer.flush(flushCtx)
+	return s.mcp.Stop()
+}
+
+// running reports whether the transport has been started and can deliver notifications
+func (s *Server) running() bool {
+	return s.started.Load()
+}
+
+// Close closes the underlying transport
+func (s *Server) Close() error {
+	return s.mcp.Close()
+}
+
+// setupMessageHandler sets up the message handler for HTTP-based transports
+func (s *Server) setupMessageHandler() {
+	// Create a message handler function that processes JSON-RPC messages
+	handle := func(ctx context.Context, message []byte) ([]byte, error) {
+		// Responses to server-initiated requests go to their waiting callers
+		if s.requests.deliver(SessionID(ctx), message) {
+			return nil, nil
+		}
+
+		var request mcp.JSONRPCRequest
+		if err := json.Unmarshal(message, &request); err != nil {
+			return nil, fmt.Errorf("invalid JSON-RPC message: %w", err)
+		}
+
+		// Check if this is a notification (no ID field)
+		if request.ID == nil {
+			// This is a notification - handle it and don't send a response
+			if handler := s.mcp.GetNotificationHandler(request.Method); handler != nil {
+				if err := handler(ctx, request.Params); err != nil {
+					log.Printf("Error handling notification %s: %v", request.Method, err)
+				}
+			} else {
+				debugf("No handler for notification: %s", request.Method)
+			}
+			// Return nil for notifications (no response expected)
+			return nil, nil
+		}
+
+		// This is a request - handle it and send a response
+		ctx = withRequestID(ctx, request.ID)
+		response := mcp.JSONRPCResponse{
+			JSONRPC: mcp.JSONRPCVersion,
+			ID:      request.ID,
+		}
+
+		// Get the handler for this method
+		if handler := s.mcp.GetHandler(request.Method); handler != nil {
+			result, err := handler(ctx, request.Params)
+			if err != nil {
+				if rpcErr, ok := err.(*mcp.RPCError); ok {
+					response.Error = rpcErr
+				} else {
+					response.Error = &mcp.RPCError{
+						Code:    mcp.InternalError,
+						Message: err.Error(),
+					}
+				}
+			} else {
+				response.Result = result
+			}
+		} else {
+			response.Error = &mcp.RPCError{
+				Code:    mcp.MethodNotFound,
+				Message: fmt.Sprintf("Method not found: %s", request.Method),
+			}
+		}
+
+		// Marshal the response
+		return json.Marshal(response)
+	}
+
+	handleMessage := func(ctx context.Context, message []byte) ([]byte, error) {
+		s.capture.record(ctx, captureIn, message)
+		response, err := handle(ctx, message)
+		if len(response) > 0 {
+			s.capture.record(ctx, captureOut, response)
+		}
+		return response, err
+	}
+
+	messageHandler := func(message []byte) ([]byte, error) {
+		return handleMessage(context.Background(), message)
+	}
+
+	// Set up message handler for SSE transport
+	if sseTransport, ok := s.transport.(*transport.SSETransport); ok {
+		sseTransport.SetMessageHandler(messageHandler)
+	}
+
+	// Our SSE transport tells each message's client session as well
+	if sseTransport, ok := s.transport.(*httptransport.SSE); ok {
+		sseTransport.SetSessionMessageHandler(func(sessionID string, message []byte) ([]byte, error) {
+			return handleMessage(WithSessionID(context.Background(), sessionID), message)
+		})
+		sseTransport.SetSessionClosedHandler(s.endSession)
+	}
+
+	// Set up message handler for HTTP Streams transport
+	if httpStreamsTransport, ok := s.transport.(*transport.HTTPStreamsTransport); ok {
+		httpStreamsTransport.SetMessageHandler(messageHandler)
+	}
+
+	// Session-aware HTTP Streams transport attributes each call to its session
+	if streams, ok := s.transport.(*httptransport.Streams); ok {
+		streams.SetSessionMessageHandler(func(sessionID string, message []byte) ([]byte, error) {
+			ctx := WithSessionID(context.Background(), sessionID)
+			if user := streams.SessionUser(sessionID); user != "" {
+				ctx = WithUser(ctx, user)
+			}
+			return handleMessage(ctx, message)
+		})
+		streams.SetSessionClosedHandler(s.endSession)
+	}
+}
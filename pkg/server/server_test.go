@@ -0,0 +1,120 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/protobomb/mcp-server-framework/pkg/transport"
+)
+
+// fakeRunner returns canned output keyed by the joined devpod arguments.
+// Commands listed in failures exit with an error and the given stderr.
+type fakeRunner struct {
+	mu       sync.Mutex
+	outputs  map[string]string
+	failures map[string]string
+	calls    [][]string
+}
+
+func (r *fakeRunner) Run(ctx context.Context, args []string) ([]byte, []byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, args)
+	key := strings.Join(args, " ")
+	if stderr, ok := r.failures[key]; ok {
+		return nil, []byte(stderr), errors.New("exit status 1")
+	}
+	// Output is produced at once, like a command that prints and exits
+	if w := OutputWriter(ctx); w != nil {
+		w.Write([]byte(r.outputs[key]))
+	}
+	return []byte(r.outputs[key]), nil, nil
+}
+
+func newTestServer(t *testing.T, runner Runner) *Server {
+	t.Helper()
+	return New(transport.NewSTDIOTransport(), Options{
+		Runner:    runner,
+		StatePath: filepath.Join(t.TempDir(), "state.json"),
+	})
+}
+
+func TestExecRunnerReportsMissingBinary(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	_, _, err := (&ExecRunner{}).Run(context.Background(), []string{"version"})
+	if err == nil || !strings.Contains(err.Error(), "not found on PATH") {
+		t.Fatalf("Expected a missing binary error, got %v", err)
+	}
+
+	err = binaryNotFound("windows", "devpod", errors.New("executable file not found in %PATH%"))
+	if !strings.Contains(err.Error(), "devpod.exe not found on PATH") {
+		t.Errorf("Expected the Windows error to name devpod.exe, got %v", err)
+	}
+}
+
+func TestNewRegistersDevPodTools(t *testing.T) {
+	runner := &fakeRunner{outputs: map[string]string{
+		"list --output json": `[{"id":"test1","provider":{"name":"docker"}}]`,
+	}}
+	s := newTestServer(t, runner)
+
+	handler := s.MCP().GetHandler("devpod_listWorkspaces")
+	if handler == nil {
+		t.Fatal("Expected devpod_listWorkspaces handler to be registered")
+	}
+
+	result, err := handler(context.Background(), json.RawMessage(`{}`))
+	if err != nil {
+		t.Fatalf("devpod_listWorkspaces failed: %v", err)
+	}
+
+	workspaces, ok := result.(map[string]interface{})["workspaces"].([]DevPodWorkspace)
+	if !ok || len(workspaces) != 1 || workspaces[0].ID != "test1" {
+		t.Errorf("Unexpected result: %v", result)
+	}
+}
+
+func TestStartWorkspaceReturnsMetadata(t *testing.T) {
+	runner := &fakeRunner{outputs: map[string]string{
+		"version":                    "v0.5.0",
+		"list --output json":         `[{"id":"test1","provider":{"name":"docker"},"ide":{"name":"vscode"}}]`,
+		"status test1 --output json": `{"id":"test1","state":"Running"}`,
+		"up test1":                   "done",
+	}}
+	s := newTestServer(t, runner)
+
+	result, err := s.MCP().GetHandler("devpod_startWorkspace")(context.Background(), json.RawMessage(`{"name":"test1"}`))
+	if err != nil {
+		t.Fatalf("devpod_startWorkspace failed: %v", err)
+	}
+
+	details, ok := result.(map[string]interface{})["workspace"].(*WorkspaceDetails)
+	if !ok {
+		t.Fatalf("Expected workspace details in result: %v", result)
+	}
+	if details.Provider.Name != "docker" || details.IDE.Name != "vscode" || details.State != "Running" {
+		t.Errorf("Unexpected workspace details: %+v", details)
+	}
+}
+
+func TestStrictJSONRejectsTextOutput(t *testing.T) {
+	runner := &fakeRunner{outputs: map[string]string{
+		"version":            "v0.5.0",
+		"list --output json": "NAME    STATUS    PROVIDER\ntest1   Running   docker",
+	}}
+	s := New(transport.NewSTDIOTransport(), Options{
+		Runner:     runner,
+		StatePath:  filepath.Join(t.TempDir(), "state.json"),
+		StrictJSON: true,
+	})
+
+	_, err := s.MCP().GetHandler("devpod_listWorkspaces")(context.Background(), json.RawMessage(`{}`))
+	if err == nil || !strings.Contains(err.Error(), "unsupported devpod version") {
+		t.Errorf("Expected unsupported devpod version error, got %v", err)
+	}
+}
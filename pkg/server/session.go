@@ -0,0 +1,185 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+// sessionIDKey is the context key for the client session a call belongs to
+type sessionIDKey struct{}
+
+// WithSessionID returns a context attributing devpod calls to a client
+// session, so per-session limits and defaults apply. Transports that track
+// sessions should set it on every request.
+func WithSessionID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, sessionIDKey{}, id)
+}
+
+// SessionID returns the client session attached to ctx, or "" if none
+func SessionID(ctx context.Context) string {
+	id, _ := ctx.Value(sessionIDKey{}).(string)
+	return id
+}
+
+// maxRecentWorkspaces caps the recent workspaces remembered per session
+const maxRecentWorkspaces = 10
+
+// sessionState holds the defaults and history of one client session. Calls
+// without a session, e.g. over STDIO, share the state of the empty session.
+type sessionState struct {
+	// Context is the devpod context all commands of the session run in
+	Context string `json:"context,omitempty"`
+	// Provider and IDE are used when a call does not name one
+	Provider string `json:"provider,omitempty"`
+	IDE      string `json:"ide,omitempty"`
+	// RecentWorkspaces lists the workspaces the session used, most recent first
+	RecentWorkspaces []string `json:"recentWorkspaces"`
+	// Client is the clientInfo name the session announced in initialize
+	Client string `json:"client,omitempty"`
+	// capabilities are the optional features the session's client announced
+	capabilities clientCapabilities
+	// roots caches the directories the session's client shares
+	roots rootsCache
+}
+
+// sessionStates tracks the state of every client session
+type sessionStates struct {
+	mu     sync.Mutex
+	states map[string]*sessionState
+}
+
+func newSessionStates() *sessionStates {
+	return &sessionStates{states: make(map[string]*sessionState)}
+}
+
+// get returns a copy of the state of a session
+func (s *sessionStates) get(id string) sessionState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.states[id]
+	if !ok {
+		return sessionState{RecentWorkspaces: []string{}}
+	}
+	copied := *state
+	copied.RecentWorkspaces = append([]string{}, state.RecentWorkspaces...)
+	return copied
+}
+
+// update changes the state of a session and returns a copy of the result
+func (s *sessionStates) update(id string, change func(*sessionState)) sessionState {
+	s.mu.Lock()
+	state, ok := s.states[id]
+	if !ok {
+		state = &sessionState{RecentWorkspaces: []string{}}
+		s.states[id] = state
+	}
+	change(state)
+	s.mu.Unlock()
+	return s.get(id)
+}
+
+// remove forgets a session
+func (s *sessionStates) remove(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.states, id)
+}
+
+// session returns the state of the session a call belongs to
+func (s *Server) session(ctx context.Context) sessionState {
+	return s.sessions.get(SessionID(ctx))
+}
+
+// touchWorkspace moves a workspace to the front of the session's recent list
+func (s *Server) touchWorkspace(ctx context.Context, name string) {
+	s.sessions.update(SessionID(ctx), func(state *sessionState) {
+		recent := []string{name}
+		for _, workspace := range state.RecentWorkspaces {
+			if workspace != name && len(recent) < maxRecentWorkspaces {
+				recent = append(recent, workspace)
+			}
+		}
+		state.RecentWorkspaces = recent
+	})
+}
+
+// forgetWorkspace removes a deleted workspace from the session's recent list
+func (s *Server) forgetWorkspace(ctx context.Context, name string) {
+	s.sessions.update(SessionID(ctx), func(state *sessionState) {
+		recent := state.RecentWorkspaces[:0]
+		for _, workspace := range state.RecentWorkspaces {
+			if workspace != name {
+				recent = append(recent, workspace)
+			}
+		}
+		state.RecentWorkspaces = recent
+	})
+}
+
+// endSession drops everything kept for a session once the client ends it
+func (s *Server) endSession(id string) {
+	s.sessions.remove(id)
+	s.limiter.removeSession(id)
+	infof("Session %s ended", id)
+}
+
+// registerSessionTools registers the tool that sets session defaults
+func (s *Server) registerSessionTools() {
+	// Set session defaults
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_setDefaults",
+		Description: "Set the devpod context, provider and IDE used by later calls of this session when they omit them; call without arguments to read the current defaults",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"context": map[string]interface{}{
+					"type":        "string",
+					"description": "The devpod context to run all commands in (empty string clears it)",
+				},
+				"provider": map[string]interface{}{
+					"type":        "string",
+					"description": "The default provider for new workspaces (empty string clears it)",
+				},
+				"ide": map[string]interface{}{
+					"type":        "string",
+					"description": "The default IDE for new workspaces (empty string clears it)",
+				},
+			},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		// Pointers tell omitted fields, which are kept, from empty ones, which clear
+		var defaultsParams struct {
+			Context  *string `json:"context,omitempty"`
+			Provider *string `json:"provider,omitempty"`
+			IDE      *string `json:"ide,omitempty"`
+		}
+
+		if len(params) > 0 {
+			if err := json.Unmarshal(params, &defaultsParams); err != nil {
+				return nil, mcp.NewInvalidParamsError("Invalid set defaults parameters")
+			}
+		}
+
+		state := s.sessions.update(SessionID(ctx), func(state *sessionState) {
+			if defaultsParams.Context != nil {
+				state.Context = strings.TrimSpace(*defaultsParams.Context)
+			}
+			if defaultsParams.Provider != nil {
+				state.Provider = strings.TrimSpace(*defaultsParams.Provider)
+			}
+			if defaultsParams.IDE != nil {
+				state.IDE = strings.TrimSpace(*defaultsParams.IDE)
+			}
+		})
+
+		return map[string]interface{}{
+			"defaults": state,
+			"message":  "Session defaults updated",
+		}, nil
+	})
+}
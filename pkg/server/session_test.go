@@ -0,0 +1,133 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSessionDefaults(t *testing.T) {
+	runner := &fakeRunner{outputs: map[string]string{"list --output json --context staging": "[]"}}
+	s := newTestServer(t, runner)
+	ctx := WithSessionID(context.Background(), "a")
+
+	if _, err := s.MCP().GetHandler("devpod_setDefaults")(ctx, json.RawMessage(`{"context":"staging","provider":"aws","ide":"vscode"}`)); err != nil {
+		t.Fatalf("devpod_setDefaults failed: %v", err)
+	}
+	if _, err := s.MCP().GetHandler("devpod_createWorkspace")(ctx, json.RawMessage(`{"name":"api","source":"github.com/acme/api"}`)); err != nil {
+		t.Fatalf("devpod_createWorkspace failed: %v", err)
+	}
+
+	var up string
+	for _, call := range runner.calls {
+		if call[0] == "up" {
+			up = strings.Join(call, " ")
+		}
+	}
+	for _, want := range []string{"--provider aws", "--ide vscode", "--context staging"} {
+		if !strings.Contains(up, want) {
+			t.Errorf("Expected %q in up command %q", want, up)
+		}
+	}
+	if recent := s.session(ctx).RecentWorkspaces; len(recent) != 1 || recent[0] != "api" {
+		t.Errorf("Expected api as recent workspace, got %v", recent)
+	}
+
+	// Other sessions keep their own defaults
+	if other := s.session(WithSessionID(context.Background(), "b")); other.Provider != "" || other.Context != "" {
+		t.Errorf("Expected session b to have no defaults, got %+v", other)
+	}
+
+	// Omitted fields are kept and empty ones clear
+	result, err := s.MCP().GetHandler("devpod_setDefaults")(ctx, json.RawMessage(`{"context":""}`))
+	if err != nil {
+		t.Fatalf("devpod_setDefaults failed: %v", err)
+	}
+	state := result.(map[string]interface{})["defaults"].(sessionState)
+	if state.Context != "" || state.Provider != "aws" {
+		t.Errorf("Unexpected defaults after clearing context: %+v", state)
+	}
+
+	s.endSession("a")
+	if state := s.session(ctx); state.Provider != "" || len(state.RecentWorkspaces) != 0 {
+		t.Errorf("Expected ended session to be forgotten, got %+v", state)
+	}
+}
+
+// queueTransport hands the server's messages to a test, with the session
+// each was sent to, or "" for a broadcast
+type queueTransport struct {
+	recordingTransport
+	messages chan sessionMessage
+}
+
+type sessionMessage struct {
+	session string
+	message []byte
+}
+
+func (t *queueTransport) Send(message []byte) error {
+	t.messages <- sessionMessage{message: message}
+	return nil
+}
+
+func (t *queueTransport) SendTo(session string, message []byte) error {
+	t.messages <- sessionMessage{session: session, message: message}
+	return nil
+}
+
+func TestClientRequestsStayInTheirSession(t *testing.T) {
+	transport := &queueTransport{messages: make(chan sessionMessage, 10)}
+	s := New(transport, Options{Runner: &fakeRunner{}, StatePath: filepath.Join(t.TempDir(), "state.json")})
+	a := WithSessionID(context.Background(), "a")
+	b := WithSessionID(context.Background(), "b")
+
+	s.setClientCapabilities(a, json.RawMessage(`{"capabilities":{"elicitation":{}}}`))
+	if s.capabilities(a).Elicitation == nil || s.capabilities(b).Elicitation != nil {
+		t.Errorf("Expected only session a to support elicitation, got %+v and %+v", s.capabilities(a), s.capabilities(b))
+	}
+
+	type result struct {
+		raw json.RawMessage
+		err error
+	}
+	results := make(chan result, 1)
+	go func() {
+		raw, err := s.requestClient(a, "elicitation/create", map[string]interface{}{})
+		results <- result{raw, err}
+	}()
+	sent := <-transport.messages
+	if sent.session != "a" {
+		t.Fatalf("Expected the request to go to session a only, got %q", sent.session)
+	}
+	var request struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(sent.message, &request); err != nil {
+		t.Fatalf("Failed to parse request: %v", err)
+	}
+	response := []byte(`{"jsonrpc":"2.0","id":"` + request.ID + `","result":{"action":"accept"}}`)
+
+	// Session b cannot answer session a's request
+	if !s.requests.deliver("b", response) {
+		t.Error("Expected the response from session b to be consumed")
+	}
+	select {
+	case got := <-results:
+		t.Fatalf("Expected the request to keep waiting for session a, got %s, %v", got.raw, got.err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	s.requests.deliver("a", response)
+	if got := <-results; got.err != nil || string(got.raw) != `{"action":"accept"}` {
+		t.Errorf("Expected session a's answer, got %s, %v", got.raw, got.err)
+	}
+
+	// Calls without a session have no client to ask
+	if _, err := s.requestClient(context.Background(), "roots/list", map[string]interface{}{}); err == nil {
+		t.Error("Expected a request without a session to fail")
+	}
+}
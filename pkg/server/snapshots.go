@@ -0,0 +1,221 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+// devcontainerIDLabel is the label devpod's docker driver puts on the
+// container of a workspace, set to the workspace ID
+const devcontainerIDLabel = "dev.containers.id"
+
+// workspaceSnapshot is an image committed from a workspace container
+type workspaceSnapshot struct {
+	ID        string `json:"id"`
+	Workspace string `json:"workspace"`
+	// Image is the reference the container was committed to
+	Image   string `json:"image"`
+	ImageID string `json:"imageId,omitempty"`
+	Pushed  bool   `json:"pushed"`
+	// Provider and IDE are those of the workspace, for recreating it alike
+	Provider string                `json:"provider"`
+	IDE      string                `json:"ide,omitempty"`
+	Source   DevPodWorkspaceSource `json:"source"`
+	Created  time.Time             `json:"created"`
+}
+
+// newSnapshotID returns a random snapshot ID
+func newSnapshotID() string {
+	bytes := make([]byte, 6)
+	if _, err := rand.Read(bytes); err != nil {
+		return fmt.Sprintf("snap-%d", time.Now().UnixNano())
+	}
+	return "snap-" + hex.EncodeToString(bytes)
+}
+
+// defaultSnapshotImage returns the local image a workspace is committed to
+// when no image is given
+func defaultSnapshotImage(name string, now time.Time) string {
+	return fmt.Sprintf("devpod-snapshot/%s:%s", strings.ToLower(name), now.UTC().Format("20060102-150405"))
+}
+
+// providerOption returns the value of a workspace provider option, which
+// devpod lists either as a plain value or as an object with a value
+func providerOption(workspace *DevPodWorkspace, name string) string {
+	switch value := workspace.Provider.Options[name].(type) {
+	case string:
+		return value
+	case map[string]interface{}:
+		if s, ok := value["value"].(string); ok {
+			return s
+		}
+	}
+	return ""
+}
+
+// runDocker runs a docker command against the daemon of a docker-provider
+// workspace
+func (s *Server) runDocker(ctx context.Context, workspace *DevPodWorkspace, args []string) ([]byte, []byte, error) {
+	if host := providerOption(workspace, "DOCKER_HOST"); host != "" {
+		ctx = WithCommandEnv(ctx, []string{"DOCKER_HOST=" + host})
+	}
+	return s.docker.Run(ctx, args)
+}
+
+// workspaceContainer returns the ID of the running container of a
+// docker-provider workspace
+func (s *Server) workspaceContainer(ctx context.Context, workspace *DevPodWorkspace) (string, error) {
+	output, stderr, err := s.runDocker(ctx, workspace, []string{"ps", "-q", "--filter", "label=" + devcontainerIDLabel + "=" + workspace.ID})
+	if err != nil {
+		return "", newDevPodError("failed to find the workspace container", err, stderr)
+	}
+	ids := strings.Fields(string(output))
+	if len(ids) == 0 {
+		return "", fmt.Errorf("no running container is labeled %s=%s", devcontainerIDLabel, workspace.ID)
+	}
+	return ids[0], nil
+}
+
+// registerSnapshotTools registers the tools that snapshot workspaces
+func (s *Server) registerSnapshotTools() {
+	// Snapshot workspace
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_snapshotWorkspace",
+		Description: "Commit the running container of a docker-provider workspace to an image, optionally push it, and record it so the environment can be recreated with devpod_createWorkspace",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "The name of the workspace",
+				},
+				"image": map[string]interface{}{
+					"type":        "string",
+					"description": "Image reference to commit to, e.g. ghcr.io/org/env:v1 (default: devpod-snapshot/<name>:<timestamp>)",
+				},
+				"push": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Push the image to its registry after committing (default: false)",
+				},
+			},
+			"required": []string{"name"},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var snapshotParams struct {
+			Name  string `json:"name"`
+			Image string `json:"image"`
+			Push  bool   `json:"push"`
+		}
+
+		if err := json.Unmarshal(params, &snapshotParams); err != nil {
+			return nil, mcp.NewInvalidParamsError("Invalid snapshot workspace parameters")
+		}
+
+		if snapshotParams.Name == "" {
+			return nil, mcp.NewInvalidParamsError("Workspace name is required")
+		}
+		now := time.Now()
+		if snapshotParams.Image == "" {
+			snapshotParams.Image = defaultSnapshotImage(snapshotParams.Name, now)
+		}
+		if _, err := parseImageRef(snapshotParams.Image); err != nil {
+			return nil, mcp.NewInvalidParamsError(err.Error())
+		}
+
+		workspace, err := s.findWorkspace(ctx, snapshotParams.Name)
+		if err != nil {
+			return nil, newDevPodError("failed to look up workspace", err, nil)
+		}
+		if workspace == nil {
+			return nil, mcp.NewInvalidParamsError(fmt.Sprintf("Workspace %s not found", snapshotParams.Name))
+		}
+		if workspace.Provider.Name != "docker" {
+			return nil, mcp.NewInvalidParamsError(fmt.Sprintf("Snapshots need the docker provider; workspace %s uses %s", snapshotParams.Name, workspace.Provider.Name))
+		}
+		if err := s.requireRunning(ctx, snapshotParams.Name); err != nil {
+			return nil, err
+		}
+
+		container, err := s.workspaceContainer(ctx, workspace)
+		if err != nil {
+			return nil, err
+		}
+		output, stderr, err := s.runDocker(ctx, workspace, []string{
+			"commit", "--message", "devpod snapshot of " + snapshotParams.Name, container, snapshotParams.Image,
+		})
+		if err != nil {
+			s.state(ctx).RecordEvent(snapshotParams.Name, "error", fmt.Sprintf("snapshot failed: %v", err))
+			return nil, newDevPodError("failed to commit the workspace container", err, stderr)
+		}
+
+		snapshot := workspaceSnapshot{
+			ID:        newSnapshotID(),
+			Workspace: snapshotParams.Name,
+			Image:     snapshotParams.Image,
+			ImageID:   strings.TrimSpace(string(output)),
+			Provider:  workspace.Provider.Name,
+			IDE:       workspace.IDE.Name,
+			Source:    workspace.Source,
+			Created:   now.UTC(),
+		}
+		s.state(ctx).SetSnapshot(snapshot)
+		s.state(ctx).RecordEvent(snapshotParams.Name, "snapshot", fmt.Sprintf("Committed to %s", snapshot.Image))
+
+		if snapshotParams.Push {
+			if _, stderr, err := s.runDocker(ctx, workspace, []string{"push", snapshot.Image}); err != nil {
+				return nil, newDevPodError(fmt.Sprintf("committed %s but failed to push it", snapshot.Image), err, stderr)
+			}
+			snapshot.Pushed = true
+			s.state(ctx).SetSnapshot(snapshot)
+		}
+
+		return map[string]interface{}{
+			"snapshot": snapshot,
+			"source":   "image:" + snapshot.Image,
+			"message":  fmt.Sprintf("Snapshot %s saved as %s; pass source %q to devpod_createWorkspace to recreate the environment", snapshot.ID, snapshot.Image, "image:"+snapshot.Image),
+		}, nil
+	})
+
+	// List snapshots
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_listSnapshots",
+		Description: "List workspace snapshots taken through this server, most recent first",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"workspace": map[string]interface{}{
+					"type":        "string",
+					"description": "Only list snapshots of this workspace (optional)",
+				},
+			},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var listParams struct {
+			Workspace string `json:"workspace,omitempty"`
+		}
+
+		if len(params) > 0 {
+			if err := json.Unmarshal(params, &listParams); err != nil {
+				return nil, mcp.NewInvalidParamsError("Invalid list snapshots parameters")
+			}
+		}
+
+		snapshots := []workspaceSnapshot{}
+		for _, snapshot := range s.state(ctx).Snapshots() {
+			if listParams.Workspace == "" || snapshot.Workspace == listParams.Workspace {
+				snapshots = append(snapshots, snapshot)
+			}
+		}
+		return map[string]interface{}{
+			"snapshots": snapshots,
+			"count":     len(snapshots),
+		}, nil
+	})
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDefaultSnapshotImage(t *testing.T) {
+	image := defaultSnapshotImage("My-App", time.Date(2026, 3, 1, 12, 30, 0, 0, time.UTC))
+	if image != "devpod-snapshot/my-app:20260301-123000" {
+		t.Errorf("Unexpected image %s", image)
+	}
+	if _, err := parseImageRef(image); err != nil {
+		t.Errorf("Expected a valid image reference: %v", err)
+	}
+}
+
+func TestSnapshotWorkspace(t *testing.T) {
+	runner := &fakeRunner{outputs: map[string]string{
+		"list --output json":       `[{"id":"api","provider":{"name":"docker","options":{"DOCKER_HOST":{"value":"tcp://builder:2375"}}},"ide":{"name":"vscode"},"source":{"gitRepository":"https://github.com/example/api"}},{"id":"cloud","provider":{"name":"aws"}}]`,
+		"status api --output json": `{"state":"Running"}`,
+	}}
+	docker := &envRunner{fakeRunner: fakeRunner{outputs: map[string]string{
+		"ps -q --filter label=dev.containers.id=api":                         "c0ffee\n",
+		"commit --message devpod snapshot of api c0ffee ghcr.io/acme/api:v1": "sha256:abc\n",
+	}}}
+	s := newTestServer(t, runner)
+	s.docker = docker
+	snapshot := s.MCP().GetHandler("devpod_snapshotWorkspace")
+
+	result, err := snapshot(context.Background(), json.RawMessage(`{"name":"api","image":"ghcr.io/acme/api:v1","push":true}`))
+	if err != nil {
+		t.Fatalf("devpod_snapshotWorkspace failed: %v", err)
+	}
+	taken := result.(map[string]interface{})["snapshot"].(workspaceSnapshot)
+	if taken.ImageID != "sha256:abc" || !taken.Pushed || taken.IDE != "vscode" || taken.Source.GitRepository == "" {
+		t.Errorf("Unexpected snapshot %+v", taken)
+	}
+	if source := result.(map[string]interface{})["source"]; source != "image:ghcr.io/acme/api:v1" {
+		t.Errorf("Unexpected source %v", source)
+	}
+	if last := strings.Join(docker.calls[len(docker.calls)-1], " "); last != "push ghcr.io/acme/api:v1" {
+		t.Errorf("Expected the image to be pushed, got %s", last)
+	}
+	if docker.env != "DOCKER_HOST=tcp://builder:2375" {
+		t.Errorf("Expected the provider's docker host, got %q", docker.env)
+	}
+
+	listed, err := s.MCP().GetHandler("devpod_listSnapshots")(context.Background(), json.RawMessage(`{"workspace":"api"}`))
+	if err != nil || listed.(map[string]interface{})["count"] != 1 {
+		t.Errorf("Expected the snapshot to be recorded, got %v (%v)", listed, err)
+	}
+
+	if _, err := snapshot(context.Background(), json.RawMessage(`{"name":"cloud"}`)); err == nil {
+		t.Error("Expected workspaces of other providers to be rejected")
+	}
+}
+
+// envRunner is a fakeRunner that also records the extra environment of the
+// last call
+type envRunner struct {
+	fakeRunner
+	env string
+}
+
+func (r *envRunner) Run(ctx context.Context, args []string) ([]byte, []byte, error) {
+	r.env = strings.Join(CommandEnv(ctx), " ")
+	return r.fakeRunner.Run(ctx, args)
+}
@@ -0,0 +1,405 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Workspace source types
+const (
+	sourceGit   = "git"
+	sourceLocal = "local"
+	sourceImage = "image"
+)
+
+// gitHosts are hosts whose paths are always git repositories
+var gitHosts = map[string]bool{
+	"github.com":        true,
+	"gitlab.com":        true,
+	"bitbucket.org":     true,
+	"dev.azure.com":     true,
+	"ssh.dev.azure.com": true,
+	"codeberg.org":      true,
+	"gitea.com":         true,
+}
+
+// registryHosts are well-known container registries. Other hosts followed by
+// a path are taken for self-hosted git servers unless a tag or digest is given.
+var registryHosts = []string{
+	"docker.io", "index.docker.io", "registry-1.docker.io", "ghcr.io", "quay.io", "gcr.io", ".gcr.io", ".pkg.dev",
+	"mcr.microsoft.com", "public.ecr.aws", ".amazonaws.com", ".azurecr.io", "registry.gitlab.com", "localhost",
+}
+
+// imagePath matches the repository path of an image reference, following the
+// distribution reference grammar
+var imagePath = regexp.MustCompile(`^[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*)*$`)
+
+// imageTag matches the tag of an image reference
+var imageTag = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,127}$`)
+
+// imageDigest matches the digest of an image reference
+var imageDigest = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+
+// imageRef is a parsed container image reference
+type imageRef struct {
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// reference returns the tag or digest the manifest is looked up by
+func (r imageRef) reference() string {
+	if r.Digest != "" {
+		return r.Digest
+	}
+	return r.Tag
+}
+
+// splitImageRef splits a reference into its registry, path, tag and digest
+// without validating them. The registry is empty for Docker Hub images.
+func splitImageRef(ref string) imageRef {
+	var parsed imageRef
+	if i := strings.Index(ref, "@"); i >= 0 {
+		ref, parsed.Digest = ref[:i], ref[i+1:]
+	}
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		ref, parsed.Tag = ref[:i], ref[i+1:]
+	}
+	if i := strings.Index(ref, "/"); i >= 0 {
+		if host := ref[:i]; strings.ContainsAny(host, ".:") || host == "localhost" {
+			parsed.Registry, ref = host, ref[i+1:]
+		}
+	}
+	parsed.Repository = ref
+	return parsed
+}
+
+// parseImageRef validates an image reference and fills in the Docker Hub
+// registry, library namespace and latest tag where they are implied
+func parseImageRef(ref string) (imageRef, error) {
+	parsed := splitImageRef(ref)
+	if !imagePath.MatchString(parsed.Repository) {
+		return parsed, fmt.Errorf("invalid image reference %s: the repository must be lowercase letters, digits and separators", ref)
+	}
+	if parsed.Tag != "" && !imageTag.MatchString(parsed.Tag) {
+		return parsed, fmt.Errorf("invalid image reference %s: invalid tag %q", ref, parsed.Tag)
+	}
+	if parsed.Digest != "" && !imageDigest.MatchString(parsed.Digest) {
+		return parsed, fmt.Errorf("invalid image reference %s: invalid digest %q", ref, parsed.Digest)
+	}
+
+	if parsed.Registry == "" || parsed.Registry == "docker.io" || parsed.Registry == "index.docker.io" {
+		parsed.Registry = "registry-1.docker.io"
+		if !strings.Contains(parsed.Repository, "/") {
+			parsed.Repository = "library/" + parsed.Repository
+		}
+	}
+	if parsed.Tag == "" && parsed.Digest == "" {
+		parsed.Tag = "latest"
+	}
+	return parsed, nil
+}
+
+// isRegistryHost reports whether host is a well-known container registry
+func isRegistryHost(host string) bool {
+	host = strings.SplitN(host, ":", 2)[0]
+	for _, registry := range registryHosts {
+		if host == registry || (strings.HasPrefix(registry, ".") && strings.HasSuffix(host, registry)) {
+			return true
+		}
+	}
+	return false
+}
+
+// isLocalPath reports whether source is written as a filesystem path.
+// Windows paths are recognized on any OS, so a client on Windows talking to
+// a server elsewhere gets a clear error rather than a failed git clone.
+func isLocalPath(source string) bool {
+	return strings.HasPrefix(source, "/") || strings.HasPrefix(source, ".") || strings.HasPrefix(source, "~") ||
+		strings.HasPrefix(source, `\`) || filepath.VolumeName(source) != "" || hasDriveLetter(source)
+}
+
+// hasDriveLetter reports whether path starts with a Windows drive such as
+// C:\ or C:/
+func hasDriveLetter(path string) bool {
+	if len(path) < 3 || path[1] != ':' || (path[2] != '\\' && path[2] != '/') {
+		return false
+	}
+	letter := path[0] | 0x20
+	return letter >= 'a' && letter <= 'z'
+}
+
+// classifySource returns the type of a `devpod up` source and the source as
+// devpod expects it. "image:" and "docker://" prefixes mark image sources
+// explicitly. Anything that is neither a path nor recognizably a git
+// repository or image is left to devpod as a git source.
+func classifySource(source string) (string, string) {
+	for _, prefix := range []string{"image:", "docker://"} {
+		if strings.HasPrefix(source, prefix) {
+			return sourceImage, strings.TrimPrefix(source, prefix)
+		}
+	}
+	if isLocalPath(source) {
+		return sourceLocal, source
+	}
+	if strings.Contains(source, "://") || strings.HasPrefix(source, "git@") {
+		return sourceGit, source
+	}
+
+	ref := splitImageRef(source)
+	host := strings.SplitN(source, "/", 2)[0]
+	if gitHosts[host] || strings.HasSuffix(ref.Repository, ".git") {
+		return sourceGit, source
+	}
+	// A self-hosted git server looks like a registry host; only a tag,
+	// digest or well-known registry makes it an image
+	if ref.Registry == "" || isRegistryHost(ref.Registry) || ref.Tag != "" || imageDigest.MatchString(ref.Digest) {
+		return sourceImage, source
+	}
+	return sourceGit, source
+}
+
+// resolveLocalSource returns the absolute path of a local source. Relative
+// paths are resolved against the first of the client's roots they exist in,
+// else the workspace root, or the working directory when no root is
+// configured. With a root, paths that lead outside it, including through
+// symlinks, are rejected.
+func (s *Server) resolveLocalSource(ctx context.Context, source string) (string, error) {
+	path := source
+	if path == "~" || strings.HasPrefix(path, "~/") || strings.HasPrefix(path, `~\`) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("cannot resolve %s: %w", source, err)
+		}
+		path = filepath.Join(home, path[1:])
+	}
+
+	root := s.opts.WorkspaceRoot
+	if !filepath.IsAbs(path) {
+		base, err := s.localBase(ctx, path)
+		if err != nil {
+			return "", fmt.Errorf("cannot resolve %s: %w", source, err)
+		}
+		path = filepath.Join(base, path)
+	}
+
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", fmt.Errorf("local source %s does not exist", source)
+	}
+	if info, err := os.Stat(resolved); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("local source %s is not a directory", source)
+	}
+
+	if root != "" {
+		resolvedRoot, err := filepath.EvalSymlinks(root)
+		if err != nil {
+			return "", fmt.Errorf("workspace root %s is not accessible: %w", root, err)
+		}
+		rel, err := filepath.Rel(resolvedRoot, resolved)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return "", fmt.Errorf("local source %s is outside the workspace root %s", source, root)
+		}
+	}
+	return resolved, nil
+}
+
+// localBase returns the directory a relative local source is resolved
+// against: the first client root it exists in, else the workspace root or
+// the working directory
+func (s *Server) localBase(ctx context.Context, rel string) (string, error) {
+	for _, root := range s.clientRoots(ctx) {
+		if _, err := os.Stat(filepath.Join(root.Path, rel)); err == nil {
+			return root.Path, nil
+		}
+	}
+	if s.opts.WorkspaceRoot != "" {
+		return s.opts.WorkspaceRoot, nil
+	}
+	return os.Getwd()
+}
+
+// localSourceArg checks local sources with resolveLocalSource and returns
+// other sources unchanged. A bare name that is a directory in the workspace
+// root or a client root is taken as a local source.
+func (s *Server) localSourceArg(ctx context.Context, source string) (string, error) {
+	kind, _ := classifySource(source)
+	if kind != sourceLocal && !s.inLocalRoot(ctx, source) {
+		return source, nil
+	}
+	return s.resolveLocalSource(ctx, source)
+}
+
+// inLocalRoot reports whether a bare source name is a directory in the
+// configured workspace root or one of the client's roots
+func (s *Server) inLocalRoot(ctx context.Context, source string) bool {
+	if strings.ContainsAny(source, `/\:@`) {
+		return false
+	}
+	var roots []string
+	for _, root := range s.clientRoots(ctx) {
+		roots = append(roots, root.Path)
+	}
+	if s.opts.WorkspaceRoot != "" {
+		roots = append(roots, s.opts.WorkspaceRoot)
+	}
+	for _, root := range roots {
+		if info, err := os.Stat(filepath.Join(root, source)); err == nil && info.IsDir() {
+			return true
+		}
+	}
+	return false
+}
+
+// errImageUnverified is returned when a registry does not let anonymous
+// clients read a manifest, so devpod's credentials may still work
+var errImageUnverified = errors.New("the registry requires credentials to check the image")
+
+// errImageNotFound is returned when the registry has no such image
+var errImageNotFound = errors.New("image not found")
+
+// manifestMediaTypes are the manifest formats accepted from registries
+var manifestMediaTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// authParam matches the key="value" pairs of a WWW-Authenticate challenge
+var authParam = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// registryToken requests an anonymous pull token for a Bearer challenge
+func registryToken(ctx context.Context, challenge string) (string, error) {
+	params := make(map[string]string)
+	for _, match := range authParam.FindAllStringSubmatch(challenge, -1) {
+		params[match[1]] = match[2]
+	}
+	if params["realm"] == "" {
+		return "", errImageUnverified
+	}
+
+	query := url.Values{}
+	for _, key := range []string{"service", "scope"} {
+		if params[key] != "" {
+			query.Set(key, params[key])
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, params["realm"]+"?"+query.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", errImageUnverified
+	}
+
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("failed to parse registry token: %w", err)
+	}
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+	return token.Token, nil
+}
+
+// manifestIndex is the part of a multi-arch image index listing platforms
+type manifestIndex struct {
+	Manifests []struct {
+		Platform struct {
+			OS           string `json:"os"`
+			Architecture string `json:"architecture"`
+			Variant      string `json:"variant,omitempty"`
+		} `json:"platform"`
+	} `json:"manifests"`
+}
+
+// platforms returns the os/arch[/variant] platforms of an index, leaving out
+// the "unknown" entries of attestation manifests
+func (index manifestIndex) platforms() []string {
+	var platforms []string
+	for _, manifest := range index.Manifests {
+		p := manifest.Platform
+		if p.OS == "" || p.OS == "unknown" || p.Architecture == "unknown" {
+			continue
+		}
+		platform := p.OS + "/" + p.Architecture
+		if p.Variant != "" {
+			platform += "/" + p.Variant
+		}
+		platforms = append(platforms, platform)
+	}
+	return platforms
+}
+
+// checkImage looks up the manifest of an image in its registry, requesting an
+// anonymous token when the registry asks for one, and returns the platforms
+// a multi-arch image is published for. Single-platform images return none.
+// It returns errImageUnverified when the image may exist but is not public.
+func checkImage(ctx context.Context, ref imageRef) ([]string, error) {
+	scheme := "https"
+	if host := strings.SplitN(ref.Registry, ":", 2)[0]; host == "localhost" || host == "127.0.0.1" {
+		scheme = "http"
+	}
+	manifestURL := fmt.Sprintf("%s://%s/v2/%s/manifests/%s", scheme, ref.Registry, ref.Repository, ref.reference())
+
+	token := ""
+	for attempt := 0; attempt < 2; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, manifestURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", strings.Join(manifestMediaTypes, ", "))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to reach registry %s: %w", ref.Registry, err)
+		}
+		var index manifestIndex
+		if resp.StatusCode == http.StatusOK {
+			// Image manifests have no manifests list and decode to no platforms
+			_ = json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&index)
+		}
+		resp.Body.Close()
+
+		switch resp.StatusCode {
+		case http.StatusOK:
+			return index.platforms(), nil
+		case http.StatusNotFound:
+			return nil, fmt.Errorf("%w: %s/%s:%s", errImageNotFound, ref.Registry, ref.Repository, ref.reference())
+		case http.StatusUnauthorized:
+			challenge := resp.Header.Get("WWW-Authenticate")
+			if token != "" || !strings.HasPrefix(strings.ToLower(challenge), "bearer ") {
+				return nil, errImageUnverified
+			}
+			if token, err = registryToken(ctx, challenge); err != nil {
+				return nil, err
+			}
+		case http.StatusForbidden:
+			return nil, errImageUnverified
+		default:
+			return nil, fmt.Errorf("registry %s returned %s", ref.Registry, resp.Status)
+		}
+	}
+	return nil, errImageUnverified
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestClassifySource(t *testing.T) {
+	tests := []struct {
+		source, kind, arg string
+	}{
+		{"github.com/org/repo", sourceGit, "github.com/org/repo"},
+		{"github.com/org/repo@main", sourceGit, "github.com/org/repo@main"},
+		{"https://git.example.com/org/repo", sourceGit, "https://git.example.com/org/repo"},
+		{"git@github.com:org/repo.git", sourceGit, "git@github.com:org/repo.git"},
+		{"git.example.com/org/repo", sourceGit, "git.example.com/org/repo"},
+		{"./myproject", sourceLocal, "./myproject"},
+		{"/home/me/project", sourceLocal, "/home/me/project"},
+		{`C:\Users\me\project`, sourceLocal, `C:\Users\me\project`},
+		{"c:/src/app", sourceLocal, "c:/src/app"},
+		{`~\project`, sourceLocal, `~\project`},
+		{"ubuntu", sourceImage, "ubuntu"},
+		{"mcr.microsoft.com/devcontainers/go:1.22", sourceImage, "mcr.microsoft.com/devcontainers/go:1.22"},
+		{"registry.example.com/team/dev:2024", sourceImage, "registry.example.com/team/dev:2024"},
+		{"image:git.example.com/team/dev", sourceImage, "git.example.com/team/dev"},
+	}
+	for _, tt := range tests {
+		if kind, arg := classifySource(tt.source); kind != tt.kind || arg != tt.arg {
+			t.Errorf("classifySource(%q) = %s, %s; want %s, %s", tt.source, kind, arg, tt.kind, tt.arg)
+		}
+	}
+}
+
+func TestParseImageRef(t *testing.T) {
+	ref, err := parseImageRef("ubuntu")
+	if err != nil || ref.Registry != "registry-1.docker.io" || ref.Repository != "library/ubuntu" || ref.Tag != "latest" {
+		t.Errorf("Unexpected Docker Hub reference %+v (%v)", ref, err)
+	}
+	ref, err = parseImageRef("localhost:5000/team/dev@sha256:" + strings.Repeat("a", 64))
+	if err != nil || ref.Registry != "localhost:5000" || ref.Repository != "team/dev" || ref.reference() != "sha256:"+strings.Repeat("a", 64) {
+		t.Errorf("Unexpected digest reference %+v (%v)", ref, err)
+	}
+	for _, invalid := range []string{"Ubuntu:22.04", "ubuntu:-bad", "ubuntu@sha256:short"} {
+		if _, err := parseImageRef(invalid); err == nil {
+			t.Errorf("Expected %s to be rejected", invalid)
+		}
+	}
+}
+
+func TestCheckImage(t *testing.T) {
+	var registry *httptest.Server
+	registry = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/token":
+			if r.URL.Query().Get("scope") != "repository:team/dev:pull" {
+				http.Error(w, "bad scope", http.StatusBadRequest)
+				return
+			}
+			fmt.Fprint(w, `{"token":"anonymous"}`)
+		case r.Header.Get("Authorization") != "Bearer anonymous":
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test",scope="repository:team/dev:pull"`, registry.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+		case r.URL.Path == "/v2/team/dev/manifests/1.0":
+			w.Header().Set("Content-Type", "application/vnd.oci.image.index.v1+json")
+			fmt.Fprint(w, `{"manifests":[{"platform":{"os":"linux","architecture":"amd64"}},{"platform":{"os":"linux","architecture":"arm64","variant":"v8"}},{"platform":{"os":"unknown","architecture":"unknown"}}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer registry.Close()
+	host := strings.TrimPrefix(registry.URL, "http://")
+
+	platforms, err := checkImage(context.Background(), imageRef{Registry: host, Repository: "team/dev", Tag: "1.0"})
+	if err != nil {
+		t.Errorf("Expected the image to be found, got %v", err)
+	}
+	if strings.Join(platforms, ",") != "linux/amd64,linux/arm64/v8" {
+		t.Errorf("Expected the published platforms, got %v", platforms)
+	}
+	if _, err := checkImage(context.Background(), imageRef{Registry: host, Repository: "team/dev", Tag: "2.0"}); !errors.Is(err, errImageNotFound) {
+		t.Errorf("Expected image not found, got %v", err)
+	}
+}
+
+func TestResolveLocalSource(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	for _, dir := range []string{"myproject", "nested/app"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Fatal(err)
+	}
+
+	s := newTestServer(t, &fakeRunner{})
+	s.opts.WorkspaceRoot = root
+	resolvedRoot, _ := filepath.EvalSymlinks(root)
+
+	if path, err := s.resolveLocalSource(context.Background(), "./nested/app"); err != nil || path != filepath.Join(resolvedRoot, "nested", "app") {
+		t.Errorf("Expected ./nested/app inside the root, got %s (%v)", path, err)
+	}
+	if path, err := s.localSourceArg(context.Background(), "myproject"); err != nil || path != filepath.Join(resolvedRoot, "myproject") {
+		t.Errorf("Expected the bare name to resolve in the root, got %s (%v)", path, err)
+	}
+	for _, source := range []string{"../", outside, "./escape", "./missing"} {
+		if _, err := s.resolveLocalSource(context.Background(), source); err == nil {
+			t.Errorf("Expected %s to be rejected", source)
+		}
+	}
+	if source, err := s.localSourceArg(context.Background(), "github.com/org/repo"); err != nil || source != "github.com/org/repo" {
+		t.Errorf("Expected git sources to pass through, got %s (%v)", source, err)
+	}
+}
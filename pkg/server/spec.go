@@ -0,0 +1,257 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+// workspaceSpecVersion is the current version of the workspace spec format
+const workspaceSpecVersion = 1
+
+// workspaceSpec is a portable description of a workspace that can recreate
+// it on another machine
+type workspaceSpec struct {
+	Version             int                   `json:"version"`
+	Name                string                `json:"name"`
+	Source              DevPodWorkspaceSource `json:"source"`
+	Provider            *specComponent        `json:"provider,omitempty"`
+	IDE                 *specComponent        `json:"ide,omitempty"`
+	Env                 map[string]string     `json:"env,omitempty"`
+	GitCredentialScopes []string              `json:"gitCredentialScopes,omitempty"`
+}
+
+// specComponent is a provider or IDE with its options
+type specComponent struct {
+	Name    string            `json:"name"`
+	Options map[string]string `json:"options,omitempty"`
+}
+
+// specFromWorkspace describes an existing workspace. Provider options that
+// the provider marks as passwords are left out and their names returned.
+func (s *Server) specFromWorkspace(ctx context.Context, workspace DevPodWorkspace) (*workspaceSpec, []string) {
+	spec := &workspaceSpec{
+		Version:             workspaceSpecVersion,
+		Name:                workspace.ID,
+		Source:              workspace.Source,
+		GitCredentialScopes: s.state(ctx).CredentialScopes(workspace.ID),
+	}
+
+	var omitted []string
+	if workspace.Provider.Name != "" {
+		options := flattenOptions(workspace.Provider.Options)
+		if schema, err := s.providerOptions(ctx, workspace.Provider.Name); err == nil {
+			for name := range options {
+				if schema[name].Password {
+					delete(options, name)
+					omitted = append(omitted, name)
+				}
+			}
+		} else {
+			log.Printf("WARNING: failed to read %s provider options, secrets cannot be filtered: %v", workspace.Provider.Name, err)
+		}
+		spec.Provider = &specComponent{Name: workspace.Provider.Name, Options: options}
+	}
+	if workspace.IDE.Name != "" {
+		spec.IDE = &specComponent{Name: workspace.IDE.Name, Options: flattenOptions(workspace.IDE.Options)}
+	}
+
+	sort.Strings(omitted)
+	return spec, omitted
+}
+
+// upArgs returns the `devpod up` arguments that create the workspace in spec
+func (spec *workspaceSpec) upArgs(source string) []string {
+	args := []string{"up", source, "--id", spec.Name}
+	if spec.Provider != nil && spec.Provider.Name != "" {
+		args = append(args, optionArgs("--provider-option", spec.Provider.Options)...)
+		args = append(args, "--provider", spec.Provider.Name)
+	}
+	if spec.IDE != nil && spec.IDE.Name != "" {
+		args = append(args, optionArgs("--ide-option", spec.IDE.Options)...)
+		args = append(args, "--ide", spec.IDE.Name)
+	}
+	return append(args, optionArgs("--workspace-env", spec.Env)...)
+}
+
+// createFromSpec creates the workspace described by spec from the given
+// source argument and returns the devpod output. event describes the creation
+// in the workspace timeline. The spec's credential scopes must be normalized.
+func (s *Server) createFromSpec(ctx context.Context, spec *workspaceSpec, source, event string) ([]byte, error) {
+	req := quotaRequest{Name: spec.Name}
+	if spec.Provider != nil {
+		req.Provider, req.Options = spec.Provider.Name, spec.Provider.Options
+	}
+	release, err := s.reserveQuota(ctx, req)
+	if err != nil {
+		s.state(ctx).RecordEvent(spec.Name, "error", fmt.Sprintf("create rejected: %v", err))
+		return nil, err
+	}
+	defer release()
+	if err := s.limiter.allowCreate(time.Now()); err != nil {
+		return nil, err
+	}
+
+	s.state(ctx).SetCredentialScopes(spec.Name, spec.GitCredentialScopes)
+	ctx = s.withCredentialScopes(ctx, spec.GitCredentialScopes)
+
+	secretArgs, cleanup, err := s.secretUpArgs(ctx, spec.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare secrets: %w", err)
+	}
+	defer cleanup()
+
+	output, err := s.combinedOutput(ctx, append(spec.upArgs(source), secretArgs...))
+	if err != nil {
+		s.state(ctx).RecordEvent(spec.Name, "error", fmt.Sprintf("create failed: %v", err))
+		return output, err
+	}
+	s.recordLifecycle(ctx, spec.Name, "created", event)
+	s.touchWorkspace(ctx, spec.Name)
+	// Failures are recorded in the timeline
+	s.injectSecretFiles(ctx, spec.Name)
+	return output, nil
+}
+
+// registerSpecTools registers the tools that export and import workspace specs
+func (s *Server) registerSpecTools() {
+	// Export workspace spec
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_exportWorkspace",
+		Description: "Export a portable JSON spec (source, provider, options, IDE, env) of a workspace",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "The name of the workspace",
+				},
+			},
+			"required": []string{"name"},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var exportParams struct {
+			Name string `json:"name"`
+		}
+
+		if err := json.Unmarshal(params, &exportParams); err != nil {
+			return nil, mcp.NewInvalidParamsError("Invalid export workspace parameters")
+		}
+
+		if exportParams.Name == "" {
+			return nil, mcp.NewInvalidParamsError("Workspace name is required")
+		}
+
+		workspace, err := s.findWorkspace(ctx, exportParams.Name)
+		if err != nil {
+			return nil, newDevPodError("failed to look up workspace", err, nil)
+		}
+		if workspace == nil {
+			return nil, mcp.NewInvalidParamsError(fmt.Sprintf("Workspace %s not found", exportParams.Name))
+		}
+
+		spec, omitted := s.specFromWorkspace(ctx, *workspace)
+		specJSON, err := json.MarshalIndent(spec, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode workspace spec: %w", err)
+		}
+
+		result := map[string]interface{}{
+			"name":    exportParams.Name,
+			"spec":    spec,
+			"json":    string(specJSON),
+			"message": "Workspace exported successfully",
+		}
+		if len(omitted) > 0 {
+			result["omittedOptions"] = omitted
+			result["message"] = fmt.Sprintf("Workspace exported without secret provider options: %s", strings.Join(omitted, ", "))
+		}
+		return result, nil
+	})
+
+	// Import workspace spec
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_importWorkspace",
+		Description: "Create a workspace from a spec produced by devpod_exportWorkspace",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"type":        "object",
+					"description": "The workspace spec",
+				},
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "Create the workspace under this name instead of the spec's (optional)",
+				},
+			},
+			"required": []string{"spec"},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var importParams struct {
+			Spec *workspaceSpec `json:"spec"`
+			Name string         `json:"name,omitempty"`
+		}
+
+		if err := json.Unmarshal(params, &importParams); err != nil {
+			return nil, mcp.NewInvalidParamsError("Invalid import workspace parameters")
+		}
+
+		spec := importParams.Spec
+		if spec == nil {
+			return nil, mcp.NewInvalidParamsError("spec is required")
+		}
+		if spec.Version > workspaceSpecVersion {
+			return nil, mcp.NewInvalidParamsError(fmt.Sprintf("Unsupported spec version %d (this server supports up to %d)", spec.Version, workspaceSpecVersion))
+		}
+		if importParams.Name != "" {
+			spec.Name = importParams.Name
+		}
+		if spec.Name == "" {
+			return nil, mcp.NewInvalidParamsError("Workspace name is required in the spec or as name")
+		}
+
+		source, err := sourceArg(spec.Source, "", "")
+		if err != nil {
+			return nil, mcp.NewInvalidParamsError(fmt.Sprintf("Invalid spec: %v", err))
+		}
+		if spec.GitCredentialScopes, err = normalizeCredentialScopes(spec.GitCredentialScopes); err != nil {
+			return nil, mcp.NewInvalidParamsError(err.Error())
+		}
+
+		if exists, err := s.workspaceExists(ctx, spec.Name); err != nil {
+			return nil, newDevPodError("failed to check for existing workspace", err, nil)
+		} else if exists {
+			return nil, mcp.NewInvalidParamsError(fmt.Sprintf("Workspace %s already exists", spec.Name))
+		}
+		if err := s.opts.Names.check(spec.Name); err != nil {
+			return nil, mcp.NewInvalidParamsError(err.Error())
+		}
+
+		start := time.Now()
+		output, err := s.createFromSpec(ctx, spec, source, "Workspace imported from spec")
+		if err != nil {
+			return nil, withPhases(newDevPodError("failed to import workspace", err, output), output)
+		}
+
+		result := map[string]interface{}{
+			"name":       spec.Name,
+			"message":    "Workspace imported successfully",
+			"output":     string(output),
+			"phases":     parsePhases(string(output), false),
+			"durationMs": durationMs(start),
+		}
+		if details, err := s.describeWorkspace(ctx, spec.Name); err == nil {
+			result["workspace"] = details
+		} else {
+			log.Printf("WARNING: failed to describe workspace %s: %v", spec.Name, err)
+		}
+		return result, nil
+	})
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestExportImportWorkspace(t *testing.T) {
+	runner := &fakeRunner{outputs: map[string]string{
+		"list --output json": `[{"id":"api","source":{"gitRepository":"github.com/acme/api","gitBranch":"main"},` +
+			`"provider":{"name":"aws","options":{"AWS_REGION":{"value":"eu-west-1"},"AWS_SECRET_KEY":{"value":"hunter2"}}},"ide":{"name":"vscode"}}]`,
+		"provider options aws --output json": `{"AWS_REGION":{},"AWS_SECRET_KEY":{"password":true}}`,
+	}}
+	s := newTestServer(t, runner)
+
+	result, err := s.MCP().GetHandler("devpod_exportWorkspace")(context.Background(), json.RawMessage(`{"name":"api"}`))
+	if err != nil {
+		t.Fatalf("devpod_exportWorkspace failed: %v", err)
+	}
+	exported := result.(map[string]interface{})
+	specJSON := exported["json"].(string)
+	if strings.Contains(specJSON, "hunter2") {
+		t.Errorf("Expected secret options to be left out of the export: %s", specJSON)
+	}
+
+	params, _ := json.Marshal(map[string]interface{}{"spec": json.RawMessage(specJSON), "name": "api-copy"})
+	if _, err := s.MCP().GetHandler("devpod_importWorkspace")(context.Background(), params); err != nil {
+		t.Fatalf("devpod_importWorkspace failed: %v", err)
+	}
+
+	want := "up github.com/acme/api@main --id api-copy --provider-option AWS_REGION=eu-west-1 --provider aws --ide vscode"
+	var up []string
+	for _, call := range runner.calls {
+		if call[0] == "up" {
+			up = call
+		}
+	}
+	if got := strings.Join(up, " "); got != want {
+		t.Errorf("Unexpected up command:\n got %s\nwant %s", got, want)
+	}
+}
@@ -0,0 +1,247 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+// sshHostSuffix is appended to workspace names in the host aliases devpod
+// writes to the SSH config
+const sshHostSuffix = ".devpod"
+
+// devpodSSHOptions are the settings devpod writes into every workspace's
+// SSH config entry besides the user and proxy command
+var devpodSSHOptions = [][2]string{
+	{"ForwardAgent", "yes"},
+	{"LogLevel", "error"},
+	{"StrictHostKeyChecking", "no"},
+	{"UserKnownHostsFile", "/dev/null"},
+	{"HostKeyAlgorithms", "rsa-sha2-256,rsa-sha2-512,ssh-rsa"},
+}
+
+// sshConfigEntry is the Host entry of a workspace in an SSH config
+type sshConfigEntry struct {
+	Host         string `json:"host"`
+	User         string `json:"user,omitempty"`
+	ProxyCommand string `json:"proxyCommand"`
+	// Options are the other settings of the entry by keyword
+	Options map[string]string `json:"options,omitempty"`
+	// Stanza is the entry as it appears in the SSH config
+	Stanza string `json:"stanza"`
+}
+
+// sshConfigPath returns the SSH config devpod writes workspace hosts to
+func (s *Server) sshConfigPath() (string, error) {
+	if s.opts.SSHConfigPath != "" {
+		return s.opts.SSHConfigPath, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot locate the SSH config: %w", err)
+	}
+	return filepath.Join(home, ".ssh", "config"), nil
+}
+
+// findSSHConfigEntry returns the entry of host in an SSH config. The block
+// between devpod's "# DevPod Start" and "# DevPod End" markers is preferred;
+// otherwise the Host block naming host is used. It returns nil when the
+// config has no entry for host.
+func findSSHConfigEntry(config, host string) *sshConfigEntry {
+	var block []string
+	inMarkers, inHost := false, false
+	scanner := bufio.NewScanner(strings.NewReader(config))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "# DevPod Start "+host:
+			block, inMarkers, inHost = nil, true, false
+			continue
+		case trimmed == "# DevPod End "+host && inMarkers:
+			return parseSSHConfigEntry(host, block)
+		}
+		if inMarkers {
+			block = append(block, line)
+			continue
+		}
+
+		keyword, value := splitSSHConfigLine(trimmed)
+		if strings.HasPrefix(trimmed, "# DevPod Start ") {
+			// another workspace's entry ends a Host block
+			keyword, value = "Match", ""
+		}
+		if strings.EqualFold(keyword, "Host") || strings.EqualFold(keyword, "Match") {
+			if inHost {
+				return parseSSHConfigEntry(host, block)
+			}
+			inHost = strings.EqualFold(keyword, "Host") && containsField(value, host)
+			if inHost {
+				block = []string{line}
+			}
+			continue
+		}
+		if inHost {
+			block = append(block, line)
+		}
+	}
+	if inHost {
+		return parseSSHConfigEntry(host, block)
+	}
+	return nil
+}
+
+// containsField reports whether a whitespace-separated list contains field
+func containsField(list, field string) bool {
+	for _, f := range strings.Fields(list) {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// splitSSHConfigLine splits an SSH config line into its keyword and value,
+// which are separated by whitespace or "="
+func splitSSHConfigLine(line string) (string, string) {
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", ""
+	}
+	i := strings.IndexAny(line, " \t=")
+	if i < 0 {
+		return line, ""
+	}
+	value := strings.TrimSpace(line[i+1:])
+	if line[i] != '=' {
+		value = strings.TrimSpace(strings.TrimPrefix(value, "="))
+	}
+	return line[:i], value
+}
+
+// parseSSHConfigEntry collects the settings of an entry's lines
+func parseSSHConfigEntry(host string, lines []string) *sshConfigEntry {
+	entry := &sshConfigEntry{Host: host, Options: make(map[string]string)}
+	var stanza []string
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		stanza = append(stanza, line)
+		keyword, value := splitSSHConfigLine(strings.TrimSpace(line))
+		switch strings.ToLower(keyword) {
+		case "", "host":
+		case "user":
+			entry.User = value
+		case "proxycommand":
+			entry.ProxyCommand = value
+		default:
+			entry.Options[keyword] = value
+		}
+	}
+	entry.Stanza = strings.Join(stanza, "\n") + "\n"
+	return entry
+}
+
+// generatedSSHConfigEntry returns the entry devpod would write for a
+// workspace in a devpod context. The user is left to `devpod ssh`, which
+// picks the workspace's remote user.
+func generatedSSHConfigEntry(name, devpodContext string) *sshConfigEntry {
+	if devpodContext == "" {
+		devpodContext = "default"
+	}
+	entry := &sshConfigEntry{
+		Host:         name + sshHostSuffix,
+		ProxyCommand: fmt.Sprintf("devpod ssh --stdio --context %s %s", devpodContext, name),
+		Options:      make(map[string]string),
+	}
+	lines := []string{"Host " + entry.Host}
+	for _, option := range devpodSSHOptions {
+		entry.Options[option[0]] = option[1]
+		lines = append(lines, fmt.Sprintf("  %s %s", option[0], option[1]))
+	}
+	lines = append(lines, "  ProxyCommand "+entry.ProxyCommand)
+	entry.Stanza = strings.Join(lines, "\n") + "\n"
+	return entry
+}
+
+// registerSSHConfigTools registers the tool that returns the SSH config of a
+// workspace
+func (s *Server) registerSSHConfigTools() {
+	// SSH config of a workspace
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_getSSHConfig",
+		Description: "Get the SSH config entry (host alias, proxy command, user) devpod writes for a workspace, for connecting with ssh, scp or rsync or configuring remote interpreters in IDEs",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "The name of the workspace",
+				},
+			},
+			"required": []string{"name"},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var configParams struct {
+			Name string `json:"name"`
+		}
+
+		if err := json.Unmarshal(params, &configParams); err != nil {
+			return nil, mcp.NewInvalidParamsError("Invalid get SSH config parameters")
+		}
+
+		if configParams.Name == "" {
+			return nil, mcp.NewInvalidParamsError("Workspace name is required")
+		}
+
+		workspace, err := s.findWorkspace(ctx, configParams.Name)
+		if err != nil {
+			return nil, newDevPodError("failed to look up workspace", err, nil)
+		}
+		if workspace == nil {
+			return nil, mcp.NewInvalidParamsError(fmt.Sprintf("Workspace %s not found", configParams.Name))
+		}
+
+		path, err := s.sshConfigPath()
+		if err != nil {
+			return nil, err
+		}
+		host := configParams.Name + sshHostSuffix
+		source := "sshConfig"
+		message := fmt.Sprintf("Connect with ssh %s", host)
+		var entry *sshConfigEntry
+		if config, err := os.ReadFile(path); err == nil {
+			entry = findSSHConfigEntry(string(config), host)
+		} else if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		if entry == nil {
+			entry = generatedSSHConfigEntry(configParams.Name, workspace.Context)
+			source = "generated"
+			message = fmt.Sprintf("%s has no entry for %s yet; devpod writes it when the workspace starts, or add this stanza yourself", path, host)
+		}
+
+		return map[string]interface{}{
+			"name":         configParams.Name,
+			"host":         entry.Host,
+			"user":         entry.User,
+			"proxyCommand": entry.ProxyCommand,
+			"options":      entry.Options,
+			"stanza":       entry.Stanza,
+			"source":       source,
+			"configPath":   path,
+			"examples": map[string]string{
+				"ssh":   "ssh " + host,
+				"scp":   fmt.Sprintf("scp ./file %s:~/", host),
+				"rsync": fmt.Sprintf("rsync -av ./ %s:~/%s/", host, configParams.Name),
+			},
+			"message": message,
+		}, nil
+	})
+}
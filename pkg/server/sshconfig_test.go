@@ -0,0 +1,86 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const sampleSSHConfig = `Host *
+  ServerAliveInterval 60
+
+Host web.devpod
+  User alice
+  ProxyCommand "/usr/local/bin/devpod" ssh --stdio web
+
+# DevPod Start api.devpod
+Host api.devpod
+  ForwardAgent yes
+  LogLevel error
+  ProxyCommand "/usr/local/bin/devpod" ssh --stdio --context default --user vscode api
+  User vscode
+# DevPod End api.devpod
+
+Host bastion
+  HostName 10.0.0.1
+`
+
+func TestFindSSHConfigEntry(t *testing.T) {
+	api := findSSHConfigEntry(sampleSSHConfig, "api.devpod")
+	if api == nil || api.User != "vscode" || !strings.Contains(api.ProxyCommand, "--user vscode api") || api.Options["ForwardAgent"] != "yes" {
+		t.Fatalf("Unexpected api entry %+v", api)
+	}
+	if !strings.HasPrefix(api.Stanza, "Host api.devpod\n") || strings.Contains(api.Stanza, "DevPod") {
+		t.Errorf("Expected the stanza between the markers, got %q", api.Stanza)
+	}
+
+	web := findSSHConfigEntry(sampleSSHConfig, "web.devpod")
+	if web == nil || web.User != "alice" || strings.Contains(web.Stanza, "api") {
+		t.Errorf("Expected the web Host block to end at the next entry, got %+v", web)
+	}
+
+	if entry := findSSHConfigEntry(sampleSSHConfig, "missing.devpod"); entry != nil {
+		t.Errorf("Expected no entry for a missing host, got %+v", entry)
+	}
+}
+
+func TestGetSSHConfig(t *testing.T) {
+	runner := &fakeRunner{outputs: map[string]string{
+		"list --output json": `[{"id":"api","context":"work"},{"id":"web"}]`,
+	}}
+	s := newTestServer(t, runner)
+	s.opts.SSHConfigPath = filepath.Join(t.TempDir(), "config")
+	getSSHConfig := s.MCP().GetHandler("devpod_getSSHConfig")
+
+	// Without a config file the entry devpod would write is returned
+	result, err := getSSHConfig(context.Background(), json.RawMessage(`{"name":"api"}`))
+	if err != nil {
+		t.Fatalf("devpod_getSSHConfig failed: %v", err)
+	}
+	config := result.(map[string]interface{})
+	if config["source"] != "generated" || config["proxyCommand"] != "devpod ssh --stdio --context work api" {
+		t.Errorf("Unexpected generated entry %v", config)
+	}
+
+	if err := os.WriteFile(s.opts.SSHConfigPath, []byte(sampleSSHConfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	result, err = getSSHConfig(context.Background(), json.RawMessage(`{"name":"web"}`))
+	if err != nil {
+		t.Fatalf("devpod_getSSHConfig failed: %v", err)
+	}
+	config = result.(map[string]interface{})
+	if config["source"] != "sshConfig" || config["host"] != "web.devpod" || config["user"] != "alice" {
+		t.Errorf("Unexpected entry %v", config)
+	}
+	if examples := config["examples"].(map[string]string); examples["ssh"] != "ssh web.devpod" {
+		t.Errorf("Unexpected examples %v", examples)
+	}
+
+	if _, err := getSSHConfig(context.Background(), json.RawMessage(`{"name":"missing"}`)); err == nil {
+		t.Error("Expected an unknown workspace to be rejected")
+	}
+}
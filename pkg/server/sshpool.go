@@ -0,0 +1,453 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+// sshPool keeps ControlMaster connections to workspaces open between calls so
+// repeated commands skip the SSH handshake. Workspaces are reached through the
+// "<name>.devpod" host alias that devpod writes to the user's SSH config.
+type sshPool struct {
+	mu          sync.Mutex
+	dir         string
+	idleTimeout time.Duration
+	conns       map[string]*sshConn
+}
+
+// sshConn is a single pooled control connection. Until ready is closed the
+// master is still being started by the call that created the entry, and
+// other calls for the workspace wait for it instead of starting their own.
+type sshConn struct {
+	host        string
+	controlPath string
+	master      *exec.Cmd
+	lastUsed    time.Time
+	// cancel aborts starting the master when the connection is closed early
+	cancel context.CancelFunc
+	ready  chan struct{}
+	// err is why the master could not be started, set before ready is closed
+	err error
+}
+
+// poolableName matches the workspace IDs devpod writes host aliases for.
+// Other names are not passed to ssh, where they could be read as options.
+var poolableName = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+func newSSHPool(idleTimeout time.Duration) (*sshPool, error) {
+	// Windows OpenSSH has no ControlMaster support
+	if runtime.GOOS == "windows" {
+		return nil, errors.New("ssh connection sharing is not supported on Windows")
+	}
+	if _, err := exec.LookPath("ssh"); err != nil {
+		return nil, fmt.Errorf("ssh binary not found: %w", err)
+	}
+
+	dir, err := os.MkdirTemp("", "devpod-mcp-ssh")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create control socket directory: %w", err)
+	}
+
+	return &sshPool{
+		dir:         dir,
+		idleTimeout: idleTimeout,
+		conns:       make(map[string]*sshConn),
+	}, nil
+}
+
+// errNoConnection is returned by sshPool.Run when no control connection could
+// be established, in which case callers should fall back to `devpod ssh`
+var errNoConnection = errors.New("no pooled SSH connection available")
+
+// Run implements Runner for `ssh <name> --command <command>`, executing the
+// command in the workspace over a pooled connection. Other devpod commands,
+// including ones in a non-default context, fail with errNoConnection.
+func (p *sshPool) Run(ctx context.Context, args []string) ([]byte, []byte, error) {
+	if len(args) != 4 || args[0] != "ssh" || args[2] != "--command" {
+		return nil, nil, fmt.Errorf("%w for devpod %s", errNoConnection, strings.Join(args, " "))
+	}
+	name, command := args[1], args[3]
+	conn, err := p.acquire(ctx, name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", errNoConnection, err)
+	}
+
+	cmd := exec.CommandContext(ctx, "ssh", "-o", "ControlMaster=no", "-o", "ControlPath="+conn.controlPath, conn.host, command)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err = cmd.Run()
+
+	// Exit code 255 is also what ssh reports when the connection failed, in
+	// which case the master no longer answers and is dropped
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 255 && !conn.alive() {
+		p.drop(name, conn)
+	}
+
+	return stdout.Bytes(), stderr.Bytes(), err
+}
+
+// acquire returns a live control connection for the workspace, starting one
+// if needed. The master is started without holding the pool lock, so a slow
+// workspace only holds up calls to that workspace, and each of them can give
+// up when its context ends.
+func (p *sshPool) acquire(ctx context.Context, name string) (*sshConn, error) {
+	if !poolableName.MatchString(name) {
+		return nil, fmt.Errorf("workspace name %q has no SSH host alias", name)
+	}
+
+	p.mu.Lock()
+	conn, ok := p.conns[name]
+	if !ok {
+		dialCtx, cancel := context.WithCancel(ctx)
+		conn = &sshConn{
+			host:        name + ".devpod",
+			controlPath: p.controlPath(name),
+			cancel:      cancel,
+			ready:       make(chan struct{}),
+		}
+		p.conns[name] = conn
+		p.mu.Unlock()
+
+		conn.err = conn.dial(dialCtx)
+		cancel()
+		close(conn.ready)
+		if conn.err != nil {
+			p.drop(name, conn)
+			return nil, conn.err
+		}
+		p.mu.Lock()
+		closed := p.conns[name] != conn
+		conn.lastUsed = time.Now()
+		p.mu.Unlock()
+		// Closed while the master was starting
+		if closed {
+			conn.close()
+			return nil, fmt.Errorf("SSH connection to %s was closed", conn.host)
+		}
+		debugf("Opened pooled SSH connection to %s", conn.host)
+		return conn, nil
+	}
+	p.mu.Unlock()
+
+	select {
+	case <-conn.ready:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if conn.err != nil {
+		return nil, conn.err
+	}
+	if !conn.alive() {
+		p.drop(name, conn)
+		return p.acquire(ctx, name)
+	}
+	p.touch(conn)
+	return conn, nil
+}
+
+// controlPath returns the control socket of a workspace. The name is hashed
+// so the path stays inside the pool directory and under the socket path limit.
+func (p *sshPool) controlPath(name string) string {
+	sum := sha256.Sum256([]byte(name))
+	return filepath.Join(p.dir, hex.EncodeToString(sum[:8])+".sock")
+}
+
+// dial starts the control master and waits until it accepts connections
+func (c *sshConn) dial(ctx context.Context) error {
+	c.master = exec.Command("ssh", "-N", "-o", "ControlMaster=yes", "-o", "ControlPath="+c.controlPath,
+		"-o", "BatchMode=yes", "-o", "ConnectTimeout=30", c.host)
+	// The devpod ssh proxy behind the host alias forwards git credentials,
+	// so it runs with the workspace's credential scoping
+	c.master.Env = append(os.Environ(), CommandEnv(ctx)...)
+	if err := c.master.Start(); err != nil {
+		return fmt.Errorf("failed to start SSH master: %w", err)
+	}
+
+	// Reap the master when it exits so alive() sees the real state
+	exited := make(chan struct{})
+	go func() {
+		_ = c.master.Wait()
+		close(exited)
+	}()
+
+	deadline := time.After(30 * time.Second)
+	for !c.alive() {
+		select {
+		case <-exited:
+			return fmt.Errorf("SSH master for %s exited before becoming ready", c.host)
+		case <-ctx.Done():
+			c.close()
+			return ctx.Err()
+		case <-deadline:
+			c.close()
+			return fmt.Errorf("timed out waiting for SSH master for %s", c.host)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	return nil
+}
+
+// touch marks a connection as used now
+func (p *sshPool) touch(conn *sshConn) {
+	p.mu.Lock()
+	conn.lastUsed = time.Now()
+	p.mu.Unlock()
+}
+
+// drop closes a connection that failed and removes it from the pool, unless
+// another call already replaced it
+func (p *sshPool) drop(name string, conn *sshConn) {
+	p.mu.Lock()
+	if p.conns[name] == conn {
+		delete(p.conns, name)
+	}
+	p.mu.Unlock()
+	conn.close()
+}
+
+// Close closes the pooled connection for a workspace, reporting whether one existed
+func (p *sshPool) Close(name string) bool {
+	p.mu.Lock()
+	conn, ok := p.conns[name]
+	delete(p.conns, name)
+	p.mu.Unlock()
+
+	if !ok {
+		return false
+	}
+	conn.shutdown()
+	return true
+}
+
+// CloseAll closes every pooled connection and returns the affected workspace names
+func (p *sshPool) CloseAll() []string {
+	p.mu.Lock()
+	conns := p.conns
+	p.conns = make(map[string]*sshConn)
+	p.mu.Unlock()
+
+	closed := make([]string, 0, len(conns))
+	for name, conn := range conns {
+		conn.shutdown()
+		closed = append(closed, name)
+	}
+	return closed
+}
+
+// cleanupLoop closes connections that have been idle longer than the idle timeout
+func (p *sshPool) cleanupLoop(ctx context.Context) {
+	interval := p.idleTimeout / 2
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			var idle []*sshConn
+			p.mu.Lock()
+			for name, conn := range p.conns {
+				if conn.started() && time.Since(conn.lastUsed) > p.idleTimeout {
+					idle = append(idle, conn)
+					delete(p.conns, name)
+				}
+			}
+			p.mu.Unlock()
+			for _, conn := range idle {
+				debugf("Closing idle SSH connection to %s", conn.host)
+				conn.close()
+			}
+		}
+	}
+}
+
+// started reports whether the call that created the connection is done
+// starting its master
+func (c *sshConn) started() bool {
+	select {
+	case <-c.ready:
+		return true
+	default:
+		return false
+	}
+}
+
+// shutdown closes a connection removed from the pool. Starting one is
+// cancelled instead, and the call starting it cleans up.
+func (c *sshConn) shutdown() {
+	if c.started() {
+		c.close()
+		return
+	}
+	c.cancel()
+}
+
+// alive checks whether the control master is accepting connections
+func (c *sshConn) alive() bool {
+	return exec.Command("ssh", "-O", "check", "-o", "ControlPath="+c.controlPath, c.host).Run() == nil
+}
+
+// close asks the control master to exit and cleans up its socket
+func (c *sshConn) close() {
+	_ = exec.Command("ssh", "-O", "exit", "-o", "ControlPath="+c.controlPath, c.host).Run()
+	if c.master != nil && c.master.Process != nil {
+		_ = c.master.Process.Kill()
+	}
+	_ = os.Remove(c.controlPath)
+}
+
+// registerSSHTools registers the tools that run commands over SSH and close
+// pooled connections
+func (s *Server) registerSSHTools() {
+	// SSH into workspace
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_ssh",
+		Description: "SSH into a DevPod workspace",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "The name of the workspace",
+				},
+				"command": map[string]interface{}{
+					"type":        "string",
+					"description": "Command to execute (optional)",
+				},
+			},
+			"required": []string{"name"},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var sshParams struct {
+			Name    string `json:"name"`
+			Command string `json:"command,omitempty"`
+		}
+
+		if err := json.Unmarshal(params, &sshParams); err != nil {
+			return nil, mcp.NewInvalidParamsError("Invalid SSH parameters")
+		}
+
+		if sshParams.Name == "" {
+			return nil, mcp.NewInvalidParamsError("Workspace name is required")
+		}
+
+		args := []string{"ssh", sshParams.Name}
+		if sshParams.Command != "" {
+			args = append(args, "--command", sshParams.Command)
+		}
+
+		var output []byte
+		var err error
+		pooled := false
+		start := time.Now()
+		// Pooled connections use the SSH config devpod writes for the default
+		// context, not another context or the user's DEVPOD_HOME. They go
+		// through the same breaker, limits and tracing as devpod commands.
+		if s.pool != nil && sshParams.Command != "" && UserName(ctx) == "" && s.devpodContext(ctx) == "" {
+			stdout, stderr, runErr := s.runWith(ctx, s.pool, args)
+			if errors.Is(runErr, errNoConnection) {
+				debugf("SSH pool unavailable for %s, falling back to devpod ssh: %v", sshParams.Name, runErr)
+			} else {
+				output, err = []byte(sanitizeOutput(string(append(stdout, stderr...)))), runErr
+				pooled = true
+			}
+		}
+		if !pooled {
+			output, err = s.combinedOutput(ctx, args)
+		}
+		if err != nil {
+			s.state(ctx).RecordEvent(sshParams.Name, "error", fmt.Sprintf("command %q failed: %v", sshParams.Command, err))
+			return nil, newDevPodError("failed to SSH into workspace", err, output)
+		}
+		s.state(ctx).RecordEvent(sshParams.Name, "command", fmt.Sprintf("Executed %q", sshParams.Command))
+		s.touchWorkspace(ctx, sshParams.Name)
+
+		return map[string]interface{}{
+			"name":       sshParams.Name,
+			"output":     string(output),
+			"pooled":     pooled,
+			"message":    "SSH command executed successfully",
+			"durationMs": durationMs(start),
+		}, nil
+	})
+
+	// Close pooled SSH connections
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_closeConnections",
+		Description: "Close pooled SSH connections and browser IDE tunnels to DevPod workspaces",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "The name of the workspace (optional, defaults to all)",
+				},
+			},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var closeParams struct {
+			Name string `json:"name,omitempty"`
+		}
+
+		if len(params) > 0 {
+			if err := json.Unmarshal(params, &closeParams); err != nil {
+				return nil, mcp.NewInvalidParamsError("Invalid close connections parameters")
+			}
+		}
+
+		closedTunnels := []string{}
+		if closeParams.Name != "" {
+			if s.ides.close(UserName(ctx), closeParams.Name) {
+				closedTunnels = append(closedTunnels, closeParams.Name)
+			}
+		} else {
+			closedTunnels = s.ides.closeUser(UserName(ctx))
+		}
+
+		if s.pool == nil {
+			return map[string]interface{}{
+				"closed":     []string{},
+				"ideTunnels": closedTunnels,
+				"message":    fmt.Sprintf("SSH connection pooling is disabled; closed %d IDE tunnel(s)", len(closedTunnels)),
+			}, nil
+		}
+
+		var closed []string
+		if closeParams.Name != "" {
+			if s.pool.Close(closeParams.Name) {
+				closed = append(closed, closeParams.Name)
+			}
+		} else {
+			closed = s.pool.CloseAll()
+		}
+		if closed == nil {
+			closed = []string{}
+		}
+
+		return map[string]interface{}{
+			"closed":     closed,
+			"ideTunnels": closedTunnels,
+			"message":    fmt.Sprintf("Closed %d SSH connection(s) and %d IDE tunnel(s)", len(closed), len(closedTunnels)),
+		}, nil
+	})
+}
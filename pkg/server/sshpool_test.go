@@ -0,0 +1,196 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+// fakeSSH is an ssh stand-in for the pool: a master creates its control
+// socket as a plain file, except for slow.devpod which never gets ready,
+// and commands run locally while the socket exists.
+const fakeSSH = `#!/bin/sh
+mode=run
+path=
+while [ $# -gt 0 ]; do
+	case "$1" in
+	-N) mode=master; shift ;;
+	-O) mode=$2; shift 2 ;;
+	-o) case "$2" in ControlPath=*) path=${2#ControlPath=} ;; esac; shift 2 ;;
+	*) break ;;
+	esac
+done
+host=$1
+shift
+case $mode in
+master)
+	[ "$host" = slow.devpod ] || touch "$path"
+	exec sleep 60 ;;
+check) test -e "$path" ;;
+exit) rm -f "$path" ;;
+run) test -e "$path" || exit 255; sh -c "$1" ;;
+esac
+`
+
+func newFakeSSHPool(t *testing.T) *sshPool {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the SSH pool is not supported on Windows")
+	}
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "ssh"), []byte(fakeSSH), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	pool, err := newSSHPool(time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		pool.CloseAll()
+		os.RemoveAll(pool.dir)
+	})
+	return pool
+}
+
+func TestSSHPoolDialsOutsideTheLock(t *testing.T) {
+	pool := newFakeSSHPool(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	slow := make(chan error, 1)
+	go func() {
+		_, err := pool.acquire(ctx, "slow")
+		slow <- err
+	}()
+	// Wait for the slow master to be starting
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		pool.mu.Lock()
+		_, starting := pool.conns["slow"]
+		pool.mu.Unlock()
+		if starting {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the slow connection to be starting")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	start := time.Now()
+	stdout, _, err := pool.Run(context.Background(), []string{"ssh", "api", "--command", "echo hi"})
+	if err != nil || strings.TrimSpace(string(stdout)) != "hi" {
+		t.Fatalf("Expected hi from api, got %q, %v", stdout, err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected api not to wait for the slow workspace, took %s", elapsed)
+	}
+
+	cancel()
+	select {
+	case err := <-slow:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected the slow dial to be cancelled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the slow dial to stop when its context is cancelled")
+	}
+	pool.mu.Lock()
+	_, left := pool.conns["slow"]
+	pool.mu.Unlock()
+	if left {
+		t.Error("Expected the cancelled connection to be removed from the pool")
+	}
+}
+
+func TestSSHPoolKeepsConnectionOnCommandExit255(t *testing.T) {
+	pool := newFakeSSHPool(t)
+
+	_, _, err := pool.Run(context.Background(), []string{"ssh", "api", "--command", "exit 255"})
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 255 {
+		t.Fatalf("Expected exit code 255, got %v", err)
+	}
+	pool.mu.Lock()
+	conn := pool.conns["api"]
+	pool.mu.Unlock()
+	if conn == nil || !conn.alive() {
+		t.Fatal("Expected the live connection to stay pooled")
+	}
+
+	// A master that went away is replaced
+	os.Remove(conn.controlPath)
+	if _, _, err := pool.Run(context.Background(), []string{"ssh", "api", "--command", "true"}); err != nil {
+		t.Fatalf("Expected the command to run over a new connection, got %v", err)
+	}
+	pool.mu.Lock()
+	replaced := pool.conns["api"]
+	pool.mu.Unlock()
+	if replaced == conn {
+		t.Error("Expected the dead connection to be replaced")
+	}
+}
+
+func TestSSHPoolRejectsNamesWithoutHostAlias(t *testing.T) {
+	pool := newFakeSSHPool(t)
+
+	for _, name := range []string{"-oProxyCommand=touch x", "../api", "API", ""} {
+		if _, _, err := pool.Run(context.Background(), []string{"ssh", name, "--command", "true"}); !errors.Is(err, errNoConnection) {
+			t.Errorf("Expected no pooled connection for %q, got %v", name, err)
+		}
+	}
+	if _, _, err := pool.Run(context.Background(), []string{"ssh", "api", "--command", "true", "--context", "staging"}); !errors.Is(err, errNoConnection) {
+		t.Errorf("Expected no pooled connection in another context, got %v", err)
+	}
+
+	path := pool.controlPath("api")
+	if filepath.Dir(path) != pool.dir || strings.Contains(filepath.Base(path), "api") {
+		t.Errorf("Expected a hashed control path in %s, got %s", pool.dir, path)
+	}
+}
+
+func TestPooledSSHGoesThroughBreakerAndContext(t *testing.T) {
+	pool := newFakeSSHPool(t)
+	runner := &fakeRunner{outputs: map[string]string{"ssh api --command echo hi --context staging": "staging\n"}}
+	s := newTestServer(t, runner)
+	s.pool = pool
+	handler := s.MCP().GetHandler("devpod_ssh")
+
+	result, err := handler(context.Background(), json.RawMessage(`{"name":"api","command":"echo hi"}`))
+	if err != nil {
+		t.Fatalf("devpod_ssh failed: %v", err)
+	}
+	if got := result.(map[string]interface{}); got["pooled"] != true || strings.TrimSpace(got["output"].(string)) != "hi" {
+		t.Errorf("Expected a pooled hi, got %v", got)
+	}
+
+	// A session in another context goes through devpod ssh
+	ctx := WithSessionID(context.Background(), "a")
+	if _, err := s.MCP().GetHandler("devpod_setDefaults")(ctx, json.RawMessage(`{"context":"staging"}`)); err != nil {
+		t.Fatalf("devpod_setDefaults failed: %v", err)
+	}
+	result, err = handler(ctx, json.RawMessage(`{"name":"api","command":"echo hi"}`))
+	if err != nil {
+		t.Fatalf("devpod_ssh failed: %v", err)
+	}
+	if got := result.(map[string]interface{}); got["pooled"] != false || got["output"] != "staging\n" {
+		t.Errorf("Expected devpod ssh in the staging context, got %v", got)
+	}
+
+	// An open breaker fails pooled calls fast too
+	s.breaker = newCircuitBreaker(BreakerPolicy{Threshold: 1, Cooldown: time.Minute}, nil)
+	s.breaker.record([]string{"up", "api"}, errors.New("exit status 1"), []byte("Cannot connect to the Docker daemon"))
+	_, err = handler(context.Background(), json.RawMessage(`{"name":"api","command":"echo hi"}`))
+	if rpcErr, ok := err.(*mcp.RPCError); !ok || rpcErr.Code != categoryCodes[CategoryBackendUnavailable] {
+		t.Errorf("Expected the open breaker to reject the pooled call, got %v", err)
+	}
+}
@@ -0,0 +1,899 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxTimelineEvents bounds the number of events kept per workspace
+const maxTimelineEvents = 200
+
+// timelineEvent is a single entry in a workspace's event timeline
+type timelineEvent struct {
+	Time    time.Time `json:"time"`
+	Type    string    `json:"type"`
+	Message string    `json:"message"`
+}
+
+// environment is a named group of workspaces managed together
+type environment struct {
+	Name       string    `json:"name"`
+	Workspaces []string  `json:"workspaces"`
+	Created    time.Time `json:"created"`
+}
+
+// prebuild is a devcontainer image build triggered through the server
+type prebuild struct {
+	ID         string     `json:"id"`
+	Source     string     `json:"source"`
+	Repository string     `json:"repository"`
+	Provider   string     `json:"provider,omitempty"`
+	Platforms  []string   `json:"platforms,omitempty"`
+	Status     string     `json:"status"`
+	Started    time.Time  `json:"started"`
+	Finished   *time.Time `json:"finished,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	// Output is the tail of the build output
+	Output string `json:"output,omitempty"`
+}
+
+// workspaceMetadata holds the tags and note agents attach to a workspace
+type workspaceMetadata struct {
+	Tags    []string  `json:"tags,omitempty"`
+	Note    string    `json:"note,omitempty"`
+	Updated time.Time `json:"updated"`
+}
+
+// empty reports whether the metadata holds nothing worth keeping
+func (m workspaceMetadata) empty() bool {
+	return len(m.Tags) == 0 && m.Note == ""
+}
+
+// stateData is the on-disk layout of the state store. The state of
+// authenticated users is kept apart under Users, so workspaces of the same
+// name never share tags, schedules or secrets.
+type stateData struct {
+	Timelines        map[string][]timelineEvent    `json:"timelines"`
+	CredentialScopes map[string][]string           `json:"credentialScopes,omitempty"`
+	Environments     map[string]environment        `json:"environments,omitempty"`
+	Prebuilds        map[string]prebuild           `json:"prebuilds,omitempty"`
+	Secrets          map[string]storedSecret       `json:"secrets,omitempty"`
+	Metadata         map[string]workspaceMetadata  `json:"metadata,omitempty"`
+	Schedules        map[string]scheduledOperation `json:"schedules,omitempty"`
+	Snapshots        map[string]workspaceSnapshot  `json:"snapshots,omitempty"`
+	SSHHosts         map[string]sshHost            `json:"sshHosts,omitempty"`
+	Creators         map[string]workspaceCreator   `json:"creators,omitempty"`
+	Operations       []operation                   `json:"operations,omitempty"`
+	Users            map[string]*stateData         `json:"users,omitempty"`
+}
+
+// init creates the maps a new or loaded state lacks and marks builds that
+// were running when the server stopped as interrupted, since they will
+// never finish
+func (d *stateData) init() {
+	if d.Timelines == nil {
+		d.Timelines = make(map[string][]timelineEvent)
+	}
+	if d.CredentialScopes == nil {
+		d.CredentialScopes = make(map[string][]string)
+	}
+	if d.Environments == nil {
+		d.Environments = make(map[string]environment)
+	}
+	if d.Prebuilds == nil {
+		d.Prebuilds = make(map[string]prebuild)
+	}
+	if d.Secrets == nil {
+		d.Secrets = make(map[string]storedSecret)
+	}
+	if d.Metadata == nil {
+		d.Metadata = make(map[string]workspaceMetadata)
+	}
+	if d.Schedules == nil {
+		d.Schedules = make(map[string]scheduledOperation)
+	}
+	if d.Snapshots == nil {
+		d.Snapshots = make(map[string]workspaceSnapshot)
+	}
+	if d.SSHHosts == nil {
+		d.SSHHosts = make(map[string]sshHost)
+	}
+	if d.Creators == nil {
+		d.Creators = make(map[string]workspaceCreator)
+	}
+	for id, build := range d.Prebuilds {
+		if build.Status == prebuildRunning {
+			build.Status = prebuildInterrupted
+			d.Prebuilds[id] = build
+		}
+	}
+}
+
+// stateDocument is the state file shared by the views of all users
+type stateDocument struct {
+	mu   sync.Mutex
+	path string
+	root stateData
+}
+
+// stateStore persists server state as a JSON document. A store without a
+// path keeps everything in memory. Each store is the view of one user, ""
+// for calls without one; forUser returns the view of another user.
+type stateStore struct {
+	*stateDocument
+	user string
+}
+
+// stateFile is the name of the state file in the data directory
+const stateFile = "state.json"
+
+// defaultStatePath returns the state file location under the user's config directory
+func defaultStatePath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "mcp-server-devpod", stateFile)
+}
+
+// openStateStore loads the state file at path, creating it on first write.
+// On error an in-memory store is returned alongside the error.
+func openStateStore(path string) (*stateStore, error) {
+	store := &stateStore{stateDocument: &stateDocument{}}
+	store.root.init()
+	if path == "" {
+		return store, fmt.Errorf("no state directory available")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return store, fmt.Errorf("failed to read state file: %w", err)
+	}
+	if len(data) > 0 {
+		var loaded stateData
+		if err := json.Unmarshal(data, &loaded); err != nil {
+			return store, fmt.Errorf("failed to parse state file %s: %w", path, err)
+		}
+		store.root = loaded
+		store.root.init()
+		for name, user := range store.root.Users {
+			if user == nil {
+				delete(store.root.Users, name)
+				continue
+			}
+			user.init()
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return store, fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	store.path = path
+	return store, nil
+}
+
+// forUser returns the view of the state of a user, "" for the state of
+// calls without one
+func (s *stateStore) forUser(user string) *stateStore {
+	if s == nil {
+		return nil
+	}
+	return &stateStore{stateDocument: s.stateDocument, user: user}
+}
+
+// Users returns the authenticated users with recorded state, ordered by name
+func (s *stateStore) Users() []string {
+	if s == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	users := make([]string, 0, len(s.root.Users))
+	for name := range s.root.Users {
+		users = append(users, name)
+	}
+	sort.Strings(users)
+	return users
+}
+
+// data returns the state of the store's user, creating it on first use.
+// Callers must hold s.mu.
+func (s *stateStore) data() *stateData {
+	if s.user == "" {
+		return &s.root
+	}
+	if s.root.Users == nil {
+		s.root.Users = make(map[string]*stateData)
+	}
+	data, ok := s.root.Users[s.user]
+	if !ok {
+		data = &stateData{}
+		data.init()
+		s.root.Users[s.user] = data
+	}
+	return data
+}
+
+// RecordEvent appends an event to the workspace timeline and persists it
+func (s *stateStore) RecordEvent(workspace, eventType, message string) {
+	if s == nil || workspace == "" {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	events := append(s.data().Timelines[workspace], timelineEvent{
+		Time:    time.Now().UTC(),
+		Type:    eventType,
+		Message: message,
+	})
+	if len(events) > maxTimelineEvents {
+		events = events[len(events)-maxTimelineEvents:]
+	}
+	s.data().Timelines[workspace] = events
+
+	if err := s.save(); err != nil {
+		log.Printf("WARNING: failed to persist state: %v", err)
+	}
+}
+
+// Timeline returns a copy of the events recorded for a workspace
+func (s *stateStore) Timeline(workspace string) []timelineEvent {
+	if s == nil {
+		return []timelineEvent{}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	events := make([]timelineEvent, len(s.data().Timelines[workspace]))
+	copy(events, s.data().Timelines[workspace])
+	return events
+}
+
+// SetCredentialScopes records the git credential scopes of a workspace. An
+// empty list removes any scoping.
+func (s *stateStore) SetCredentialScopes(workspace string, scopes []string) {
+	if s == nil || workspace == "" {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(scopes) == 0 {
+		delete(s.data().CredentialScopes, workspace)
+	} else {
+		s.data().CredentialScopes[workspace] = append([]string{}, scopes...)
+	}
+
+	if err := s.save(); err != nil {
+		log.Printf("WARNING: failed to persist state: %v", err)
+	}
+}
+
+// CredentialScopes returns the git credential scopes recorded for a workspace
+func (s *stateStore) CredentialScopes(workspace string) []string {
+	if s == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string{}, s.data().CredentialScopes[workspace]...)
+}
+
+// SetEnvironment records a workspace group, replacing any with the same name
+func (s *stateStore) SetEnvironment(env environment) {
+	if s == nil || env.Name == "" {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	env.Workspaces = append([]string{}, env.Workspaces...)
+	s.data().Environments[env.Name] = env
+
+	if err := s.save(); err != nil {
+		log.Printf("WARNING: failed to persist state: %v", err)
+	}
+}
+
+// Environment returns a recorded workspace group
+func (s *stateStore) Environment(name string) (environment, bool) {
+	if s == nil {
+		return environment{}, false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	env, ok := s.data().Environments[name]
+	env.Workspaces = append([]string{}, env.Workspaces...)
+	return env, ok
+}
+
+// Environments returns all recorded workspace groups ordered by name
+func (s *stateStore) Environments() []environment {
+	if s == nil {
+		return []environment{}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	envs := make([]environment, 0, len(s.data().Environments))
+	for _, env := range s.data().Environments {
+		env.Workspaces = append([]string{}, env.Workspaces...)
+		envs = append(envs, env)
+	}
+	sort.Slice(envs, func(i, j int) bool { return envs[i].Name < envs[j].Name })
+	return envs
+}
+
+// DeleteEnvironment forgets a workspace group
+func (s *stateStore) DeleteEnvironment(name string) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.data().Environments, name)
+
+	if err := s.save(); err != nil {
+		log.Printf("WARNING: failed to persist state: %v", err)
+	}
+}
+
+// SetPrebuild records a prebuild, replacing any with the same ID
+func (s *stateStore) SetPrebuild(build prebuild) {
+	if s == nil || build.ID == "" {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data().Prebuilds[build.ID] = build
+
+	if err := s.save(); err != nil {
+		log.Printf("WARNING: failed to persist state: %v", err)
+	}
+}
+
+// Prebuild returns a recorded prebuild
+func (s *stateStore) Prebuild(id string) (prebuild, bool) {
+	if s == nil {
+		return prebuild{}, false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	build, ok := s.data().Prebuilds[id]
+	return build, ok
+}
+
+// Prebuilds returns all recorded prebuilds, most recent first
+func (s *stateStore) Prebuilds() []prebuild {
+	if s == nil {
+		return []prebuild{}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	builds := make([]prebuild, 0, len(s.data().Prebuilds))
+	for _, build := range s.data().Prebuilds {
+		builds = append(builds, build)
+	}
+	sort.Slice(builds, func(i, j int) bool { return builds[i].Started.After(builds[j].Started) })
+	return builds
+}
+
+// DeletePrebuild forgets a prebuild
+func (s *stateStore) DeletePrebuild(id string) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.data().Prebuilds, id)
+
+	if err := s.save(); err != nil {
+		log.Printf("WARNING: failed to persist state: %v", err)
+	}
+}
+
+// SetSnapshot records a workspace snapshot, replacing any with the same ID
+func (s *stateStore) SetSnapshot(snapshot workspaceSnapshot) {
+	if s == nil || snapshot.ID == "" {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data().Snapshots[snapshot.ID] = snapshot
+
+	if err := s.save(); err != nil {
+		log.Printf("WARNING: failed to persist state: %v", err)
+	}
+}
+
+// Snapshots returns all recorded snapshots, most recent first
+func (s *stateStore) Snapshots() []workspaceSnapshot {
+	if s == nil {
+		return []workspaceSnapshot{}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshots := make([]workspaceSnapshot, 0, len(s.data().Snapshots))
+	for _, snapshot := range s.data().Snapshots {
+		snapshots = append(snapshots, snapshot)
+	}
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].Created.After(snapshots[j].Created) })
+	return snapshots
+}
+
+// SetSecret records an encrypted secret, replacing any with the same name
+func (s *stateStore) SetSecret(secret storedSecret) {
+	if s == nil || secret.Name == "" {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	secret.Workspaces = append([]string{}, secret.Workspaces...)
+	s.data().Secrets[secret.Name] = secret
+
+	if err := s.save(); err != nil {
+		log.Printf("WARNING: failed to persist state: %v", err)
+	}
+}
+
+// Secrets returns all recorded secrets ordered by name
+func (s *stateStore) Secrets() []storedSecret {
+	if s == nil {
+		return []storedSecret{}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	secrets := make([]storedSecret, 0, len(s.data().Secrets))
+	for _, secret := range s.data().Secrets {
+		secret.Workspaces = append([]string{}, secret.Workspaces...)
+		secrets = append(secrets, secret)
+	}
+	sort.Slice(secrets, func(i, j int) bool { return secrets[i].Name < secrets[j].Name })
+	return secrets
+}
+
+// DeleteSecret forgets a secret and reports whether it existed
+func (s *stateStore) DeleteSecret(name string) bool {
+	if s == nil {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.data().Secrets[name]; !ok {
+		return false
+	}
+	delete(s.data().Secrets, name)
+
+	if err := s.save(); err != nil {
+		log.Printf("WARNING: failed to persist state: %v", err)
+	}
+	return true
+}
+
+// SetMetadata records the tags and note of a workspace. Empty metadata
+// removes the record.
+func (s *stateStore) SetMetadata(workspace string, metadata workspaceMetadata) {
+	if s == nil || workspace == "" {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if metadata.empty() {
+		if _, ok := s.data().Metadata[workspace]; !ok {
+			return
+		}
+		delete(s.data().Metadata, workspace)
+	} else {
+		metadata.Tags = append([]string{}, metadata.Tags...)
+		s.data().Metadata[workspace] = metadata
+	}
+
+	if err := s.save(); err != nil {
+		log.Printf("WARNING: failed to persist state: %v", err)
+	}
+}
+
+// Metadata returns the tags and note recorded for a workspace
+func (s *stateStore) Metadata(workspace string) workspaceMetadata {
+	if s == nil {
+		return workspaceMetadata{}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	metadata := s.data().Metadata[workspace]
+	metadata.Tags = append([]string{}, metadata.Tags...)
+	return metadata
+}
+
+// SetSchedule records a scheduled operation, replacing any with the same ID
+func (s *stateStore) SetSchedule(op scheduledOperation) {
+	if s == nil || op.ID == "" {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data().Schedules[op.ID] = op
+
+	if err := s.save(); err != nil {
+		log.Printf("WARNING: failed to persist state: %v", err)
+	}
+}
+
+// UpdateSchedule replaces a scheduled operation unless it was cancelled
+// meanwhile
+func (s *stateStore) UpdateSchedule(op scheduledOperation) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.data().Schedules[op.ID]; !ok {
+		return
+	}
+	s.data().Schedules[op.ID] = op
+
+	if err := s.save(); err != nil {
+		log.Printf("WARNING: failed to persist state: %v", err)
+	}
+}
+
+// Schedules returns all scheduled operations ordered by workspace and creation
+func (s *stateStore) Schedules() []scheduledOperation {
+	if s == nil {
+		return []scheduledOperation{}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ops := make([]scheduledOperation, 0, len(s.data().Schedules))
+	for _, op := range s.data().Schedules {
+		ops = append(ops, op)
+	}
+	sort.Slice(ops, func(i, j int) bool {
+		if ops[i].Workspace != ops[j].Workspace {
+			return ops[i].Workspace < ops[j].Workspace
+		}
+		return ops[i].Created.Before(ops[j].Created)
+	})
+	return ops
+}
+
+// DeleteSchedule forgets a scheduled operation and reports whether it existed
+func (s *stateStore) DeleteSchedule(id string) bool {
+	if s == nil {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.data().Schedules[id]; !ok {
+		return false
+	}
+	delete(s.data().Schedules, id)
+
+	if err := s.save(); err != nil {
+		log.Printf("WARNING: failed to persist state: %v", err)
+	}
+	return true
+}
+
+// DeleteWorkspaceSchedules forgets the scheduled operations of a workspace
+func (s *stateStore) DeleteWorkspaceSchedules(workspace string) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := false
+	for id, op := range s.data().Schedules {
+		if op.Workspace == workspace {
+			delete(s.data().Schedules, id)
+			removed = true
+		}
+	}
+	if !removed {
+		return
+	}
+
+	if err := s.save(); err != nil {
+		log.Printf("WARNING: failed to persist state: %v", err)
+	}
+}
+
+// SetSSHHost records a host of the ssh provider, replacing any with the same name
+func (s *stateStore) SetSSHHost(host sshHost) {
+	if s == nil || host.Name == "" {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	host.Workspaces = append([]string{}, host.Workspaces...)
+	s.data().SSHHosts[host.Name] = host
+
+	if err := s.save(); err != nil {
+		log.Printf("WARNING: failed to persist state: %v", err)
+	}
+}
+
+// SSHHost returns a recorded host of the ssh provider
+func (s *stateStore) SSHHost(name string) (sshHost, bool) {
+	if s == nil {
+		return sshHost{}, false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	host, ok := s.data().SSHHosts[name]
+	host.Workspaces = append([]string{}, host.Workspaces...)
+	return host, ok
+}
+
+// SSHHosts returns all recorded hosts of the ssh provider ordered by name
+func (s *stateStore) SSHHosts() []sshHost {
+	if s == nil {
+		return []sshHost{}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hosts := make([]sshHost, 0, len(s.data().SSHHosts))
+	for _, host := range s.data().SSHHosts {
+		host.Workspaces = append([]string{}, host.Workspaces...)
+		hosts = append(hosts, host)
+	}
+	sort.Slice(hosts, func(i, j int) bool { return hosts[i].Name < hosts[j].Name })
+	return hosts
+}
+
+// DeleteSSHHost forgets a host of the ssh provider and reports whether it existed
+func (s *stateStore) DeleteSSHHost(name string) bool {
+	if s == nil {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.data().SSHHosts[name]; !ok {
+		return false
+	}
+	delete(s.data().SSHHosts, name)
+
+	if err := s.save(); err != nil {
+		log.Printf("WARNING: failed to persist state: %v", err)
+	}
+	return true
+}
+
+// SetWorkspaceHost records the host a workspace runs on, moving it from any
+// other host; an empty host forgets where it runs
+func (s *stateStore) SetWorkspaceHost(workspace, host string) {
+	if s == nil || workspace == "" {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	changed := false
+	for name, entry := range s.data().SSHHosts {
+		kept := entry.Workspaces[:0:0]
+		found := false
+		for _, ws := range entry.Workspaces {
+			if ws == workspace {
+				found = true
+				continue
+			}
+			kept = append(kept, ws)
+		}
+		if name == host {
+			kept = append(kept, workspace)
+			sort.Strings(kept)
+		}
+		if found || name == host {
+			entry.Workspaces = kept
+			s.data().SSHHosts[name] = entry
+			changed = changed || !found || name != host
+		}
+	}
+	if !changed {
+		return
+	}
+
+	if err := s.save(); err != nil {
+		log.Printf("WARNING: failed to persist state: %v", err)
+	}
+}
+
+// SetCreator records who created a workspace; a nil creator forgets it
+func (s *stateStore) SetCreator(workspace string, creator *workspaceCreator) {
+	if s == nil || workspace == "" {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if creator == nil {
+		if _, ok := s.data().Creators[workspace]; !ok {
+			return
+		}
+		delete(s.data().Creators, workspace)
+	} else {
+		s.data().Creators[workspace] = *creator
+	}
+
+	if err := s.save(); err != nil {
+		log.Printf("WARNING: failed to persist state: %v", err)
+	}
+}
+
+// Creator returns who created a workspace, or nil if it was not created
+// through the server
+func (s *stateStore) Creator(workspace string) *workspaceCreator {
+	if s == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	creator, ok := s.data().Creators[workspace]
+	if !ok {
+		return nil
+	}
+	return &creator
+}
+
+// RecordOperation appends an operation to the recent operations, marking
+// the one it undoes as undone, and persists it
+func (s *stateStore) RecordOperation(op operation) {
+	if s == nil || op.ID == "" {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if op.Undoes != "" {
+		for i := range s.data().Operations {
+			if s.data().Operations[i].ID == op.Undoes {
+				s.data().Operations[i].UndoneBy = op.ID
+			}
+		}
+	}
+	operations := append(s.data().Operations, op)
+	if len(operations) > maxOperations {
+		operations = operations[len(operations)-maxOperations:]
+	}
+	s.data().Operations = operations
+
+	if err := s.save(); err != nil {
+		log.Printf("WARNING: failed to persist state: %v", err)
+	}
+}
+
+// Operations returns the recent operations, most recent first
+func (s *stateStore) Operations() []operation {
+	if s == nil {
+		return []operation{}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	operations := make([]operation, len(s.data().Operations))
+	for i, op := range s.data().Operations {
+		operations[len(operations)-1-i] = op
+	}
+	return operations
+}
+
+// Workspaces returns the names of all workspaces with a recorded timeline
+func (s *stateStore) Workspaces() []string {
+	if s == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make([]string, 0, len(s.data().Timelines))
+	for name := range s.data().Timelines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// save writes the state file atomically. Callers must hold s.mu.
+func (s *stateStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(s.root, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// timelineURI returns the resource URI of a workspace timeline
+func timelineURI(name string) string {
+	return fmt.Sprintf("devpod://workspace/%s/timeline", name)
+}
+
+// parseTimelineURI extracts the workspace name from a timeline resource URI
+func parseTimelineURI(uri string) (string, bool) {
+	const prefix, suffix = "devpod://workspace/", "/timeline"
+	if !strings.HasPrefix(uri, prefix) || !strings.HasSuffix(uri, suffix) {
+		return "", false
+	}
+	name := strings.TrimSuffix(strings.TrimPrefix(uri, prefix), suffix)
+	if name == "" || strings.Contains(name, "/") {
+		return "", false
+	}
+	return name, true
+}
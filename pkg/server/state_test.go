@@ -0,0 +1,75 @@
+package server
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestParseTimelineURI(t *testing.T) {
+	name, ok := parseTimelineURI(timelineURI("my-workspace"))
+	if !ok || name != "my-workspace" {
+		t.Errorf("Expected my-workspace, got %q (ok=%v)", name, ok)
+	}
+
+	for _, uri := range []string{"devpod://workspace//timeline", "devpod://workspace/a/b/timeline", "file:///tmp"} {
+		if _, ok := parseTimelineURI(uri); ok {
+			t.Errorf("Expected %q to be rejected", uri)
+		}
+	}
+}
+
+func TestStateStorePersistsTimeline(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	store, err := openStateStore(path)
+	if err != nil {
+		t.Fatalf("Failed to open state store: %v", err)
+	}
+	store.RecordEvent("test1", "created", "Workspace created")
+	store.RecordEvent("test1", "started", "Workspace started")
+
+	reopened, err := openStateStore(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen state store: %v", err)
+	}
+
+	events := reopened.Timeline("test1")
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(events))
+	}
+	if events[0].Type != "created" || events[1].Type != "started" {
+		t.Errorf("Unexpected events: %v", events)
+	}
+}
+
+func TestStateStoreKeepsUsersApart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	store, err := openStateStore(path)
+	if err != nil {
+		t.Fatalf("Failed to open state store: %v", err)
+	}
+	store.SetMetadata("api", workspaceMetadata{Note: "shared"})
+	store.forUser("alice").SetMetadata("api", workspaceMetadata{Note: "alice's"})
+	store.forUser("alice").RecordEvent("api", "created", "Workspace created")
+
+	reopened, err := openStateStore(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen state store: %v", err)
+	}
+	if note := reopened.Metadata("api").Note; note != "shared" {
+		t.Errorf("Expected the note of calls without a user, got %q", note)
+	}
+	if note := reopened.forUser("alice").Metadata("api").Note; note != "alice's" {
+		t.Errorf("Expected alice's note, got %q", note)
+	}
+	if note := reopened.forUser("bob").Metadata("api").Note; note != "" {
+		t.Errorf("Expected no note for bob, got %q", note)
+	}
+	if events := reopened.Timeline("api"); len(events) != 0 {
+		t.Errorf("Expected alice's events to stay hers, got %v", events)
+	}
+	if users := reopened.Users(); len(users) != 2 || users[0] != "alice" || users[1] != "bob" {
+		t.Errorf("Expected alice and bob, got %v", users)
+	}
+}
@@ -0,0 +1,236 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+// statsCommand prints load, CPU count, memory, cgroup memory, disk usage of
+// the working directory (the project folder devpod ssh starts in) and the
+// busiest processes, separated by marker lines. Sections of tools missing in
+// the image stay empty.
+const statsCommand = `cat /proc/loadavg 2>/dev/null; echo ---; nproc 2>/dev/null || getconf _NPROCESSORS_ONLN 2>/dev/null; echo ---; grep -E '^(MemTotal|MemAvailable):' /proc/meminfo 2>/dev/null; echo ---; cat /sys/fs/cgroup/memory.max /sys/fs/cgroup/memory.current 2>/dev/null || cat /sys/fs/cgroup/memory/memory.limit_in_bytes /sys/fs/cgroup/memory/memory.usage_in_bytes 2>/dev/null; echo ---; df -Pk . 2>/dev/null | tail -n 1; pwd; echo ---; ps -eo pid=,pcpu=,pmem=,rss=,args= --sort=-pcpu 2>/dev/null | head -n %d`
+
+// maxTopProcesses bounds the processes a stats call may ask for
+const maxTopProcesses = 50
+
+// memoryStats is the memory use of a workspace. LimitBytes is the container
+// memory limit when one is set; UsedPercent is relative to it then.
+type memoryStats struct {
+	TotalBytes     int64   `json:"totalBytes"`
+	AvailableBytes int64   `json:"availableBytes"`
+	UsedBytes      int64   `json:"usedBytes"`
+	LimitBytes     int64   `json:"limitBytes,omitempty"`
+	UsedPercent    float64 `json:"usedPercent"`
+}
+
+// diskStats is the disk use of the filesystem holding the project folder
+type diskStats struct {
+	Path           string  `json:"path"`
+	Filesystem     string  `json:"filesystem"`
+	TotalBytes     int64   `json:"totalBytes"`
+	UsedBytes      int64   `json:"usedBytes"`
+	AvailableBytes int64   `json:"availableBytes"`
+	UsedPercent    float64 `json:"usedPercent"`
+}
+
+// processStats is one of the busiest processes in a workspace
+type processStats struct {
+	PID        int     `json:"pid"`
+	CPUPercent float64 `json:"cpuPercent"`
+	MemPercent float64 `json:"memPercent"`
+	RSSBytes   int64   `json:"rssBytes"`
+	Command    string  `json:"command"`
+}
+
+// workspaceStats is the resource usage inside a workspace container
+type workspaceStats struct {
+	// LoadAverage is the 1, 5 and 15 minute load average
+	LoadAverage  []float64      `json:"loadAverage,omitempty"`
+	CPUs         int            `json:"cpus,omitempty"`
+	Memory       *memoryStats   `json:"memory,omitempty"`
+	Disk         *diskStats     `json:"disk,omitempty"`
+	TopProcesses []processStats `json:"topProcesses"`
+	Warnings     []string       `json:"warnings,omitempty"`
+}
+
+// percent returns part as a percentage of total, rounded to one decimal
+func percent(part, total int64) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return float64(part*1000/total) / 10
+}
+
+// parseStats interprets the output of statsCommand
+func parseStats(output string) workspaceStats {
+	stats := workspaceStats{TopProcesses: []processStats{}}
+	sections := strings.Split(output, "---\n")
+	for len(sections) < 6 {
+		sections = append(sections, "")
+	}
+
+	for _, field := range strings.Fields(sections[0]) {
+		if len(stats.LoadAverage) == 3 {
+			break
+		}
+		if load, err := strconv.ParseFloat(field, 64); err == nil {
+			stats.LoadAverage = append(stats.LoadAverage, load)
+		}
+	}
+	if len(stats.LoadAverage) == 0 {
+		stats.Warnings = append(stats.Warnings, "load average not available")
+	}
+	if cpus, err := strconv.Atoi(strings.TrimSpace(sections[1])); err == nil {
+		stats.CPUs = cpus
+	}
+
+	meminfo := make(map[string]int64)
+	for _, line := range strings.Split(sections[2], "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 {
+			if kb, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
+				meminfo[strings.TrimSuffix(fields[0], ":")] = kb * 1024
+			}
+		}
+	}
+	if total := meminfo["MemTotal"]; total > 0 {
+		memory := &memoryStats{TotalBytes: total, AvailableBytes: meminfo["MemAvailable"]}
+		memory.UsedBytes = total - memory.AvailableBytes
+		memory.UsedPercent = percent(memory.UsedBytes, total)
+
+		// /proc/meminfo shows the host; a cgroup limit is what the container gets
+		if cgroup := strings.Fields(sections[3]); len(cgroup) == 2 {
+			limit, limitErr := strconv.ParseInt(cgroup[0], 10, 64)
+			used, usedErr := strconv.ParseInt(cgroup[1], 10, 64)
+			if limitErr == nil && usedErr == nil && limit > 0 && limit < total {
+				memory.LimitBytes = limit
+				memory.UsedBytes = used
+				memory.AvailableBytes = limit - used
+				memory.UsedPercent = percent(used, limit)
+			}
+		}
+		stats.Memory = memory
+	} else {
+		stats.Warnings = append(stats.Warnings, "memory usage not available")
+	}
+
+	diskLines := strings.Split(strings.TrimSpace(sections[4]), "\n")
+	if fields := strings.Fields(diskLines[0]); len(fields) >= 6 && len(diskLines) == 2 {
+		total, _ := strconv.ParseInt(fields[1], 10, 64)
+		used, _ := strconv.ParseInt(fields[2], 10, 64)
+		available, _ := strconv.ParseInt(fields[3], 10, 64)
+		stats.Disk = &diskStats{
+			Path:           strings.TrimSpace(diskLines[1]),
+			Filesystem:     fields[0],
+			TotalBytes:     total * 1024,
+			UsedBytes:      used * 1024,
+			AvailableBytes: available * 1024,
+			UsedPercent:    percent(used, total),
+		}
+	} else {
+		stats.Warnings = append(stats.Warnings, "disk usage not available")
+	}
+
+	for _, line := range strings.Split(sections[5], "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 5 {
+			continue
+		}
+		pid, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		cpu, _ := strconv.ParseFloat(fields[1], 64)
+		mem, _ := strconv.ParseFloat(fields[2], 64)
+		rss, _ := strconv.ParseInt(fields[3], 10, 64)
+		stats.TopProcesses = append(stats.TopProcesses, processStats{
+			PID:        pid,
+			CPUPercent: cpu,
+			MemPercent: mem,
+			RSSBytes:   rss * 1024,
+			Command:    strings.Join(fields[4:], " "),
+		})
+	}
+	if len(stats.TopProcesses) == 0 {
+		stats.Warnings = append(stats.Warnings, "process list not available")
+	}
+	return stats
+}
+
+// statsProbe returns the stats command listing the given number of processes
+func statsProbe(top int) string {
+	return fmt.Sprintf(statsCommand, top)
+}
+
+// registerStatsTools registers the tool that reports resource usage inside
+// workspaces
+func (s *Server) registerStatsTools() {
+	// Get resource usage inside a workspace
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_workspaceStats",
+		Description: "Report CPU load, memory, disk usage of the project folder and the busiest processes inside a running DevPod workspace",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "The name of the workspace",
+				},
+				"topProcesses": map[string]interface{}{
+					"type":        "integer",
+					"description": fmt.Sprintf("Number of processes to list, by CPU usage (default: 5, max: %d)", maxTopProcesses),
+				},
+			},
+			"required": []string{"name"},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var statsParams struct {
+			Name         string `json:"name"`
+			TopProcesses int    `json:"topProcesses"`
+		}
+
+		if err := json.Unmarshal(params, &statsParams); err != nil {
+			return nil, mcp.NewInvalidParamsError("Invalid stats parameters")
+		}
+
+		if statsParams.Name == "" {
+			return nil, mcp.NewInvalidParamsError("Workspace name is required")
+		}
+		if statsParams.TopProcesses <= 0 {
+			statsParams.TopProcesses = 5
+		}
+		if statsParams.TopProcesses > maxTopProcesses {
+			statsParams.TopProcesses = maxTopProcesses
+		}
+
+		if err := s.requireRunning(ctx, statsParams.Name); err != nil {
+			return nil, err
+		}
+
+		output, err := s.combinedOutput(ctx, []string{"ssh", statsParams.Name, "--command", statsProbe(statsParams.TopProcesses)})
+		if err != nil {
+			return nil, newDevPodError("failed to collect workspace stats", err, output)
+		}
+		s.touchWorkspace(ctx, statsParams.Name)
+
+		stats := parseStats(string(output))
+		result := map[string]interface{}{
+			"name":         statsParams.Name,
+			"loadAverage":  stats.LoadAverage,
+			"cpus":         stats.CPUs,
+			"memory":       stats.Memory,
+			"disk":         stats.Disk,
+			"topProcesses": stats.TopProcesses,
+		}
+		if len(stats.Warnings) > 0 {
+			result["warnings"] = stats.Warnings
+		}
+		return result, nil
+	})
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+const statsOutput = `2.50 1.75 1.00 3/412 9876
+---
+4
+---
+MemTotal:       16384000 kB
+MemAvailable:    8192000 kB
+---
+max
+104857600
+---
+overlay 61255492 30627746 30627746 50% /
+/workspaces/api
+---
+  123 95.0  2.5 409600 node /workspaces/api/node_modules/.bin/tsc --watch
+   45  3.2  0.4  65536 /usr/bin/dockerd
+`
+
+func TestParseStats(t *testing.T) {
+	stats := parseStats(statsOutput)
+
+	if len(stats.LoadAverage) != 3 || stats.LoadAverage[0] != 2.5 || stats.CPUs != 4 {
+		t.Errorf("Unexpected load %v on %d cpus", stats.LoadAverage, stats.CPUs)
+	}
+	// An unlimited cgroup falls back to the host memory
+	if stats.Memory == nil || stats.Memory.LimitBytes != 0 || stats.Memory.UsedBytes != 8192000*1024 || stats.Memory.UsedPercent != 50 {
+		t.Errorf("Unexpected memory %+v", stats.Memory)
+	}
+	if stats.Disk == nil || stats.Disk.Path != "/workspaces/api" || stats.Disk.UsedPercent != 50 || stats.Disk.TotalBytes != 61255492*1024 {
+		t.Errorf("Unexpected disk %+v", stats.Disk)
+	}
+	if len(stats.TopProcesses) != 2 || stats.TopProcesses[0].PID != 123 || stats.TopProcesses[0].Command != "node /workspaces/api/node_modules/.bin/tsc --watch" {
+		t.Errorf("Unexpected processes %+v", stats.TopProcesses)
+	}
+	if len(stats.Warnings) != 0 {
+		t.Errorf("Expected no warnings, got %v", stats.Warnings)
+	}
+
+	limited := parseStats("---\n---\nMemTotal: 16384000 kB\nMemAvailable: 8192000 kB\n---\n2147483648\n1073741824\n---\n---\n")
+	if limited.Memory.LimitBytes != 2147483648 || limited.Memory.UsedPercent != 50 {
+		t.Errorf("Expected the cgroup limit to be used, got %+v", limited.Memory)
+	}
+	if len(limited.Warnings) != 3 {
+		t.Errorf("Expected warnings for load, disk and processes, got %v", limited.Warnings)
+	}
+}
+
+func TestWorkspaceStats(t *testing.T) {
+	runner := &fakeRunner{outputs: map[string]string{
+		"status api --output json":             `{"id":"api","state":"Running"}`,
+		"ssh api --command " + statsProbe(10):  statsOutput,
+		"status idle --output json":            `{"id":"idle","state":"Stopped"}`,
+		"ssh idle --command " + statsProbe(10): statsOutput,
+	}}
+	s := newTestServer(t, runner)
+	handler := s.MCP().GetHandler("devpod_workspaceStats")
+
+	result, err := handler(context.Background(), json.RawMessage(`{"name":"api","topProcesses":10}`))
+	if err != nil {
+		t.Fatalf("devpod_workspaceStats failed: %v", err)
+	}
+	if processes := result.(map[string]interface{})["topProcesses"].([]processStats); len(processes) != 2 {
+		t.Errorf("Expected 2 processes, got %v", processes)
+	}
+
+	if _, err := handler(context.Background(), json.RawMessage(`{"name":"idle","topProcesses":10}`)); err == nil {
+		t.Error("Expected an error for a stopped workspace")
+	}
+	for _, call := range runner.calls {
+		if call[0] == "ssh" && call[1] == "idle" {
+			t.Error("Expected a stopped workspace not to be started over ssh")
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+// probeCommand lists listening TCP ports and IDE server processes inside a
+// workspace, separated by a marker line. It only relies on tools found in
+// most images and prints nothing for the ones that are missing.
+const probeCommand = `(ss -ltnH 2>/dev/null || netstat -ltn 2>/dev/null) | awk '{print $4}'; echo ---; ps -eo args 2>/dev/null | grep -E 'vscode-server|openvscode|remote-dev-server|jupyter' | grep -v grep`
+
+// ideServerPatterns are process name fragments of each IDE's server
+var ideServerPatterns = map[string][]string{
+	"vscode":          {"vscode-server", "openvscode"},
+	"vscode-insiders": {"vscode-server"},
+	"openvscode":      {"openvscode"},
+	"goland":          {"remote-dev-server"},
+	"intellij":        {"remote-dev-server"},
+	"pycharm":         {"remote-dev-server"},
+	"phpstorm":        {"remote-dev-server"},
+	"rustrover":       {"remote-dev-server"},
+	"webstorm":        {"remote-dev-server"},
+	"rider":           {"remote-dev-server"},
+	"clion":           {"remote-dev-server"},
+	"rubymine":        {"remote-dev-server"},
+	"jupyternotebook": {"jupyter"},
+}
+
+// providerHealth reports whether a workspace's provider is configured
+type providerHealth struct {
+	Name        string `json:"name"`
+	Found       bool   `json:"found"`
+	Initialized bool   `json:"initialized"`
+}
+
+// machineHealth reports the state of the machine a workspace runs on
+type machineHealth struct {
+	ID    string `json:"id"`
+	State string `json:"state"`
+}
+
+// containerHealth reports the dev container and what runs inside it
+type containerHealth struct {
+	State string `json:"state"`
+	// Ports are the TCP ports listening inside a running container
+	Ports []int  `json:"ports,omitempty"`
+	IDE   string `json:"ide,omitempty"`
+	// IDEServer is running, stopped or unknown when the IDE is not recognized
+	IDEServer string `json:"ideServer,omitempty"`
+}
+
+// parseProbe interprets the output of probeCommand for a running container
+func parseProbe(output, ide string) containerHealth {
+	probe := containerHealth{State: "Running", Ports: []int{}, IDE: ide, IDEServer: "unknown"}
+	ports, processes := output, ""
+	if i := strings.Index(output, "---"); i >= 0 {
+		ports, processes = output[:i], output[i+3:]
+	}
+
+	seen := make(map[int]bool)
+	for _, line := range strings.Split(ports, "\n") {
+		line = strings.TrimSpace(line)
+		i := strings.LastIndex(line, ":")
+		if i < 0 {
+			continue
+		}
+		port, err := strconv.Atoi(line[i+1:])
+		if err != nil || port <= 0 || seen[port] {
+			continue
+		}
+		seen[port] = true
+		probe.Ports = append(probe.Ports, port)
+	}
+	sort.Ints(probe.Ports)
+
+	if patterns, ok := ideServerPatterns[ide]; ok {
+		probe.IDEServer = "stopped"
+		for _, pattern := range patterns {
+			if strings.Contains(processes, pattern) {
+				probe.IDEServer = "running"
+				break
+			}
+		}
+	}
+	return probe
+}
+
+// lastError returns the most recent error in a workspace timeline
+func lastError(timeline []timelineEvent) *timelineEvent {
+	for i := len(timeline) - 1; i >= 0; i-- {
+		if timeline[i].Type == "error" {
+			event := timeline[i]
+			return &event
+		}
+	}
+	return nil
+}
+
+// enrichStatus adds the workspace entry, provider and machine health, the
+// container probe and the last recorded error to a devpod status result.
+// Sources that cannot be read are listed under warnings instead of failing.
+func (s *Server) enrichStatus(ctx context.Context, name string, status map[string]interface{}) {
+	var warnings []string
+	state, _ := status["state"].(string)
+
+	workspace, err := s.findWorkspace(ctx, name)
+	if err != nil {
+		warnings = append(warnings, fmt.Sprintf("workspace list: %v", err))
+	} else if workspace != nil {
+		status["workspace"] = workspace
+
+		if workspace.Provider.Name != "" {
+			health := providerHealth{Name: workspace.Provider.Name}
+			output, err := s.output(ctx, []string{"provider", "list", "--output", "json"})
+			var providers map[string]DevPodProvider
+			if err == nil {
+				err = json.Unmarshal(output, &providers)
+			}
+			if err != nil {
+				warnings = append(warnings, fmt.Sprintf("provider list: %v", err))
+			} else {
+				provider, ok := providers[workspace.Provider.Name]
+				health.Found = ok
+				health.Initialized = provider.State.Initialized
+				status["providerHealth"] = health
+			}
+		}
+
+		if id, _ := workspace.Machine["machineId"].(string); id != "" {
+			health := machineHealth{ID: id, State: "Unknown"}
+			output, err := s.output(ctx, []string{"machine", "status", id, "--output", "json"})
+			var machine struct {
+				State string `json:"state"`
+			}
+			if err == nil {
+				err = json.Unmarshal(output, &machine)
+			}
+			if err != nil {
+				warnings = append(warnings, fmt.Sprintf("machine status: %v", err))
+			} else if machine.State != "" {
+				health.State = machine.State
+			}
+			status["machine"] = health
+		}
+	}
+
+	container := containerHealth{State: state}
+	if workspace != nil {
+		container.IDE = workspace.IDE.Name
+	}
+	// Only probe running containers; ssh would otherwise start the workspace
+	if state == "Running" {
+		output, err := s.combinedOutput(ctx, []string{"ssh", name, "--command", probeCommand})
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("container probe: %v", err))
+		} else {
+			container = parseProbe(string(output), container.IDE)
+		}
+	}
+	status["container"] = container
+
+	if event := lastError(s.state(ctx).Timeline(name)); event != nil {
+		status["lastError"] = map[string]interface{}{
+			"time":       event.Time.Format(time.RFC3339),
+			"message":    event.Message,
+			"ageSeconds": int64(time.Since(event.Time).Seconds()),
+		}
+	}
+	if len(warnings) > 0 {
+		status["warnings"] = warnings
+	}
+}
+
+// registerStatusTools registers the tool that reports the status of a workspace
+func (s *Server) registerStatusTools() {
+	// Get workspace status
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_status",
+		Description: "Get the status of a specific DevPod workspace with provider, machine, container and IDE server health",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "The name of the workspace",
+				},
+			},
+			"required": []string{"name"},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var statusParams struct {
+			Name string `json:"name"`
+		}
+
+		if err := json.Unmarshal(params, &statusParams); err != nil {
+			return nil, mcp.NewInvalidParamsError("Invalid status parameters")
+		}
+
+		if statusParams.Name == "" {
+			return nil, mcp.NewInvalidParamsError("Workspace name is required")
+		}
+
+		output, stderr, err := s.run(ctx, []string{"status", statusParams.Name, "--output", "json"})
+		if err != nil {
+			return nil, newDevPodError("failed to get workspace status", err, stderr)
+		}
+
+		var status map[string]interface{}
+		if err := json.Unmarshal(output, &status); err != nil {
+			if s.opts.StrictJSON {
+				return nil, unsupportedOutputError("status", err)
+			}
+			// If JSON parsing fails, return the text output
+			return map[string]interface{}{
+				"name":   statusParams.Name,
+				"status": strings.TrimSpace(string(output)),
+			}, nil
+		}
+
+		normalizeFields(status)
+		s.enrichStatus(ctx, statusParams.Name, status)
+		return status, nil
+	})
+}
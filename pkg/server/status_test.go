@@ -0,0 +1,65 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestParseProbe(t *testing.T) {
+	output := "0.0.0.0:8080\n[::]:8080\n127.0.0.1:5432\n---\n/home/vscode/.vscode-server/bin/node server.js\n"
+
+	probe := parseProbe(output, "vscode")
+	if len(probe.Ports) != 2 || probe.Ports[0] != 5432 || probe.Ports[1] != 8080 {
+		t.Errorf("Expected ports [5432 8080], got %v", probe.Ports)
+	}
+	if probe.IDEServer != "running" {
+		t.Errorf("Expected vscode server running, got %s", probe.IDEServer)
+	}
+	if probe := parseProbe(output, "goland"); probe.IDEServer != "stopped" {
+		t.Errorf("Expected goland server stopped, got %s", probe.IDEServer)
+	}
+	if probe := parseProbe(output, "none"); probe.IDEServer != "unknown" {
+		t.Errorf("Expected unknown server state for IDE none, got %s", probe.IDEServer)
+	}
+}
+
+func TestStatusAggregatesSources(t *testing.T) {
+	runner := &fakeRunner{
+		outputs: map[string]string{
+			"status api --output json":          `{"id":"api","state":"Running"}`,
+			"list --output json":                `[{"id":"api","provider":{"name":"aws"},"machine":{"machineId":"m1"},"ide":{"name":"vscode"}}]`,
+			"provider list --output json":       `{"aws":{"state":{"initialized":true}}}`,
+			"machine status m1 --output json":   `{"state":"Running"}`,
+			"ssh api --command " + probeCommand: "0.0.0.0:3000\n---\nopenvscode-server\n",
+		},
+	}
+	s := newTestServer(t, runner)
+	s.store.RecordEvent("api", "error", "start failed: exit status 1")
+
+	result, err := s.MCP().GetHandler("devpod_status")(context.Background(), json.RawMessage(`{"name":"api"}`))
+	if err != nil {
+		t.Fatalf("devpod_status failed: %v", err)
+	}
+	status := result.(map[string]interface{})
+
+	if status["state"] != "Running" {
+		t.Errorf("Expected the devpod status fields to be kept, got %v", status["state"])
+	}
+	if health := status["providerHealth"].(providerHealth); !health.Found || !health.Initialized {
+		t.Errorf("Unexpected provider health %+v", health)
+	}
+	if machine := status["machine"].(machineHealth); machine.State != "Running" {
+		t.Errorf("Unexpected machine health %+v", machine)
+	}
+	container := status["container"].(containerHealth)
+	if len(container.Ports) != 1 || container.Ports[0] != 3000 || container.IDEServer != "running" {
+		t.Errorf("Unexpected container health %+v", container)
+	}
+	if status["lastError"] == nil {
+		t.Error("Expected the last recorded error")
+	}
+	if status["warnings"] != nil {
+		t.Errorf("Expected no warnings, got %v", status["warnings"])
+	}
+}
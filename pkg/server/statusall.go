@@ -0,0 +1,274 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+// workspaceReport is one workspace in the devpod_statusAll report
+type workspaceReport struct {
+	Name     string `json:"name"`
+	State    string `json:"state"`
+	Provider string `json:"provider,omitempty"`
+	IDE      string `json:"ide,omitempty"`
+	// UptimeSeconds is the time since a running workspace was created or
+	// started through the server
+	UptimeSeconds *int64 `json:"uptimeSeconds,omitempty"`
+	AgeSeconds    *int64 `json:"ageSeconds,omitempty"`
+	IdleSeconds   *int64 `json:"idleSeconds,omitempty"`
+	// Error is the most recent error no successful create or start followed
+	Error *reportedError `json:"error,omitempty"`
+}
+
+// reportedError is an error recorded in a workspace timeline
+type reportedError struct {
+	Time       time.Time `json:"time"`
+	Message    string    `json:"message"`
+	AgeSeconds int64     `json:"ageSeconds"`
+}
+
+// providerReport is one provider in the devpod_statusAll report
+type providerReport struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+	// Installed is false for providers workspaces use that devpod no longer has
+	Installed   bool `json:"installed"`
+	Initialized bool `json:"initialized"`
+	Default     bool `json:"default,omitempty"`
+	Workspaces  int  `json:"workspaces"`
+	Healthy     bool `json:"healthy"`
+}
+
+// statusReport is the devpod_statusAll result
+type statusReport struct {
+	Workspaces []workspaceReport `json:"workspaces"`
+	Providers  []providerReport  `json:"providers"`
+	// States counts the workspaces by state
+	States map[string]int `json:"states"`
+	// Problems lists what needs attention, one line each
+	Problems []string `json:"problems"`
+	// Warnings lists the sources that could not be read
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// statusAll reports every workspace with its state and any unresolved error,
+// and the health of the providers they use
+func (s *Server) statusAll(ctx context.Context) (*statusReport, error) {
+	workspaces, err := s.listWorkspaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	report := &statusReport{
+		Workspaces: make([]workspaceReport, len(workspaces)),
+		Providers:  []providerReport{},
+		States:     map[string]int{},
+		Problems:   []string{},
+	}
+	var wg sync.WaitGroup
+	for i, workspace := range workspaces {
+		wg.Add(1)
+		go func(i int, workspace DevPodWorkspace) {
+			defer wg.Done()
+			entry := workspaceReport{
+				Name:        workspace.ID,
+				State:       s.getWorkspaceState(ctx, workspace.ID),
+				Provider:    workspace.Provider.Name,
+				IDE:         workspace.IDE.Name,
+				AgeSeconds:  workspace.AgeSeconds,
+				IdleSeconds: workspace.IdleSeconds,
+			}
+			started, failure := timelineHealth(s.state(ctx).Timeline(workspace.ID))
+			if started != nil && entry.State == "Running" {
+				uptime := int64(now.Sub(*started).Seconds())
+				entry.UptimeSeconds = &uptime
+			}
+			if failure != nil {
+				entry.Error = &reportedError{Time: failure.Time, Message: failure.Message, AgeSeconds: int64(now.Sub(failure.Time).Seconds())}
+			}
+			report.Workspaces[i] = entry
+		}(i, workspace)
+	}
+	wg.Wait()
+	sort.Slice(report.Workspaces, func(i, j int) bool { return report.Workspaces[i].Name < report.Workspaces[j].Name })
+
+	used := map[string]int{}
+	for _, entry := range report.Workspaces {
+		report.States[entry.State]++
+		if entry.Provider != "" {
+			used[entry.Provider]++
+		}
+		if entry.State == "Unknown" {
+			report.Problems = append(report.Problems, fmt.Sprintf("workspace %s: state unknown, devpod status failed", entry.Name))
+		}
+		if entry.Error != nil {
+			report.Problems = append(report.Problems, fmt.Sprintf("workspace %s: %s", entry.Name, entry.Error.Message))
+		}
+	}
+
+	providers, err := s.installedProviders(ctx)
+	if err != nil {
+		report.Warnings = append(report.Warnings, "providers: "+err.Error())
+		return report, nil
+	}
+	for name, provider := range providers {
+		report.Providers = append(report.Providers, providerReport{
+			Name:        name,
+			Version:     provider.Config.Version,
+			Installed:   true,
+			Initialized: provider.State.Initialized,
+			Default:     provider.Default,
+			Workspaces:  used[name],
+			Healthy:     provider.State.Initialized,
+		})
+	}
+	for name, count := range used {
+		if _, ok := providers[name]; !ok {
+			report.Providers = append(report.Providers, providerReport{Name: name, Workspaces: count})
+		}
+	}
+	sort.Slice(report.Providers, func(i, j int) bool { return report.Providers[i].Name < report.Providers[j].Name })
+	for _, provider := range report.Providers {
+		switch {
+		case !provider.Installed:
+			report.Problems = append(report.Problems, fmt.Sprintf("provider %s: not installed, but %d workspace(s) use it", provider.Name, provider.Workspaces))
+		case !provider.Initialized:
+			report.Problems = append(report.Problems, fmt.Sprintf("provider %s: not initialized", provider.Name))
+		}
+	}
+	return report, nil
+}
+
+// timelineHealth returns when the workspace was last created or started and
+// the most recent error no later create or start resolved
+func timelineHealth(timeline []timelineEvent) (*time.Time, *timelineEvent) {
+	var failure *timelineEvent
+	for i := len(timeline) - 1; i >= 0; i-- {
+		event := timeline[i]
+		switch event.Type {
+		case "error":
+			if failure == nil {
+				failure = &event
+			}
+		case "created", "recreated", "started":
+			return &event.Time, failure
+		case "stopped", "deleted":
+			return nil, failure
+		}
+	}
+	return nil, failure
+}
+
+// summary renders the report as a short text block for the model to read
+func (r *statusReport) summary() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n", r.headline())
+	for _, entry := range r.Workspaces {
+		fmt.Fprintf(&b, "- %s: %s", entry.Name, entry.State)
+		if entry.Provider != "" {
+			fmt.Fprintf(&b, " on %s", entry.Provider)
+		}
+		if entry.UptimeSeconds != nil {
+			fmt.Fprintf(&b, ", up %s", roughDuration(*entry.UptimeSeconds))
+		}
+		if entry.IdleSeconds != nil {
+			fmt.Fprintf(&b, ", idle %s", roughDuration(*entry.IdleSeconds))
+		}
+		if entry.Error != nil {
+			fmt.Fprintf(&b, ", error %s ago: %s", roughDuration(entry.Error.AgeSeconds), entry.Error.Message)
+		}
+		b.WriteString("\n")
+	}
+	if len(r.Providers) > 0 {
+		var providers []string
+		for _, provider := range r.Providers {
+			health := "ok"
+			if !provider.Installed {
+				health = "not installed"
+			} else if !provider.Initialized {
+				health = "not initialized"
+			}
+			if provider.Default {
+				health = "default, " + health
+			}
+			providers = append(providers, fmt.Sprintf("%s (%s)", provider.Name, health))
+		}
+		fmt.Fprintf(&b, "Providers: %s\n", strings.Join(providers, ", "))
+	}
+	for _, warning := range r.Warnings {
+		fmt.Fprintf(&b, "Not checked: %s\n", warning)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// headline counts the workspaces by state and the problems in one line
+func (r *statusReport) headline() string {
+	states := make([]string, 0, len(r.States))
+	for state := range r.States {
+		states = append(states, state)
+	}
+	sort.Strings(states)
+	counts := make([]string, len(states))
+	for i, state := range states {
+		counts[i] = fmt.Sprintf("%d %s", r.States[state], strings.ToLower(state))
+	}
+	headline := fmt.Sprintf("%d workspace(s)", len(r.Workspaces))
+	if len(counts) > 0 {
+		headline += ": " + strings.Join(counts, ", ")
+	}
+	if len(r.Problems) == 0 {
+		return headline + "; no problems"
+	}
+	return fmt.Sprintf("%s; %d problem(s)", headline, len(r.Problems))
+}
+
+// roughDuration formats seconds in the largest whole unit, e.g. 3d or 45m
+func roughDuration(seconds int64) string {
+	switch {
+	case seconds < 60:
+		return fmt.Sprintf("%ds", seconds)
+	case seconds < 3600:
+		return fmt.Sprintf("%dm", seconds/60)
+	case seconds < 48*3600:
+		return fmt.Sprintf("%dh", seconds/3600)
+	}
+	return fmt.Sprintf("%dd", seconds/86400)
+}
+
+// registerStatusAllTools registers the tool that reports on all workspaces and
+// providers
+func (s *Server) registerStatusAllTools() {
+	// Report on all workspaces and providers at once
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_statusAll",
+		Description: "Report every DevPod workspace with its state, provider, uptime, idle time and unresolved errors, plus the health of the providers, as structured data and a short text summary",
+		InputSchema: map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		report, err := s.statusAll(ctx)
+		if err != nil {
+			return nil, newDevPodError("failed to list workspaces", err, nil)
+		}
+		result := map[string]interface{}{
+			"workspaces": report.Workspaces,
+			"providers":  report.Providers,
+			"states":     report.States,
+			"problems":   report.Problems,
+			"summary":    report.summary(),
+			"message":    report.headline(),
+		}
+		if len(report.Warnings) > 0 {
+			result["warnings"] = report.Warnings
+		}
+		return result, nil
+	})
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatusAll(t *testing.T) {
+	runner := &fakeRunner{outputs: map[string]string{
+		"list --output json":          `[{"id":"web","provider":{"name":"docker"}},{"id":"api","provider":{"name":"docker"}},{"id":"gpu","provider":{"name":"aws"}}]`,
+		"status api --output json":    `{"state":"Running"}`,
+		"status web --output json":    `{"state":"Stopped"}`,
+		"status gpu --output json":    `{"state":"Stopped"}`,
+		"provider list --output json": `{"docker":{"default":true,"state":{"initialized":true}},"kubernetes":{}}`,
+	}}
+	s := newTestServer(t, runner)
+	s.store.RecordEvent("api", "error", "create failed: network unreachable")
+	s.store.RecordEvent("api", "created", "Workspace created")
+	s.store.RecordEvent("web", "started", "Workspace started")
+	s.store.RecordEvent("web", "error", "stop failed: timeout")
+
+	report, err := s.statusAll(context.Background())
+	if err != nil {
+		t.Fatalf("statusAll failed: %v", err)
+	}
+	api, web := report.Workspaces[0], report.Workspaces[2]
+	if api.Name != "api" || api.UptimeSeconds == nil || api.Error != nil {
+		t.Errorf("Expected api to be up with its creation error resolved, got %+v", api)
+	}
+	if web.Name != "web" || web.UptimeSeconds != nil || web.Error == nil || web.Error.Message != "stop failed: timeout" {
+		t.Errorf("Expected web's unresolved error to be reported, got %+v", web)
+	}
+	if report.States["Stopped"] != 2 || report.States["Running"] != 1 {
+		t.Errorf("Unexpected state counts %v", report.States)
+	}
+	problems := strings.Join(report.Problems, "\n")
+	for _, want := range []string{"workspace web: stop failed", "provider aws: not installed, but 1 workspace(s) use it", "provider kubernetes: not initialized"} {
+		if !strings.Contains(problems, want) {
+			t.Errorf("Expected problem %q, got %v", want, report.Problems)
+		}
+	}
+
+	summary := report.summary()
+	for _, want := range []string{"3 workspace(s): 1 running, 2 stopped; 3 problem(s)", "- api: Running on docker, up 0s", "- web: Stopped on docker, error 0s ago: stop failed: timeout", "docker (default, ok)"} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("Expected %q in the summary:\n%s", want, summary)
+		}
+	}
+}
+
+func TestRoughDuration(t *testing.T) {
+	for seconds, want := range map[int64]string{45: "45s", 150: "2m", int64(5 * time.Hour / time.Second): "5h", 3 * 86400: "3d"} {
+		if got := roughDuration(seconds); got != want {
+			t.Errorf("roughDuration(%d) = %s, want %s", seconds, got, want)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// streamInterval is how often streamed devpod output is sent to the client
+const streamInterval = 250 * time.Millisecond
+
+// maxStreamBatch bounds the lines sent in one notification; the rest follow
+// in the next
+const maxStreamBatch = 100
+
+// requestIDKey is the context key for the JSON-RPC ID of the request a call
+// serves
+type requestIDKey struct{}
+
+// withRequestID returns a context carrying the ID of the request being served
+func withRequestID(ctx context.Context, id interface{}) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// requestID returns the ID of the request being served, or nil if unknown
+func requestID(ctx context.Context) interface{} {
+	return ctx.Value(requestIDKey{})
+}
+
+// sessionSender is implemented by transports that can send a message to a
+// single session
+type sessionSender interface {
+	SendTo(sessionID string, message []byte) error
+}
+
+// userSender is implemented by transports that can send a message to the
+// sessions of one authenticated user
+type userSender interface {
+	SendToUser(user string, message []byte) error
+}
+
+// notification encodes a JSON-RPC notification
+func notification(method string, params interface{}) ([]byte, error) {
+	paramsBytes, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  method,
+		"params":  json.RawMessage(paramsBytes),
+	})
+}
+
+// notifySession sends a notification to the session of ctx when the
+// transport can address it, else to every client
+func (s *Server) notifySession(ctx context.Context, method string, params interface{}) error {
+	sender, ok := s.transport.(sessionSender)
+	if id := SessionID(ctx); ok && id != "" {
+		message, err := notification(method, params)
+		if err != nil {
+			return err
+		}
+		return sender.SendTo(id, message)
+	}
+	return s.mcp.SendNotification(method, params)
+}
+
+// notifyUser sends a notification to the sessions of a user, or to the
+// sessions without one for "", when the transport tells users apart, else
+// to every client
+func (s *Server) notifyUser(user, method string, params interface{}) error {
+	sender, ok := s.transport.(userSender)
+	if !ok {
+		return s.mcp.SendNotification(method, params)
+	}
+	message, err := notification(method, params)
+	if err != nil {
+		return err
+	}
+	return sender.SendToUser(user, message)
+}
+
+// jsonOutput reports whether devpod is asked for JSON output
+func jsonOutput(args []string) bool {
+	for i, arg := range args {
+		if arg == "--output=json" || (arg == "--output" && i+1 < len(args) && args[i+1] == "json") {
+			return true
+		}
+	}
+	return false
+}
+
+// outputStream sends the console output of the devpod commands of a tool
+// call to the client while they run. Lines are sanitized and redacted and
+// sent in batches: as notifications/progress messages when the client asked
+// for progress, and as devpod/commandOutput notifications to HTTP Streams
+// sessions.
+type outputStream struct {
+	server *Server
+	ctx    context.Context
+	tool   string
+	// token is the client's progress token, nil when it asked for none
+	token interface{}
+	// session is set when the call came from an addressable session
+	session bool
+
+	mu      sync.Mutex
+	partial string
+	pending []string
+	sent    int
+	// closed drops the output of commands that outlive the call
+	closed bool
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// streamOutput returns a context whose devpod output is streamed to the
+// client and a function that sends the rest and stops streaming. Calls
+// without a progress token outside an HTTP Streams session are not
+// streamed.
+func (s *Server) streamOutput(ctx context.Context, tool string, token interface{}) (context.Context, func()) {
+	_, addressable := s.transport.(sessionSender)
+	session := addressable && SessionID(ctx) != ""
+	if (token == nil && !session) || !s.running() {
+		return ctx, func() {}
+	}
+
+	stream := &outputStream{
+		server:  s,
+		ctx:     ctx,
+		tool:    tool,
+		token:   token,
+		session: session,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go stream.loop()
+	return WithOutputWriter(ctx, stream), stream.close
+}
+
+// Write implements io.Writer, collecting complete lines
+func (o *outputStream) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.closed {
+		return len(p), nil
+	}
+	text := o.partial + string(p)
+	i := strings.LastIndex(text, "\n")
+	if i < 0 {
+		o.partial = text
+		return len(p), nil
+	}
+	o.partial = text[i+1:]
+	o.add(text[:i])
+	return len(p), nil
+}
+
+// add queues the lines of text that carry anything once sanitized
+func (o *outputStream) add(text string) {
+	for _, line := range strings.Split(sanitizeOutput(text), "\n") {
+		if line = strings.TrimRight(line, " \t"); line != "" {
+			o.pending = append(o.pending, o.server.redactor.redact(line))
+		}
+	}
+}
+
+func (o *outputStream) loop() {
+	defer close(o.done)
+	ticker := time.NewTicker(streamInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			o.flush()
+		case <-o.stop:
+			return
+		}
+	}
+}
+
+// flush sends the queued lines
+func (o *outputStream) flush() {
+	for {
+		o.mu.Lock()
+		lines := o.pending
+		if len(lines) > maxStreamBatch {
+			lines = lines[:maxStreamBatch]
+		}
+		o.pending = o.pending[len(lines):]
+		o.sent += len(lines)
+		sent := o.sent
+		o.mu.Unlock()
+		if len(lines) == 0 {
+			return
+		}
+		o.send(lines, sent)
+	}
+}
+
+func (o *outputStream) send(lines []string, sent int) {
+	if o.token != nil {
+		if err := o.server.notifySession(o.ctx, "notifications/progress", map[string]interface{}{
+			"progressToken": o.token,
+			"progress":      sent,
+			"message":       strings.Join(lines, "\n"),
+		}); err != nil {
+			log.Printf("WARNING: failed to send progress notification: %v", err)
+		}
+	}
+	if o.session {
+		params := map[string]interface{}{
+			"tool":  o.tool,
+			"lines": lines,
+		}
+		if id := requestID(o.ctx); id != nil {
+			params["requestId"] = id
+		}
+		if err := o.server.notifySession(o.ctx, "devpod/commandOutput", params); err != nil {
+			log.Printf("WARNING: failed to send command output notification: %v", err)
+		}
+	}
+}
+
+// close sends the remaining output, including an unterminated last line,
+// and stops the stream. It runs before the result is returned, so the
+// output arrives ahead of it.
+func (o *outputStream) close() {
+	close(o.stop)
+	<-o.done
+	o.mu.Lock()
+	if o.partial != "" {
+		o.add(o.partial)
+		o.partial = ""
+	}
+	o.closed = true
+	o.mu.Unlock()
+	o.flush()
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// sessionTransport records the messages sent to single sessions
+type sessionTransport struct {
+	recordingTransport
+	mu       sync.Mutex
+	sessions map[string][][]byte
+}
+
+func (s *sessionTransport) SendTo(sessionID string, message []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[sessionID] = append(s.sessions[sessionID], message)
+	return nil
+}
+
+// notifications returns the params of the notifications of a method
+func notifications(t *testing.T, messages [][]byte, method string) []map[string]interface{} {
+	t.Helper()
+	var found []map[string]interface{}
+	for _, message := range messages {
+		var notification struct {
+			Method string                 `json:"method"`
+			Params map[string]interface{} `json:"params"`
+		}
+		if err := json.Unmarshal(message, &notification); err != nil {
+			t.Fatalf("Failed to parse notification: %v", err)
+		}
+		if notification.Method == method {
+			found = append(found, notification.Params)
+		}
+	}
+	return found
+}
+
+func TestStreamOutputAsProgress(t *testing.T) {
+	transport := &recordingTransport{}
+	s := New(transport, Options{
+		Runner:    &fakeRunner{outputs: map[string]string{"stop ws1": "\x1b[32minfo\x1b[0m Stopping container\n\ninfo Stopped"}},
+		StatePath: filepath.Join(t.TempDir(), "state.json"),
+	})
+	s.started.Store(true)
+
+	params := `{"name":"devpod_stopWorkspace","arguments":{"name":"ws1"},"_meta":{"progressToken":"tok-1"}}`
+	if _, err := s.MCP().GetHandler("tools/call")(context.Background(), json.RawMessage(params)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	transport.mu.Lock()
+	progress := notifications(t, transport.sent, "notifications/progress")
+	transport.mu.Unlock()
+	if len(progress) != 1 {
+		t.Fatalf("Expected one progress notification, got %v", progress)
+	}
+	if progress[0]["progressToken"] != "tok-1" || progress[0]["message"] != "info Stopping container\ninfo Stopped" || progress[0]["progress"] != float64(2) {
+		t.Errorf("Unexpected progress notification %v", progress[0])
+	}
+}
+
+func TestStreamOutputToSession(t *testing.T) {
+	transport := &sessionTransport{sessions: make(map[string][][]byte)}
+	s := New(transport, Options{
+		Runner: &fakeRunner{outputs: map[string]string{
+			"stop ws1":           "info Stopping container\n",
+			"list --output json": `[{"id":"ws1"}]`,
+		}},
+		StatePath: filepath.Join(t.TempDir(), "state.json"),
+	})
+	s.started.Store(true)
+
+	ctx := withRequestID(WithSessionID(context.Background(), "session-a"), 7)
+	if _, err := s.MCP().GetHandler("tools/call")(ctx, json.RawMessage(`{"name":"devpod_stopWorkspace","arguments":{"name":"ws1"}}`)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := s.MCP().GetHandler("tools/call")(ctx, json.RawMessage(`{"name":"devpod_listWorkspaces","arguments":{}}`)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	transport.mu.Lock()
+	output := notifications(t, transport.sessions["session-a"], "devpod/commandOutput")
+	transport.mu.Unlock()
+	if len(output) != 1 {
+		t.Fatalf("Expected the stop output only, got %v", output)
+	}
+	if output[0]["tool"] != "devpod_stopWorkspace" || output[0]["requestId"] != float64(7) || !strings.Contains(mustJSON(t, output[0]["lines"]), "Stopping container") {
+		t.Errorf("Unexpected command output notification %v", output[0])
+	}
+	if progress := notifications(t, transport.sent, "devpod/commandOutput"); len(progress) != 0 {
+		t.Errorf("Expected no output sent to other sessions, got %v", progress)
+	}
+}
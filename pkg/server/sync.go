@@ -0,0 +1,312 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+// Directions of devpod_syncDirectory
+const (
+	syncPush = "push"
+	syncPull = "pull"
+)
+
+// syncChange is a file or directory rsync changed or would change
+type syncChange struct {
+	Path string `json:"path"`
+	// Action is created, updated or deleted
+	Action string `json:"action"`
+	Dir    bool   `json:"dir,omitempty"`
+}
+
+// syncReport summarizes an rsync run
+type syncReport struct {
+	Changes []syncChange `json:"changes"`
+	// FilesTransferred and BytesTransferred come from rsync's statistics
+	FilesTransferred int   `json:"filesTransferred"`
+	BytesTransferred int64 `json:"bytesTransferred"`
+	Deleted          int   `json:"deleted"`
+}
+
+// syncOptions are the settings of a devpod_syncDirectory call
+type syncOptions struct {
+	Include []string
+	Exclude []string
+	Delete  bool
+	DryRun  bool
+}
+
+// rsyncArgs returns the rsync arguments copying the contents of source into
+// dest through the remote shell rsh. Includes are listed before excludes,
+// so an include wins over an exclude matching the same path.
+func rsyncArgs(opts syncOptions, rsh, source, dest string) []string {
+	// -s keeps the remote shell from splitting paths with spaces
+	args := []string{"-az", "-s", "--itemize-changes", "--stats", "-e", rsh}
+	if opts.DryRun {
+		args = append(args, "--dry-run")
+	}
+	if opts.Delete {
+		args = append(args, "--delete")
+	}
+	for _, pattern := range opts.Include {
+		args = append(args, "--include="+pattern)
+	}
+	for _, pattern := range opts.Exclude {
+		args = append(args, "--exclude="+pattern)
+	}
+	return append(args, "--", withTrailingSlash(source), withTrailingSlash(dest))
+}
+
+// withTrailingSlash makes rsync copy the contents of a directory rather than
+// the directory itself
+func withTrailingSlash(path string) string {
+	if strings.HasSuffix(path, "/") {
+		return path
+	}
+	return path + "/"
+}
+
+// rsyncLocalPath returns a local path as rsync expects it. Windows builds of
+// rsync are cygwin programs that would take the drive letter of C:\src for a
+// host name, so drives are written as /cygdrive/c/src.
+func rsyncLocalPath(goos, path string) string {
+	if goos != "windows" {
+		return path
+	}
+	path = strings.ReplaceAll(path, `\`, "/")
+	if hasDriveLetter(path) {
+		path = "/cygdrive/" + strings.ToLower(path[:1]) + path[2:]
+	}
+	return path
+}
+
+// rsyncShell returns the remote shell rsync reaches a workspace's host alias
+// through. The alias works as is once devpod wrote it to the SSH config;
+// before that the proxy command devpod would write is passed to ssh.
+func (s *Server) rsyncShell(name, devpodContext string) (string, error) {
+	path, err := s.sshConfigPath()
+	if err != nil {
+		return "", err
+	}
+	rsh := "ssh"
+	if s.opts.SSHConfigPath != "" {
+		rsh += " -F " + shellQuote(path)
+	}
+	if config, err := os.ReadFile(path); err == nil && findSSHConfigEntry(string(config), name+sshHostSuffix) != nil {
+		return rsh, nil
+	}
+
+	entry := generatedSSHConfigEntry(name, devpodContext)
+	rsh += " -o " + shellQuote("ProxyCommand="+entry.ProxyCommand)
+	for _, option := range devpodSSHOptions {
+		rsh += " -o " + shellQuote(option[0]+"="+option[1])
+	}
+	return rsh, nil
+}
+
+// runRsync runs rsync, copying its output to the output writer of ctx
+func runRsync(ctx context.Context, args []string) ([]byte, []byte, error) {
+	path, err := exec.LookPath("rsync")
+	if err != nil {
+		return nil, nil, fmt.Errorf("rsync is not installed on the machine running the server: %w", err)
+	}
+	cmd := exec.CommandContext(ctx, path, args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if w := OutputWriter(ctx); w != nil {
+		cmd.Stdout = io.MultiWriter(&stdout, w)
+		cmd.Stderr = io.MultiWriter(&stderr, w)
+	}
+	err = cmd.Run()
+	return stdout.Bytes(), stderr.Bytes(), err
+}
+
+// parseRsyncOutput interprets the --itemize-changes and --stats output of
+// rsync. Itemized lines start with an 11 character change summary such as
+// ">f+++++++++" for a new file or "*deleting  " for a deletion.
+func parseRsyncOutput(output string) syncReport {
+	report := syncReport{Changes: []syncChange{}}
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if path, ok := strings.CutPrefix(line, "*deleting "); ok {
+			path = strings.TrimSpace(path)
+			report.Changes = append(report.Changes, syncChange{Path: strings.TrimSuffix(path, "/"), Action: "deleted", Dir: strings.HasSuffix(path, "/")})
+			report.Deleted++
+			continue
+		}
+		if len(line) < 13 || line[11] != ' ' || !strings.ContainsRune("<>ch.", rune(line[0])) {
+			if key, value, ok := strings.Cut(line, ": "); ok && strings.TrimSpace(value) != "" {
+				value = strings.ReplaceAll(strings.Fields(value)[0], ",", "")
+				switch key {
+				case "Number of regular files transferred":
+					report.FilesTransferred, _ = strconv.Atoi(value)
+				case "Total transferred file size":
+					report.BytesTransferred, _ = strconv.ParseInt(value, 10, 64)
+				}
+			}
+			continue
+		}
+		flags, path := line[:11], line[12:]
+		// "." with unchanged attributes is rsync listing a file it skipped
+		if flags[0] == '.' && strings.Trim(flags[2:], ". ") == "" {
+			continue
+		}
+		action := "updated"
+		if strings.HasPrefix(flags[2:], "+++++++++") {
+			action = "created"
+		}
+		dir := flags[1] == 'd'
+		if dir && action == "updated" {
+			// only the timestamps of existing directories change
+			continue
+		}
+		report.Changes = append(report.Changes, syncChange{Path: strings.TrimSuffix(path, "/"), Action: action, Dir: dir})
+	}
+	return report
+}
+
+// registerSyncTools registers the tool that syncs a local directory with a
+// workspace
+func (s *Server) registerSyncTools() {
+	// Sync a local directory with a workspace directory
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_syncDirectory",
+		Description: "Synchronize a local directory into or out of a directory of a running DevPod workspace with rsync over its SSH host alias, e.g. to move build artifacts or datasets",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "The name of the workspace",
+				},
+				"localPath": map[string]interface{}{
+					"type":        "string",
+					"description": "Existing local directory, within the workspace root when one is set",
+				},
+				"remotePath": map[string]interface{}{
+					"type":        "string",
+					"description": "Directory inside the workspace; relative paths start at the remote user's home",
+				},
+				"direction": map[string]interface{}{
+					"type":        "string",
+					"enum":        []string{syncPush, syncPull},
+					"description": "push copies the local directory into the workspace, pull copies the workspace directory here (default: push)",
+				},
+				"include": map[string]interface{}{
+					"type":        "array",
+					"items":       map[string]interface{}{"type": "string"},
+					"description": "rsync patterns to include even when an exclude matches them (optional)",
+				},
+				"exclude": map[string]interface{}{
+					"type":        "array",
+					"items":       map[string]interface{}{"type": "string"},
+					"description": "rsync patterns to leave out, e.g. node_modules/ or *.log (optional)",
+				},
+				"delete": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Delete files in the destination that the source does not have (default: false)",
+				},
+				"dryRun": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Only report what would change (default: false)",
+				},
+			},
+			"required": []string{"name", "localPath", "remotePath"},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var syncParams struct {
+			Name       string   `json:"name"`
+			LocalPath  string   `json:"localPath"`
+			RemotePath string   `json:"remotePath"`
+			Direction  string   `json:"direction"`
+			Include    []string `json:"include"`
+			Exclude    []string `json:"exclude"`
+			Delete     bool     `json:"delete"`
+			DryRun     bool     `json:"dryRun"`
+		}
+
+		if err := json.Unmarshal(params, &syncParams); err != nil {
+			return nil, mcp.NewInvalidParamsError("Invalid sync directory parameters")
+		}
+
+		if syncParams.Name == "" {
+			return nil, mcp.NewInvalidParamsError("Workspace name is required")
+		}
+		if syncParams.LocalPath == "" || syncParams.RemotePath == "" {
+			return nil, mcp.NewInvalidParamsError("localPath and remotePath are required")
+		}
+		if syncParams.Direction == "" {
+			syncParams.Direction = syncPush
+		}
+		if syncParams.Direction != syncPush && syncParams.Direction != syncPull {
+			return nil, mcp.NewInvalidParamsError(fmt.Sprintf("Invalid direction %q: must be push or pull", syncParams.Direction))
+		}
+
+		localPath, err := s.resolveLocalSource(ctx, syncParams.LocalPath)
+		if err != nil {
+			return nil, mcp.NewInvalidParamsError(err.Error())
+		}
+		workspace, err := s.findWorkspace(ctx, syncParams.Name)
+		if err != nil {
+			return nil, newDevPodError("failed to look up workspace", err, nil)
+		}
+		if workspace == nil {
+			return nil, mcp.NewInvalidParamsError(fmt.Sprintf("Workspace %s not found", syncParams.Name))
+		}
+		if err := s.requireRunning(ctx, syncParams.Name); err != nil {
+			return nil, err
+		}
+
+		rsh, err := s.rsyncShell(syncParams.Name, workspace.Context)
+		if err != nil {
+			return nil, err
+		}
+		source, dest := rsyncLocalPath(runtime.GOOS, localPath), syncParams.Name+sshHostSuffix+":"+syncParams.RemotePath
+		if syncParams.Direction == syncPull {
+			source, dest = dest, source
+		}
+		args := rsyncArgs(syncOptions{
+			Include: syncParams.Include,
+			Exclude: syncParams.Exclude,
+			Delete:  syncParams.Delete,
+			DryRun:  syncParams.DryRun,
+		}, rsh, source, dest)
+
+		output, stderr, err := runRsync(ctx, args)
+		if err != nil {
+			if output == nil && stderr == nil {
+				return nil, err
+			}
+			return nil, newDevPodError("failed to sync directory", err, stderr)
+		}
+
+		report := parseRsyncOutput(string(output))
+		message := fmt.Sprintf("Synced %d files (%d bytes) from %s to %s", report.FilesTransferred, report.BytesTransferred, source, dest)
+		if syncParams.DryRun {
+			message = fmt.Sprintf("Dry run: %d files (%d bytes) would be copied from %s to %s and %d deleted", report.FilesTransferred, report.BytesTransferred, source, dest, report.Deleted)
+		}
+		return map[string]interface{}{
+			"name":             syncParams.Name,
+			"direction":        syncParams.Direction,
+			"source":           source,
+			"destination":      dest,
+			"dryRun":           syncParams.DryRun,
+			"changes":          report.Changes,
+			"filesTransferred": report.FilesTransferred,
+			"bytesTransferred": report.BytesTransferred,
+			"deleted":          report.Deleted,
+			"message":          message,
+		}, nil
+	})
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRsyncArgs(t *testing.T) {
+	args := rsyncArgs(syncOptions{Include: []string{"keep.log"}, Exclude: []string{"*.log"}, Delete: true, DryRun: true}, "ssh", "/src", "api.devpod:data")
+	got := strings.Join(args, " ")
+	want := "-az -s --itemize-changes --stats -e ssh --dry-run --delete --include=keep.log --exclude=*.log -- /src/ api.devpod:data/"
+	if got != want {
+		t.Errorf("rsyncArgs = %s; want %s", got, want)
+	}
+}
+
+func TestRsyncLocalPath(t *testing.T) {
+	tests := []struct{ goos, path, want string }{
+		{"linux", "/home/me/src", "/home/me/src"},
+		{"windows", `C:\Users\me\src`, "/cygdrive/c/Users/me/src"},
+		{"windows", `d:/work`, "/cygdrive/d/work"},
+		{"windows", `\\server\share\src`, "//server/share/src"},
+	}
+	for _, tt := range tests {
+		if got := rsyncLocalPath(tt.goos, tt.path); got != tt.want {
+			t.Errorf("rsyncLocalPath(%s, %q) = %s; want %s", tt.goos, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestParseRsyncOutput(t *testing.T) {
+	output := `cd+++++++++ models/
+>f+++++++++ models/weights.bin
+>f.st...... train.py
+.d..t...... ./
+*deleting   old.csv
+.f          unchanged.txt
+
+Number of files: 5 (reg: 3, dir: 2)
+Number of regular files transferred: 2
+Total transferred file size: 1,048,576 bytes
+`
+	report := parseRsyncOutput(output)
+	if fmt.Sprint(report.Changes) != "[{models created true} {models/weights.bin created false} {train.py updated false} {old.csv deleted false}]" {
+		t.Errorf("Unexpected changes %v", report.Changes)
+	}
+	if report.FilesTransferred != 2 || report.BytesTransferred != 1048576 || report.Deleted != 1 {
+		t.Errorf("Unexpected statistics %+v", report)
+	}
+}
+
+func TestRsyncShell(t *testing.T) {
+	s := newTestServer(t, &fakeRunner{})
+	s.opts.SSHConfigPath = filepath.Join(t.TempDir(), "config")
+
+	rsh, err := s.rsyncShell("api", "work")
+	if err != nil {
+		t.Fatalf("rsyncShell failed: %v", err)
+	}
+	if !strings.Contains(rsh, "'ProxyCommand=devpod ssh --stdio --context work api'") || !strings.HasPrefix(rsh, "ssh -F ") {
+		t.Errorf("Expected the generated proxy command, got %s", rsh)
+	}
+}
+
+func TestSyncDirectoryValidation(t *testing.T) {
+	s := newTestServer(t, &fakeRunner{outputs: map[string]string{
+		"list --output json":       `[{"id":"api"}]`,
+		"status api --output json": `{"state":"Stopped"}`,
+	}})
+	s.opts.WorkspaceRoot = t.TempDir()
+	sync := s.MCP().GetHandler("devpod_syncDirectory")
+
+	for _, params := range []string{
+		`{"name":"api","localPath":".","remotePath":"data","direction":"sideways"}`,
+		`{"name":"api","localPath":"/","remotePath":"data"}`,
+		`{"name":"missing","localPath":".","remotePath":"data"}`,
+		`{"name":"api","localPath":".","remotePath":"data"}`,
+	} {
+		if _, err := sync(context.Background(), json.RawMessage(params)); err == nil {
+			t.Errorf("Expected %s to be rejected", params)
+		}
+	}
+}
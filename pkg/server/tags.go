@@ -0,0 +1,145 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+// maxNoteLength bounds the note kept per workspace
+const maxNoteLength = 2000
+
+// validTag matches tags: a letter or digit followed by letters, digits and
+// . _ : / -, e.g. ticket-1234 or team/payments
+var validTag = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._:/-]{0,63}$`)
+
+// checkTags rejects tags that validTag does not match
+func checkTags(tags []string) error {
+	for _, tag := range tags {
+		if !validTag.MatchString(tag) {
+			return fmt.Errorf("invalid tag %q: use up to 64 letters, digits and . _ : / -, starting with a letter or digit", tag)
+		}
+	}
+	return nil
+}
+
+// updateTags adds and removes tags and returns the sorted, unique result
+func updateTags(tags, add, remove []string) []string {
+	set := make(map[string]bool, len(tags)+len(add))
+	for _, tag := range append(append([]string{}, tags...), add...) {
+		set[tag] = true
+	}
+	for _, tag := range remove {
+		delete(set, tag)
+	}
+	updated := make([]string, 0, len(set))
+	for tag := range set {
+		updated = append(updated, tag)
+	}
+	sort.Strings(updated)
+	return updated
+}
+
+// hasTag reports whether a tag is in a list
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// attachMetadata fills in the tags and notes the user of ctx recorded for
+// workspaces
+func (s *Server) attachMetadata(ctx context.Context, workspaces []DevPodWorkspace) {
+	store := s.state(ctx)
+	for i := range workspaces {
+		metadata := store.Metadata(workspaces[i].ID)
+		workspaces[i].Tags = metadata.Tags
+		workspaces[i].Note = metadata.Note
+		workspaces[i].CreatedBy = store.Creator(workspaces[i].ID)
+	}
+}
+
+// registerTagTools registers the tool that tags workspaces
+func (s *Server) registerTagTools() {
+	// Tag workspace
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_tagWorkspace",
+		Description: "Add or remove tags and set a note on a workspace, e.g. a ticket number, to organize, search and clean up workspaces",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "The name of the workspace",
+				},
+				"add": map[string]interface{}{
+					"type":        "array",
+					"items":       map[string]interface{}{"type": "string"},
+					"description": "Tags to add, e.g. ticket-1234 or experimental",
+				},
+				"remove": map[string]interface{}{
+					"type":        "array",
+					"items":       map[string]interface{}{"type": "string"},
+					"description": "Tags to remove",
+				},
+				"note": map[string]interface{}{
+					"type":        "string",
+					"description": "Free-form note replacing the current one; an empty string clears it (optional)",
+				},
+			},
+			"required": []string{"name"},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var tagParams struct {
+			Name   string   `json:"name"`
+			Add    []string `json:"add,omitempty"`
+			Remove []string `json:"remove,omitempty"`
+			Note   *string  `json:"note,omitempty"`
+		}
+
+		if err := json.Unmarshal(params, &tagParams); err != nil {
+			return nil, mcp.NewInvalidParamsError("Invalid tag workspace parameters")
+		}
+
+		if tagParams.Name == "" {
+			return nil, mcp.NewInvalidParamsError("Workspace name is required")
+		}
+		if err := checkTags(tagParams.Add); err != nil {
+			return nil, mcp.NewInvalidParamsError(err.Error())
+		}
+		if tagParams.Note != nil && len(*tagParams.Note) > maxNoteLength {
+			return nil, mcp.NewInvalidParamsError(fmt.Sprintf("note must be at most %d characters", maxNoteLength))
+		}
+
+		exists, err := s.workspaceExists(ctx, tagParams.Name)
+		if err != nil {
+			return nil, newDevPodError("failed to list workspaces", err, nil)
+		}
+		if !exists {
+			return nil, mcp.NewInvalidParamsError(fmt.Sprintf("Workspace %s not found", tagParams.Name))
+		}
+
+		metadata := s.state(ctx).Metadata(tagParams.Name)
+		metadata.Tags = updateTags(metadata.Tags, tagParams.Add, tagParams.Remove)
+		if tagParams.Note != nil {
+			metadata.Note = *tagParams.Note
+		}
+		metadata.Updated = time.Now().UTC()
+		s.state(ctx).SetMetadata(tagParams.Name, metadata)
+
+		return map[string]interface{}{
+			"name":    tagParams.Name,
+			"tags":    metadata.Tags,
+			"note":    metadata.Note,
+			"message": "Workspace tags updated",
+		}, nil
+	})
+}
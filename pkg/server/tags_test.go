@@ -0,0 +1,72 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/protobomb/mcp-server-framework/pkg/transport"
+)
+
+func TestUpdateTags(t *testing.T) {
+	got := updateTags([]string{"b", "a"}, []string{"c", "a"}, []string{"b", "x"})
+	if want := []string{"a", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("updateTags = %v, want %v", got, want)
+	}
+	if err := checkTags([]string{"ticket-1234", "team/payments", "v1.2"}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	for _, tag := range []string{"", "-x", "has space", "a,b"} {
+		if err := checkTags([]string{tag}); err == nil {
+			t.Errorf("Expected %q to be rejected", tag)
+		}
+	}
+}
+
+func TestTagWorkspace(t *testing.T) {
+	runner := &fakeRunner{outputs: map[string]string{
+		"list --output json": `[{"id":"api","provider":{"name":"docker"}},{"id":"web","provider":{"name":"docker"}}]`,
+		"delete api":         "",
+	}}
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	s := New(transport.NewSTDIOTransport(), Options{Runner: runner, StatePath: statePath})
+	ctx := context.Background()
+
+	tag := s.MCP().GetHandler("devpod_tagWorkspace")
+	result, err := tag(ctx, json.RawMessage(`{"name":"api","add":["ticket-1234","experimental"],"note":"Payment retries spike"}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if tags := result.(map[string]interface{})["tags"]; !reflect.DeepEqual(tags, []string{"experimental", "ticket-1234"}) {
+		t.Errorf("Unexpected tags: %v", tags)
+	}
+	if _, err := tag(ctx, json.RawMessage(`{"name":"api","remove":["experimental"]}`)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := tag(ctx, json.RawMessage(`{"name":"missing","add":["x"]}`)); err == nil {
+		t.Error("Expected tagging an unknown workspace to fail")
+	}
+	if _, err := tag(ctx, json.RawMessage(`{"name":"web","add":["bad tag"]}`)); err == nil {
+		t.Error("Expected an invalid tag to be rejected")
+	}
+
+	// Tags survive a restart and filter the workspace list
+	s = New(transport.NewSTDIOTransport(), Options{Runner: runner, StatePath: statePath})
+	result, err = s.MCP().GetHandler("devpod_listWorkspaces")(ctx, json.RawMessage(`{"tag":"ticket-1234"}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	workspaces := result.(map[string]interface{})["workspaces"].([]DevPodWorkspace)
+	if len(workspaces) != 1 || workspaces[0].ID != "api" || workspaces[0].Note != "Payment retries spike" || !reflect.DeepEqual(workspaces[0].Tags, []string{"ticket-1234"}) {
+		t.Errorf("Expected the tagged workspace, got %+v", workspaces)
+	}
+
+	if _, err := s.MCP().GetHandler("devpod_deleteWorkspace")(ctx, json.RawMessage(`{"name":"api"}`)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if metadata := s.store.Metadata("api"); !metadata.empty() {
+		t.Errorf("Expected tags to be dropped with the workspace, got %+v", metadata)
+	}
+}
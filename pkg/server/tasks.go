@@ -0,0 +1,421 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+// taskFiles are the files tasks are read from, relative to the workspace
+// folder. The first devcontainer.json found applies.
+var taskFiles = []string{".devcontainer/devcontainer.json", ".devcontainer.json", ".vscode/tasks.json"}
+
+// fileMarker precedes each file in the output of filesCommand
+const fileMarker = "---devpod-file---"
+
+// exitMarker precedes the exit code of a command in the output of
+// exitCodeCommand
+const exitMarker = "---devpod-exit---"
+
+// lifecycleCommands are the devcontainer.json commands run inside the
+// container, in the order devcontainer tooling runs them. initializeCommand
+// runs on the host and is left out.
+var lifecycleCommands = []string{"onCreateCommand", "updateContentCommand", "postCreateCommand", "postStartCommand", "postAttachCommand"}
+
+// workspaceTask is a command of the project that devpod_execTask can run
+type workspaceTask struct {
+	Name string `json:"name"`
+	// Source is lifecycle for devcontainer.json lifecycle commands, custom
+	// for customizations.devpod.tasks and vscode for .vscode/tasks.json
+	Source  string `json:"source"`
+	File    string `json:"file"`
+	Command string `json:"command"`
+	// Workdir is relative to the workspace folder unless absolute
+	Workdir string `json:"workdir,omitempty"`
+}
+
+// filesCommand prints every file of files that exists, each after a marker
+// line naming it, and only the marker line for each path of present that
+// exists
+func filesCommand(files, present []string) string {
+	command := fmt.Sprintf(`for f in %s; do if [ -f "$f" ]; then printf '\n%s %%s\n' "$f"; cat "$f"; fi; done`, shellWords(files), fileMarker)
+	if len(present) > 0 {
+		command += fmt.Sprintf(`; for f in %s; do if [ -e "$f" ]; then printf '\n%s %%s\n' "$f"; fi; done`, shellWords(present), fileMarker)
+	}
+	return command
+}
+
+// shellWords quotes values as POSIX shell words separated by spaces
+func shellWords(values []string) string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = shellQuote(value)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// splitFiles separates the output of filesCommand into the files' contents
+// by path
+func splitFiles(output string) map[string]string {
+	files := make(map[string]string)
+	for _, part := range strings.Split(output, "\n"+fileMarker+" ")[1:] {
+		path, content, _ := strings.Cut(part, "\n")
+		files[strings.TrimSpace(path)] = content
+	}
+	return files
+}
+
+// parseTasks returns the tasks defined by the task files, lifecycle
+// commands first. Names already taken are skipped.
+func parseTasks(files map[string]string) ([]workspaceTask, error) {
+	var tasks []workspaceTask
+	seen := make(map[string]bool)
+	add := func(task workspaceTask) {
+		if task.Command == "" || seen[task.Name] {
+			return
+		}
+		seen[task.Name] = true
+		tasks = append(tasks, task)
+	}
+
+	for _, file := range taskFiles[:2] {
+		content, ok := files[file]
+		if !ok {
+			continue
+		}
+		var config map[string]json.RawMessage
+		if err := json.Unmarshal(stripJSONC([]byte(content)), &config); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+		}
+		for _, name := range lifecycleCommands {
+			raw, ok := config[name]
+			if !ok {
+				continue
+			}
+			command, parts, err := lifecycleCommand(raw)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s in %s: %w", name, file, err)
+			}
+			add(workspaceTask{Name: name, Source: "lifecycle", File: file, Command: command})
+			for _, part := range parts {
+				add(workspaceTask{Name: name + ":" + part.Name, Source: "lifecycle", File: file, Command: part.Command})
+			}
+		}
+
+		var customizations struct {
+			DevPod struct {
+				Tasks map[string]json.RawMessage `json:"tasks"`
+			} `json:"devpod"`
+		}
+		if raw, ok := config["customizations"]; ok {
+			if err := json.Unmarshal(raw, &customizations); err != nil {
+				return nil, fmt.Errorf("invalid customizations in %s: %w", file, err)
+			}
+		}
+		names := make([]string, 0, len(customizations.DevPod.Tasks))
+		for name := range customizations.DevPod.Tasks {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			command, err := commandString(customizations.DevPod.Tasks[name])
+			if err != nil {
+				return nil, fmt.Errorf("invalid task %s in %s: %w", name, file, err)
+			}
+			add(workspaceTask{Name: name, Source: "custom", File: file, Command: command})
+		}
+		break
+	}
+
+	if content, ok := files[".vscode/tasks.json"]; ok {
+		var config struct {
+			Tasks []struct {
+				Label   string          `json:"label"`
+				Type    string          `json:"type"`
+				Command json.RawMessage `json:"command"`
+				Args    []string        `json:"args"`
+				Options struct {
+					Cwd string `json:"cwd"`
+				} `json:"options"`
+			} `json:"tasks"`
+		}
+		if err := json.Unmarshal(stripJSONC([]byte(content)), &config); err != nil {
+			return nil, fmt.Errorf("failed to parse .vscode/tasks.json: %w", err)
+		}
+		for _, task := range config.Tasks {
+			// Only shell and process tasks run a command of their own
+			if task.Label == "" || (task.Type != "" && task.Type != "shell" && task.Type != "process") {
+				continue
+			}
+			var command string
+			if err := json.Unmarshal(task.Command, &command); err != nil {
+				continue
+			}
+			words := []string{vscodeVariables(command)}
+			for _, arg := range task.Args {
+				words = append(words, shellQuote(vscodeVariables(arg)))
+			}
+			add(workspaceTask{
+				Name:    task.Label,
+				Source:  "vscode",
+				File:    ".vscode/tasks.json",
+				Command: strings.Join(words, " "),
+				Workdir: vscodeVariables(task.Options.Cwd),
+			})
+		}
+	}
+	return tasks, nil
+}
+
+// lifecycleCommand returns the shell command of a lifecycle command in any
+// of its forms: a string, an array of arguments or an object of named
+// commands, which are returned as parts as well and run one after another
+func lifecycleCommand(raw json.RawMessage) (string, []workspaceTask, error) {
+	var named map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &named); err != nil {
+		command, err := commandString(raw)
+		return command, nil, err
+	}
+	names := make([]string, 0, len(named))
+	for name := range named {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var parts []workspaceTask
+	var commands []string
+	for _, name := range names {
+		command, err := commandString(named[name])
+		if err != nil {
+			return "", nil, err
+		}
+		parts = append(parts, workspaceTask{Name: name, Command: command})
+		commands = append(commands, "("+command+")")
+	}
+	return strings.Join(commands, " && "), parts, nil
+}
+
+// commandString returns a command given as a shell string or as an array of
+// arguments run without a shell
+func commandString(raw json.RawMessage) (string, error) {
+	var command string
+	if err := json.Unmarshal(raw, &command); err == nil {
+		return command, nil
+	}
+	var args []string
+	if err := json.Unmarshal(raw, &args); err != nil || len(args) == 0 {
+		return "", fmt.Errorf("a command must be a string or a non-empty array of strings")
+	}
+	for i, arg := range args {
+		args[i] = shellQuote(arg)
+	}
+	return strings.Join(args, " "), nil
+}
+
+// vscodeVariable matches the workspace folder variables of VS Code tasks
+var vscodeVariable = regexp.MustCompile(`\$\{workspaceFolder\}/?`)
+
+// vscodeVariables resolves the workspace folder in a VS Code task value;
+// tasks run in the workspace folder already
+func vscodeVariables(value string) string {
+	value = vscodeVariable.ReplaceAllString(value, "./")
+	if value == "./" {
+		return "."
+	}
+	return value
+}
+
+// taskCommand runs a task in its working directory
+func taskCommand(task workspaceTask) string {
+	return exitCodeCommand(inDir(task.Workdir, task.Command), 0)
+}
+
+// inDir prefixes command with a change to dir, relative to the workspace
+// folder unless absolute
+func inDir(dir, command string) string {
+	if dir == "" || dir == "." {
+		return command
+	}
+	return "cd " + shellQuote(dir) + " && " + command
+}
+
+// exitCodeCommand runs a shell command with its output on stdout and prints
+// its exit code after exitMarker, so a failing command is told apart from a
+// failing connection. With a timeout the command is stopped after it, with
+// exit code 124, when the workspace has the timeout command.
+func exitCodeCommand(command string, timeout time.Duration) string {
+	run := "sh -c " + shellQuote(command)
+	if timeout > 0 {
+		seconds := int64((timeout + time.Second - 1) / time.Second)
+		run = fmt.Sprintf(`if command -v timeout >/dev/null 2>&1; then timeout %d %s; else %s; fi`, seconds, run, run)
+	}
+	return fmt.Sprintf(`%s 2>&1; printf '\n%s %%s\n' "$?"`, run, exitMarker)
+}
+
+// splitExitCode separates a command's output from the exit code
+// exitCodeCommand printed, reporting whether it was printed
+func splitExitCode(output string) (string, int, bool) {
+	i := strings.LastIndex(output, "\n"+exitMarker+" ")
+	if i < 0 {
+		return output, -1, false
+	}
+	code, err := strconv.Atoi(strings.TrimSpace(output[i+len(exitMarker)+2:]))
+	if err != nil {
+		return output, -1, false
+	}
+	return output[:i], code, true
+}
+
+// stripJSONC removes the comments and trailing commas devcontainer.json and
+// tasks.json may contain, leaving plain JSON
+func stripJSONC(data []byte) []byte {
+	var out []byte
+	inString := false
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case inString:
+			out = append(out, c)
+			if c == '\\' && i+1 < len(data) {
+				i++
+				out = append(out, data[i])
+			} else if c == '"' {
+				inString = false
+			}
+		case c == '"':
+			inString = true
+			out = append(out, c)
+		case c == '/' && i+1 < len(data) && data[i+1] == '/':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+			i--
+		case c == '/' && i+1 < len(data) && data[i+1] == '*':
+			end := strings.Index(string(data[i+2:]), "*/")
+			if end < 0 {
+				return out
+			}
+			i += end + 3
+		case c == '}' || c == ']':
+			// Drop a comma before the closing bracket
+			j := len(out) - 1
+			for j >= 0 && strings.ContainsRune(" \t\r\n", rune(out[j])) {
+				j--
+			}
+			if j >= 0 && out[j] == ',' {
+				out = append(out[:j], out[j+1:]...)
+			}
+			out = append(out, c)
+		default:
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// registerTaskTools registers the tool that runs tasks of the project
+func (s *Server) registerTaskTools() {
+	// Run a task of the project in a workspace
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_execTask",
+		Description: "Run a named task of the project inside a running workspace, e.g. its postCreateCommand, a task under customizations.devpod.tasks in devcontainer.json or a shell task of .vscode/tasks.json, and return its exit code and output. Without a task the available tasks are listed.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "The name of the workspace",
+				},
+				"task": map[string]interface{}{
+					"type":        "string",
+					"description": "The task to run, e.g. postCreateCommand or build; a named part of an object lifecycle command is e.g. postCreateCommand:install (optional, lists the tasks when omitted)",
+				},
+			},
+			"required": []string{"name"},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var taskParams struct {
+			Name string `json:"name"`
+			Task string `json:"task,omitempty"`
+		}
+
+		if err := json.Unmarshal(params, &taskParams); err != nil {
+			return nil, mcp.NewInvalidParamsError("Invalid exec task parameters")
+		}
+		if taskParams.Name == "" {
+			return nil, mcp.NewInvalidParamsError("Workspace name is required")
+		}
+		if err := s.requireRunning(ctx, taskParams.Name); err != nil {
+			return nil, err
+		}
+
+		// The task files are data for the server, not console output
+		output, err := s.combinedOutput(WithOutputWriter(ctx, nil), []string{"ssh", taskParams.Name, "--command", filesCommand(taskFiles, nil)})
+		if err != nil {
+			return nil, newDevPodError("failed to read workspace tasks", err, output)
+		}
+		tasks, err := parseTasks(splitFiles(string(output)))
+		if err != nil {
+			return nil, mcp.NewInvalidParamsError(err.Error())
+		}
+
+		if taskParams.Task == "" {
+			if tasks == nil {
+				tasks = []workspaceTask{}
+			}
+			return map[string]interface{}{
+				"name":    taskParams.Name,
+				"tasks":   tasks,
+				"message": fmt.Sprintf("Found %d task(s) in %s", len(tasks), taskParams.Name),
+			}, nil
+		}
+
+		var task *workspaceTask
+		names := make([]string, 0, len(tasks))
+		for i := range tasks {
+			if tasks[i].Name == taskParams.Task {
+				task = &tasks[i]
+			}
+			names = append(names, tasks[i].Name)
+		}
+		if task == nil {
+			if len(names) == 0 {
+				return nil, mcp.NewInvalidParamsError(fmt.Sprintf("Unknown task: %s (the workspace defines no tasks)", taskParams.Task))
+			}
+			return nil, mcp.NewInvalidParamsError(fmt.Sprintf("Unknown task: %s (available: %s)", taskParams.Task, strings.Join(names, ", ")))
+		}
+
+		start := time.Now()
+		output, err = s.combinedOutput(ctx, []string{"ssh", taskParams.Name, "--command", taskCommand(*task)})
+		taskOutput, exitCode, ran := splitExitCode(string(output))
+		if !ran {
+			if err == nil {
+				err = fmt.Errorf("the task's exit code was not reported")
+			}
+			s.state(ctx).RecordEvent(taskParams.Name, "error", fmt.Sprintf("task %s failed: %v", task.Name, err))
+			return nil, newDevPodError("failed to run task", err, output)
+		}
+		s.state(ctx).RecordEvent(taskParams.Name, "command", fmt.Sprintf("Ran task %s (exit code %d)", task.Name, exitCode))
+		s.touchWorkspace(ctx, taskParams.Name)
+
+		message := fmt.Sprintf("Task %s succeeded", task.Name)
+		if exitCode != 0 {
+			message = fmt.Sprintf("Task %s failed with exit code %d", task.Name, exitCode)
+		}
+		return map[string]interface{}{
+			"name":       taskParams.Name,
+			"task":       task,
+			"exitCode":   exitCode,
+			"success":    exitCode == 0,
+			"output":     strings.TrimRight(taskOutput, "\n"),
+			"durationMs": durationMs(start),
+			"message":    message,
+		}, nil
+	})
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// shellRunner runs the commands of devpod ssh with sh in dir, standing in
+// for the workspace folder
+type shellRunner struct {
+	fakeRunner
+	dir string
+}
+
+func (r *shellRunner) Run(ctx context.Context, args []string) ([]byte, []byte, error) {
+	if len(args) != 4 || args[0] != "ssh" || args[2] != "--command" {
+		return r.fakeRunner.Run(ctx, args)
+	}
+	cmd := exec.CommandContext(ctx, "sh", "-c", args[3])
+	cmd.Dir = r.dir
+	if data := CommandInput(ctx); data != nil {
+		cmd.Stdin = bytes.NewReader(data)
+	}
+	output, err := cmd.Output()
+	return output, nil, err
+}
+
+func TestParseTasks(t *testing.T) {
+	tasks, err := parseTasks(map[string]string{
+		".devcontainer/devcontainer.json": `{
+			// JSONC, as devcontainer tooling accepts it
+			"image": "mcr.microsoft.com/devcontainers/go:1", /* trailing comma below */
+			"postCreateCommand": {"tools": ["go", "install", "./..."], "deps": "go mod download"},
+			"postStartCommand": "echo 'http://example.com'",
+			"customizations": {"devpod": {"tasks": {"lint": "golangci-lint run",}}},
+		}`,
+		".devcontainer.json": `{"postCreateCommand": "ignored"}`,
+		".vscode/tasks.json": `{"version": "2.0.0", "tasks": [
+			{"label": "test", "type": "shell", "command": "go test", "args": ["./..."], "options": {"cwd": "${workspaceFolder}/src"}},
+			{"label": "lint", "type": "shell", "command": "ignored"},
+			{"label": "npm", "type": "npm", "script": "build"},
+		]}`,
+	})
+	if err != nil {
+		t.Fatalf("parseTasks failed: %v", err)
+	}
+	want := []workspaceTask{
+		{Name: "postCreateCommand", Source: "lifecycle", File: ".devcontainer/devcontainer.json", Command: "(go mod download) && ('go' 'install' './...')"},
+		{Name: "postCreateCommand:deps", Source: "lifecycle", File: ".devcontainer/devcontainer.json", Command: "go mod download"},
+		{Name: "postCreateCommand:tools", Source: "lifecycle", File: ".devcontainer/devcontainer.json", Command: "'go' 'install' './...'"},
+		{Name: "postStartCommand", Source: "lifecycle", File: ".devcontainer/devcontainer.json", Command: "echo 'http://example.com'"},
+		{Name: "lint", Source: "custom", File: ".devcontainer/devcontainer.json", Command: "golangci-lint run"},
+		{Name: "test", Source: "vscode", File: ".vscode/tasks.json", Command: "go test './...'", Workdir: "./src"},
+	}
+	if !reflect.DeepEqual(tasks, want) {
+		t.Errorf("Unexpected tasks:\n%+v\nwant:\n%+v", tasks, want)
+	}
+
+	if _, err := parseTasks(map[string]string{".devcontainer.json": `{"postCreateCommand": 42}`}); err == nil {
+		t.Error("Expected an invalid command to be rejected")
+	}
+}
+
+func TestExecTask(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, ".devcontainer"), 0o755)
+	os.MkdirAll(filepath.Join(dir, "src"), 0o755)
+	os.WriteFile(filepath.Join(dir, ".devcontainer", "devcontainer.json"), []byte(`{
+		"postCreateCommand": "echo \"it's set up\"",
+		"customizations": {"devpod": {"tasks": {"where": "pwd", "fail": "echo broken >&2; exit 3"}}}
+	}`), 0o644)
+	os.WriteFile(filepath.Join(dir, "src", "marker"), nil, 0o644)
+	os.MkdirAll(filepath.Join(dir, ".vscode"), 0o755)
+	os.WriteFile(filepath.Join(dir, ".vscode", "tasks.json"), []byte(`{"tasks": [{"label": "ls", "command": "ls", "options": {"cwd": "${workspaceFolder}/src"}}]}`), 0o644)
+
+	s := newTestServer(t, &shellRunner{dir: dir})
+	execTask := s.MCP().GetHandler("devpod_execTask")
+	run := func(args string) map[string]interface{} {
+		t.Helper()
+		result, err := execTask(context.Background(), json.RawMessage(args))
+		if err != nil {
+			t.Fatalf("execTask %s failed: %v", args, err)
+		}
+		return result.(map[string]interface{})
+	}
+
+	if tasks := run(`{"name":"api"}`)["tasks"].([]workspaceTask); len(tasks) != 4 {
+		t.Errorf("Expected 4 tasks, got %+v", tasks)
+	}
+
+	result := run(`{"name":"api","task":"postCreateCommand"}`)
+	if result["output"] != "it's set up" || result["exitCode"] != 0 || result["success"] != true {
+		t.Errorf("Unexpected result %v", result)
+	}
+	if result := run(`{"name":"api","task":"ls"}`); result["output"] != "marker" {
+		t.Errorf("Expected the task to run in its cwd, got %v", result)
+	}
+	result = run(`{"name":"api","task":"fail"}`)
+	if result["output"] != "broken" || result["exitCode"] != 3 || result["success"] != false {
+		t.Errorf("Expected the failure to be reported, got %v", result)
+	}
+
+	if _, err := execTask(context.Background(), json.RawMessage(`{"name":"api","task":"deploy"}`)); err == nil {
+		t.Error("Expected an unknown task to be rejected")
+	}
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+)
+
+// Template holds reusable defaults for devpod_createWorkspace
+type Template struct {
+	// Source is the repository or path used when the call omits one
+	Source string `json:"source,omitempty"`
+	// IDE is the IDE used when the call omits one
+	IDE string `json:"ide,omitempty"`
+	// Providers is an ordered preference list; creation fails over to the
+	// next provider when one is out of quota or unavailable
+	Providers []string `json:"providers,omitempty"`
+}
+
+// LoadTemplates reads a JSON object mapping template names to templates
+func LoadTemplates(path string) (map[string]Template, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var templates map[string]Template
+	if err := json.Unmarshal(data, &templates); err != nil {
+		return nil, fmt.Errorf("failed to parse templates file %s: %w", path, err)
+	}
+	return templates, nil
+}
+
+// failoverCategories are the failures that indicate a provider problem rather
+// than a problem with the workspace itself, so another provider may succeed
+var failoverCategories = map[string]bool{
+	CategoryQuotaExceeded:     true,
+	CategoryDockerUnavailable: true,
+	CategoryProviderNotFound:  true,
+	CategoryTimeout:           true,
+}
+
+// providerAttempt records a failed creation attempt on one provider
+type providerAttempt struct {
+	Provider string `json:"provider"`
+	Category string `json:"category"`
+	Error    string `json:"error"`
+}
+
+// upWithFailover runs `devpod up` with each provider in turn until one
+// succeeds or fails for a reason that is not provider-specific. providerArgs
+// holds extra arguments per provider. It returns the output of the last
+// attempt, the provider used and the earlier failed attempts.
+func (s *Server) upWithFailover(ctx context.Context, name string, args []string, providers []string, providerArgs map[string][]string) ([]byte, string, []providerAttempt, error) {
+	var attempts []providerAttempt
+	for i, provider := range providers {
+		attemptArgs := append(append([]string{}, args...), providerArgs[provider]...)
+		if provider != "" {
+			attemptArgs = append(attemptArgs, "--provider", provider)
+		}
+
+		output, err := s.combinedOutput(ctx, attemptArgs)
+		if err == nil {
+			return output, provider, attempts, nil
+		}
+
+		category, excerpt := categorizeFailure(err, output)
+		if i == len(providers)-1 || !failoverCategories[category] || ctx.Err() != nil {
+			return output, provider, attempts, err
+		}
+
+		attempts = append(attempts, providerAttempt{Provider: provider, Category: category, Error: excerpt})
+		log.Printf("WARNING: creating %s on provider %s failed (%s), trying %s", name, provider, category, providers[i+1])
+		s.state(ctx).RecordEvent(name, "failover", fmt.Sprintf("Provider %s failed (%s), trying %s", provider, category, providers[i+1]))
+
+		// Remove whatever the failed attempt left behind before retrying elsewhere
+		if _, err := s.combinedOutput(ctx, []string{"delete", name, "--force"}); err != nil {
+			log.Printf("WARNING: failed to clean up %s after failed attempt: %v", name, err)
+		}
+	}
+	return nil, "", attempts, fmt.Errorf("no providers to try")
+}
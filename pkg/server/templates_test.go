@@ -0,0 +1,93 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/protobomb/mcp-server-framework/pkg/transport"
+)
+
+func TestLoadTemplates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "templates.json")
+	if err := os.WriteFile(path, []byte(`{"go":{"source":"github.com/acme/go","providers":["aws","gcp"]}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	templates, err := LoadTemplates(path)
+	if err != nil {
+		t.Fatalf("LoadTemplates failed: %v", err)
+	}
+	if got := templates["go"]; got.Source != "github.com/acme/go" || len(got.Providers) != 2 {
+		t.Errorf("Unexpected template: %+v", got)
+	}
+}
+
+func TestCreateWorkspaceFailsOverToNextProvider(t *testing.T) {
+	runner := &fakeRunner{
+		outputs: map[string]string{
+			"list --output json":                           "[]",
+			"up github.com/acme/go --id ws --provider gcp": "done",
+		},
+		failures: map[string]string{
+			"up github.com/acme/go --id ws --provider aws": "Error: vCPU quota exceeded in us-east-1",
+		},
+	}
+	s := New(transport.NewSTDIOTransport(), Options{
+		Runner:    runner,
+		StatePath: filepath.Join(t.TempDir(), "state.json"),
+		Templates: map[string]Template{
+			"go": {Source: "github.com/acme/go", Providers: []string{"aws", "gcp"}},
+		},
+	})
+
+	result, err := s.MCP().GetHandler("devpod_createWorkspace")(context.Background(), json.RawMessage(`{"name":"ws","template":"go"}`))
+	if err != nil {
+		t.Fatalf("devpod_createWorkspace failed: %v", err)
+	}
+
+	fields := result.(map[string]interface{})
+	if fields["provider"] != "gcp" {
+		t.Errorf("Expected provider gcp, got %v", fields["provider"])
+	}
+	attempts, ok := fields["failedAttempts"].([]providerAttempt)
+	if !ok || len(attempts) != 1 || attempts[0].Provider != "aws" || attempts[0].Category != CategoryQuotaExceeded {
+		t.Errorf("Unexpected failed attempts: %v", fields["failedAttempts"])
+	}
+
+	var cleanedUp bool
+	for _, call := range runner.calls {
+		if strings.Join(call, " ") == "delete ws --force" {
+			cleanedUp = true
+		}
+	}
+	if !cleanedUp {
+		t.Error("Expected the failed attempt to be cleaned up before failover")
+	}
+}
+
+func TestCreateWorkspaceDoesNotFailOverOnWorkspaceErrors(t *testing.T) {
+	runner := &fakeRunner{
+		outputs: map[string]string{
+			"list --output json": "[]",
+		},
+		failures: map[string]string{
+			"up github.com/acme/go --id ws --provider aws": "Error: authentication failed for github.com/acme/go",
+		},
+	}
+	s := New(transport.NewSTDIOTransport(), Options{
+		Runner:    runner,
+		StatePath: filepath.Join(t.TempDir(), "state.json"),
+		Templates: map[string]Template{
+			"go": {Source: "github.com/acme/go", Providers: []string{"aws", "gcp"}},
+		},
+	})
+
+	_, err := s.MCP().GetHandler("devpod_createWorkspace")(context.Background(), json.RawMessage(`{"name":"ws","template":"go"}`))
+	if err == nil || !strings.Contains(err.Error(), CategoryAuthFailure) {
+		t.Errorf("Expected AuthFailure error, got %v", err)
+	}
+}
@@ -0,0 +1,449 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+// defaultTestTimeout bounds a devpod_runTests run unless the call sets a
+// timeout
+const defaultTestTimeout = 10 * time.Minute
+
+// maxFailures bounds the failed test names in a devpod_runTests result
+const maxFailures = 50
+
+// failureTailLines is how much of the end of the output of a failed run is
+// returned as failureOutput
+const failureTailLines = 60
+
+// testFiles are read to detect a project's test command, relative to the
+// directory the tests run in
+var testFiles = []string{"package.json", "pyproject.toml", "setup.cfg", "tox.ini", "Makefile"}
+
+// testMarkers only need to exist to detect a project's test command
+var testMarkers = []string{"go.mod", "Cargo.toml", "pom.xml", "build.gradle", "build.gradle.kts", "gradlew", "pnpm-lock.yaml", "yarn.lock", "pytest.ini", "conftest.py", "setup.py"}
+
+// testCommand is a detected test command
+type testCommand struct {
+	// Framework is go, npm, yarn, pnpm, cargo, pytest, maven, gradle or make
+	Framework string
+	Command   string
+}
+
+// makeTestTarget matches a test target in a Makefile
+var makeTestTarget = regexp.MustCompile(`(?m)^test:`)
+
+// detectTestCommand picks the test command of a project from the files of
+// testFiles and testMarkers it has
+func detectTestCommand(files map[string]string) (testCommand, bool) {
+	has := func(file string) bool {
+		_, ok := files[file]
+		return ok
+	}
+
+	if has("go.mod") {
+		return testCommand{"go", "go test -v ./..."}, true
+	}
+	if content, ok := files["package.json"]; ok {
+		var pkg struct {
+			Scripts map[string]string `json:"scripts"`
+		}
+		// npm init's placeholder only fails
+		if json.Unmarshal([]byte(content), &pkg) == nil && pkg.Scripts["test"] != "" && !strings.Contains(pkg.Scripts["test"], "no test specified") {
+			switch {
+			case has("pnpm-lock.yaml"):
+				return testCommand{"pnpm", "pnpm test"}, true
+			case has("yarn.lock"):
+				return testCommand{"yarn", "yarn test"}, true
+			}
+			return testCommand{"npm", "npm test"}, true
+		}
+	}
+	if has("Cargo.toml") {
+		return testCommand{"cargo", "cargo test"}, true
+	}
+	if has("pytest.ini") || has("conftest.py") || strings.Contains(files["pyproject.toml"], "[tool.pytest") ||
+		strings.Contains(files["setup.cfg"], "[tool:pytest]") || strings.Contains(files["tox.ini"], "[pytest]") {
+		return testCommand{"pytest", "python -m pytest"}, true
+	}
+	if has("pom.xml") {
+		return testCommand{"maven", "mvn -B test"}, true
+	}
+	if has("build.gradle") || has("build.gradle.kts") {
+		if has("gradlew") {
+			return testCommand{"gradle", "./gradlew test"}, true
+		}
+		return testCommand{"gradle", "gradle test"}, true
+	}
+	if makeTestTarget.MatchString(files["Makefile"]) {
+		return testCommand{"make", "make test"}, true
+	}
+	// pytest also runs unittest suites
+	if has("pyproject.toml") || has("setup.py") {
+		return testCommand{"pytest", "python -m pytest"}, true
+	}
+	return testCommand{}, false
+}
+
+// testSummary are the counts and failed tests parsed from the output of a
+// test run
+type testSummary struct {
+	Passed   int
+	Failed   int
+	Skipped  int
+	Failures []string
+}
+
+// addFailure records a failed test once
+func (s *testSummary) addFailure(name string) {
+	name = strings.TrimSpace(name)
+	for _, failure := range s.Failures {
+		if failure == name {
+			return
+		}
+	}
+	if name != "" && len(s.Failures) < maxFailures {
+		s.Failures = append(s.Failures, name)
+	}
+}
+
+// testOutputParser reads the summary of one test framework's output,
+// reporting whether the output is of that framework
+type testOutputParser func(output string) (testSummary, bool)
+
+// testOutputParsers are tried in order on the output of a test run
+var testOutputParsers = []testOutputParser{parseGoTest, parseCargoTest, parsePytest, parseJest, parseVitest, parseMocha, parseMaven, parseGradle}
+
+// parseTestOutput returns the summary of the first framework recognizing
+// output
+func parseTestOutput(output string) (testSummary, bool) {
+	for _, parse := range testOutputParsers {
+		if summary, ok := parse(output); ok {
+			return summary, true
+		}
+	}
+	return testSummary{}, false
+}
+
+var (
+	goTestResult    = regexp.MustCompile(`(?m)^\s*--- (PASS|FAIL|SKIP): (\S+)`)
+	goBuildFailure  = regexp.MustCompile(`(?m)^FAIL\s+(\S+)\s+\[(?:build|setup) failed\]`)
+	cargoResult     = regexp.MustCompile(`test result: \w+\. (\d+) passed; (\d+) failed; (\d+) ignored`)
+	cargoFailure    = regexp.MustCompile(`(?m)^test (\S+) \.\.\. FAILED`)
+	pytestSummary   = regexp.MustCompile(`(?m)^=+ (.*\d+ (?:passed|failed|skipped|errors?|deselected|xfailed|xpassed).*) in [\d.]+s`)
+	pytestFailure   = regexp.MustCompile(`(?m)^(?:FAILED|ERROR) (\S+)`)
+	jestSummary     = regexp.MustCompile(`(?m)^Tests:\s+(.*\d+ total)`)
+	jestFailure     = regexp.MustCompile(`(?m)^\s+● (.+)$`)
+	vitestSummary   = regexp.MustCompile(`(?m)^\s*Tests\s+(.*\d+ (?:passed|failed|skipped).*)\(\d+\)`)
+	vitestFailure   = regexp.MustCompile(`(?m)^\s*(?:FAIL|×)\s+(.+?)\s*(?:\d+ms)?$`)
+	mochaPassing    = regexp.MustCompile(`(?m)^\s*(\d+) passing`)
+	mochaFailing    = regexp.MustCompile(`(?m)^\s*(\d+) failing`)
+	mochaPending    = regexp.MustCompile(`(?m)^\s*(\d+) pending`)
+	mochaFailure    = regexp.MustCompile(`(?m)^\s+\d+\) (.+)$`)
+	mavenResult     = regexp.MustCompile(`Tests run: (\d+), Failures: (\d+), Errors: (\d+), Skipped: (\d+)`)
+	mavenFailure    = regexp.MustCompile(`<<< (?:FAILURE|ERROR)!? -+ in (\S+)`)
+	gradleSummary   = regexp.MustCompile(`(\d+) tests completed, (\d+) failed(?:, (\d+) skipped)?`)
+	gradleFailure   = regexp.MustCompile(`(?m)^(\S+ > .+) FAILED$`)
+	countedOutcomes = regexp.MustCompile(`(\d+) (passed|failed|skipped|errors?|pending|todo)`)
+)
+
+func parseGoTest(output string) (testSummary, bool) {
+	var summary testSummary
+	matches := goTestResult.FindAllStringSubmatch(output, -1)
+	builds := goBuildFailure.FindAllStringSubmatch(output, -1)
+	if len(matches) == 0 && len(builds) == 0 {
+		return summary, false
+	}
+	for _, match := range matches {
+		switch match[1] {
+		case "PASS":
+			summary.Passed++
+		case "FAIL":
+			summary.Failed++
+			summary.addFailure(match[2])
+		case "SKIP":
+			summary.Skipped++
+		}
+	}
+	for _, match := range builds {
+		summary.Failed++
+		summary.addFailure(match[1] + " [build failed]")
+	}
+	return summary, true
+}
+
+func parseCargoTest(output string) (testSummary, bool) {
+	var summary testSummary
+	results := cargoResult.FindAllStringSubmatch(output, -1)
+	if len(results) == 0 {
+		return summary, false
+	}
+	// One result per test binary
+	for _, result := range results {
+		summary.Passed += atoi(result[1])
+		summary.Failed += atoi(result[2])
+		summary.Skipped += atoi(result[3])
+	}
+	for _, match := range cargoFailure.FindAllStringSubmatch(output, -1) {
+		summary.addFailure(match[1])
+	}
+	return summary, true
+}
+
+func parsePytest(output string) (testSummary, bool) {
+	matches := pytestSummary.FindAllStringSubmatch(output, -1)
+	if len(matches) == 0 {
+		return testSummary{}, false
+	}
+	summary := countOutcomes(matches[len(matches)-1][1])
+	for _, match := range pytestFailure.FindAllStringSubmatch(output, -1) {
+		summary.addFailure(match[1])
+	}
+	return summary, true
+}
+
+func parseJest(output string) (testSummary, bool) {
+	matches := jestSummary.FindAllStringSubmatch(output, -1)
+	if len(matches) == 0 {
+		return testSummary{}, false
+	}
+	summary := countOutcomes(matches[len(matches)-1][1])
+	for _, match := range jestFailure.FindAllStringSubmatch(output, -1) {
+		if !strings.HasPrefix(match[1], "Test suite failed to run") && !strings.HasPrefix(match[1], "Console") {
+			summary.addFailure(match[1])
+		}
+	}
+	return summary, true
+}
+
+func parseVitest(output string) (testSummary, bool) {
+	matches := vitestSummary.FindAllStringSubmatch(output, -1)
+	if len(matches) == 0 {
+		return testSummary{}, false
+	}
+	summary := countOutcomes(matches[len(matches)-1][1])
+	for _, match := range vitestFailure.FindAllStringSubmatch(output, -1) {
+		summary.addFailure(match[1])
+	}
+	return summary, true
+}
+
+func parseMocha(output string) (testSummary, bool) {
+	passing := mochaPassing.FindStringSubmatch(output)
+	if passing == nil {
+		return testSummary{}, false
+	}
+	summary := testSummary{Passed: atoi(passing[1])}
+	if failing := mochaFailing.FindStringSubmatch(output); failing != nil {
+		summary.Failed = atoi(failing[1])
+		// The failures are numbered again in the details after the counts
+		details := output[strings.Index(output, failing[0]):]
+		for _, match := range mochaFailure.FindAllStringSubmatch(details, -1) {
+			summary.addFailure(match[1])
+		}
+	}
+	if pending := mochaPending.FindStringSubmatch(output); pending != nil {
+		summary.Skipped = atoi(pending[1])
+	}
+	return summary, true
+}
+
+func parseMaven(output string) (testSummary, bool) {
+	results := mavenResult.FindAllStringSubmatch(output, -1)
+	if len(results) == 0 {
+		return testSummary{}, false
+	}
+	// The last line totals the module
+	total := results[len(results)-1]
+	run, failures, errors, skipped := atoi(total[1]), atoi(total[2]), atoi(total[3]), atoi(total[4])
+	summary := testSummary{Passed: run - failures - errors - skipped, Failed: failures + errors, Skipped: skipped}
+	for _, match := range mavenFailure.FindAllStringSubmatch(output, -1) {
+		summary.addFailure(match[1])
+	}
+	return summary, true
+}
+
+func parseGradle(output string) (testSummary, bool) {
+	failures := gradleFailure.FindAllStringSubmatch(output, -1)
+	match := gradleSummary.FindStringSubmatch(output)
+	if match == nil && len(failures) == 0 {
+		return testSummary{}, false
+	}
+	var summary testSummary
+	if match != nil {
+		summary.Failed = atoi(match[2])
+		summary.Skipped = atoi(match[3])
+		summary.Passed = atoi(match[1]) - summary.Failed - summary.Skipped
+	}
+	for _, failure := range failures {
+		summary.addFailure(failure[1])
+	}
+	return summary, true
+}
+
+// countOutcomes reads counts such as "2 failed, 10 passed, 1 skipped"
+func countOutcomes(text string) testSummary {
+	var summary testSummary
+	for _, match := range countedOutcomes.FindAllStringSubmatch(text, -1) {
+		count := atoi(match[1])
+		switch match[2] {
+		case "passed":
+			summary.Passed += count
+		case "failed", "error", "errors":
+			summary.Failed += count
+		case "skipped", "pending", "todo":
+			summary.Skipped += count
+		}
+	}
+	return summary
+}
+
+// atoi parses a count the patterns above matched as digits
+func atoi(value string) int {
+	n, _ := strconv.Atoi(value)
+	return n
+}
+
+// outputTail returns the last lines of output
+func outputTail(output string, lines int) string {
+	all := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	if len(all) <= lines {
+		return strings.Join(all, "\n")
+	}
+	return strings.Join(all[len(all)-lines:], "\n")
+}
+
+// registerTestTools registers the tool that runs the project's tests
+func (s *Server) registerTestTools() {
+	// Run the project's tests in a workspace
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_runTests",
+		Description: "Run the tests of the project in a running workspace and return the pass/fail/skip counts, the failed tests and the end of the output. The test command is detected (go test, npm/yarn/pnpm test, cargo test, pytest, mvn test, gradle test or make test) unless given.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "The name of the workspace",
+				},
+				"command": map[string]interface{}{
+					"type":        "string",
+					"description": "Test command to run instead of the detected one, e.g. go test -run TestLogin ./auth/... (optional)",
+				},
+				"workdir": map[string]interface{}{
+					"type":        "string",
+					"description": "Directory to detect and run the tests in, relative to the workspace folder, e.g. a package of a monorepo (optional)",
+				},
+				"timeout": map[string]interface{}{
+					"type":        "string",
+					"description": "Stop the tests after this long, e.g. 30m (default: 10m)",
+				},
+			},
+			"required": []string{"name"},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var testParams struct {
+			Name    string `json:"name"`
+			Command string `json:"command,omitempty"`
+			Workdir string `json:"workdir,omitempty"`
+			Timeout string `json:"timeout,omitempty"`
+		}
+
+		if err := json.Unmarshal(params, &testParams); err != nil {
+			return nil, mcp.NewInvalidParamsError("Invalid run tests parameters")
+		}
+		if testParams.Name == "" {
+			return nil, mcp.NewInvalidParamsError("Workspace name is required")
+		}
+		timeout := defaultTestTimeout
+		if testParams.Timeout != "" {
+			d, err := time.ParseDuration(testParams.Timeout)
+			if err != nil || d <= 0 {
+				return nil, mcp.NewInvalidParamsError(fmt.Sprintf("Invalid timeout: %s", testParams.Timeout))
+			}
+			timeout = d
+		}
+		if err := s.requireRunning(ctx, testParams.Name); err != nil {
+			return nil, err
+		}
+
+		detected := testCommand{Framework: "custom", Command: testParams.Command}
+		if detected.Command == "" {
+			// The project files are data for the server, not console output
+			output, err := s.combinedOutput(WithOutputWriter(ctx, nil), []string{"ssh", testParams.Name, "--command", inDir(testParams.Workdir, filesCommand(testFiles, testMarkers))})
+			if err != nil {
+				return nil, newDevPodError("failed to detect the test command", err, output)
+			}
+			var ok bool
+			if detected, ok = detectTestCommand(splitFiles(string(output))); !ok {
+				return nil, mcp.NewInvalidParamsError(fmt.Sprintf("No test command detected in %s; pass command", testParams.Name))
+			}
+		}
+
+		// The workspace stops the tests at the timeout; this stops waiting
+		// for a workspace without the timeout command
+		runCtx, cancel := context.WithTimeout(ctx, timeout+time.Minute)
+		defer cancel()
+		start := time.Now()
+		output, err := s.combinedOutput(runCtx, []string{"ssh", testParams.Name, "--command", exitCodeCommand(inDir(testParams.Workdir, detected.Command), timeout)})
+		testOutput, exitCode, ran := splitExitCode(string(output))
+		if !ran {
+			if err == nil {
+				err = fmt.Errorf("the tests' exit code was not reported")
+			}
+			s.state(ctx).RecordEvent(testParams.Name, "error", fmt.Sprintf("tests failed to run: %v", err))
+			return nil, newDevPodError("failed to run tests", err, output)
+		}
+		testOutput = strings.TrimRight(testOutput, "\n")
+		timedOut := exitCode == 124
+		s.state(ctx).RecordEvent(testParams.Name, "command", fmt.Sprintf("Ran %q (exit code %d)", detected.Command, exitCode))
+		s.touchWorkspace(ctx, testParams.Name)
+
+		summary, parsed := parseTestOutput(testOutput)
+		result := map[string]interface{}{
+			"name":       testParams.Name,
+			"framework":  detected.Framework,
+			"command":    detected.Command,
+			"exitCode":   exitCode,
+			"success":    exitCode == 0,
+			"timedOut":   timedOut,
+			"parsed":     parsed,
+			"output":     testOutput,
+			"durationMs": durationMs(start),
+		}
+		if parsed {
+			result["passed"] = summary.Passed
+			result["failed"] = summary.Failed
+			result["skipped"] = summary.Skipped
+			result["total"] = summary.Passed + summary.Failed + summary.Skipped
+			failures := summary.Failures
+			if failures == nil {
+				failures = []string{}
+			}
+			result["failures"] = failures
+		}
+		switch {
+		case timedOut:
+			result["message"] = fmt.Sprintf("Tests timed out after %s", timeout)
+		case exitCode == 0 && parsed:
+			result["message"] = fmt.Sprintf("%d test(s) passed, %d skipped", summary.Passed, summary.Skipped)
+		case exitCode == 0:
+			result["message"] = "Tests passed"
+		case parsed:
+			result["message"] = fmt.Sprintf("%d of %d test(s) failed", summary.Failed, summary.Passed+summary.Failed)
+		default:
+			result["message"] = fmt.Sprintf("Tests failed with exit code %d", exitCode)
+		}
+		if exitCode != 0 {
+			result["failureOutput"] = outputTail(testOutput, failureTailLines)
+		}
+		return result, nil
+	})
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDetectTestCommand(t *testing.T) {
+	for _, tc := range []struct {
+		files map[string]string
+		want  string
+	}{
+		{map[string]string{"go.mod": "", "package.json": `{"scripts":{"test":"jest"}}`}, "go test -v ./..."},
+		{map[string]string{"package.json": `{"scripts":{"test":"jest"}}`, "yarn.lock": ""}, "yarn test"},
+		{map[string]string{"package.json": `{"scripts":{"test":"echo \"Error: no test specified\" && exit 1"}}`, "setup.py": ""}, "python -m pytest"},
+		{map[string]string{"pyproject.toml": "[tool.pytest.ini_options]\n", "Makefile": "test:\n\tnox\n"}, "python -m pytest"},
+		{map[string]string{"Cargo.toml": ""}, "cargo test"},
+		{map[string]string{"build.gradle.kts": "", "gradlew": ""}, "./gradlew test"},
+		{map[string]string{"Makefile": "build:\n\tcc main.c\ntest: build\n\t./run-tests\n"}, "make test"},
+	} {
+		detected, ok := detectTestCommand(tc.files)
+		if !ok || detected.Command != tc.want {
+			t.Errorf("%v: expected %s, got %+v", tc.files, tc.want, detected)
+		}
+	}
+	if detected, ok := detectTestCommand(map[string]string{"README.md": ""}); ok {
+		t.Errorf("Expected no test command, got %+v", detected)
+	}
+}
+
+func TestParseTestOutput(t *testing.T) {
+	for _, tc := range []struct {
+		framework string
+		output    string
+		want      testSummary
+	}{
+		{"go", "=== RUN   TestA\n--- PASS: TestA (0.00s)\n=== RUN   TestB\n    b_test.go:9: boom\n--- FAIL: TestB (0.01s)\n--- SKIP: TestC (0.00s)\nFAIL\nFAIL\texample.com/b\t0.02s\nFAIL\texample.com/c [build failed]\n",
+			testSummary{Passed: 1, Failed: 2, Skipped: 1, Failures: []string{"TestB", "example.com/c [build failed]"}}},
+		{"cargo", "test tests::adds ... ok\ntest tests::divides ... FAILED\n\ntest result: FAILED. 1 passed; 1 failed; 2 ignored; 0 measured\n\ntest result: ok. 3 passed; 0 failed; 0 ignored; 0 measured\n",
+			testSummary{Passed: 4, Failed: 1, Skipped: 2, Failures: []string{"tests::divides"}}},
+		{"pytest", "tests/test_api.py .F.s\n=========================== short test summary info ============================\nFAILED tests/test_api.py::test_login - AssertionError\n============== 1 failed, 2 passed, 1 skipped in 0.12s ==============\n",
+			testSummary{Passed: 2, Failed: 1, Skipped: 1, Failures: []string{"tests/test_api.py::test_login"}}},
+		{"jest", "FAIL src/sum.test.js\n  ● sum › adds numbers\n\n    expect(received).toBe(expected)\n\nTest Suites: 1 failed, 1 total\nTests:       1 failed, 1 skipped, 4 passed, 6 total\n",
+			testSummary{Passed: 4, Failed: 1, Skipped: 1, Failures: []string{"sum › adds numbers"}}},
+		{"vitest", " ✓ src/a.test.ts (2 tests) 3ms\n FAIL  src/b.test.ts > b > fails\n\n Test Files  1 failed | 1 passed (2)\n      Tests  1 failed | 2 passed (3)\n",
+			testSummary{Passed: 2, Failed: 1, Failures: []string{"src/b.test.ts > b > fails"}}},
+		{"mocha", "  Array\n    ✓ works\n    1) breaks\n\n  1 passing (8ms)\n  1 failing\n  1 pending\n\n  1) Array\n       breaks:\n     AssertionError\n",
+			testSummary{Passed: 1, Failed: 1, Skipped: 1, Failures: []string{"Array"}}},
+		{"maven", "[ERROR] Tests run: 3, Failures: 1, Errors: 0, Skipped: 0, Time elapsed: 0.1 s <<< FAILURE! -- in com.example.AppTest\n[INFO] Results:\n[ERROR] Tests run: 5, Failures: 1, Errors: 1, Skipped: 1\n",
+			testSummary{Passed: 2, Failed: 2, Skipped: 1, Failures: []string{"com.example.AppTest"}}},
+		{"gradle", "AppTest > adds() FAILED\n    org.opentest4j.AssertionFailedError\n\n4 tests completed, 1 failed, 1 skipped\n",
+			testSummary{Passed: 2, Failed: 1, Skipped: 1, Failures: []string{"AppTest > adds()"}}},
+	} {
+		summary, ok := parseTestOutput(tc.output)
+		if !ok || !reflect.DeepEqual(summary, tc.want) {
+			t.Errorf("%s: expected %+v, got %+v (%v)", tc.framework, tc.want, summary, ok)
+		}
+	}
+	if _, ok := parseTestOutput("all good\n"); ok {
+		t.Error("Expected unknown output not to be parsed")
+	}
+}
+
+func TestRunTests(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "svc"), 0o755)
+	os.WriteFile(filepath.Join(dir, "svc", "Makefile"), []byte("test:\n\t@echo '--- PASS: TestUp'\n\t@echo '--- FAIL: TestDown'\n\t@exit 1\n"), 0o644)
+
+	s := newTestServer(t, &shellRunner{dir: dir})
+	runTests := s.MCP().GetHandler("devpod_runTests")
+	run := func(args string) map[string]interface{} {
+		t.Helper()
+		result, err := runTests(context.Background(), json.RawMessage(args))
+		if err != nil {
+			t.Fatalf("runTests %s failed: %v", args, err)
+		}
+		return result.(map[string]interface{})
+	}
+
+	result := run(`{"name":"api","workdir":"svc"}`)
+	if result["command"] != "make test" || result["success"] != false || result["passed"] != 1 || result["failed"] != 1 {
+		t.Fatalf("Unexpected result %v", result)
+	}
+	if failures := result["failures"].([]string); len(failures) != 1 || failures[0] != "TestDown" {
+		t.Errorf("Expected TestDown to be reported, got %v", failures)
+	}
+	if !strings.Contains(result["failureOutput"].(string), "--- FAIL: TestDown") {
+		t.Errorf("Expected the end of the output, got %q", result["failureOutput"])
+	}
+
+	result = run(`{"name":"api","command":"echo '3 passing'","timeout":"5s"}`)
+	if result["success"] != true || result["passed"] != 3 || result["framework"] != "custom" {
+		t.Errorf("Unexpected result %v", result)
+	}
+	if result := run(`{"name":"api","command":"sleep 5","timeout":"1s"}`); result["timedOut"] != true {
+		t.Errorf("Expected the tests to time out, got %v", result)
+	}
+
+	if _, err := runTests(context.Background(), json.RawMessage(`{"name":"api"}`)); err == nil {
+		t.Error("Expected a project without tests to be rejected")
+	}
+}
@@ -0,0 +1,183 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+// registeredTool is a tool listed by tools/list with the handler tools/call
+// dispatches to
+type registeredTool struct {
+	mcp.Tool
+	handler mcp.Handler
+}
+
+// toolRegistry holds the tools in registration order. It is the single
+// source for tools/list and tools/call, so every listed tool is callable.
+type toolRegistry struct {
+	mu    sync.RWMutex
+	tools []registeredTool
+	index map[string]int
+	// aliases maps deprecated tool names to current ones
+	aliases map[string]string
+}
+
+func newToolRegistry() *toolRegistry {
+	return &toolRegistry{index: make(map[string]int), aliases: make(map[string]string)}
+}
+
+// legacyToolPrefix is the prefix of the dot-named tools, e.g.
+// devpod.listWorkspaces, used by older clients and documentation
+const legacyToolPrefix = "devpod."
+
+// add registers a tool, replacing an earlier tool of the same name
+func (r *toolRegistry) add(tool mcp.Tool, handler mcp.Handler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if i, ok := r.index[tool.Name]; ok {
+		r.tools[i] = registeredTool{Tool: tool, handler: handler}
+		return
+	}
+	r.index[tool.Name] = len(r.tools)
+	r.tools = append(r.tools, registeredTool{Tool: tool, handler: handler})
+	if strings.HasPrefix(tool.Name, "devpod_") {
+		r.aliases[legacyToolPrefix+strings.TrimPrefix(tool.Name, "devpod_")] = tool.Name
+	}
+}
+
+// resolve returns the tool called name, following deprecated aliases. The
+// alias is returned when one was used.
+func (r *toolRegistry) resolve(name string) (registeredTool, string, bool) {
+	r.mu.RLock()
+	current, isAlias := r.aliases[name]
+	r.mu.RUnlock()
+	if !isAlias {
+		tool, ok := r.get(name)
+		return tool, "", ok
+	}
+	tool, ok := r.get(current)
+	return tool, name, ok
+}
+
+// get returns the tool with the given name
+func (r *toolRegistry) get(name string) (registeredTool, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	i, ok := r.index[name]
+	if !ok {
+		return registeredTool{}, false
+	}
+	return r.tools[i], true
+}
+
+// list returns the listings of all tools in registration order
+func (r *toolRegistry) list() []mcp.Tool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tools := make([]mcp.Tool, len(r.tools))
+	for i, tool := range r.tools {
+		tools[i] = tool.Tool
+	}
+	return tools
+}
+
+// RegisterTool adds a tool to tools/list and routes tools/call requests for
+// it to handler. The handler is also registered as a method of its own name.
+// Calls the policy denies fail without running it, credentials in its
+// results and errors are redacted, and its calls are listed on the dashboard.
+func (s *Server) RegisterTool(tool mcp.Tool, handler mcp.Handler) {
+	tool.InputSchema = withFormatProperty(withEnvProperty(tool.Name, tool.InputSchema))
+	handler = s.trackHandler(tool.Name, s.policyHandler(tool.Name, s.lockHandler(tool.Name, s.redactHandler(handler))))
+	s.tools.add(tool, handler)
+	s.mcp.RegisterHandler(tool.Name, handler)
+}
+
+// validateArguments checks tool arguments against the tool's input schema:
+// required properties must be present and declared properties must have the
+// declared type and, for enums, one of the declared values. Undeclared
+// properties are left to the handler.
+func validateArguments(schema map[string]interface{}, args map[string]interface{}) error {
+	for _, name := range schemaStrings(schema["required"]) {
+		if value, ok := args[name]; !ok || value == nil {
+			return fmt.Errorf("%s is required", name)
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]interface{})
+	names := make([]string, 0, len(args))
+	for name := range args {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		property, ok := properties[name].(map[string]interface{})
+		value := args[name]
+		if !ok || value == nil {
+			continue
+		}
+		if kind, _ := property["type"].(string); kind != "" && !hasSchemaType(value, kind) {
+			return fmt.Errorf("%s must be of type %s", name, kind)
+		}
+		if enum := schemaStrings(property["enum"]); len(enum) > 0 {
+			valid := false
+			for _, allowed := range enum {
+				if value == allowed {
+					valid = true
+					break
+				}
+			}
+			if !valid {
+				return fmt.Errorf("%s must be one of: %s", name, strings.Join(enum, ", "))
+			}
+		}
+	}
+	return nil
+}
+
+// hasSchemaType reports whether a decoded JSON value has a JSON schema type
+func hasSchemaType(value interface{}, kind string) bool {
+	switch kind {
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "integer":
+		n, ok := value.(float64)
+		return ok && n == math.Trunc(n)
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	}
+	return true
+}
+
+// schemaStrings returns a schema keyword holding a list of strings
+func schemaStrings(value interface{}) []string {
+	switch list := value.(type) {
+	case []string:
+		return list
+	case []interface{}:
+		strs := make([]string, 0, len(list))
+		for _, item := range list {
+			if s, ok := item.(string); ok {
+				strs = append(strs, s)
+			}
+		}
+		return strs
+	}
+	return nil
+}
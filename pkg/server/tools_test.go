@@ -0,0 +1,100 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestValidateArguments(t *testing.T) {
+	schema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"name":     map[string]interface{}{"type": "string"},
+			"limit":    map[string]interface{}{"type": "integer"},
+			"ifExists": map[string]interface{}{"type": "string", "enum": []string{"fail", "start"}},
+		},
+		"required": []string{"name"},
+	}
+
+	tests := []struct {
+		args map[string]interface{}
+		want string
+	}{
+		{map[string]interface{}{"name": "api", "limit": float64(5), "extra": true}, ""},
+		{map[string]interface{}{"limit": float64(5)}, "name is required"},
+		{map[string]interface{}{"name": "api", "limit": 2.5}, "limit must be of type integer"},
+		{map[string]interface{}{"name": 3.0}, "name must be of type string"},
+		{map[string]interface{}{"name": "api", "ifExists": "recreate"}, "ifExists must be one of: fail, start"},
+	}
+	for _, tt := range tests {
+		err := validateArguments(schema, tt.args)
+		if (tt.want == "" && err != nil) || (tt.want != "" && (err == nil || err.Error() != tt.want)) {
+			t.Errorf("validateArguments(%v) = %v; want %q", tt.args, err, tt.want)
+		}
+	}
+}
+
+func TestListedToolsAreCallable(t *testing.T) {
+	s := newTestServer(t, &fakeRunner{})
+
+	result, err := s.MCP().GetHandler("tools/list")(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("tools/list failed: %v", err)
+	}
+	tools := result.(toolsListResult).Tools
+	if len(tools) < 2 || tools[0].Name != "echo" {
+		t.Fatalf("Expected echo followed by the DevPod tools, got %d tools", len(tools))
+	}
+	for _, tool := range tools {
+		if s.MCP().GetHandler(tool.Name) == nil {
+			t.Errorf("Listed tool %s has no handler", tool.Name)
+		}
+		if tool.InputSchema["type"] != "object" {
+			t.Errorf("Tool %s has no object input schema", tool.Name)
+		}
+	}
+
+	call := s.MCP().GetHandler("tools/call")
+	if _, err := call(context.Background(), json.RawMessage(`{"name":"devpod_status","arguments":{}}`)); err == nil || !strings.Contains(err.Error(), "name is required") {
+		t.Errorf("Expected missing arguments to be rejected, got %v", err)
+	}
+	if _, err := call(context.Background(), json.RawMessage(`{"name":"tools/list"}`)); err == nil {
+		t.Error("Expected methods that are not tools to be rejected")
+	}
+	result, err = call(context.Background(), json.RawMessage(`{"name":"echo","arguments":{"message":"hi"}}`))
+	if err != nil || !strings.Contains(mustJSON(t, result), "Echo: hi") {
+		t.Errorf("Unexpected echo result %v (%v)", result, err)
+	}
+}
+
+func mustJSON(t *testing.T, v interface{}) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestDeprecatedToolNames(t *testing.T) {
+	s := newTestServer(t, &fakeRunner{outputs: map[string]string{"list --output json": "[]"}})
+
+	result, err := s.MCP().GetHandler("tools/call")(context.Background(), json.RawMessage(`{"name":"devpod.listWorkspaces"}`))
+	if err != nil {
+		t.Fatalf("devpod.listWorkspaces failed: %v", err)
+	}
+	meta, ok := result.(map[string]interface{})["_meta"].(map[string]interface{})
+	if !ok || meta["toolName"] != "devpod_listWorkspaces" || !strings.Contains(meta["deprecated"].(string), "deprecated") {
+		t.Errorf("Expected a deprecation notice, got %v", result)
+	}
+
+	result, err = s.MCP().GetHandler("tools/call")(context.Background(), json.RawMessage(`{"name":"devpod_listWorkspaces"}`))
+	if err != nil {
+		t.Fatalf("devpod_listWorkspaces failed: %v", err)
+	}
+	if _, ok := result.(map[string]interface{})["_meta"]; ok {
+		t.Error("Expected no deprecation notice for the current name")
+	}
+}
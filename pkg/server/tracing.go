@@ -0,0 +1,385 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tracing defaults for TracingOptions fields left at zero
+const (
+	defaultTracingService  = "mcp-server-devpod"
+	defaultTracingInterval = 5 * time.Second
+	// maxPendingSpans bounds the spans waiting for export; newer spans are
+	// dropped while the collector is unreachable
+	maxPendingSpans = 4096
+)
+
+// TracingOptions exports OpenTelemetry spans of tool calls and the devpod
+// commands they run to an OTLP/HTTP collector. The spans are encoded as OTLP
+// JSON by the server itself, without the OpenTelemetry SDK.
+type TracingOptions struct {
+	// Endpoint is the collector's base URL, e.g. http://localhost:4318; spans
+	// are posted to its /v1/traces. Empty disables tracing.
+	Endpoint string
+	// ServiceName is the service.name of the exported spans (default:
+	// mcp-server-devpod)
+	ServiceName string
+	// Headers are sent with every export, e.g. for authentication
+	Headers map[string]string
+	// Interval is how often finished spans are exported (default: 5s)
+	Interval time.Duration
+}
+
+// ParseOTLPHeaders parses headers written as "key=value,key=value", the
+// format of OTEL_EXPORTER_OTLP_HEADERS
+func ParseOTLPHeaders(spec string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(spec, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid header %q: expected key=value", pair)
+		}
+		headers[key] = strings.TrimSpace(value)
+	}
+	return headers, nil
+}
+
+// Span kinds of the OTLP data model
+const (
+	spanKindServer = 2
+	spanKindClient = 3
+)
+
+// OTLP status codes
+const (
+	statusOK    = 1
+	statusError = 2
+)
+
+// span is a timed operation of a trace
+type span struct {
+	traceID  [16]byte
+	spanID   [8]byte
+	parentID [8]byte
+	name     string
+	kind     int
+	start    time.Time
+
+	mu         sync.Mutex
+	end        time.Time
+	attributes map[string]interface{}
+	status     int
+	message    string
+	tracer     *tracer
+}
+
+// setAttribute records a string, int, int64 or bool attribute
+func (sp *span) setAttribute(key string, value interface{}) {
+	if sp == nil {
+		return
+	}
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	sp.attributes[key] = value
+}
+
+// finish ends the span with the outcome of its operation and queues it for
+// export
+func (sp *span) finish(err error) {
+	if sp == nil {
+		return
+	}
+	sp.mu.Lock()
+	sp.end = time.Now()
+	if err != nil {
+		sp.status, sp.message = statusError, sp.tracer.redact(err.Error())
+	} else {
+		sp.status = statusOK
+	}
+	sp.mu.Unlock()
+	sp.tracer.queue(sp)
+}
+
+// spanKey is the context key of the current span
+type spanKey struct{}
+
+// withTraceParent returns a context whose spans continue the trace of a W3C
+// traceparent, e.g. from the _meta of a tools/call, so a client's trace
+// extends into this server. Malformed values are ignored.
+func withTraceParent(ctx context.Context, traceparent string) context.Context {
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return ctx
+	}
+	remote := &span{}
+	traceID, err := hex.DecodeString(parts[1])
+	if err != nil || len(traceID) != len(remote.traceID) {
+		return ctx
+	}
+	spanID, err := hex.DecodeString(parts[2])
+	if err != nil || len(spanID) != len(remote.spanID) {
+		return ctx
+	}
+	copy(remote.traceID[:], traceID)
+	copy(remote.spanID[:], spanID)
+	if remote.traceID == ([16]byte{}) || remote.spanID == ([8]byte{}) {
+		return ctx
+	}
+	return context.WithValue(ctx, spanKey{}, remote)
+}
+
+// traceParent returns the W3C traceparent of the context's current span for
+// outgoing requests, or "" when there is none
+func traceParent(ctx context.Context) string {
+	sp, ok := ctx.Value(spanKey{}).(*span)
+	if !ok || sp == nil || sp.tracer == nil {
+		return ""
+	}
+	return fmt.Sprintf("00-%s-%s-01", hex.EncodeToString(sp.traceID[:]), hex.EncodeToString(sp.spanID[:]))
+}
+
+// tracer batches finished spans and posts them to the collector
+type tracer struct {
+	url      string
+	service  string
+	version  string
+	headers  map[string]string
+	interval time.Duration
+	client   *http.Client
+	redact   func(string) string
+	// failed reports export failures
+	failed func(error)
+
+	mu      sync.Mutex
+	pending []*span
+	dropped int
+}
+
+// newTracer returns nil when tracing is disabled
+func newTracer(opts TracingOptions, version string, redact func(string) string, failed func(error)) *tracer {
+	if opts.Endpoint == "" {
+		return nil
+	}
+	url := strings.TrimSuffix(opts.Endpoint, "/")
+	if !strings.HasSuffix(url, "/v1/traces") {
+		url += "/v1/traces"
+	}
+	if opts.ServiceName == "" {
+		opts.ServiceName = defaultTracingService
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = defaultTracingInterval
+	}
+	return &tracer{
+		url:      url,
+		service:  opts.ServiceName,
+		version:  version,
+		headers:  opts.Headers,
+		interval: opts.Interval,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		redact:   redact,
+		failed:   failed,
+	}
+}
+
+// startSpan starts a span as a child of the context's current span, which
+// may be a remote one from withTraceParent, or as the root of a new trace. It returns the context carrying the new span,
+// and a nil span when tracing is disabled.
+func (t *tracer) startSpan(ctx context.Context, name string, kind int) (context.Context, *span) {
+	if t == nil {
+		return ctx, nil
+	}
+	sp := &span{name: name, kind: kind, start: time.Now(), attributes: make(map[string]interface{}), tracer: t}
+	if parent, ok := ctx.Value(spanKey{}).(*span); ok && parent != nil {
+		sp.traceID, sp.parentID = parent.traceID, parent.spanID
+	} else {
+		_, _ = rand.Read(sp.traceID[:])
+	}
+	_, _ = rand.Read(sp.spanID[:])
+	return context.WithValue(ctx, spanKey{}, sp), sp
+}
+
+func (t *tracer) queue(sp *span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.pending) >= maxPendingSpans {
+		t.dropped++
+		return
+	}
+	t.pending = append(t.pending, sp)
+}
+
+// run exports finished spans every interval until ctx is done
+func (t *tracer) run(ctx context.Context) {
+	if t == nil {
+		return
+	}
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			t.flush(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// flush exports the finished spans. Spans of a failed export are dropped
+// rather than retried so a collector outage cannot grow memory.
+func (t *tracer) flush(ctx context.Context) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	spans, dropped := t.pending, t.dropped
+	t.pending, t.dropped = nil, 0
+	t.mu.Unlock()
+	if len(spans) == 0 {
+		return
+	}
+	if err := t.export(ctx, spans); err != nil {
+		t.failed(fmt.Errorf("failed to export %d span(s) to %s: %w", len(spans), t.url, err))
+	} else if dropped > 0 {
+		t.failed(fmt.Errorf("dropped %d span(s) while the export queue was full", dropped))
+	}
+}
+
+// export posts spans to the collector as OTLP JSON
+func (t *tracer) export(ctx context.Context, spans []*span) error {
+	body, err := json.Marshal(t.request(spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range t.headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("collector responded %s", resp.Status)
+	}
+	return nil
+}
+
+// request encodes spans as an OTLP ExportTraceServiceRequest
+func (t *tracer) request(spans []*span) map[string]interface{} {
+	encoded := make([]map[string]interface{}, 0, len(spans))
+	for _, sp := range spans {
+		sp.mu.Lock()
+		entry := map[string]interface{}{
+			"traceId":           hex.EncodeToString(sp.traceID[:]),
+			"spanId":            hex.EncodeToString(sp.spanID[:]),
+			"name":              sp.name,
+			"kind":              sp.kind,
+			"startTimeUnixNano": strconv.FormatInt(sp.start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(sp.end.UnixNano(), 10),
+			"attributes":        otlpAttributes(sp.attributes),
+			"status":            map[string]interface{}{"code": sp.status, "message": sp.message},
+		}
+		if sp.parentID != ([8]byte{}) {
+			entry["parentSpanId"] = hex.EncodeToString(sp.parentID[:])
+		}
+		sp.mu.Unlock()
+		encoded = append(encoded, entry)
+	}
+	return map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{
+				"attributes": otlpAttributes(map[string]interface{}{
+					"service.name":    t.service,
+					"service.version": t.version,
+				}),
+			},
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": map[string]interface{}{"name": defaultTracingService, "version": t.version},
+				"spans": encoded,
+			}},
+		}},
+	}
+}
+
+// otlpAttributes encodes attributes as OTLP key-value pairs
+func otlpAttributes(attributes map[string]interface{}) []map[string]interface{} {
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	encoded := make([]map[string]interface{}, 0, len(keys))
+	for _, key := range keys {
+		var value map[string]interface{}
+		switch v := attributes[key].(type) {
+		case bool:
+			value = map[string]interface{}{"boolValue": v}
+		case int:
+			value = map[string]interface{}{"intValue": strconv.Itoa(v)}
+		case int64:
+			value = map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}
+		default:
+			value = map[string]interface{}{"stringValue": fmt.Sprint(v)}
+		}
+		encoded = append(encoded, map[string]interface{}{"key": key, "value": value})
+	}
+	return encoded
+}
+
+// argsHash identifies a call's arguments without exporting them, since they
+// may hold credentials
+func argsHash(params []byte) string {
+	sum := sha256.Sum256(params)
+	return hex.EncodeToString(sum[:8])
+}
+
+// devpodSubcommand returns the devpod subcommand of args, including the
+// second word of command groups such as provider add
+func devpodSubcommand(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	switch args[0] {
+	case "provider", "context", "ide", "machine", "pro":
+		if len(args) > 1 && !strings.HasPrefix(args[1], "-") {
+			return args[0] + " " + args[1]
+		}
+	}
+	return args[0]
+}
+
+// traceCommand records the outcome of a devpod command on its span
+func traceCommand(sp *span, err error) {
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		sp.setAttribute("process.exit_code", 0)
+	case errors.As(err, &exitErr):
+		sp.setAttribute("process.exit_code", exitErr.ExitCode())
+	}
+	sp.finish(err)
+}
@@ -0,0 +1,200 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/protobomb/mcp-server-framework/pkg/transport"
+)
+
+// otlpSpan is the part of an exported span the tests look at
+type otlpSpan struct {
+	TraceID      string `json:"traceId"`
+	SpanID       string `json:"spanId"`
+	ParentSpanID string `json:"parentSpanId"`
+	Name         string `json:"name"`
+	Kind         int    `json:"kind"`
+	Attributes   []struct {
+		Key   string                 `json:"key"`
+		Value map[string]interface{} `json:"value"`
+	} `json:"attributes"`
+	Status struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"status"`
+}
+
+func (sp otlpSpan) attribute(key string) interface{} {
+	for _, attribute := range sp.Attributes {
+		if attribute.Key == key {
+			for _, value := range attribute.Value {
+				return value
+			}
+		}
+	}
+	return nil
+}
+
+// collector records the spans posted to it
+type collector struct {
+	mu      sync.Mutex
+	spans   []otlpSpan
+	headers http.Header
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []otlpSpan `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	if r.URL.Path != "/v1/traces" || json.NewDecoder(r.Body).Decode(&request) != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.headers = r.Header
+	for _, resource := range request.ResourceSpans {
+		for _, scope := range resource.ScopeSpans {
+			c.spans = append(c.spans, scope.Spans...)
+		}
+	}
+}
+
+func TestToolCallsAreTraced(t *testing.T) {
+	collected := &collector{}
+	endpoint := httptest.NewServer(collected)
+	defer endpoint.Close()
+
+	runner := &fakeRunner{
+		outputs:  map[string]string{"stop api": "stopped"},
+		failures: map[string]string{"delete api": "delete failed with token hunter2-secret"},
+	}
+	s := New(transport.NewSTDIOTransport(), Options{
+		Runner:    runner,
+		StatePath: filepath.Join(t.TempDir(), "state.json"),
+		Tracing:   TracingOptions{Endpoint: endpoint.URL, Headers: map[string]string{"Authorization": "Bearer abc"}},
+	})
+	s.redactor.add("hunter2-secret")
+
+	if _, err := s.MCP().GetHandler("devpod_stopWorkspace")(context.Background(), json.RawMessage(`{"name":"api"}`)); err != nil {
+		t.Fatalf("devpod_stopWorkspace failed: %v", err)
+	}
+	if _, err := s.MCP().GetHandler("devpod_deleteWorkspace")(context.Background(), json.RawMessage(`{"name":"api"}`)); err == nil {
+		t.Fatal("Expected devpod_deleteWorkspace to fail")
+	}
+	s.tracer.flush(context.Background())
+
+	collected.mu.Lock()
+	defer collected.mu.Unlock()
+	if collected.headers.Get("Authorization") != "Bearer abc" {
+		t.Errorf("Expected the configured headers, got %v", collected.headers)
+	}
+	spans := make(map[string]otlpSpan)
+	for _, sp := range collected.spans {
+		spans[sp.Name] = sp
+	}
+	tool, command := spans["devpod_stopWorkspace"], spans["devpod stop"]
+	if tool.Kind != spanKindServer || tool.attribute("mcp.tool.name") != "devpod_stopWorkspace" || tool.attribute("mcp.tool.args_hash") == nil {
+		t.Fatalf("Expected a tool span, got %+v", collected.spans)
+	}
+	if command.TraceID != tool.TraceID || command.ParentSpanID != tool.SpanID || command.Kind != spanKindClient {
+		t.Errorf("Expected the devpod command as a child of the tool call, got %+v and %+v", tool, command)
+	}
+	if command.attribute("process.exit_code") != "0" || tool.Status.Code != statusOK {
+		t.Errorf("Expected a successful command, got %+v", command)
+	}
+
+	failed := spans["devpod_deleteWorkspace"]
+	if failed.Status.Code != statusError || strings.Contains(failed.Status.Message, "hunter2-secret") {
+		t.Errorf("Expected a redacted error status, got %+v", failed.Status)
+	}
+	for _, sp := range collected.spans {
+		for _, attribute := range sp.Attributes {
+			if value, _ := attribute.Value["stringValue"].(string); value == "api" {
+				t.Errorf("Expected arguments not to be exported, got %s on %s", attribute.Key, sp.Name)
+			}
+		}
+	}
+}
+
+func TestTracingDisabledWithoutEndpoint(t *testing.T) {
+	s := newTestServer(t, &fakeRunner{})
+	if s.tracer != nil {
+		t.Fatal("Expected tracing to be disabled")
+	}
+	ctx, sp := s.tracer.startSpan(context.Background(), "devpod_listWorkspaces", spanKindServer)
+	sp.setAttribute("mcp.tool.name", "devpod_listWorkspaces")
+	sp.finish(nil)
+	if ctx.Value(spanKey{}) != nil {
+		t.Error("Expected no span in the context")
+	}
+}
+
+func TestParseOTLPHeaders(t *testing.T) {
+	headers, err := ParseOTLPHeaders("authorization=Bearer abc, x-tenant = dev")
+	if err != nil || headers["authorization"] != "Bearer abc" || headers["x-tenant"] != "dev" {
+		t.Errorf("Unexpected headers %v (%v)", headers, err)
+	}
+	if _, err := ParseOTLPHeaders("broken"); err == nil {
+		t.Error("Expected a header without a value to be rejected")
+	}
+}
+
+func TestToolCallsContinueTheClientsTrace(t *testing.T) {
+	collected := &collector{}
+	endpoint := httptest.NewServer(collected)
+	defer endpoint.Close()
+	var forwarded []string
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded = append(forwarded, r.Header.Get("traceparent"))
+		w.Write([]byte(`{"result":true}`))
+	}))
+	defer webhook.Close()
+
+	s := New(transport.NewSTDIOTransport(), Options{
+		Runner:    &fakeRunner{outputs: map[string]string{"stop api": "stopped"}},
+		StatePath: filepath.Join(t.TempDir(), "state.json"),
+		Tracing:   TracingOptions{Endpoint: endpoint.URL},
+		Policy:    PolicyOptions{Webhook: webhook.URL},
+	})
+	call := s.MCP().GetHandler("tools/call")
+	for _, traceparent := range []string{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "00-00000000000000000000000000000000-00f067aa0ba902b7-01"} {
+		params := `{"name":"devpod_stopWorkspace","arguments":{"name":"api"},"_meta":{"traceparent":"` + traceparent + `"}}`
+		if _, err := call(context.Background(), json.RawMessage(params)); err != nil {
+			t.Fatalf("devpod_stopWorkspace failed: %v", err)
+		}
+	}
+	s.tracer.flush(context.Background())
+
+	collected.mu.Lock()
+	defer collected.mu.Unlock()
+	var tools []otlpSpan
+	for _, sp := range collected.spans {
+		if sp.Name == "devpod_stopWorkspace" {
+			tools = append(tools, sp)
+		}
+	}
+	if len(tools) != 2 {
+		t.Fatalf("Expected 2 tool spans, got %+v", collected.spans)
+	}
+	if tools[0].TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || tools[0].ParentSpanID != "00f067aa0ba902b7" {
+		t.Errorf("Expected the tool call to continue the client's trace, got %+v", tools[0])
+	}
+	if len(forwarded) != 2 || forwarded[0] != "00-"+tools[0].TraceID+"-"+tools[0].SpanID+"-01" {
+		t.Errorf("Expected the tool span to be propagated to the policy webhook, got %v", forwarded)
+	}
+	// An invalid traceparent starts a new trace
+	if tools[1].TraceID == "00000000000000000000000000000000" || tools[1].ParentSpanID != "" {
+		t.Errorf("Expected a new trace for an invalid traceparent, got %+v", tools[1])
+	}
+}
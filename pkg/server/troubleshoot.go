@@ -0,0 +1,191 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+// defaultSectionBytes caps each troubleshoot section unless the caller asks
+// for more
+const defaultSectionBytes = 8192
+
+// troubleshootHeader matches section headers of the text output:
+// "## Title", "=== Title ===" or "Title:" alone on a line
+var troubleshootHeader = regexp.MustCompile(`^(?:#+\s*(.+?)\s*#*|=+\s*(.+?)\s*=+|([A-Z][A-Za-z0-9 ]{0,60}):)\s*$`)
+
+// sectionKey turns a header such as "Workspace Status" into "workspaceStatus"
+func sectionKey(title string) string {
+	words := strings.FieldsFunc(title, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var key strings.Builder
+	for i, word := range words {
+		if i == 0 {
+			key.WriteString(strings.ToLower(word[:1]) + word[1:])
+		} else {
+			key.WriteString(strings.ToUpper(word[:1]) + word[1:])
+		}
+	}
+	return key.String()
+}
+
+// parseTroubleshoot splits `devpod troubleshoot` output into named sections.
+// JSON output is split by its top-level keys; text output by its headers,
+// with anything before the first header under "summary".
+func parseTroubleshoot(output []byte) map[string]json.RawMessage {
+	sections := make(map[string]json.RawMessage)
+	if trimmed := bytes.TrimSpace(output); len(trimmed) > 0 && trimmed[0] == '{' {
+		if err := json.Unmarshal(trimmed, &sections); err == nil {
+			return sections
+		}
+	}
+
+	key := "summary"
+	var body []string
+	flush := func() {
+		text := strings.TrimSpace(strings.Join(body, "\n"))
+		if text != "" {
+			encoded, _ := json.Marshal(text)
+			sections[key] = encoded
+		}
+		body = nil
+	}
+	for _, line := range strings.Split(string(output), "\n") {
+		if match := troubleshootHeader.FindStringSubmatch(strings.TrimSpace(line)); match != nil {
+			if title := match[1] + match[2] + match[3]; sectionKey(title) != "" {
+				flush()
+				key = sectionKey(title)
+				continue
+			}
+		}
+		body = append(body, line)
+	}
+	flush()
+	return sections
+}
+
+// limitSections selects the wanted sections, all when wanted is empty, and
+// cuts each to maxBytes. JSON sections that fit are returned as structured
+// values; cut sections become strings. It returns the sections and the names
+// of the ones that were cut.
+func limitSections(sections map[string]json.RawMessage, wanted []string, maxBytes int) (map[string]interface{}, []string) {
+	if len(wanted) == 0 {
+		for name := range sections {
+			wanted = append(wanted, name)
+		}
+	}
+
+	limited := make(map[string]interface{}, len(wanted))
+	truncated := []string{}
+	for _, name := range wanted {
+		raw, ok := sections[name]
+		if !ok {
+			continue
+		}
+		var value interface{}
+		if err := json.Unmarshal(raw, &value); err != nil {
+			value = string(raw)
+		}
+		text, isString := value.(string)
+		if !isString && len(raw) <= maxBytes {
+			limited[name] = value
+			continue
+		}
+		if !isString {
+			text = string(raw)
+		}
+		if len(text) > maxBytes {
+			cut := maxBytes
+			for cut > 0 && !utf8.RuneStart(text[cut]) {
+				cut--
+			}
+			text = text[:cut] + fmt.Sprintf("\n... [%d bytes truncated]", len(text)-cut)
+			truncated = append(truncated, name)
+		}
+		limited[name] = text
+	}
+	sort.Strings(truncated)
+	return limited, truncated
+}
+
+// registerTroubleshootTools registers the tool that troubleshoots workspaces
+func (s *Server) registerTroubleshootTools() {
+	// Troubleshoot workspace
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_troubleshoot",
+		Description: "Collect devpod troubleshoot diagnostics for a workspace as size-limited JSON sections",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "The name of the workspace",
+				},
+				"sections": map[string]interface{}{
+					"type":        "array",
+					"items":       map[string]interface{}{"type": "string"},
+					"description": "Only return these sections; the result lists all available ones (optional)",
+				},
+				"maxBytes": map[string]interface{}{
+					"type":        "integer",
+					"description": "Maximum size of each section in bytes (default: 8192)",
+				},
+			},
+			"required": []string{"name"},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var troubleshootParams struct {
+			Name     string   `json:"name"`
+			Sections []string `json:"sections,omitempty"`
+			MaxBytes int      `json:"maxBytes,omitempty"`
+		}
+
+		if err := json.Unmarshal(params, &troubleshootParams); err != nil {
+			return nil, mcp.NewInvalidParamsError("Invalid troubleshoot parameters")
+		}
+
+		if troubleshootParams.Name == "" {
+			return nil, mcp.NewInvalidParamsError("Workspace name is required")
+		}
+		if troubleshootParams.MaxBytes <= 0 {
+			troubleshootParams.MaxBytes = defaultSectionBytes
+		}
+
+		// troubleshoot exits non-zero for broken workspaces, which is when it matters most
+		output, err := s.combinedOutput(ctx, []string{"troubleshoot", troubleshootParams.Name})
+		if err != nil && len(bytes.TrimSpace(output)) == 0 {
+			return nil, newDevPodError("failed to troubleshoot workspace", err, output)
+		}
+
+		sections := parseTroubleshoot(output)
+		available := make([]string, 0, len(sections))
+		for name := range sections {
+			available = append(available, name)
+		}
+		sort.Strings(available)
+
+		limited, truncated := limitSections(sections, troubleshootParams.Sections, troubleshootParams.MaxBytes)
+		result := map[string]interface{}{
+			"name":              troubleshootParams.Name,
+			"sections":          limited,
+			"availableSections": available,
+			"truncated":         truncated,
+			"totalBytes":        len(output),
+		}
+		if err != nil {
+			category, _ := categorizeFailure(err, output)
+			result["error"] = err.Error()
+			result["category"] = category
+		}
+		return result, nil
+	})
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParseTroubleshootText(t *testing.T) {
+	output := "devpod troubleshoot\n## Workspace Status\nstate: Busy\n=== Agent Logs ===\nline 1\nline 2\n"
+
+	sections := parseTroubleshoot([]byte(output))
+	for _, key := range []string{"summary", "workspaceStatus", "agentLogs"} {
+		if _, ok := sections[key]; !ok {
+			t.Errorf("Expected section %s, got %v", key, sections)
+		}
+	}
+	var logs string
+	if err := json.Unmarshal(sections["agentLogs"], &logs); err != nil || logs != "line 1\nline 2" {
+		t.Errorf("Unexpected agent logs %q", logs)
+	}
+}
+
+func TestLimitSections(t *testing.T) {
+	sections := parseTroubleshoot([]byte(`{"workspaceInfo":{"id":"api"},"daemonLogs":"` + strings.Repeat("x", 100) + `"}`))
+
+	limited, truncated := limitSections(sections, nil, 32)
+	if info, ok := limited["workspaceInfo"].(map[string]interface{}); !ok || info["id"] != "api" {
+		t.Errorf("Expected small JSON sections to stay structured, got %v", limited["workspaceInfo"])
+	}
+	if len(truncated) != 1 || truncated[0] != "daemonLogs" {
+		t.Errorf("Expected daemonLogs to be truncated, got %v", truncated)
+	}
+	if logs := limited["daemonLogs"].(string); !strings.HasPrefix(logs, strings.Repeat("x", 32)+"\n... [68 bytes truncated]") {
+		t.Errorf("Unexpected truncated section %q", logs)
+	}
+
+	limited, _ = limitSections(sections, []string{"workspaceInfo", "missing"}, 32)
+	if len(limited) != 1 {
+		t.Errorf("Expected only the wanted sections, got %v", limited)
+	}
+}
+
+func TestTroubleshootKeepsOutputOfFailures(t *testing.T) {
+	runner := &fakeRunner{failures: map[string]string{
+		"troubleshoot api": "## Workspace Status\nstate: NotFound\n",
+	}}
+	s := newTestServer(t, runner)
+
+	result, err := s.MCP().GetHandler("devpod_troubleshoot")(context.Background(), json.RawMessage(`{"name":"api"}`))
+	if err != nil {
+		t.Fatalf("devpod_troubleshoot failed: %v", err)
+	}
+	troubleshoot := result.(map[string]interface{})
+	sections := troubleshoot["sections"].(map[string]interface{})
+	if sections["workspaceStatus"] != "state: NotFound" {
+		t.Errorf("Unexpected sections %v", sections)
+	}
+	if troubleshoot["error"] == nil {
+		t.Error("Expected the troubleshoot error to be reported")
+	}
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sizePattern matches human-readable sizes such as "1.2GB", "512 MiB" or "100K"
+var sizePattern = regexp.MustCompile(`^(?i)\s*([0-9]+(?:\.[0-9]+)?)\s*([kmgtp]?)(i?)(b?)\s*$`)
+
+// relativePattern matches relative times such as "2 minutes ago" or "an hour ago"
+var relativePattern = regexp.MustCompile(`^(?i)\s*(a|an|[0-9]+(?:\.[0-9]+)?)\s*(seconds?|secs?|minutes?|mins?|hours?|hrs?|days?|weeks?)\s*(ago)?\s*$`)
+
+// unitSeconds maps relative time units to their length in seconds
+var unitSeconds = map[string]float64{
+	"sec": 1, "secs": 1, "second": 1, "seconds": 1,
+	"min": 60, "mins": 60, "minute": 60, "minutes": 60,
+	"hr": 3600, "hrs": 3600, "hour": 3600, "hours": 3600,
+	"day": 86400, "days": 86400,
+	"week": 604800, "weeks": 604800,
+}
+
+// parseByteSize converts a human-readable size to bytes. Decimal units
+// (KB, MB, ...) use powers of 1000 and binary units (KiB, MiB, ...) powers of
+// 1024; a bare K/M/G suffix is treated as binary, as docker reports it.
+func parseByteSize(s string) (int64, bool) {
+	m := sizePattern.FindStringSubmatch(s)
+	if m == nil || (m[2] == "" && m[4] == "") {
+		// A bare number is not a size
+		return 0, false
+	}
+
+	value, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, false
+	}
+
+	exponent := 0
+	if m[2] != "" {
+		exponent = strings.Index("kmgtp", strings.ToLower(m[2])) + 1
+	}
+	base := 1000.0
+	if m[3] != "" || m[4] == "" {
+		base = 1024
+	}
+	return int64(math.Round(value * math.Pow(base, float64(exponent)))), true
+}
+
+// parseDurationSeconds converts a duration or relative time ("90s", "1h30m",
+// "2 minutes", "an hour ago") to whole seconds
+func parseDurationSeconds(s string) (int64, bool) {
+	if trimmed := strings.TrimSpace(s); trimmed != "0" {
+		if d, err := time.ParseDuration(trimmed); err == nil {
+			return int64(d / time.Second), true
+		}
+	}
+
+	m := relativePattern.FindStringSubmatch(s)
+	if m == nil {
+		return 0, false
+	}
+
+	value := 1.0
+	if n := strings.ToLower(m[1]); n != "a" && n != "an" {
+		v, err := strconv.ParseFloat(n, 64)
+		if err != nil {
+			return 0, false
+		}
+		value = v
+	}
+	return int64(value * unitSeconds[strings.ToLower(m[2])]), true
+}
+
+// secondsSince returns the whole seconds elapsed since an RFC 3339 timestamp
+func secondsSince(timestamp string, now time.Time) (int64, bool) {
+	if timestamp == "" {
+		return 0, false
+	}
+	t, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil || t.IsZero() {
+		return 0, false
+	}
+	seconds := int64(now.Sub(t) / time.Second)
+	if seconds < 0 {
+		seconds = 0
+	}
+	return seconds, true
+}
+
+// durationMs returns the milliseconds elapsed since start
+func durationMs(start time.Time) int64 {
+	return int64(time.Since(start) / time.Millisecond)
+}
+
+// normalizeFields adds numeric companions to human-readable string values in
+// devpod output: sizes gain a "<key>Bytes" field and durations or relative
+// times a "<key>Seconds" field. Nested objects are normalized recursively and
+// existing keys are never overwritten.
+func normalizeFields(fields map[string]interface{}) {
+	for key, value := range fields {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			normalizeFields(v)
+		case []interface{}:
+			for _, item := range v {
+				if nested, ok := item.(map[string]interface{}); ok {
+					normalizeFields(nested)
+				}
+			}
+		case string:
+			if seconds, ok := parseDurationSeconds(v); ok {
+				setIfAbsent(fields, key+"Seconds", seconds)
+			} else if bytes, ok := parseByteSize(v); ok {
+				setIfAbsent(fields, key+"Bytes", bytes)
+			} else if seconds, ok := secondsSince(v, time.Now()); ok {
+				setIfAbsent(fields, key+"AgeSeconds", seconds)
+			}
+		}
+	}
+}
+
+// setIfAbsent sets fields[key] unless the key is already present
+func setIfAbsent(fields map[string]interface{}, key string, value interface{}) {
+	if _, exists := fields[key]; !exists {
+		fields[key] = value
+	}
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseByteSize(t *testing.T) {
+	tests := map[string]int64{
+		"1.2GB":   1200000000,
+		"512 MiB": 512 * 1024 * 1024,
+		"100K":    100 * 1024,
+		"42B":     42,
+	}
+	for input, want := range tests {
+		got, ok := parseByteSize(input)
+		if !ok || got != want {
+			t.Errorf("parseByteSize(%q) = %d, %v; want %d", input, got, ok, want)
+		}
+	}
+
+	for _, input := range []string{"42", "Running", ""} {
+		if _, ok := parseByteSize(input); ok {
+			t.Errorf("parseByteSize(%q) unexpectedly succeeded", input)
+		}
+	}
+}
+
+func TestParseDurationSeconds(t *testing.T) {
+	tests := map[string]int64{
+		"1h30m":         5400,
+		"2 minutes ago": 120,
+		"an hour ago":   3600,
+		"3 days":        259200,
+	}
+	for input, want := range tests {
+		got, ok := parseDurationSeconds(input)
+		if !ok || got != want {
+			t.Errorf("parseDurationSeconds(%q) = %d, %v; want %d", input, got, ok, want)
+		}
+	}
+
+	if _, ok := parseDurationSeconds("512M"); ok {
+		t.Error("Expected sizes not to parse as durations")
+	}
+}
+
+func TestNormalizeFields(t *testing.T) {
+	fields := map[string]interface{}{
+		"state":  "Running",
+		"uptime": "2 minutes",
+		"disk":   map[string]interface{}{"size": "1.2GB"},
+	}
+	normalizeFields(fields)
+
+	if fields["uptimeSeconds"] != int64(120) {
+		t.Errorf("Expected uptimeSeconds 120, got %v", fields["uptimeSeconds"])
+	}
+	if fields["disk"].(map[string]interface{})["sizeBytes"] != int64(1200000000) {
+		t.Errorf("Expected nested sizeBytes, got %v", fields["disk"])
+	}
+	if _, ok := fields["stateSeconds"]; ok {
+		t.Error("Expected plain strings to be left alone")
+	}
+}
+
+func TestNormalizeWorkspaceTimes(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	w := DevPodWorkspace{
+		CreationTimestamp: "2024-01-01T10:00:00Z",
+		LastUsed:          "2024-01-01T11:59:00Z",
+	}
+	w.normalizeTimes(now)
+
+	if w.AgeSeconds == nil || *w.AgeSeconds != 7200 {
+		t.Errorf("Unexpected ageSeconds: %v", w.AgeSeconds)
+	}
+	if w.IdleSeconds == nil || *w.IdleSeconds != 60 {
+		t.Errorf("Unexpected idleSeconds: %v", w.IdleSeconds)
+	}
+}
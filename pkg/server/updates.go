@@ -0,0 +1,108 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// providerUpdate is the update state of an installed provider
+type providerUpdate struct {
+	Name           string `json:"name"`
+	CurrentVersion string `json:"currentVersion,omitempty"`
+	LatestVersion  string `json:"latestVersion,omitempty"`
+	// Repository is the GitHub repository the provider is released from
+	Repository      string `json:"repository,omitempty"`
+	UpdateAvailable bool   `json:"updateAvailable"`
+	ReleaseURL      string `json:"releaseUrl,omitempty"`
+	Error           string `json:"error,omitempty"`
+}
+
+// installedProviders returns the installed providers by name
+func (s *Server) installedProviders(ctx context.Context) (map[string]DevPodProvider, error) {
+	output, err := s.output(ctx, []string{"provider", "list", "--output", "json"})
+	if err != nil {
+		return nil, err
+	}
+	var providers map[string]DevPodProvider
+	if err := json.Unmarshal(output, &providers); err != nil {
+		return nil, fmt.Errorf("failed to parse provider list: %w", err)
+	}
+	return providers, nil
+}
+
+// providerRepository returns the GitHub repository a provider was installed
+// from, falling back to the provider index for providers added by their
+// short name. Providers built into devpod and ones installed from a URL or
+// file have none.
+func providerRepository(name string, provider DevPodProvider) string {
+	source := provider.Config.Source
+	if internal, _ := source["internal"].(bool); internal {
+		return ""
+	}
+	if repository, _ := source["github"].(string); repository != "" {
+		return strings.TrimPrefix(repository, "github.com/")
+	}
+	if source["url"] != nil || source["file"] != nil {
+		return ""
+	}
+	for _, entry := range providerIndex {
+		// docker ships with devpod itself
+		if entry.Name == name && entry.Name != "docker" {
+			return entry.Repository
+		}
+	}
+	return ""
+}
+
+// pinnedSource returns the source `devpod provider update` installs a
+// specific version of a provider from
+func pinnedSource(name string, provider DevPodProvider, version string) (string, error) {
+	repository := providerRepository(name, provider)
+	if repository == "" {
+		return "", fmt.Errorf("provider %s is not released on GitHub, so it cannot be pinned to a version", name)
+	}
+	return repository + "@" + version, nil
+}
+
+// checkProviderUpdates compares the installed versions of providers with
+// their latest GitHub releases. Releases are looked up concurrently.
+func (s *Server) checkProviderUpdates(ctx context.Context, providers map[string]DevPodProvider) []providerUpdate {
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	updates := make([]providerUpdate, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		provider := providers[name]
+		updates[i] = providerUpdate{
+			Name:           name,
+			CurrentVersion: provider.Config.Version,
+			Repository:     providerRepository(name, provider),
+		}
+		if updates[i].Repository == "" {
+			updates[i].Error = "not released on GitHub; built-in providers are updated with devpod"
+			continue
+		}
+		wg.Add(1)
+		go func(update *providerUpdate) {
+			defer wg.Done()
+			release, err := s.latestRelease(ctx, update.Repository)
+			if err != nil {
+				update.Error = fmt.Sprintf("failed to look up latest release: %v", err)
+				return
+			}
+			update.LatestVersion = release.TagName
+			update.ReleaseURL = release.HTMLURL
+			update.UpdateAvailable = update.CurrentVersion != "" && newerVersion(release.TagName, update.CurrentVersion)
+		}(&updates[i])
+	}
+	wg.Wait()
+	return updates
+}
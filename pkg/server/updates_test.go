@@ -0,0 +1,73 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCheckProviderUpdates(t *testing.T) {
+	index := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"tag_name":"v0.0.9","html_url":"https://example.com/release"}`)
+	}))
+	defer index.Close()
+
+	runner := &fakeRunner{outputs: map[string]string{
+		"provider list --output json": `{
+			"aws":{"config":{"name":"aws","version":"v0.0.7","source":{"github":"loft-sh/devpod-provider-aws"}}},
+			"gcloud":{"config":{"name":"gcloud","version":"v0.0.9"}},
+			"docker":{"config":{"name":"docker","version":"v0.0.1","source":{"internal":true}}}
+		}`,
+	}}
+	s := newTestServer(t, runner)
+	s.opts.ProviderIndexURL = index.URL
+
+	result, err := s.MCP().GetHandler("devpod_updateProvider")(context.Background(), json.RawMessage(`{"checkOnly":true}`))
+	if err != nil {
+		t.Fatalf("devpod_updateProvider failed: %v", err)
+	}
+	updates := result.(map[string]interface{})["providers"].([]providerUpdate)
+	if len(updates) != 3 || result.(map[string]interface{})["available"] != 1 {
+		t.Fatalf("Unexpected updates %+v", result)
+	}
+	if aws := updates[0]; aws.Name != "aws" || !aws.UpdateAvailable || aws.LatestVersion != "v0.0.9" {
+		t.Errorf("Unexpected aws update %+v", aws)
+	}
+	if docker := updates[1]; docker.UpdateAvailable || docker.Error == "" {
+		t.Errorf("Expected docker to be updated with devpod, got %+v", docker)
+	}
+	if gcloud := updates[2]; gcloud.UpdateAvailable || gcloud.Repository != "loft-sh/devpod-provider-gcloud" {
+		t.Errorf("Unexpected gcloud update %+v", gcloud)
+	}
+}
+
+func TestUpdateProviderPinsVersion(t *testing.T) {
+	runner := &fakeRunner{outputs: map[string]string{
+		"provider list --output json": `{"aws":{"config":{"name":"aws","version":"v0.0.7","source":{"github":"loft-sh/devpod-provider-aws"}}},"docker":{"config":{"source":{"internal":true}}}}`,
+	}}
+	s := newTestServer(t, runner)
+	update := s.MCP().GetHandler("devpod_updateProvider")
+
+	if _, err := update(context.Background(), json.RawMessage(`{"name":"aws","version":"v0.0.8"}`)); err != nil {
+		t.Fatalf("devpod_updateProvider failed: %v", err)
+	}
+	var updated bool
+	for _, call := range runner.calls {
+		if strings.Join(call, " ") == "provider update aws loft-sh/devpod-provider-aws@v0.0.8" {
+			updated = true
+		}
+	}
+	if !updated {
+		t.Errorf("Expected the pinned source to be installed, got calls %v", runner.calls)
+	}
+
+	for _, params := range []string{`{"name":"docker","version":"v1"}`, `{"name":"missing"}`, `{}`} {
+		if _, err := update(context.Background(), json.RawMessage(params)); err == nil {
+			t.Errorf("Expected %s to be rejected", params)
+		}
+	}
+}
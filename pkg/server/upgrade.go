@@ -0,0 +1,461 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+// defaultReleaseURL is the GitHub API endpoint for the latest devpod release
+const defaultReleaseURL = "https://api.github.com/repos/loft-sh/devpod/releases"
+
+// maxSandboxFileSize skips large files such as agent binaries when copying
+// the devpod home into the sandbox
+const maxSandboxFileSize = 10 << 20
+
+// devpodRelease is the subset of a GitHub release used by the upgrade checker
+type devpodRelease struct {
+	TagName     string `json:"tag_name"`
+	HTMLURL     string `json:"html_url"`
+	PublishedAt string `json:"published_at,omitempty"`
+	Assets      []struct {
+		Name               string `json:"name"`
+		BrowserDownloadURL string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+// upgradeCheck is the outcome of one compatibility check
+type upgradeCheck struct {
+	Name   string `json:"name"`
+	Passed bool   `json:"passed"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// upgradeReport summarizes whether the devpod CLI can be upgraded safely
+type upgradeReport struct {
+	CurrentVersion  string         `json:"currentVersion"`
+	LatestVersion   string         `json:"latestVersion"`
+	ReleaseURL      string         `json:"releaseUrl,omitempty"`
+	UpdateAvailable bool           `json:"updateAvailable"`
+	SafeToUpgrade   bool           `json:"safeToUpgrade"`
+	Checks          []upgradeCheck `json:"checks"`
+}
+
+// parseVersion splits a version such as "v0.5.20" or "0.6.0-beta.1" into its
+// numeric major, minor and patch parts
+func parseVersion(version string) ([3]int, bool) {
+	var parts [3]int
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	if i := strings.IndexAny(version, "-+"); i >= 0 {
+		version = version[:i]
+	}
+
+	fields := strings.Split(version, ".")
+	if len(fields) == 0 || len(fields) > 3 {
+		return parts, false
+	}
+	for i, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil {
+			return parts, false
+		}
+		parts[i] = n
+	}
+	return parts, true
+}
+
+// newerVersion reports whether candidate is a later release than current
+func newerVersion(candidate, current string) bool {
+	c, ok1 := parseVersion(candidate)
+	v, ok2 := parseVersion(current)
+	if !ok1 || !ok2 {
+		return candidate != current
+	}
+	for i := range c {
+		if c[i] != v[i] {
+			return c[i] > v[i]
+		}
+	}
+	return false
+}
+
+// releaseAssetName returns the devpod binary name published for this platform
+func releaseAssetName() string {
+	name := fmt.Sprintf("devpod-%s-%s", runtime.GOOS, runtime.GOARCH)
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// fetchRelease returns the latest devpod release, or the release with the
+// given tag
+func (s *Server) fetchRelease(ctx context.Context, tag string) (*devpodRelease, error) {
+	url := s.opts.ReleaseURL + "/latest"
+	if tag != "" {
+		url = s.opts.ReleaseURL + "/tags/" + tag
+	}
+
+	var release devpodRelease
+	if err := getGitHubJSON(ctx, url, &release); err != nil {
+		return nil, fmt.Errorf("failed to fetch release: %w", err)
+	}
+	return &release, nil
+}
+
+// getGitHubJSON fetches a GitHub API URL and decodes the JSON response into v.
+// GITHUB_TOKEN is sent when set to raise the anonymous rate limit.
+func getGitHubJSON(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s", resp.Status)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
+
+// downloadFile saves url to path with the given permissions
+func downloadFile(ctx context.Context, url, path string, perm os.FileMode) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download failed: %s", resp.Status)
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// devpodHome returns the devpod home directory used by the current CLI
+func devpodHome() string {
+	if home := os.Getenv("DEVPOD_HOME"); home != "" {
+		return home
+	}
+	userHome, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(userHome, ".devpod")
+}
+
+// copySandboxHome copies the devpod home into dst so a candidate CLI can read
+// current state without migrating or modifying it. Large files are skipped.
+func copySandboxHome(src, dst string) error {
+	if src == "" {
+		return nil
+	}
+	if _, err := os.Stat(src); os.IsNotExist(err) {
+		return nil
+	}
+
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		if d.IsDir() {
+			return os.MkdirAll(target, 0o700)
+		}
+		info, err := d.Info()
+		if err != nil || !info.Mode().IsRegular() || info.Size() > maxSandboxFileSize {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, info.Mode().Perm())
+	})
+}
+
+// sortedKeys returns the keys of a set in order
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// compareSets describes the differences between two name sets, or "" if equal
+func compareSets(want, got map[string]bool) string {
+	var missing, extra []string
+	for name := range want {
+		if !got[name] {
+			missing = append(missing, name)
+		}
+	}
+	for name := range got {
+		if !want[name] {
+			extra = append(extra, name)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(extra)
+
+	var problems []string
+	if len(missing) > 0 {
+		problems = append(problems, "missing "+strings.Join(missing, ", "))
+	}
+	if len(extra) > 0 {
+		problems = append(problems, "unexpected "+strings.Join(extra, ", "))
+	}
+	return strings.Join(problems, "; ")
+}
+
+// workspaceIDs returns the IDs in a `devpod list --output json` result
+func workspaceIDs(output []byte) (map[string]bool, error) {
+	var workspaces []DevPodWorkspace
+	if err := json.Unmarshal(output, &workspaces); err != nil {
+		return nil, err
+	}
+	ids := make(map[string]bool, len(workspaces))
+	for _, workspace := range workspaces {
+		ids[workspace.ID] = true
+	}
+	return ids, nil
+}
+
+// providerNames returns the names in a `devpod provider list --output json` result
+func providerNames(output []byte) (map[string]bool, error) {
+	var providers map[string]DevPodProvider
+	if err := json.Unmarshal(output, &providers); err != nil {
+		return nil, err
+	}
+	names := make(map[string]bool, len(providers))
+	for name := range providers {
+		names[name] = true
+	}
+	return names, nil
+}
+
+// runCandidateChecks runs the read-only commands the server depends on with
+// the candidate CLI, using the extra environment env, and compares the results
+// with the current CLI's output
+func (s *Server) runCandidateChecks(ctx context.Context, candidate Runner, env []string) []upgradeCheck {
+	var checks []upgradeCheck
+	candidateCtx := WithCommandEnv(ctx, env)
+	run := func(args ...string) ([]byte, error) {
+		stdout, stderr, err := candidate.Run(candidateCtx, args)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %s", err, strings.TrimSpace(string(stderr)))
+		}
+		return stdout, nil
+	}
+
+	// Workspace list: must parse and report the same workspaces
+	var ids []string
+	check := upgradeCheck{Name: "list"}
+	if output, err := run("list", "--output", "json"); err != nil {
+		check.Detail = err.Error()
+	} else if got, err := workspaceIDs(output); err != nil {
+		check.Detail = "output is no longer compatible: " + err.Error()
+	} else if current, err := s.listWorkspaces(ctx); err != nil {
+		check.Detail = "current CLI failed: " + err.Error()
+	} else {
+		want := make(map[string]bool, len(current))
+		for _, workspace := range current {
+			want[workspace.ID] = true
+		}
+		check.Detail = compareSets(want, got)
+		check.Passed = check.Detail == ""
+		if check.Passed {
+			check.Detail = fmt.Sprintf("%d workspace(s) match", len(got))
+		}
+		ids = sortedKeys(got)
+	}
+	checks = append(checks, check)
+
+	// Status: the first workspace must still report a state
+	if len(ids) > 0 {
+		check = upgradeCheck{Name: "status"}
+		var fields map[string]interface{}
+		if output, err := run("status", ids[0], "--output", "json"); err != nil {
+			check.Detail = err.Error()
+		} else if err := json.Unmarshal(output, &fields); err != nil {
+			check.Detail = "output is no longer compatible: " + err.Error()
+		} else if state, _ := fields["state"].(string); state == "" {
+			check.Detail = "output has no state field"
+		} else {
+			check.Passed = true
+			check.Detail = fmt.Sprintf("%s is %s", ids[0], state)
+		}
+		checks = append(checks, check)
+	}
+
+	// Provider list: must parse and report the same providers
+	check = upgradeCheck{Name: "provider list"}
+	if output, err := run("provider", "list", "--output", "json"); err != nil {
+		check.Detail = err.Error()
+	} else if got, err := providerNames(output); err != nil {
+		check.Detail = "output is no longer compatible: " + err.Error()
+	} else if currentOutput, err := s.output(ctx, []string{"provider", "list", "--output", "json"}); err != nil {
+		check.Detail = "current CLI failed: " + err.Error()
+	} else if want, err := providerNames(currentOutput); err != nil {
+		check.Detail = "current CLI output could not be parsed: " + err.Error()
+	} else {
+		check.Detail = compareSets(want, got)
+		check.Passed = check.Detail == ""
+		if check.Passed {
+			check.Detail = fmt.Sprintf("%d provider(s) match", len(got))
+		}
+	}
+	return append(checks, check)
+}
+
+// checkUpgrade compares the installed devpod CLI with a release. When the
+// release is newer it downloads it and runs the read-only commands the server
+// relies on against a copy of the current devpod home.
+func (s *Server) checkUpgrade(ctx context.Context, tag string) (*upgradeReport, error) {
+	currentOutput, err := s.output(ctx, []string{"version"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current devpod version: %w", err)
+	}
+	release, err := s.fetchRelease(ctx, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &upgradeReport{
+		CurrentVersion: strings.TrimSpace(string(currentOutput)),
+		LatestVersion:  release.TagName,
+		ReleaseURL:     release.HTMLURL,
+		Checks:         []upgradeCheck{},
+	}
+	report.UpdateAvailable = newerVersion(report.LatestVersion, report.CurrentVersion)
+	if !report.UpdateAvailable && tag == "" {
+		return report, nil
+	}
+
+	assetURL := ""
+	for _, asset := range release.Assets {
+		if asset.Name == releaseAssetName() {
+			assetURL = asset.BrowserDownloadURL
+		}
+	}
+	if assetURL == "" {
+		report.Checks = append(report.Checks, upgradeCheck{Name: "download", Detail: "no " + releaseAssetName() + " asset in the release"})
+		return report, nil
+	}
+
+	sandbox, err := os.MkdirTemp("", "devpod-upgrade-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sandbox: %w", err)
+	}
+	defer os.RemoveAll(sandbox)
+
+	binary := filepath.Join(sandbox, releaseAssetName())
+	if err := downloadFile(ctx, assetURL, binary, 0o755); err != nil {
+		report.Checks = append(report.Checks, upgradeCheck{Name: "download", Detail: err.Error()})
+		return report, nil
+	}
+	report.Checks = append(report.Checks, upgradeCheck{Name: "download", Passed: true, Detail: assetURL})
+
+	home := filepath.Join(sandbox, "home")
+	if err := copySandboxHome(devpodHome(), home); err != nil {
+		return nil, fmt.Errorf("failed to copy devpod home into sandbox: %w", err)
+	}
+
+	report.Checks = append(report.Checks, s.runCandidateChecks(ctx, &ExecRunner{Path: binary}, []string{"DEVPOD_HOME=" + home})...)
+
+	report.SafeToUpgrade = true
+	for _, check := range report.Checks {
+		if !check.Passed {
+			report.SafeToUpgrade = false
+		}
+	}
+	return report, nil
+}
+
+// registerUpgradeTools registers the tool that checks devpod CLI upgrades
+func (s *Server) registerUpgradeTools() {
+	// Check whether a newer devpod CLI is safe to install
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_checkUpgrade",
+		Description: "Check for a newer DevPod CLI release and test it against current workspaces in a sandbox before upgrading",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"version": map[string]interface{}{
+					"type":        "string",
+					"description": "Release tag to test, e.g. v0.6.0 (optional, defaults to the latest release)",
+				},
+			},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var upgradeParams struct {
+			Version string `json:"version,omitempty"`
+		}
+
+		if len(params) > 0 {
+			if err := json.Unmarshal(params, &upgradeParams); err != nil {
+				return nil, mcp.NewInvalidParamsError("Invalid check upgrade parameters")
+			}
+		}
+
+		report, err := s.checkUpgrade(ctx, upgradeParams.Version)
+		if err != nil {
+			return nil, fmt.Errorf("upgrade check failed: %w", err)
+		}
+
+		message := fmt.Sprintf("devpod %s is up to date", report.CurrentVersion)
+		switch {
+		case report.SafeToUpgrade:
+			message = fmt.Sprintf("devpod %s passed all compatibility checks and is safe to install", report.LatestVersion)
+		case len(report.Checks) > 0:
+			message = fmt.Sprintf("devpod %s failed compatibility checks; do not upgrade yet", report.LatestVersion)
+		}
+
+		return map[string]interface{}{
+			"report":  report,
+			"message": message,
+		}, nil
+	})
+}
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"context"
+	"testing"
+)
+
+func TestNewerVersion(t *testing.T) {
+	tests := []struct {
+		candidate, current string
+		want               bool
+	}{
+		{"v0.6.0", "v0.5.20", true},
+		{"v0.5.20", "0.5.20", false},
+		{"v0.5.3", "v0.5.20", false},
+		{"v1.0.0-beta.1", "v0.9.9", true},
+	}
+	for _, tt := range tests {
+		if got := newerVersion(tt.candidate, tt.current); got != tt.want {
+			t.Errorf("newerVersion(%q, %q) = %v; want %v", tt.candidate, tt.current, got, tt.want)
+		}
+	}
+}
+
+func TestRunCandidateChecks(t *testing.T) {
+	current := &fakeRunner{outputs: map[string]string{
+		"list --output json":          `[{"id":"ws1"}]`,
+		"provider list --output json": `{"docker":{}}`,
+	}}
+	s := newTestServer(t, current)
+
+	compatible := &fakeRunner{outputs: map[string]string{
+		"list --output json":          `[{"id":"ws1"}]`,
+		"status ws1 --output json":    `{"state":"Running"}`,
+		"provider list --output json": `{"docker":{}}`,
+	}}
+	for _, check := range s.runCandidateChecks(context.Background(), compatible, nil) {
+		if !check.Passed {
+			t.Errorf("Expected check %s to pass: %s", check.Name, check.Detail)
+		}
+	}
+
+	broken := &fakeRunner{outputs: map[string]string{
+		"list --output json":          `{"workspaces":[{"id":"ws1"}]}`,
+		"provider list --output json": `{"docker":{}}`,
+	}}
+	checks := s.runCandidateChecks(context.Background(), broken, nil)
+	if len(checks) == 0 || checks[0].Name != "list" || checks[0].Passed {
+		t.Errorf("Expected the list check to fail on a changed output format, got %+v", checks)
+	}
+}
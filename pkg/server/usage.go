@@ -0,0 +1,230 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+// machineTypeOptions are the provider options that name a cloud instance type
+var machineTypeOptions = []string{
+	"AWS_INSTANCE_TYPE",
+	"GCLOUD_MACHINE_TYPE",
+	"AZURE_VM_SIZE",
+	"AZURE_INSTANCE_SIZE",
+	"DIGITALOCEAN_DROPLET_SIZE",
+	"DROPLET_SIZE",
+	"HETZNER_SERVER_TYPE",
+	"INSTANCE_TYPE",
+	"MACHINE_TYPE",
+}
+
+// hourlyRates holds rough on-demand USD prices for common instance types.
+// They are only meant to rank workspaces by cost, not to match a bill.
+var hourlyRates = map[string]float64{
+	// AWS (us-east-1)
+	"t3.medium":   0.0416,
+	"t3.large":    0.0832,
+	"t3.xlarge":   0.1664,
+	"t3.2xlarge":  0.3328,
+	"m5.large":    0.096,
+	"m5.xlarge":   0.192,
+	"m5.2xlarge":  0.384,
+	"c5.xlarge":   0.17,
+	"c5.2xlarge":  0.34,
+	"g4dn.xlarge": 0.526,
+	// Google Cloud (us-central1)
+	"e2-standard-2": 0.067,
+	"e2-standard-4": 0.134,
+	"e2-standard-8": 0.268,
+	"n2-standard-4": 0.194,
+	"n2-standard-8": 0.389,
+	// Azure (East US)
+	"Standard_D2s_v3": 0.096,
+	"Standard_D4s_v3": 0.192,
+	"Standard_D8s_v3": 0.384,
+	// DigitalOcean
+	"s-2vcpu-4gb": 0.036,
+	"s-4vcpu-8gb": 0.071,
+	// Hetzner
+	"cx22": 0.006,
+	"cx32": 0.011,
+}
+
+// workspaceUsage is the usage and cost estimate reported for one workspace
+type workspaceUsage struct {
+	Name        string `json:"name"`
+	Provider    string `json:"provider"`
+	MachineType string `json:"machineType,omitempty"`
+	State       string `json:"state"`
+	// AgeSeconds and IdleSeconds are measured from creation and last use
+	AgeSeconds  *int64 `json:"ageSeconds,omitempty"`
+	IdleSeconds *int64 `json:"idleSeconds,omitempty"`
+	// HourlyRate is the rough on-demand USD price of the machine type
+	HourlyRate *float64 `json:"hourlyRate,omitempty"`
+	// EstimatedCost is an upper bound assuming the machine ran since creation
+	EstimatedCost *float64 `json:"estimatedCost,omitempty"`
+	// IdleCost is the estimated spend since the workspace was last used while running
+	IdleCost *float64 `json:"idleCost,omitempty"`
+	// RecommendStop is set for running workspaces idle beyond the threshold
+	RecommendStop bool   `json:"recommendStop"`
+	Reason        string `json:"reason,omitempty"`
+}
+
+// providerOptionValue returns a workspace provider option as a string. devpod
+// stores options either as plain values or as {"value": ...} objects.
+func providerOptionValue(options map[string]interface{}, name string) string {
+	switch v := options[name].(type) {
+	case string:
+		return v
+	case map[string]interface{}:
+		if value, ok := v["value"].(string); ok {
+			return value
+		}
+	}
+	return ""
+}
+
+// machineType returns the instance type configured for a workspace, if any
+func machineType(workspace DevPodWorkspace) string {
+	for _, name := range machineTypeOptions {
+		if value := providerOptionValue(workspace.Provider.Options, name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// roundCost rounds a USD amount to cents
+func roundCost(cost float64) *float64 {
+	rounded := math.Round(cost*100) / 100
+	return &rounded
+}
+
+// estimateUsage builds the usage report for a workspace in the given state
+func estimateUsage(workspace DevPodWorkspace, state string, idleThreshold time.Duration) workspaceUsage {
+	usage := workspaceUsage{
+		Name:        workspace.ID,
+		Provider:    workspace.Provider.Name,
+		MachineType: machineType(workspace),
+		State:       state,
+		AgeSeconds:  workspace.AgeSeconds,
+		IdleSeconds: workspace.IdleSeconds,
+	}
+
+	if rate, ok := hourlyRates[usage.MachineType]; ok {
+		usage.HourlyRate = &rate
+		if usage.AgeSeconds != nil {
+			usage.EstimatedCost = roundCost(rate * float64(*usage.AgeSeconds) / 3600)
+		}
+		if state == "Running" && usage.IdleSeconds != nil {
+			usage.IdleCost = roundCost(rate * float64(*usage.IdleSeconds) / 3600)
+		}
+	}
+
+	if state == "Running" && usage.IdleSeconds != nil && time.Duration(*usage.IdleSeconds)*time.Second >= idleThreshold {
+		usage.RecommendStop = true
+		usage.Reason = fmt.Sprintf("running but unused for %s", (time.Duration(*usage.IdleSeconds) * time.Second).String())
+	}
+	return usage
+}
+
+// estimateWorkspaceUsage reports usage for one workspace, or all when name is
+// empty, ordered with the most expensive idle workspaces first
+func (s *Server) estimateWorkspaceUsage(ctx context.Context, name string, idleThreshold time.Duration) ([]workspaceUsage, error) {
+	workspaces, err := s.listWorkspaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	usages := []workspaceUsage{}
+	for _, workspace := range workspaces {
+		if name != "" && workspace.ID != name {
+			continue
+		}
+		usages = append(usages, estimateUsage(workspace, s.getWorkspaceState(ctx, workspace.ID), idleThreshold))
+	}
+
+	sort.SliceStable(usages, func(i, j int) bool {
+		if usages[i].RecommendStop != usages[j].RecommendStop {
+			return usages[i].RecommendStop
+		}
+		return costOf(usages[i].IdleCost) > costOf(usages[j].IdleCost)
+	})
+	return usages, nil
+}
+
+// costOf dereferences an optional cost, treating unknown costs as zero
+func costOf(cost *float64) float64 {
+	if cost == nil {
+		return 0
+	}
+	return *cost
+}
+
+// registerUsageTools registers the tool that estimates workspace usage and cost
+func (s *Server) registerUsageTools() {
+	// Estimate workspace usage and cost
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_estimateUsage",
+		Description: "Report per-workspace uptime, machine type and a rough cost estimate, and recommend which workspaces to stop",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "The name of the workspace (optional, defaults to all)",
+				},
+				"idleThreshold": map[string]interface{}{
+					"type":        "string",
+					"description": "Recommend stopping running workspaces unused for this long (default: 1h)",
+				},
+			},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var usageParams struct {
+			Name          string `json:"name,omitempty"`
+			IdleThreshold string `json:"idleThreshold,omitempty"`
+		}
+
+		if len(params) > 0 {
+			if err := json.Unmarshal(params, &usageParams); err != nil {
+				return nil, mcp.NewInvalidParamsError("Invalid estimate usage parameters")
+			}
+		}
+
+		idleThreshold := time.Hour
+		if usageParams.IdleThreshold != "" {
+			d, err := time.ParseDuration(usageParams.IdleThreshold)
+			if err != nil || d <= 0 {
+				return nil, mcp.NewInvalidParamsError("idleThreshold must be a positive duration such as 30m or 2h")
+			}
+			idleThreshold = d
+		}
+
+		usages, err := s.estimateWorkspaceUsage(ctx, usageParams.Name, idleThreshold)
+		if err != nil {
+			return nil, newDevPodError("failed to estimate usage", err, nil)
+		}
+		if usageParams.Name != "" && len(usages) == 0 {
+			return nil, mcp.NewInvalidParamsError(fmt.Sprintf("Workspace %s not found", usageParams.Name))
+		}
+
+		recommended := 0
+		for _, usage := range usages {
+			if usage.RecommendStop {
+				recommended++
+			}
+		}
+
+		return map[string]interface{}{
+			"workspaces": usages,
+			"message":    fmt.Sprintf("%d workspace(s), %d recommended to stop", len(usages), recommended),
+		}, nil
+	})
+}
@@ -0,0 +1,34 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEstimateUsage(t *testing.T) {
+	age, idle := int64(10*3600), int64(2*3600)
+	workspace := DevPodWorkspace{
+		ID: "ws",
+		Provider: DevPodWorkspaceProvider{
+			Name:    "aws",
+			Options: map[string]interface{}{"AWS_INSTANCE_TYPE": map[string]interface{}{"value": "m5.xlarge"}},
+		},
+		AgeSeconds:  &age,
+		IdleSeconds: &idle,
+	}
+
+	usage := estimateUsage(workspace, "Running", time.Hour)
+	if usage.MachineType != "m5.xlarge" || usage.HourlyRate == nil {
+		t.Fatalf("Expected m5.xlarge pricing, got %+v", usage)
+	}
+	if *usage.EstimatedCost != 1.92 || *usage.IdleCost != 0.38 {
+		t.Errorf("Unexpected costs: estimated %v, idle %v", *usage.EstimatedCost, *usage.IdleCost)
+	}
+	if !usage.RecommendStop {
+		t.Error("Expected an idle running workspace to be recommended for stopping")
+	}
+
+	if usage := estimateUsage(workspace, "Stopped", time.Hour); usage.RecommendStop || usage.IdleCost != nil {
+		t.Errorf("Expected no recommendation or idle cost for a stopped workspace, got %+v", usage)
+	}
+}
@@ -0,0 +1,227 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+// User is an account of a server shared by several people. Each user's
+// devpod commands run with their own DEVPOD_HOME, so they see only their
+// own workspaces, providers and contexts.
+type User struct {
+	// Token authenticates the user as an HTTP bearer token
+	Token string `json:"token,omitempty"`
+	// DevPodHome is the user's DEVPOD_HOME (default: the user's directory
+	// under Options.UserHomeRoot)
+	DevPodHome string `json:"devpodHome,omitempty"`
+	// Context is the devpod context the user's commands run in unless the
+	// session selects another with devpod_setDefaults
+	Context string `json:"context,omitempty"`
+}
+
+// userName matches the names users may have, which become directory names
+var userName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._@-]*$`)
+
+// LoadUsers reads a JSON file mapping user names to their settings
+func LoadUsers(path string) (map[string]User, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var users map[string]User
+	if err := json.Unmarshal(data, &users); err != nil {
+		return nil, fmt.Errorf("failed to parse users file %s: %w", path, err)
+	}
+	tokens := make(map[string]string)
+	for name, user := range users {
+		if !userName.MatchString(name) {
+			return nil, fmt.Errorf("invalid user name %q in %s", name, path)
+		}
+		if user.Token == "" {
+			continue
+		}
+		if other, ok := tokens[user.Token]; ok {
+			return nil, fmt.Errorf("users %s and %s in %s have the same token", other, name, path)
+		}
+		tokens[user.Token] = name
+	}
+	return users, nil
+}
+
+// Authenticator returns a function identifying the user of an HTTP request
+// for httptransport.Options.Authenticate. Users are identified by their
+// bearer token or, when header is set, by that header as set by an
+// authenticating proxy in front of the server. Header identities must be
+// listed in users unless homeRoot gives every user a DEVPOD_HOME.
+func Authenticator(users map[string]User, header, homeRoot string) func(*http.Request) (string, error) {
+	return func(r *http.Request) (string, error) {
+		if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && token != "" {
+			for name, user := range users {
+				if user.Token != "" && subtle.ConstantTimeCompare([]byte(user.Token), []byte(token)) == 1 {
+					return name, nil
+				}
+			}
+			return "", errors.New("unknown bearer token")
+		}
+		if header == "" {
+			return "", errors.New("missing bearer token")
+		}
+		name := strings.TrimSpace(r.Header.Get(header))
+		if name == "" {
+			return "", fmt.Errorf("missing %s header", header)
+		}
+		if !userName.MatchString(name) {
+			return "", fmt.Errorf("invalid user name %q", name)
+		}
+		if _, ok := users[name]; !ok && homeRoot == "" {
+			return "", fmt.Errorf("unknown user %q", name)
+		}
+		return name, nil
+	}
+}
+
+// workspaceKey identifies a workspace of a user. Users of a multi-user
+// server have their own workspaces, which may share names.
+type workspaceKey struct {
+	user, workspace string
+}
+
+// userKey is the context key of the authenticated user a call belongs to
+type userKey struct{}
+
+// WithUser returns a context attributing devpod calls to an authenticated
+// user, whose DEVPOD_HOME and context they run with. Transports that
+// authenticate clients should set it on every request.
+func WithUser(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, userKey{}, name)
+}
+
+// UserName returns the authenticated user attached to ctx, or "" if none
+func UserName(ctx context.Context) string {
+	name, _ := ctx.Value(userKey{}).(string)
+	return name
+}
+
+// multiUser reports whether the server isolates users from each other
+func (s *Server) multiUser() bool {
+	return len(s.opts.Users) > 0 || s.opts.UserHomeRoot != ""
+}
+
+// stateUsers returns the users background work such as the scheduler and
+// the workspace watcher runs for: "" for the server's own devpod
+// configuration unless every call has a user, and the users with recorded
+// state
+func (s *Server) stateUsers() []string {
+	users := s.store.Users()
+	if !s.multiUser() {
+		users = append([]string{""}, users...)
+	}
+	return users
+}
+
+// user returns the settings of the user a call belongs to, with the default
+// DEVPOD_HOME filled in. Calls without a user get the zero User and run with
+// the server's own devpod configuration.
+func (s *Server) user(ctx context.Context) User {
+	name := UserName(ctx)
+	if name == "" {
+		return User{}
+	}
+	user := s.opts.Users[name]
+	if user.DevPodHome == "" && s.opts.UserHomeRoot != "" {
+		user.DevPodHome = filepath.Join(s.opts.UserHomeRoot, name)
+	}
+	return user
+}
+
+// state returns the view of the state store of the user a call belongs to
+func (s *Server) state(ctx context.Context) *stateStore {
+	return s.store.forUser(UserName(ctx))
+}
+
+// userEnv returns the environment the devpod commands of a user run with,
+// creating the user's DEVPOD_HOME on first use
+func (s *Server) userEnv(ctx context.Context) ([]string, error) {
+	home := s.user(ctx).DevPodHome
+	if home == "" {
+		return nil, nil
+	}
+	if err := os.MkdirAll(home, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create DEVPOD_HOME of user %s: %w", UserName(ctx), err)
+	}
+	return []string{"DEVPOD_HOME=" + home}, nil
+}
+
+// defaultDevPodHome is the DEVPOD_HOME of devpod commands run without a user
+func defaultDevPodHome() string {
+	if home := os.Getenv("DEVPOD_HOME"); home != "" {
+		return home
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".devpod")
+	}
+	return ""
+}
+
+// whoami describes the identity a call runs as: its user, session and
+// client, and the DEVPOD_HOME and devpod context its commands use
+func (s *Server) whoami(ctx context.Context) map[string]interface{} {
+	user := s.user(ctx)
+	session := s.session(ctx)
+
+	home := user.DevPodHome
+	if home == "" {
+		home = defaultDevPodHome()
+	}
+	devpodContext, contextSource := session.Context, "session"
+	if devpodContext == "" {
+		devpodContext, contextSource = user.Context, "user"
+	}
+	if devpodContext == "" {
+		// devpod's own active context applies
+		contextSource = "devpod"
+	}
+
+	result := map[string]interface{}{
+		"authenticated": UserName(ctx) != "",
+		"multiUser":     s.multiUser(),
+		"session":       SessionID(ctx),
+		"devpodHome":    home,
+		"contextSource": contextSource,
+	}
+	if name := UserName(ctx); name != "" {
+		result["user"] = name
+	}
+	if session.Client != "" {
+		result["client"] = session.Client
+	}
+	if devpodContext != "" {
+		result["context"] = devpodContext
+	}
+	return result
+}
+
+// registerUserTools registers the tool that reports the identity calls run as
+func (s *Server) registerUserTools() {
+	// Report the identity calls run as
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_whoami",
+		Description: "Show the authenticated user, session and client this connection runs as, and the DEVPOD_HOME and devpod context its commands use",
+		InputSchema: map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		return s.whoami(ctx), nil
+	})
+}
@@ -0,0 +1,208 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/protobomb/mcp-server-framework/pkg/transport"
+)
+
+func TestLoadUsers(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "users.json")
+	os.WriteFile(path, []byte(`{"alice":{"token":"a","context":"team"},"bob":{"token":"b","devpodHome":"/srv/bob"}}`), 0o600)
+	users, err := LoadUsers(path)
+	if err != nil || users["alice"].Context != "team" || users["bob"].DevPodHome != "/srv/bob" {
+		t.Fatalf("Unexpected users %+v, %v", users, err)
+	}
+
+	for _, content := range []string{
+		`{"../root":{"token":"a"}}`,
+		`{"alice":{"token":"same"},"bob":{"token":"same"}}`,
+	} {
+		os.WriteFile(path, []byte(content), 0o600)
+		if _, err := LoadUsers(path); err == nil {
+			t.Errorf("Expected %s to be rejected", content)
+		}
+	}
+}
+
+func TestAuthenticator(t *testing.T) {
+	users := map[string]User{"alice": {Token: "alice-token"}}
+	for _, tc := range []struct {
+		header, value string
+		homeRoot      string
+		want          string
+	}{
+		{"Authorization", "Bearer alice-token", "", "alice"},
+		{"Authorization", "Bearer wrong", "", ""},
+		{"X-Forwarded-User", "alice", "", "alice"},
+		{"X-Forwarded-User", "carol", "", ""},
+		{"X-Forwarded-User", "carol", "/srv/devpod", "carol"},
+		{"X-Forwarded-User", "../etc", "/srv/devpod", ""},
+	} {
+		req := httptest.NewRequest("POST", "/mcp", nil)
+		req.Header.Set(tc.header, tc.value)
+		user, err := Authenticator(users, "X-Forwarded-User", tc.homeRoot)(req)
+		if user != tc.want || (err == nil) != (tc.want != "") {
+			t.Errorf("%s: %s: expected %q, got %q (%v)", tc.header, tc.value, tc.want, user, err)
+		}
+	}
+
+	if _, err := Authenticator(users, "", "")(httptest.NewRequest("POST", "/mcp", nil)); err == nil {
+		t.Error("Expected requests without a token to be refused")
+	}
+}
+
+func TestUsersRunWithTheirOwnDevPodHome(t *testing.T) {
+	root := t.TempDir()
+	runner := &envRunner{fakeRunner: fakeRunner{outputs: map[string]string{
+		"list --output json":                    "[]",
+		"list --output json --context team-ctx": "[]",
+	}}}
+	s := New(transport.NewSTDIOTransport(), Options{
+		Runner:       runner,
+		StatePath:    filepath.Join(t.TempDir(), "state.json"),
+		Users:        map[string]User{"alice": {Context: "team-ctx"}},
+		UserHomeRoot: root,
+	})
+	call := s.MCP().GetHandler("tools/call")
+	alice := WithUser(WithSessionID(context.Background(), "s1"), "alice")
+
+	if _, err := call(alice, json.RawMessage(`{"name":"devpod_listWorkspaces","arguments":{}}`)); err != nil {
+		t.Fatalf("listWorkspaces failed: %v", err)
+	}
+	home := filepath.Join(root, "alice")
+	if runner.env != "DEVPOD_HOME="+home {
+		t.Errorf("Expected DEVPOD_HOME=%s, got %q", home, runner.env)
+	}
+	if last := runner.calls[len(runner.calls)-1]; strings.Join(last, " ") != "list --output json --context team-ctx" {
+		t.Errorf("Expected the user's context, got %v", last)
+	}
+	if info, err := os.Stat(home); err != nil || !info.IsDir() {
+		t.Errorf("Expected the user's DEVPOD_HOME to be created: %v", err)
+	}
+
+	result, err := s.MCP().GetHandler("devpod_whoami")(alice, nil)
+	if err != nil {
+		t.Fatalf("whoami failed: %v", err)
+	}
+	identity := result.(map[string]interface{})
+	if identity["user"] != "alice" || identity["devpodHome"] != home || identity["context"] != "team-ctx" || identity["contextSource"] != "user" || identity["multiUser"] != true {
+		t.Errorf("Unexpected identity %v", identity)
+	}
+
+	// calls without a user keep the server's own configuration
+	if _, err := call(context.Background(), json.RawMessage(`{"name":"devpod_listWorkspaces","arguments":{}}`)); err != nil {
+		t.Fatalf("listWorkspaces failed: %v", err)
+	}
+	if runner.env != "" {
+		t.Errorf("Expected no DEVPOD_HOME without a user, got %q", runner.env)
+	}
+}
+
+// userTransport records the messages the server sends to each user's sessions
+type userTransport struct {
+	recordingTransport
+	users []string
+}
+
+func (u *userTransport) SendToUser(user string, message []byte) error {
+	u.mu.Lock()
+	u.users = append(u.users, user)
+	u.mu.Unlock()
+	return u.Send(message)
+}
+
+func TestUsersHaveTheirOwnState(t *testing.T) {
+	t.Setenv(secretKeyEnv, "")
+	root := t.TempDir()
+	var mu sync.Mutex
+	homes := make(map[string]string)
+	runner := runnerFunc(func(ctx context.Context, args []string) ([]byte, []byte, error) {
+		mu.Lock()
+		homes[strings.Join(args, " ")] = strings.Join(CommandEnv(ctx), " ")
+		mu.Unlock()
+		if args[0] == "status" {
+			return []byte(`{"state":"Running"}`), nil, nil
+		}
+		return nil, nil, nil
+	})
+	transport := &userTransport{}
+	s := New(transport, Options{
+		Runner:       runner,
+		StatePath:    filepath.Join(t.TempDir(), "state.json"),
+		UserHomeRoot: root,
+	})
+	s.started.Store(true)
+	alice := WithUser(WithSessionID(context.Background(), "s1"), "alice")
+	bob := WithUser(WithSessionID(context.Background(), "s2"), "bob")
+
+	// Both users have a workspace named api
+	for _, ctx := range []context.Context{alice, bob} {
+		s.state(ctx).SetMetadata("api", workspaceMetadata{Tags: []string{UserName(ctx)}})
+		s.state(ctx).SetCreator("api", s.creator(ctx))
+	}
+	if _, err := s.MCP().GetHandler("devpod_setSecret")(alice, json.RawMessage(`{"name":"TOKEN","value":"alice-token"}`)); err != nil {
+		t.Fatalf("devpod_setSecret failed: %v", err)
+	}
+	past := time.Now().Add(-time.Minute).UTC()
+	s.state(bob).SetSchedule(scheduledOperation{ID: "sch-bob", Workspace: "api", User: "bob", Action: "stop", At: &past, NextRun: &past, Created: past})
+
+	// Alice's secret stays hers
+	if secrets, err := s.workspaceSecrets(bob, "api"); err != nil || len(secrets) != 0 {
+		t.Errorf("Expected no secrets for bob's api, got %v, %v", secrets, err)
+	}
+	if _, err := s.MCP().GetHandler("devpod_deleteSecret")(bob, json.RawMessage(`{"name":"TOKEN"}`)); err == nil {
+		t.Error("Expected bob not to delete alice's secret")
+	}
+	if secrets, err := s.workspaceSecrets(alice, "api"); err != nil || len(secrets) != 1 {
+		t.Errorf("Expected alice's secret for her api, got %v, %v", secrets, err)
+	}
+
+	// Deleting alice's api leaves bob's alone and tells only alice
+	s.recordDeletion(alice, "api", "Workspace deleted", nil)
+	if tags := s.state(alice).Metadata("api").Tags; len(tags) != 0 {
+		t.Errorf("Expected alice's tags to be cleared, got %v", tags)
+	}
+	if tags := s.state(bob).Metadata("api").Tags; len(tags) != 1 || tags[0] != "bob" {
+		t.Errorf("Expected bob's tags to stay, got %v", tags)
+	}
+	if creator := s.state(bob).Creator("api"); creator == nil || creator.User != "bob" {
+		t.Errorf("Expected bob's creator to stay, got %+v", creator)
+	}
+	if schedules := s.state(bob).Schedules(); len(schedules) != 1 {
+		t.Errorf("Expected bob's schedule to stay, got %v", schedules)
+	}
+	if len(transport.users) == 0 {
+		t.Fatal("Expected lifecycle notifications")
+	}
+	for _, user := range transport.users {
+		if user != "alice" {
+			t.Errorf("Expected notifications only for alice, got one for %q", user)
+		}
+	}
+
+	// Timelines are read per user
+	read := s.MCP().GetHandler("resources/read")
+	result, err := read(bob, json.RawMessage(`{"uri":"devpod://workspace/api/timeline"}`))
+	if err != nil {
+		t.Fatalf("resources/read failed: %v", err)
+	}
+	if text := result.(map[string]interface{})["contents"].([]map[string]interface{})[0]["text"].(string); strings.Contains(text, "deleted") {
+		t.Errorf("Expected bob's timeline without alice's deletion, got %s", text)
+	}
+
+	// Bob's schedule runs with his DEVPOD_HOME
+	s.runDueSchedules(context.Background(), time.Now().UTC())
+	if home := homes["stop api"]; home != "DEVPOD_HOME="+filepath.Join(root, "bob") {
+		t.Errorf("Expected bob's schedule to run with his DEVPOD_HOME, got %q", home)
+	}
+}
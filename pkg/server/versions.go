@@ -0,0 +1,191 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+// toolRequirements is the oldest devpod release that supports the
+// subcommands and flags each tool relies on. Tools not listed work with any
+// release.
+var toolRequirements = map[string]string{
+	// status --output json
+	"devpod_status": "v0.3.0",
+	// build --repository --platform
+	"devpod_triggerPrebuild": "v0.4.0",
+	// troubleshoot
+	"devpod_troubleshoot": "v0.5.0",
+}
+
+// devpodVersion returns the installed devpod CLI version. It is detected on
+// start and on first use when detection failed, e.g. because the CLI was
+// installed later.
+func (s *Server) devpodVersion(ctx context.Context) (string, error) {
+	s.versionMu.Lock()
+	version := s.cliVersion
+	s.versionMu.Unlock()
+	if version != "" {
+		return version, nil
+	}
+
+	output, err := s.output(ctx, []string{"version"})
+	if err != nil {
+		return "", err
+	}
+	version = strings.TrimSpace(string(output))
+	s.versionMu.Lock()
+	s.cliVersion = version
+	s.versionMu.Unlock()
+	return version, nil
+}
+
+// resetDevPodVersion forgets the detected version so the next call detects
+// it again
+func (s *Server) resetDevPodVersion() {
+	s.versionMu.Lock()
+	s.cliVersion = ""
+	s.availabilityErr = nil
+	s.versionMu.Unlock()
+}
+
+// availabilityRecheck is how long a failed detection is trusted before the
+// devpod CLI is looked for again
+const availabilityRecheck = 10 * time.Second
+
+// devpodAvailable returns the version of the devpod CLI, or why it cannot be
+// run. Failures are cached briefly so a missing CLI isn't probed on every
+// call, while one installed after start is picked up without a restart.
+func (s *Server) devpodAvailable(ctx context.Context) (string, error) {
+	s.versionMu.Lock()
+	if s.cliVersion == "" && s.availabilityErr != nil && time.Since(s.availabilityChecked) < availabilityRecheck {
+		err := s.availabilityErr
+		s.versionMu.Unlock()
+		return "", err
+	}
+	s.versionMu.Unlock()
+
+	version, err := s.devpodVersion(ctx)
+	s.versionMu.Lock()
+	s.availabilityErr, s.availabilityChecked = err, time.Now()
+	s.versionMu.Unlock()
+	return version, err
+}
+
+// devpodPath returns the devpod binary commands run, resolved through PATH.
+// It returns "" when the server runs devpod through a custom Runner.
+func (s *Server) devpodPath() (string, error) {
+	runner, ok := s.runner.(*ExecRunner)
+	if !ok {
+		return "", nil
+	}
+	return runner.resolve()
+}
+
+// versionSupports reports whether installed is at least required. Versions
+// that cannot be parsed, such as development builds, are assumed to support
+// everything.
+func versionSupports(installed, required string) bool {
+	if _, ok := parseVersion(installed); !ok {
+		return true
+	}
+	return !newerVersion(required, installed)
+}
+
+// requireDevPod returns an error naming the required release when the
+// installed devpod CLI is too old for a tool. An undetectable version is left
+// for the tool itself to report.
+func (s *Server) requireDevPod(ctx context.Context, tool string) error {
+	required, ok := toolRequirements[tool]
+	if !ok {
+		return nil
+	}
+	installed, err := s.devpodVersion(ctx)
+	if err != nil || versionSupports(installed, required) {
+		return nil
+	}
+	return mcp.NewRPCError(categoryCodes[CategoryUnsupportedVersion],
+		fmt.Sprintf("%s requires devpod >= %s, found %s: %s", tool, required, installed, CategoryUnsupportedVersion),
+		map[string]interface{}{
+			"category":  CategoryUnsupportedVersion,
+			"required":  required,
+			"installed": installed,
+		})
+}
+
+// toolSupport reports for each version-gated tool the release it requires and
+// whether the installed CLI provides it
+func toolSupport(installed string) map[string]interface{} {
+	support := make(map[string]interface{}, len(toolRequirements))
+	for tool, required := range toolRequirements {
+		support[tool] = map[string]interface{}{
+			"requires":  required,
+			"supported": installed != "" && versionSupports(installed, required),
+		}
+	}
+	return support
+}
+
+// registerVersionTools registers the tools that report and detect the devpod
+// CLI
+func (s *Server) registerVersionTools() {
+	// Report server and devpod CLI versions
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_version",
+		Description: "Show the server and DevPod CLI versions and which tools the installed CLI supports",
+		InputSchema: map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		// Detect again so a CLI upgraded since start is picked up
+		s.resetDevPodVersion()
+		version, err := s.devpodVersion(ctx)
+		if err != nil {
+			return nil, newDevPodError("failed to get devpod version", err, nil)
+		}
+
+		return map[string]interface{}{
+			"serverVersion": s.opts.Version,
+			"devpodVersion": version,
+			"tools":         toolSupport(version),
+		}, nil
+	})
+
+	// Look for the devpod CLI again
+	s.RegisterTool(mcp.Tool{
+		Name:        "devpod_checkAvailability",
+		Description: "Check again whether the DevPod CLI can be run, e.g. after installing it, and report the resolved binary path and version",
+		InputSchema: map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{},
+		},
+	}, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		result := map[string]interface{}{"available": false}
+		path, pathErr := s.devpodPath()
+		if path != "" {
+			result["path"] = path
+			if s.opts.InstallDir != "" {
+				result["managed"] = path == installPath(s.opts.InstallDir)
+			}
+		}
+
+		if err := s.checkDevPodAvailable(ctx); err != nil {
+			if pathErr != nil {
+				err = pathErr
+			}
+			result["error"] = err.Error()
+			result["message"] = "DevPod is not available; install it with devpod_installCLI or put it on the server's PATH"
+			return result, nil
+		}
+		version, _ := s.devpodVersion(ctx)
+		result["available"] = true
+		result["version"] = version
+		result["message"] = fmt.Sprintf("DevPod %s is available", version)
+		return result, nil
+	})
+}
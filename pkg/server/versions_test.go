@@ -0,0 +1,100 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/protobomb/mcp-server-framework/pkg/mcp"
+)
+
+func TestVersionSupports(t *testing.T) {
+	tests := []struct {
+		installed, required string
+		want                bool
+	}{
+		{"v0.5.0", "v0.5.0", true},
+		{"0.6.1", "v0.5.0", true},
+		{"v0.4.9", "v0.5.0", false},
+		{"dev", "v0.5.0", true},
+	}
+	for _, tt := range tests {
+		if got := versionSupports(tt.installed, tt.required); got != tt.want {
+			t.Errorf("versionSupports(%q, %q) = %v; want %v", tt.installed, tt.required, got, tt.want)
+		}
+	}
+}
+
+func TestToolCallRequiresDevPodVersion(t *testing.T) {
+	runner := &fakeRunner{outputs: map[string]string{"version": "v0.4.2\n"}}
+	s := newTestServer(t, runner)
+
+	_, err := s.MCP().GetHandler("tools/call")(context.Background(), json.RawMessage(`{"name":"devpod_troubleshoot","arguments":{"name":"api"}}`))
+	rpcErr, ok := err.(*mcp.RPCError)
+	if !ok || rpcErr.Code != categoryCodes[CategoryUnsupportedVersion] {
+		t.Fatalf("Expected an UnsupportedVersion error, got %v", err)
+	}
+	if !strings.Contains(rpcErr.Message, "requires devpod >= v0.5.0, found v0.4.2") {
+		t.Errorf("Unexpected message %q", rpcErr.Message)
+	}
+	for _, call := range runner.calls {
+		if call[0] == "troubleshoot" {
+			t.Error("Expected troubleshoot not to run on an old CLI")
+		}
+	}
+
+	result, err := s.MCP().GetHandler("devpod_version")(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("devpod_version failed: %v", err)
+	}
+	version := result.(map[string]interface{})
+	if version["devpodVersion"] != "v0.4.2" {
+		t.Errorf("Expected devpod version v0.4.2, got %v", version["devpodVersion"])
+	}
+	tools := version["tools"].(map[string]interface{})
+	if tools["devpod_troubleshoot"].(map[string]interface{})["supported"] != false {
+		t.Errorf("Expected devpod_troubleshoot to be unsupported, got %v", tools["devpod_troubleshoot"])
+	}
+}
+
+func TestDevPodInstalledAfterStart(t *testing.T) {
+	runner := &fakeRunner{
+		outputs:  map[string]string{"version": "v0.6.15\n", "list --output json": "[]"},
+		failures: map[string]string{"version": "devpod: command not found"},
+	}
+	s := newTestServer(t, runner)
+	call := s.MCP().GetHandler("tools/call")
+	list := json.RawMessage(`{"name":"devpod_listWorkspaces","arguments":{}}`)
+
+	for i := 0; i < 2; i++ {
+		if _, err := call(context.Background(), list); err == nil || !strings.Contains(err.Error(), "not available") {
+			t.Fatalf("Expected DevPod to be unavailable, got %v", err)
+		}
+	}
+	probes := 0
+	for _, args := range runner.calls {
+		if args[0] == "version" {
+			probes++
+		}
+	}
+	if probes != 1 {
+		t.Errorf("Expected the failed check to be cached, got %d version calls", probes)
+	}
+
+	runner.mu.Lock()
+	delete(runner.failures, "version")
+	runner.mu.Unlock()
+
+	result, err := s.MCP().GetHandler("devpod_checkAvailability")(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("devpod_checkAvailability failed: %v", err)
+	}
+	availability := result.(map[string]interface{})
+	if availability["available"] != true || availability["version"] != "v0.6.15" {
+		t.Fatalf("Expected DevPod v0.6.15 to be available, got %v", availability)
+	}
+	if _, err := call(context.Background(), list); err != nil {
+		t.Errorf("Expected listing to work after the re-check, got %v", err)
+	}
+}
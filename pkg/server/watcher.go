@@ -0,0 +1,128 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+// workspaceChange describes a workspace state transition
+type workspaceChange struct {
+	Name          string `json:"name"`
+	PreviousState string `json:"previousState"`
+	State         string `json:"state"`
+}
+
+// workspaceWatcher polls devpod for the workspace state of each user and
+// notifies the user's clients of transitions
+type workspaceWatcher struct {
+	server   *Server
+	interval time.Duration
+	// states are the last polled states of each user's workspaces
+	states map[string]map[string]string
+}
+
+func newWorkspaceWatcher(server *Server, interval time.Duration) *workspaceWatcher {
+	return &workspaceWatcher{
+		server:   server,
+		interval: interval,
+		states:   make(map[string]map[string]string),
+	}
+}
+
+// Run polls until the context is cancelled. The first poll of each user only
+// records a baseline.
+func (w *workspaceWatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		for _, user := range w.server.stateUsers() {
+			w.check(WithUser(ctx, user))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// check polls the workspaces of the user of ctx and notifies their changes
+func (w *workspaceWatcher) check(ctx context.Context) {
+	user := UserName(ctx)
+	states, err := w.poll(ctx)
+	if err != nil {
+		if user != "" {
+			err = fmt.Errorf("workspaces of user %s: %w", user, err)
+		}
+		w.server.reportEvent("warning", "watcher", fmt.Errorf("workspace watcher poll failed: %w", err))
+		return
+	}
+	if previous, ok := w.states[user]; ok {
+		for _, change := range diffWorkspaceStates(previous, states) {
+			w.notify(ctx, change)
+		}
+	}
+	w.states[user] = states
+}
+
+// poll returns the current state of every workspace of the user of ctx
+// keyed by workspace ID
+func (w *workspaceWatcher) poll(ctx context.Context) (map[string]string, error) {
+	output, err := w.server.output(ctx, []string{"list", "--output", "json"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list workspaces: %w", err)
+	}
+
+	var workspaces []DevPodWorkspace
+	if err := json.Unmarshal(output, &workspaces); err != nil {
+		return nil, fmt.Errorf("failed to parse workspace list: %w", err)
+	}
+
+	states := make(map[string]string, len(workspaces))
+	for _, workspace := range workspaces {
+		states[workspace.ID] = w.server.getWorkspaceState(ctx, workspace.ID)
+	}
+	return states, nil
+}
+
+// notify records a state change of a workspace of the user of ctx and emits
+// notifications to the user's clients
+func (w *workspaceWatcher) notify(ctx context.Context, change workspaceChange) {
+	user := UserName(ctx)
+	infof("Workspace %s changed state: %q -> %q", change.Name, change.PreviousState, change.State)
+	w.server.state(ctx).RecordEvent(change.Name, "stateChanged", fmt.Sprintf("State changed from %q to %q", change.PreviousState, change.State))
+	w.server.observeLifecycle(user, change)
+
+	if err := w.server.notifyUser(user, "devpod/workspaceChanged", change); err != nil {
+		log.Printf("WARNING: failed to send workspace change notification: %v", err)
+	}
+	if err := w.server.notifyUser(user, "notifications/resources/updated", map[string]interface{}{
+		"uri": timelineURI(change.Name),
+	}); err != nil {
+		log.Printf("WARNING: failed to send resource update notification: %v", err)
+	}
+}
+
+// diffWorkspaceStates lists transitions between two snapshots. Workspaces
+// missing from the new snapshot are reported with the NotFound state.
+func diffWorkspaceStates(previous, current map[string]string) []workspaceChange {
+	var changes []workspaceChange
+	for name, state := range current {
+		if old, ok := previous[name]; !ok || old != state {
+			changes = append(changes, workspaceChange{Name: name, PreviousState: old, State: state})
+		}
+	}
+	for name, old := range previous {
+		if _, ok := current[name]; !ok {
+			changes = append(changes, workspaceChange{Name: name, PreviousState: old, State: "NotFound"})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Name < changes[j].Name })
+	return changes
+}
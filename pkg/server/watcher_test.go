@@ -0,0 +1,26 @@
+package server
+
+import (
+	"testing"
+)
+
+func TestDiffWorkspaceStates(t *testing.T) {
+	previous := map[string]string{"a": "Running", "b": "Stopped", "c": "Running"}
+	current := map[string]string{"a": "Running", "b": "Running", "d": "Busy"}
+
+	changes := diffWorkspaceStates(previous, current)
+	if len(changes) != 3 {
+		t.Fatalf("Expected 3 changes, got %d: %v", len(changes), changes)
+	}
+
+	expected := []workspaceChange{
+		{Name: "b", PreviousState: "Stopped", State: "Running"},
+		{Name: "c", PreviousState: "Running", State: "NotFound"},
+		{Name: "d", PreviousState: "", State: "Busy"},
+	}
+	for i, change := range changes {
+		if change != expected[i] {
+			t.Errorf("Change %d: expected %v, got %v", i, expected[i], change)
+		}
+	}
+}